@@ -5,6 +5,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"flag"
 	"fmt"
@@ -18,6 +19,7 @@ import (
 	"github.com/joho/godotenv"
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
@@ -414,6 +416,20 @@ func main() {
 		CallbackPort: oauthCallbackPort,
 	}
 
+	if cfg.Cluster.Enabled {
+		var clusterDB *sql.DB
+		if usePostgresStore {
+			clusterDB = pgStoreInst.DB()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		errCluster := cluster.Setup(ctx, cfg.Cluster, clusterDB)
+		cancel()
+		if errCluster != nil {
+			log.Errorf("failed to initialize cluster mode: %v", errCluster)
+			return
+		}
+	}
+
 	// Register the shared token store once so all components use the same persistence backend.
 	if usePostgresStore {
 		sdkAuth.RegisterTokenStore(pgStoreInst)
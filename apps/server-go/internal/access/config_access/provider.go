@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -109,6 +110,24 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 			if authID := strings.TrimSpace(entry.Scope.AuthID); authID != "" {
 				metadata["scope_auth_id"] = authID
 			}
+			if entry.ContextCompaction != nil {
+				metadata["context_compaction"] = strconv.FormatBool(*entry.ContextCompaction)
+			}
+			if policy := strings.ToLower(strings.TrimSpace(entry.ParameterPolicy)); policy != "" {
+				metadata["parameter_policy"] = policy
+			}
+			if entry.Sandbox != nil {
+				metadata["sandbox"] = strconv.FormatBool(*entry.Sandbox)
+			}
+			if len(entry.Policies) > 0 {
+				policies := make([]string, 0, len(entry.Policies))
+				for _, name := range entry.Policies {
+					if name = strings.TrimSpace(name); name != "" {
+						policies = append(policies, name)
+					}
+				}
+				metadata["policies"] = strings.Join(policies, ",")
+			}
 			models := entry.Scope.Models
 			if len(models) > 0 {
 				models = slices.Clone(models)
@@ -12,7 +12,9 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/proxypool"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/geminicli"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upstreamtls"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/proxy"
@@ -631,9 +633,24 @@ func (h *Handler) authByIndex(authIndex string) *coreauth.Auth {
 	return nil
 }
 
+// apiCallTransport returns the transport for upstream calls made on behalf of auth, with its
+// proxy and upstream TLS settings.
 func (h *Handler) apiCallTransport(auth *coreauth.Auth) http.RoundTripper {
-	var proxyCandidates []string
+	rt := upstreamtls.Wrap(h.apiCallProxyTransport(auth))
+	if auth != nil {
+		rt = upstreamtls.Bind(rt, auth.Provider, auth.Attributes, auth.Metadata)
+	}
+	return rt
+}
+
+func (h *Handler) apiCallProxyTransport(auth *coreauth.Auth) http.RoundTripper {
 	if auth != nil {
+		if rt, ok := proxypool.RoundTripper(auth.ID, auth.Provider, auth.ProxyURL); ok {
+			return rt
+		}
+	}
+	var proxyCandidates []string
+	if auth != nil && !proxypool.IsPoolRef(auth.ProxyURL) {
 		if proxyStr := strings.TrimSpace(auth.ProxyURL); proxyStr != "" {
 			proxyCandidates = append(proxyCandidates, proxyStr)
 		}
@@ -0,0 +1,105 @@
+package management
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+const (
+	defaultAuthHealthWindow = 24 * time.Hour
+	maxAuthHealthWindow     = 7 * 24 * time.Hour
+)
+
+// GetAuthHealth returns uptime, time-in-cooldown and event counts per auth over a window
+// (query "window", a Go duration such as 24h or 168h; default 24h, at most 7 days). With
+// "name" set to an auth ID or file name, only that auth is returned, including its timeline.
+// Timelines are kept in memory and start empty after a restart unless cluster mode is enabled,
+// in which case they are restored from the cluster backend.
+func (h *Handler) GetAuthHealth(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	window := defaultAuthHealthWindow
+	if raw := strings.TrimSpace(c.Query("window")); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window"})
+			return
+		}
+		window = parsed
+	}
+	if window > maxAuthHealthWindow {
+		window = maxAuthHealthWindow
+	}
+	now := time.Now()
+	since := now.Add(-window)
+
+	if name := strings.TrimSpace(c.Query("name")); name != "" {
+		auth := h.findAuth(name)
+		if auth == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "auth not found"})
+			return
+		}
+		entry := h.authHealthEntry(auth, since, now)
+		entry["events"] = h.authManager.HealthTimeline(auth.ID, since)
+		c.JSON(http.StatusOK, gin.H{"window": window.String(), "auth": entry})
+		return
+	}
+
+	auths := h.authManager.List()
+	sort.Slice(auths, func(i, j int) bool { return auths[i].ID < auths[j].ID })
+	entries := make([]gin.H, 0, len(auths))
+	for _, auth := range auths {
+		entries = append(entries, h.authHealthEntry(auth, since, now))
+	}
+	c.JSON(http.StatusOK, gin.H{"window": window.String(), "auths": entries})
+}
+
+func (h *Handler) findAuth(name string) *coreauth.Auth {
+	if auth, ok := h.authManager.GetByID(name); ok {
+		return auth
+	}
+	for _, auth := range h.authManager.List() {
+		if auth.FileName == name {
+			return auth
+		}
+	}
+	return nil
+}
+
+func (h *Handler) authHealthEntry(auth *coreauth.Auth, since, now time.Time) gin.H {
+	summary, _ := h.authManager.HealthSummary(auth.ID, since, now)
+	name := strings.TrimSpace(auth.FileName)
+	if name == "" {
+		name = auth.ID
+	}
+	return gin.H{
+		"id":                auth.ID,
+		"auth_index":        auth.Index,
+		"name":              name,
+		"provider":          strings.TrimSpace(auth.Provider),
+		"label":             auth.Label,
+		"status":            auth.Status,
+		"disabled":          auth.Disabled,
+		"uptime_percent":    summary.UptimePercent,
+		"cooldown_seconds":  summary.CooldownSeconds,
+		"cooldown_by_model": summary.CooldownByModel,
+		"counts":            summary.Counts,
+	}
+}
+
+// GetAuthProbes returns the health probe schedule, the upstream calls spent on probing and the
+// last probe result per auth.
+func (h *Handler) GetAuthProbes(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, h.authManager.ProbeStatus())
+}
@@ -0,0 +1,16 @@
+package management
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// GetCapabilities returns the per-provider, per-surface parameter capability matrix together with
+// the configured parameter policy.
+func (h *Handler) GetCapabilities(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(200, handlers.BuildCapabilityMatrix(nil))
+		return
+	}
+	c.JSON(200, handlers.BuildCapabilityMatrix(&h.cfg.SDKConfig))
+}
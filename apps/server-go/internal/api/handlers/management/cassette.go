@@ -0,0 +1,14 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cassette"
+)
+
+// GetCassette returns the upstream record/replay mode, how many exchanges were recorded or
+// replayed, and the replayed requests that had no recorded exchange.
+func (h *Handler) GetCassette(c *gin.Context) {
+	c.JSON(http.StatusOK, cassette.CurrentStatus())
+}
@@ -28,7 +28,45 @@ func (h *Handler) GetConfig(c *gin.Context) {
 		c.JSON(200, gin.H{})
 		return
 	}
-	c.JSON(200, new(*h.cfg))
+	cfgCopy := new(*h.cfg)
+	if !cfgCopy.HasSecretReferences() {
+		c.JSON(200, cfgCopy)
+		return
+	}
+	masked, err := maskSecretReferences(cfgCopy, "", cfgCopy)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "marshal_failed", "message": err.Error()})
+		return
+	}
+	c.JSON(200, masked)
+}
+
+// writeMaskedConfigValue responds with {key: value}, reporting values loaded from ${...}
+// references as the references. path is the YAML path of value in the config.
+func (h *Handler) writeMaskedConfigValue(c *gin.Context, key, path string, value any) {
+	masked, err := maskSecretReferences(h.cfg, path, value)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "marshal_failed", "message": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{key: masked})
+}
+
+// maskSecretReferences converts value to its JSON form with secrets resolved from ${...}
+// references at path replaced by the references.
+func maskSecretReferences(cfg *config.Config, path string, value any) (any, error) {
+	if !cfg.HasSecretReferences() {
+		return value, nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded any
+	if err = json.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	return cfg.MaskSecretValues(path, decoded), nil
 }
 
 type releaseInfo struct {
@@ -119,31 +157,14 @@ func (h *Handler) PutConfigYAML(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_yaml", "message": err.Error()})
 		return
 	}
-	// Validate config using LoadConfigOptional with optional=false to enforce parsing
-	tmpDir := filepath.Dir(h.configFilePath)
-	tmpFile, err := os.CreateTemp(tmpDir, "config-validate-*.yaml")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": err.Error()})
-		return
-	}
-	tempFile := tmpFile.Name()
-	if _, errWrite := tmpFile.Write(body); errWrite != nil {
-		_ = tmpFile.Close()
-		_ = os.Remove(tempFile)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": errWrite.Error()})
+	issues := config.ValidateConfigData(body, filepath.Dir(h.configFilePath))
+	if config.HasValidationErrors(issues) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid_config", "message": "config failed validation", "issues": issues})
 		return
 	}
-	if errClose := tmpFile.Close(); errClose != nil {
-		_ = os.Remove(tempFile)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": errClose.Error()})
-		return
-	}
-	defer func() {
-		_ = os.Remove(tempFile)
-	}()
-	_, err = config.LoadConfigOptional(tempFile, false)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid_config", "message": err.Error()})
+	// Load the candidate through the regular loader so it is sanitized exactly like a reload
+	if _, status, errLoad := h.loadCandidateConfig(body); errLoad != nil {
+		c.JSON(status, gin.H{"error": candidateConfigErrorCode(status), "message": errLoad.Error()})
 		return
 	}
 	h.mu.Lock()
@@ -159,7 +180,45 @@ func (h *Handler) PutConfigYAML(c *gin.Context) {
 		return
 	}
 	h.cfg = newCfg
-	c.JSON(http.StatusOK, gin.H{"ok": true, "changed": []string{"config"}})
+	resp := gin.H{"ok": true, "changed": []string{"config"}}
+	if len(issues) > 0 {
+		resp["warnings"] = issues
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// loadCandidateConfig loads body through the regular config loader using a temporary file
+// next to config.yaml, so candidates are sanitized exactly like a real reload.
+// It returns the HTTP status to use when loading fails.
+func (h *Handler) loadCandidateConfig(body []byte) (*config.Config, int, error) {
+	tmpDir := filepath.Dir(h.configFilePath)
+	tmpFile, err := os.CreateTemp(tmpDir, "config-validate-*.yaml")
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	tempFile := tmpFile.Name()
+	defer func() {
+		_ = os.Remove(tempFile)
+	}()
+	if _, errWrite := tmpFile.Write(body); errWrite != nil {
+		_ = tmpFile.Close()
+		return nil, http.StatusInternalServerError, errWrite
+	}
+	if errClose := tmpFile.Close(); errClose != nil {
+		return nil, http.StatusInternalServerError, errClose
+	}
+	cfg, err := config.LoadConfigOptional(tempFile, false)
+	if err != nil {
+		return nil, http.StatusUnprocessableEntity, err
+	}
+	return cfg, http.StatusOK, nil
+}
+
+func candidateConfigErrorCode(status int) string {
+	if status == http.StatusUnprocessableEntity {
+		return "invalid_config"
+	}
+	return "write_failed"
 }
 
 // GetConfigYAML returns the raw config.yaml file bytes without re-encoding.
@@ -320,7 +379,9 @@ func (h *Handler) PutRoutingStrategy(c *gin.Context) {
 }
 
 // Proxy URL
-func (h *Handler) GetProxyURL(c *gin.Context) { c.JSON(200, gin.H{"proxy-url": h.cfg.ProxyURL}) }
+func (h *Handler) GetProxyURL(c *gin.Context) {
+	h.writeMaskedConfigValue(c, "proxy-url", "proxy-url", h.cfg.ProxyURL)
+}
 func (h *Handler) PutProxyURL(c *gin.Context) {
 	h.updateStringField(c, func(v string) { h.cfg.ProxyURL = v })
 }
@@ -105,7 +105,9 @@ func (h *Handler) deleteFromStringList(c *gin.Context, target *[]string, after f
 }
 
 // api-keys
-func (h *Handler) GetAPIKeys(c *gin.Context) { c.JSON(200, gin.H{"api-keys": h.cfg.APIKeys}) }
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	h.writeMaskedConfigValue(c, "api-keys", "api-keys", h.cfg.APIKeys)
+}
 func (h *Handler) PutAPIKeys(c *gin.Context) {
 	h.putStringList(c, func(v []string) {
 		h.cfg.APIKeys = append([]string(nil), v...)
@@ -125,7 +127,7 @@ func (h *Handler) GetClientAPIKeys(c *gin.Context) {
 		c.JSON(200, gin.H{"client-api-keys": []config.ClientAPIKey{}})
 		return
 	}
-	c.JSON(200, gin.H{"client-api-keys": managed})
+	h.writeMaskedConfigValue(c, "client-api-keys", "client-api-keys", managed)
 }
 
 func (h *Handler) PutClientAPIKeys(c *gin.Context) {
@@ -206,7 +208,7 @@ func managementClientAPIKeys(cfg *config.Config) []config.ClientAPIKey {
 
 // gemini-api-key: []GeminiKey
 func (h *Handler) GetGeminiKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"gemini-api-key": h.cfg.GeminiKey})
+	h.writeMaskedConfigValue(c, "gemini-api-key", "gemini-api-key", h.cfg.GeminiKey)
 }
 func (h *Handler) PutGeminiKeys(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -329,7 +331,7 @@ func (h *Handler) DeleteGeminiKey(c *gin.Context) {
 
 // claude-api-key: []ClaudeKey
 func (h *Handler) GetClaudeKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"claude-api-key": h.cfg.ClaudeKey})
+	h.writeMaskedConfigValue(c, "claude-api-key", "claude-api-key", h.cfg.ClaudeKey)
 }
 func (h *Handler) PutClaudeKeys(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -448,7 +450,7 @@ func (h *Handler) DeleteClaudeKey(c *gin.Context) {
 
 // openai-compatibility: []OpenAICompatibility
 func (h *Handler) GetOpenAICompat(c *gin.Context) {
-	c.JSON(200, gin.H{"openai-compatibility": normalizedOpenAICompatibilityEntries(h.cfg.OpenAICompatibility)})
+	h.writeMaskedConfigValue(c, "openai-compatibility", "openai-compatibility", normalizedOpenAICompatibilityEntries(h.cfg.OpenAICompatibility))
 }
 func (h *Handler) PutOpenAICompat(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -574,7 +576,7 @@ func (h *Handler) DeleteOpenAICompat(c *gin.Context) {
 
 // vertex-api-key: []VertexCompatKey
 func (h *Handler) GetVertexCompatKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"vertex-api-key": h.cfg.VertexCompatAPIKey})
+	h.writeMaskedConfigValue(c, "vertex-api-key", "vertex-api-key", h.cfg.VertexCompatAPIKey)
 }
 func (h *Handler) PutVertexCompatKeys(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -887,7 +889,7 @@ func (h *Handler) DeleteOAuthModelAlias(c *gin.Context) {
 
 // codex-api-key: []CodexKey
 func (h *Handler) GetCodexKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"codex-api-key": h.cfg.CodexKey})
+	h.writeMaskedConfigValue(c, "codex-api-key", "codex-api-key", h.cfg.CodexKey)
 }
 func (h *Handler) PutCodexKeys(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -1155,7 +1157,7 @@ func (h *Handler) GetAmpCode(c *gin.Context) {
 		c.JSON(200, gin.H{"ampcode": config.AmpCode{}})
 		return
 	}
-	c.JSON(200, gin.H{"ampcode": h.cfg.AmpCode})
+	h.writeMaskedConfigValue(c, "ampcode", "ampcode", h.cfg.AmpCode)
 }
 
 // GetAmpUpstreamURL returns the ampcode upstream URL.
@@ -1164,7 +1166,7 @@ func (h *Handler) GetAmpUpstreamURL(c *gin.Context) {
 		c.JSON(200, gin.H{"upstream-url": ""})
 		return
 	}
-	c.JSON(200, gin.H{"upstream-url": h.cfg.AmpCode.UpstreamURL})
+	h.writeMaskedConfigValue(c, "upstream-url", "ampcode.upstream-url", h.cfg.AmpCode.UpstreamURL)
 }
 
 // PutAmpUpstreamURL updates the ampcode upstream URL.
@@ -1184,7 +1186,7 @@ func (h *Handler) GetAmpUpstreamAPIKey(c *gin.Context) {
 		c.JSON(200, gin.H{"upstream-api-key": ""})
 		return
 	}
-	c.JSON(200, gin.H{"upstream-api-key": h.cfg.AmpCode.UpstreamAPIKey})
+	h.writeMaskedConfigValue(c, "upstream-api-key", "ampcode.upstream-api-key", h.cfg.AmpCode.UpstreamAPIKey)
 }
 
 // PutAmpUpstreamAPIKey updates the ampcode upstream API key.
@@ -1307,7 +1309,7 @@ func (h *Handler) GetAmpUpstreamAPIKeys(c *gin.Context) {
 		c.JSON(200, gin.H{"upstream-api-keys": []config.AmpUpstreamAPIKeyEntry{}})
 		return
 	}
-	c.JSON(200, gin.H{"upstream-api-keys": h.cfg.AmpCode.UpstreamAPIKeys})
+	h.writeMaskedConfigValue(c, "upstream-api-keys", "ampcode.upstream-api-keys", h.cfg.AmpCode.UpstreamAPIKeys)
 }
 
 // PutAmpUpstreamAPIKeys replaces all ampcode upstream API keys mappings.
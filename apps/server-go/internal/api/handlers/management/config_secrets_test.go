@@ -0,0 +1,46 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestManagementKeyListsReportSecretReferences(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("CLIPROXY_TEST_KEY", "env-secret")
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	raw := "api-keys:\n  - \"${ENV:CLIPROXY_TEST_KEY}\"\n  - plain-key\n" +
+		"claude-api-key:\n  - api-key: \"${ENV:CLIPROXY_TEST_KEY}\"\n    prefix: env-secret\n"
+	if err := os.WriteFile(configFile, []byte(raw), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	h := &Handler{cfg: cfg}
+
+	for name, handler := range map[string]gin.HandlerFunc{"api-keys": h.GetAPIKeys, "claude-api-key": h.GetClaudeKeys} {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v0/management/"+name, nil)
+		handler(c)
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || !strings.Contains(body, "${ENV:CLIPROXY_TEST_KEY}") {
+			t.Fatalf("%s: status = %d body = %s", name, rec.Code, body)
+		}
+		if name == "api-keys" && strings.Contains(body, "env-secret") {
+			t.Fatalf("%s leaked the resolved secret: %s", name, body)
+		}
+		if name == "claude-api-key" && !strings.Contains(body, `"prefix":"env-secret"`) {
+			t.Fatalf("%s masked an unrelated field: %s", name, body)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package management
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/diff"
+)
+
+// GetConfigSchema returns the JSON Schema describing config.yaml.
+func (h *Handler) GetConfigSchema(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, config.JSONSchema())
+}
+
+// PostConfigYAMLValidate performs a dry run of PUT /config.yaml.
+// It validates the candidate YAML and reports the change details and runtime effects
+// relative to the active configuration without writing or applying anything.
+func (h *Handler) PostConfigYAMLValidate(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_yaml", "message": "cannot read request body"})
+		return
+	}
+
+	issues := config.ValidateConfigData(body, filepath.Dir(h.configFilePath))
+	if issues == nil {
+		issues = []config.ValidationIssue{}
+	}
+	valid := !config.HasValidationErrors(issues)
+	resp := gin.H{
+		"valid":  valid,
+		"issues": issues,
+	}
+	if !valid {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	candidate, status, errLoad := h.loadCandidateConfig(body)
+	if errLoad != nil {
+		if status != http.StatusUnprocessableEntity {
+			c.JSON(status, gin.H{"error": candidateConfigErrorCode(status), "message": errLoad.Error()})
+			return
+		}
+		resp["valid"] = false
+		resp["issues"] = append(issues, config.ValidationIssue{Path: "$", Severity: config.ValidationSeverityError, Message: errLoad.Error()})
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	h.mu.Lock()
+	current := h.cfg
+	h.mu.Unlock()
+	changes := diff.BuildConfigChangeDetails(current, candidate)
+	if changes == nil {
+		changes = []string{}
+	}
+	resp["changes"] = changes
+	resp["effects"] = diff.BuildConfigChangeEffects(current, candidate)
+	c.JSON(http.StatusOK, resp)
+}
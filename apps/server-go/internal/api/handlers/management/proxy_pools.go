@@ -0,0 +1,132 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/proxypool"
+)
+
+// GetProxyPools returns the configured proxy pools, the health and usage of each member, and
+// which member serves each credential.
+func (h *Handler) GetProxyPools(c *gin.Context) {
+	assignments := make([]gin.H, 0)
+	if h.authManager != nil {
+		auths := h.authManager.List()
+		sort.Slice(auths, func(i, j int) bool { return auths[i].ID < auths[j].ID })
+		for _, auth := range auths {
+			assignment, ok := proxypool.Resolve(auth.ID, auth.Provider, auth.ProxyURL)
+			if !ok {
+				continue
+			}
+			assignments = append(assignments, gin.H{
+				"auth_id":  auth.ID,
+				"label":    auth.Label,
+				"provider": auth.Provider,
+				"pool":     assignment.Pool,
+				"member":   assignment.Member,
+				"failover": assignment.Failover,
+			})
+		}
+	}
+	pools := h.cfg.ProxyPools
+	if pools == nil {
+		pools = []config.ProxyPool{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"proxy-pools": pools,
+		"status":      proxypool.Status(),
+		"assignments": assignments,
+	})
+}
+
+// PutProxyPools replaces all proxy pools.
+func (h *Handler) PutProxyPools(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	var pools []config.ProxyPool
+	if err = json.Unmarshal(data, &pools); err != nil {
+		var wrapper struct {
+			Items []config.ProxyPool `json:"items"`
+		}
+		if errWrapper := json.Unmarshal(data, &wrapper); errWrapper != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+		pools = wrapper.Items
+	}
+	if !validProxyPools(c, pools) {
+		return
+	}
+	h.cfg.ProxyPools = pools
+	h.persist(c)
+}
+
+// PatchProxyPools adds a pool, or replaces the pool with the same name.
+func (h *Handler) PatchProxyPools(c *gin.Context) {
+	var body struct {
+		Value *config.ProxyPool `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	pools := append([]config.ProxyPool(nil), h.cfg.ProxyPools...)
+	replaced := false
+	for i := range pools {
+		if strings.EqualFold(strings.TrimSpace(pools[i].Name), strings.TrimSpace(body.Value.Name)) {
+			pools[i] = *body.Value
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		pools = append(pools, *body.Value)
+	}
+	if !validProxyPools(c, pools) {
+		return
+	}
+	h.cfg.ProxyPools = pools
+	h.persist(c)
+}
+
+// DeleteProxyPools removes the pool given by query "name".
+func (h *Handler) DeleteProxyPools(c *gin.Context) {
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing name"})
+		return
+	}
+	for i := range h.cfg.ProxyPools {
+		if strings.EqualFold(strings.TrimSpace(h.cfg.ProxyPools[i].Name), name) {
+			h.cfg.ProxyPools = append(h.cfg.ProxyPools[:i:i], h.cfg.ProxyPools[i+1:]...)
+			if len(h.cfg.ProxyPools) == 0 {
+				h.cfg.ProxyPools = nil
+			}
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "pool not found"})
+}
+
+// validProxyPools reports config validation errors for pools with 400.
+func validProxyPools(c *gin.Context, pools []config.ProxyPool) bool {
+	issues := (&config.Config{ProxyPools: pools}).Validate()
+	if !config.HasValidationErrors(issues) {
+		return true
+	}
+	messages := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "invalid proxy pools", "issues": messages})
+	return false
+}
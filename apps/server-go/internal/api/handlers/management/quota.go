@@ -1,6 +1,10 @@
 package management
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
 
 // Quota exceeded toggles
 func (h *Handler) GetSwitchProject(c *gin.Context) {
@@ -16,3 +20,13 @@ func (h *Handler) GetSwitchPreviewModel(c *gin.Context) {
 func (h *Handler) PutSwitchPreviewModel(c *gin.Context) {
 	h.updateBoolField(c, func(v bool) { h.cfg.QuotaExceeded.SwitchPreviewModel = v })
 }
+
+// GetQuotaForecast returns each credential's learned consumption rate, remaining quota estimate
+// and forecasted time to exhaustion.
+func (h *Handler) GetQuotaForecast(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"forecasts": h.authManager.QuotaForecasts()})
+}
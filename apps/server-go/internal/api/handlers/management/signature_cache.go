@@ -0,0 +1,30 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+)
+
+// GetSignatureCache returns the thinking-signature cache configuration, entry counts per model
+// group, and hit/miss counters.
+func (h *Handler) GetSignatureCache(c *gin.Context) {
+	c.JSON(http.StatusOK, cache.GetSignatureCacheStats())
+}
+
+// GetSignatureCacheGroup lists the cached signatures of one model group, most recently used
+// first. Signatures are truncated to a short preview.
+func (h *Handler) GetSignatureCacheGroup(c *gin.Context) {
+	group := strings.TrimSpace(c.Param("group"))
+	entries := cache.ListSignatureCacheEntries(group)
+	c.JSON(http.StatusOK, gin.H{"group": group, "count": len(entries), "entries": entries})
+}
+
+// DeleteSignatureCache clears one model group, or the whole cache when no group is given.
+func (h *Handler) DeleteSignatureCache(c *gin.Context) {
+	group := strings.TrimSpace(c.Param("group"))
+	removed := cache.ClearSignatureGroup(group)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "group": group, "removed": removed})
+}
@@ -24,8 +24,13 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cassette"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/notify"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/proxypool"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upstreamtls"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -51,6 +56,7 @@ type serverOptionConfig struct {
 	keepAliveTimeout     time.Duration
 	keepAliveOnTimeout   func()
 	postAuthHook         auth.PostAuthHook
+	notifier             *notify.Notifier
 }
 
 // ServerOption customises HTTP server construction.
@@ -118,6 +124,14 @@ func WithPostAuthHook(hook auth.PostAuthHook) ServerOption {
 	}
 }
 
+// WithNotifier sets the notifier configured from the notifications section. Default is the
+// process-wide notify.Default().
+func WithNotifier(notifier *notify.Notifier) ServerOption {
+	return func(cfg *serverOptionConfig) {
+		cfg.notifier = notifier
+	}
+}
+
 // Server represents the main API server.
 // It encapsulates the Gin engine, HTTP server, handlers, and configuration.
 type Server struct {
@@ -169,6 +183,9 @@ type Server struct {
 
 	localPassword string
 
+	// notifier delivers incident alerts and follows the notifications config.
+	notifier *notify.Notifier
+
 	keepAliveEnabled   bool
 	keepAliveTimeout   time.Duration
 	keepAliveOnTimeout func()
@@ -243,6 +260,10 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		configFilePath:      configFilePath,
 		envManagementSecret: envManagementSecret,
 		wsRoutes:            make(map[string]struct{}),
+		notifier:            optionState.notifier,
+	}
+	if s.notifier == nil {
+		s.notifier = notify.Default()
 	}
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
 	// Save initial YAML snapshot
@@ -252,6 +273,11 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		authManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
 	}
 	auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	s.applySignatureCacheConfig(cfg)
+	s.applyCassetteConfig(cfg)
+	proxypool.Configure(cfg.ProxyPools)
+	s.applyUpstreamTLSConfig(cfg)
+	s.notifier.Configure(cfg.Notifications)
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
 	if optionState.localPassword != "" {
@@ -339,6 +365,11 @@ func (s *Server) setupRoutes() {
 		v1.POST("/conversations/:conversation_id/items", openaiResponsesHandlers.AddConversationItems)
 		v1.GET("/conversations/:conversation_id/items/:item_id", openaiResponsesHandlers.GetConversationItem)
 		v1.DELETE("/conversations/:conversation_id/items/:item_id", openaiResponsesHandlers.DeleteConversationItem)
+		v1.POST("/files", openaiHandlers.UploadFile)
+		v1.GET("/files", openaiHandlers.ListFiles)
+		v1.GET("/files/:file_id", openaiHandlers.GetFile)
+		v1.GET("/files/:file_id/content", openaiHandlers.GetFileContent)
+		v1.DELETE("/files/:file_id", openaiHandlers.DeleteFile)
 	}
 
 	// Gemini compatible API routes
@@ -348,6 +379,19 @@ func (s *Server) setupRoutes() {
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/*action", geminiHandlers.GeminiHandler)
 		v1beta.GET("/models/*action", geminiHandlers.GeminiGetHandler)
+		v1beta.GET("/files", geminiHandlers.ListFiles)
+		v1beta.GET("/files/:name", geminiHandlers.GetFile)
+		v1beta.DELETE("/files/:name", geminiHandlers.DeleteFile)
+		v1beta.POST("/cachedContents", geminiHandlers.CreateCachedContent)
+		v1beta.GET("/cachedContents", geminiHandlers.ListCachedContents)
+		v1beta.GET("/cachedContents/:name", geminiHandlers.GetCachedContent)
+		v1beta.PATCH("/cachedContents/:name", geminiHandlers.UpdateCachedContent)
+		v1beta.DELETE("/cachedContents/:name", geminiHandlers.DeleteCachedContent)
+	}
+	geminiUpload := s.engine.Group("/upload/v1beta")
+	geminiUpload.Use(AuthMiddleware(s.accessManager))
+	{
+		geminiUpload.POST("/files", geminiHandlers.UploadFile)
 	}
 
 	// Root endpoint
@@ -439,7 +483,14 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/config", s.mgmt.GetConfig)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
+		mgmt.POST("/config.yaml/validate", s.mgmt.PostConfigYAMLValidate)
+		mgmt.GET("/config/schema", s.mgmt.GetConfigSchema)
 		mgmt.GET("/latest-version", s.mgmt.GetLatestVersion)
+		mgmt.GET("/capabilities", s.mgmt.GetCapabilities)
+		mgmt.GET("/signature-cache", s.mgmt.GetSignatureCache)
+		mgmt.GET("/signature-cache/:group", s.mgmt.GetSignatureCacheGroup)
+		mgmt.DELETE("/signature-cache", s.mgmt.DeleteSignatureCache)
+		mgmt.DELETE("/signature-cache/:group", s.mgmt.DeleteSignatureCache)
 
 		mgmt.GET("/debug", s.mgmt.GetDebug)
 		mgmt.PUT("/debug", s.mgmt.PutDebug)
@@ -552,6 +603,14 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
 		mgmt.PATCH("/auth-files/status", s.mgmt.PatchAuthFileStatus)
 		mgmt.PATCH("/auth-files/fields", s.mgmt.PatchAuthFileFields)
+		mgmt.GET("/auth-health", s.mgmt.GetAuthHealth)
+		mgmt.GET("/auth-probes", s.mgmt.GetAuthProbes)
+		mgmt.GET("/quota-forecast", s.mgmt.GetQuotaForecast)
+		mgmt.GET("/cassette", s.mgmt.GetCassette)
+		mgmt.GET("/proxy-pools", s.mgmt.GetProxyPools)
+		mgmt.PUT("/proxy-pools", s.mgmt.PutProxyPools)
+		mgmt.PATCH("/proxy-pools", s.mgmt.PatchProxyPools)
+		mgmt.DELETE("/proxy-pools", s.mgmt.DeleteProxyPools)
 
 		mgmt.GET("/antigravity-auth-url", s.mgmt.RequestAntigravityToken)
 		mgmt.POST("/oauth-callback", s.mgmt.PostOAuthCallback)
@@ -709,11 +768,80 @@ func (s *Server) Stop(ctx context.Context) error {
 	if err := s.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
+	if err := cache.CloseSignatureCache(); err != nil {
+		log.Warnf("failed to flush signature cache: %v", err)
+	}
 
 	log.Debug("API server stopped")
 	return nil
 }
 
+// applySignatureCacheConfig configures the thinking-signature cache. A disk store without a
+// path writes next to the config file, or under WRITABLE_PATH when set.
+func (s *Server) applySignatureCacheConfig(cfg *config.Config) {
+	sigCfg := cfg.SignatureCache
+	if strings.EqualFold(strings.TrimSpace(sigCfg.Store), cache.SignatureStoreDisk) && strings.TrimSpace(sigCfg.Path) == "" {
+		dir := util.WritablePath()
+		if dir == "" && s.configFilePath != "" {
+			dir = filepath.Dir(s.configFilePath)
+		}
+		sigCfg.Path = filepath.Join(dir, "signature-cache.json")
+	}
+	if err := cache.ConfigureSignatureCache(sigCfg); err != nil {
+		log.Errorf("failed to configure signature cache: %v", err)
+	}
+}
+
+// applyCassetteConfig switches upstream record/replay. A relative cassette dir is resolved against
+// the config file's directory.
+func (s *Server) applyCassetteConfig(cfg *config.Config) {
+	cassetteCfg := cfg.Cassette
+	if dir := strings.TrimSpace(cassetteCfg.Dir); dir != "" && !filepath.IsAbs(dir) && s.configFilePath != "" {
+		cassetteCfg.Dir = filepath.Join(filepath.Dir(s.configFilePath), dir)
+	}
+	if err := cassette.Configure(cassetteCfg); err != nil {
+		log.Errorf("failed to configure cassette: %v", err)
+	}
+}
+
+// applyUpstreamTLSConfig loads the upstream TLS files, resolving relative paths against the
+// directory of the config file. On error the previous settings stay in effect.
+func (s *Server) applyUpstreamTLSConfig(cfg *config.Config) {
+	resolve := func(settings config.UpstreamTLSSettings) config.UpstreamTLSSettings {
+		if s.configFilePath == "" {
+			return settings
+		}
+		abs := func(path string) string {
+			if path = strings.TrimSpace(path); path == "" || filepath.IsAbs(path) {
+				return path
+			}
+			return filepath.Join(filepath.Dir(s.configFilePath), path)
+		}
+		resolved := settings
+		resolved.CAFiles = make([]string, len(settings.CAFiles))
+		for i, path := range settings.CAFiles {
+			resolved.CAFiles[i] = abs(path)
+		}
+		resolved.ClientCert = abs(settings.ClientCert)
+		resolved.ClientKey = abs(settings.ClientKey)
+		return resolved
+	}
+	tlsCfg := config.UpstreamTLS{
+		UpstreamTLSSettings: resolve(cfg.UpstreamTLS.UpstreamTLSSettings),
+		Providers:           make(map[string]config.UpstreamTLSSettings, len(cfg.UpstreamTLS.Providers)),
+		Profiles:            make(map[string]config.UpstreamTLSSettings, len(cfg.UpstreamTLS.Profiles)),
+	}
+	for name, settings := range cfg.UpstreamTLS.Providers {
+		tlsCfg.Providers[name] = resolve(settings)
+	}
+	for name, settings := range cfg.UpstreamTLS.Profiles {
+		tlsCfg.Profiles[name] = resolve(settings)
+	}
+	if err := upstreamtls.Configure(tlsCfg); err != nil {
+		log.Errorf("failed to configure upstream TLS: %v", err)
+	}
+}
+
 // corsMiddleware returns a Gin middleware handler that adds CORS headers
 // to every response, allowing cross-origin requests.
 //
@@ -789,6 +917,22 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 	}
 
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.SignatureCache, cfg.SignatureCache) {
+		s.applySignatureCacheConfig(cfg)
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Cassette, cfg.Cassette) {
+		s.applyCassetteConfig(cfg)
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.ProxyPools, cfg.ProxyPools) {
+		proxypool.Configure(cfg.ProxyPools)
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.UpstreamTLS, cfg.UpstreamTLS) {
+		s.applyUpstreamTLSConfig(cfg)
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Notifications, cfg.Notifications) {
+		s.notifier.Configure(cfg.Notifications)
+	}
+
 	if s.handlers != nil && s.handlers.AuthManager != nil {
 		s.handlers.AuthManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
 	}
@@ -925,6 +1069,18 @@ func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 					if note := strings.TrimSpace(result.Metadata["note"]); note != "" {
 						c.Set(handlers.AccessKeyNoteContextKey, note)
 					}
+					if compaction := strings.TrimSpace(result.Metadata["context_compaction"]); compaction != "" {
+						c.Set(handlers.AccessContextCompactionContextKey, compaction)
+					}
+					if policy := strings.TrimSpace(result.Metadata["parameter_policy"]); policy != "" {
+						c.Set(handlers.AccessParameterPolicyContextKey, policy)
+					}
+					if sandbox := strings.TrimSpace(result.Metadata["sandbox"]); sandbox != "" {
+						c.Set(handlers.AccessSandboxContextKey, sandbox)
+					}
+					if policies := strings.TrimSpace(result.Metadata["policies"]); policies != "" {
+						c.Set(handlers.AccessPoliciesContextKey, policies)
+					}
 				}
 			}
 			c.Next()
@@ -3,12 +3,15 @@
 package claude
 
 import (
+	stdtls "crypto/tls"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
 
 	tls "github.com/refraction-networking/utls"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upstreamtls"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/http2"
@@ -55,21 +58,21 @@ func newUtlsRoundTripper(cfg *config.SDKConfig) *utlsRoundTripper {
 // getOrCreateConnection gets an existing connection or creates a new one.
 // It uses a per-host locking mechanism to prevent multiple goroutines from
 // creating connections to the same host simultaneously.
-func (t *utlsRoundTripper) getOrCreateConnection(host, addr string) (*http2.ClientConn, error) {
+func (t *utlsRoundTripper) getOrCreateConnection(key, host, addr string, profile *upstreamtls.Profile) (*http2.ClientConn, error) {
 	t.mu.Lock()
 
 	// Check if connection exists and is usable
-	if h2Conn, ok := t.connections[host]; ok && h2Conn.CanTakeNewRequest() {
+	if h2Conn, ok := t.connections[key]; ok && h2Conn.CanTakeNewRequest() {
 		t.mu.Unlock()
 		return h2Conn, nil
 	}
 
 	// Check if another goroutine is already creating a connection
-	if cond, ok := t.pending[host]; ok {
+	if cond, ok := t.pending[key]; ok {
 		// Wait for the other goroutine to finish
 		cond.Wait()
 		// Check if connection is now available
-		if h2Conn, ok := t.connections[host]; ok && h2Conn.CanTakeNewRequest() {
+		if h2Conn, ok := t.connections[key]; ok && h2Conn.CanTakeNewRequest() {
 			t.mu.Unlock()
 			return h2Conn, nil
 		}
@@ -78,17 +81,17 @@ func (t *utlsRoundTripper) getOrCreateConnection(host, addr string) (*http2.Clie
 
 	// Mark this host as pending
 	cond := sync.NewCond(&t.mu)
-	t.pending[host] = cond
+	t.pending[key] = cond
 	t.mu.Unlock()
 
 	// Create connection outside the lock
-	h2Conn, err := t.createConnection(host, addr)
+	h2Conn, err := t.createConnection(host, addr, profile)
 
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	// Remove pending marker and wake up waiting goroutines
-	delete(t.pending, host)
+	delete(t.pending, key)
 	cond.Broadcast()
 
 	if err != nil {
@@ -96,18 +99,19 @@ func (t *utlsRoundTripper) getOrCreateConnection(host, addr string) (*http2.Clie
 	}
 
 	// Store the new connection
-	t.connections[host] = h2Conn
+	t.connections[key] = h2Conn
 	return h2Conn, nil
 }
 
 // createConnection creates a new HTTP/2 connection with Firefox TLS fingerprint
-func (t *utlsRoundTripper) createConnection(host, addr string) (*http2.ClientConn, error) {
+func (t *utlsRoundTripper) createConnection(host, addr string, profile *upstreamtls.Profile) (*http2.ClientConn, error) {
 	conn, err := t.dialer.Dial("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
 
 	tlsConfig := &tls.Config{ServerName: host}
+	applyUpstreamTLS(tlsConfig, profile)
 	tlsConn := tls.UClient(conn, tlsConfig, tls.HelloFirefox_Auto)
 
 	if err := tlsConn.Handshake(); err != nil {
@@ -136,7 +140,11 @@ func (t *utlsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 	// Get hostname without port for TLS ServerName
 	hostname := req.URL.Hostname()
 
-	h2Conn, err := t.getOrCreateConnection(hostname, addr)
+	// Connections are kept per host and upstream TLS settings.
+	profile := upstreamtls.FromContext(req.Context())
+	key := fmt.Sprintf("%s|%p", hostname, profile)
+
+	h2Conn, err := t.getOrCreateConnection(key, hostname, addr, profile)
 	if err != nil {
 		return nil, err
 	}
@@ -145,8 +153,8 @@ func (t *utlsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 	if err != nil {
 		// Connection failed, remove it from cache
 		t.mu.Lock()
-		if cached, ok := t.connections[hostname]; ok && cached == h2Conn {
-			delete(t.connections, hostname)
+		if cached, ok := t.connections[key]; ok && cached == h2Conn {
+			delete(t.connections, key)
 		}
 		t.mu.Unlock()
 		return nil, err
@@ -155,6 +163,24 @@ func (t *utlsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 	return resp, nil
 }
 
+// applyUpstreamTLS copies the upstream TLS settings of profile onto a utls configuration.
+func applyUpstreamTLS(cfg *tls.Config, profile *upstreamtls.Profile) {
+	std := profile.TLSConfig()
+	if std == nil {
+		return
+	}
+	cfg.RootCAs = std.RootCAs
+	cfg.MinVersion = std.MinVersion
+	for _, cert := range std.Certificates {
+		cfg.Certificates = append(cfg.Certificates, tls.Certificate{Certificate: cert.Certificate, PrivateKey: cert.PrivateKey, Leaf: cert.Leaf})
+	}
+	if verify := std.VerifyConnection; verify != nil {
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verify(stdtls.ConnectionState{ServerName: cs.ServerName, PeerCertificates: cs.PeerCertificates, VerifiedChains: cs.VerifiedChains})
+		}
+	}
+}
+
 // NewAnthropicHttpClient creates an HTTP client that bypasses TLS fingerprinting
 // for Anthropic domains by using utls with Firefox fingerprint.
 // It accepts optional SDK configuration for proxy settings.
@@ -19,6 +19,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/browser"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upstreamtls"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
@@ -105,10 +106,14 @@ func (g *GeminiAuth) GetAuthenticatedClient(ctx context.Context, ts *GeminiToken
 		}
 
 		if transport != nil {
-			proxyClient := &http.Client{Transport: transport}
+			proxyClient := &http.Client{Transport: upstreamtls.Wrap(transport)}
 			ctx = context.WithValue(ctx, oauth2.HTTPClient, proxyClient)
 		}
 	}
+	// Token requests without a proxy still carry the upstream TLS settings.
+	if _, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); !ok {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: upstreamtls.Wrap(nil)})
+	}
 
 	// Configure the OAuth2 client.
 	conf := &oauth2.Config{
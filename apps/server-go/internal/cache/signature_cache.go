@@ -3,21 +3,27 @@ package cache
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	log "github.com/sirupsen/logrus"
 )
 
 // SignatureEntry holds a cached thinking signature with timestamp
 type SignatureEntry struct {
-	Signature string
-	Timestamp time.Time
+	Signature string    `json:"signature"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 const (
-	// SignatureCacheTTL is how long signatures are valid
+	// SignatureCacheTTL is the default for how long signatures are valid
 	SignatureCacheTTL = 3 * time.Hour
 
 	// SignatureTextHashLen is the length of the hash key (16 hex chars = 64-bit key space)
@@ -30,39 +36,137 @@ const (
 	CacheCleanupInterval = 10 * time.Minute
 )
 
-// signatureCache stores signatures by model group -> textHash -> SignatureEntry
-var signatureCache sync.Map
+var (
+	// signatureStore holds signatures by model group -> textHash -> SignatureEntry
+	signatureStoreMu sync.RWMutex
+	signatureStore   SignatureStore = newMemorySignatureStore(0, 0)
+	signatureOptions                = config.SignatureCacheConfig{Store: SignatureStoreMemory}
+
+	// signatureTTL is the sliding expiration in nanoseconds
+	signatureTTL atomic.Int64
+
+	signatureHits       atomic.Int64
+	signatureMisses     atomic.Int64
+	signatureStores     atomic.Int64
+	signatureEvictions  atomic.Int64
+	signatureMigrations atomic.Int64
+)
 
 // cacheCleanupOnce ensures the background cleanup goroutine starts only once
 var cacheCleanupOnce sync.Once
 
-// groupCache is the inner map type
-type groupCache struct {
-	mu      sync.RWMutex
-	entries map[string]SignatureEntry
+func init() {
+	signatureTTL.Store(int64(SignatureCacheTTL))
 }
 
-// hashText creates a stable, Unicode-safe key from text content
-func hashText(text string) string {
-	h := sha256.Sum256([]byte(text))
-	return hex.EncodeToString(h[:])[:SignatureTextHashLen]
+// SignatureCacheStats reports the state of the signature cache.
+type SignatureCacheStats struct {
+	Store              string         `json:"store"`
+	Path               string         `json:"path,omitempty"`
+	TTLSeconds         int64          `json:"ttl_seconds"`
+	MaxEntries         int            `json:"max_entries"`
+	MaxEntriesPerGroup int            `json:"max_entries_per_group"`
+	Entries            int            `json:"entries"`
+	Groups             map[string]int `json:"groups"`
+	Hits               int64          `json:"hits"`
+	Misses             int64          `json:"misses"`
+	Stores             int64          `json:"stores"`
+	Evictions          int64          `json:"evictions"`
+	Migrations         int64          `json:"migrations"`
+}
+
+// SignatureCacheEntry describes one cached signature for inspection.
+type SignatureCacheEntry struct {
+	Hash             string    `json:"hash"`
+	SignaturePreview string    `json:"signature_preview"`
+	SignatureLength  int       `json:"signature_length"`
+	LastUsedAt       time.Time `json:"last_used_at"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// ConfigureSignatureCache applies cfg. Changing the store or its path moves the current
+// entries into the new store; a disk store also loads the entries persisted by earlier runs.
+func ConfigureSignatureCache(cfg config.SignatureCacheConfig) error {
+	ttl := SignatureCacheTTL
+	if cfg.TTLMinutes > 0 {
+		ttl = time.Duration(cfg.TTLMinutes) * time.Minute
+	}
+	signatureTTL.Store(int64(ttl))
+	kind := strings.ToLower(strings.TrimSpace(cfg.Store))
+	if kind == "" {
+		kind = SignatureStoreMemory
+	}
+	cfg.Store = kind
+	cfg.Path = strings.TrimSpace(cfg.Path)
+
+	signatureStoreMu.Lock()
+	defer signatureStoreMu.Unlock()
+	current := signatureStore
+	if kind == signatureOptions.Store && cfg.Path == signatureOptions.Path {
+		if bounded, ok := current.(interface{ setBounds(int, int) int }); ok {
+			signatureEvictions.Add(int64(bounded.setBounds(cfg.MaxEntries, cfg.MaxEntriesPerGroup)))
+		}
+		signatureOptions = cfg
+		return nil
+	}
+
+	var next SignatureStore
+	switch kind {
+	case SignatureStoreMemory:
+		next = newMemorySignatureStore(cfg.MaxEntries, cfg.MaxEntriesPerGroup)
+	case SignatureStoreDisk:
+		disk, err := newDiskSignatureStore(cfg.Path, cfg.MaxEntries, cfg.MaxEntriesPerGroup, time.Now().Add(-ttl))
+		if err != nil {
+			return err
+		}
+		next = disk
+	default:
+		return fmt.Errorf("signature cache: unsupported store %q", cfg.Store)
+	}
+	for group := range current.GroupSizes() {
+		for hash, entry := range current.Entries(group) {
+			signatureEvictions.Add(int64(next.Put(group, hash, entry)))
+		}
+	}
+	if err := current.Close(); err != nil {
+		log.Warnf("signature cache: close previous store: %v", err)
+	}
+	signatureStore = next
+	signatureOptions = cfg
+	return nil
+}
+
+// CloseSignatureCache flushes a persistent store. The cache stays usable afterwards.
+func CloseSignatureCache() error {
+	signatureStoreMu.RLock()
+	defer signatureStoreMu.RUnlock()
+	if disk, ok := signatureStore.(*diskSignatureStore); ok {
+		return disk.flush(true)
+	}
+	return nil
 }
 
-// getOrCreateGroupCache gets or creates a cache bucket for a model group
-func getOrCreateGroupCache(groupKey string) *groupCache {
+func currentSignatureStore() SignatureStore {
 	// Start background cleanup on first access
 	cacheCleanupOnce.Do(startCacheCleanup)
 
-	if val, ok := signatureCache.Load(groupKey); ok {
-		return val.(*groupCache)
-	}
-	sc := &groupCache{entries: make(map[string]SignatureEntry)}
-	actual, _ := signatureCache.LoadOrStore(groupKey, sc)
-	return actual.(*groupCache)
+	signatureStoreMu.RLock()
+	defer signatureStoreMu.RUnlock()
+	return signatureStore
+}
+
+func currentSignatureTTL() time.Duration {
+	return time.Duration(signatureTTL.Load())
+}
+
+// hashText creates a stable, Unicode-safe key from text content
+func hashText(text string) string {
+	h := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(h[:])[:SignatureTextHashLen]
 }
 
 // startCacheCleanup launches a background goroutine that periodically
-// removes caches where all entries have expired.
+// removes expired entries.
 func startCacheCleanup() {
 	go func() {
 		ticker := time.NewTicker(CacheCleanupInterval)
@@ -73,26 +177,9 @@ func startCacheCleanup() {
 	}()
 }
 
-// purgeExpiredCaches removes caches with no valid (non-expired) entries.
+// purgeExpiredCaches removes entries that have not been used within the TTL.
 func purgeExpiredCaches() {
-	now := time.Now()
-	signatureCache.Range(func(key, value any) bool {
-		sc := value.(*groupCache)
-		sc.mu.Lock()
-		// Remove expired entries
-		for k, entry := range sc.entries {
-			if now.Sub(entry.Timestamp) > SignatureCacheTTL {
-				delete(sc.entries, k)
-			}
-		}
-		isEmpty := len(sc.entries) == 0
-		sc.mu.Unlock()
-		// Remove cache bucket if empty
-		if isEmpty {
-			signatureCache.Delete(key)
-		}
-		return true
-	})
+	currentSignatureStore().Purge(time.Now().Add(-currentSignatureTTL()))
 }
 
 // CacheSignature stores a thinking signature for a given model group and text.
@@ -107,14 +194,42 @@ func CacheSignature(modelName, text, signature string) {
 
 	groupKey := GetModelGroup(modelName)
 	textHash := hashText(text)
-	sc := getOrCreateGroupCache(groupKey)
-	sc.mu.Lock()
-	defer sc.mu.Unlock()
-
-	sc.entries[textHash] = SignatureEntry{
+	entry := SignatureEntry{
 		Signature: signature,
 		Timestamp: time.Now(),
 	}
+	signatureEvictions.Add(int64(currentSignatureStore().Put(groupKey, textHash, entry)))
+	signatureStores.Add(1)
+	cluster.Save(cluster.NamespaceSignature, groupKey+":"+textHash, entry, currentSignatureTTL())
+}
+
+// loadSharedSignature fetches a signature cached by another node and keeps a local copy.
+func loadSharedSignature(store SignatureStore, groupKey, textHash string) (SignatureEntry, bool) {
+	var entry SignatureEntry
+	if !cluster.Load(cluster.NamespaceSignature, groupKey+":"+textHash, &entry) || entry.Signature == "" {
+		return SignatureEntry{}, false
+	}
+	entry.Timestamp = time.Now()
+	signatureEvictions.Add(int64(store.Put(groupKey, textHash, entry)))
+	return entry, true
+}
+
+// migrateSignature moves an entry cached under the raw model name into groupKey. Models
+// served through provider API keys (e.g. kimi-k2.5 via claude-api-key) are grouped by the
+// registry, so entries cached before the model was registered sit under the model name.
+func migrateSignature(store SignatureStore, modelName, groupKey, textHash string, now time.Time) (SignatureEntry, bool) {
+	if modelName == "" || modelName == groupKey {
+		return SignatureEntry{}, false
+	}
+	entry, ok := store.Get(modelName, textHash)
+	if !ok || now.Sub(entry.Timestamp) > currentSignatureTTL() {
+		return SignatureEntry{}, false
+	}
+	store.Delete(modelName, textHash)
+	entry.Timestamp = now
+	signatureEvictions.Add(int64(store.Put(groupKey, textHash, entry)))
+	signatureMigrations.Add(1)
+	return entry, true
 }
 
 // GetCachedSignature retrieves a cached signature for a given model group and text.
@@ -128,31 +243,25 @@ func GetCachedSignature(modelName, text string) string {
 		}
 		return ""
 	}
-	val, ok := signatureCache.Load(groupKey)
-	if !ok {
-		if groupKey == "gemini" {
-			return "skip_thought_signature_validator"
-		}
-		return ""
-	}
-	sc := val.(*groupCache)
-
 	textHash := hashText(text)
-
+	store := currentSignatureStore()
 	now := time.Now()
 
-	sc.mu.Lock()
-	entry, exists := sc.entries[textHash]
+	entry, exists := store.Get(groupKey, textHash)
+	if exists && now.Sub(entry.Timestamp) > currentSignatureTTL() {
+		store.Delete(groupKey, textHash)
+		exists = false
+	}
 	if !exists {
-		sc.mu.Unlock()
-		if groupKey == "gemini" {
-			return "skip_thought_signature_validator"
+		if migrated, ok := migrateSignature(store, modelName, groupKey, textHash, now); ok {
+			signatureHits.Add(1)
+			return migrated.Signature
 		}
-		return ""
-	}
-	if now.Sub(entry.Timestamp) > SignatureCacheTTL {
-		delete(sc.entries, textHash)
-		sc.mu.Unlock()
+		if shared, ok := loadSharedSignature(store, groupKey, textHash); ok {
+			signatureHits.Add(1)
+			return shared.Signature
+		}
+		signatureMisses.Add(1)
 		if groupKey == "gemini" {
 			return "skip_thought_signature_validator"
 		}
@@ -161,8 +270,8 @@ func GetCachedSignature(modelName, text string) string {
 
 	// Refresh TTL on access (sliding expiration).
 	entry.Timestamp = now
-	sc.entries[textHash] = entry
-	sc.mu.Unlock()
+	store.Put(groupKey, textHash, entry)
+	signatureHits.Add(1)
 
 	return entry.Signature
 }
@@ -170,14 +279,84 @@ func GetCachedSignature(modelName, text string) string {
 // ClearSignatureCache clears signature cache for a specific model group or all groups.
 func ClearSignatureCache(modelName string) {
 	if modelName == "" {
-		signatureCache.Range(func(key, _ any) bool {
-			signatureCache.Delete(key)
-			return true
-		})
+		currentSignatureStore().Clear("")
 		return
 	}
-	groupKey := GetModelGroup(modelName)
-	signatureCache.Delete(groupKey)
+	currentSignatureStore().Clear(GetModelGroup(modelName))
+}
+
+// ClearSignatureGroup removes every entry of group, or of every group when group is empty, and
+// returns how many entries were removed.
+func ClearSignatureGroup(group string) int {
+	store := currentSignatureStore()
+	removed := 0
+	if group == "" {
+		for _, size := range store.GroupSizes() {
+			removed += size
+		}
+	} else {
+		removed = store.GroupSizes()[group]
+	}
+	store.Clear(group)
+	return removed
+}
+
+// GetSignatureCacheStats returns the cache configuration, sizes and counters.
+func GetSignatureCacheStats() SignatureCacheStats {
+	store := currentSignatureStore()
+	signatureStoreMu.RLock()
+	options := signatureOptions
+	signatureStoreMu.RUnlock()
+	if options.Store == "" {
+		options.Store = SignatureStoreMemory
+	}
+	stats := SignatureCacheStats{
+		Store:              options.Store,
+		Path:               options.Path,
+		TTLSeconds:         int64(currentSignatureTTL() / time.Second),
+		MaxEntries:         options.MaxEntries,
+		MaxEntriesPerGroup: options.MaxEntriesPerGroup,
+		Groups:             store.GroupSizes(),
+		Hits:               signatureHits.Load(),
+		Misses:             signatureMisses.Load(),
+		Stores:             signatureStores.Load(),
+		Evictions:          signatureEvictions.Load(),
+		Migrations:         signatureMigrations.Load(),
+	}
+	if options.Store != SignatureStoreDisk {
+		stats.Path = ""
+	}
+	for _, size := range stats.Groups {
+		stats.Entries += size
+	}
+	return stats
+}
+
+// ListSignatureCacheEntries returns the entries of group, most recently used first.
+func ListSignatureCacheEntries(group string) []SignatureCacheEntry {
+	ttl := currentSignatureTTL()
+	entries := currentSignatureStore().Entries(group)
+	out := make([]SignatureCacheEntry, 0, len(entries))
+	for hash, entry := range entries {
+		preview := entry.Signature
+		if len(preview) > 16 {
+			preview = preview[:16] + "..."
+		}
+		out = append(out, SignatureCacheEntry{
+			Hash:             hash,
+			SignaturePreview: preview,
+			SignatureLength:  len(entry.Signature),
+			LastUsedAt:       entry.Timestamp,
+			ExpiresAt:        entry.Timestamp.Add(ttl),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].LastUsedAt.Equal(out[j].LastUsedAt) {
+			return out[i].LastUsedAt.After(out[j].LastUsedAt)
+		}
+		return out[i].Hash < out[j].Hash
+	})
+	return out
 }
 
 // HasValidSignature checks if a signature is valid (non-empty and long enough)
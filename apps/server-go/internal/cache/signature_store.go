@@ -0,0 +1,343 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Signature store names.
+const (
+	SignatureStoreMemory = "memory"
+	SignatureStoreDisk   = "disk"
+)
+
+// signatureDiskFlushInterval controls how often a dirty disk store is written out.
+const signatureDiskFlushInterval = 30 * time.Second
+
+// SignatureStore holds cached signatures by model group and text hash. Implementations are
+// safe for concurrent use; TTL handling stays in the package-level functions.
+type SignatureStore interface {
+	// Get returns the entry for group/hash.
+	Get(group, hash string) (SignatureEntry, bool)
+	// Put stores entry and returns how many entries were evicted to stay within the bounds.
+	Put(group, hash string, entry SignatureEntry) int
+	// Delete removes group/hash.
+	Delete(group, hash string)
+	// Clear removes every entry of group, or of every group when group is empty.
+	Clear(group string)
+	// Entries returns a copy of the entries of group.
+	Entries(group string) map[string]SignatureEntry
+	// GroupSizes returns the number of entries per group.
+	GroupSizes() map[string]int
+	// Purge removes entries last used before cutoff and returns how many were removed.
+	Purge(cutoff time.Time) int
+	// Close flushes and releases the store.
+	Close() error
+}
+
+// memorySignatureStore keeps signatures in process memory with optional size bounds. When a
+// bound is reached the least recently used entry of the group, or of the whole cache, is
+// evicted.
+type memorySignatureStore struct {
+	mu          sync.Mutex
+	groups      map[string]map[string]SignatureEntry
+	total       int
+	maxTotal    int
+	maxPerGroup int
+}
+
+func newMemorySignatureStore(maxTotal, maxPerGroup int) *memorySignatureStore {
+	return &memorySignatureStore{
+		groups:      make(map[string]map[string]SignatureEntry),
+		maxTotal:    maxTotal,
+		maxPerGroup: maxPerGroup,
+	}
+}
+
+func (s *memorySignatureStore) setBounds(maxTotal, maxPerGroup int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxTotal = maxTotal
+	s.maxPerGroup = maxPerGroup
+	evicted := 0
+	for group, entries := range s.groups {
+		for s.maxPerGroup > 0 && len(entries) > s.maxPerGroup {
+			s.evictOldestLocked(group)
+			evicted++
+		}
+	}
+	for s.maxTotal > 0 && s.total > s.maxTotal {
+		s.evictOldestLocked("")
+		evicted++
+	}
+	return evicted
+}
+
+func (s *memorySignatureStore) Get(group, hash string) (SignatureEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.groups[group][hash]
+	return entry, ok
+}
+
+func (s *memorySignatureStore) Put(group, hash string, entry SignatureEntry) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, ok := s.groups[group]
+	if !ok {
+		entries = make(map[string]SignatureEntry)
+		s.groups[group] = entries
+	}
+	evicted := 0
+	if _, exists := entries[hash]; !exists {
+		if s.maxPerGroup > 0 && len(entries) >= s.maxPerGroup {
+			s.evictOldestLocked(group)
+			evicted++
+		}
+		if s.maxTotal > 0 && s.total >= s.maxTotal {
+			s.evictOldestLocked("")
+			evicted++
+		}
+		s.total++
+	}
+	entries[hash] = entry
+	return evicted
+}
+
+// evictOldestLocked removes the least recently used entry of group, or of the whole cache when
+// group is empty.
+func (s *memorySignatureStore) evictOldestLocked(group string) {
+	oldestGroup, oldestHash := "", ""
+	var oldest time.Time
+	for name, entries := range s.groups {
+		if group != "" && name != group {
+			continue
+		}
+		for hash, entry := range entries {
+			if oldestHash == "" || entry.Timestamp.Before(oldest) {
+				oldestGroup, oldestHash, oldest = name, hash, entry.Timestamp
+			}
+		}
+	}
+	if oldestHash != "" {
+		s.deleteLocked(oldestGroup, oldestHash)
+	}
+}
+
+func (s *memorySignatureStore) deleteLocked(group, hash string) {
+	entries, ok := s.groups[group]
+	if !ok {
+		return
+	}
+	if _, exists := entries[hash]; !exists {
+		return
+	}
+	delete(entries, hash)
+	s.total--
+	if len(entries) == 0 {
+		delete(s.groups, group)
+	}
+}
+
+func (s *memorySignatureStore) Delete(group, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteLocked(group, hash)
+}
+
+func (s *memorySignatureStore) Clear(group string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if group == "" {
+		s.groups = make(map[string]map[string]SignatureEntry)
+		s.total = 0
+		return
+	}
+	s.total -= len(s.groups[group])
+	delete(s.groups, group)
+}
+
+func (s *memorySignatureStore) Entries(group string) map[string]SignatureEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]SignatureEntry, len(s.groups[group]))
+	for hash, entry := range s.groups[group] {
+		out[hash] = entry
+	}
+	return out
+}
+
+func (s *memorySignatureStore) GroupSizes() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int, len(s.groups))
+	for group, entries := range s.groups {
+		out[group] = len(entries)
+	}
+	return out
+}
+
+func (s *memorySignatureStore) Purge(cutoff time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for group, entries := range s.groups {
+		for hash, entry := range entries {
+			if entry.Timestamp.Before(cutoff) {
+				s.deleteLocked(group, hash)
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+func (s *memorySignatureStore) Close() error {
+	return nil
+}
+
+// signatureDiskFile is the on-disk layout of a disk store.
+type signatureDiskFile struct {
+	Version int                                  `json:"version"`
+	Groups  map[string]map[string]SignatureEntry `json:"groups"`
+}
+
+// diskSignatureStore keeps the working set in memory and writes it to a JSON file in the
+// background and on Close, so signatures survive restarts.
+type diskSignatureStore struct {
+	*memorySignatureStore
+	path string
+
+	flushMu sync.Mutex
+	dirty   bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// newDiskSignatureStore loads path, dropping entries last used before cutoff, and starts the
+// background flush loop. A missing file starts an empty cache.
+func newDiskSignatureStore(path string, maxTotal, maxPerGroup int, cutoff time.Time) (*diskSignatureStore, error) {
+	if path == "" {
+		return nil, errors.New("signature cache: disk store requires a path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("signature cache: create directory: %w", err)
+	}
+	s := &diskSignatureStore{
+		memorySignatureStore: newMemorySignatureStore(maxTotal, maxPerGroup),
+		path:                 path,
+		stop:                 make(chan struct{}),
+		done:                 make(chan struct{}),
+	}
+	raw, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("signature cache: read %s: %w", path, err)
+	default:
+		var file signatureDiskFile
+		if err = json.Unmarshal(raw, &file); err != nil {
+			log.Warnf("signature cache: ignoring unreadable %s: %v", path, err)
+		}
+		for group, entries := range file.Groups {
+			for hash, entry := range entries {
+				if entry.Signature != "" && !entry.Timestamp.Before(cutoff) {
+					s.memorySignatureStore.Put(group, hash, entry)
+				}
+			}
+		}
+	}
+	go s.flushLoop()
+	return s, nil
+}
+
+func (s *diskSignatureStore) markDirty() {
+	s.flushMu.Lock()
+	s.dirty = true
+	s.flushMu.Unlock()
+}
+
+func (s *diskSignatureStore) Put(group, hash string, entry SignatureEntry) int {
+	evicted := s.memorySignatureStore.Put(group, hash, entry)
+	s.markDirty()
+	return evicted
+}
+
+func (s *diskSignatureStore) Delete(group, hash string) {
+	s.memorySignatureStore.Delete(group, hash)
+	s.markDirty()
+}
+
+func (s *diskSignatureStore) Clear(group string) {
+	s.memorySignatureStore.Clear(group)
+	s.markDirty()
+}
+
+func (s *diskSignatureStore) Purge(cutoff time.Time) int {
+	removed := s.memorySignatureStore.Purge(cutoff)
+	if removed > 0 {
+		s.markDirty()
+	}
+	return removed
+}
+
+func (s *diskSignatureStore) flushLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(signatureDiskFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.flush(false); err != nil {
+				log.Warnf("%v", err)
+			}
+		}
+	}
+}
+
+// flush writes the cache to disk through a temporary file when it changed since the last
+// write, or unconditionally when force is set.
+func (s *diskSignatureStore) flush(force bool) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	if !s.dirty && !force {
+		return nil
+	}
+	file := signatureDiskFile{Version: 1, Groups: make(map[string]map[string]SignatureEntry)}
+	for group := range s.GroupSizes() {
+		file.Groups[group] = s.memorySignatureStore.Entries(group)
+	}
+	raw, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("signature cache: encode: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("signature cache: write %s: %w", tmp, err)
+	}
+	if err = os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("signature cache: replace %s: %w", s.path, err)
+	}
+	s.dirty = false
+	return nil
+}
+
+func (s *diskSignatureStore) Close() error {
+	select {
+	case <-s.stop:
+		return nil
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	return s.flush(true)
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const testSignature = "persistedSig1234567890123456789012345678901234567890123"
+
+func resetSignatureCacheConfig(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		if err := ConfigureSignatureCache(config.SignatureCacheConfig{}); err != nil {
+			t.Fatalf("reset signature cache: %v", err)
+		}
+		ClearSignatureCache("")
+	})
+}
+
+func TestSignatureCache_DiskStoreSurvivesRestart(t *testing.T) {
+	resetSignatureCacheConfig(t)
+	ClearSignatureCache("")
+	path := filepath.Join(t.TempDir(), "signature-cache.json")
+
+	if err := ConfigureSignatureCache(config.SignatureCacheConfig{Store: SignatureStoreDisk, Path: path}); err != nil {
+		t.Fatalf("ConfigureSignatureCache(disk) error = %v", err)
+	}
+	CacheSignature(testModelName, "long agent session", testSignature)
+	if err := CloseSignatureCache(); err != nil {
+		t.Fatalf("CloseSignatureCache() error = %v", err)
+	}
+
+	// Simulate a restart: drop the in-memory state, then reopen the file.
+	if err := ConfigureSignatureCache(config.SignatureCacheConfig{}); err != nil {
+		t.Fatalf("ConfigureSignatureCache(memory) error = %v", err)
+	}
+	ClearSignatureCache("")
+	if err := ConfigureSignatureCache(config.SignatureCacheConfig{Store: SignatureStoreDisk, Path: path}); err != nil {
+		t.Fatalf("ConfigureSignatureCache(disk) error = %v", err)
+	}
+	if got := GetCachedSignature(testModelName, "long agent session"); got != testSignature {
+		t.Fatalf("GetCachedSignature() after restart = %q, want persisted signature", got)
+	}
+}
+
+func TestSignatureCache_MaxEntriesPerGroupEvictsLeastRecentlyUsed(t *testing.T) {
+	resetSignatureCacheConfig(t)
+	ClearSignatureCache("")
+	if err := ConfigureSignatureCache(config.SignatureCacheConfig{MaxEntriesPerGroup: 2}); err != nil {
+		t.Fatalf("ConfigureSignatureCache() error = %v", err)
+	}
+	before := GetSignatureCacheStats().Evictions
+
+	CacheSignature(testModelName, "first", testSignature)
+	CacheSignature(testModelName, "second", testSignature)
+	_ = GetCachedSignature(testModelName, "first")
+	CacheSignature(testModelName, "third", testSignature)
+
+	if got := GetCachedSignature(testModelName, "second"); got != "" {
+		t.Fatalf("least recently used entry still cached: %q", got)
+	}
+	if got := GetCachedSignature(testModelName, "first"); got != testSignature {
+		t.Fatalf("recently used entry evicted: %q", got)
+	}
+	stats := GetSignatureCacheStats()
+	if stats.Groups["claude"] != 2 || stats.Evictions != before+1 {
+		t.Fatalf("stats = %+v, want 2 claude entries and one eviction", stats)
+	}
+}
+
+func TestSignatureCache_MigratesEntriesCachedUnderModelName(t *testing.T) {
+	resetSignatureCacheConfig(t)
+	ClearSignatureCache("")
+	text := "thinking cached before the model was registered"
+
+	// Before registration the model falls back to its own name as group.
+	currentSignatureStore().Put("kimi-k2.5", hashText(text), SignatureEntry{Signature: testSignature, Timestamp: time.Now()})
+	entry, ok := migrateSignature(currentSignatureStore(), "kimi-k2.5", "claude", hashText(text), time.Now())
+	if !ok || entry.Signature != testSignature {
+		t.Fatalf("migrateSignature() = %+v, %v", entry, ok)
+	}
+	if _, stillThere := currentSignatureStore().Get("kimi-k2.5", hashText(text)); stillThere {
+		t.Fatal("entry left behind in the model-name group")
+	}
+	if got, _ := currentSignatureStore().Get("claude", hashText(text)); got.Signature != testSignature {
+		t.Fatalf("migrated entry = %+v, want it under claude", got)
+	}
+}
+
+func TestSignatureCache_StatsCountHitsAndMisses(t *testing.T) {
+	resetSignatureCacheConfig(t)
+	ClearSignatureCache("")
+	before := GetSignatureCacheStats()
+
+	CacheSignature(testModelName, "counted", testSignature)
+	_ = GetCachedSignature(testModelName, "counted")
+	_ = GetCachedSignature(testModelName, "absent")
+
+	after := GetSignatureCacheStats()
+	if after.Hits-before.Hits != 1 || after.Misses-before.Misses != 1 || after.Stores-before.Stores != 1 {
+		t.Fatalf("stats delta = hits %d misses %d stores %d, want 1/1/1", after.Hits-before.Hits, after.Misses-before.Misses, after.Stores-before.Stores)
+	}
+	if removed := ClearSignatureGroup("claude"); removed != 1 {
+		t.Fatalf("ClearSignatureGroup() = %d, want 1", removed)
+	}
+}
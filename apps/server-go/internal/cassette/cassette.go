@@ -0,0 +1,235 @@
+// Package cassette records upstream HTTP exchanges made by provider executors to files and
+// replays them without network access. Recorded exchanges have secrets scrubbed and keep the
+// timing of streamed response chunks; replayed requests are matched on a normalized form of
+// method, URL and JSON body.
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxMismatches bounds the unmatched replay requests kept for reporting.
+const maxMismatches = 100
+
+// Interaction is one recorded request/response exchange, stored as a JSON file.
+type Interaction struct {
+	Key        string           `json:"key"`
+	RecordedAt time.Time        `json:"recorded_at"`
+	Request    RecordedRequest  `json:"request"`
+	Response   RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the scrubbed upstream request.
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the scrubbed upstream response. The body is kept as the chunks it was
+// read in, each with its delay after the previous one.
+type RecordedResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Chunks  []Chunk     `json:"chunks,omitempty"`
+}
+
+// Chunk is one read of a response body.
+type Chunk struct {
+	DelayMs int64  `json:"delay_ms"`
+	Data    string `json:"data"`
+	// Encoding is "base64" when Data is not valid UTF-8.
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Mismatch describes a replayed request with no recorded exchange.
+type Mismatch struct {
+	At     time.Time `json:"at"`
+	Method string    `json:"method"`
+	URL    string    `json:"url"`
+	Key    string    `json:"key"`
+	// Candidates counts recorded exchanges for the same method and URL whose body differs.
+	Candidates int `json:"candidates"`
+}
+
+// Status reports the cassette mode and traffic counters.
+type Status struct {
+	Mode         string     `json:"mode"`
+	Dir          string     `json:"dir,omitempty"`
+	Interactions int        `json:"interactions"`
+	Recorded     int64      `json:"recorded"`
+	Replayed     int64      `json:"replayed"`
+	Mismatches   []Mismatch `json:"mismatches"`
+}
+
+// deck is the active cassette. It is replaced as a whole on reconfiguration.
+type deck struct {
+	mode     string
+	dir      string
+	realtime bool
+	ignore   []string
+
+	seq      atomic.Int64
+	recorded atomic.Int64
+	replayed atomic.Int64
+
+	mu         sync.Mutex
+	index      map[string][]*Interaction
+	cursor     map[string]int
+	endpoints  map[string]int
+	mismatches []Mismatch
+}
+
+var (
+	activeMu sync.RWMutex
+	active   *deck
+)
+
+// Configure switches the cassette mode. Replay mode loads every exchange under cfg.Dir.
+func Configure(cfg config.CassetteConfig) error {
+	mode := strings.ToLower(strings.TrimSpace(cfg.Mode))
+	if mode == "" {
+		activeMu.Lock()
+		active = nil
+		activeMu.Unlock()
+		return nil
+	}
+	dir := strings.TrimSpace(cfg.Dir)
+	if dir == "" {
+		return fmt.Errorf("cassette: dir is required in %s mode", mode)
+	}
+	d := &deck{
+		mode:      mode,
+		dir:       dir,
+		realtime:  cfg.Realtime,
+		ignore:    append([]string(nil), cfg.IgnoreBodyFields...),
+		index:     make(map[string][]*Interaction),
+		cursor:    make(map[string]int),
+		endpoints: make(map[string]int),
+	}
+	switch mode {
+	case config.CassetteModeRecord:
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("cassette: create %s: %w", dir, err)
+		}
+	case config.CassetteModeReplay:
+		if err := d.load(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cassette: unsupported mode %q", cfg.Mode)
+	}
+	activeMu.Lock()
+	active = d
+	activeMu.Unlock()
+	log.Infof("cassette: %s mode, dir %s", mode, dir)
+	return nil
+}
+
+func current() *deck {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active
+}
+
+// CurrentStatus returns the cassette mode and counters.
+func CurrentStatus() Status {
+	d := current()
+	if d == nil {
+		return Status{Mode: "off", Mismatches: []Mismatch{}}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	interactions := 0
+	for _, list := range d.index {
+		interactions += len(list)
+	}
+	return Status{
+		Mode:         d.mode,
+		Dir:          d.dir,
+		Interactions: interactions,
+		Recorded:     d.recorded.Load(),
+		Replayed:     d.replayed.Load(),
+		Mismatches:   append([]Mismatch{}, d.mismatches...),
+	}
+}
+
+// load indexes every exchange file under dir by request key, in recording order.
+func (d *deck) load() error {
+	paths, err := filepath.Glob(filepath.Join(d.dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("cassette: list %s: %w", d.dir, err)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		raw, errRead := os.ReadFile(path)
+		if errRead != nil {
+			return fmt.Errorf("cassette: read %s: %w", path, errRead)
+		}
+		var interaction Interaction
+		if errDecode := json.Unmarshal(raw, &interaction); errDecode != nil {
+			log.Warnf("cassette: skipping %s: %v", path, errDecode)
+			continue
+		}
+		d.add(&interaction)
+	}
+	return nil
+}
+
+func (d *deck) add(interaction *Interaction) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.index[interaction.Key] = append(d.index[interaction.Key], interaction)
+	d.endpoints[endpointKey(interaction.Request.Method, interaction.Request.URL)]++
+}
+
+// save writes interaction to its own file. File names sort in recording order.
+func (d *deck) save(interaction *Interaction) {
+	raw, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		log.Warnf("cassette: encode exchange: %v", err)
+		return
+	}
+	name := fmt.Sprintf("%s-%06d-%s.json", interaction.RecordedAt.UTC().Format("20060102T150405"), d.seq.Add(1), interaction.Key)
+	if err = os.WriteFile(filepath.Join(d.dir, name), raw, 0o600); err != nil {
+		log.Warnf("cassette: write %s: %v", name, err)
+		return
+	}
+	d.recorded.Add(1)
+	d.add(interaction)
+}
+
+// next returns the exchange to replay for key: recorded exchanges are served in order, and the
+// last one keeps being served once they are used up.
+func (d *deck) next(key, method, rawURL string) (*Interaction, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := d.index[key]
+	if len(list) == 0 {
+		mismatch := Mismatch{At: time.Now(), Method: method, URL: rawURL, Key: key, Candidates: d.endpoints[endpointKey(method, rawURL)]}
+		if len(d.mismatches) >= maxMismatches {
+			d.mismatches = d.mismatches[1:]
+		}
+		d.mismatches = append(d.mismatches, mismatch)
+		return nil, false
+	}
+	idx := d.cursor[key]
+	if idx >= len(list) {
+		idx = len(list) - 1
+	}
+	d.cursor[key] = idx + 1
+	return list[idx], true
+}
@@ -0,0 +1,114 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	t.Cleanup(func() { _ = Configure(config.CassetteConfig{}) })
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Set-Cookie", "session=secret")
+		_, _ = io.WriteString(w, "data: {\"text\":\"hel\"}\n\n")
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, "data: {\"text\":\"lo\",\"access_token\":\"leaked\"}\n\n")
+	}))
+	defer upstream.Close()
+	dir := t.TempDir()
+	cfg := config.CassetteConfig{Dir: dir, IgnoreBodyFields: []string{"requestId"}}
+
+	send := func(body, token string) (string, error) {
+		req, _ := http.NewRequest(http.MethodPost, upstream.URL+"/v1/stream?key="+token, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		client := &http.Client{Transport: Wrap(nil)}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		out, err := io.ReadAll(resp.Body)
+		return string(out), err
+	}
+
+	cfg.Mode = config.CassetteModeRecord
+	if err := Configure(cfg); err != nil {
+		t.Fatalf("Configure(record) error = %v", err)
+	}
+	live, err := send(`{"prompt":"hi","requestId":"1"}`, "live-token")
+	if err != nil {
+		t.Fatalf("record request error = %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("recorded files = %v, want one", files)
+	}
+	raw, _ := os.ReadFile(files[0])
+	for _, secret := range []string{"live-token", "session=secret", "leaked"} {
+		if strings.Contains(string(raw), secret) {
+			t.Fatalf("cassette file leaks %q:\n%s", secret, raw)
+		}
+	}
+
+	cfg.Mode = config.CassetteModeReplay
+	if err = Configure(cfg); err != nil {
+		t.Fatalf("Configure(replay) error = %v", err)
+	}
+	upstream.Close()
+	replayed, err := send(`{"requestId":"2","prompt":"hi"}`, "other-token")
+	if err != nil {
+		t.Fatalf("replay request error = %v", err)
+	}
+	if want := strings.Replace(live, "leaked", redacted, 1); replayed != want {
+		t.Fatalf("replayed body = %q, want %q", replayed, want)
+	}
+
+	if _, err = send(`{"prompt":"bye"}`, "other-token"); err == nil {
+		t.Fatal("unrecorded request replayed without error")
+	}
+	status := CurrentStatus()
+	if status.Replayed != 1 || len(status.Mismatches) != 1 || status.Mismatches[0].Candidates != 1 {
+		t.Fatalf("CurrentStatus() = %+v, want one replay and one mismatch against one candidate", status)
+	}
+}
+
+func TestScrubReadsCatchesSecretsSplitAcrossReads(t *testing.T) {
+	reads := []recordedRead{
+		{data: []byte(`{"text":"caf`)},
+		{data: []byte{0xc3}},
+		{data: []byte{0xa9, '"', ','}},
+		{data: []byte(`"access_tok`)},
+		{data: []byte(`en":"lea`)},
+		{data: []byte(`ked","note":"Bear`)},
+		{data: []byte(`er abc`)},
+		{data: []byte(`123"}`)},
+	}
+	chunks := scrubReads(reads)
+
+	var joined strings.Builder
+	for _, chunk := range chunks {
+		if chunk.Encoding != "" {
+			t.Fatalf("chunk %+v stored as %s, want text", chunk, chunk.Encoding)
+		}
+		for _, fragment := range []string{"lea", "ked", "abc", "123"} {
+			if strings.Contains(chunk.Data, fragment) {
+				t.Fatalf("chunk %q leaks %q", chunk.Data, fragment)
+			}
+		}
+		joined.WriteString(chunk.Data)
+	}
+	want := `{"text":"café","access_token":"` + redacted + `","note":"Bearer ` + redacted + `"}`
+	if joined.String() != want {
+		t.Fatalf("recorded body = %q, want %q", joined.String(), want)
+	}
+	if len(chunks) < 3 {
+		t.Fatalf("chunks = %d, want reads that split nothing kept apart", len(chunks))
+	}
+}
@@ -0,0 +1,327 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/sjson"
+)
+
+const redacted = "REDACTED"
+
+// secretHeaders are replaced in recorded exchanges.
+var secretHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"X-Api-Key",
+	"X-Goog-Api-Key",
+	"Api-Key",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// secretQueryParams are replaced in recorded URLs and ignored when matching.
+var secretQueryParams = []string{"key", "api_key", "access_token", "token"}
+
+var (
+	secretBodyFields = regexp.MustCompile(`("(?:access_token|refresh_token|id_token|client_secret|api_key|apiKey|password|secret)"\s*:\s*)"[^"]*"`)
+	bearerTokens     = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+)
+
+// Transport records or replays the exchanges of its inner RoundTripper.
+type Transport struct {
+	inner http.RoundTripper
+	deck  *deck
+}
+
+// Wrap returns rt wrapped for the active cassette mode, or rt unchanged when cassettes are off.
+// A nil rt records through http.DefaultTransport.
+func Wrap(rt http.RoundTripper) http.RoundTripper {
+	d := current()
+	if d == nil {
+		return rt
+	}
+	if wrapped, ok := rt.(*Transport); ok {
+		rt = wrapped.inner
+	}
+	return &Transport{inner: rt, deck: d}
+}
+
+// Unwrap returns the RoundTripper wrapped by Wrap, or rt itself.
+func Unwrap(rt http.RoundTripper) http.RoundTripper {
+	if wrapped, ok := rt.(*Transport); ok {
+		return wrapped.inner
+	}
+	return rt
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	key := requestKey(req.Method, req.URL, body, t.deck.ignore)
+	if t.deck.mode == config.CassetteModeReplay {
+		return t.replay(req, key)
+	}
+	return t.record(req, key, body)
+}
+
+func (t *Transport) record(req *http.Request, key string, body []byte) (*http.Response, error) {
+	inner := t.inner
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	start := time.Now()
+	resp, err := inner.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	interaction := &Interaction{
+		Key:        key,
+		RecordedAt: start,
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     scrubURL(req.URL).String(),
+			Headers: scrubHeaders(req.Header),
+			Body:    scrubBody(string(body)),
+		},
+		Response: RecordedResponse{Status: resp.StatusCode, Headers: scrubHeaders(resp.Header)},
+	}
+	resp.Body = &recordingBody{inner: resp.Body, last: time.Now(), interaction: interaction, deck: t.deck}
+	return resp, nil
+}
+
+func (t *Transport) replay(req *http.Request, key string) (*http.Response, error) {
+	rawURL := scrubURL(req.URL).String()
+	interaction, ok := t.deck.next(key, req.Method, rawURL)
+	if !ok {
+		log.Warnf("cassette: no recorded exchange for %s %s (key %s)", req.Method, rawURL, key)
+		return nil, fmt.Errorf("cassette: no recorded exchange for %s %s (key %s)", req.Method, rawURL, key)
+	}
+	t.deck.replayed.Add(1)
+	recorded := interaction.Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Headers.Clone(),
+		Body:          &replayBody{ctx: req.Context(), chunks: recorded.Chunks, realtime: t.deck.realtime},
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// recordingBody captures each read of a response body with its delay and saves the exchange
+// once the body is drained or closed.
+type recordingBody struct {
+	inner       io.ReadCloser
+	last        time.Time
+	reads       []recordedRead
+	interaction *Interaction
+	deck        *deck
+	once        sync.Once
+}
+
+// recordedRead is one unscrubbed read of a response body.
+type recordedRead struct {
+	data  []byte
+	delay time.Duration
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.inner.Read(p)
+	if n > 0 {
+		now := time.Now()
+		b.reads = append(b.reads, recordedRead{data: bytes.Clone(p[:n]), delay: now.Sub(b.last)})
+		b.last = now
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.finish()
+	return b.inner.Close()
+}
+
+func (b *recordingBody) finish() {
+	b.once.Do(func() {
+		b.interaction.Response.Chunks = scrubReads(b.reads)
+		b.deck.save(b.interaction)
+	})
+}
+
+// scrubReads turns the reads of a body into recorded chunks. Secrets are located in the assembled
+// body, and reads that split a secret or a UTF-8 sequence are merged first, so a token spread over
+// several reads is still scrubbed. Bodies that are not UTF-8 text are stored as base64.
+func scrubReads(reads []recordedRead) []Chunk {
+	var body []byte
+	for _, read := range reads {
+		body = append(body, read.data...)
+	}
+	textual := utf8.Valid(body)
+	var secrets [][]int
+	if textual {
+		secrets = append(secretBodyFields.FindAllIndex(body, -1), bearerTokens.FindAllIndex(body, -1)...)
+	}
+	splits := func(pos int) bool {
+		if pos >= len(body) || !textual {
+			return false
+		}
+		if !utf8.RuneStart(body[pos]) {
+			return true
+		}
+		for _, span := range secrets {
+			if span[0] < pos && pos < span[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	chunks := make([]Chunk, 0, len(reads))
+	start, end := 0, 0
+	var delay time.Duration
+	for _, read := range reads {
+		end += len(read.data)
+		delay += read.delay
+		if splits(end) {
+			continue
+		}
+		data := body[start:end]
+		if textual {
+			chunks = append(chunks, Chunk{DelayMs: delay.Milliseconds(), Data: scrubBody(string(data))})
+		} else {
+			chunks = append(chunks, Chunk{DelayMs: delay.Milliseconds(), Data: base64.StdEncoding.EncodeToString(data), Encoding: "base64"})
+		}
+		start, delay = end, 0
+	}
+	return chunks
+}
+
+// replayBody serves recorded chunks, optionally with their recorded delays.
+type replayBody struct {
+	ctx      context.Context
+	chunks   []Chunk
+	realtime bool
+	pending  []byte
+	waited   bool
+}
+
+func (b *replayBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if len(b.chunks) == 0 {
+			return 0, io.EOF
+		}
+		chunk := b.chunks[0]
+		if b.realtime && !b.waited && chunk.DelayMs > 0 {
+			b.waited = true
+			timer := time.NewTimer(time.Duration(chunk.DelayMs) * time.Millisecond)
+			select {
+			case <-b.ctx.Done():
+				timer.Stop()
+				return 0, b.ctx.Err()
+			case <-timer.C:
+			}
+		}
+		b.chunks = b.chunks[1:]
+		b.waited = false
+		b.pending = []byte(chunk.Data)
+		if chunk.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(chunk.Data)
+			if err != nil {
+				return 0, fmt.Errorf("cassette: decode chunk: %w", err)
+			}
+			b.pending = decoded
+		}
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+func (b *replayBody) Close() error { return nil }
+
+// requestKey identifies a request for replay: method, URL without secrets and with sorted query,
+// and the JSON body with secrets scrubbed, ignored fields removed and keys sorted.
+func requestKey(method string, u *url.URL, body []byte, ignore []string) string {
+	normalized := []byte(scrubBody(string(body)))
+	if json.Valid(normalized) {
+		for _, path := range ignore {
+			if updated, err := sjson.DeleteBytes(normalized, path); err == nil {
+				normalized = updated
+			}
+		}
+		var decoded any
+		if err := json.Unmarshal(normalized, &decoded); err == nil {
+			if canonical, errMarshal := json.Marshal(decoded); errMarshal == nil {
+				normalized = canonical
+			}
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.ToUpper(method) + " " + scrubURL(u).String() + "\n" + string(normalized)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func endpointKey(method, rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		rawURL = u.Scheme + "://" + u.Host + u.Path
+	}
+	return strings.ToUpper(method) + " " + rawURL
+}
+
+func scrubURL(u *url.URL) *url.URL {
+	clone := *u
+	clone.User = nil
+	clone.Fragment = ""
+	query := clone.Query()
+	for _, param := range secretQueryParams {
+		if query.Has(param) {
+			query.Set(param, redacted)
+		}
+	}
+	clone.RawQuery = query.Encode()
+	return &clone
+}
+
+func scrubHeaders(headers http.Header) http.Header {
+	clone := headers.Clone()
+	for _, name := range secretHeaders {
+		if clone.Get(name) != "" {
+			clone.Set(name, redacted)
+		}
+	}
+	return clone
+}
+
+func scrubBody(body string) string {
+	body = secretBodyFields.ReplaceAllString(body, `$1"`+redacted+`"`)
+	return bearerTokens.ReplaceAllString(body, "${1}"+redacted)
+}
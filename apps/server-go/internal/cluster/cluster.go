@@ -0,0 +1,264 @@
+// Package cluster shares runtime state between proxy instances that serve the same credentials.
+//
+// Components keep their process-local caches and use this package to write state through to a
+// shared backend and to read it back on a local miss. With the default in-process backend the
+// helpers are no-ops, so a single instance behaves exactly as before.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Namespaces used by the shared components.
+const (
+	NamespaceCursor         = "cursor"
+	NamespaceCooldown       = "cooldown"
+	NamespaceSignature      = "signature"
+	NamespaceAuggieState    = "auggie-state"
+	NamespaceAuggieToolCall = "auggie-tool-call"
+	NamespaceAuggieCallID   = "auggie-call-id"
+	NamespaceResponse       = "response"
+	NamespaceConversation   = "conversation"
+	NamespaceHealth         = "health"
+)
+
+// opTimeout bounds every helper call so a slow backend degrades to local-only behaviour
+// instead of stalling requests.
+const opTimeout = 2 * time.Second
+
+// reserveTimeout bounds counter reservations, which sit on the request path of every pick that
+// exhausts its local block.
+const reserveTimeout = 250 * time.Millisecond
+
+// Backend stores shared state as opaque values grouped by namespace.
+type Backend interface {
+	// Get returns the value stored under namespace/key, or false when it is missing or expired.
+	Get(ctx context.Context, namespace, key string) ([]byte, bool, error)
+	// Set stores value under namespace/key. A zero ttl keeps the value until it is deleted.
+	Set(ctx context.Context, namespace, key string, value []byte, ttl time.Duration) error
+	// Delete removes namespace/key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, namespace, key string) error
+	// List returns every unexpired value in namespace keyed by key.
+	List(ctx context.Context, namespace string) (map[string][]byte, error)
+	// Incr atomically adds delta to the counter namespace/key and returns the new value. A
+	// positive ttl expires the counter ttl after its last increment; an expired counter restarts
+	// from zero.
+	Incr(ctx context.Context, namespace, key string, delta int64, ttl time.Duration) (int64, error)
+	// TryLock acquires or renews the lease name for owner. It returns false when another owner
+	// holds an unexpired lease.
+	TryLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+	// Unlock releases the lease name when owner holds it.
+	Unlock(ctx context.Context, name, owner string) error
+	// Close releases the backend's resources.
+	Close() error
+}
+
+var (
+	mu      sync.RWMutex
+	backend Backend = NewMemoryBackend()
+	shared  bool
+	nodeID  = defaultNodeID()
+)
+
+func defaultNodeID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "node"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Install makes b the shared backend for this process. An empty id keeps the default
+// hostname-pid node ID. The previous backend is closed.
+func Install(b Backend, id string) {
+	if b == nil {
+		return
+	}
+	mu.Lock()
+	previous := backend
+	backend = b
+	shared = true
+	if id != "" {
+		nodeID = id
+	}
+	mu.Unlock()
+	if previous != nil && previous != b {
+		_ = previous.Close()
+	}
+}
+
+// Reset restores the in-process backend and closes the shared one.
+func Reset() {
+	mu.Lock()
+	previous := backend
+	backend = NewMemoryBackend()
+	shared = false
+	nodeID = defaultNodeID()
+	mu.Unlock()
+	if previous != nil {
+		_ = previous.Close()
+	}
+}
+
+// Shared reports whether a shared backend is installed.
+func Shared() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return shared
+}
+
+// NodeID returns the identity this process uses as lease owner.
+func NodeID() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return nodeID
+}
+
+// Current returns the active backend.
+func Current() Backend {
+	mu.RLock()
+	defer mu.RUnlock()
+	return backend
+}
+
+func sharedBackend() (Backend, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return backend, shared
+}
+
+// Load reads namespace/key from the shared backend into out. It returns false when no shared
+// backend is installed, the key is missing, or the value cannot be decoded.
+func Load(namespace, key string, out any) bool {
+	found, _ := Lookup(namespace, key, out)
+	return found
+}
+
+// Lookup is Load for stores that treat the shared copy as authoritative. reachable is false
+// when no shared backend is installed or the backend failed, in which case callers fall back to
+// their local copy; otherwise found reports whether the key exists.
+func Lookup(namespace, key string, out any) (found, reachable bool) {
+	b, ok := sharedBackend()
+	if !ok || key == "" {
+		return false, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	raw, found, err := b.Get(ctx, namespace, key)
+	if err != nil {
+		log.Debugf("cluster: get %s/%s: %v", namespace, key, err)
+		return false, false
+	}
+	if !found {
+		return false, true
+	}
+	if err = json.Unmarshal(raw, out); err != nil {
+		log.Debugf("cluster: decode %s/%s: %v", namespace, key, err)
+		return false, false
+	}
+	return true, true
+}
+
+// Save writes value to namespace/key on the shared backend. It is a no-op without one.
+func Save(namespace, key string, value any, ttl time.Duration) {
+	b, ok := sharedBackend()
+	if !ok || key == "" {
+		return
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		log.Debugf("cluster: encode %s/%s: %v", namespace, key, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	if err = b.Set(ctx, namespace, key, raw, ttl); err != nil {
+		log.Warnf("cluster: set %s/%s: %v", namespace, key, err)
+	}
+}
+
+// Remove deletes namespace/key from the shared backend. It is a no-op without one.
+func Remove(namespace, key string) {
+	b, ok := sharedBackend()
+	if !ok || key == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	if err := b.Delete(ctx, namespace, key); err != nil {
+		log.Warnf("cluster: delete %s/%s: %v", namespace, key, err)
+	}
+}
+
+// Entries returns every value in namespace on the shared backend, or nil without one.
+func Entries(namespace string) map[string][]byte {
+	b, ok := sharedBackend()
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	entries, err := b.List(ctx, namespace)
+	if err != nil {
+		log.Debugf("cluster: list %s: %v", namespace, err)
+		return nil
+	}
+	return entries
+}
+
+// Reserve claims the next size values of the shared counter namespace/key and returns the
+// first one; the block is [first, first+size). The counter expires ttl after its last
+// reservation. The boolean is false when no shared backend is installed or the backend failed
+// or timed out, in which case callers use their local state.
+func Reserve(namespace, key string, size int64, ttl time.Duration) (int64, bool) {
+	b, ok := sharedBackend()
+	if !ok || key == "" || size <= 0 {
+		return 0, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reserveTimeout)
+	defer cancel()
+	value, err := b.Incr(ctx, namespace, key, size, ttl)
+	if err != nil {
+		log.Debugf("cluster: incr %s/%s: %v", namespace, key, err)
+		return 0, false
+	}
+	return value - size, true
+}
+
+// AcquireLease reports whether this node may perform the work guarded by name for ttl.
+// Without a shared backend every node is its own leader. Backend errors deny the lease so two
+// nodes never act at once while the backend is unreachable.
+func AcquireLease(name string, ttl time.Duration) bool {
+	b, ok := sharedBackend()
+	if !ok {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	acquired, err := b.TryLock(ctx, name, NodeID(), ttl)
+	if err != nil {
+		log.Warnf("cluster: acquire lease %s: %v", name, err)
+		return false
+	}
+	return acquired
+}
+
+// ReleaseLease gives up the lease name when this node holds it.
+func ReleaseLease(name string) {
+	b, ok := sharedBackend()
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	if err := b.Unlock(ctx, name, NodeID()); err != nil {
+		log.Debugf("cluster: release lease %s: %v", name, err)
+	}
+}
@@ -0,0 +1,93 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackendValuesExpire(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend()
+	if err := b.Set(ctx, "ns", "keep", []byte("1"), 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := b.Set(ctx, "ns", "short", []byte("2"), time.Millisecond); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, found, _ := b.Get(ctx, "ns", "short"); found {
+		t.Fatal("expired value still returned")
+	}
+	entries, err := b.List(ctx, "ns")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || string(entries["keep"]) != "1" {
+		t.Fatalf("List() = %v, want only keep", entries)
+	}
+
+	if _, err = b.Incr(ctx, "ns", "counter", 5, time.Millisecond); err != nil {
+		t.Fatalf("Incr() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if value, _ := b.Incr(ctx, "ns", "counter", 1, time.Millisecond); value != 1 {
+		t.Fatalf("Incr() after expiry = %d, want 1", value)
+	}
+}
+
+func TestMemoryBackendLeaseContention(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend()
+
+	if ok, _ := b.TryLock(ctx, "refresh/a", "node-1", time.Minute); !ok {
+		t.Fatal("first TryLock() = false, want true")
+	}
+	if ok, _ := b.TryLock(ctx, "refresh/a", "node-2", time.Minute); ok {
+		t.Fatal("TryLock() by second node = true while lease is held")
+	}
+	if ok, _ := b.TryLock(ctx, "refresh/a", "node-1", time.Minute); !ok {
+		t.Fatal("renewal by holder = false, want true")
+	}
+	_ = b.Unlock(ctx, "refresh/a", "node-2")
+	if ok, _ := b.TryLock(ctx, "refresh/a", "node-2", time.Minute); ok {
+		t.Fatal("Unlock() by non-holder released the lease")
+	}
+	_ = b.Unlock(ctx, "refresh/a", "node-1")
+	if ok, _ := b.TryLock(ctx, "refresh/a", "node-2", time.Minute); !ok {
+		t.Fatal("TryLock() after release = false, want true")
+	}
+}
+
+func TestHelpersAreNoOpsWithoutSharedBackend(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+
+	Save("ns", "k", "v", 0)
+	var out string
+	if Load("ns", "k", &out) {
+		t.Fatal("Load() succeeded without a shared backend")
+	}
+	if _, ok := Reserve("ns", "k", 1, 0); ok {
+		t.Fatal("Reserve() succeeded without a shared backend")
+	}
+	if !AcquireLease("refresh/a", time.Minute) {
+		t.Fatal("AcquireLease() = false without a shared backend, want every node to lead")
+	}
+
+	Install(NewMemoryBackend(), "node-1")
+	Save("ns", "k", "v", 0)
+	if !Load("ns", "k", &out) || out != "v" {
+		t.Fatalf("Load() = %q, want v", out)
+	}
+	if first, _ := Reserve("ns", "k", 4, 0); first != 0 {
+		t.Fatalf("Reserve() = %d, want 0", first)
+	}
+	if next, _ := Reserve("ns", "k", 4, 0); next != 4 {
+		t.Fatalf("second Reserve() = %d, want 4", next)
+	}
+	if NodeID() != "node-1" {
+		t.Fatalf("NodeID() = %q, want node-1", NodeID())
+	}
+}
@@ -0,0 +1,250 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	postgresStateTable   = "cluster_state"
+	postgresCounterTable = "cluster_counters"
+	postgresLeaseTable   = "cluster_leases"
+
+	// postgresCounterPurgeInterval spaces the removal of expired counters.
+	postgresCounterPurgeInterval = time.Minute
+)
+
+// PostgresBackend shares state through three PostgreSQL tables: values and counters with an
+// optional expiry, and leases.
+type PostgresBackend struct {
+	db     *sql.DB
+	schema string
+	ownsDB bool
+
+	lastCounterPurge atomic.Int64
+}
+
+// OpenPostgresBackend connects to dsn and prepares the cluster tables in schema.
+func OpenPostgresBackend(ctx context.Context, dsn, schema string) (*PostgresBackend, error) {
+	dsn = strings.TrimSpace(dsn)
+	if dsn == "" {
+		return nil, fmt.Errorf("cluster: postgres DSN is required")
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: open database connection: %w", err)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("cluster: ping database: %w", err)
+	}
+	backend, err := NewPostgresBackend(ctx, db, schema)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	backend.ownsDB = true
+	return backend, nil
+}
+
+// NewPostgresBackend prepares the cluster tables in schema on an existing connection pool,
+// which the caller keeps ownership of.
+func NewPostgresBackend(ctx context.Context, db *sql.DB, schema string) (*PostgresBackend, error) {
+	if db == nil {
+		return nil, fmt.Errorf("cluster: database is nil")
+	}
+	b := &PostgresBackend{db: db, schema: strings.TrimSpace(schema)}
+	if err := b.ensureSchema(ctx); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *PostgresBackend) ensureSchema(ctx context.Context) error {
+	if b.schema != "" {
+		if _, err := b.db.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quoteIdentifier(b.schema))); err != nil {
+			return fmt.Errorf("cluster: create schema: %w", err)
+		}
+	}
+	statements := []string{
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			namespace TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value BYTEA NOT NULL,
+			expires_at TIMESTAMPTZ,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (namespace, key)
+		)`, b.table(postgresStateTable)),
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			namespace TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (namespace, key)
+		)`, b.table(postgresCounterTable)),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ`, b.table(postgresCounterTable)),
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			name TEXT PRIMARY KEY,
+			owner TEXT NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL
+		)`, b.table(postgresLeaseTable)),
+	}
+	for _, statement := range statements {
+		if _, err := b.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("cluster: create table: %w", err)
+		}
+	}
+	return nil
+}
+
+// Get implements Backend.
+func (b *PostgresBackend) Get(ctx context.Context, namespace, key string) ([]byte, bool, error) {
+	query := fmt.Sprintf("SELECT value FROM %s WHERE namespace = $1 AND key = $2 AND (expires_at IS NULL OR expires_at > NOW())", b.table(postgresStateTable))
+	var value []byte
+	err := b.db.QueryRowContext(ctx, query, namespace, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("cluster: get: %w", err)
+	}
+	return value, true, nil
+}
+
+// Set implements Backend.
+func (b *PostgresBackend) Set(ctx context.Context, namespace, key string, value []byte, ttl time.Duration) error {
+	var expiresAt sql.NullTime
+	if ttl > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(ttl).UTC(), Valid: true}
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO %s (namespace, key, value, expires_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (namespace, key)
+		DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at, updated_at = NOW()
+	`, b.table(postgresStateTable))
+	if _, err := b.db.ExecContext(ctx, query, namespace, key, value, expiresAt); err != nil {
+		return fmt.Errorf("cluster: set: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Backend.
+func (b *PostgresBackend) Delete(ctx context.Context, namespace, key string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE namespace = $1 AND key = $2", b.table(postgresStateTable))
+	if _, err := b.db.ExecContext(ctx, query, namespace, key); err != nil {
+		return fmt.Errorf("cluster: delete: %w", err)
+	}
+	return nil
+}
+
+// List implements Backend. Expired rows of namespace are removed first.
+func (b *PostgresBackend) List(ctx context.Context, namespace string) (map[string][]byte, error) {
+	table := b.table(postgresStateTable)
+	if _, err := b.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE namespace = $1 AND expires_at IS NOT NULL AND expires_at <= NOW()", table), namespace); err != nil {
+		return nil, fmt.Errorf("cluster: purge expired: %w", err)
+	}
+	rows, err := b.db.QueryContext(ctx, fmt.Sprintf("SELECT key, value FROM %s WHERE namespace = $1", table), namespace)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: list: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	out := make(map[string][]byte)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err = rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("cluster: scan: %w", err)
+		}
+		out[key] = value
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("cluster: iterate: %w", err)
+	}
+	return out, nil
+}
+
+// Incr implements Backend. Expired counters restart from delta; rows that are no longer
+// incremented are purged at most once per postgresCounterPurgeInterval.
+func (b *PostgresBackend) Incr(ctx context.Context, namespace, key string, delta int64, ttl time.Duration) (int64, error) {
+	table := b.table(postgresCounterTable)
+	if last := b.lastCounterPurge.Load(); time.Since(time.Unix(0, last)) >= postgresCounterPurgeInterval &&
+		b.lastCounterPurge.CompareAndSwap(last, time.Now().UnixNano()) {
+		if _, err := b.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at IS NOT NULL AND expires_at <= NOW()", table)); err != nil {
+			return 0, fmt.Errorf("cluster: purge expired counters: %w", err)
+		}
+	}
+	var expiresAt sql.NullTime
+	if ttl > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(ttl).UTC(), Valid: true}
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO %s AS c (namespace, key, value, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (namespace, key) DO UPDATE SET
+			value = CASE WHEN c.expires_at IS NOT NULL AND c.expires_at <= NOW() THEN EXCLUDED.value ELSE c.value + EXCLUDED.value END,
+			expires_at = EXCLUDED.expires_at
+		RETURNING value
+	`, table)
+	var value int64
+	if err := b.db.QueryRowContext(ctx, query, namespace, key, delta, expiresAt).Scan(&value); err != nil {
+		return 0, fmt.Errorf("cluster: incr: %w", err)
+	}
+	return value, nil
+}
+
+// TryLock implements Backend. The upsert only takes over a lease that this owner already holds
+// or that has expired, so exactly one node wins a contended lease.
+func (b *PostgresBackend) TryLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	query := fmt.Sprintf(`
+		INSERT INTO %s AS l (name, owner, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET owner = EXCLUDED.owner, expires_at = EXCLUDED.expires_at
+		WHERE l.owner = EXCLUDED.owner OR l.expires_at <= NOW()
+		RETURNING owner
+	`, b.table(postgresLeaseTable))
+	var holder string
+	err := b.db.QueryRowContext(ctx, query, name, owner, time.Now().Add(ttl).UTC()).Scan(&holder)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cluster: acquire lease: %w", err)
+	}
+	return holder == owner, nil
+}
+
+// Unlock implements Backend.
+func (b *PostgresBackend) Unlock(ctx context.Context, name, owner string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE name = $1 AND owner = $2", b.table(postgresLeaseTable))
+	if _, err := b.db.ExecContext(ctx, query, name, owner); err != nil {
+		return fmt.Errorf("cluster: release lease: %w", err)
+	}
+	return nil
+}
+
+// Close implements Backend. A connection pool passed to NewPostgresBackend is left open.
+func (b *PostgresBackend) Close() error {
+	if b == nil || b.db == nil || !b.ownsDB {
+		return nil
+	}
+	return b.db.Close()
+}
+
+func (b *PostgresBackend) table(name string) string {
+	if b.schema == "" {
+		return quoteIdentifier(name)
+	}
+	return quoteIdentifier(b.schema) + "." + quoteIdentifier(name)
+}
+
+func quoteIdentifier(identifier string) string {
+	return "\"" + strings.ReplaceAll(identifier, "\"", "\"\"") + "\""
+}
@@ -0,0 +1,83 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSyncInterval = 5 * time.Second
+	defaultLeaseTTL     = 120 * time.Second
+)
+
+var (
+	syncInterval = defaultSyncInterval
+	leaseTTL     = defaultLeaseTTL
+)
+
+// Setup installs the backend described by cfg. db is an existing PostgreSQL pool, such as the
+// token store's, used when cfg.DSN is empty. A disabled config or the memory backend leaves
+// this process on its own.
+func Setup(ctx context.Context, cfg config.ClusterConfig, db *sql.DB) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	mu.Lock()
+	syncInterval = secondsOrDefault(cfg.SyncInterval, defaultSyncInterval)
+	leaseTTL = secondsOrDefault(cfg.LeaseTTL, defaultLeaseTTL)
+	mu.Unlock()
+
+	switch strings.ToLower(strings.TrimSpace(cfg.Backend)) {
+	case "", config.ClusterBackendMemory:
+		log.Info("cluster: memory backend selected; runtime state is not shared with other instances")
+		return nil
+	case config.ClusterBackendPostgres:
+		var (
+			b   *PostgresBackend
+			err error
+		)
+		if strings.TrimSpace(cfg.DSN) != "" {
+			b, err = OpenPostgresBackend(ctx, cfg.DSN, cfg.Schema)
+		} else if db != nil {
+			b, err = NewPostgresBackend(ctx, db, cfg.Schema)
+		} else {
+			return fmt.Errorf("cluster: postgres backend needs cluster.dsn or PGSTORE_DSN")
+		}
+		if err != nil {
+			return err
+		}
+		Install(b, strings.TrimSpace(cfg.NodeID))
+		log.Infof("cluster: sharing runtime state through postgres as node %s", NodeID())
+		return nil
+	default:
+		return fmt.Errorf("cluster: unsupported backend %q", cfg.Backend)
+	}
+}
+
+// SyncInterval returns how often shared state that is pushed rather than read through, such as
+// credential cooldowns, is pulled from the backend.
+func SyncInterval() time.Duration {
+	mu.RLock()
+	defer mu.RUnlock()
+	return syncInterval
+}
+
+// LeaseTTL returns how long a node keeps a lease it acquired.
+func LeaseTTL() time.Duration {
+	mu.RLock()
+	defer mu.RUnlock()
+	return leaseTTL
+}
+
+func secondsOrDefault(seconds int, fallback time.Duration) time.Duration {
+	if seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
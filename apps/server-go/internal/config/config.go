@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"syscall"
 
@@ -101,6 +102,10 @@ type Config struct {
 	// Used for services that use Vertex AI-style paths but with simple API key authentication.
 	VertexCompatAPIKey []VertexCompatKey `yaml:"vertex-api-key" json:"vertex-api-key"`
 
+	// MockProvider defines credentials of the built-in mock provider, which answers locally with
+	// scripted responses for development, integration tests and load tests.
+	MockProvider []MockProvider `yaml:"mock-provider,omitempty" json:"mock-provider,omitempty"`
+
 	// AmpCode contains Amp CLI upstream configuration, management restrictions, and model mappings.
 	AmpCode AmpCode `yaml:"ampcode" json:"ampcode"`
 
@@ -118,7 +123,37 @@ type Config struct {
 	// Payload defines default and override rules for provider payload parameters.
 	Payload PayloadConfig `yaml:"payload" json:"payload"`
 
+	// ConfigReload controls validation history and rollback for config.yaml hot reloads.
+	ConfigReload ConfigReloadConfig `yaml:"config-reload,omitempty" json:"config-reload,omitempty"`
+
+	// Cluster shares runtime state with other instances serving the same credentials.
+	Cluster ClusterConfig `yaml:"cluster,omitempty" json:"cluster,omitempty"`
+
+	// SignatureCache configures where thinking signatures are kept and for how long.
+	SignatureCache SignatureCacheConfig `yaml:"signature-cache,omitempty" json:"signature-cache,omitempty"`
+
+	// HealthProbe configures background health checks of credentials.
+	HealthProbe HealthProbeConfig `yaml:"health-probe,omitempty" json:"health-probe,omitempty"`
+
+	// QuotaForecast configures pre-emptive rotation away from credentials near their quota.
+	QuotaForecast QuotaForecastConfig `yaml:"quota-forecast,omitempty" json:"quota-forecast,omitempty"`
+
+	// Cassette records upstream traffic to files or replays it without network access.
+	Cassette CassetteConfig `yaml:"cassette,omitempty" json:"cassette,omitempty"`
+
+	// ProxyPools defines named groups of egress proxies shared by credentials.
+	ProxyPools []ProxyPool `yaml:"proxy-pools,omitempty" json:"proxy-pools,omitempty"`
+
+	// UpstreamTLS adds root CAs, client certificates and pinning to upstream TLS connections.
+	UpstreamTLS UpstreamTLS `yaml:"upstream-tls,omitempty" json:"upstream-tls,omitempty"`
+
+	// Notifications alerts webhooks and local commands about credential and quota incidents.
+	Notifications NotificationsConfig `yaml:"notifications,omitempty" json:"notifications,omitempty"`
+
 	legacyMigrationPending bool `yaml:"-" json:"-"`
+
+	// secretRefPaths records the ${...} references by YAML path.
+	secretRefPaths map[string]secretReference
 }
 
 // ClaudeHeaderDefaults configures default header values injected into Claude API requests
@@ -178,6 +213,385 @@ type RoutingConfig struct {
 	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
 }
 
+// Cluster backend names.
+const (
+	ClusterBackendMemory   = "memory"
+	ClusterBackendPostgres = "postgres"
+)
+
+// ClusterConfig configures multi-instance mode. Cluster settings are read at startup only.
+type ClusterConfig struct {
+	// Enabled shares round-robin cursors, cooldowns, thinking signatures, Auggie conversation
+	// state and stored responses/conversations through Backend, and elects one node to refresh
+	// each credential.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// Backend selects the shared store: "postgres", or "memory" (default) which only shares
+	// state within this process.
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// DSN is the PostgreSQL connection string. Empty reuses the PGSTORE_DSN connection when the
+	// Postgres token store is active.
+	DSN string `yaml:"dsn,omitempty" json:"-"`
+
+	// Schema holds the cluster tables. Empty uses the connection's default schema.
+	Schema string `yaml:"schema,omitempty" json:"schema,omitempty"`
+
+	// NodeID identifies this instance as a lease owner. Default is hostname-pid.
+	NodeID string `yaml:"node-id,omitempty" json:"node-id,omitempty"`
+
+	// SyncInterval is how often shared cooldowns are pulled from the backend, in seconds.
+	// Default is 5.
+	SyncInterval int `yaml:"sync-interval,omitempty" json:"sync-interval,omitempty"`
+
+	// LeaseTTL is how long a node keeps the refresh lease of a credential, in seconds.
+	// Default is 120.
+	LeaseTTL int `yaml:"lease-ttl,omitempty" json:"lease-ttl,omitempty"`
+}
+
+// SignatureCacheConfig configures the thinking-signature cache used to replay signed thinking
+// blocks in multi-turn conversations.
+type SignatureCacheConfig struct {
+	// Store is "memory" (default) or "disk". The disk store keeps the cache in memory and
+	// writes it to Path so signatures survive restarts.
+	Store string `yaml:"store,omitempty" json:"store,omitempty"`
+
+	// Path is the disk store's file. Default is signature-cache.json next to config.yaml, or
+	// under WRITABLE_PATH when set.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// TTLMinutes is how long an unused signature is kept. Every lookup extends it. Default is 180.
+	TTLMinutes int `yaml:"ttl-minutes,omitempty" json:"ttl-minutes,omitempty"`
+
+	// MaxEntries bounds the whole cache. The least recently used entry is evicted first.
+	// 0 means unbounded.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// MaxEntriesPerGroup bounds each model group (claude, gemini, gpt, ...). 0 means unbounded.
+	MaxEntriesPerGroup int `yaml:"max-entries-per-group,omitempty" json:"max-entries-per-group,omitempty"`
+}
+
+// HealthProbeConfig configures proactive credential probes. A probe is a cheap upstream call,
+// such as a model-list fetch, that marks a credential unhealthy before a user request fails on it.
+type HealthProbeConfig struct {
+	// Enabled starts probing credentials of providers whose executor supports it.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// Interval is the time between probe cycles, in seconds. Default is 300.
+	Interval int `yaml:"interval,omitempty" json:"interval,omitempty"`
+
+	// MaxPerCycle bounds how many credentials are probed per cycle; the least recently probed go
+	// first. Default is 10.
+	MaxPerCycle int `yaml:"max-per-cycle,omitempty" json:"max-per-cycle,omitempty"`
+
+	// Timeout bounds a single probe, in seconds. Default is 15.
+	Timeout int `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// Providers limits probing to these providers. Empty probes every supported provider.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+// QuotaForecastConfig configures pre-emptive credential rotation. Consumption rates are learned
+// from usage records, quota capacity from quota errors and upstream rate-limit headers.
+type QuotaForecastConfig struct {
+	// Enabled steers selection away from credentials forecast to be near their limit while other
+	// credentials remain. Forecasts are always reported through management.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// RotateBefore is the forecast time-to-exhaustion, in seconds, below which a credential is
+	// avoided. Default is 120.
+	RotateBefore int `yaml:"rotate-before,omitempty" json:"rotate-before,omitempty"`
+
+	// ReservePercent avoids a credential once less than this share of its known capacity is
+	// left. Default is 5.
+	ReservePercent int `yaml:"reserve-percent,omitempty" json:"reserve-percent,omitempty"`
+}
+
+// Cassette modes.
+const (
+	CassetteModeRecord = "record"
+	CassetteModeReplay = "replay"
+)
+
+// CassetteConfig configures recording and replaying of upstream HTTP exchanges made by provider
+// executors.
+type CassetteConfig struct {
+	// Mode is "record", "replay", or empty to pass traffic through untouched.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Dir holds one JSON file per recorded exchange. Required when Mode is set.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// Realtime replays streamed responses with the recorded delay between chunks instead of
+	// delivering them at once.
+	Realtime bool `yaml:"realtime,omitempty" json:"realtime,omitempty"`
+
+	// IgnoreBodyFields lists JSON body paths (gjson syntax) left out of request matching, for
+	// fields that change on every call such as request IDs.
+	IgnoreBodyFields []string `yaml:"ignore-body-fields,omitempty" json:"ignore-body-fields,omitempty"`
+}
+
+// ProxyPoolScheme prefixes a proxy URL that refers to a proxy pool by name, e.g. "pool://egress".
+const ProxyPoolScheme = "pool://"
+
+// ProxyPool is a named group of egress proxies. A credential uses the pool when its proxy-url is
+// "pool://<name>", or when it has no proxy-url and its provider is listed in Providers. Each
+// credential sticks to one healthy member, chosen by a consistent hash of its ID, and fails over
+// to another member while its own is unhealthy.
+type ProxyPool struct {
+	// Name identifies the pool and must be unique.
+	Name string `yaml:"name" json:"name"`
+
+	// Members are proxy URLs: socks5://, http:// or https://, optionally with user:pass@.
+	Members []string `yaml:"members" json:"members"`
+
+	// Providers lists providers whose credentials without their own proxy-url use this pool.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// HealthCheck configures the periodic checks of each member.
+	HealthCheck ProxyPoolHealthCheck `yaml:"health-check,omitempty" json:"health-check,omitempty"`
+}
+
+// ProxyPoolHealthCheck configures how pool members are checked.
+type ProxyPoolHealthCheck struct {
+	// Disable turns health checks off; every member is then always considered healthy.
+	Disable bool `yaml:"disable,omitempty" json:"disable,omitempty"`
+
+	// URL is fetched through each member. Default is https://www.gstatic.com/generate_204.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// Interval between checks in seconds. Default is 60.
+	Interval int `yaml:"interval,omitempty" json:"interval,omitempty"`
+
+	// Timeout of one check in seconds. Default is 10.
+	Timeout int `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed checks or requests after which a member
+	// is taken out of rotation until a check succeeds. Default is 3.
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure-threshold,omitempty"`
+}
+
+// UpstreamTLSProfileKey is the credential attribute or auth file field selecting a named
+// upstream-tls profile.
+const UpstreamTLSProfileKey = "tls_profile"
+
+// UpstreamTLS configures TLS for connections to upstream providers, token endpoints and the Codex
+// websocket. The top-level settings apply to every connection; Providers and Profiles override
+// them field by field, with a credential's profile taking precedence over its provider.
+type UpstreamTLS struct {
+	UpstreamTLSSettings `yaml:",inline"`
+
+	// Providers maps a provider key (e.g. "codex", "claude") to settings for its connections.
+	Providers map[string]UpstreamTLSSettings `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// Profiles are named settings selected by a credential's tls_profile attribute or auth file field.
+	Profiles map[string]UpstreamTLSSettings `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+}
+
+// UpstreamTLSSettings is one layer of upstream TLS settings. Unset fields inherit from the layer below.
+type UpstreamTLSSettings struct {
+	// CAFiles are PEM bundles trusted in addition to the system roots.
+	CAFiles []string `yaml:"ca-files,omitempty" json:"ca-files,omitempty"`
+
+	// ClientCert and ClientKey are PEM files presented for mutual TLS. Both must be set together.
+	ClientCert string `yaml:"client-cert,omitempty" json:"client-cert,omitempty"`
+	ClientKey  string `yaml:"client-key,omitempty" json:"client-key,omitempty"`
+
+	// PinSHA256 lists base64 SHA-256 digests of accepted subject public keys. When set, a
+	// connection is refused unless a certificate in the verified chain matches one of them.
+	PinSHA256 []string `yaml:"pin-sha256,omitempty" json:"pin-sha256,omitempty"`
+
+	// MinVersion is the lowest accepted TLS version: "1.2" or "1.3".
+	MinVersion string `yaml:"min-version,omitempty" json:"min-version,omitempty"`
+}
+
+// Notification event kinds accepted by notifications.events and the per-target events filters.
+const (
+	NotificationEventStatusChanged = "status_changed"
+	NotificationEventRecovered     = "recovered"
+	NotificationEventCooldownStorm = "cooldown_storm"
+	NotificationEventRefreshFailed = "refresh_failed"
+	NotificationEventBudget        = "budget_threshold"
+)
+
+// NotificationsConfig configures alerts about credential and quota incidents. Alerts with the same
+// kind, credential and model are sent once per dedup window, and at most RateLimit alerts are sent
+// per minute.
+type NotificationsConfig struct {
+	// Enabled turns notifications on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Events limits the event kinds raised. Empty means all.
+	Events []string `yaml:"events,omitempty" json:"events,omitempty"`
+
+	// Statuses are the credential statuses a status_changed alert is raised for. Empty means
+	// error and disabled. Errors from transient upstream failures (408, 429, 5xx) are not alerted.
+	Statuses []string `yaml:"statuses,omitempty" json:"statuses,omitempty"`
+
+	// BudgetPercent raises budget_threshold when a credential's forecast share of quota left
+	// falls below this percentage. 0 disables the check.
+	BudgetPercent int `yaml:"budget-percent,omitempty" json:"budget-percent,omitempty"`
+
+	// DedupWindow is the number of seconds an identical alert is suppressed. Default is 600.
+	DedupWindow int `yaml:"dedup-window,omitempty" json:"dedup-window,omitempty"`
+
+	// RateLimit is the maximum number of alerts sent per minute. Default is 30.
+	RateLimit int `yaml:"rate-limit,omitempty" json:"rate-limit,omitempty"`
+
+	// Webhooks receive each alert as a JSON POST.
+	Webhooks []NotificationWebhook `yaml:"webhooks,omitempty" json:"webhooks,omitempty"`
+
+	// Commands are run with each alert as JSON on standard input.
+	Commands []NotificationCommand `yaml:"commands,omitempty" json:"commands,omitempty"`
+}
+
+// NotificationWebhook is an HTTP endpoint receiving alerts.
+type NotificationWebhook struct {
+	// URL is the http or https endpoint.
+	URL string `yaml:"url" json:"url"`
+
+	// Secret signs the request: X-Cliproxy-Signature carries "sha256=" and the hex HMAC-SHA256
+	// of the X-Cliproxy-Timestamp value, a dot and the body.
+	Secret string `yaml:"secret,omitempty" json:"secret,omitempty"`
+
+	// Headers are added to every request.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// Events limits the event kinds sent to this webhook. Empty means all.
+	Events []string `yaml:"events,omitempty" json:"events,omitempty"`
+
+	// MaxRetries is the number of retries after a network error, 429 or 5xx response, with
+	// exponential backoff. Default is 3.
+	MaxRetries int `yaml:"max-retries,omitempty" json:"max-retries,omitempty"`
+
+	// Timeout is the per-attempt timeout in seconds. Default is 10.
+	Timeout int `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// NotificationCommand is a local program run for alerts.
+type NotificationCommand struct {
+	// Command is the program to run.
+	Command string `yaml:"command" json:"command"`
+
+	// Args are its command-line arguments.
+	Args []string `yaml:"args,omitempty" json:"args,omitempty"`
+
+	// Env adds environment variables. CLIPROXY_EVENT, CLIPROXY_AUTH_ID, CLIPROXY_PROVIDER and
+	// CLIPROXY_MODEL are always set.
+	Env map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+
+	// Events limits the event kinds the command runs for. Empty means all.
+	Events []string `yaml:"events,omitempty" json:"events,omitempty"`
+
+	// Timeout is the number of seconds the command may run. Default is 10.
+	Timeout int `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// Mock provider reply modes.
+const (
+	MockModeEcho  = "echo"
+	MockModeFixed = "fixed"
+)
+
+// MockProvider configures one credential of the built-in mock provider. Responses are produced
+// locally from the scripted models and never reach the network.
+type MockProvider struct {
+	// Name identifies the credential and must be unique among mock-provider entries.
+	Name string `yaml:"name" json:"name"`
+
+	// Priority controls selection preference when multiple credentials match.
+	// Higher values are preferred; defaults to 0.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Prefix optionally namespaces models for this credential (e.g., "mock/echo").
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	// Models lists the models served by this credential and how each one replies.
+	Models []MockModel `yaml:"models" json:"models"`
+}
+
+// MockModel scripts the replies of one mock model. Replies depend only on the request and on
+// how many requests the model has served for the credential, so runs are reproducible.
+type MockModel struct {
+	// Name is the client-facing model ID.
+	Name string `yaml:"name" json:"name"`
+
+	// Mode is "echo" (default) to reply with the last user message, or "fixed" to reply with Text.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Text is the reply in fixed mode.
+	Text string `yaml:"text,omitempty" json:"text,omitempty"`
+
+	// ToolCalls are returned after the text, in order.
+	ToolCalls []MockToolCall `yaml:"tool-calls,omitempty" json:"tool-calls,omitempty"`
+
+	// TTFTMs delays the first streamed chunk, and the whole non-streaming reply, in milliseconds.
+	TTFTMs int `yaml:"ttft-ms,omitempty" json:"ttft-ms,omitempty"`
+
+	// LatencyMs is the delay between streamed chunks in milliseconds. Non-streaming replies wait
+	// for the same total time as the streamed reply would take.
+	LatencyMs int `yaml:"latency-ms,omitempty" json:"latency-ms,omitempty"`
+
+	// ChunkSize is the number of characters per streamed text chunk. Default is 16.
+	ChunkSize int `yaml:"chunk-size,omitempty" json:"chunk-size,omitempty"`
+
+	// Usage overrides the reported token usage. Zero fields are estimated at four bytes per token.
+	Usage MockUsage `yaml:"usage,omitempty" json:"usage,omitempty"`
+
+	// Faults inject upstream errors instead of a reply. The first matching fault wins.
+	Faults []MockFault `yaml:"faults,omitempty" json:"faults,omitempty"`
+
+	// DisconnectAfter ends streamed replies with an error after this many chunks. 0 disables it.
+	DisconnectAfter int `yaml:"disconnect-after,omitempty" json:"disconnect-after,omitempty"`
+}
+
+func (m MockModel) GetName() string  { return m.Name }
+func (m MockModel) GetAlias() string { return "" }
+
+// MockToolCall is a tool call returned by a mock model.
+type MockToolCall struct {
+	// Name is the called function.
+	Name string `yaml:"name" json:"name"`
+
+	// Arguments is the JSON-encoded argument object. Empty means {}.
+	Arguments string `yaml:"arguments,omitempty" json:"arguments,omitempty"`
+}
+
+// MockUsage is the token usage reported by a mock model.
+type MockUsage struct {
+	InputTokens  int64 `yaml:"input-tokens,omitempty" json:"input-tokens,omitempty"`
+	OutputTokens int64 `yaml:"output-tokens,omitempty" json:"output-tokens,omitempty"`
+}
+
+// MockFault makes a mock model fail some of its requests with an HTTP error.
+type MockFault struct {
+	// Status is the HTTP status returned, such as 429 or 503.
+	Status int `yaml:"status" json:"status"`
+
+	// RetryAfter sets the Retry-After header in seconds. 0 omits it.
+	RetryAfter int `yaml:"retry-after,omitempty" json:"retry-after,omitempty"`
+
+	// Every fails every Nth request of the model, counting from 1. 0 or 1 fails every request.
+	Every int `yaml:"every,omitempty" json:"every,omitempty"`
+
+	// Times stops injecting the fault after it fired this many times. 0 means no limit.
+	Times int `yaml:"times,omitempty" json:"times,omitempty"`
+
+	// Message is the error message. Defaults to the status text.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+// ConfigReloadConfig configures how hot reloads of config.yaml are validated and rolled back.
+type ConfigReloadConfig struct {
+	// HistorySize is the number of last known-good configs retained for rollback.
+	// 0 uses the default of 5.
+	HistorySize int `yaml:"history-size,omitempty" json:"history-size,omitempty"`
+
+	// DisableRollback keeps an invalid config.yaml on disk instead of restoring the last known-good one.
+	// The invalid config is never applied either way.
+	DisableRollback bool `yaml:"disable-rollback,omitempty" json:"disable-rollback,omitempty"`
+}
+
 // OAuthModelAlias defines a model ID alias for a specific channel.
 // It maps the upstream model name (Name) to the client-visible alias (Alias).
 // When Fork is true, the alias is added as an additional model in listings while
@@ -553,13 +967,32 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	cfg.Pprof.Addr = DefaultPprofAddr
 	cfg.AmpCode.RestrictManagementToLocalhost = false // Default to false: API key auth is sufficient
 	cfg.RemoteManagement.PanelGitHubRepository = DefaultPanelGitHubRepository
-	if err = yaml.Unmarshal(data, &cfg); err != nil {
+	var root yaml.Node
+	if err = yaml.Unmarshal(data, &root); err != nil {
 		if optional {
 			// In cloud deploy mode, if YAML parsing fails, return empty config instead of error.
 			return &Config{}, nil
 		}
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	// Resolve ${ENV:...}, ${FILE:...} and other secret references before decoding. The raw
+	// templates are kept so persistence writes references back instead of resolved secrets.
+	secretRefs, errSecrets := interpolateSecretNodes(&root, filepath.Dir(configFile))
+	if errSecrets != nil {
+		if optional {
+			return &Config{}, nil
+		}
+		return nil, errSecrets
+	}
+	if err = root.Decode(&cfg); err != nil {
+		if optional {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if len(secretRefs) > 0 {
+		cfg.setSecretReferences(secretRefs)
+	}
 
 	// NOTE: Startup legacy key migration is intentionally disabled.
 	// Reason: avoid mutating config.yaml during server startup.
@@ -586,9 +1019,14 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		}
 		cfg.RemoteManagement.SecretKey = hashed
 
-		// Persist the hashed value back to the config file to avoid re-hashing on next startup.
-		// Preserve YAML comments and ordering; update only the nested key.
-		_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+		if _, fromReference := secretRefs["remote-management.secret-key"]; fromReference {
+			// Keep the reference in config.yaml; the hash only lives in memory.
+			cfg.addSecretAlias("remote-management.secret-key", hashed)
+		} else {
+			// Persist the hashed value back to the config file to avoid re-hashing on next startup.
+			// Preserve YAML comments and ordering; update only the nested key.
+			_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+		}
 	}
 
 	cfg.RemoteManagement.PanelGitHubRepository = strings.TrimSpace(cfg.RemoteManagement.PanelGitHubRepository)
@@ -980,6 +1418,8 @@ func SaveConfigPreserveComments(configFile string, cfg *Config) error {
 	if generated.Content[0].Kind != yaml.MappingNode {
 		return fmt.Errorf("expected generated root mapping node")
 	}
+	// Write ${...} references back instead of the secrets they resolved to.
+	persistCfg.restoreSecretReferences(generated.Content[0], "")
 
 	// Remove deprecated sections before merging back the sanitized config.
 	removeLegacyAuthBlock(original.Content[0])
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	configSchemaOnce sync.Once
+	configSchema     map[string]any
+)
+
+// JSONSchema returns a JSON Schema (draft 2020-12) document describing config.yaml.
+// The schema is derived from the Config struct's yaml tags so it never drifts from
+// the fields the loader actually understands. Callers must treat the result as read-only.
+func JSONSchema() map[string]any {
+	configSchemaOnce.Do(func() {
+		root := schemaForType(reflect.TypeFor[Config](), map[reflect.Type]bool{})
+		root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+		root["title"] = "CLIProxyAPI config.yaml"
+		configSchema = root
+	})
+	return configSchema
+}
+
+// yamlFieldName resolves the YAML key for a struct field.
+// It returns inline=true for embedded/inline fields and skip=true for ignored fields.
+func yamlFieldName(field reflect.StructField) (name string, inline bool, skip bool) {
+	if !field.IsExported() {
+		return "", false, true
+	}
+	tag := field.Tag.Get("yaml")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	for _, opt := range parts[1:] {
+		if opt == "inline" {
+			inline = true
+		}
+	}
+	if field.Anonymous && name == "" {
+		inline = true
+	}
+	if name == "" && !inline {
+		name = strings.ToLower(field.Name)
+	}
+	return name, inline, false
+}
+
+// yamlStructFields flattens inline fields and returns the YAML key -> field type mapping.
+func yamlStructFields(t reflect.Type) map[string]reflect.Type {
+	out := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, inline, skip := yamlFieldName(field)
+		if skip {
+			continue
+		}
+		if inline {
+			ft := field.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range yamlStructFields(ft) {
+					out[k] = v
+				}
+			}
+			continue
+		}
+		out[name] = field.Type
+	}
+	return out
+}
+
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaForType(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaForType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]any{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		fields := yamlStructFields(t)
+		props := make(map[string]any, len(fields))
+		for name, ft := range fields {
+			props[name] = schemaForType(ft, visiting)
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	default:
+		// interface{} and other dynamic values accept any JSON value.
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,690 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ValidationSeverityError marks an issue that makes the configuration unusable as written.
+	ValidationSeverityError = "error"
+	// ValidationSeverityWarning marks an issue that is tolerated but likely a mistake.
+	ValidationSeverityWarning = "warning"
+)
+
+// knownOAuthModelAliasChannels lists the channels accepted under oauth-model-alias.
+var knownOAuthModelAliasChannels = map[string]struct{}{
+	"gemini-cli":  {},
+	"vertex":      {},
+	"aistudio":    {},
+	"antigravity": {},
+	"auggie":      {},
+	"claude":      {},
+	"codex":       {},
+	"qwen":        {},
+	"iflow":       {},
+	"kimi":        {},
+}
+
+// ValidationIssue describes a single problem found while validating config.yaml.
+type ValidationIssue struct {
+	// Path is the dotted YAML path of the offending value (e.g. "oauth-model-alias.antigravity[0].alias").
+	Path string `json:"path"`
+	// Line is the 1-based source line when known, otherwise 0.
+	Line int `json:"line,omitempty"`
+	// Severity is either "error" or "warning".
+	Severity string `json:"severity"`
+	// Message is a human-readable description of the issue.
+	Message string `json:"message"`
+}
+
+func (i ValidationIssue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%s (line %d): %s", i.Path, i.Line, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// HasValidationErrors reports whether any issue has error severity.
+func HasValidationErrors(issues []ValidationIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == ValidationSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateConfigData validates raw config.yaml bytes without applying or persisting anything.
+// It reports YAML syntax errors, unknown keys (typos), unresolvable secret references and
+// semantic problems that the loader would otherwise silently drop during sanitization.
+// baseDir is the directory relative ${FILE:...} references are resolved against.
+func ValidateConfigData(data []byte, baseDir string) []ValidationIssue {
+	var issues []ValidationIssue
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []ValidationIssue{{Path: "$", Severity: ValidationSeverityError, Message: "config is empty"}}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return []ValidationIssue{{Path: "$", Severity: ValidationSeverityError, Message: err.Error()}}
+	}
+	if len(root.Content) > 0 {
+		issues = append(issues, unknownKeyIssues(root.Content[0], reflect.TypeFor[Config](), "")...)
+	}
+	if _, err := interpolateSecretNodes(&root, baseDir); err != nil {
+		return append(issues, ValidationIssue{Path: "$", Severity: ValidationSeverityError, Message: err.Error()})
+	}
+	var cfg Config
+	if err := root.Decode(&cfg); err != nil {
+		return append(issues, ValidationIssue{Path: "$", Severity: ValidationSeverityError, Message: err.Error()})
+	}
+
+	issues = append(issues, cfg.Validate()...)
+	return issues
+}
+
+// Validate performs semantic validation of an unmarshalled (not yet sanitized) configuration.
+func (cfg *Config) Validate() []ValidationIssue {
+	if cfg == nil {
+		return nil
+	}
+	var issues []ValidationIssue
+	addErr := func(path, format string, args ...any) {
+		issues = append(issues, ValidationIssue{Path: path, Severity: ValidationSeverityError, Message: fmt.Sprintf(format, args...)})
+	}
+	addWarn := func(path, format string, args ...any) {
+		issues = append(issues, ValidationIssue{Path: path, Severity: ValidationSeverityWarning, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		addErr("port", "must be between 0 and 65535, got %d", cfg.Port)
+	}
+	if cfg.TLS.Enable && (strings.TrimSpace(cfg.TLS.Cert) == "" || strings.TrimSpace(cfg.TLS.Key) == "") {
+		addErr("tls", "cert and key are required when tls.enable is true")
+	}
+	if cfg.RequestRetry < 0 {
+		addWarn("request-retry", "negative value is treated as 0")
+	}
+	if cfg.MaxRetryInterval < 0 {
+		addWarn("max-retry-interval", "negative value is treated as 0")
+	}
+	if cfg.ConfigReload.HistorySize < 0 {
+		addErr("config-reload.history-size", "must not be negative")
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Cluster.Backend)) {
+	case "", ClusterBackendMemory, ClusterBackendPostgres:
+	default:
+		addErr("cluster.backend", "unsupported backend %q (expected memory or postgres)", cfg.Cluster.Backend)
+	}
+	if cfg.Cluster.SyncInterval < 0 {
+		addErr("cluster.sync-interval", "must not be negative")
+	}
+	if cfg.Cluster.LeaseTTL < 0 {
+		addErr("cluster.lease-ttl", "must not be negative")
+	}
+	if cfg.HealthProbe.Interval < 0 {
+		addErr("health-probe.interval", "must not be negative")
+	}
+	if cfg.HealthProbe.MaxPerCycle < 0 {
+		addErr("health-probe.max-per-cycle", "must not be negative")
+	}
+	if cfg.HealthProbe.Timeout < 0 {
+		addErr("health-probe.timeout", "must not be negative")
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Cassette.Mode)) {
+	case "":
+	case CassetteModeRecord, CassetteModeReplay:
+		if strings.TrimSpace(cfg.Cassette.Dir) == "" {
+			addErr("cassette.dir", "is required when cassette.mode is set")
+		}
+	default:
+		addErr("cassette.mode", "unsupported mode %q (expected record or replay)", cfg.Cassette.Mode)
+	}
+	mockNames := make(map[string]bool, len(cfg.MockProvider))
+	for i, entry := range cfg.MockProvider {
+		path := fmt.Sprintf("mock-provider[%d]", i)
+		name := strings.ToLower(strings.TrimSpace(entry.Name))
+		if name == "" {
+			addErr(path+".name", "is required")
+		} else if mockNames[name] {
+			addErr(path+".name", "duplicate name %q", entry.Name)
+		}
+		mockNames[name] = true
+		for j, model := range entry.Models {
+			modelPath := fmt.Sprintf("%s.models[%d]", path, j)
+			if strings.TrimSpace(model.Name) == "" {
+				addErr(modelPath+".name", "is required")
+			}
+			switch strings.ToLower(strings.TrimSpace(model.Mode)) {
+			case "", MockModeEcho, MockModeFixed:
+			default:
+				addErr(modelPath+".mode", "unsupported mode %q (expected echo or fixed)", model.Mode)
+			}
+			if model.TTFTMs < 0 || model.LatencyMs < 0 || model.ChunkSize < 0 || model.DisconnectAfter < 0 {
+				addErr(modelPath, "ttft-ms, latency-ms, chunk-size and disconnect-after must not be negative")
+			}
+			for k, call := range model.ToolCalls {
+				if strings.TrimSpace(call.Name) == "" {
+					addErr(fmt.Sprintf("%s.tool-calls[%d].name", modelPath, k), "is required")
+				}
+				if args := strings.TrimSpace(call.Arguments); args != "" && !json.Valid([]byte(args)) {
+					addErr(fmt.Sprintf("%s.tool-calls[%d].arguments", modelPath, k), "must be valid JSON")
+				}
+			}
+			for k, fault := range model.Faults {
+				faultPath := fmt.Sprintf("%s.faults[%d]", modelPath, k)
+				if fault.Status < 400 || fault.Status > 599 {
+					addErr(faultPath+".status", "must be an HTTP error status between 400 and 599, got %d", fault.Status)
+				}
+				if fault.RetryAfter < 0 || fault.Every < 0 || fault.Times < 0 {
+					addErr(faultPath, "retry-after, every and times must not be negative")
+				}
+			}
+		}
+	}
+	poolNames := make(map[string]bool, len(cfg.ProxyPools))
+	for i, pool := range cfg.ProxyPools {
+		path := fmt.Sprintf("proxy-pools[%d]", i)
+		name := strings.ToLower(strings.TrimSpace(pool.Name))
+		switch {
+		case name == "":
+			addErr(path+".name", "is required")
+		case strings.ContainsAny(name, "/ "):
+			addErr(path+".name", "must not contain '/' or spaces")
+		case poolNames[name]:
+			addErr(path+".name", "duplicate name %q", pool.Name)
+		}
+		poolNames[name] = true
+		if len(pool.Members) == 0 {
+			addErr(path+".members", "at least one member is required")
+		}
+		for j, member := range pool.Members {
+			parsed, errParse := url.Parse(strings.TrimSpace(member))
+			if errParse != nil || parsed.Host == "" {
+				addErr(fmt.Sprintf("%s.members[%d]", path, j), "invalid proxy URL")
+				continue
+			}
+			switch parsed.Scheme {
+			case "socks5", "http", "https":
+			default:
+				addErr(fmt.Sprintf("%s.members[%d]", path, j), "unsupported scheme %q (expected socks5, http or https)", parsed.Scheme)
+			}
+		}
+		check := pool.HealthCheck
+		if check.Interval < 0 || check.Timeout < 0 || check.FailureThreshold < 0 {
+			addErr(path+".health-check", "interval, timeout and failure-threshold must not be negative")
+		}
+		if check.URL != "" {
+			if parsed, errParse := url.Parse(check.URL); errParse != nil || parsed.Host == "" {
+				addErr(path+".health-check.url", "invalid URL")
+			}
+		}
+	}
+	validateTLSLayer := func(path string, layer UpstreamTLSSettings) {
+		if (strings.TrimSpace(layer.ClientCert) == "") != (strings.TrimSpace(layer.ClientKey) == "") {
+			addErr(path, "client-cert and client-key must be set together")
+		}
+		for i, pin := range layer.PinSHA256 {
+			if digest, errDecode := base64.StdEncoding.DecodeString(strings.TrimSpace(pin)); errDecode != nil || len(digest) != 32 {
+				addErr(fmt.Sprintf("%s.pin-sha256[%d]", path, i), "must be a base64 SHA-256 digest")
+			}
+		}
+		switch strings.TrimSpace(layer.MinVersion) {
+		case "", "1.2", "1.3":
+		default:
+			addErr(path+".min-version", "unsupported version %q (expected 1.2 or 1.3)", layer.MinVersion)
+		}
+	}
+	validateTLSLayer("upstream-tls", cfg.UpstreamTLS.UpstreamTLSSettings)
+	for name, layer := range cfg.UpstreamTLS.Providers {
+		validateTLSLayer("upstream-tls.providers."+name, layer)
+	}
+	for name, layer := range cfg.UpstreamTLS.Profiles {
+		if strings.TrimSpace(name) == "" {
+			addErr("upstream-tls.profiles", "profile name must not be empty")
+		}
+		validateTLSLayer("upstream-tls.profiles."+name, layer)
+	}
+	validateNotificationEvents := func(path string, events []string) {
+		for i, event := range events {
+			switch strings.TrimSpace(event) {
+			case NotificationEventStatusChanged, NotificationEventRecovered, NotificationEventCooldownStorm,
+				NotificationEventRefreshFailed, NotificationEventBudget:
+			default:
+				addErr(fmt.Sprintf("%s[%d]", path, i), "unknown event %q", event)
+			}
+		}
+	}
+	validateNotificationEvents("notifications.events", cfg.Notifications.Events)
+	for i, status := range cfg.Notifications.Statuses {
+		switch strings.TrimSpace(status) {
+		case "active", "pending", "refreshing", "error", "disabled":
+		default:
+			addErr(fmt.Sprintf("notifications.statuses[%d]", i), "unknown status %q", status)
+		}
+	}
+	if cfg.Notifications.BudgetPercent < 0 || cfg.Notifications.BudgetPercent > 100 {
+		addErr("notifications.budget-percent", "must be between 0 and 100")
+	}
+	if cfg.Notifications.DedupWindow < 0 {
+		addErr("notifications.dedup-window", "must not be negative")
+	}
+	if cfg.Notifications.RateLimit < 0 {
+		addErr("notifications.rate-limit", "must not be negative")
+	}
+	for i, webhook := range cfg.Notifications.Webhooks {
+		path := fmt.Sprintf("notifications.webhooks[%d]", i)
+		if parsed, errParse := url.Parse(strings.TrimSpace(webhook.URL)); errParse != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			addErr(path+".url", "must be an http or https URL")
+		}
+		if webhook.MaxRetries < 0 {
+			addErr(path+".max-retries", "must not be negative")
+		}
+		if webhook.Timeout < 0 {
+			addErr(path+".timeout", "must not be negative")
+		}
+		validateNotificationEvents(path+".events", webhook.Events)
+	}
+	for i, command := range cfg.Notifications.Commands {
+		path := fmt.Sprintf("notifications.commands[%d]", i)
+		if strings.TrimSpace(command.Command) == "" {
+			addErr(path+".command", "is required")
+		}
+		if command.Timeout < 0 {
+			addErr(path+".timeout", "must not be negative")
+		}
+		validateNotificationEvents(path+".events", command.Events)
+	}
+	if cfg.Notifications.Enabled && len(cfg.Notifications.Webhooks) == 0 && len(cfg.Notifications.Commands) == 0 {
+		addWarn("notifications", "enabled without webhooks or commands")
+	}
+	if cfg.QuotaForecast.RotateBefore < 0 {
+		addErr("quota-forecast.rotate-before", "must not be negative")
+	}
+	if cfg.QuotaForecast.ReservePercent < 0 || cfg.QuotaForecast.ReservePercent > 100 {
+		addErr("quota-forecast.reserve-percent", "must be between 0 and 100")
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.SignatureCache.Store)) {
+	case "", "memory", "disk":
+	default:
+		addErr("signature-cache.store", "unsupported store %q (expected memory or disk)", cfg.SignatureCache.Store)
+	}
+	for _, field := range []struct {
+		path  string
+		value int
+	}{
+		{"signature-cache.ttl-minutes", cfg.SignatureCache.TTLMinutes},
+		{"signature-cache.max-entries", cfg.SignatureCache.MaxEntries},
+		{"signature-cache.max-entries-per-group", cfg.SignatureCache.MaxEntriesPerGroup},
+	} {
+		if field.value < 0 {
+			addErr(field.path, "must not be negative")
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Routing.Strategy)) {
+	case "", "round-robin", "roundrobin", "rr", "fill-first", "fillfirst", "ff", "sticky-round-robin", "stickyroundrobin", "srr":
+	default:
+		addErr("routing.strategy", "unsupported strategy %q (expected round-robin, fill-first or sticky-round-robin)", cfg.Routing.Strategy)
+	}
+
+	if msg := validateProxyURL(cfg.ProxyURL); msg != "" {
+		addWarn("proxy-url", "%s; the proxy is ignored and requests go out directly", msg)
+	}
+
+	for i, key := range cfg.APIKeys {
+		if strings.TrimSpace(key) == "" {
+			addWarn(fmt.Sprintf("api-keys[%d]", i), "empty key is ignored")
+		}
+	}
+	templateNames := make(map[string]bool, len(cfg.PolicyTemplates))
+	for i, template := range cfg.PolicyTemplates {
+		path := fmt.Sprintf("policy-templates[%d]", i)
+		name := strings.TrimSpace(template.Name)
+		switch {
+		case name == "":
+			addErr(path+".name", "is required")
+		case templateNames[name]:
+			addErr(path+".name", "duplicate name %q", template.Name)
+		}
+		templateNames[name] = true
+		for j, model := range template.Models {
+			if strings.TrimSpace(model) == "" {
+				addWarn(fmt.Sprintf("%s.models[%d]", path, j), "empty model is ignored")
+			}
+		}
+		if effort := strings.TrimSpace(template.ReasoningEffort); effort != "" && !validReasoningEffort(effort) {
+			addErr(path+".reasoning-effort", "unsupported effort %q (expected minimal, low, medium, high, xhigh, none, auto or a token budget)", template.ReasoningEffort)
+		}
+		if template.ForceReasoningEffort && strings.TrimSpace(template.ReasoningEffort) == "" {
+			addErr(path+".force-reasoning-effort", "requires reasoning-effort")
+		}
+		if template.MaxTokens < 0 {
+			addErr(path+".max-tokens", "must not be negative")
+		}
+		for j, tool := range template.DenyTools {
+			if strings.TrimSpace(tool) == "" {
+				addErr(fmt.Sprintf("%s.deny-tools[%d]", path, j), "tool type must not be empty")
+			}
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Redaction.SecretAction)) {
+	case "", RedactionActionMask, RedactionActionBlock, RedactionActionLog, RedactionActionOff:
+	default:
+		addErr("redaction.secret-action", "unsupported action %q (expected mask, block, log or off)", cfg.Redaction.SecretAction)
+	}
+	// The built-in secret detectors own these rule names.
+	ruleNames := map[string]bool{"api-key": true, "private-key": true, "jwt": true}
+	for i, rule := range cfg.Redaction.Rules {
+		path := fmt.Sprintf("redaction.rules[%d]", i)
+		name := strings.ToLower(strings.TrimSpace(rule.Name))
+		switch {
+		case name == "":
+			addErr(path+".name", "is required")
+		case ruleNames[name]:
+			addErr(path+".name", "duplicate or reserved name %q", rule.Name)
+		}
+		ruleNames[name] = true
+		if strings.TrimSpace(rule.Pattern) == "" {
+			addErr(path+".pattern", "is required")
+		} else if _, errCompile := regexp.Compile(rule.Pattern); errCompile != nil {
+			addErr(path+".pattern", "invalid regular expression: %v", errCompile)
+		}
+		switch strings.ToLower(strings.TrimSpace(rule.Action)) {
+		case "", RedactionActionMask, RedactionActionBlock, RedactionActionLog:
+		default:
+			addErr(path+".action", "unsupported action %q (expected mask, block or log)", rule.Action)
+		}
+	}
+	seenClientKeys := make(map[string]int, len(cfg.ClientAPIKeys))
+	for i, entry := range cfg.ClientAPIKeys {
+		path := fmt.Sprintf("client-api-keys[%d]", i)
+		key := strings.TrimSpace(entry.Key)
+		if key == "" {
+			addErr(path+".key", "key is required")
+			continue
+		}
+		if prev, dup := seenClientKeys[key]; dup {
+			addErr(path+".key", "duplicates client-api-keys[%d]", prev)
+		}
+		seenClientKeys[key] = i
+		if strings.TrimSpace(entry.Scope.AuthID) != "" && strings.TrimSpace(entry.Scope.Provider) == "" {
+			addErr(path+".scope.provider", "provider is required when scope.auth_id is set")
+		}
+		for j, model := range entry.Scope.Models {
+			if strings.TrimSpace(model) == "" {
+				addWarn(fmt.Sprintf("%s.scope.models[%d]", path, j), "empty model is ignored")
+			}
+		}
+		if !ValidParameterPolicy(entry.ParameterPolicy) {
+			addErr(path+".parameter-policy", "unsupported policy %q (expected strict, lenient or warn)", entry.ParameterPolicy)
+		}
+		for j, name := range entry.Policies {
+			if !templateNames[strings.TrimSpace(name)] {
+				addErr(fmt.Sprintf("%s.policies[%d]", path, j), "unknown policy template %q", name)
+			}
+		}
+	}
+
+	compactionOptIn := cfg.ContextCompaction.Enabled || len(cfg.ContextCompaction.Models) > 0
+	for _, entry := range cfg.ClientAPIKeys {
+		if entry.ContextCompaction != nil && *entry.ContextCompaction {
+			compactionOptIn = true
+		}
+	}
+	if compactionOptIn && strings.TrimSpace(cfg.ContextCompaction.SummaryModel) == "" {
+		// Without a summary model compaction could only drop turns, which clients cannot detect.
+		addErr("context-compaction.summary-model", "is required when compaction is enabled")
+	}
+
+	if !ValidParameterPolicy(cfg.ParameterPolicy.Default) {
+		addErr("parameter-policy.default", "unsupported policy %q (expected strict, lenient or warn)", cfg.ParameterPolicy.Default)
+	}
+	seenMCPLabels := make(map[string]int, len(cfg.MCP.Servers))
+	for i, server := range cfg.MCP.Servers {
+		path := fmt.Sprintf("mcp.servers[%d]", i)
+		label := strings.TrimSpace(server.Label)
+		if label == "" {
+			addErr(path+".label", "label is required")
+		} else if prev, dup := seenMCPLabels[label]; dup {
+			addErr(path+".label", "duplicates mcp.servers[%d]", prev)
+		} else {
+			seenMCPLabels[label] = i
+		}
+		hasURL := strings.TrimSpace(server.URL) != ""
+		hasCommand := strings.TrimSpace(server.Command) != ""
+		if hasURL == hasCommand {
+			addErr(path, "exactly one of url or command must be set")
+		}
+	}
+	if cfg.MCP.CallTimeout < 0 {
+		addErr("mcp.call-timeout", "must not be negative")
+	}
+	if cfg.MCP.MaxTurns < 0 {
+		addErr("mcp.max-turns", "must not be negative")
+	}
+	for i, tool := range cfg.Sandbox.Tools {
+		switch strings.ToLower(strings.TrimSpace(tool)) {
+		case SandboxToolCodeInterpreter, SandboxToolShell, SandboxToolLocalShell:
+		default:
+			addErr(fmt.Sprintf("sandbox.tools[%d]", i), "unsupported tool %q (expected code_interpreter, shell or local_shell)", tool)
+		}
+	}
+	for _, limit := range []struct {
+		path  string
+		value int
+	}{
+		{"sandbox.timeout", cfg.Sandbox.Timeout},
+		{"sandbox.cpu-seconds", cfg.Sandbox.CPUSeconds},
+		{"sandbox.memory-mb", cfg.Sandbox.MemoryMB},
+		{"sandbox.max-processes", cfg.Sandbox.MaxProcesses},
+		{"sandbox.max-output-bytes", cfg.Sandbox.MaxOutputBytes},
+		{"sandbox.max-turns", cfg.Sandbox.MaxTurns},
+	} {
+		if limit.value < 0 {
+			addErr(limit.path, "must not be negative")
+		}
+	}
+	if cfg.Sandbox.Enabled && cfg.Sandbox.AllowNetwork {
+		addWarn("sandbox.allow-network", "sandboxed code can reach the network")
+	}
+	if cfg.Sandbox.Enabled && cfg.Sandbox.AllowHostFilesystem {
+		addWarn("sandbox.allow-host-filesystem", "sandboxed code can read files outside its workspace")
+	}
+	for _, limit := range []struct {
+		path  string
+		value int
+	}{
+		{"gemini-files.max-file-mb", cfg.GeminiFiles.MaxFileMB},
+		{"gemini-files.file-ttl-hours", cfg.GeminiFiles.FileTTLHours},
+		{"gemini-files.cache-ttl-seconds", cfg.GeminiFiles.CacheTTLSeconds},
+		{"gemini-files.max-cache-ttl-hours", cfg.GeminiFiles.MaxCacheTTLHours},
+		{"files.max-file-mb", cfg.Files.MaxFileMB},
+		{"files.quota-mb", cfg.Files.QuotaMB},
+		{"files.ttl-hours", cfg.Files.TTLHours},
+	} {
+		if limit.value < 0 {
+			addErr(limit.path, "must not be negative")
+		}
+	}
+
+	for model, policy := range cfg.ParameterPolicy.Models {
+		path := "parameter-policy.models." + model
+		if strings.TrimSpace(model) == "" {
+			addErr("parameter-policy.models", "model name must not be empty")
+			continue
+		}
+		if strings.TrimSpace(policy) == "" || !ValidParameterPolicy(policy) {
+			addErr(path, "unsupported policy %q (expected strict, lenient or warn)", policy)
+		}
+	}
+
+	for rawChannel, aliases := range cfg.OAuthModelAlias {
+		channel := strings.ToLower(strings.TrimSpace(rawChannel))
+		path := "oauth-model-alias." + rawChannel
+		if channel == "" {
+			addWarn("oauth-model-alias", "channel with an empty name is ignored")
+			continue
+		}
+		if _, ok := knownOAuthModelAliasChannels[channel]; !ok {
+			addWarn(path, "unknown channel %q; aliases will never match", rawChannel)
+		}
+		seenAlias := make(map[string]int, len(aliases))
+		for i, entry := range aliases {
+			entryPath := fmt.Sprintf("%s[%d]", path, i)
+			name := strings.TrimSpace(entry.Name)
+			alias := strings.TrimSpace(entry.Alias)
+			if name == "" {
+				addErr(entryPath+".name", "name is required")
+			}
+			if alias == "" {
+				addErr(entryPath+".alias", "alias is required")
+			}
+			if name == "" || alias == "" {
+				continue
+			}
+			if strings.EqualFold(name, alias) {
+				addWarn(entryPath, "alias equals name and is ignored")
+				continue
+			}
+			aliasKey := strings.ToLower(alias)
+			if prev, dup := seenAlias[aliasKey]; dup {
+				addErr(entryPath+".alias", "alias %q duplicates %s[%d]", alias, path, prev)
+				continue
+			}
+			seenAlias[aliasKey] = i
+		}
+	}
+
+	for rawChannel, models := range cfg.OAuthExcludedModels {
+		if strings.TrimSpace(rawChannel) == "" {
+			addWarn("oauth-excluded-models", "channel with an empty name is ignored")
+			continue
+		}
+		for i, model := range models {
+			if strings.TrimSpace(model) == "" {
+				addWarn(fmt.Sprintf("oauth-excluded-models.%s[%d]", rawChannel, i), "empty model is ignored")
+			}
+		}
+	}
+
+	validateRaw := func(section string, rules []PayloadRule) {
+		for i, rule := range rules {
+			for param, value := range rule.Params {
+				raw, ok := payloadRawString(value)
+				if !ok {
+					continue
+				}
+				trimmed := bytes.TrimSpace(raw)
+				if len(trimmed) == 0 || !json.Valid(trimmed) {
+					addErr(fmt.Sprintf("payload.%s[%d].params.%s", section, i, param), "value must be valid JSON")
+				}
+			}
+		}
+	}
+	validateRaw("default-raw", cfg.Payload.DefaultRaw)
+	validateRaw("override-raw", cfg.Payload.OverrideRaw)
+
+	return issues
+}
+
+// validReasoningEffort reports whether value is a thinking level, special value or token budget.
+func validReasoningEffort(value string) bool {
+	switch strings.ToLower(value) {
+	case "minimal", "low", "medium", "high", "xhigh", "none", "auto":
+		return true
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func validateProxyURL(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return ""
+	}
+	parsed, err := url.Parse(trimmed)
+	if err != nil {
+		return fmt.Sprintf("invalid URL: %v", err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Sprintf("unsupported scheme %q (expected http, https or socks5)", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return "host is required"
+	}
+	return ""
+}
+
+// unknownKeyIssues walks a YAML node tree alongside the Go type it decodes into and
+// reports mapping keys that the type does not declare.
+func unknownKeyIssues(node *yaml.Node, t reflect.Type, path string) []ValidationIssue {
+	if node == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	var issues []ValidationIssue
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := yamlStructFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			childPath := joinValidationPath(path, keyNode.Value)
+			fieldType, ok := fields[keyNode.Value]
+			if !ok {
+				issues = append(issues, ValidationIssue{
+					Path:     childPath,
+					Line:     keyNode.Line,
+					Severity: ValidationSeverityWarning,
+					Message:  "unknown key (possible typo); it will be ignored",
+				})
+				continue
+			}
+			issues = append(issues, unknownKeyIssues(valueNode, fieldType, childPath)...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			issues = append(issues, unknownKeyIssues(node.Content[i+1], t.Elem(), joinValidationPath(path, node.Content[i].Value))...)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			issues = append(issues, unknownKeyIssues(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return issues
+}
+
+func joinValidationPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
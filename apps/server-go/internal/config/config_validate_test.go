@@ -0,0 +1,163 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigData_ReportsAliasAndClientKeyTypos(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`
+port: 8317
+client-api-keys:
+  - kye: "typo-key"
+oauth-model-alias:
+  antigravity:
+    - name: "gemini-3-pro-high"
+      alais: "gemini-3-pro-preview"
+`)
+	issues := ValidateConfigData(data, "")
+	if !HasValidationErrors(issues) {
+		t.Fatalf("expected validation errors, got %+v", issues)
+	}
+
+	want := map[string]string{
+		"client-api-keys[0].kye":                 ValidationSeverityWarning,
+		"client-api-keys[0].key":                 ValidationSeverityError,
+		"oauth-model-alias.antigravity[0].alais": ValidationSeverityWarning,
+		"oauth-model-alias.antigravity[0].alias": ValidationSeverityError,
+	}
+	got := make(map[string]string, len(issues))
+	for _, issue := range issues {
+		got[issue.Path] = issue.Severity
+	}
+	for path, severity := range want {
+		if got[path] != severity {
+			t.Fatalf("expected %s issue at %s, got issues %+v", severity, path, issues)
+		}
+	}
+}
+
+func TestValidateConfigData_AcceptsValidConfig(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`
+port: 8317
+proxy-url: "socks5://127.0.0.1:1080"
+routing:
+  strategy: fill-first
+client-api-keys:
+  - key: "k1"
+    scope:
+      provider: auggie
+oauth-model-alias:
+  antigravity:
+    - name: "gemini-3-pro-high"
+      alias: "gemini-3-pro-preview"
+config-reload:
+  history-size: 3
+`)
+	if issues := ValidateConfigData(data, ""); len(issues) != 0 {
+		t.Fatalf("expected no issues, got %+v", issues)
+	}
+}
+
+func TestValidateConfigData_SemanticErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"routing:\n  strategy: random\n":             "routing.strategy",
+		"client-api-keys:\n  - key: a\n  - key: a\n": "client-api-keys[1].key",
+		"oauth-model-alias:\n  antigravity:\n    - name: a\n      alias: x\n    - name: b\n      alias: x\n": "oauth-model-alias.antigravity[1].alias",
+		"context-compaction:\n  enabled: true\n": "context-compaction.summary-model",
+		"port: 70000\n":                          "port",
+		"tls:\n  enable: true\n":                 "tls",
+		"client-api-keys:\n  - key: a\n    scope:\n      auth_id: x\n":                                          "client-api-keys[0].scope.provider",
+		"client-api-keys:\n  - key: a\n    parameter-policy: loose\n":                                           "client-api-keys[0].parameter-policy",
+		"parameter-policy:\n  default: ignore\n":                                                                "parameter-policy.default",
+		"sandbox:\n  tools: [browser]\n":                                                                        "sandbox.tools[0]",
+		"sandbox:\n  timeout: -1\n":                                                                             "sandbox.timeout",
+		"files:\n  quota-mb: -5\n":                                                                              "files.quota-mb",
+		"gemini-files:\n  max-file-mb: -1\n":                                                                    "gemini-files.max-file-mb",
+		"cluster:\n  backend: redis\n":                                                                          "cluster.backend",
+		"signature-cache:\n  store: redis\n":                                                                    "signature-cache.store",
+		"health-probe:\n  interval: -1\n":                                                                       "health-probe.interval",
+		"quota-forecast:\n  reserve-percent: 150\n":                                                             "quota-forecast.reserve-percent",
+		"cassette:\n  mode: replay\n":                                                                           "cassette.dir",
+		"policy-templates:\n  - name: p\n    reasoning-effort: max\n":                                           "policy-templates[0].reasoning-effort",
+		"policy-templates:\n  - name: p\nclient-api-keys:\n  - key: k\n    policies: [q]\n":                     "client-api-keys[0].policies[0]",
+		"cassette:\n  mode: tape\n  dir: x\n":                                                                   "cassette.mode",
+		"proxy-pools:\n  - name: egress\n    members: [ftp://p:1]\n":                                            "proxy-pools[0].members[0]",
+		"upstream-tls:\n  client-cert: c.pem\n":                                                                 "upstream-tls",
+		"upstream-tls:\n  profiles:\n    corp:\n      min-version: \"1.1\"\n":                                   "upstream-tls.profiles.corp.min-version",
+		"mock-provider:\n  - models: []\n":                                                                      "mock-provider[0].name",
+		"mock-provider:\n  - name: m\n    models:\n      - name: x\n        faults:\n          - status: 200\n": "mock-provider[0].models[0].faults[0].status",
+		"redaction:\n  rules:\n    - name: email\n      pattern: \"[\"\n":                                       "redaction.rules[0].pattern",
+		"redaction:\n  rules:\n    - name: jwt\n      pattern: x\n":                                             "redaction.rules[0].name",
+		"notifications:\n  webhooks:\n    - url: ftp://hooks\n":                                                 "notifications.webhooks[0].url",
+		"notifications:\n  events: [quota]\n":                                                                   "notifications.events[0]",
+		"port: [\n":                                                                                             "$",
+	}
+	for input, path := range cases {
+		issues := ValidateConfigData([]byte(input), "")
+		found := false
+		for _, issue := range issues {
+			if issue.Path == path && issue.Severity == ValidationSeverityError {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("input %q: expected error at %s, got %+v", input, path, issues)
+		}
+	}
+}
+
+func TestValidateConfigData_LoaderFallbacksAreWarnings(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"proxy-url: \"ftp://proxy\"\n": "proxy-url",
+	}
+	for input, path := range cases {
+		issues := ValidateConfigData([]byte(input), "")
+		if HasValidationErrors(issues) {
+			t.Fatalf("input %q: expected no errors, got %+v", input, issues)
+		}
+		found := false
+		for _, issue := range issues {
+			if issue.Path == path && issue.Severity == ValidationSeverityWarning {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("input %q: expected warning at %s, got %+v", input, path, issues)
+		}
+	}
+}
+
+func TestJSONSchema_DescribesConfigKeys(t *testing.T) {
+	t.Parallel()
+
+	schema := JSONSchema()
+	props, ok := schema["properties"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected properties map, got %T", schema["properties"])
+	}
+	for _, key := range []string{"port", "api-keys", "client-api-keys", "oauth-model-alias", "proxy-url", "config-reload"} {
+		if _, exists := props[key]; !exists {
+			t.Fatalf("expected schema property %q", key)
+		}
+	}
+	alias := props["oauth-model-alias"].(map[string]any)
+	items := alias["additionalProperties"].(map[string]any)["items"].(map[string]any)
+	itemProps := items["properties"].(map[string]any)
+	if _, exists := itemProps["alias"]; !exists {
+		t.Fatalf("expected alias entry properties, got %v", itemProps)
+	}
+	for key := range props {
+		if strings.Contains(key, "legacy") {
+			t.Fatalf("unexpected internal field %q in schema", key)
+		}
+	}
+}
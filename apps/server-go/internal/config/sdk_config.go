@@ -4,6 +4,8 @@
 // debug settings, proxy configuration, and API keys.
 package config
 
+import "strings"
+
 // SDKConfig represents the application's configuration, loaded from a YAML file.
 type SDKConfig struct {
 	// ProxyURL is the URL of an optional proxy server to use for outbound requests.
@@ -33,6 +35,40 @@ type SDKConfig struct {
 	// NonStreamKeepAliveInterval controls how often blank lines are emitted for non-streaming responses.
 	// <= 0 disables keep-alives. Value is in seconds.
 	NonStreamKeepAliveInterval int `yaml:"nonstream-keepalive-interval,omitempty" json:"nonstream-keepalive-interval,omitempty"`
+
+	// Guardrails configures pre-flight checks applied before a request is sent upstream.
+	Guardrails GuardrailsConfig `yaml:"guardrails,omitempty" json:"guardrails,omitempty"`
+
+	// ContextCompaction configures automatic compaction of overlong conversations.
+	ContextCompaction ContextCompactionConfig `yaml:"context-compaction,omitempty" json:"context-compaction,omitempty"`
+
+	// StructuredOutput controls json_schema/json_object emulation on providers without native support.
+	StructuredOutput StructuredOutputConfig `yaml:"structured-output,omitempty" json:"structured-output,omitempty"`
+
+	// ParameterEmulation opts providers into proxy-side emulation of request parameters they cannot
+	// honor natively, keyed by provider identifier (for example "auggie").
+	ParameterEmulation map[string]ParameterEmulationConfig `yaml:"parameter-emulation,omitempty" json:"parameter-emulation,omitempty"`
+
+	// ParameterPolicy decides what happens to request parameters a provider cannot honor.
+	ParameterPolicy ParameterPolicyConfig `yaml:"parameter-policy,omitempty" json:"parameter-policy,omitempty"`
+
+	// MCP configures server-side execution of Responses API "mcp" tools.
+	MCP MCPConfig `yaml:"mcp,omitempty" json:"mcp,omitempty"`
+
+	// Sandbox configures local execution of Responses API code_interpreter and shell tools.
+	Sandbox SandboxConfig `yaml:"sandbox,omitempty" json:"sandbox,omitempty"`
+
+	// PolicyTemplates are named request policies applied to client keys or models before translation.
+	PolicyTemplates []PolicyTemplate `yaml:"policy-templates,omitempty" json:"policy-templates,omitempty"`
+
+	// Redaction filters secrets and personal data out of prompts before they are sent upstream.
+	Redaction RedactionConfig `yaml:"redaction,omitempty" json:"redaction,omitempty"`
+
+	// GeminiFiles serves the Gemini Files and cachedContents APIs from a local directory.
+	GeminiFiles GeminiFilesConfig `yaml:"gemini-files,omitempty" json:"gemini-files,omitempty"`
+
+	// Files serves the OpenAI Files API from a local directory and resolves file_id attachments.
+	Files FilesConfig `yaml:"files,omitempty" json:"files,omitempty"`
 }
 
 // ClientAPIKey describes a proxy client key managed by the application.
@@ -41,6 +77,14 @@ type ClientAPIKey struct {
 	Enabled *bool             `yaml:"enabled,omitempty" json:"enabled,omitempty"`
 	Note    string            `yaml:"note,omitempty" json:"note,omitempty"`
 	Scope   ClientAPIKeyScope `yaml:"scope,omitempty" json:"scope,omitempty"`
+	// ContextCompaction overrides context-compaction for requests made with this key when set.
+	ContextCompaction *bool `yaml:"context-compaction,omitempty" json:"context-compaction,omitempty"`
+	// ParameterPolicy overrides parameter-policy (strict, lenient or warn) for this key when set.
+	ParameterPolicy string `yaml:"parameter-policy,omitempty" json:"parameter-policy,omitempty"`
+	// Sandbox overrides sandbox.enabled for requests made with this key when set.
+	Sandbox *bool `yaml:"sandbox,omitempty" json:"sandbox,omitempty"`
+	// Policies names policy-templates applied to requests made with this key, ahead of model matches.
+	Policies []string `yaml:"policies,omitempty" json:"policies,omitempty"`
 }
 
 // ClientAPIKeyScope restricts a client key to a provider/auth pair and optional model allowlist.
@@ -187,4 +231,348 @@ type StreamingConfig struct {
 	// to allow auth rotation / transient recovery.
 	// <= 0 disables bootstrap retries. Default is 0.
 	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
+
+	// ResumeGraceSeconds keeps chat, messages and responses streams running and buffered for this long
+	// after the client disconnects, so a reconnect carrying Last-Event-ID can replay the missed events.
+	// <= 0 disables resumable streams. Default is 0.
+	ResumeGraceSeconds int `yaml:"resume-grace-seconds,omitempty" json:"resume-grace-seconds,omitempty"`
+
+	// ResumeMaxEvents caps how many events are buffered per resumable stream; older events are dropped.
+	// <= 0 uses the default of 4096.
+	ResumeMaxEvents int `yaml:"resume-max-events,omitempty" json:"resume-max-events,omitempty"`
+}
+
+// GuardrailsConfig holds pre-flight request guardrail configuration.
+type GuardrailsConfig struct {
+	// ContextWindow rejects requests whose estimated input tokens exceed the model's context window
+	// instead of forwarding them upstream. Default is false.
+	ContextWindow bool `yaml:"context-window,omitempty" json:"context-window,omitempty"`
+
+	// ClampOutputTokens lowers max_tokens / max_output_tokens values that exceed the model's
+	// output limit to that limit. Default is false.
+	ClampOutputTokens bool `yaml:"clamp-output-tokens,omitempty" json:"clamp-output-tokens,omitempty"`
+
+	// ContextFallbacks maps a model to a larger-context model that is used instead when the
+	// estimated prompt does not fit the requested model's window.
+	ContextFallbacks map[string]string `yaml:"context-fallbacks,omitempty" json:"context-fallbacks,omitempty"`
+}
+
+// ContextCompactionConfig holds automatic conversation compaction configuration.
+// Compaction applies to chat completions and Claude messages requests when enabled globally,
+// when the requested model is listed in Models, or when the client key opts in.
+type ContextCompactionConfig struct {
+	// Enabled turns compaction on for every model. Default is false.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// Models limits compaction to the listed models when Enabled is false.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Threshold is the fraction of the model's context window at which compaction starts.
+	// <= 0 or >= 1 uses the default of 0.8.
+	Threshold float64 `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+
+	// SummaryModel is the model used to summarize older turns. It is required whenever
+	// compaction is enabled globally, for some models, or by a client key.
+	SummaryModel string `yaml:"summary-model,omitempty" json:"summary-model,omitempty"`
+
+	// KeepRecent is the number of most recent messages always forwarded verbatim. Default is 8.
+	KeepRecent int `yaml:"keep-recent,omitempty" json:"keep-recent,omitempty"`
+}
+
+// StructuredOutputConfig configures structured output emulation for the Auggie bridge, which has
+// no native response_format / text.format support.
+type StructuredOutputConfig struct {
+	// Emulate accepts json_schema and json_object formats by prompting for JSON and validating
+	// the buffered result locally instead of rejecting the request.
+	Emulate bool `yaml:"emulate,omitempty" json:"emulate,omitempty"`
+
+	// MaxRetries is the number of corrective retries after a response fails validation.
+	// 0 uses the default of 2; a negative value disables retries.
+	MaxRetries int `yaml:"max-retries,omitempty" json:"max-retries,omitempty"`
+}
+
+// ParameterEmulationConfig selects which request parameters the proxy enforces itself for a provider.
+// Parameters that are not emulated keep being rejected by providers that cannot honor them.
+type ParameterEmulationConfig struct {
+	// Stop watches the response stream for stop sequences and truncates at the first match.
+	Stop bool `yaml:"stop,omitempty" json:"stop,omitempty"`
+
+	// MaxTokens counts output tokens with the local tokenizer and cuts the stream off at
+	// max_tokens / max_completion_tokens / max_output_tokens.
+	MaxTokens bool `yaml:"max-tokens,omitempty" json:"max-tokens,omitempty"`
+
+	// N fans out n parallel upstream calls and merges them into indexed choices.
+	N bool `yaml:"n,omitempty" json:"n,omitempty"`
+}
+
+// Parameter policy values accepted by parameter-policy and client-api-keys[].parameter-policy.
+const (
+	// ParameterPolicyStrict rejects requests carrying parameters the provider cannot honor.
+	ParameterPolicyStrict = "strict"
+	// ParameterPolicyLenient silently drops unsupported parameters and forwards the rest.
+	ParameterPolicyLenient = "lenient"
+	// ParameterPolicyWarn drops unsupported parameters and reports them in a response header.
+	ParameterPolicyWarn = "warn"
+)
+
+// ParameterPolicyConfig selects how unsupported request parameters are handled.
+// A client key override wins over a matching model rule, which wins over Default.
+type ParameterPolicyConfig struct {
+	// Default applies when no model rule or client key override matches. Empty means strict.
+	Default string `yaml:"default,omitempty" json:"default,omitempty"`
+
+	// Models maps model names (wildcards allowed) to a policy. An exact name wins over patterns,
+	// and the pattern with the longest literal part wins over shorter ones.
+	Models map[string]string `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// ValidParameterPolicy reports whether value names a known parameter policy. Empty is allowed.
+func ValidParameterPolicy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", ParameterPolicyStrict, ParameterPolicyLenient, ParameterPolicyWarn:
+		return true
+	}
+	return false
+}
+
+// Redaction actions accepted by redaction.secret-action and redaction.rules[].action.
+const (
+	// RedactionActionMask replaces each match with a placeholder such as [REDACTED_EMAIL_1].
+	RedactionActionMask = "mask"
+	// RedactionActionBlock rejects the request with a 400 error.
+	RedactionActionBlock = "block"
+	// RedactionActionLog forwards the request unchanged and only logs and counts the match.
+	RedactionActionLog = "log"
+	// RedactionActionOff disables the built-in secret detectors.
+	RedactionActionOff = "off"
+)
+
+// RedactionConfig configures the outbound prompt content filter. Rules apply to every string in
+// the request (messages, tool results and instructions) before translation.
+type RedactionConfig struct {
+	// Enabled turns the filter on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// SecretAction is the action for the built-in detectors of API keys, private keys and JWTs:
+	// mask (default), block, log or off.
+	SecretAction string `yaml:"secret-action,omitempty" json:"secret-action,omitempty"`
+
+	// RestoreOutput puts masked values back where the model's output repeats their placeholders.
+	// Placeholders are scoped to a single request.
+	RestoreOutput bool `yaml:"restore-output,omitempty" json:"restore-output,omitempty"`
+
+	// Rules are additional patterns, typically for personal data.
+	Rules []RedactionRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// RedactionRule is a named regular expression and the action taken on a match.
+type RedactionRule struct {
+	// Name identifies the rule in logs, placeholders and usage statistics.
+	Name string `yaml:"name" json:"name"`
+
+	// Pattern is a Go regular expression.
+	Pattern string `yaml:"pattern" json:"pattern"`
+
+	// Action is mask (default), block or log.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+}
+
+// PolicyTemplate is a named request policy. It applies to requests made with a client key listing
+// it in policies and to requests for a model matching one of Models. When several templates apply,
+// the client key's come first in the order listed, then model matches in config order; the first
+// template setting a reasoning effort wins, and the lowest max-tokens cap wins.
+type PolicyTemplate struct {
+	// Name identifies the template and must be unique.
+	Name string `yaml:"name" json:"name"`
+
+	// Models lists model names (wildcards allowed) the template applies to regardless of client key.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// StripSystem removes the client's system instructions before SystemPrepend and SystemAppend apply.
+	StripSystem bool `yaml:"strip-system,omitempty" json:"strip-system,omitempty"`
+
+	// SystemPrepend is placed before the client's system instructions.
+	SystemPrepend string `yaml:"system-prepend,omitempty" json:"system-prepend,omitempty"`
+
+	// SystemAppend is placed after the client's system instructions.
+	SystemAppend string `yaml:"system-append,omitempty" json:"system-append,omitempty"`
+
+	// ReasoningEffort is a thinking level (minimal, low, medium, high, xhigh, none, auto) or token
+	// budget used when the client does not choose one.
+	ReasoningEffort string `yaml:"reasoning-effort,omitempty" json:"reasoning-effort,omitempty"`
+
+	// ForceReasoningEffort replaces a reasoning effort chosen by the client.
+	ForceReasoningEffort bool `yaml:"force-reasoning-effort,omitempty" json:"force-reasoning-effort,omitempty"`
+
+	// MaxTokens caps the output token parameter, setting it when the client omits it. 0 means no cap.
+	MaxTokens int `yaml:"max-tokens,omitempty" json:"max-tokens,omitempty"`
+
+	// DenyTools rejects requests declaring a tool of one of these types, e.g. "web_search",
+	// "code_interpreter", "mcp" or "function". A type also matches its versioned variants such as
+	// "web_search_preview" or "web_search_20250305".
+	DenyTools []string `yaml:"deny-tools,omitempty" json:"deny-tools,omitempty"`
+}
+
+// MCPConfig configures the server-side MCP client that executes Responses API "mcp" tools for
+// backends without native MCP support.
+type MCPConfig struct {
+	// Enabled turns on server-side MCP tool execution. When false, mcp tools are rejected.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// AllowRemoteServers lets requests connect to any server_url. When false only the servers
+	// listed in Servers can be used, referenced by server_label.
+	AllowRemoteServers bool `yaml:"allow-remote-servers,omitempty" json:"allow-remote-servers,omitempty"`
+
+	// CallTimeout bounds each tools/list and tools/call request, in seconds. Default is 60.
+	CallTimeout int `yaml:"call-timeout,omitempty" json:"call-timeout,omitempty"`
+
+	// MaxTurns bounds the number of model turns in one request's tool loop. Default is 8.
+	MaxTurns int `yaml:"max-turns,omitempty" json:"max-turns,omitempty"`
+
+	// Servers are MCP servers the proxy knows by label.
+	Servers []MCPServerConfig `yaml:"servers,omitempty" json:"servers,omitempty"`
+}
+
+// MCPServerConfig describes one MCP server. Either URL (streamable HTTP) or Command (stdio) is set.
+type MCPServerConfig struct {
+	// Label is the server_label requests use to reference this server.
+	Label string `yaml:"label" json:"label"`
+
+	// URL is the streamable HTTP endpoint of the server.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// Headers are sent with every HTTP request, for example an Authorization header.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// Command launches a stdio server. The process is started on first use and kept running.
+	Command string `yaml:"command,omitempty" json:"command,omitempty"`
+
+	// Args are the command-line arguments for Command.
+	Args []string `yaml:"args,omitempty" json:"args,omitempty"`
+
+	// Env adds environment variables for Command.
+	Env map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+}
+
+// Sandbox tool types that can be executed locally.
+const (
+	SandboxToolCodeInterpreter = "code_interpreter"
+	SandboxToolShell           = "shell"
+	SandboxToolLocalShell      = "local_shell"
+)
+
+// GeminiFilesConfig configures the local emulation of the Gemini Files API (/upload/v1beta/files,
+// /v1beta/files) and cachedContents API (/v1beta/cachedContents). Uploaded files and cached
+// contents are stored on disk per client key, and generate requests that reference them are
+// rewritten to carry the content inline before they are sent upstream.
+type GeminiFilesConfig struct {
+	// Enabled registers the endpoints. Default is false.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// Dir is where files and cached contents are stored. Default is a directory under the
+	// system temp dir.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// MaxFileMB caps the size of one uploaded file or cached content, in MiB. Default is 100.
+	MaxFileMB int `yaml:"max-file-mb,omitempty" json:"max-file-mb,omitempty"`
+
+	// FileTTLHours is how long uploaded files are kept. Default is 48, matching Gemini.
+	FileTTLHours int `yaml:"file-ttl-hours,omitempty" json:"file-ttl-hours,omitempty"`
+
+	// CacheTTLSeconds is the lifetime of cached contents created without ttl or expireTime.
+	// Default is 3600.
+	CacheTTLSeconds int `yaml:"cache-ttl-seconds,omitempty" json:"cache-ttl-seconds,omitempty"`
+
+	// MaxCacheTTLHours caps the lifetime a client may request for cached contents. Default is 24.
+	MaxCacheTTLHours int `yaml:"max-cache-ttl-hours,omitempty" json:"max-cache-ttl-hours,omitempty"`
+}
+
+// FilesConfig configures the local OpenAI Files API (/v1/files). Uploaded files are stored on disk
+// per client key, and chat or Responses requests that reference them by file_id are rewritten to
+// carry the content inline before they are translated for the upstream provider.
+type FilesConfig struct {
+	// Enabled registers the endpoints and file_id resolution. Default is false.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// Dir is where files are stored. Default is a directory under the system temp dir.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// MaxFileMB caps the size of one uploaded file, in MiB. Default is 100.
+	MaxFileMB int `yaml:"max-file-mb,omitempty" json:"max-file-mb,omitempty"`
+
+	// QuotaMB caps the total size of the files stored for one client key, in MiB. 0 is unlimited.
+	QuotaMB int `yaml:"quota-mb,omitempty" json:"quota-mb,omitempty"`
+
+	// TTLHours is how long uploaded files are kept. Default is 720 (30 days); files are removed
+	// lazily once expired.
+	TTLHours int `yaml:"ttl-hours,omitempty" json:"ttl-hours,omitempty"`
+}
+
+// SandboxConfig configures the local executor for Responses API code_interpreter, shell and
+// local_shell tools. Code runs in a subprocess with resource limits, a timeout, a private working
+// directory and, on Linux, no network access.
+type SandboxConfig struct {
+	// Enabled turns on local tool execution for every client key. Keys can override it with
+	// their own sandbox flag.
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// Tools lists the tool types that may run locally. Empty allows all of code_interpreter,
+	// shell and local_shell.
+	Tools []string `yaml:"tools,omitempty" json:"tools,omitempty"`
+
+	// AllowedCommands lists the programs shell and local_shell calls may start, matched against
+	// the base name of the first word of each command. "*" allows any program. Empty rejects
+	// every shell command.
+	AllowedCommands []string `yaml:"allowed-commands,omitempty" json:"allowed-commands,omitempty"`
+
+	// PythonCommand is the interpreter used for code_interpreter. Default is "python3".
+	PythonCommand string `yaml:"python-command,omitempty" json:"python-command,omitempty"`
+
+	// WorkDir is the directory under which each request gets its own working directory.
+	// Default is a directory under the system temp dir.
+	WorkDir string `yaml:"work-dir,omitempty" json:"work-dir,omitempty"`
+
+	// Timeout bounds each execution, in seconds. Default is 30.
+	Timeout int `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// CPUSeconds limits CPU time per execution. Default is the timeout.
+	CPUSeconds int `yaml:"cpu-seconds,omitempty" json:"cpu-seconds,omitempty"`
+
+	// MemoryMB limits the address space per execution, in MiB. Default is 512.
+	MemoryMB int `yaml:"memory-mb,omitempty" json:"memory-mb,omitempty"`
+
+	// MaxProcesses limits the number of processes per execution. Default is 64.
+	MaxProcesses int `yaml:"max-processes,omitempty" json:"max-processes,omitempty"`
+
+	// MaxOutputBytes caps the captured stdout and stderr of each execution. Default is 65536.
+	MaxOutputBytes int `yaml:"max-output-bytes,omitempty" json:"max-output-bytes,omitempty"`
+
+	// AllowNetwork keeps network access. By default executions run in an isolated network
+	// namespace, which requires Linux with unprivileged user namespaces.
+	AllowNetwork bool `yaml:"allow-network,omitempty" json:"allow-network,omitempty"`
+
+	// AllowHostFilesystem runs executions without confining them to their workspace. By default
+	// Landlock hides everything but the workspace and read-only system directories, which
+	// requires Linux 5.13 or newer.
+	AllowHostFilesystem bool `yaml:"allow-host-filesystem,omitempty" json:"allow-host-filesystem,omitempty"`
+
+	// MaxTurns bounds the number of model turns in one request's tool loop. Default is 8.
+	MaxTurns int `yaml:"max-turns,omitempty" json:"max-turns,omitempty"`
+
+	// AuditLog is a file that receives one JSON line per execution. Empty disables the file;
+	// executions are always logged at info level.
+	AuditLog string `yaml:"audit-log,omitempty" json:"audit-log,omitempty"`
+}
+
+// AllowsTool reports whether tool may be executed locally.
+func (c SandboxConfig) AllowsTool(tool string) bool {
+	if len(c.Tools) == 0 {
+		return tool == SandboxToolCodeInterpreter || tool == SandboxToolShell || tool == SandboxToolLocalShell
+	}
+	for _, allowed := range c.Tools {
+		if strings.EqualFold(strings.TrimSpace(allowed), tool) {
+			return true
+		}
+	}
+	return false
 }
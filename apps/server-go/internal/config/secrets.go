@@ -0,0 +1,345 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SecretResolver resolves the reference part of a ${SCHEME:reference} placeholder in config.yaml.
+type SecretResolver interface {
+	// Scheme returns the upper-case placeholder scheme handled by the resolver (e.g. "ENV").
+	Scheme() string
+	// Resolve returns the secret value for ref. baseDir is the directory containing config.yaml.
+	Resolve(ref string, baseDir string) (string, error)
+}
+
+var (
+	secretResolversMu sync.RWMutex
+	secretResolvers   = map[string]SecretResolver{}
+)
+
+func init() {
+	RegisterSecretResolver(envSecretResolver{})
+	RegisterSecretResolver(fileSecretResolver{})
+	RegisterSecretResolver(keychainSecretResolver{})
+}
+
+// RegisterSecretResolver installs or replaces the resolver for its scheme.
+func RegisterSecretResolver(resolver SecretResolver) {
+	if resolver == nil {
+		return
+	}
+	scheme := strings.ToUpper(strings.TrimSpace(resolver.Scheme()))
+	if scheme == "" {
+		return
+	}
+	secretResolversMu.Lock()
+	secretResolvers[scheme] = resolver
+	secretResolversMu.Unlock()
+}
+
+// UnregisterSecretResolver removes the resolver for scheme.
+func UnregisterSecretResolver(scheme string) {
+	secretResolversMu.Lock()
+	delete(secretResolvers, strings.ToUpper(strings.TrimSpace(scheme)))
+	secretResolversMu.Unlock()
+}
+
+func lookupSecretResolver(scheme string) SecretResolver {
+	secretResolversMu.RLock()
+	defer secretResolversMu.RUnlock()
+	return secretResolvers[strings.ToUpper(scheme)]
+}
+
+// envSecretResolver resolves ${ENV:NAME} from the process environment.
+type envSecretResolver struct{}
+
+func (envSecretResolver) Scheme() string { return "ENV" }
+
+func (envSecretResolver) Resolve(ref string, _ string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+}
+
+// fileSecretResolver resolves ${FILE:/path} to the file contents with trailing newlines removed.
+// Relative paths are resolved against the config.yaml directory.
+type fileSecretResolver struct{}
+
+func (fileSecretResolver) Scheme() string { return "FILE" }
+
+func (fileSecretResolver) Resolve(ref string, baseDir string) (string, error) {
+	path := ref
+	if strings.HasPrefix(path, "~/") {
+		if home, errHome := os.UserHomeDir(); errHome == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	if !filepath.IsAbs(path) && baseDir != "" {
+		path = filepath.Join(baseDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// keychainSecretResolver resolves ${KEYCHAIN:service} or ${KEYCHAIN:service/account}
+// from the macOS login keychain using the security(1) tool.
+type keychainSecretResolver struct{}
+
+func (keychainSecretResolver) Scheme() string { return "KEYCHAIN" }
+
+func (keychainSecretResolver) Resolve(ref string, _ string) (string, error) {
+	if runtime.GOOS != "darwin" {
+		return "", fmt.Errorf("keychain references are only supported on macOS")
+	}
+	service, account, _ := strings.Cut(ref, "/")
+	args := []string{"find-generic-password", "-w", "-s", service}
+	if account != "" {
+		args = append(args, "-a", account)
+	}
+	out, err := exec.Command("security", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("keychain lookup failed for service %q: %w", service, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// errNoSecretReference reports that a scalar contains no ${...} placeholder.
+var errNoSecretReference = errors.New("no secret reference")
+
+// interpolateSecretString expands every ${SCHEME:ref} placeholder in value.
+// "$${" escapes a literal "${".
+func interpolateSecretString(value string, baseDir string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, errNoSecretReference
+	}
+	var b strings.Builder
+	found := false
+	for i := 0; i < len(value); {
+		if strings.HasPrefix(value[i:], "$${") {
+			b.WriteString("${")
+			i += 3
+			found = true
+			continue
+		}
+		if !strings.HasPrefix(value[i:], "${") {
+			b.WriteByte(value[i])
+			i++
+			continue
+		}
+		end := strings.IndexByte(value[i+2:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated secret reference")
+		}
+		body := value[i+2 : i+2+end]
+		scheme, ref, ok := strings.Cut(body, ":")
+		scheme = strings.TrimSpace(scheme)
+		ref = strings.TrimSpace(ref)
+		if !ok || scheme == "" || ref == "" {
+			return "", fmt.Errorf("malformed secret reference ${%s}", body)
+		}
+		resolver := lookupSecretResolver(scheme)
+		if resolver == nil {
+			return "", fmt.Errorf("unknown secret scheme %q", scheme)
+		}
+		resolved, err := resolver.Resolve(ref, baseDir)
+		if err != nil {
+			return "", fmt.Errorf("${%s:%s}: %w", strings.ToUpper(scheme), ref, err)
+		}
+		b.WriteString(resolved)
+		found = true
+		i += 2 + end + 1
+	}
+	if !found {
+		return value, errNoSecretReference
+	}
+	return b.String(), nil
+}
+
+// secretReference records a config value that was loaded from a ${...} template.
+type secretReference struct {
+	template string
+	resolved string
+}
+
+// secretFieldNames lists the YAML keys whose values may hold ${...} secret references. Values
+// of every other key, and of keys outside a headers map, are kept literally.
+var secretFieldNames = map[string]struct{}{
+	"api-key":              {},
+	"api-keys":             {},
+	"key":                  {},
+	"secret":               {},
+	"secret-key":           {},
+	"client-key":           {},
+	"dsn":                  {},
+	"proxy-url":            {},
+	"url":                  {},
+	"base-url":             {},
+	"upstream-url":         {},
+	"upstream-api-key":     {},
+	"upstream-api-keys":    {},
+	"amp-upstream-url":     {},
+	"amp-upstream-api-key": {},
+	"members":              {},
+}
+
+// isSecretFieldPath reports whether the scalar at path belongs to a secret-bearing field.
+func isSecretFieldPath(path string) bool {
+	segments := strings.Split(path, ".")
+	last := segments[len(segments)-1]
+	if idx := strings.IndexByte(last, '['); idx >= 0 {
+		last = last[:idx]
+	}
+	if _, ok := secretFieldNames[strings.ToLower(last)]; ok {
+		return true
+	}
+	return len(segments) > 1 && strings.EqualFold(segments[len(segments)-2], "headers")
+}
+
+// interpolateSecretNodes resolves placeholders in the secret-bearing scalar nodes of the tree
+// in place. It returns the references keyed by YAML path.
+func interpolateSecretNodes(root *yaml.Node, baseDir string) (map[string]secretReference, error) {
+	refs := make(map[string]secretReference)
+	var walk func(node *yaml.Node, path string) error
+	walk = func(node *yaml.Node, path string) error {
+		if node == nil {
+			return nil
+		}
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				if err := walk(child, path); err != nil {
+					return err
+				}
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if err := walk(node.Content[i+1], joinValidationPath(path, node.Content[i].Value)); err != nil {
+					return err
+				}
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				if err := walk(child, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		case yaml.ScalarNode:
+			if !isSecretFieldPath(path) {
+				return nil
+			}
+			resolved, err := interpolateSecretString(node.Value, baseDir)
+			if errors.Is(err, errNoSecretReference) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to resolve secret reference at %s: %w", path, err)
+			}
+			refs[path] = secretReference{template: node.Value, resolved: resolved}
+			node.Value = resolved
+			if node.Style == 0 {
+				// Let plain scalars re-resolve their type from the resolved value.
+				node.Tag = ""
+			}
+		}
+		return nil
+	}
+	if err := walk(root, ""); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// setSecretReferences stores the references collected while loading cfg.
+func (cfg *Config) setSecretReferences(refs map[string]secretReference) {
+	cfg.secretRefPaths = refs
+}
+
+// addSecretAlias maps a derived value (e.g. a hashed secret) back to the template at path.
+func (cfg *Config) addSecretAlias(path, derived string) {
+	ref, ok := cfg.secretRefPaths[path]
+	if !ok || derived == "" {
+		return
+	}
+	cfg.secretRefPaths[path] = secretReference{template: ref.template, resolved: derived}
+}
+
+// restoreSecretReferences replaces resolved secret values in a marshalled node tree with
+// their original ${...} templates so that persisting the config never writes plaintext secrets.
+// Values are matched by path, so unrelated fields holding the same string are written as-is.
+func (cfg *Config) restoreSecretReferences(node *yaml.Node, path string) {
+	if cfg == nil || node == nil || len(cfg.secretRefPaths) == 0 {
+		return
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			cfg.restoreSecretReferences(child, path)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			cfg.restoreSecretReferences(node.Content[i+1], joinValidationPath(path, node.Content[i].Value))
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			cfg.restoreSecretReferences(child, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case yaml.ScalarNode:
+		ref, ok := cfg.secretRefPaths[path]
+		if !ok || ref.resolved != node.Value {
+			return
+		}
+		node.Value = ref.template
+		node.Tag = "!!str"
+		node.Style = yaml.DoubleQuotedStyle
+	}
+}
+
+// MaskSecretValues returns a copy of v (a decoded JSON value located at the YAML path path, ""
+// for the whole config) in which every value loaded from a ${...} reference is replaced by
+// that reference. Values are matched by path, so unrelated fields holding the same string are
+// left untouched.
+func (cfg *Config) MaskSecretValues(path string, v any) any {
+	if cfg == nil || len(cfg.secretRefPaths) == 0 {
+		return v
+	}
+	switch typed := v.(type) {
+	case string:
+		if ref, ok := cfg.secretRefPaths[path]; ok && ref.resolved == typed {
+			return ref.template
+		}
+		return typed
+	case []any:
+		out := make([]any, len(typed))
+		for i := range typed {
+			out[i] = cfg.MaskSecretValues(fmt.Sprintf("%s[%d]", path, i), typed[i])
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(typed))
+		for k, item := range typed {
+			out[k] = cfg.MaskSecretValues(joinValidationPath(path, k), item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// HasSecretReferences reports whether any config value was loaded from a ${...} reference.
+func (cfg *Config) HasSecretReferences() bool {
+	return cfg != nil && len(cfg.secretRefPaths) > 0
+}
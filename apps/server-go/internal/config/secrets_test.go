@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_ResolvesSecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "upstream.key"), []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatalf("write secret file: %v", err)
+	}
+	t.Setenv("CLIPROXY_TEST_KEY", "env-secret")
+	t.Setenv("CLIPROXY_TEST_PROXY", "socks5://proxy:1080")
+
+	configFile := filepath.Join(dir, "config.yaml")
+	raw := "host: \"${ENV:CLIPROXY_TEST_KEY}\"\nproxy-url: ${ENV:CLIPROXY_TEST_PROXY}\napi-keys:\n  - \"${ENV:CLIPROXY_TEST_KEY}\"\n  - \"${FILE:upstream.key}\"\n  - \"literal-$${ENV:NOPE}\"\n"
+	if err := os.WriteFile(configFile, []byte(raw), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.ProxyURL != "socks5://proxy:1080" {
+		t.Fatalf("expected resolved proxy-url, got %q", cfg.ProxyURL)
+	}
+	if cfg.Host != "${ENV:CLIPROXY_TEST_KEY}" {
+		t.Fatalf("non-secret field was interpolated: host = %q", cfg.Host)
+	}
+	want := []string{"env-secret", "file-secret", "literal-${ENV:NOPE}"}
+	if len(cfg.APIKeys) != len(want) {
+		t.Fatalf("expected %d api keys, got %#v", len(want), cfg.APIKeys)
+	}
+	for i := range want {
+		if cfg.APIKeys[i] != want[i] {
+			t.Fatalf("api-keys[%d] = %q, want %q", i, cfg.APIKeys[i], want[i])
+		}
+	}
+}
+
+func TestLoadConfig_UnresolvedSecretReferenceFails(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("api-keys:\n  - \"${ENV:CLIPROXY_TEST_MISSING}\"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := LoadConfig(configFile); err == nil || !strings.Contains(err.Error(), "CLIPROXY_TEST_MISSING") {
+		t.Fatalf("expected unresolved reference error, got %v", err)
+	}
+	cfg, err := LoadConfigOptional(configFile, true)
+	if err != nil || cfg == nil {
+		t.Fatalf("LoadConfigOptional(optional) = %v, %v; want empty config", cfg, err)
+	}
+	issues := ValidateConfigData([]byte("api-keys:\n  - \"${VAULT:x}\"\n"), dir)
+	if !HasValidationErrors(issues) {
+		t.Fatalf("expected validation error for unknown scheme, got %#v", issues)
+	}
+}
+
+func TestSaveConfigPreserveComments_KeepsSecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLIPROXY_TEST_KEY", "env-secret")
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("api-keys:\n  - \"${ENV:CLIPROXY_TEST_KEY}\"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	cfg.APIKeys = append(cfg.APIKeys, "plain-key")
+	cfg.Host = "env-secret"
+	if err = SaveConfigPreserveComments(configFile, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments() error = %v", err)
+	}
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if strings.Contains(string(data), "- env-secret") || strings.Contains(string(data), "- \"env-secret\"") {
+		t.Fatalf("resolved secret was persisted:\n%s", data)
+	}
+	if !strings.Contains(string(data), "${ENV:CLIPROXY_TEST_KEY}") || !strings.Contains(string(data), "plain-key") {
+		t.Fatalf("expected reference and new key to be persisted:\n%s", data)
+	}
+	if !strings.Contains(string(data), "host: env-secret") || strings.Count(string(data), "${ENV:CLIPROXY_TEST_KEY}") != 1 {
+		t.Fatalf("unrelated field with the same value was rewritten to the reference:\n%s", data)
+	}
+
+	masked, ok := cfg.MaskSecretValues("", map[string]any{"api-keys": []any{"env-secret", "plain-key"}, "host": "env-secret"}).(map[string]any)
+	if !ok {
+		t.Fatalf("expected masked map")
+	}
+	keys := masked["api-keys"].([]any)
+	if keys[0] != "${ENV:CLIPROXY_TEST_KEY}" || keys[1] != "plain-key" {
+		t.Fatalf("unexpected masked keys %#v", keys)
+	}
+	if masked["host"] != "env-secret" {
+		t.Fatalf("unrelated field with the same value was masked: %#v", masked["host"])
+	}
+	if got := cfg.MaskSecretValues("api-keys", []any{"env-secret"}).([]any); got[0] != "${ENV:CLIPROXY_TEST_KEY}" {
+		t.Fatalf("unexpected masked list %#v", got)
+	}
+}
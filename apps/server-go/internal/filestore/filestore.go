@@ -0,0 +1,322 @@
+// Package filestore keeps uploaded files and their metadata in a local directory. Every file
+// belongs to an owner (normally a hashed client key), may expire, and is subject to per-file
+// size limits and per-owner quotas. Expired files are removed lazily on each operation, the same
+// way the in-memory response stores drop stale entries.
+package filestore
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for unknown, expired or foreign files.
+	ErrNotFound = errors.New("file not found")
+	// ErrTooLarge is returned when a file exceeds Limits.MaxBytes.
+	ErrTooLarge = errors.New("file exceeds the size limit")
+	// ErrQuotaExceeded is returned when a file would push its owner over Limits.QuotaBytes.
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// Limits bounds what a single Put may store.
+type Limits struct {
+	// MaxBytes caps the size of one file. <= 0 means unlimited.
+	MaxBytes int64
+	// QuotaBytes caps the total size of one owner's files. <= 0 means unlimited.
+	QuotaBytes int64
+	// TTL sets ExpiresAt for files stored without one. <= 0 keeps them until deleted.
+	TTL time.Duration
+}
+
+// Meta describes a stored file.
+type Meta struct {
+	ID         string            `json:"id"`
+	Owner      string            `json:"owner"`
+	Name       string            `json:"name,omitempty"`
+	MIMEType   string            `json:"mime_type,omitempty"`
+	Purpose    string            `json:"purpose,omitempty"`
+	Size       int64             `json:"size"`
+	SHA256     string            `json:"sha256"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	ExpiresAt  time.Time         `json:"expires_at,omitzero"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Expired reports whether the file has passed its expiry at now.
+func (m Meta) Expired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt)
+}
+
+// Store is a directory of files with a JSON metadata sidecar each, indexed in memory.
+type Store struct {
+	dir string
+
+	mu    sync.Mutex
+	index map[string]Meta
+}
+
+var (
+	storesMu sync.Mutex
+	stores   = make(map[string]*Store)
+)
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// Open returns the store rooted at dir, creating the directory and loading its index on first use.
+// Stores are shared per directory so handlers can reopen them on every request.
+func Open(dir string) (*Store, error) {
+	dir = filepath.Clean(strings.TrimSpace(dir))
+	if dir == "" || dir == "." {
+		return nil, errors.New("filestore: directory is required")
+	}
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	if store, ok := stores[dir]; ok {
+		return store, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("filestore: create %s: %w", dir, err)
+	}
+	store := &Store{dir: dir, index: make(map[string]Meta)}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: read %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		raw, errRead := os.ReadFile(filepath.Join(dir, name))
+		if errRead != nil {
+			continue
+		}
+		var meta Meta
+		if json.Unmarshal(raw, &meta) != nil || !validID.MatchString(meta.ID) {
+			continue
+		}
+		store.index[meta.ID] = meta
+	}
+	stores[dir] = store
+	return store, nil
+}
+
+// OwnerKey derives the owner of files uploaded with a client key without keeping the key itself.
+func OwnerKey(principal string) string {
+	if principal == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(principal))
+	return hex.EncodeToString(sum[:12])
+}
+
+// NewID returns a random identifier with the given prefix.
+func NewID(prefix string) string {
+	buf := make([]byte, 12)
+	_, _ = rand.Read(buf)
+	return prefix + hex.EncodeToString(buf)
+}
+
+// Dir returns the directory the store writes to.
+func (s *Store) Dir() string { return s.dir }
+
+// Put stores the content of r under meta.ID (generated when empty) and returns the saved metadata.
+func (s *Store) Put(meta Meta, r io.Reader, limits Limits) (Meta, error) {
+	if meta.ID == "" {
+		meta.ID = NewID("")
+	}
+	if !validID.MatchString(meta.ID) {
+		return Meta{}, fmt.Errorf("filestore: invalid id %q", meta.ID)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return Meta{}, fmt.Errorf("filestore: create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	hash := sha256.New()
+	reader := r
+	if limits.MaxBytes > 0 {
+		reader = io.LimitReader(r, limits.MaxBytes+1)
+	}
+	size, err := io.Copy(io.MultiWriter(tmp, hash), reader)
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return Meta{}, fmt.Errorf("filestore: write %s: %w", meta.ID, err)
+	}
+	if limits.MaxBytes > 0 && size > limits.MaxBytes {
+		return Meta{}, ErrTooLarge
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	s.cleanupLocked(now)
+	if limits.QuotaBytes > 0 && s.usageLocked(meta.Owner)+size > limits.QuotaBytes {
+		return Meta{}, ErrQuotaExceeded
+	}
+	meta.Size = size
+	meta.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	meta.CreatedAt, meta.UpdatedAt = now, now
+	if meta.ExpiresAt.IsZero() && limits.TTL > 0 {
+		meta.ExpiresAt = now.Add(limits.TTL)
+	}
+	if err = os.Rename(tmp.Name(), s.dataPath(meta.ID)); err != nil {
+		return Meta{}, fmt.Errorf("filestore: store %s: %w", meta.ID, err)
+	}
+	if err = s.writeMetaLocked(meta); err != nil {
+		_ = os.Remove(s.dataPath(meta.ID))
+		return Meta{}, err
+	}
+	s.index[meta.ID] = meta
+	return meta, nil
+}
+
+// Get returns the metadata of an owner's file.
+func (s *Store) Get(owner, id string) (Meta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cleanupLocked(time.Now().UTC())
+	return s.lookupLocked(owner, id)
+}
+
+// ReadAll returns the metadata and content of an owner's file.
+func (s *Store) ReadAll(owner, id string) (Meta, []byte, error) {
+	meta, err := s.Get(owner, id)
+	if err != nil {
+		return Meta{}, nil, err
+	}
+	data, err := os.ReadFile(s.dataPath(meta.ID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Meta{}, nil, ErrNotFound
+		}
+		return Meta{}, nil, fmt.Errorf("filestore: read %s: %w", meta.ID, err)
+	}
+	return meta, data, nil
+}
+
+// Update applies fn to an owner's file metadata and persists the result. ID and Owner cannot change.
+func (s *Store) Update(owner, id string, fn func(*Meta)) (Meta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cleanupLocked(time.Now().UTC())
+	meta, err := s.lookupLocked(owner, id)
+	if err != nil {
+		return Meta{}, err
+	}
+	fn(&meta)
+	meta.ID, meta.Owner = id, owner
+	meta.UpdatedAt = time.Now().UTC()
+	if err = s.writeMetaLocked(meta); err != nil {
+		return Meta{}, err
+	}
+	s.index[id] = meta
+	return meta, nil
+}
+
+// List returns an owner's files, oldest first.
+func (s *Store) List(owner string) []Meta {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cleanupLocked(time.Now().UTC())
+	out := make([]Meta, 0)
+	for _, meta := range s.index {
+		if meta.Owner == owner {
+			out = append(out, meta)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Delete removes an owner's file.
+func (s *Store) Delete(owner, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cleanupLocked(time.Now().UTC())
+	if _, err := s.lookupLocked(owner, id); err != nil {
+		return err
+	}
+	s.removeLocked(id)
+	return nil
+}
+
+// Usage returns the total size of an owner's files.
+func (s *Store) Usage(owner string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cleanupLocked(time.Now().UTC())
+	return s.usageLocked(owner)
+}
+
+func (s *Store) lookupLocked(owner, id string) (Meta, error) {
+	meta, ok := s.index[id]
+	if !ok || meta.Owner != owner {
+		return Meta{}, ErrNotFound
+	}
+	return meta, nil
+}
+
+func (s *Store) usageLocked(owner string) int64 {
+	var total int64
+	for _, meta := range s.index {
+		if meta.Owner == owner {
+			total += meta.Size
+		}
+	}
+	return total
+}
+
+func (s *Store) cleanupLocked(now time.Time) {
+	for id, meta := range s.index {
+		if meta.Expired(now) {
+			s.removeLocked(id)
+		}
+	}
+}
+
+func (s *Store) removeLocked(id string) {
+	delete(s.index, id)
+	_ = os.Remove(s.dataPath(id))
+	_ = os.Remove(s.metaPath(id))
+}
+
+func (s *Store) writeMetaLocked(meta Meta) error {
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("filestore: encode %s: %w", meta.ID, err)
+	}
+	tmp := s.metaPath(meta.ID) + ".tmp"
+	if err = os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("filestore: write %s: %w", meta.ID, err)
+	}
+	if err = os.Rename(tmp, s.metaPath(meta.ID)); err != nil {
+		return fmt.Errorf("filestore: write %s: %w", meta.ID, err)
+	}
+	return nil
+}
+
+func (s *Store) dataPath(id string) string { return filepath.Join(s.dir, id+".data") }
+
+func (s *Store) metaPath(id string) string { return filepath.Join(s.dir, id+".json") }
@@ -0,0 +1,77 @@
+package filestore
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPutReadListDelete(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	owner := OwnerKey("client-key")
+	meta, err := store.Put(Meta{Owner: owner, Name: "notes.txt", MIMEType: "text/plain"}, strings.NewReader("hello"), Limits{TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if meta.Size != 5 || meta.ExpiresAt.IsZero() || meta.SHA256 == "" {
+		t.Fatalf("meta = %+v", meta)
+	}
+
+	if _, data, errRead := store.ReadAll(owner, meta.ID); errRead != nil || string(data) != "hello" {
+		t.Fatalf("ReadAll() = %q, %v", data, errRead)
+	}
+	if _, errGet := store.Get(OwnerKey("other-key"), meta.ID); !errors.Is(errGet, ErrNotFound) {
+		t.Fatalf("foreign Get() error = %v, want ErrNotFound", errGet)
+	}
+
+	delete(stores, dir)
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	if list := reopened.List(owner); len(list) != 1 || list[0].Name != "notes.txt" {
+		t.Fatalf("List() after reopen = %+v", list)
+	}
+	if err = reopened.Delete(owner, meta.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, _, err = reopened.ReadAll(owner, meta.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ReadAll() after delete error = %v", err)
+	}
+}
+
+func TestLimitsAndExpiry(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	owner := OwnerKey("client-key")
+	if _, err = store.Put(Meta{Owner: owner}, strings.NewReader("too long"), Limits{MaxBytes: 4}); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("oversized Put() error = %v", err)
+	}
+	if _, err = store.Put(Meta{Owner: owner}, strings.NewReader("1234"), Limits{QuotaBytes: 6}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err = store.Put(Meta{Owner: owner}, strings.NewReader("567"), Limits{QuotaBytes: 6}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("over-quota Put() error = %v", err)
+	}
+	if _, err = store.Put(Meta{Owner: OwnerKey("other")}, strings.NewReader("567"), Limits{QuotaBytes: 6}); err != nil {
+		t.Fatalf("quota leaked across owners: %v", err)
+	}
+
+	expiring, err := store.Put(Meta{Owner: owner, ExpiresAt: time.Now().Add(-time.Second)}, strings.NewReader("x"), Limits{})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err = store.Get(owner, expiring.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired Get() error = %v", err)
+	}
+	if usage := store.Usage(owner); usage != 4 {
+		t.Fatalf("Usage() = %d, want 4", usage)
+	}
+}
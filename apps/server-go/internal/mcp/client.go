@@ -0,0 +1,236 @@
+// Package mcp implements a minimal Model Context Protocol client used to execute MCP tools on
+// behalf of model requests. It speaks JSON-RPC 2.0 over the streamable HTTP transport and over
+// the stdio transport of a locally launched server process.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ProtocolVersion is the MCP revision announced during initialization.
+const ProtocolVersion = "2025-06-18"
+
+const maxListToolsPages = 32
+
+// Tool describes one tool advertised by an MCP server.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+	Annotations json.RawMessage `json:"annotations,omitempty"`
+}
+
+// ReadOnly reports whether the server marked the tool as free of side effects.
+func (t Tool) ReadOnly() bool {
+	if len(t.Annotations) == 0 {
+		return false
+	}
+	var annotations struct {
+		ReadOnlyHint bool `json:"readOnlyHint"`
+	}
+	if err := json.Unmarshal(t.Annotations, &annotations); err != nil {
+		return false
+	}
+	return annotations.ReadOnlyHint
+}
+
+// Content is one item of a tool call result.
+type Content struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	MimeType string          `json:"mimeType,omitempty"`
+	Data     string          `json:"data,omitempty"`
+	Resource json.RawMessage `json:"resource,omitempty"`
+}
+
+// CallResult is the result of a tools/call request.
+type CallResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+// Text flattens the result into the string handed back to the model. Text items are joined by
+// newlines; other items are included as JSON.
+func (r CallResult) Text() string {
+	parts := make([]string, 0, len(r.Content))
+	for _, item := range r.Content {
+		if item.Type == "text" {
+			parts = append(parts, item.Text)
+			continue
+		}
+		raw, err := json.Marshal(item)
+		if err == nil {
+			parts = append(parts, string(raw))
+		}
+	}
+	if len(parts) == 0 && len(r.StructuredContent) > 0 {
+		return string(r.StructuredContent)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// RPCError is a JSON-RPC error returned by the server.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      *int64 `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// transport delivers one JSON-RPC message and, for requests, returns the matching response.
+type transport interface {
+	roundTrip(ctx context.Context, id int64, message []byte) (*rpcResponse, error)
+	notify(ctx context.Context, message []byte) error
+	close() error
+}
+
+// Client is a connection to one MCP server. It is safe for concurrent use.
+type Client struct {
+	transport transport
+	nextID    atomic.Int64
+
+	initOnce sync.Once
+	initErr  error
+}
+
+func newClient(t transport) *Client {
+	return &Client{transport: t}
+}
+
+// Close releases the underlying transport.
+func (c *Client) Close() error {
+	if c == nil || c.transport == nil {
+		return nil
+	}
+	return c.transport.close()
+}
+
+// ListTools returns every tool the server advertises, following pagination cursors.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	if err := c.initialize(ctx); err != nil {
+		return nil, err
+	}
+	var tools []Tool
+	cursor := ""
+	for page := 0; page < maxListToolsPages; page++ {
+		var params any
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		var result struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+	return tools, nil
+}
+
+// CallTool invokes a tool. arguments must be a JSON object or empty.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (CallResult, error) {
+	if err := c.initialize(ctx); err != nil {
+		return CallResult{}, err
+	}
+	if len(strings.TrimSpace(string(arguments))) == 0 {
+		arguments = json.RawMessage(`{}`)
+	}
+	var result CallResult
+	err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": arguments}, &result)
+	return result, err
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	c.initOnce.Do(func() {
+		params := map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{},
+			"clientInfo":      map[string]string{"name": "cli-proxy-api", "version": "1"},
+		}
+		var result json.RawMessage
+		if err := c.call(ctx, "initialize", params, &result); err != nil {
+			c.initErr = fmt.Errorf("mcp initialize: %w", err)
+			return
+		}
+		message, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: "notifications/initialized"})
+		if err != nil {
+			c.initErr = err
+			return
+		}
+		if err = c.transport.notify(ctx, message); err != nil {
+			c.initErr = fmt.Errorf("mcp initialized notification: %w", err)
+		}
+	})
+	return c.initErr
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, out any) error {
+	id := c.nextID.Add(1)
+	message, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	resp, err := c.transport.roundTrip(ctx, id, message)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(resp.Result, out); err != nil {
+		return fmt.Errorf("mcp %s: decode result: %w", method, err)
+	}
+	return nil
+}
+
+// matchesID reports whether a raw JSON-RPC id equals id.
+func matchesID(raw json.RawMessage, id int64) bool {
+	if len(raw) == 0 {
+		return false
+	}
+	var number int64
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return number == id
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text == fmt.Sprint(id)
+	}
+	return false
+}
+
+var errClosed = errors.New("mcp: transport closed")
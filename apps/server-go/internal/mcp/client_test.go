@@ -0,0 +1,113 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPClientListAndCallTools(t *testing.T) {
+	var sawSession, sawAuth bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Cursor string `json:"cursor"`
+			} `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		if r.Header.Get("Authorization") == "Bearer secret" {
+			sawAuth = true
+		}
+		if msg.Method == "initialize" {
+			w.Header().Set("Mcp-Session-Id", "session-1")
+		} else if r.Header.Get("Mcp-Session-Id") == "session-1" {
+			sawSession = true
+		}
+		if len(msg.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var result string
+		switch {
+		case msg.Method == "initialize":
+			result = `{"protocolVersion":"2025-06-18","capabilities":{}}`
+		case msg.Method == "tools/list" && msg.Params.Cursor == "":
+			result = `{"tools":[{"name":"a"}],"nextCursor":"page2"}`
+		case msg.Method == "tools/list":
+			result = `{"tools":[{"name":"b","annotations":{"readOnlyHint":true}}]}`
+		case msg.Method == "tools/call":
+			// Answer over SSE, preceded by an unrelated notification.
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			_, _ = fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":%s,\"result\":{\"content\":[{\"type\":\"text\",\"text\":\"one\"},{\"type\":\"text\",\"text\":\"two\"}]}}\n\n", msg.ID)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%s}`, msg.ID, result)
+	}))
+	defer server.Close()
+
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer secret")
+	client := NewHTTPClient(server.URL, headers, nil)
+	defer func() { _ = client.Close() }()
+
+	tools, err := client.ListTools(context.Background())
+	if err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+	if len(tools) != 2 || tools[0].Name != "a" || tools[1].Name != "b" {
+		t.Fatalf("tools = %+v", tools)
+	}
+	if tools[0].ReadOnly() || !tools[1].ReadOnly() {
+		t.Fatalf("read-only hints not decoded: %+v", tools)
+	}
+
+	result, err := client.CallTool(context.Background(), "a", nil)
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if got := result.Text(); got != "one\ntwo" {
+		t.Fatalf("Text() = %q", got)
+	}
+	if !sawSession || !sawAuth {
+		t.Fatalf("session header seen = %v, auth header seen = %v", sawSession, sawAuth)
+	}
+}
+
+func TestHTTPClientReturnsRPCError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		if len(msg.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if msg.Method == "initialize" {
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{}}`, msg.ID)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32602,"message":"unknown tool"}}`, msg.ID)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL, nil, nil)
+	_, err := client.CallTool(context.Background(), "missing", json.RawMessage(`{}`))
+	rpcErr, ok := err.(*RPCError)
+	if !ok || rpcErr.Code != -32602 {
+		t.Fatalf("err = %v, want RPCError -32602", err)
+	}
+}
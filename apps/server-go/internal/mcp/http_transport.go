@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const maxHTTPErrorBody = 4 << 10
+
+// httpTransport implements the streamable HTTP transport: every message is POSTed to a single
+// endpoint and the server answers with either a JSON body or an SSE stream.
+type httpTransport struct {
+	endpoint string
+	headers  http.Header
+	client   *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+// NewHTTPClient returns a client for a streamable HTTP MCP server at endpoint. headers are sent
+// with every request, for example an Authorization header.
+func NewHTTPClient(endpoint string, headers http.Header, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return newClient(&httpTransport{endpoint: endpoint, headers: headers.Clone(), client: httpClient})
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, id int64, message []byte) (*rpcResponse, error) {
+	resp, err := t.post(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return readSSEResponse(resp.Body, id)
+	}
+	var decoded rpcResponse
+	if err = json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("mcp: decode response: %w", err)
+	}
+	return &decoded, nil
+}
+
+func (t *httpTransport) notify(ctx context.Context, message []byte) error {
+	resp, err := t.post(ctx, message)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// close ends the server-side session when the server assigned one.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, t.endpoint, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req, sessionID)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (t *httpTransport) post(ctx context.Context, message []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(message))
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	t.setHeaders(req, sessionID)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("mcp: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxHTTPErrorBody))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("mcp: server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if id := strings.TrimSpace(resp.Header.Get("Mcp-Session-Id")); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) setHeaders(req *http.Request, sessionID string) {
+	for key, values := range t.headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("MCP-Protocol-Version", ProtocolVersion)
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+}
+
+// readSSEResponse reads events until the response to id arrives. Server requests and
+// notifications interleaved on the stream are skipped.
+func readSSEResponse(body io.Reader, id int64) (*rpcResponse, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	var data strings.Builder
+	flush := func() (*rpcResponse, bool) {
+		if data.Len() == 0 {
+			return nil, false
+		}
+		payload := data.String()
+		data.Reset()
+		var decoded rpcResponse
+		if err := json.Unmarshal([]byte(payload), &decoded); err != nil {
+			return nil, false
+		}
+		if decoded.Method != "" || !matchesID(decoded.ID, id) {
+			return nil, false
+		}
+		return &decoded, true
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if resp, ok := flush(); ok {
+				return resp, nil
+			}
+			continue
+		}
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+		}
+	}
+	if resp, ok := flush(); ok {
+		return resp, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("mcp: read event stream: %w", err)
+	}
+	return nil, fmt.Errorf("mcp: event stream ended without a response to request %d", id)
+}
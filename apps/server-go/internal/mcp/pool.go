@@ -0,0 +1,80 @@
+package mcp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StdioPool keeps one long-lived stdio client per server label so each request does not pay for
+// a process start and an initialize handshake.
+type StdioPool struct {
+	mu      sync.Mutex
+	clients map[string]pooledStdioClient
+}
+
+type pooledStdioClient struct {
+	signature string
+	client    *Client
+}
+
+// NewStdioPool returns an empty pool.
+func NewStdioPool() *StdioPool {
+	return &StdioPool{clients: make(map[string]pooledStdioClient)}
+}
+
+// Get returns the client for label, starting the server when it is not running or when its
+// command line changed since it was started.
+func (p *StdioPool) Get(label, command string, args []string, env map[string]string) (*Client, error) {
+	signature := stdioSignature(command, args, env)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if existing, ok := p.clients[label]; ok {
+		if existing.signature == signature {
+			return existing.client, nil
+		}
+		_ = existing.client.Close()
+		delete(p.clients, label)
+	}
+	client, err := NewStdioClient(command, args, env)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[label] = pooledStdioClient{signature: signature, client: client}
+	return client, nil
+}
+
+// Discard stops the server for label if client is still the pooled instance. Callers use it
+// after a transport failure so the next request starts a fresh process.
+func (p *StdioPool) Discard(label string, client *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if existing, ok := p.clients[label]; ok && existing.client == client {
+		_ = existing.client.Close()
+		delete(p.clients, label)
+	}
+}
+
+// Close stops every pooled server.
+func (p *StdioPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for label, existing := range p.clients {
+		_ = existing.client.Close()
+		delete(p.clients, label)
+	}
+}
+
+func stdioSignature(command string, args []string, env map[string]string) string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+env[key])
+	}
+	return fmt.Sprintf("%s\x00%s\x00%s", command, strings.Join(args, "\x00"), strings.Join(pairs, "\x00"))
+}
@@ -0,0 +1,145 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// stdioTransport exchanges newline-delimited JSON-RPC messages with a child process.
+type stdioTransport struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[int64]chan *rpcResponse
+	done    chan struct{}
+	err     error
+}
+
+// NewStdioClient launches command with args and returns a client talking to it over stdin and
+// stdout. env entries are added to the current environment. The process is stopped by Close.
+func NewStdioClient(command string, args []string, env map[string]string) (*Client, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = io.Discard
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("mcp: start %s: %w", command, err)
+	}
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan *rpcResponse),
+		done:    make(chan struct{}),
+	}
+	go t.readLoop(stdout)
+	return newClient(t), nil
+}
+
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var decoded rpcResponse
+		if err := json.Unmarshal(scanner.Bytes(), &decoded); err != nil {
+			log.Debugf("mcp stdio: skipping malformed line: %v", err)
+			continue
+		}
+		if decoded.Method != "" {
+			continue
+		}
+		var id int64
+		if err := json.Unmarshal(decoded.ID, &id); err != nil {
+			continue
+		}
+		t.mu.Lock()
+		ch, ok := t.pending[id]
+		delete(t.pending, id)
+		t.mu.Unlock()
+		if ok {
+			ch <- &decoded
+		}
+	}
+	t.mu.Lock()
+	t.err = scanner.Err()
+	if t.err == nil {
+		t.err = errClosed
+	}
+	t.mu.Unlock()
+	close(t.done)
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, id int64, message []byte) (*rpcResponse, error) {
+	ch := make(chan *rpcResponse, 1)
+	t.mu.Lock()
+	if t.err != nil {
+		err := t.err
+		t.mu.Unlock()
+		return nil, err
+	}
+	t.pending[id] = ch
+	t.mu.Unlock()
+
+	if err := t.write(message); err != nil {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+		return nil, err
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-t.done:
+		t.mu.Lock()
+		err := t.err
+		t.mu.Unlock()
+		return nil, err
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(_ context.Context, message []byte) error {
+	return t.write(message)
+}
+
+func (t *stdioTransport) write(message []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(append(message, '\n')); err != nil {
+		return fmt.Errorf("mcp stdio: write: %w", err)
+	}
+	return nil
+}
+
+func (t *stdioTransport) close() error {
+	_ = t.stdin.Close()
+	if t.cmd.Process != nil {
+		_ = t.cmd.Process.Kill()
+	}
+	_ = t.cmd.Wait()
+	return nil
+}
@@ -0,0 +1,465 @@
+// Package notify raises alerts about credential and quota incidents and delivers them to webhooks
+// and local commands. It observes the auth manager through coreauth.Hook: status transitions and
+// refresh failures of credentials, models left without any credential outside cooldown, and
+// credentials running low on forecast quota.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDedupWindow = 10 * time.Minute
+	defaultRateLimit   = 30
+	defaultMaxRetries  = 3
+	defaultTimeout     = 10 * time.Second
+	maxRetryDelay      = 30 * time.Second
+)
+
+// Headers sent with webhook deliveries.
+const (
+	EventHeader     = "X-Cliproxy-Event"
+	TimestampHeader = "X-Cliproxy-Timestamp"
+	SignatureHeader = "X-Cliproxy-Signature"
+)
+
+// retryBaseDelay is the delay before the first webhook retry; it doubles for each further retry.
+var retryBaseDelay = time.Second
+
+// Event is one alert, delivered as the JSON body of webhooks and the standard input of commands.
+type Event struct {
+	Kind     string    `json:"kind"`
+	Time     time.Time `json:"time"`
+	AuthID   string    `json:"auth_id,omitempty"`
+	Provider string    `json:"provider,omitempty"`
+	Label    string    `json:"label,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	// From and Status carry the previous and new credential status of status events.
+	From       string `json:"from,omitempty"`
+	Status     string `json:"status,omitempty"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+	Message    string `json:"message,omitempty"`
+	// RemainingPercent is the forecast share of quota left of budget events.
+	RemainingPercent *float64 `json:"remaining_percent,omitempty"`
+}
+
+// Source gives the notifier access to the auth manager state behind hook callbacks.
+type Source interface {
+	GetByID(id string) (*coreauth.Auth, bool)
+	QuotaForecast(authID string) (coreauth.QuotaForecast, bool)
+}
+
+// authState is what the notifier remembers about a credential between callbacks.
+type authState struct {
+	status  coreauth.Status
+	message string
+	alerted bool
+	budget  bool
+}
+
+// Notifier detects incidents and delivers alerts. It implements coreauth.Hook and
+// coreauth.RefreshHook.
+type Notifier struct {
+	mu       sync.Mutex
+	cfg      config.NotificationsConfig
+	source   Source
+	auths    map[string]*authState
+	lastSent map[string]time.Time
+	sent     []time.Time
+	client   *http.Client
+	now      func() time.Time
+}
+
+// New returns a notifier with notifications disabled until Configure is called.
+func New() *Notifier {
+	return &Notifier{
+		auths:    make(map[string]*authState),
+		lastSent: make(map[string]time.Time),
+		client:   &http.Client{},
+		now:      time.Now,
+	}
+}
+
+var defaultNotifier = New()
+
+// Default returns the process-wide notifier.
+func Default() *Notifier { return defaultNotifier }
+
+// Configure replaces the configuration of the process-wide notifier.
+func Configure(cfg config.NotificationsConfig) { defaultNotifier.Configure(cfg) }
+
+// Configure replaces the notifier's configuration.
+func (n *Notifier) Configure(cfg config.NotificationsConfig) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.cfg = cfg
+}
+
+// Attach sets the auth manager consulted for credential state and quota forecasts.
+func (n *Notifier) Attach(source Source) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.source = source
+}
+
+// OnAuthRegistered implements coreauth.Hook. It records the credential's status without alerting.
+func (n *Notifier) OnAuthRegistered(_ context.Context, auth *coreauth.Auth) {
+	if auth == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.auths[auth.ID] = &authState{status: auth.Status, message: auth.StatusMessage}
+}
+
+// OnAuthUpdated implements coreauth.Hook.
+func (n *Notifier) OnAuthUpdated(_ context.Context, auth *coreauth.Auth) {
+	n.observeStatus(auth)
+}
+
+// OnResult implements coreauth.Hook.
+func (n *Notifier) OnResult(_ context.Context, result coreauth.Result) {
+	n.mu.Lock()
+	source := n.source
+	enabled := n.cfg.Enabled
+	n.mu.Unlock()
+	if !enabled || source == nil || result.AuthID == "" {
+		return
+	}
+	auth, ok := source.GetByID(result.AuthID)
+	if !ok {
+		return
+	}
+	n.observeStatus(auth)
+	if !result.Success && result.Model != "" {
+		n.observeCooldownStorm(auth, result)
+	}
+	if forecast, okForecast := source.QuotaForecast(result.AuthID); okForecast {
+		n.observeBudget(auth, forecast)
+	}
+}
+
+// OnRefreshFailed implements coreauth.RefreshHook.
+func (n *Notifier) OnRefreshFailed(_ context.Context, auth *coreauth.Auth, err error) {
+	if auth == nil || err == nil {
+		return
+	}
+	n.emit(Event{
+		Kind:     config.NotificationEventRefreshFailed,
+		AuthID:   auth.ID,
+		Provider: auth.Provider,
+		Label:    auth.Label,
+		Message:  err.Error(),
+	})
+}
+
+func (n *Notifier) observeStatus(auth *coreauth.Auth) {
+	if auth == nil {
+		return
+	}
+	n.mu.Lock()
+	if !n.cfg.Enabled {
+		n.mu.Unlock()
+		return
+	}
+	state := n.auths[auth.ID]
+	if state == nil {
+		state = &authState{status: coreauth.StatusActive}
+		n.auths[auth.ID] = state
+	}
+	previous := state.status
+	changed := previous != auth.Status || state.message != auth.StatusMessage
+	state.status, state.message = auth.Status, auth.StatusMessage
+	if !changed {
+		n.mu.Unlock()
+		return
+	}
+	var event *Event
+	switch {
+	case auth.Status == coreauth.StatusActive && state.alerted:
+		state.alerted = false
+		event = &Event{Kind: config.NotificationEventRecovered}
+	case n.alertedStatus(auth.Status) && !transientFailure(auth):
+		state.alerted = true
+		event = &Event{Kind: config.NotificationEventStatusChanged, Message: auth.StatusMessage}
+		if auth.LastError != nil {
+			event.HTTPStatus = auth.LastError.HTTPStatus
+			if event.Message == "" {
+				event.Message = auth.LastError.Message
+			}
+		}
+	}
+	n.mu.Unlock()
+	if event == nil {
+		return
+	}
+	event.AuthID, event.Provider, event.Label = auth.ID, auth.Provider, auth.Label
+	event.From, event.Status = string(previous), string(auth.Status)
+	n.emit(*event)
+}
+
+func (n *Notifier) alertedStatus(status coreauth.Status) bool {
+	if len(n.cfg.Statuses) == 0 {
+		return status == coreauth.StatusError || status == coreauth.StatusDisabled
+	}
+	for _, candidate := range n.cfg.Statuses {
+		if coreauth.Status(strings.TrimSpace(candidate)) == status {
+			return true
+		}
+	}
+	return false
+}
+
+// transientFailure reports an error status caused by a retryable upstream failure; those are
+// covered by cooldown storm alerts.
+func transientFailure(auth *coreauth.Auth) bool {
+	if auth.Status != coreauth.StatusError || auth.LastError == nil {
+		return false
+	}
+	switch status := auth.LastError.HTTPStatus; {
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests, status >= 500:
+		return true
+	}
+	return false
+}
+
+// observeCooldownStorm alerts when the failed result left no credential available for its model.
+func (n *Notifier) observeCooldownStorm(auth *coreauth.Auth, result coreauth.Result) {
+	modelRegistry := registry.GetGlobalRegistry()
+	if !modelRegistry.ClientSupportsModel(result.AuthID, result.Model) || modelRegistry.GetModelCount(result.Model) > 0 {
+		return
+	}
+	event := Event{
+		Kind:     config.NotificationEventCooldownStorm,
+		Provider: auth.Provider,
+		Model:    result.Model,
+		Message:  fmt.Sprintf("every credential for %s is cooling down", result.Model),
+	}
+	if result.Error != nil {
+		event.HTTPStatus = result.Error.HTTPStatus
+	}
+	n.emit(event)
+}
+
+// observeBudget alerts once when a credential's forecast share of quota left drops below the
+// threshold, and again only after it has recovered above it.
+func (n *Notifier) observeBudget(auth *coreauth.Auth, forecast coreauth.QuotaForecast) {
+	n.mu.Lock()
+	threshold := n.cfg.BudgetPercent
+	if threshold <= 0 || forecast.RemainingPercent == nil {
+		n.mu.Unlock()
+		return
+	}
+	state := n.auths[auth.ID]
+	if state == nil {
+		state = &authState{status: auth.Status, message: auth.StatusMessage}
+		n.auths[auth.ID] = state
+	}
+	below := *forecast.RemainingPercent < float64(threshold)
+	raise := below && !state.budget
+	state.budget = below
+	n.mu.Unlock()
+	if !raise {
+		return
+	}
+	remaining := *forecast.RemainingPercent
+	n.emit(Event{
+		Kind:             config.NotificationEventBudget,
+		AuthID:           auth.ID,
+		Provider:         auth.Provider,
+		Label:            auth.Label,
+		RemainingPercent: &remaining,
+		Message:          fmt.Sprintf("%.1f%% of quota left, below %d%%", remaining, threshold),
+	})
+}
+
+// emit applies the event filter, deduplication and rate limit, then delivers the event to every
+// matching target in the background.
+func (n *Notifier) emit(event Event) {
+	n.mu.Lock()
+	cfg := n.cfg
+	if !cfg.Enabled || !eventSelected(cfg.Events, event.Kind) {
+		n.mu.Unlock()
+		return
+	}
+	now := n.now()
+	event.Time = now.UTC()
+	dedupWindow := defaultDedupWindow
+	if cfg.DedupWindow > 0 {
+		dedupWindow = time.Duration(cfg.DedupWindow) * time.Second
+	}
+	key := strings.Join([]string{event.Kind, event.AuthID, event.Provider, event.Model, event.Status}, "|")
+	if last, ok := n.lastSent[key]; ok && now.Sub(last) < dedupWindow {
+		n.mu.Unlock()
+		return
+	}
+	rateLimit := defaultRateLimit
+	if cfg.RateLimit > 0 {
+		rateLimit = cfg.RateLimit
+	}
+	kept := n.sent[:0]
+	for _, at := range n.sent {
+		if now.Sub(at) < time.Minute {
+			kept = append(kept, at)
+		}
+	}
+	n.sent = kept
+	if len(n.sent) >= rateLimit {
+		n.mu.Unlock()
+		log.Warnf("notify: rate limit reached, dropping %s alert for %s", event.Kind, firstNonEmpty(event.AuthID, event.Model))
+		return
+	}
+	n.sent = append(n.sent, now)
+	n.lastSent[key] = now
+	for k, at := range n.lastSent {
+		if now.Sub(at) >= dedupWindow {
+			delete(n.lastSent, k)
+		}
+	}
+	client := n.client
+	n.mu.Unlock()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Warnf("notify: failed to encode %s alert: %v", event.Kind, err)
+		return
+	}
+	log.Infof("notify: %s alert for %s", event.Kind, firstNonEmpty(event.AuthID, event.Model))
+	for _, webhook := range cfg.Webhooks {
+		if eventSelected(webhook.Events, event.Kind) {
+			go deliverWebhook(client, webhook, event.Kind, body)
+		}
+	}
+	for _, command := range cfg.Commands {
+		if eventSelected(command.Events, event.Kind) {
+			go runCommand(command, event, body)
+		}
+	}
+}
+
+func eventSelected(events []string, kind string) bool {
+	if len(events) == 0 {
+		return true
+	}
+	for _, event := range events {
+		if strings.TrimSpace(event) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Sign returns the X-Cliproxy-Signature value for body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverWebhook posts body, retrying network errors, 429 and 5xx responses with exponential
+// backoff.
+func deliverWebhook(client *http.Client, webhook config.NotificationWebhook, kind string, body []byte) {
+	maxRetries := defaultMaxRetries
+	if webhook.MaxRetries > 0 {
+		maxRetries = webhook.MaxRetries
+	}
+	timeout := defaultTimeout
+	if webhook.Timeout > 0 {
+		timeout = time.Duration(webhook.Timeout) * time.Second
+	}
+	target := strings.TrimSpace(webhook.URL)
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := postWebhook(client, webhook, target, kind, body, timeout)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= maxRetries {
+			log.Warnf("notify: webhook %s failed after %d attempt(s): %v", target, attempt+1, err)
+			return
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+func postWebhook(client *http.Client, webhook config.NotificationWebhook, target, kind string, body []byte, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, kind)
+	req.Header.Set(TimestampHeader, timestamp)
+	if webhook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+func runCommand(command config.NotificationCommand, event Event, body []byte) {
+	timeout := defaultTimeout
+	if command.Timeout > 0 {
+		timeout = time.Duration(command.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, strings.TrimSpace(command.Command), command.Args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"CLIPROXY_EVENT="+event.Kind,
+		"CLIPROXY_AUTH_ID="+event.AuthID,
+		"CLIPROXY_PROVIDER="+event.Provider,
+		"CLIPROXY_MODEL="+event.Model,
+	)
+	for name, value := range command.Env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Warnf("notify: command %s failed: %v: %s", command.Command, err, strings.TrimSpace(string(output)))
+	}
+}
@@ -0,0 +1,175 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+type fakeSource struct {
+	auth     *coreauth.Auth
+	forecast *coreauth.QuotaForecast
+}
+
+func (s *fakeSource) GetByID(string) (*coreauth.Auth, bool) { return s.auth, s.auth != nil }
+
+func (s *fakeSource) QuotaForecast(string) (coreauth.QuotaForecast, bool) {
+	if s.forecast == nil {
+		return coreauth.QuotaForecast{}, false
+	}
+	return *s.forecast, true
+}
+
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookServer(t *testing.T, failures int32) (*httptest.Server, <-chan delivery, *atomic.Int32) {
+	t.Helper()
+	deliveries := make(chan delivery, 16)
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- delivery{header: r.Header.Clone(), body: body}
+	}))
+	t.Cleanup(server.Close)
+	return server, deliveries, &attempts
+}
+
+func receive(t *testing.T, deliveries <-chan delivery) (delivery, Event) {
+	t.Helper()
+	select {
+	case got := <-deliveries:
+		var event Event
+		if err := json.Unmarshal(got.body, &event); err != nil {
+			t.Fatalf("webhook body %s: %v", got.body, err)
+		}
+		return got, event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook delivery")
+	}
+	return delivery{}, Event{}
+}
+
+func TestStatusTransitionIsSignedAndRetried(t *testing.T) {
+	previousDelay := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = previousDelay })
+	server, deliveries, attempts := newWebhookServer(t, 2)
+	n := New()
+	n.Configure(config.NotificationsConfig{
+		Enabled:  true,
+		Webhooks: []config.NotificationWebhook{{URL: server.URL, Secret: "s3cret"}},
+	})
+	auth := &coreauth.Auth{ID: "auggie-1", Provider: "auggie", Status: coreauth.StatusActive}
+	n.OnAuthRegistered(context.Background(), auth)
+
+	auth = auth.Clone()
+	auth.Status = coreauth.StatusError
+	auth.StatusMessage = "Auggie upstream account is suspended or requires an active subscription"
+	auth.LastError = &coreauth.Error{HTTPStatus: http.StatusForbidden, Message: auth.StatusMessage}
+	n.Attach(&fakeSource{auth: auth})
+	n.OnResult(context.Background(), coreauth.Result{AuthID: auth.ID, Provider: "auggie"})
+
+	got, event := receive(t, deliveries)
+	if event.Kind != config.NotificationEventStatusChanged || event.From != "active" || event.Status != "error" || event.HTTPStatus != http.StatusForbidden {
+		t.Fatalf("event = %+v", event)
+	}
+	if want := Sign("s3cret", got.header.Get(TimestampHeader), got.body); got.header.Get(SignatureHeader) != want {
+		t.Fatalf("signature = %q, want %q", got.header.Get(SignatureHeader), want)
+	}
+	if attempts.Load() != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts.Load())
+	}
+
+	n.OnAuthUpdated(context.Background(), auth)
+	recovered := auth.Clone()
+	recovered.Status, recovered.StatusMessage, recovered.LastError = coreauth.StatusActive, "", nil
+	n.OnAuthUpdated(context.Background(), recovered)
+	if _, event = receive(t, deliveries); event.Kind != config.NotificationEventRecovered {
+		t.Fatalf("second event = %+v, want recovered", event)
+	}
+}
+
+func TestTransientErrorsAreNotAlerted(t *testing.T) {
+	server, deliveries, _ := newWebhookServer(t, 0)
+	n := New()
+	n.Configure(config.NotificationsConfig{Enabled: true, Webhooks: []config.NotificationWebhook{{URL: server.URL}}})
+	n.OnAuthUpdated(context.Background(), &coreauth.Auth{
+		ID:        "codex-1",
+		Status:    coreauth.StatusError,
+		LastError: &coreauth.Error{HTTPStatus: http.StatusBadGateway, Message: "bad gateway"},
+	})
+	select {
+	case got := <-deliveries:
+		t.Fatalf("unexpected alert %s", got.body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDedupAndRateLimit(t *testing.T) {
+	server, deliveries, _ := newWebhookServer(t, 0)
+	n := New()
+	n.Configure(config.NotificationsConfig{
+		Enabled:   true,
+		RateLimit: 2,
+		Webhooks:  []config.NotificationWebhook{{URL: server.URL, Events: []string{config.NotificationEventRefreshFailed}}},
+	})
+	revoked := errors.New("invalid_grant: token has been revoked")
+	for _, id := range []string{"antigravity-1", "antigravity-1", "antigravity-2", "antigravity-3"} {
+		n.OnRefreshFailed(context.Background(), &coreauth.Auth{ID: id, Provider: "antigravity"}, revoked)
+	}
+	seen := map[string]bool{}
+	for range 2 {
+		_, event := receive(t, deliveries)
+		if event.Kind != config.NotificationEventRefreshFailed || event.Message != revoked.Error() {
+			t.Fatalf("event = %+v", event)
+		}
+		seen[event.AuthID] = true
+	}
+	if !seen["antigravity-1"] || !seen["antigravity-2"] {
+		t.Fatalf("delivered alerts for %v", seen)
+	}
+	select {
+	case got := <-deliveries:
+		t.Fatalf("alert beyond dedup and rate limit: %s", got.body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestBudgetThresholdAlertsOncePerCrossing(t *testing.T) {
+	server, deliveries, _ := newWebhookServer(t, 0)
+	n := New()
+	n.Configure(config.NotificationsConfig{Enabled: true, BudgetPercent: 10, DedupWindow: 1, Webhooks: []config.NotificationWebhook{{URL: server.URL}}})
+	percent := 4.0
+	source := &fakeSource{
+		auth:     &coreauth.Auth{ID: "claude-1", Provider: "claude", Status: coreauth.StatusActive},
+		forecast: &coreauth.QuotaForecast{AuthID: "claude-1", RemainingPercent: &percent},
+	}
+	n.Attach(source)
+	result := coreauth.Result{AuthID: "claude-1", Provider: "claude", Success: true}
+	n.OnResult(context.Background(), result)
+	n.OnResult(context.Background(), result)
+	if _, event := receive(t, deliveries); event.Kind != config.NotificationEventBudget || event.RemainingPercent == nil || *event.RemainingPercent != 4 {
+		t.Fatalf("event = %+v", event)
+	}
+	select {
+	case got := <-deliveries:
+		t.Fatalf("budget alert repeated while still below threshold: %s", got.body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
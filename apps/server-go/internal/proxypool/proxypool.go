@@ -0,0 +1,488 @@
+// Package proxypool assigns credentials to members of named egress proxy pools. Each credential
+// sticks to the member with the highest rendezvous hash of its ID among the healthy members, so
+// assignments only move when a member fails or the pool changes. Members are health-checked
+// periodically and report per-member usage through the transports handed out by RoundTripper.
+package proxypool
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upstreamtls"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/proxy"
+)
+
+const (
+	defaultCheckURL         = "https://www.gstatic.com/generate_204"
+	defaultCheckInterval    = 60 * time.Second
+	defaultCheckTimeout     = 10 * time.Second
+	defaultFailureThreshold = 3
+)
+
+// Assignment is the pool member serving a credential.
+type Assignment struct {
+	Pool string
+	// Member is the member URL with its password redacted.
+	Member string
+	// URL is the member URL including credentials.
+	URL string
+	// Failover is true when the preferred member is unhealthy and another one was chosen.
+	Failover bool
+}
+
+// MemberStatus reports the health and usage of one pool member.
+type MemberStatus struct {
+	URL                 string     `json:"url"`
+	Healthy             bool       `json:"healthy"`
+	ConsecutiveFailures int64      `json:"consecutive_failures"`
+	LastCheck           *time.Time `json:"last_check,omitempty"`
+	LastLatencyMs       int64      `json:"last_latency_ms,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	Requests            int64      `json:"requests"`
+	Failures            int64      `json:"failures"`
+	LastUsed            *time.Time `json:"last_used,omitempty"`
+}
+
+// PoolStatus reports the members of one pool.
+type PoolStatus struct {
+	Name        string         `json:"name"`
+	Providers   []string       `json:"providers,omitempty"`
+	HealthCheck bool           `json:"health_check"`
+	Members     []MemberStatus `json:"members"`
+}
+
+type member struct {
+	url       string
+	redacted  string
+	transport http.RoundTripper
+
+	healthy     atomic.Bool
+	consecutive atomic.Int64
+	requests    atomic.Int64
+	failures    atomic.Int64
+	lastUsed    atomic.Int64
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	latency   time.Duration
+	lastError string
+}
+
+type pool struct {
+	name      string
+	providers []string
+	members   []*member
+
+	checks    bool
+	checkURL  string
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int64
+}
+
+type registry struct {
+	pools map[string]*pool
+	order []*pool
+	stop  chan struct{}
+}
+
+var (
+	mu     sync.RWMutex
+	active *registry
+)
+
+// Configure replaces the active pools and restarts health checking. Members whose URL is
+// unchanged keep their transport, health and usage counters.
+func Configure(pools []config.ProxyPool) {
+	previous := make(map[string]*member)
+	mu.Lock()
+	defer mu.Unlock()
+	if active != nil {
+		close(active.stop)
+		for _, p := range active.order {
+			for _, m := range p.members {
+				previous[p.name+"\x00"+m.url] = m
+			}
+		}
+		active = nil
+	}
+	if len(pools) == 0 {
+		return
+	}
+	reg := &registry{pools: make(map[string]*pool, len(pools)), stop: make(chan struct{})}
+	for _, cfg := range pools {
+		name := strings.ToLower(strings.TrimSpace(cfg.Name))
+		if name == "" || reg.pools[name] != nil {
+			continue
+		}
+		p := newPool(name, cfg)
+		for _, raw := range cfg.Members {
+			raw = strings.TrimSpace(raw)
+			if m := previous[name+"\x00"+raw]; m != nil {
+				p.members = append(p.members, m)
+				continue
+			}
+			m, err := newMember(raw)
+			if err != nil {
+				log.Warnf("proxy pool %s: skipping member %s: %v", name, redact(raw), err)
+				continue
+			}
+			p.members = append(p.members, m)
+		}
+		if len(p.members) == 0 {
+			log.Warnf("proxy pool %s: no usable members", name)
+			continue
+		}
+		if !p.checks {
+			for _, m := range p.members {
+				m.healthy.Store(true)
+				m.consecutive.Store(0)
+			}
+		}
+		reg.pools[name] = p
+		reg.order = append(reg.order, p)
+		if p.checks {
+			go p.checkLoop(reg.stop)
+		}
+	}
+	active = reg
+}
+
+func newPool(name string, cfg config.ProxyPool) *pool {
+	p := &pool{
+		name:      name,
+		checks:    !cfg.HealthCheck.Disable,
+		checkURL:  strings.TrimSpace(cfg.HealthCheck.URL),
+		interval:  time.Duration(cfg.HealthCheck.Interval) * time.Second,
+		timeout:   time.Duration(cfg.HealthCheck.Timeout) * time.Second,
+		threshold: int64(cfg.HealthCheck.FailureThreshold),
+	}
+	for _, provider := range cfg.Providers {
+		if provider = strings.ToLower(strings.TrimSpace(provider)); provider != "" {
+			p.providers = append(p.providers, provider)
+		}
+	}
+	if p.checkURL == "" {
+		p.checkURL = defaultCheckURL
+	}
+	if p.interval <= 0 {
+		p.interval = defaultCheckInterval
+	}
+	if p.timeout <= 0 {
+		p.timeout = defaultCheckTimeout
+	}
+	if p.threshold <= 0 {
+		p.threshold = defaultFailureThreshold
+	}
+	return p
+}
+
+func newMember(raw string) (*member, error) {
+	transport, err := newTransport(raw)
+	if err != nil {
+		return nil, err
+	}
+	m := &member{url: raw, redacted: redact(raw), transport: upstreamtls.Wrap(transport)}
+	m.healthy.Store(true)
+	return m, nil
+}
+
+// newTransport builds a transport egressing through the proxy at raw.
+func newTransport(raw string) (*http.Transport, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy URL: %w", err)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("proxy URL has no host")
+	}
+	switch parsed.Scheme {
+	case "socks5":
+		var proxyAuth *proxy.Auth
+		if parsed.User != nil {
+			password, _ := parsed.User.Password()
+			proxyAuth = &proxy.Auth{User: parsed.User.Username(), Password: password}
+		}
+		dialer, errSOCKS5 := proxy.SOCKS5("tcp", parsed.Host, proxyAuth, proxy.Direct)
+		if errSOCKS5 != nil {
+			return nil, fmt.Errorf("create SOCKS5 dialer: %w", errSOCKS5)
+		}
+		return &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				if contextDialer, ok := dialer.(proxy.ContextDialer); ok {
+					return contextDialer.DialContext(ctx, network, addr)
+				}
+				return dialer.Dial(network, addr)
+			},
+		}, nil
+	case "http", "https":
+		return &http.Transport{Proxy: http.ProxyURL(parsed)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", parsed.Scheme)
+	}
+}
+
+// IsPoolRef reports whether proxyURL refers to a pool.
+func IsPoolRef(proxyURL string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(proxyURL)), config.ProxyPoolScheme)
+}
+
+// Resolve returns the member serving the credential, if it uses a pool: proxyURL names the pool
+// with the pool:// scheme, or is empty and a pool lists provider.
+func Resolve(authID, provider, proxyURL string) (Assignment, bool) {
+	p, m, failover := resolve(authID, provider, proxyURL)
+	if m == nil {
+		return Assignment{}, false
+	}
+	return Assignment{Pool: p.name, Member: m.redacted, URL: m.url, Failover: failover}, true
+}
+
+// RoundTripper returns a transport egressing through the member serving the credential and
+// counting its usage, or false when the credential does not use a pool.
+func RoundTripper(authID, provider, proxyURL string) (http.RoundTripper, bool) {
+	p, m, _ := resolve(authID, provider, proxyURL)
+	if m == nil {
+		return nil, false
+	}
+	return &countingTransport{member: m, threshold: p.threshold, checks: p.checks, pool: p.name}, true
+}
+
+func resolve(authID, provider, proxyURL string) (*pool, *member, bool) {
+	mu.RLock()
+	reg := active
+	mu.RUnlock()
+	if reg == nil {
+		return nil, nil, false
+	}
+	var p *pool
+	proxyURL = strings.TrimSpace(proxyURL)
+	switch {
+	case IsPoolRef(proxyURL):
+		name := strings.ToLower(strings.TrimSpace(proxyURL[len(config.ProxyPoolScheme):]))
+		p = reg.pools[name]
+		if p == nil {
+			log.Warnf("proxy pool %q referenced by credential %s is not configured", name, authID)
+			return nil, nil, false
+		}
+	case proxyURL == "":
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		for _, candidate := range reg.order {
+			for _, listed := range candidate.providers {
+				if listed == provider {
+					p = candidate
+					break
+				}
+			}
+			if p != nil {
+				break
+			}
+		}
+	}
+	if p == nil {
+		return nil, nil, false
+	}
+	m, failover := p.pick(authID)
+	return p, m, failover
+}
+
+// pick returns the healthy member with the highest rendezvous score for authID. When every member
+// is unhealthy the preferred member is used anyway.
+func (p *pool) pick(authID string) (*member, bool) {
+	var preferred, best *member
+	var preferredScore, bestScore uint64
+	for _, m := range p.members {
+		score := rendezvousScore(authID, m.url)
+		if preferred == nil || score > preferredScore {
+			preferred, preferredScore = m, score
+		}
+		if m.healthy.Load() && (best == nil || score > bestScore) {
+			best, bestScore = m, score
+		}
+	}
+	if best == nil {
+		return preferred, false
+	}
+	return best, best != preferred
+}
+
+func rendezvousScore(authID, memberURL string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(authID))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(memberURL))
+	return h.Sum64()
+}
+
+// checkLoop checks every member immediately and then once per interval until stop is closed.
+func (p *pool) checkLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.checkAll(stop)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *pool) checkAll(stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	for _, m := range p.members {
+		wg.Add(1)
+		go func(m *member) {
+			defer wg.Done()
+			p.check(stop, m)
+		}(m)
+	}
+	wg.Wait()
+}
+
+func (p *pool) check(stop <-chan struct{}, m *member) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	start := time.Now()
+	err := probe(ctx, m.transport, p.checkURL)
+	select {
+	case <-stop:
+		return
+	default:
+	}
+	m.mu.Lock()
+	m.lastCheck = time.Now()
+	m.latency = time.Since(start)
+	m.lastError = ""
+	if err != nil {
+		m.lastError = err.Error()
+	}
+	m.mu.Unlock()
+	if err == nil {
+		m.consecutive.Store(0)
+		if !m.healthy.Swap(true) {
+			log.Infof("proxy pool %s: member %s is healthy again", p.name, m.redacted)
+		}
+		return
+	}
+	m.markFailure(p.name, p.threshold, err)
+}
+
+func probe(ctx context.Context, transport http.RoundTripper, checkURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusProxyAuthRequired || resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// markFailure counts a failed check or request and takes the member out of rotation once the
+// threshold is reached.
+func (m *member) markFailure(poolName string, threshold int64, err error) {
+	if m.consecutive.Add(1) >= threshold && m.healthy.Swap(false) {
+		log.Warnf("proxy pool %s: member %s is unhealthy: %v", poolName, m.redacted, err)
+	}
+}
+
+// countingTransport records the usage of a pool member. Transport errors count towards taking the
+// member out of rotation when health checks can bring it back.
+type countingTransport struct {
+	member    *member
+	pool      string
+	threshold int64
+	checks    bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.member.requests.Add(1)
+	t.member.lastUsed.Store(time.Now().UnixNano())
+	resp, err := t.member.transport.RoundTrip(req)
+	if err != nil {
+		if req.Context().Err() == nil {
+			t.member.failures.Add(1)
+			if t.checks {
+				t.member.markFailure(t.pool, t.threshold, err)
+			}
+		}
+		return resp, err
+	}
+	t.member.consecutive.Store(0)
+	return resp, nil
+}
+
+// Status reports every pool with the health and usage of its members.
+func Status() []PoolStatus {
+	mu.RLock()
+	reg := active
+	mu.RUnlock()
+	out := []PoolStatus{}
+	if reg == nil {
+		return out
+	}
+	for _, p := range reg.order {
+		status := PoolStatus{Name: p.name, Providers: p.providers, HealthCheck: p.checks, Members: make([]MemberStatus, 0, len(p.members))}
+		for _, m := range p.members {
+			ms := MemberStatus{
+				URL:                 m.redacted,
+				Healthy:             m.healthy.Load(),
+				ConsecutiveFailures: m.consecutive.Load(),
+				Requests:            m.requests.Load(),
+				Failures:            m.failures.Load(),
+			}
+			if used := m.lastUsed.Load(); used > 0 {
+				at := time.Unix(0, used)
+				ms.LastUsed = &at
+			}
+			m.mu.Lock()
+			if !m.lastCheck.IsZero() {
+				at := m.lastCheck
+				ms.LastCheck = &at
+				ms.LastLatencyMs = m.latency.Milliseconds()
+			}
+			ms.LastError = m.lastError
+			m.mu.Unlock()
+			status.Members = append(status.Members, ms)
+		}
+		out = append(out, status)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// redact hides the password of a proxy URL.
+func redact(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.User == nil {
+		return raw
+	}
+	if _, hasPassword := parsed.User.Password(); hasPassword {
+		parsed.User = url.UserPassword(parsed.User.Username(), "xxxxx")
+	}
+	return parsed.String()
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upstreamtls"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
@@ -174,16 +175,16 @@ func initAntigravityTransport() {
 func newAntigravityHTTPClient(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, timeout time.Duration) *http.Client {
 	antigravityTransportOnce.Do(initAntigravityTransport)
 
-	client := newProxyAwareHTTPClient(ctx, cfg, auth, timeout)
+	rt := proxyAwareRoundTripper(ctx, cfg, auth)
 	// If the proxy helper didn't set a custom transport (e.g. SOCKS5), use
 	// the shared HTTP/1.1 transport. Custom proxy transports are left as-is
 	// because they already carry their own dialer configuration.
-	if client.Transport == nil {
-		client.Transport = antigravityTransport
-	} else if _, isDefault := client.Transport.(*http.Transport); isDefault {
-		client.Transport = antigravityTransport
+	if rt == nil {
+		rt = upstreamtls.Shared(antigravityTransport)
+	} else if _, isDefault := rt.(*http.Transport); isDefault {
+		rt = upstreamtls.Shared(antigravityTransport)
 	}
-	return client
+	return newUpstreamHTTPClient(auth, timeout, rt)
 }
 
 // Identifier returns the executor identifier.
@@ -1151,6 +1152,59 @@ func (e *AntigravityExecutor) CountTokens(ctx context.Context, auth *cliproxyaut
 	}
 }
 
+// Probe implements cliproxyauth.HealthProber with a model-list request. An expired access token
+// is refreshed first; the refreshed auth is returned so the manager can persist it.
+func (e *AntigravityExecutor) Probe(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	token, updatedAuth, errToken := e.ensureAccessToken(ctx, auth)
+	if errToken != nil {
+		return nil, errToken
+	}
+	if updatedAuth != nil {
+		auth = updatedAuth
+	}
+
+	payload := []byte(`{}`)
+	if pid := strings.TrimSpace(metaStringValue(auth.Metadata, "project_id")); pid != "" {
+		payload = []byte(fmt.Sprintf(`{"project": "%s"}`, pid))
+	}
+	httpClient := newAntigravityHTTPClient(ctx, e.cfg, auth, 0)
+	var lastErr error = statusErr{code: http.StatusServiceUnavailable, msg: "antigravity executor: no base url available"}
+	for _, baseURL := range antigravityBaseURLFallbackOrder(auth) {
+		httpReq, errReq := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+antigravityModelsPath, bytes.NewReader(payload))
+		if errReq != nil {
+			return updatedAuth, errReq
+		}
+		httpReq.Close = true
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+		httpReq.Header.Set("User-Agent", resolveUserAgent(auth))
+		if host := resolveHost(baseURL); host != "" {
+			httpReq.Host = host
+		}
+		httpResp, errDo := httpClient.Do(httpReq)
+		if errDo != nil {
+			lastErr = errDo
+			if errors.Is(errDo, context.Canceled) || errors.Is(errDo, context.DeadlineExceeded) {
+				break
+			}
+			continue
+		}
+		body, _ := io.ReadAll(io.LimitReader(httpResp.Body, 4096))
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("antigravity executor: close response body error: %v", errClose)
+		}
+		if httpResp.StatusCode >= http.StatusOK && httpResp.StatusCode < http.StatusMultipleChoices {
+			return updatedAuth, nil
+		}
+		lastErr = statusErr{code: httpResp.StatusCode, msg: string(body)}
+		// Rate limits and server errors may be specific to one base URL.
+		if httpResp.StatusCode != http.StatusTooManyRequests && httpResp.StatusCode < http.StatusInternalServerError {
+			break
+		}
+	}
+	return updatedAuth, lastErr
+}
+
 // FetchAntigravityModels retrieves available models using the supplied auth.
 func FetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *config.Config) []*registry.ModelInfo {
 	exec := &AntigravityExecutor{cfg: cfg}
@@ -12,12 +12,14 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 	"unicode"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
@@ -59,6 +61,8 @@ type auggieConversationState struct {
 type auggieConversationStateStore struct {
 	mu    sync.Mutex
 	items map[string]auggieConversationState
+	// namespace is the cluster namespace the store writes through to in cluster mode.
+	namespace string
 }
 
 type auggieToolCallIDMapping struct {
@@ -98,11 +102,13 @@ type auggieModelInfoRegistryEntry struct {
 }
 
 var defaultAuggieResponsesStateStore = &auggieConversationStateStore{
-	items: make(map[string]auggieConversationState),
+	items:     make(map[string]auggieConversationState),
+	namespace: cluster.NamespaceAuggieState,
 }
 
 var defaultAuggieToolCallStateStore = &auggieConversationStateStore{
-	items: make(map[string]auggieConversationState),
+	items:     make(map[string]auggieConversationState),
+	namespace: cluster.NamespaceAuggieToolCall,
 }
 
 var defaultAuggieToolCallIDMappingStore = &auggieToolCallIDMappingStore{
@@ -209,27 +215,15 @@ func (e *AuggieExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 
 	switch from {
 	case sdktranslator.FormatOpenAI:
-		streamResult, err := e.ExecuteStream(ctx, auth, req, opts)
+		req, opts, emulation, err := e.prepareAuggieChatParameterEmulation(req, opts)
 		if err != nil {
 			return cliproxyexecutor.Response{}, err
 		}
-		if streamResult == nil {
-			return cliproxyexecutor.Response{}, statusErr{code: http.StatusBadGateway, msg: "auggie stream result is nil"}
+		ctx = withAuggieOutputLimits(ctx, emulation.Limits)
+		if emulation.N > 1 {
+			return e.executeAuggieChatChoices(ctx, auth, req, opts, emulation.N)
 		}
-
-		payload, err := collectAuggieOpenAINonStream(streamResult.Chunks, payloadRequestedModel(opts, req.Model))
-		if err != nil {
-			return cliproxyexecutor.Response{}, err
-		}
-		payload, err = appendAuggieOpenAIChatCompletionMetadata(payload, originalAuggieOpenAIRequest(req, opts))
-		if err != nil {
-			return cliproxyexecutor.Response{}, err
-		}
-
-		return cliproxyexecutor.Response{
-			Payload: payload,
-			Headers: streamResult.Headers,
-		}, nil
+		return e.executeAuggieChatChoice(ctx, auth, req, opts)
 	case sdktranslator.FormatClaude:
 		return e.executeClaude(ctx, auth, req, opts)
 	case sdktranslator.FormatOpenAIResponse:
@@ -239,6 +233,43 @@ func (e *AuggieExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	}
 }
 
+// executeAuggieChatChoice produces a single-choice chat completion from one upstream call.
+func (e *AuggieExecutor) executeAuggieChatChoice(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	spec, structured, err := e.auggieStructuredOutputRequest(originalAuggieOpenAIRequest(req, opts), "response_format")
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	if structured {
+		payload, headers, errStructured := e.executeAuggieStructuredChat(ctx, auth, req, opts, spec)
+		if errStructured != nil {
+			return cliproxyexecutor.Response{}, errStructured
+		}
+		return cliproxyexecutor.Response{Payload: payload, Headers: headers}, nil
+	}
+
+	streamResult, err := e.executeAuggieChatChoiceStream(ctx, auth, req, opts)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	if streamResult == nil {
+		return cliproxyexecutor.Response{}, statusErr{code: http.StatusBadGateway, msg: "auggie stream result is nil"}
+	}
+
+	payload, err := collectAuggieOpenAINonStream(streamResult.Chunks, payloadRequestedModel(opts, req.Model))
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	payload, err = appendAuggieOpenAIChatCompletionMetadata(payload, originalAuggieOpenAIRequest(req, opts))
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+
+	return cliproxyexecutor.Response{
+		Payload: payload,
+		Headers: streamResult.Headers,
+	}, nil
+}
+
 func originalAuggieOpenAIRequest(req cliproxyexecutor.Request, opts cliproxyexecutor.Options) []byte {
 	if len(opts.OriginalRequest) > 0 {
 		return opts.OriginalRequest
@@ -260,18 +291,15 @@ func (e *AuggieExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 
 	switch from {
 	case sdktranslator.FormatOpenAI:
-		if err := validateAuggieOpenAIRequestCapabilities(req.Payload); err != nil {
-			return nil, err
-		}
-		resolvedReq := req
-		resolvedReq.Model = resolveAuggieModelAlias(auth, req.Model)
-
-		translated := sdktranslator.TranslateRequest(from, sdktranslator.FormatAuggie, resolvedReq.Model, req.Payload, true)
-		translated, err := enrichAuggieOpenAIChatCompletionRequest(resolvedReq.Model, req.Payload, translated)
+		req, opts, emulation, err := e.prepareAuggieChatParameterEmulation(req, opts)
 		if err != nil {
 			return nil, err
 		}
-		return e.executeAuggieStream(ctx, auth, resolvedReq, opts, translated, from, true)
+		ctx = withAuggieOutputLimits(ctx, emulation.Limits)
+		if emulation.N > 1 {
+			return e.executeAuggieChatChoicesStream(ctx, auth, req, opts, emulation.N)
+		}
+		return e.executeAuggieChatChoiceStream(ctx, auth, req, opts)
 	case sdktranslator.FormatClaude:
 		return e.executeClaudeStream(ctx, auth, req, opts)
 	case sdktranslator.FormatOpenAIResponse:
@@ -281,6 +309,39 @@ func (e *AuggieExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	}
 }
 
+// executeAuggieChatChoiceStream streams a single-choice chat completion from one upstream call.
+func (e *AuggieExecutor) executeAuggieChatChoiceStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	spec, structured, err := e.auggieStructuredOutputRequest(originalAuggieOpenAIRequest(req, opts), "response_format")
+	if err != nil {
+		return nil, err
+	}
+	if structured {
+		// Structured output is validated before anything is sent, so the stream is synthesized
+		// from the buffered result.
+		payload, headers, errStructured := e.executeAuggieStructuredChat(ctx, auth, req, opts, spec)
+		if errStructured != nil {
+			return nil, errStructured
+		}
+		return streamAuggieStructuredChat(payload, headers), nil
+	}
+	return e.executeAuggieOpenAIChatStream(ctx, auth, req, opts)
+}
+
+func (e *AuggieExecutor) executeAuggieOpenAIChatStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	if err := validateAuggieOpenAIRequestCapabilities(req.Payload); err != nil {
+		return nil, err
+	}
+	resolvedReq := req
+	resolvedReq.Model = resolveAuggieModelAlias(auth, req.Model)
+
+	translated := sdktranslator.TranslateRequest(sdktranslator.FormatOpenAI, sdktranslator.FormatAuggie, resolvedReq.Model, req.Payload, true)
+	translated, err := enrichAuggieOpenAIChatCompletionRequest(resolvedReq.Model, req.Payload, translated)
+	if err != nil {
+		return nil, err
+	}
+	return e.executeAuggieStream(ctx, auth, resolvedReq, opts, translated, sdktranslator.FormatOpenAI, true)
+}
+
 func (e *AuggieExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	from := opts.SourceFormat
 	if from == "" {
@@ -393,6 +454,25 @@ func (e *AuggieExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*c
 	return updated, nil
 }
 
+// Probe implements cliproxyauth.HealthProber with a get-models request. A rejected token is
+// revalidated from the Auggie session once; the auth is returned when its token changed.
+func (e *AuggieExecutor) Probe(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	if auth == nil {
+		return nil, statusErr{code: http.StatusUnauthorized, msg: "auggie executor: auth is nil"}
+	}
+	_, updated := e.fetchModels(ctx, auth, true)
+	if updated == nil {
+		return nil, statusErr{code: http.StatusServiceUnavailable, msg: "auggie executor: get-models probe failed"}
+	}
+	if updated.Status == cliproxyauth.StatusError && updated.LastError != nil {
+		return nil, statusErr{code: updated.LastError.HTTPStatus, msg: updated.LastError.Message}
+	}
+	if auggieAccessToken(updated) == auggieAccessToken(auth) {
+		return nil, nil
+	}
+	return updated, nil
+}
+
 func FetchAuggieModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *config.Config) []*registry.ModelInfo {
 	exec := NewAuggieExecutor(cfg)
 	models, updatedAuth := exec.fetchModels(ctx, auth, true)
@@ -815,6 +895,9 @@ func (s *auggieConversationStateStore) Store(key string, state auggieConversatio
 	s.cleanupLocked(now)
 	state.UpdatedAt = now
 	s.items[key] = state
+	if s.namespace != "" {
+		cluster.Save(s.namespace, key, state, auggieResponsesStateTTL)
+	}
 }
 
 func (s *auggieConversationStateStore) Load(key string) (auggieConversationState, bool) {
@@ -824,7 +907,13 @@ func (s *auggieConversationStateStore) Load(key string) (auggieConversationState
 	now := time.Now().UTC()
 	s.cleanupLocked(now)
 
-	state, ok := s.items[strings.TrimSpace(key)]
+	key = strings.TrimSpace(key)
+	state, ok := s.items[key]
+	if !ok && s.namespace != "" && cluster.Load(s.namespace, key, &state) {
+		// The previous turn was served by another node.
+		s.items[key] = state
+		ok = true
+	}
 	return state, ok
 }
 
@@ -856,6 +945,19 @@ func (s *auggieToolCallIDMappingStore) Store(publicID, internalID string) {
 	}
 	s.byPublicID[publicID] = entry
 	s.byInternal[internalID] = entry
+	cluster.Save(cluster.NamespaceAuggieCallID, "public:"+publicID, entry, auggieResponsesStateTTL)
+	cluster.Save(cluster.NamespaceAuggieCallID, "internal:"+internalID, entry, auggieResponsesStateTTL)
+}
+
+// loadSharedLocked fetches a mapping stored by another node and indexes it locally.
+func (s *auggieToolCallIDMappingStore) loadSharedLocked(key string) (auggieToolCallIDMapping, bool) {
+	var entry auggieToolCallIDMapping
+	if !cluster.Load(cluster.NamespaceAuggieCallID, key, &entry) || entry.PublicID == "" || entry.InternalID == "" {
+		return auggieToolCallIDMapping{}, false
+	}
+	s.byPublicID[entry.PublicID] = entry
+	s.byInternal[entry.InternalID] = entry
+	return entry, true
 }
 
 func (s *auggieToolCallIDMappingStore) LoadInternal(publicID string) (string, bool) {
@@ -865,9 +967,12 @@ func (s *auggieToolCallIDMappingStore) LoadInternal(publicID string) (string, bo
 	now := time.Now().UTC()
 	s.cleanupLocked(now)
 
-	entry, ok := s.byPublicID[strings.TrimSpace(publicID)]
+	publicID = strings.TrimSpace(publicID)
+	entry, ok := s.byPublicID[publicID]
 	if !ok {
-		return "", false
+		if entry, ok = s.loadSharedLocked("public:" + publicID); !ok {
+			return "", false
+		}
 	}
 	return entry.InternalID, true
 }
@@ -879,9 +984,12 @@ func (s *auggieToolCallIDMappingStore) LoadPublic(internalID string) (string, bo
 	now := time.Now().UTC()
 	s.cleanupLocked(now)
 
-	entry, ok := s.byInternal[strings.TrimSpace(internalID)]
+	internalID = strings.TrimSpace(internalID)
+	entry, ok := s.byInternal[internalID]
 	if !ok {
-		return "", false
+		if entry, ok = s.loadSharedLocked("internal:" + internalID); !ok {
+			return "", false
+		}
 	}
 	return entry.PublicID, true
 }
@@ -1040,6 +1148,9 @@ func (e *AuggieExecutor) executeAuggieStream(ctx context.Context, auth *cliproxy
 	if auth == nil {
 		return nil, statusErr{code: http.StatusInternalServerError, msg: "auggie executor: auth is nil"}
 	}
+	if limits, ok := auggieOutputLimitsFromContext(ctx); ok && from == sdktranslator.FormatOpenAI {
+		return e.executeAuggieLimitedStream(ctx, auth, req, opts, translated, limits, allowRefresh)
+	}
 	translated, err = enrichAuggieIDEStateNode(translated)
 	if err != nil {
 		return nil, err
@@ -1131,7 +1242,7 @@ func (e *AuggieExecutor) executeAuggieStream(ctx context.Context, auth *cliproxy
 			log.Warn("auggie executor: upstream rejected system prompt customization controls; retrying request without native system_prompt fields")
 			return e.executeAuggieStream(ctx, auth, req, opts, fallbackPayload, from, allowRefresh)
 		}
-		return nil, statusErr{code: httpResp.StatusCode, msg: string(body)}
+		return nil, newAuggieUpstreamStatusErr(httpResp, body)
 	}
 
 	markAuggieAuthActive(auth, time.Now().UTC())
@@ -1188,11 +1299,12 @@ func (e *AuggieExecutor) executeAuggieStream(ctx context.Context, auth *cliproxy
 			for i := range chunks {
 				chunkPayload := []byte(chunks[i])
 				if from == sdktranslator.FormatOpenAI {
-					chunkPayload, err = rewriteOpenAIToolCallIDs(chunkPayload)
-					if err != nil {
-						recordAPIResponseError(ctx, e.cfg, err)
+					var errRewrite error
+					chunkPayload, errRewrite = rewriteOpenAIToolCallIDs(chunkPayload)
+					if errRewrite != nil {
+						recordAPIResponseError(ctx, e.cfg, errRewrite)
 						reporter.publishFailure(ctx)
-						out <- cliproxyexecutor.StreamChunk{Err: err}
+						out <- cliproxyexecutor.StreamChunk{Err: errRewrite}
 						return
 					}
 				}
@@ -1357,7 +1469,7 @@ func (e *AuggieExecutor) executeAuggieJSON(ctx context.Context, auth *cliproxyau
 			return nil, statusErr{code: http.StatusUnauthorized, msg: string(responseBody)}
 		}
 		if httpResp.StatusCode < http.StatusOK || httpResp.StatusCode >= http.StatusMultipleChoices {
-			return nil, statusErr{code: httpResp.StatusCode, msg: string(responseBody)}
+			return nil, newAuggieUpstreamStatusErr(httpResp, responseBody)
 		}
 
 		markAuggieAuthActive(auth, time.Now().UTC())
@@ -1582,6 +1694,25 @@ func collectAuggieOpenAINonStream(chunks <-chan cliproxyexecutor.StreamChunk, fa
 	return data, nil
 }
 
+// newAuggieUpstreamStatusErr wraps a failed upstream response. A 429 keeps its Retry-After, both as
+// the retry hint that drives the credential cooldown and as a header for the client.
+func newAuggieUpstreamStatusErr(httpResp *http.Response, body []byte) error {
+	err := statusErr{code: httpResp.StatusCode, msg: string(body)}
+	if httpResp.StatusCode != http.StatusTooManyRequests {
+		return err
+	}
+	raw := strings.TrimSpace(httpResp.Header.Get("Retry-After"))
+	if raw == "" {
+		return err
+	}
+	if seconds, errParse := strconv.ParseFloat(raw, 64); errParse == nil && seconds >= 0 {
+		err.retryAfter = new(time.Duration(seconds * float64(time.Second)))
+	} else if at, errParse := http.ParseTime(raw); errParse == nil {
+		err.retryAfter = new(max(time.Until(at), 0))
+	}
+	return statusErrWithHeaders{statusErr: err, headers: http.Header{"Retry-After": {raw}}}
+}
+
 func detectAuggieSuspendedAccountStatusErr(payload []byte) error {
 	if !auggiePayloadContainsSuspendedAccountBanner(payload) {
 		return nil
@@ -1730,6 +1861,7 @@ func appendAuggieOpenAIChatCompletionMetadata(payload, requestRawJSON []byte) ([
 
 func (e *AuggieExecutor) executeClaude(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	openAIReq, originalPayload := buildAuggieBridgeToOpenAIRequest(req, opts, sdktranslator.FormatClaude, false)
+	openAIReq.Payload = e.withoutUnenforcedClaudeMaxTokens(openAIReq.Payload)
 	openAIOpts := opts
 	openAIOpts.SourceFormat = sdktranslator.FormatOpenAI
 
@@ -1757,6 +1889,7 @@ func (e *AuggieExecutor) executeClaude(ctx context.Context, auth *cliproxyauth.A
 
 func (e *AuggieExecutor) executeClaudeStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
 	openAIReq, originalPayload := buildAuggieBridgeToOpenAIRequest(req, opts, sdktranslator.FormatClaude, true)
+	openAIReq.Payload = e.withoutUnenforcedClaudeMaxTokens(openAIReq.Payload)
 	openAIOpts := opts
 	openAIOpts.SourceFormat = sdktranslator.FormatOpenAI
 
@@ -1817,12 +1950,34 @@ func (e *AuggieExecutor) executeClaudeStream(ctx context.Context, auth *cliproxy
 }
 
 func (e *AuggieExecutor) executeOpenAIResponses(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	// The client's request is echoed back in the response, including any emulated parameters.
+	requestPayload := originalAuggieOpenAIRequest(req, opts)
+	req, opts, limits, err := e.prepareAuggieResponsesParameterEmulation(req, opts)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	ctx = withAuggieOutputLimits(ctx, limits)
 	resolvedReq := req
 	resolvedReq.Model = resolveAuggieModelAlias(auth, req.Model)
+	spec, structured, err := e.auggieStructuredOutputRequest(originalAuggieOpenAIRequest(req, opts), "text.format")
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	if structured {
+		result, originalPayload, errStructured := e.executeAuggieStructuredResponses(ctx, auth, resolvedReq, opts, spec)
+		if errStructured != nil {
+			return cliproxyexecutor.Response{}, errStructured
+		}
+		return cliproxyexecutor.Response{
+			Payload: translateAuggieStructuredResponses(ctx, payloadRequestedModel(opts, req.Model), originalPayload, result),
+			Headers: result.Headers,
+		}, nil
+	}
 	translated, originalPayload, err := buildAuggieResponsesTranslatedRequest(resolvedReq.Model, req, opts, false)
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
 	}
+	originalPayload = requestPayload
 	builtInRegistry, translated, err := e.prepareAuggieResponsesBuiltInToolBridge(ctx, auth, originalPayload, translated)
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
@@ -1967,12 +2122,31 @@ func suppressAuggieResponsesBuiltInToolCalls(openAIPayload []byte) ([]byte, erro
 }
 
 func (e *AuggieExecutor) executeOpenAIResponsesStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	// The client's request is echoed back in the response, including any emulated parameters.
+	requestPayload := originalAuggieOpenAIRequest(req, opts)
+	req, opts, limits, err := e.prepareAuggieResponsesParameterEmulation(req, opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withAuggieOutputLimits(ctx, limits)
 	resolvedReq := req
 	resolvedReq.Model = resolveAuggieModelAlias(auth, req.Model)
+	spec, structured, err := e.auggieStructuredOutputRequest(originalAuggieOpenAIRequest(req, opts), "text.format")
+	if err != nil {
+		return nil, err
+	}
+	if structured {
+		result, originalPayload, errStructured := e.executeAuggieStructuredResponses(ctx, auth, resolvedReq, opts, spec)
+		if errStructured != nil {
+			return nil, errStructured
+		}
+		return streamAuggieStructuredResponses(ctx, payloadRequestedModel(opts, req.Model), originalPayload, result)
+	}
 	translated, originalPayload, err := buildAuggieResponsesTranslatedRequest(resolvedReq.Model, req, opts, true)
 	if err != nil {
 		return nil, err
 	}
+	originalPayload = requestPayload
 	if auggieResponsesRequestUsesBuiltInToolBridge(originalPayload) {
 		builtInRegistry, translated, err := e.prepareAuggieResponsesBuiltInToolBridge(ctx, auth, originalPayload, translated)
 		if err != nil {
@@ -3542,7 +3716,7 @@ func validateAuggieStructuredOutputFormat(format gjson.Result, field string) err
 		return nil
 	case "json_schema", "json_object":
 		return newAuggieInvalidRequestStatusErr(
-			fmt.Sprintf("%s.type=%q is not supported by Auggie; structured output response formats are not currently supported", field, formatType),
+			fmt.Sprintf("%s.type=%q is not supported by Auggie; structured output response formats require structured-output.emulate to be enabled", field, formatType),
 			field+".type",
 			"invalid_value",
 		)
@@ -2,6 +2,7 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -4083,6 +4084,27 @@ func TestAuggieExecuteStream_RetriesUnauthorizedBeforeFirstByte(t *testing.T) {
 	}
 }
 
+func TestAuggieExecuteStream_RateLimitKeepsRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":"rate limited"}`))
+	}))
+	defer server.Close()
+
+	_, err := executeAuggieStreamForTest(t, context.Background(), newAuggieStreamTestAuth("token-1"), server.URL)
+	var rateLimited statusErrWithHeaders
+	if !errors.As(err, &rateLimited) || rateLimited.StatusCode() != http.StatusTooManyRequests {
+		t.Fatalf("ExecuteStream error = %#v, want a 429 carrying headers", err)
+	}
+	if got := rateLimited.RetryAfter(); got == nil || *got != 30*time.Second {
+		t.Fatalf("RetryAfter() = %v, want 30s", got)
+	}
+	if got := rateLimited.Headers().Get("Retry-After"); got != "30" {
+		t.Fatalf("Retry-After header = %q, want 30", got)
+	}
+}
+
 func TestBuildAuggieSystemPromptCustomizationFallbackPayload_StripsNativeFieldsAndInlinesPrompt(t *testing.T) {
 	translated := []byte(`{
 		"model":"gpt-5-4",
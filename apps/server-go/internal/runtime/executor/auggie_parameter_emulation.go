@@ -0,0 +1,587 @@
+package executor
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+// maxAuggieEmulatedChoices caps n when choices are fanned out to parallel upstream calls.
+const maxAuggieEmulatedChoices = 8
+
+// auggieOutputLimits are stop sequences and an output token budget enforced on the translated
+// OpenAI chat stream because the Auggie upstream cannot honor them.
+type auggieOutputLimits struct {
+	Stop      []string
+	MaxTokens int
+}
+
+func (l auggieOutputLimits) empty() bool {
+	return len(l.Stop) == 0 && l.MaxTokens <= 0
+}
+
+// auggieParameterEmulation is the set of emulated parameters extracted from a request.
+type auggieParameterEmulation struct {
+	Limits auggieOutputLimits
+	N      int
+}
+
+type auggieOutputLimitsContextKey struct{}
+
+// withAuggieOutputLimits attaches output limits to ctx so every OpenAI chat stream opened for the
+// request, including built-in tool loop turns, is cut off by the same rules.
+func withAuggieOutputLimits(ctx context.Context, limits auggieOutputLimits) context.Context {
+	if limits.empty() {
+		return ctx
+	}
+	return context.WithValue(ctx, auggieOutputLimitsContextKey{}, limits)
+}
+
+func auggieOutputLimitsFromContext(ctx context.Context) (auggieOutputLimits, bool) {
+	if ctx == nil {
+		return auggieOutputLimits{}, false
+	}
+	limits, ok := ctx.Value(auggieOutputLimitsContextKey{}).(auggieOutputLimits)
+	return limits, ok && !limits.empty()
+}
+
+func (e *AuggieExecutor) parameterEmulationConfig() config.ParameterEmulationConfig {
+	if e == nil || e.cfg == nil {
+		return config.ParameterEmulationConfig{}
+	}
+	return e.cfg.ParameterEmulation[e.Identifier()]
+}
+
+// prepareAuggieChatParameterEmulation extracts the chat completion parameters enabled for emulation
+// and strips them from the request so capability validation no longer rejects them.
+func (e *AuggieExecutor) prepareAuggieChatParameterEmulation(req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Request, cliproxyexecutor.Options, auggieParameterEmulation, error) {
+	emulation := auggieParameterEmulation{N: 1}
+	cfg := e.parameterEmulationConfig()
+	rawJSON := originalAuggieOpenAIRequest(req, opts)
+	var stripped []string
+
+	if cfg.Stop {
+		if err := validateAuggieOptionalStopField(rawJSON); err != nil {
+			return req, opts, emulation, err
+		}
+		stop := gjson.GetBytes(rawJSON, "stop")
+		if stop.Type == gjson.String {
+			emulation.Limits.Stop = appendAuggieStopSequence(emulation.Limits.Stop, stop.String())
+		} else {
+			for _, item := range stop.Array() {
+				emulation.Limits.Stop = appendAuggieStopSequence(emulation.Limits.Stop, item.String())
+			}
+		}
+		stripped = append(stripped, "stop")
+	}
+
+	if cfg.MaxTokens {
+		for _, field := range []string{"max_completion_tokens", "max_tokens"} {
+			if err := validateAuggieOptionalIntegerRangeField(rawJSON, field, 1, math.MaxInt32); err != nil {
+				return req, opts, emulation, err
+			}
+			if value := gjson.GetBytes(rawJSON, field); value.Type == gjson.Number && emulation.Limits.MaxTokens == 0 {
+				emulation.Limits.MaxTokens = int(value.Int())
+			}
+		}
+		stripped = append(stripped, "max_completion_tokens", "max_tokens")
+	}
+
+	if cfg.N {
+		if err := validateAuggieOptionalIntegerRangeField(rawJSON, "n", 1, maxAuggieEmulatedChoices); err != nil {
+			return req, opts, emulation, err
+		}
+		if value := gjson.GetBytes(rawJSON, "n"); value.Type == gjson.Number {
+			emulation.N = int(value.Int())
+		}
+		stripped = append(stripped, "n")
+	}
+
+	req, opts = stripAuggieEmulatedParameters(req, opts, stripped)
+	return req, opts, emulation, nil
+}
+
+// prepareAuggieResponsesParameterEmulation is the Responses API counterpart of
+// prepareAuggieChatParameterEmulation; only max_output_tokens applies there.
+func (e *AuggieExecutor) prepareAuggieResponsesParameterEmulation(req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Request, cliproxyexecutor.Options, auggieOutputLimits, error) {
+	var limits auggieOutputLimits
+	if !e.parameterEmulationConfig().MaxTokens {
+		return req, opts, limits, nil
+	}
+	rawJSON := originalAuggieOpenAIRequest(req, opts)
+	if err := validateAuggieOptionalIntegerRangeField(rawJSON, "max_output_tokens", 1, math.MaxInt32); err != nil {
+		return req, opts, limits, err
+	}
+	if value := gjson.GetBytes(rawJSON, "max_output_tokens"); value.Type == gjson.Number {
+		limits.MaxTokens = int(value.Int())
+	}
+	req, opts = stripAuggieEmulatedParameters(req, opts, []string{"max_output_tokens"})
+	return req, opts, limits, nil
+}
+
+// withoutUnenforcedClaudeMaxTokens drops max_tokens from a bridged Claude request unless it is
+// emulated. The Messages API requires the field, so rejecting it would make the surface unusable.
+func (e *AuggieExecutor) withoutUnenforcedClaudeMaxTokens(payload []byte) []byte {
+	if e.parameterEmulationConfig().MaxTokens || !gjson.GetBytes(payload, "max_tokens").Exists() {
+		return payload
+	}
+	out, err := sjson.DeleteBytes(payload, "max_tokens")
+	if err != nil {
+		return payload
+	}
+	return out
+}
+
+func appendAuggieStopSequence(stops []string, stop string) []string {
+	if stop == "" {
+		return stops
+	}
+	return append(stops, stop)
+}
+
+func stripAuggieEmulatedParameters(req cliproxyexecutor.Request, opts cliproxyexecutor.Options, fields []string) (cliproxyexecutor.Request, cliproxyexecutor.Options) {
+	for _, field := range fields {
+		if gjson.GetBytes(req.Payload, field).Exists() {
+			req.Payload, _ = sjson.DeleteBytes(req.Payload, field)
+		}
+		if len(opts.OriginalRequest) > 0 && gjson.GetBytes(opts.OriginalRequest, field).Exists() {
+			opts.OriginalRequest, _ = sjson.DeleteBytes(opts.OriginalRequest, field)
+		}
+	}
+	return req, opts
+}
+
+// executeAuggieLimitedStream opens the upstream stream with a cancellable context and enforces the
+// output limits on the translated chunks. Upstream is cancelled as soon as a limit is hit, so the
+// usage it would have reported is replaced by one counted locally from the prompt and the output.
+func (e *AuggieExecutor) executeAuggieLimitedStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, translated []byte, limits auggieOutputLimits, allowRefresh bool) (*cliproxyexecutor.StreamResult, error) {
+	limiter, err := newAuggieOutputLimiter(limits, req.Model)
+	if err != nil {
+		return nil, statusErr{code: http.StatusInternalServerError, msg: "auggie executor: tokenizer unavailable for max_tokens emulation: " + err.Error()}
+	}
+	streamCtx, cancel := context.WithCancel(context.WithValue(ctx, auggieOutputLimitsContextKey{}, nil))
+	source, err := e.executeAuggieStream(streamCtx, auth, req, opts, translated, sdktranslator.FormatOpenAI, allowRefresh)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if source == nil {
+		cancel()
+		return nil, statusErr{code: http.StatusBadGateway, msg: "auggie stream result is nil"}
+	}
+
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer cancel()
+		usageSent := false
+		for chunk := range source.Chunks {
+			if limiter.done {
+				// Drain whatever upstream produced before it noticed the cancellation.
+				continue
+			}
+			if chunk.Err != nil {
+				out <- chunk
+				continue
+			}
+			for _, payload := range limiter.process(chunk.Payload) {
+				usageSent = usageSent || gjson.GetBytes(payload, "usage").IsObject()
+				out <- cliproxyexecutor.StreamChunk{Payload: payload}
+			}
+			if limiter.done {
+				cancel()
+				if !usageSent {
+					if usage := limiter.usageChunk(originalAuggieOpenAIRequest(req, opts)); usage != nil {
+						out <- cliproxyexecutor.StreamChunk{Payload: usage}
+					}
+				}
+			}
+		}
+		if !limiter.done {
+			for _, payload := range limiter.flush() {
+				out <- cliproxyexecutor.StreamChunk{Payload: payload}
+			}
+		}
+	}()
+	return &cliproxyexecutor.StreamResult{Headers: source.Headers, Chunks: out}, nil
+}
+
+// auggieOutputLimiter applies stop sequences and a token budget to choice 0 of OpenAI chat chunks.
+// The tail of the content that could still be the start of a stop sequence is held back so
+// sequences spanning chunk boundaries are caught before any of their bytes reach the client.
+type auggieOutputLimiter struct {
+	limits   auggieOutputLimits
+	codec    tokenizer.Codec
+	holdback int
+	pending  string
+	tokens   int
+	template []byte
+	done     bool
+}
+
+func newAuggieOutputLimiter(limits auggieOutputLimits, model string) (*auggieOutputLimiter, error) {
+	limiter := &auggieOutputLimiter{limits: limits}
+	for _, stop := range limits.Stop {
+		if len(stop)-1 > limiter.holdback {
+			limiter.holdback = len(stop) - 1
+		}
+	}
+	// The codec also counts the forwarded output for the usage reported after a cut-off, but only
+	// the token budget depends on it.
+	codec, err := tokenizerForModel(model)
+	if err != nil && limits.MaxTokens > 0 {
+		return nil, err
+	}
+	limiter.codec = codec
+	return limiter, nil
+}
+
+// usageChunk returns a choice-less chunk carrying usage counted from the prompt in request and
+// the output forwarded so far, or nil when the output cannot be counted.
+func (l *auggieOutputLimiter) usageChunk(request []byte) []byte {
+	if l.codec == nil || len(l.template) == 0 {
+		return nil
+	}
+	prompt, err := countOpenAIChatTokens(l.codec, request)
+	if err != nil {
+		prompt = 0
+	}
+	chunk, _ := sjson.SetRawBytes(l.template, "choices", []byte(`[]`))
+	chunk, _ = sjson.SetBytes(chunk, "usage", map[string]int64{
+		"prompt_tokens":     prompt,
+		"completion_tokens": int64(l.tokens),
+		"total_tokens":      prompt + int64(l.tokens),
+	})
+	return chunk
+}
+
+// process returns the chunks to forward for one upstream chunk. Once a limit is hit the returned
+// chunk carries the truncated content and the emulated finish_reason, and done is set.
+func (l *auggieOutputLimiter) process(payload []byte) [][]byte {
+	if len(payload) > 0 {
+		l.template = payload
+	}
+	content := gjson.GetBytes(payload, "choices.0.delta.content")
+	finishReason := gjson.GetBytes(payload, "choices.0.finish_reason")
+	finished := finishReason.Type == gjson.String && strings.TrimSpace(finishReason.String()) != ""
+	if content.Type != gjson.String && !finished {
+		return [][]byte{payload}
+	}
+
+	l.pending += content.String()
+	text, reason := l.release(finished)
+	if reason != "" {
+		l.done = true
+		return [][]byte{rewriteAuggieLimitedChunk(payload, text, reason, true)}
+	}
+	if content.Type != gjson.String && text == "" {
+		return [][]byte{payload}
+	}
+	return [][]byte{rewriteAuggieLimitedChunk(payload, text, "", false)}
+}
+
+// flush releases held-back content when upstream ended without a finish_reason chunk.
+func (l *auggieOutputLimiter) flush() [][]byte {
+	if l.pending == "" || len(l.template) == 0 {
+		return nil
+	}
+	text, reason := l.release(true)
+	l.done = true
+	chunk, _ := sjson.SetRawBytes(l.template, "choices.0.delta", []byte(`{}`))
+	chunk, _ = sjson.DeleteBytes(chunk, "usage")
+	return [][]byte{rewriteAuggieLimitedChunk(chunk, text, reason, reason != "")}
+}
+
+// release returns the pending content that is safe to forward and the finish_reason to report
+// when a limit cuts the output short.
+func (l *auggieOutputLimiter) release(final bool) (string, string) {
+	text, reason := l.pending, ""
+	if index := l.stopIndex(); index >= 0 {
+		text, reason = l.pending[:index], "stop"
+		l.pending = ""
+	} else if !final && l.holdback > 0 {
+		split := len(l.pending) - l.holdback
+		if split < 0 {
+			split = 0
+		}
+		for split > 0 && !utf8.RuneStart(l.pending[split]) {
+			split--
+		}
+		text, l.pending = l.pending[:split], l.pending[split:]
+	} else {
+		l.pending = ""
+	}
+
+	if truncated, ok := l.spend(text); !ok {
+		return truncated, "length"
+	}
+	return text, reason
+}
+
+func (l *auggieOutputLimiter) stopIndex() int {
+	index := -1
+	for _, stop := range l.limits.Stop {
+		if i := strings.Index(l.pending, stop); i >= 0 && (index < 0 || i < index) {
+			index = i
+		}
+	}
+	return index
+}
+
+// spend charges text against the token budget and returns the prefix that fits when it does not.
+// Without a budget the text is only counted.
+func (l *auggieOutputLimiter) spend(text string) (string, bool) {
+	if l.codec == nil || text == "" {
+		return text, true
+	}
+	ids, _, err := l.codec.Encode(text)
+	if err != nil {
+		return text, true
+	}
+	if l.limits.MaxTokens <= 0 {
+		l.tokens += len(ids)
+		return text, true
+	}
+	remaining := l.limits.MaxTokens - l.tokens
+	if len(ids) <= remaining {
+		l.tokens += len(ids)
+		return text, true
+	}
+	l.tokens = l.limits.MaxTokens
+	if remaining <= 0 {
+		return "", false
+	}
+	truncated, err := l.codec.Decode(ids[:remaining])
+	if err != nil {
+		return "", false
+	}
+	for len(truncated) > 0 && !utf8.ValidString(truncated) {
+		truncated = truncated[:len(truncated)-1]
+	}
+	return truncated, false
+}
+
+func rewriteAuggieLimitedChunk(payload []byte, text, finishReason string, setContent bool) []byte {
+	out := payload
+	if setContent || gjson.GetBytes(payload, "choices.0.delta.content").Exists() || text != "" {
+		out, _ = sjson.SetBytes(out, "choices.0.delta.content", text)
+	}
+	if finishReason != "" {
+		out, _ = sjson.SetBytes(out, "choices.0.finish_reason", finishReason)
+		out, _ = sjson.DeleteBytes(out, "choices.0.delta.tool_calls")
+	}
+	return out
+}
+
+// auggieFanOutUsage merges the usage of parallel choices the way OpenAI reports n > 1: the prompt
+// is counted once and completion tokens are summed.
+type auggieFanOutUsage struct {
+	raw []byte
+}
+
+func (u *auggieFanOutUsage) add(usage gjson.Result) {
+	if !usage.IsObject() {
+		return
+	}
+	if u.raw == nil {
+		u.raw = []byte(usage.Raw)
+		return
+	}
+	completion := gjson.GetBytes(u.raw, "completion_tokens").Int() + usage.Get("completion_tokens").Int()
+	u.raw, _ = sjson.SetBytes(u.raw, "completion_tokens", completion)
+	if reasoning := usage.Get("completion_tokens_details.reasoning_tokens"); reasoning.Exists() {
+		total := gjson.GetBytes(u.raw, "completion_tokens_details.reasoning_tokens").Int() + reasoning.Int()
+		u.raw, _ = sjson.SetBytes(u.raw, "completion_tokens_details.reasoning_tokens", total)
+	}
+	u.raw, _ = sjson.SetBytes(u.raw, "total_tokens", gjson.GetBytes(u.raw, "prompt_tokens").Int()+completion)
+}
+
+// cloneAuggieFanOutAuths gives every parallel call its own copy of the credential because the
+// executor updates auth state in place; mergeAuggieFanOutAuths writes the outcome back afterwards.
+func cloneAuggieFanOutAuths(auth *cliproxyauth.Auth, n int) []*cliproxyauth.Auth {
+	auth.EnsureIndex()
+	auths := make([]*cliproxyauth.Auth, n)
+	for i := range auths {
+		auths[i] = auth.Clone()
+	}
+	return auths
+}
+
+// mergeAuggieFanOutAuths writes the state left by the parallel calls back to auth. A copy that
+// marked the credential unavailable wins so the failure is not lost; otherwise the most recently
+// updated copy, such as one holding a refreshed token, is kept.
+func mergeAuggieFanOutAuths(auth *cliproxyauth.Auth, auths []*cliproxyauth.Auth) {
+	var chosen *cliproxyauth.Auth
+	for _, candidate := range auths {
+		switch {
+		case candidate == nil:
+		case chosen == nil:
+			chosen = candidate
+		case candidate.Unavailable != chosen.Unavailable:
+			if candidate.Unavailable {
+				chosen = candidate
+			}
+		case candidate.UpdatedAt.After(chosen.UpdatedAt):
+			chosen = candidate
+		}
+	}
+	replaceAuggieAuthState(auth, chosen)
+}
+
+// executeAuggieChatChoices runs n independent upstream calls in parallel and merges their
+// single-choice completions into one response with indexed choices.
+func (e *AuggieExecutor) executeAuggieChatChoices(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, n int) (cliproxyexecutor.Response, error) {
+	fanCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	auths := cloneAuggieFanOutAuths(auth, n)
+
+	responses := make([]cliproxyexecutor.Response, n)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := e.executeAuggieChatChoice(fanCtx, auths[i], req, opts)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			responses[i] = resp
+		}(i)
+	}
+	wg.Wait()
+	mergeAuggieFanOutAuths(auth, auths)
+	if firstErr != nil {
+		return cliproxyexecutor.Response{}, firstErr
+	}
+
+	choices := []byte(`[]`)
+	var usage auggieFanOutUsage
+	for i, resp := range responses {
+		choice, _ := sjson.SetBytes([]byte(gjson.GetBytes(resp.Payload, "choices.0").Raw), "index", i)
+		choices, _ = sjson.SetRawBytes(choices, "-1", choice)
+		usage.add(gjson.GetBytes(resp.Payload, "usage"))
+	}
+	payload, err := sjson.SetRawBytes(responses[0].Payload, "choices", choices)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	if usage.raw != nil {
+		payload, _ = sjson.SetRawBytes(payload, "usage", usage.raw)
+	}
+	return cliproxyexecutor.Response{Payload: payload, Headers: responses[0].Headers}, nil
+}
+
+// executeAuggieChatChoicesStream is the streaming counterpart of executeAuggieChatChoices: the
+// parallel streams are interleaved under one completion id with choices indexed by stream, and the
+// merged usage is reported in a final chunk.
+func (e *AuggieExecutor) executeAuggieChatChoicesStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, n int) (*cliproxyexecutor.StreamResult, error) {
+	fanCtx, cancel := context.WithCancel(ctx)
+	auths := cloneAuggieFanOutAuths(auth, n)
+
+	results := make([]*cliproxyexecutor.StreamResult, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = e.executeAuggieChatChoiceStream(fanCtx, auths[i], req, opts)
+		}(i)
+	}
+	wg.Wait()
+	mergeAuggieFanOutAuths(auth, auths)
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		cancel()
+		for _, result := range results {
+			if result != nil {
+				go drainAuggieStreamChunks(result.Chunks)
+			}
+		}
+		return nil, err
+	}
+
+	id := newAuggiePublicChatCompletionID()
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		var (
+			forward  sync.WaitGroup
+			mu       sync.Mutex
+			failed   bool
+			usage    auggieFanOutUsage
+			template []byte
+		)
+		for i, result := range results {
+			forward.Add(1)
+			go func(i int, chunks <-chan cliproxyexecutor.StreamChunk) {
+				defer forward.Done()
+				for chunk := range chunks {
+					mu.Lock()
+					if failed {
+						mu.Unlock()
+						continue
+					}
+					if chunk.Err != nil {
+						failed = true
+						mu.Unlock()
+						cancel()
+						out <- chunk
+						continue
+					}
+					payload := chunk.Payload
+					if u := gjson.GetBytes(payload, "usage"); u.Exists() && u.Type != gjson.Null {
+						usage.add(u)
+						template = payload
+						payload, _ = sjson.DeleteBytes(payload, "usage")
+					}
+					mu.Unlock()
+					if len(gjson.GetBytes(payload, "choices").Array()) == 0 {
+						continue
+					}
+					payload, _ = sjson.SetBytes(payload, "id", id)
+					payload, _ = sjson.SetBytes(payload, "choices.0.index", i)
+					out <- cliproxyexecutor.StreamChunk{Payload: payload}
+				}
+			}(i, result.Chunks)
+		}
+		forward.Wait()
+
+		if failed || usage.raw == nil {
+			return
+		}
+		final, _ := sjson.SetBytes(template, "id", id)
+		final, _ = sjson.SetRawBytes(final, "choices", []byte(`[]`))
+		final, _ = sjson.SetRawBytes(final, "usage", usage.raw)
+		out <- cliproxyexecutor.StreamChunk{Payload: final}
+	}()
+	return &cliproxyexecutor.StreamResult{Headers: results[0].Headers, Chunks: out}, nil
+}
+
+func drainAuggieStreamChunks(chunks <-chan cliproxyexecutor.StreamChunk) {
+	for range chunks {
+	}
+}
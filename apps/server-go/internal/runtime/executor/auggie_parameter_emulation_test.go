@@ -0,0 +1,242 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// newAuggieChunkedServer streams each text fragment as its own ndjson line, followed by a final
+// line carrying token usage.
+func newAuggieChunkedServer(t *testing.T, fragments ...string) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, fragment := range fragments {
+			_, _ = fmt.Fprintf(w, "{\"text\":%q}\n", fragment)
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+		_, _ = fmt.Fprint(w, "{\"text\":\"\",\"nodes\":[{\"id\":0,\"type\":10,\"token_usage\":{\"input_tokens\":12,\"output_tokens\":9}}],\"stop_reason\":\"end_turn\"}\n")
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// newAuggieHangingServer streams the fragments and then holds the response open until the client
+// cancels it, recording the cancellation.
+func newAuggieHangingServer(t *testing.T, fragments ...string) (*httptest.Server, *int32) {
+	t.Helper()
+	var cancelled int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, fragment := range fragments {
+			_, _ = fmt.Fprintf(w, "{\"text\":%q}\n", fragment)
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		select {
+		case <-r.Context().Done():
+			atomic.StoreInt32(&cancelled, 1)
+		case <-time.After(5 * time.Second):
+			_, _ = fmt.Fprint(w, "{\"text\":\"\",\"nodes\":[{\"id\":0,\"type\":10,\"token_usage\":{\"input_tokens\":12,\"output_tokens\":900}}],\"stop_reason\":\"end_turn\"}\n")
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &cancelled
+}
+
+func newAuggieEmulationTestExecutor(emulation config.ParameterEmulationConfig) *AuggieExecutor {
+	return NewAuggieExecutor(&config.Config{SDKConfig: config.SDKConfig{ParameterEmulation: map[string]config.ParameterEmulationConfig{"auggie": emulation}}})
+}
+
+func executeAuggieEmulatedChatStreamForTest(t *testing.T, exec *AuggieExecutor, targetURL, payload string) []string {
+	t.Helper()
+	ctx := context.WithValue(context.Background(), "cliproxy.roundtripper", newAuggieRewriteTransport(t, targetURL))
+	req := cliproxyexecutor.Request{Model: "gpt-5.4", Payload: []byte(payload), Format: sdktranslator.FormatOpenAI}
+	opts := cliproxyexecutor.Options{Stream: true, OriginalRequest: req.Payload, SourceFormat: sdktranslator.FormatOpenAI}
+	result, err := exec.ExecuteStream(ctx, newAuggieStreamTestAuth("token-1"), req, opts)
+	if err != nil {
+		t.Fatalf("ExecuteStream error: %v", err)
+	}
+	var chunks []string
+	for chunk := range result.Chunks {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		chunks = append(chunks, string(chunk.Payload))
+	}
+	return chunks
+}
+
+func auggieStreamedContent(chunks []string, index int) (string, string) {
+	var content strings.Builder
+	finishReason := ""
+	for _, chunk := range chunks {
+		choice := gjson.Get(chunk, fmt.Sprintf("choices.#(index==%d)", index))
+		content.WriteString(choice.Get("delta.content").String())
+		if reason := choice.Get("finish_reason").String(); reason != "" {
+			finishReason = reason
+		}
+	}
+	return content.String(), finishReason
+}
+
+func TestAuggieParameterEmulation_StopSpanningChunks(t *testing.T) {
+	server, _ := newAuggieChunkedServer(t, "alpha be", "ta END", "MARK gamma")
+	exec := newAuggieEmulationTestExecutor(config.ParameterEmulationConfig{Stop: true})
+
+	chunks := executeAuggieEmulatedChatStreamForTest(t, exec, server.URL, `{"messages":[{"role":"user","content":"hi"}],"stop":["ENDMARK"],"stream":true}`)
+	content, finishReason := auggieStreamedContent(chunks, 0)
+	if content != "alpha beta " {
+		t.Fatalf("content = %q, want %q", content, "alpha beta ")
+	}
+	if finishReason != "stop" {
+		t.Fatalf("finish_reason = %q, want stop", finishReason)
+	}
+}
+
+func TestAuggieParameterEmulation_MaxTokensReportsLength(t *testing.T) {
+	server, cancelled := newAuggieHangingServer(t, "one two three", " four five six", " seven eight nine")
+	exec := newAuggieEmulationTestExecutor(config.ParameterEmulationConfig{MaxTokens: true})
+	ctx := context.WithValue(context.Background(), "cliproxy.roundtripper", newAuggieRewriteTransport(t, server.URL))
+	req := cliproxyexecutor.Request{Model: "gpt-5.4", Payload: []byte(`{"messages":[{"role":"user","content":"count"}],"max_tokens":4}`), Format: sdktranslator.FormatOpenAI}
+	opts := cliproxyexecutor.Options{OriginalRequest: req.Payload, SourceFormat: sdktranslator.FormatOpenAI}
+
+	resp, err := exec.Execute(ctx, newAuggieStreamTestAuth("token-1"), req, opts)
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if got := gjson.GetBytes(resp.Payload, "choices.0.message.content").String(); got != "one two three four" {
+		t.Fatalf("content = %q, want the first 4 tokens", got)
+	}
+	if got := gjson.GetBytes(resp.Payload, "choices.0.finish_reason").String(); got != "length" {
+		t.Fatalf("finish_reason = %q, want length", got)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(cancelled) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected upstream to be cancelled once the limit was hit")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := gjson.GetBytes(resp.Payload, "usage.completion_tokens").Int(); got != 4 {
+		t.Fatalf("usage.completion_tokens = %d, want 4 counted locally; payload=%s", got, resp.Payload)
+	}
+	if got := gjson.GetBytes(resp.Payload, "usage.prompt_tokens").Int(); got <= 0 {
+		t.Fatalf("usage.prompt_tokens = %d, want the locally counted prompt; payload=%s", got, resp.Payload)
+	}
+}
+
+func TestAuggieParameterEmulation_ClaudeMaxTokensAccepted(t *testing.T) {
+	server, _ := newAuggieChunkedServer(t, "one two three four")
+	ctx := context.WithValue(context.Background(), "cliproxy.roundtripper", newAuggieRewriteTransport(t, server.URL))
+	req := cliproxyexecutor.Request{Model: "claude-sonnet-4-6", Payload: []byte(`{"max_tokens":2,"messages":[{"role":"user","content":"count"}]}`), Format: sdktranslator.FormatClaude}
+	opts := cliproxyexecutor.Options{OriginalRequest: req.Payload, SourceFormat: sdktranslator.FormatClaude}
+
+	resp, err := newAuggieEmulationTestExecutor(config.ParameterEmulationConfig{}).Execute(ctx, newAuggieStreamTestAuth("token-1"), req, opts)
+	if err != nil {
+		t.Fatalf("Execute without emulation error: %v", err)
+	}
+	if got := gjson.GetBytes(resp.Payload, "content.0.text").String(); got != "one two three four" {
+		t.Fatalf("content = %q, want the full output", got)
+	}
+
+	resp, err = newAuggieEmulationTestExecutor(config.ParameterEmulationConfig{MaxTokens: true}).Execute(ctx, newAuggieStreamTestAuth("token-1"), req, opts)
+	if err != nil {
+		t.Fatalf("Execute with emulation error: %v", err)
+	}
+	if got := gjson.GetBytes(resp.Payload, "stop_reason").String(); got != "max_tokens" {
+		t.Fatalf("stop_reason = %q, want max_tokens; payload=%s", got, resp.Payload)
+	}
+}
+
+func TestMergeAuggieFanOutAuths_KeepsFailureAndLatestUpdate(t *testing.T) {
+	now := time.Now()
+	base := newAuggieStreamTestAuth("token-1")
+	refreshed := base.Clone()
+	refreshed.UpdatedAt = now
+	refreshed.Metadata["access_token"] = "token-2"
+	stale := base.Clone()
+	stale.UpdatedAt = now.Add(-time.Minute)
+
+	merged := base.Clone()
+	mergeAuggieFanOutAuths(merged, []*cliproxyauth.Auth{stale, refreshed})
+	if merged.Metadata["access_token"] != "token-2" {
+		t.Fatalf("expected the most recently updated copy, got %v", merged.Metadata["access_token"])
+	}
+
+	failed := markAuggieAuthUnauthorized(stale, "unauthorized")
+	failed.UpdatedAt = now.Add(-time.Hour)
+	merged = base.Clone()
+	mergeAuggieFanOutAuths(merged, []*cliproxyauth.Auth{refreshed, failed, stale})
+	if !merged.Unavailable || merged.StatusMessage != "unauthorized" {
+		t.Fatalf("expected the failure of one fan-out call to be kept, got %+v", merged)
+	}
+}
+
+func TestAuggieParameterEmulation_NMergesChoices(t *testing.T) {
+	server, calls := newAuggieChunkedServer(t, "hello")
+	exec := newAuggieEmulationTestExecutor(config.ParameterEmulationConfig{N: true})
+	payload := `{"messages":[{"role":"user","content":"hi"}],"n":3}`
+
+	ctx := context.WithValue(context.Background(), "cliproxy.roundtripper", newAuggieRewriteTransport(t, server.URL))
+	req := cliproxyexecutor.Request{Model: "gpt-5.4", Payload: []byte(payload), Format: sdktranslator.FormatOpenAI}
+	opts := cliproxyexecutor.Options{OriginalRequest: req.Payload, SourceFormat: sdktranslator.FormatOpenAI}
+	resp, err := exec.Execute(ctx, newAuggieStreamTestAuth("token-1"), req, opts)
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	choices := gjson.GetBytes(resp.Payload, "choices").Array()
+	if len(choices) != 3 {
+		t.Fatalf("choices = %d, want 3; payload=%s", len(choices), resp.Payload)
+	}
+	for i, choice := range choices {
+		if choice.Get("index").Int() != int64(i) || choice.Get("message.content").String() != "hello" {
+			t.Fatalf("choice %d = %s", i, choice.Raw)
+		}
+	}
+
+	chunks := executeAuggieEmulatedChatStreamForTest(t, exec, server.URL, payload)
+	if got := atomic.LoadInt32(calls); got != 6 {
+		t.Fatalf("upstream calls = %d, want 6", got)
+	}
+	id := gjson.Get(chunks[0], "id").String()
+	for i := 0; i < 3; i++ {
+		if content, _ := auggieStreamedContent(chunks, i); content != "hello" {
+			t.Fatalf("choice %d streamed %q", i, content)
+		}
+	}
+	for _, chunk := range chunks {
+		if gjson.Get(chunk, "id").String() != id {
+			t.Fatalf("expected a shared completion id, got %s", chunk)
+		}
+	}
+}
+
+func TestAuggieParameterEmulation_DisabledStillRejects(t *testing.T) {
+	exec := newAuggieEmulationTestExecutor(config.ParameterEmulationConfig{Stop: true})
+	req := cliproxyexecutor.Request{Model: "gpt-5.4", Payload: []byte(`{"messages":[{"role":"user","content":"hi"}],"n":2}`), Format: sdktranslator.FormatOpenAI}
+	opts := cliproxyexecutor.Options{OriginalRequest: req.Payload, SourceFormat: sdktranslator.FormatOpenAI}
+
+	_, err := exec.Execute(context.Background(), newAuggieStreamTestAuth("token-1"), req, opts)
+	if err == nil {
+		t.Fatal("expected n to be rejected when only stop is emulated")
+	}
+	assertOpenAIErrorJSON(t, err, "n", "invalid_value", "n is not supported by Auggie")
+}
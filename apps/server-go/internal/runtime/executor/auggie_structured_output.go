@@ -0,0 +1,521 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultAuggieStructuredOutputRetries = 2
+	// auggieStructuredOutputDeltaSize is the byte size of synthesized content deltas for validated output.
+	auggieStructuredOutputDeltaSize = 256
+)
+
+// auggieStructuredOutputSpec is a json_schema or json_object format requested by the client.
+type auggieStructuredOutputSpec struct {
+	Type   string
+	Name   string
+	Schema gjson.Result
+}
+
+// auggieStructuredOutputResult is the outcome of an emulated structured output request.
+// Refusal is set when no attempt produced valid output; the payload content then carries the refusal text.
+type auggieStructuredOutputResult struct {
+	Payload []byte
+	Headers http.Header
+	Parsed  string
+	Refusal string
+}
+
+// auggieStructuredOutputRequest returns the structured output format of rawJSON when emulation is enabled.
+// field is response_format for chat completions and text.format for the Responses API.
+func (e *AuggieExecutor) auggieStructuredOutputRequest(rawJSON []byte, field string) (auggieStructuredOutputSpec, bool, error) {
+	if e == nil || e.cfg == nil || !e.cfg.StructuredOutput.Emulate {
+		return auggieStructuredOutputSpec{}, false, nil
+	}
+	format := gjson.GetBytes(rawJSON, field)
+	if !format.IsObject() {
+		return auggieStructuredOutputSpec{}, false, nil
+	}
+	spec := auggieStructuredOutputSpec{Type: strings.ToLower(strings.TrimSpace(format.Get("type").String()))}
+	switch spec.Type {
+	case "json_object":
+		return spec, true, nil
+	case "json_schema":
+	default:
+		return auggieStructuredOutputSpec{}, false, nil
+	}
+
+	// Chat completions nest the schema under json_schema; Responses puts it on the format itself.
+	schemaField := field
+	definition := format
+	if field == "response_format" {
+		schemaField = field + ".json_schema"
+		definition = format.Get("json_schema")
+	}
+	spec.Name = strings.TrimSpace(definition.Get("name").String())
+	spec.Schema = definition.Get("schema")
+	if !spec.Schema.IsObject() {
+		return auggieStructuredOutputSpec{}, false, newAuggieInvalidRequestStatusErr(
+			fmt.Sprintf("%s.schema must be a JSON Schema object", schemaField),
+			schemaField+".schema",
+			"invalid_value",
+		)
+	}
+	return spec, true, nil
+}
+
+func (e *AuggieExecutor) auggieStructuredOutputRetries() int {
+	retries := e.cfg.StructuredOutput.MaxRetries
+	switch {
+	case retries < 0:
+		return 0
+	case retries == 0:
+		return defaultAuggieStructuredOutputRetries
+	default:
+		return retries
+	}
+}
+
+// instructions returns the constraint injected into the conversation in place of the native format.
+func (s auggieStructuredOutputSpec) instructions() string {
+	if s.Type == "json_object" {
+		return "Respond with a single valid JSON object and nothing else. Do not wrap it in code fences and do not add any text before or after it."
+	}
+	var b strings.Builder
+	b.WriteString("Respond with a single JSON value that conforms to the JSON Schema below and nothing else. Do not wrap it in code fences and do not add any text before or after it.")
+	if s.Name != "" {
+		b.WriteString("\nSchema name: ")
+		b.WriteString(s.Name)
+	}
+	b.WriteString("\nJSON Schema:\n")
+	b.WriteString(s.Schema.Raw)
+	return b.String()
+}
+
+// evaluate extracts the JSON value from a model reply and validates it. It returns the JSON text and
+// the violations; an empty violation list means the reply is acceptable.
+func (s auggieStructuredOutputSpec) evaluate(reply string) (string, []string) {
+	candidate := extractAuggieStructuredOutputJSON(reply)
+	if candidate == "" {
+		return "", []string{"the reply does not contain a JSON value"}
+	}
+	value := gjson.Parse(candidate)
+	if s.Type == "json_object" {
+		if !value.IsObject() {
+			return "", []string{"the reply must be a JSON object"}
+		}
+		return candidate, nil
+	}
+	return candidate, util.ValidateJSONSchema(s.Schema, value)
+}
+
+// extractAuggieStructuredOutputJSON returns the JSON value in reply, tolerating code fences and
+// surrounding prose. It returns an empty string when no valid JSON value is found.
+func extractAuggieStructuredOutputJSON(reply string) string {
+	text := strings.TrimSpace(reply)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+			text = text[newline+1:]
+		}
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+	}
+	if text != "" && gjson.Valid(text) {
+		return text
+	}
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return ""
+	}
+	closer := "}"
+	if text[start] == '[' {
+		closer = "]"
+	}
+	end := strings.LastIndex(text, closer)
+	if end <= start {
+		return ""
+	}
+	if candidate := text[start : end+1]; gjson.Valid(candidate) {
+		return candidate
+	}
+	return ""
+}
+
+func auggieStructuredOutputFeedback(violations []string) string {
+	var b strings.Builder
+	b.WriteString("Your previous reply did not satisfy the required JSON format:\n")
+	for _, violation := range violations {
+		b.WriteString("- ")
+		b.WriteString(violation)
+		b.WriteByte('\n')
+	}
+	b.WriteString("Reply again with only the corrected JSON.")
+	return b.String()
+}
+
+func auggieStructuredOutputRefusal(attempts int, violations []string) string {
+	message := fmt.Sprintf("Unable to produce output matching the requested response format after %d attempts", attempts)
+	if len(violations) > 0 {
+		message += ": " + strings.Join(violations, "; ")
+	}
+	return message
+}
+
+// runAuggieStructuredOutputLoop executes payload, validates the reply and retries with the validation
+// errors as feedback. Replies that call tools are returned unchanged because the format only applies
+// to the final answer. Token usage is summed over all attempts.
+func (e *AuggieExecutor) runAuggieStructuredOutputLoop(
+	spec auggieStructuredOutputSpec,
+	payload []byte,
+	attempt func(payload []byte) ([]byte, http.Header, error),
+	appendFeedback func(payload []byte, reply, feedback string) ([]byte, error),
+) (auggieStructuredOutputResult, error) {
+	retries := e.auggieStructuredOutputRetries()
+	var usage [3]int64
+	for attemptIndex := 0; ; attemptIndex++ {
+		openAIPayload, headers, err := attempt(payload)
+		if err != nil {
+			return auggieStructuredOutputResult{}, err
+		}
+		for i, field := range []string{"prompt_tokens", "completion_tokens", "total_tokens"} {
+			usage[i] += gjson.GetBytes(openAIPayload, "usage."+field).Int()
+		}
+		if attemptIndex > 0 && gjson.GetBytes(openAIPayload, "usage").IsObject() {
+			for i, field := range []string{"prompt_tokens", "completion_tokens", "total_tokens"} {
+				openAIPayload, _ = sjson.SetBytes(openAIPayload, "usage."+field, usage[i])
+			}
+		}
+
+		result := auggieStructuredOutputResult{Payload: openAIPayload, Headers: headers}
+		if toolCalls := gjson.GetBytes(openAIPayload, "choices.0.message.tool_calls"); toolCalls.IsArray() && len(toolCalls.Array()) > 0 {
+			return result, nil
+		}
+
+		reply := gjson.GetBytes(openAIPayload, "choices.0.message.content").String()
+		parsed, violations := spec.evaluate(reply)
+		if len(violations) == 0 {
+			result.Parsed = parsed
+			result.Payload, err = sjson.SetBytes(openAIPayload, "choices.0.message.content", parsed)
+			return result, err
+		}
+		if attemptIndex >= retries {
+			log.Warnf("auggie structured output: giving up after %d attempts: %s", attemptIndex+1, strings.Join(violations, "; "))
+			result.Refusal = auggieStructuredOutputRefusal(attemptIndex+1, violations)
+			result.Payload, err = sjson.SetBytes(openAIPayload, "choices.0.message.content", result.Refusal)
+			return result, err
+		}
+		log.Debugf("auggie structured output: attempt %d failed validation: %s", attemptIndex+1, strings.Join(violations, "; "))
+		payload, err = appendFeedback(payload, reply, auggieStructuredOutputFeedback(violations))
+		if err != nil {
+			return auggieStructuredOutputResult{}, err
+		}
+	}
+}
+
+// executeAuggieStructuredChat emulates response_format for /v1/chat/completions and returns the final
+// chat.completion payload with message.parsed on success or message.refusal when validation never passed.
+func (e *AuggieExecutor) executeAuggieStructuredChat(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, spec auggieStructuredOutputSpec) ([]byte, http.Header, error) {
+	requestRawJSON := originalAuggieOpenAIRequest(req, opts)
+	payload, err := sjson.DeleteBytes(requestRawJSON, "response_format")
+	if err != nil {
+		return nil, nil, err
+	}
+	messages := []string{}
+	if instruction, errMarshal := json.Marshal(map[string]string{"role": "system", "content": spec.instructions()}); errMarshal == nil {
+		messages = append(messages, string(instruction))
+	}
+	gjson.GetBytes(payload, "messages").ForEach(func(_, message gjson.Result) bool {
+		messages = append(messages, message.Raw)
+		return true
+	})
+	if payload, err = sjson.SetRawBytes(payload, "messages", []byte("["+strings.Join(messages, ",")+"]")); err != nil {
+		return nil, nil, err
+	}
+
+	attempt := func(payload []byte) ([]byte, http.Header, error) {
+		attemptReq := req
+		attemptReq.Payload = payload
+		attemptOpts := opts
+		attemptOpts.OriginalRequest = payload
+		streamResult, errStream := e.executeAuggieOpenAIChatStream(ctx, auth, attemptReq, attemptOpts)
+		if errStream != nil {
+			return nil, nil, errStream
+		}
+		if streamResult == nil {
+			return nil, nil, statusErr{code: http.StatusBadGateway, msg: "auggie stream result is nil"}
+		}
+		openAIPayload, errCollect := collectAuggieOpenAINonStream(streamResult.Chunks, payloadRequestedModel(opts, req.Model))
+		return openAIPayload, streamResult.Headers, errCollect
+	}
+	appendFeedback := func(payload []byte, reply, feedback string) ([]byte, error) {
+		payload, errSet := sjson.SetBytes(payload, "messages.-1", map[string]string{"role": "assistant", "content": reply})
+		if errSet != nil {
+			return nil, errSet
+		}
+		return sjson.SetBytes(payload, "messages.-1", map[string]string{"role": "user", "content": feedback})
+	}
+
+	result, err := e.runAuggieStructuredOutputLoop(spec, payload, attempt, appendFeedback)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := result.Payload
+	switch {
+	case result.Refusal != "":
+		out, _ = sjson.SetBytes(out, "choices.0.message.content", nil)
+		out, _ = sjson.SetBytes(out, "choices.0.message.refusal", result.Refusal)
+		out, _ = sjson.SetBytes(out, "choices.0.finish_reason", "stop")
+	case result.Parsed != "":
+		out, _ = sjson.SetRawBytes(out, "choices.0.message.parsed", []byte(result.Parsed))
+		out, _ = sjson.SetBytes(out, "choices.0.message.refusal", nil)
+	}
+	out, err = appendAuggieOpenAIChatCompletionMetadata(out, requestRawJSON)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, result.Headers, nil
+}
+
+// streamAuggieStructuredChat emits a validated chat.completion payload as chat.completion.chunk deltas.
+func streamAuggieStructuredChat(openAIPayload []byte, headers http.Header) *cliproxyexecutor.StreamResult {
+	chunks := synthesizeAuggieStructuredChatChunks(openAIPayload)
+	out := make(chan cliproxyexecutor.StreamChunk, len(chunks))
+	for _, chunk := range chunks {
+		out <- cliproxyexecutor.StreamChunk{Payload: chunk}
+	}
+	close(out)
+	return &cliproxyexecutor.StreamResult{Headers: headers, Chunks: out}
+}
+
+func synthesizeAuggieStructuredChatChunks(openAIPayload []byte) [][]byte {
+	root := gjson.ParseBytes(openAIPayload)
+	message := root.Get("choices.0.message")
+	newChunk := func(delta map[string]any, finishReason any) []byte {
+		chunk := map[string]any{
+			"id":      root.Get("id").String(),
+			"object":  "chat.completion.chunk",
+			"created": root.Get("created").Int(),
+			"model":   root.Get("model").String(),
+			"choices": []map[string]any{{"index": 0, "delta": delta, "finish_reason": finishReason}},
+		}
+		if finishReason != nil {
+			if usage := root.Get("usage"); usage.IsObject() {
+				chunk["usage"] = usage.Value()
+			}
+		}
+		raw, _ := json.Marshal(chunk)
+		return raw
+	}
+
+	chunks := [][]byte{newChunk(map[string]any{"role": "assistant", "content": ""}, nil)}
+	if refusal := message.Get("refusal"); refusal.Type == gjson.String {
+		chunks = append(chunks, newChunk(map[string]any{"refusal": refusal.String()}, nil))
+	} else if toolCalls := message.Get("tool_calls"); toolCalls.IsArray() && len(toolCalls.Array()) > 0 {
+		calls := make([]any, 0, len(toolCalls.Array()))
+		for index, call := range toolCalls.Array() {
+			value, _ := call.Value().(map[string]any)
+			if value != nil {
+				value["index"] = index
+			}
+			calls = append(calls, value)
+		}
+		chunks = append(chunks, newChunk(map[string]any{"tool_calls": calls}, nil))
+	} else {
+		for _, piece := range splitAuggieStructuredOutputDeltas(message.Get("content").String()) {
+			chunks = append(chunks, newChunk(map[string]any{"content": piece}, nil))
+		}
+	}
+
+	finishReason := root.Get("choices.0.finish_reason").String()
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+	return append(chunks, newChunk(map[string]any{}, finishReason))
+}
+
+// splitAuggieStructuredOutputDeltas splits text into deltas without breaking UTF-8 sequences.
+func splitAuggieStructuredOutputDeltas(text string) []string {
+	var pieces []string
+	for len(text) > auggieStructuredOutputDeltaSize {
+		cut := auggieStructuredOutputDeltaSize
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			cut = auggieStructuredOutputDeltaSize
+		}
+		pieces = append(pieces, text[:cut])
+		text = text[cut:]
+	}
+	if text != "" {
+		pieces = append(pieces, text)
+	}
+	return pieces
+}
+
+// executeAuggieStructuredResponses emulates text.format for /v1/responses. The returned payload is in
+// chat completions shape, ready for the Responses translator; originalPayload is the client request.
+func (e *AuggieExecutor) executeAuggieStructuredResponses(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, spec auggieStructuredOutputSpec) (auggieStructuredOutputResult, []byte, error) {
+	originalPayload := req.Payload
+	if len(opts.OriginalRequest) > 0 {
+		originalPayload = opts.OriginalRequest
+	}
+	payload, err := sjson.DeleteBytes(originalPayload, "text.format")
+	if err != nil {
+		return auggieStructuredOutputResult{}, originalPayload, err
+	}
+	instructions := spec.instructions()
+	if existing := strings.TrimSpace(gjson.GetBytes(payload, "instructions").String()); existing != "" {
+		instructions = existing + "\n\n" + instructions
+	}
+	if payload, err = sjson.SetBytes(payload, "instructions", instructions); err != nil {
+		return auggieStructuredOutputResult{}, originalPayload, err
+	}
+
+	attempt := func(payload []byte) ([]byte, http.Header, error) {
+		attemptReq := req
+		attemptReq.Payload = payload
+		attemptOpts := opts
+		attemptOpts.OriginalRequest = payload
+		translated, requestRawJSON, errBuild := buildAuggieResponsesTranslatedRequest(req.Model, attemptReq, attemptOpts, false)
+		if errBuild != nil {
+			return nil, nil, errBuild
+		}
+		builtInRegistry, translated, errBridge := e.prepareAuggieResponsesBuiltInToolBridge(ctx, auth, requestRawJSON, translated)
+		if errBridge != nil {
+			return nil, nil, errBridge
+		}
+		openAIPayload, headers, errTurn := e.executeAuggieResponsesTurn(ctx, auth, attemptReq, attemptOpts, translated)
+		if errTurn != nil {
+			return nil, nil, errTurn
+		}
+		return e.completeAuggieResponsesBuiltInToolLoop(ctx, auth, attemptReq, attemptOpts, requestRawJSON, translated, builtInRegistry, openAIPayload, headers)
+	}
+	appendFeedback := func(payload []byte, reply, feedback string) ([]byte, error) {
+		if input := gjson.GetBytes(payload, "input"); input.Type == gjson.String {
+			var errSet error
+			payload, errSet = sjson.SetBytes(payload, "input", []map[string]string{{"role": "user", "content": input.String()}})
+			if errSet != nil {
+				return nil, errSet
+			}
+		}
+		payload, errSet := sjson.SetBytes(payload, "input.-1", map[string]string{"role": "assistant", "content": reply})
+		if errSet != nil {
+			return nil, errSet
+		}
+		return sjson.SetBytes(payload, "input.-1", map[string]string{"role": "user", "content": feedback})
+	}
+
+	result, err := e.runAuggieStructuredOutputLoop(spec, payload, attempt, appendFeedback)
+	return result, originalPayload, err
+}
+
+// streamAuggieStructuredResponses emits the buffered Responses events for a structured output result.
+func streamAuggieStructuredResponses(ctx context.Context, responseModel string, originalPayload []byte, result auggieStructuredOutputResult) (*cliproxyexecutor.StreamResult, error) {
+	stream, err := streamAuggieBufferedResponsesPayload(ctx, responseModel, originalPayload, result.Payload, result.Headers)
+	if err != nil || result.Refusal == "" {
+		return stream, err
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		for chunk := range stream.Chunks {
+			if chunk.Err == nil {
+				chunk.Payload = rewriteAuggieResponsesRefusal(chunk.Payload)
+			}
+			out <- chunk
+		}
+	}()
+	return &cliproxyexecutor.StreamResult{Headers: stream.Headers, Chunks: out}, nil
+}
+
+// rewriteAuggieResponsesRefusal turns output_text content into refusal content in a Responses object or
+// SSE event, renaming response.output_text.* events to their response.refusal.* counterparts.
+func rewriteAuggieResponsesRefusal(payload []byte) []byte {
+	prefix := []byte(nil)
+	data := payload
+	if bytes.HasPrefix(payload, []byte("event:")) {
+		newline := bytes.IndexByte(payload, '\n')
+		if newline < 0 {
+			return payload
+		}
+		event := bytes.TrimSpace(bytes.TrimPrefix(payload[:newline], []byte("event:")))
+		event = bytes.Replace(event, []byte("response.output_text."), []byte("response.refusal."), 1)
+		prefix = append(append([]byte("event: "), event...), []byte("\ndata: ")...)
+		data = bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(payload[newline+1:]), []byte("data:")))
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return payload
+	}
+	value = rewriteAuggieResponsesRefusalValue(value)
+	rewritten, err := json.Marshal(value)
+	if err != nil {
+		return payload
+	}
+	return append(prefix, rewritten...)
+}
+
+func rewriteAuggieResponsesRefusalValue(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		switch typed["type"] {
+		case "output_text":
+			return map[string]any{"type": "refusal", "refusal": typed["text"]}
+		case "response.output_text.delta":
+			typed["type"] = "response.refusal.delta"
+			delete(typed, "logprobs")
+		case "response.output_text.done":
+			typed["type"] = "response.refusal.done"
+			typed["refusal"] = typed["text"]
+			delete(typed, "text")
+			delete(typed, "logprobs")
+		}
+		for key, child := range typed {
+			typed[key] = rewriteAuggieResponsesRefusalValue(child)
+		}
+		return typed
+	case []any:
+		for i, child := range typed {
+			typed[i] = rewriteAuggieResponsesRefusalValue(child)
+		}
+		return typed
+	default:
+		return value
+	}
+}
+
+// translateAuggieStructuredResponses converts a structured output result into a Responses object.
+func translateAuggieStructuredResponses(ctx context.Context, responseModel string, originalPayload []byte, result auggieStructuredOutputResult) []byte {
+	var param any
+	translated := []byte(sdktranslator.TranslateNonStream(
+		ctx,
+		sdktranslator.FormatOpenAI,
+		sdktranslator.FormatOpenAIResponse,
+		responseModel,
+		originalPayload,
+		originalPayload,
+		result.Payload,
+		&param,
+	))
+	if result.Refusal != "" {
+		translated = rewriteAuggieResponsesRefusal(translated)
+	}
+	storeAuggieResponsesStateForFinalResponseID(originalPayload, result.Payload, translated)
+	return translated
+}
@@ -0,0 +1,138 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+const testAuggieStructuredChatPayload = `{
+	"messages":[{"role":"user","content":"where am I?"}],
+	"response_format":{
+		"type":"json_schema",
+		"json_schema":{
+			"name":"pwd_result",
+			"schema":{"type":"object","properties":{"cwd":{"type":"string"}},"required":["cwd"],"additionalProperties":false}
+		}
+	}
+}`
+
+// newAuggieStructuredOutputServer replies with replies[i] to the i-th upstream request and records the bodies.
+func newAuggieStructuredOutputServer(t *testing.T, replies ...string) (*httptest.Server, *[]string, *int32) {
+	t.Helper()
+	var calls int32
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		index := int(atomic.AddInt32(&calls, 1)) - 1
+		if index >= len(replies) {
+			index = len(replies) - 1
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = fmt.Fprintf(w, "{\"text\":%q,\"stop_reason\":\"end_turn\"}\n", replies[index])
+	}))
+	t.Cleanup(server.Close)
+	return server, &bodies, &calls
+}
+
+func executeAuggieStructuredForTest(t *testing.T, targetURL string, format sdktranslator.Format, stream bool, payload string, cfg config.StructuredOutputConfig) (cliproxyexecutor.Response, []string, error) {
+	t.Helper()
+
+	exec := NewAuggieExecutor(&config.Config{SDKConfig: config.SDKConfig{StructuredOutput: cfg}})
+	ctx := context.WithValue(context.Background(), "cliproxy.roundtripper", newAuggieRewriteTransport(t, targetURL))
+	req := cliproxyexecutor.Request{Model: "gpt-5.4", Payload: []byte(payload), Format: format}
+	opts := cliproxyexecutor.Options{Stream: stream, OriginalRequest: req.Payload, SourceFormat: format}
+	if !stream {
+		resp, err := exec.Execute(ctx, newAuggieStreamTestAuth("token-1"), req, opts)
+		return resp, nil, err
+	}
+	result, err := exec.ExecuteStream(ctx, newAuggieStreamTestAuth("token-1"), req, opts)
+	if err != nil {
+		return cliproxyexecutor.Response{}, nil, err
+	}
+	var chunks []string
+	for chunk := range result.Chunks {
+		if chunk.Err != nil {
+			return cliproxyexecutor.Response{}, chunks, chunk.Err
+		}
+		chunks = append(chunks, string(chunk.Payload))
+	}
+	return cliproxyexecutor.Response{}, chunks, nil
+}
+
+func TestAuggieStructuredOutput_ChatRetriesWithValidationFeedback(t *testing.T) {
+	server, bodies, calls := newAuggieStructuredOutputServer(t, "Sure! You are in /tmp.", "```json\n{\"cwd\":\"/tmp\"}\n```")
+
+	resp, _, err := executeAuggieStructuredForTest(t, server.URL, sdktranslator.FormatOpenAI, false, testAuggieStructuredChatPayload, config.StructuredOutputConfig{Emulate: true})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Fatalf("upstream calls = %d, want 2", got)
+	}
+	if !strings.Contains((*bodies)[0], "pwd_result") {
+		t.Fatalf("expected schema instructions in first request, got %s", (*bodies)[0])
+	}
+	if !strings.Contains((*bodies)[1], "did not satisfy the required JSON format") {
+		t.Fatalf("expected validation feedback in retry, got %s", (*bodies)[1])
+	}
+	if got := gjson.GetBytes(resp.Payload, "choices.0.message.content").String(); got != `{"cwd":"/tmp"}` {
+		t.Fatalf("message.content = %q", got)
+	}
+	if got := gjson.GetBytes(resp.Payload, "choices.0.message.parsed.cwd").String(); got != "/tmp" {
+		t.Fatalf("message.parsed.cwd = %q, want /tmp; payload=%s", got, resp.Payload)
+	}
+}
+
+func TestAuggieStructuredOutput_ChatStreamEmitsValidatedDeltas(t *testing.T) {
+	server, _, _ := newAuggieStructuredOutputServer(t, `{"cwd":"/srv"}`)
+
+	_, chunks, err := executeAuggieStructuredForTest(t, server.URL, sdktranslator.FormatOpenAI, true, testAuggieStructuredChatPayload, config.StructuredOutputConfig{Emulate: true})
+	if err != nil {
+		t.Fatalf("ExecuteStream error: %v", err)
+	}
+	var content strings.Builder
+	for _, chunk := range chunks {
+		content.WriteString(gjson.Get(chunk, "choices.0.delta.content").String())
+	}
+	if content.String() != `{"cwd":"/srv"}` {
+		t.Fatalf("streamed content = %q", content.String())
+	}
+	if got := gjson.Get(chunks[len(chunks)-1], "choices.0.finish_reason").String(); got != "stop" {
+		t.Fatalf("final finish_reason = %q, want stop", got)
+	}
+}
+
+func TestAuggieStructuredOutput_ResponsesRefusesAfterRetries(t *testing.T) {
+	server, _, calls := newAuggieStructuredOutputServer(t, `{"dir":"/tmp"}`)
+
+	resp, _, err := executeAuggieStructuredForTest(t, server.URL, sdktranslator.FormatOpenAIResponse, false, `{
+		"model":"gpt-5.4",
+		"input":"where am I?",
+		"text":{"format":{"type":"json_schema","name":"pwd_result","schema":{"type":"object","properties":{"cwd":{"type":"string"}},"required":["cwd"]}}}
+	}`, config.StructuredOutputConfig{Emulate: true, MaxRetries: 1})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Fatalf("upstream calls = %d, want 2", got)
+	}
+	part := gjson.GetBytes(resp.Payload, `output.#(type=="message").content.0`)
+	if part.Get("type").String() != "refusal" || !strings.Contains(part.Get("refusal").String(), `missing required property "cwd"`) {
+		t.Fatalf("expected refusal content part, got %s", gjson.GetBytes(resp.Payload, "output").Raw)
+	}
+	if got := gjson.GetBytes(resp.Payload, "text.format.type").String(); got != "json_schema" {
+		t.Fatalf("text.format.type = %q, want the requested format echoed", got)
+	}
+}
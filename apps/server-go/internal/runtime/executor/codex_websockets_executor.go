@@ -19,7 +19,9 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/proxypool"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upstreamtls"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -750,10 +752,19 @@ func newProxyAwareWebsocketDialer(cfg *config.Config, auth *cliproxyauth.Auth) *
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
 	}
+	if auth != nil {
+		dialer.TLSClientConfig = upstreamtls.ForAuth(auth.Provider, auth.Attributes, auth.Metadata).TLSConfig()
+	} else {
+		dialer.TLSClientConfig = upstreamtls.Resolve("", "").TLSConfig()
+	}
 
 	proxyURL := ""
 	if auth != nil {
-		proxyURL = strings.TrimSpace(auth.ProxyURL)
+		if assignment, ok := proxypool.Resolve(auth.ID, auth.Provider, auth.ProxyURL); ok {
+			proxyURL = assignment.URL
+		} else if !proxypool.IsPoolRef(auth.ProxyURL) {
+			proxyURL = strings.TrimSpace(auth.ProxyURL)
+		}
 	}
 	if proxyURL == "" && cfg != nil {
 		proxyURL = strings.TrimSpace(cfg.ProxyURL)
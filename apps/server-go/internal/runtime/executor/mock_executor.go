@@ -0,0 +1,421 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	mockDefaultChunkSize = 16
+	// mockCreated is the fixed "created" timestamp of mock replies, keeping them byte-for-byte stable.
+	mockCreated = 1735689600
+)
+
+// MockExecutor answers requests locally with the replies scripted in the mock-provider config.
+// Requests are translated to OpenAI chat completions and replies are translated back, so every
+// client surface is served. Replies depend only on the request and on the number of requests
+// the model has served for the credential.
+type MockExecutor struct {
+	cfg *config.Config
+
+	mu    sync.Mutex
+	state map[string]*mockModelState
+}
+
+// mockModelState counts requests and injected faults for one credential and model.
+type mockModelState struct {
+	requests int
+	fired    []int
+}
+
+// mockReply is the planned reply to one request.
+type mockReply struct {
+	id              string
+	model           string
+	text            string
+	toolCalls       []config.MockToolCall
+	usage           usage.Detail
+	chunkSize       int
+	ttft            time.Duration
+	latency         time.Duration
+	disconnectAfter int
+}
+
+// NewMockExecutor creates a mock executor reading its scripts from cfg.
+func NewMockExecutor(cfg *config.Config) *MockExecutor {
+	return &MockExecutor{cfg: cfg, state: make(map[string]*mockModelState)}
+}
+
+// Identifier returns the executor identifier.
+func (e *MockExecutor) Identifier() string { return "mock" }
+
+// PrepareRequest is a no-op; mock credentials carry no secrets.
+func (e *MockExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
+
+// HttpRequest is not supported because the mock provider has no upstream.
+func (e *MockExecutor) HttpRequest(_ context.Context, _ *cliproxyauth.Auth, _ *http.Request) (*http.Response, error) {
+	return nil, statusErr{code: http.StatusNotImplemented, msg: "mock executor: raw HTTP requests are not supported"}
+}
+
+// Refresh is a no-op for mock credentials.
+func (e *MockExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	return auth, nil
+}
+
+// Execute returns the scripted reply after its full simulated duration.
+func (e *MockExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	baseModel := thinking.ParseSuffix(req.Model).ModelName
+
+	reporter := newUsageReporter(ctx, e.Identifier(), baseModel, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FormatOpenAI
+	translated := sdktranslator.TranslateRequest(from, to, baseModel, req.Payload, false)
+	reply, err := e.plan(auth, baseModel, translated)
+	if err != nil {
+		return resp, err
+	}
+	chunks := reply.streamChunks()
+	if err = mockWait(ctx, reply.ttft+time.Duration(len(chunks)-1)*reply.latency); err != nil {
+		return resp, err
+	}
+	body := reply.completion()
+	reporter.publish(ctx, reply.usage)
+	var param any
+	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, opts.OriginalRequest, translated, body, &param)
+	return cliproxyexecutor.Response{Payload: []byte(out)}, nil
+}
+
+// ExecuteStream streams the scripted reply in chunks with the configured TTFT and latency.
+func (e *MockExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (_ *cliproxyexecutor.StreamResult, err error) {
+	baseModel := thinking.ParseSuffix(req.Model).ModelName
+
+	reporter := newUsageReporter(ctx, e.Identifier(), baseModel, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FormatOpenAI
+	translated := sdktranslator.TranslateRequest(from, to, baseModel, req.Payload, true)
+	reply, err := e.plan(auth, baseModel, translated)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		var param any
+		send := func(line string) bool {
+			for _, chunk := range sdktranslator.TranslateStream(ctx, to, from, req.Model, opts.OriginalRequest, translated, []byte(line), &param) {
+				select {
+				case out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk)}:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		}
+		for i, chunk := range reply.streamChunks() {
+			if reply.disconnectAfter > 0 && i == reply.disconnectAfter {
+				reporter.publishFailure(ctx)
+				errDisconnect := fmt.Errorf("mock executor: stream disconnected after %d chunks: %w", i, io.ErrUnexpectedEOF)
+				select {
+				case out <- cliproxyexecutor.StreamChunk{Err: errDisconnect}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			delay := reply.latency
+			if i == 0 {
+				delay = reply.ttft
+			}
+			if errWait := mockWait(ctx, delay); errWait != nil {
+				reporter.publishFailure(ctx)
+				return
+			}
+			if !send("data: " + string(chunk)) {
+				return
+			}
+		}
+		reporter.publish(ctx, reply.usage)
+		send("data: [DONE]")
+	}()
+	return &cliproxyexecutor.StreamResult{Headers: http.Header{"Content-Type": {"text/event-stream"}}, Chunks: out}, nil
+}
+
+// CountTokens returns the same input token count the mock model would report.
+func (e *MockExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	baseModel := thinking.ParseSuffix(req.Model).ModelName
+	model, err := e.resolveModel(auth, baseModel)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	from := opts.SourceFormat
+	to := sdktranslator.FormatOpenAI
+	translated := sdktranslator.TranslateRequest(from, to, baseModel, req.Payload, false)
+	count := model.Usage.InputTokens
+	if count == 0 {
+		count = mockEstimateTokens(mockPromptText(translated))
+	}
+	usageJSON := buildOpenAIUsageJSON(count)
+	translatedUsage := sdktranslator.TranslateTokenCount(ctx, to, from, count, usageJSON)
+	return cliproxyexecutor.Response{Payload: []byte(translatedUsage)}, nil
+}
+
+// resolveModel finds the scripted model for the credential.
+func (e *MockExecutor) resolveModel(auth *cliproxyauth.Auth, model string) (config.MockModel, error) {
+	var name string
+	if auth != nil && auth.Attributes != nil {
+		name = auth.Attributes["mock_name"]
+	}
+	if e.cfg != nil {
+		for i := range e.cfg.MockProvider {
+			entry := &e.cfg.MockProvider[i]
+			if !strings.EqualFold(strings.TrimSpace(entry.Name), name) {
+				continue
+			}
+			for _, candidate := range entry.Models {
+				if strings.EqualFold(strings.TrimSpace(candidate.Name), model) {
+					return candidate, nil
+				}
+			}
+		}
+	}
+	return config.MockModel{}, statusErr{code: http.StatusNotFound, msg: fmt.Sprintf("mock executor: model %q is not configured for credential %q", model, name)}
+}
+
+// plan counts the request and returns either the injected fault or the reply to send.
+func (e *MockExecutor) plan(auth *cliproxyauth.Auth, modelName string, translated []byte) (*mockReply, error) {
+	model, err := e.resolveModel(auth, modelName)
+	if err != nil {
+		return nil, err
+	}
+	authID := ""
+	if auth != nil {
+		authID = auth.ID
+	}
+
+	e.mu.Lock()
+	key := authID + "|" + strings.ToLower(modelName)
+	state := e.state[key]
+	if state == nil {
+		state = &mockModelState{}
+		e.state[key] = state
+	}
+	state.requests++
+	n := state.requests
+	if len(state.fired) != len(model.Faults) {
+		state.fired = make([]int, len(model.Faults))
+	}
+	var fault *config.MockFault
+	for i := range model.Faults {
+		f := &model.Faults[i]
+		if f.Every > 1 && n%f.Every != 0 {
+			continue
+		}
+		if f.Times > 0 && state.fired[i] >= f.Times {
+			continue
+		}
+		state.fired[i]++
+		fault = f
+		break
+	}
+	e.mu.Unlock()
+
+	if fault != nil {
+		return nil, mockFaultError(fault)
+	}
+
+	reply := &mockReply{
+		id:              fmt.Sprintf("chatcmpl-mock-%d", n),
+		model:           modelName,
+		toolCalls:       model.ToolCalls,
+		chunkSize:       model.ChunkSize,
+		ttft:            time.Duration(model.TTFTMs) * time.Millisecond,
+		latency:         time.Duration(model.LatencyMs) * time.Millisecond,
+		disconnectAfter: model.DisconnectAfter,
+	}
+	if reply.chunkSize <= 0 {
+		reply.chunkSize = mockDefaultChunkSize
+	}
+	if strings.EqualFold(strings.TrimSpace(model.Mode), config.MockModeFixed) {
+		reply.text = model.Text
+	} else {
+		reply.text = mockLastUserText(translated)
+	}
+	reply.usage.InputTokens = model.Usage.InputTokens
+	if reply.usage.InputTokens == 0 {
+		reply.usage.InputTokens = mockEstimateTokens(mockPromptText(translated))
+	}
+	reply.usage.OutputTokens = model.Usage.OutputTokens
+	if reply.usage.OutputTokens == 0 {
+		output := reply.text
+		for _, call := range reply.toolCalls {
+			output += call.Name + call.Arguments
+		}
+		reply.usage.OutputTokens = mockEstimateTokens(output)
+	}
+	reply.usage.TotalTokens = reply.usage.InputTokens + reply.usage.OutputTokens
+	return reply, nil
+}
+
+func mockFaultError(fault *config.MockFault) error {
+	message := fault.Message
+	if message == "" {
+		message = http.StatusText(fault.Status)
+	}
+	body := `{"error":{"message":"","type":"mock_fault","code":0}}`
+	body, _ = sjson.Set(body, "error.message", message)
+	body, _ = sjson.Set(body, "error.code", fault.Status)
+	err := statusErrWithHeaders{statusErr: statusErr{code: fault.Status, msg: body}}
+	if fault.RetryAfter > 0 {
+		retryAfter := time.Duration(fault.RetryAfter) * time.Second
+		err.retryAfter = &retryAfter
+		err.headers = http.Header{"Retry-After": {strconv.Itoa(fault.RetryAfter)}}
+	}
+	return err
+}
+
+// completion renders the reply as an OpenAI chat completion.
+func (r *mockReply) completion() []byte {
+	out := []byte(`{"id":"","object":"chat.completion","created":0,"model":"","choices":[{"index":0,"message":{"role":"assistant","content":""},"finish_reason":""}]}`)
+	out, _ = sjson.SetBytes(out, "id", r.id)
+	out, _ = sjson.SetBytes(out, "created", mockCreated)
+	out, _ = sjson.SetBytes(out, "model", r.model)
+	out, _ = sjson.SetBytes(out, "choices.0.message.content", r.text)
+	for i, call := range r.toolCalls {
+		out, _ = sjson.SetRawBytes(out, fmt.Sprintf("choices.0.message.tool_calls.%d", i), r.toolCall(i, call, false))
+	}
+	out, _ = sjson.SetBytes(out, "choices.0.finish_reason", r.finishReason())
+	out, _ = sjson.SetRawBytes(out, "usage", r.usageJSON())
+	return out
+}
+
+// streamChunks renders the reply as OpenAI chat completion chunks: text in chunkSize pieces,
+// one chunk per tool call, and a final chunk with the finish reason and usage.
+func (r *mockReply) streamChunks() [][]byte {
+	base := []byte(`{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[{"index":0,"delta":{},"finish_reason":null}]}`)
+	base, _ = sjson.SetBytes(base, "id", r.id)
+	base, _ = sjson.SetBytes(base, "created", mockCreated)
+	base, _ = sjson.SetBytes(base, "model", r.model)
+
+	var chunks [][]byte
+	pieces := mockSplitText(r.text, r.chunkSize)
+	if len(pieces) == 0 {
+		pieces = []string{""}
+	}
+	for i, piece := range pieces {
+		chunk, _ := sjson.SetBytes(base, "choices.0.delta.content", piece)
+		if i == 0 {
+			chunk, _ = sjson.SetBytes(chunk, "choices.0.delta.role", "assistant")
+		}
+		chunks = append(chunks, chunk)
+	}
+	for i, call := range r.toolCalls {
+		chunk, _ := sjson.SetRawBytes(base, "choices.0.delta.tool_calls.0", r.toolCall(i, call, true))
+		chunks = append(chunks, chunk)
+	}
+	final, _ := sjson.SetBytes(base, "choices.0.finish_reason", r.finishReason())
+	final, _ = sjson.SetRawBytes(final, "usage", r.usageJSON())
+	return append(chunks, final)
+}
+
+func (r *mockReply) toolCall(index int, call config.MockToolCall, stream bool) []byte {
+	arguments := strings.TrimSpace(call.Arguments)
+	if arguments == "" {
+		arguments = "{}"
+	}
+	out := []byte(`{"id":"","type":"function","function":{"name":"","arguments":""}}`)
+	if stream {
+		out, _ = sjson.SetBytes(out, "index", index)
+	}
+	out, _ = sjson.SetBytes(out, "id", fmt.Sprintf("call_mock_%d", index))
+	out, _ = sjson.SetBytes(out, "function.name", call.Name)
+	out, _ = sjson.SetBytes(out, "function.arguments", arguments)
+	return out
+}
+
+func (r *mockReply) finishReason() string {
+	if len(r.toolCalls) > 0 {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+func (r *mockReply) usageJSON() []byte {
+	return []byte(fmt.Sprintf(`{"prompt_tokens":%d,"completion_tokens":%d,"total_tokens":%d}`, r.usage.InputTokens, r.usage.OutputTokens, r.usage.TotalTokens))
+}
+
+// mockLastUserText returns the text of the last user message of an OpenAI chat request.
+func mockLastUserText(payload []byte) string {
+	messages := gjson.GetBytes(payload, "messages").Array()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Get("role").String() != "user" {
+			continue
+		}
+		content := messages[i].Get("content")
+		if content.Type == gjson.String {
+			return content.String()
+		}
+		var segments []string
+		content.ForEach(func(_, part gjson.Result) bool {
+			if part.Get("type").String() == "text" {
+				segments = append(segments, part.Get("text").String())
+			}
+			return true
+		})
+		return strings.Join(segments, "\n")
+	}
+	return ""
+}
+
+func mockPromptText(payload []byte) string {
+	var segments []string
+	collectOpenAIMessages(gjson.GetBytes(payload, "messages"), &segments)
+	return strings.Join(segments, "\n")
+}
+
+// mockEstimateTokens estimates four bytes per token, rounding up.
+func mockEstimateTokens(text string) int64 {
+	return int64((len(text) + 3) / 4)
+}
+
+func mockSplitText(text string, size int) []string {
+	runes := []rune(text)
+	var out []string
+	for len(runes) > 0 {
+		n := min(size, len(runes))
+		out = append(out, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return out
+}
+
+func mockWait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
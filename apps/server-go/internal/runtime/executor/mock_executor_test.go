@@ -0,0 +1,142 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func newTestMockExecutor(models ...config.MockModel) (*MockExecutor, *cliproxyauth.Auth) {
+	cfg := &config.Config{MockProvider: []config.MockProvider{{Name: "local", Models: models}}}
+	auth := &cliproxyauth.Auth{ID: "mock-1", Provider: "mock", Attributes: map[string]string{"mock_name": "local"}}
+	return NewMockExecutor(cfg), auth
+}
+
+func TestMockExecutor_FixedReplyWithToolCalls(t *testing.T) {
+	e, auth := newTestMockExecutor(config.MockModel{
+		Name:      "mock-tools",
+		Mode:      config.MockModeFixed,
+		Text:      "Let me check.",
+		ToolCalls: []config.MockToolCall{{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		Usage:     config.MockUsage{InputTokens: 100, OutputTokens: 20},
+	})
+	req := cliproxyexecutor.Request{Model: "mock-tools", Payload: []byte(`{"model":"mock-tools","messages":[{"role":"user","content":"weather?"}]}`)}
+	opts := cliproxyexecutor.Options{SourceFormat: sdktranslator.FormatOpenAI}
+
+	first, err := e.Execute(context.Background(), auth, req, opts)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	body := gjson.ParseBytes(first.Payload)
+	if got := body.Get("choices.0.message.content").String(); got != "Let me check." {
+		t.Fatalf("content = %q, want fixed text", got)
+	}
+	if got := body.Get("choices.0.message.tool_calls.0.function.name").String(); got != "get_weather" {
+		t.Fatalf("tool call name = %q, want get_weather", got)
+	}
+	if got := body.Get("choices.0.finish_reason").String(); got != "tool_calls" {
+		t.Fatalf("finish_reason = %q, want tool_calls", got)
+	}
+	if got := body.Get("usage.total_tokens").Int(); got != 120 {
+		t.Fatalf("usage.total_tokens = %d, want 120", got)
+	}
+
+	// A fresh executor replays the same sequence byte for byte.
+	again, _ := newTestMockExecutor(e.cfg.MockProvider[0].Models...)
+	second, err := again.Execute(context.Background(), auth, req, opts)
+	if err != nil || string(second.Payload) != string(first.Payload) {
+		t.Fatalf("replies differ across runs:\n%s\n%s (err %v)", first.Payload, second.Payload, err)
+	}
+}
+
+func TestMockExecutor_StreamEchoesToClaudeAndDisconnects(t *testing.T) {
+	e, auth := newTestMockExecutor(config.MockModel{Name: "mock-echo", ChunkSize: 4})
+	payload := []byte(`{"model":"mock-echo","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hello there"}]}`)
+	req := cliproxyexecutor.Request{Model: "mock-echo", Payload: payload}
+	opts := cliproxyexecutor.Options{SourceFormat: sdktranslator.FormatClaude, Stream: true, OriginalRequest: payload}
+
+	result, err := e.ExecuteStream(context.Background(), auth, req, opts)
+	if err != nil {
+		t.Fatalf("ExecuteStream() error = %v", err)
+	}
+	var text strings.Builder
+	var sawStop bool
+	for chunk := range result.Chunks {
+		if chunk.Err != nil {
+			t.Fatalf("stream error = %v", chunk.Err)
+		}
+		for _, line := range strings.Split(string(chunk.Payload), "\n") {
+			data := gjson.Parse(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+			text.WriteString(data.Get("delta.text").String())
+			sawStop = sawStop || data.Get("type").String() == "message_stop"
+		}
+	}
+	if text.String() != "hello there" || !sawStop {
+		t.Fatalf("streamed text = %q (message_stop %t), want the echoed prompt and a clean stop", text.String(), sawStop)
+	}
+
+	e, auth = newTestMockExecutor(config.MockModel{Name: "mock-echo", ChunkSize: 4, DisconnectAfter: 2})
+	result, err = e.ExecuteStream(context.Background(), auth, req, opts)
+	if err != nil {
+		t.Fatalf("ExecuteStream() error = %v", err)
+	}
+	var streamErr error
+	for chunk := range result.Chunks {
+		if chunk.Err != nil {
+			streamErr = chunk.Err
+		}
+	}
+	if !errors.Is(streamErr, io.ErrUnexpectedEOF) {
+		t.Fatalf("stream error = %v, want a disconnect", streamErr)
+	}
+}
+
+func TestMockExecutor_InjectsFaultsDeterministically(t *testing.T) {
+	e, auth := newTestMockExecutor(config.MockModel{
+		Name: "mock-flaky",
+		Mode: config.MockModeFixed,
+		Text: "ok",
+		Faults: []config.MockFault{
+			{Status: 429, RetryAfter: 5, Every: 3},
+			{Status: 503, Times: 1},
+		},
+	})
+	req := cliproxyexecutor.Request{Model: "mock-flaky", Payload: []byte(`{"model":"mock-flaky","messages":[{"role":"user","content":"hi"}]}`)}
+	opts := cliproxyexecutor.Options{SourceFormat: sdktranslator.FormatOpenAI}
+
+	var got []int
+	for i := 0; i < 6; i++ {
+		_, err := e.Execute(context.Background(), auth, req, opts)
+		status := 200
+		if err != nil {
+			var se interface{ StatusCode() int }
+			if !errors.As(err, &se) {
+				t.Fatalf("request %d error = %v, want a status error", i+1, err)
+			}
+			status = se.StatusCode()
+			if status == 429 {
+				headers := err.(interface{ Headers() http.Header }).Headers()
+				if headers.Get("Retry-After") != "5" {
+					t.Fatalf("Retry-After = %q, want 5", headers.Get("Retry-After"))
+				}
+			}
+		}
+		got = append(got, status)
+	}
+	want := []int{503, 200, 429, 200, 200, 429}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("statuses = %v, want %v", got, want)
+		}
+	}
+}
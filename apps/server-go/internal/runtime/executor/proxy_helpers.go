@@ -8,16 +8,22 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cassette"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/proxypool"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upstreamtls"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/proxy"
 )
 
 // newProxyAwareHTTPClient creates an HTTP client with proper proxy configuration priority:
-// 1. Use auth.ProxyURL if configured (highest priority)
-// 2. Use cfg.ProxyURL if auth proxy is not configured
-// 3. Use RoundTripper from context if neither are configured
+// 1. Use the proxy pool member assigned to auth, if auth uses a pool (highest priority)
+// 2. Use auth.ProxyURL if configured
+// 3. Use cfg.ProxyURL if auth proxy is not configured
+// 4. Use RoundTripper from context if none are configured
+//
+// Connections use the upstream TLS settings of auth.
 //
 // Parameters:
 //   - ctx: The context containing optional RoundTripper
@@ -28,18 +34,26 @@ import (
 // Returns:
 //   - *http.Client: An HTTP client with configured proxy or transport
 func newProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, timeout time.Duration) *http.Client {
-	httpClient := &http.Client{}
-	if timeout > 0 {
-		httpClient.Timeout = timeout
+	return newUpstreamHTTPClient(auth, timeout, proxyAwareRoundTripper(ctx, cfg, auth))
+}
+
+// proxyAwareRoundTripper selects the transport for newProxyAwareHTTPClient. It returns nil when
+// neither a proxy nor a context RoundTripper is configured.
+func proxyAwareRoundTripper(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth) http.RoundTripper {
+	// Priority 1: Use the proxy pool member assigned to auth
+	if auth != nil {
+		if rt, ok := proxypool.RoundTripper(auth.ID, auth.Provider, auth.ProxyURL); ok {
+			return rt
+		}
 	}
 
-	// Priority 1: Use auth.ProxyURL if configured
+	// Priority 2: Use auth.ProxyURL if configured; unresolved pool references fall through
 	var proxyURL string
-	if auth != nil {
+	if auth != nil && !proxypool.IsPoolRef(auth.ProxyURL) {
 		proxyURL = strings.TrimSpace(auth.ProxyURL)
 	}
 
-	// Priority 2: Use cfg.ProxyURL if auth proxy is not configured
+	// Priority 3: Use cfg.ProxyURL if auth proxy is not configured
 	if proxyURL == "" && cfg != nil {
 		proxyURL = strings.TrimSpace(cfg.ProxyURL)
 	}
@@ -48,18 +62,32 @@ func newProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *clip
 	if proxyURL != "" {
 		transport := buildProxyTransport(proxyURL)
 		if transport != nil {
-			httpClient.Transport = transport
-			return httpClient
+			return transport
 		}
 		// If proxy setup failed, log and fall through to context RoundTripper
 		log.Debugf("failed to setup proxy from URL: %s, falling back to context transport", proxyURL)
 	}
 
-	// Priority 3: Use RoundTripper from context (typically from RoundTripperFor)
+	// Priority 4: Use RoundTripper from context (typically from RoundTripperFor)
 	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		httpClient.Transport = rt
+		return rt
 	}
+	return nil
+}
 
+// newUpstreamHTTPClient returns a client sending requests through rt (nil means the default
+// transport) with the upstream TLS settings of auth, recorded or replayed when a cassette mode is
+// active.
+func newUpstreamHTTPClient(auth *cliproxyauth.Auth, timeout time.Duration, rt http.RoundTripper) *http.Client {
+	httpClient := &http.Client{}
+	if timeout > 0 {
+		httpClient.Timeout = timeout
+	}
+	rt = upstreamtls.Wrap(rt)
+	if auth != nil {
+		rt = upstreamtls.Bind(rt, auth.Provider, auth.Attributes, auth.Metadata)
+	}
+	httpClient.Transport = cassette.Wrap(rt)
 	return httpClient
 }
 
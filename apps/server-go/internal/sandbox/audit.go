@@ -0,0 +1,66 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const maxAuditCommandBytes = 4 << 10
+
+// AuditEntry records one execution.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Key        string    `json:"key,omitempty"`
+	Model      string    `json:"model,omitempty"`
+	Tool       string    `json:"tool"`
+	Command    string    `json:"command"`
+	ExitCode   int       `json:"exit_code"`
+	TimedOut   bool      `json:"timed_out,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// AuditLog logs every execution and, when a path is set, appends it to that file as a JSON line.
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewAuditLog returns an audit log writing to path, or only to the process log when path is empty.
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// Record writes entry. File errors are logged and otherwise ignored so auditing never fails a
+// request.
+func (a *AuditLog) Record(entry AuditEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if len(entry.Command) > maxAuditCommandBytes {
+		entry.Command = entry.Command[:maxAuditCommandBytes] + "...(truncated)"
+	}
+	log.Infof("sandbox: tool=%s model=%s key=%s exit=%d timed_out=%t duration=%dms", entry.Tool, entry.Model, entry.Key, entry.ExitCode, entry.TimedOut, entry.DurationMS)
+	if a == nil || a.path == "" {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Warnf("sandbox: open audit log: %v", err)
+		return
+	}
+	defer func() { _ = file.Close() }()
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		log.Warnf("sandbox: write audit log: %v", err)
+	}
+}
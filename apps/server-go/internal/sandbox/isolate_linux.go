@@ -0,0 +1,174 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	landlockReadOnly      = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	landlockReadWriteFile = unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+)
+
+// runtimeDirs are exposed read-only to executions so interpreters and the allowed programs can
+// load; everything else outside the workspace is unreachable.
+var runtimeDirs = []string{"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/libx32", "/etc", "/opt", "/dev"}
+
+// isolate puts the execution in its own process group and, unless network access is allowed,
+// in new user and network namespaces with only a loopback interface. No id mappings are written:
+// they go through /proc, which the Landlock ruleset hides, and the unmapped ids keep the host
+// credentials for file access in the workspace.
+func isolate(cmd *exec.Cmd, allowNetwork bool) error {
+	attr := &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGKILL}
+	if !allowNetwork {
+		attr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+	}
+	cmd.SysProcAttr = attr
+	return nil
+}
+
+// startConfined starts cmd with its filesystem view restricted by Landlock: the runtime
+// directories are readable and executable, the workspace is fully writable and nothing else is
+// reachable. Landlock applies to the calling thread, so the start happens on a dedicated locked
+// thread that is discarded afterwards. That thread is also the parent the death signal is tied
+// to, so it is kept alive until release is called after the execution has been waited for.
+// opts.AllowHostFilesystem skips the restriction; otherwise the execution is refused when
+// Landlock is unavailable.
+func startConfined(cmd *exec.Cmd, dir string, opts Options) (release func(), err error) {
+	started := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		// The thread is never unlocked: once restricted it must exit with this goroutine.
+		runtime.LockOSThread()
+		if err := restrictFilesystem(dir, opts); err != nil {
+			started <- err
+			return
+		}
+		if err := cmd.Start(); err != nil {
+			started <- fmt.Errorf("sandbox: start: %w", err)
+			return
+		}
+		started <- nil
+		<-done
+	}()
+	if err = <-started; err != nil {
+		return nil, err
+	}
+	return func() { close(done) }, nil
+}
+
+// restrictFilesystem applies the Landlock ruleset to the current thread.
+func restrictFilesystem(dir string, opts Options) error {
+	if opts.AllowHostFilesystem {
+		return nil
+	}
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		if errors.Is(errno, unix.ENOSYS) || errors.Is(errno, unix.EOPNOTSUPP) {
+			return errors.New("sandbox: filesystem isolation requires Landlock; set sandbox.allow-host-filesystem to run without it")
+		}
+		return fmt.Errorf("sandbox: landlock: %w", errno)
+	}
+	handled := uint64(unix.LANDLOCK_ACCESS_FS_MAKE_SYM<<1 - 1)
+	if abi >= 2 {
+		handled |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		handled |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if abi >= 5 {
+		handled |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("sandbox: landlock ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer func() { _ = unix.Close(ruleset) }()
+
+	rules := map[string]uint64{dir: handled, "/dev/null": landlockReadWriteFile & handled}
+	for _, path := range runtimeDirs {
+		rules[path] = landlockReadOnly
+	}
+	if python, errLook := exec.LookPath(opts.PythonCommand); errLook == nil {
+		if resolved, errEval := filepath.EvalSymlinks(python); errEval == nil {
+			if prefix := filepath.Dir(filepath.Dir(resolved)); prefix != "/" {
+				if _, ok := rules[prefix]; !ok {
+					rules[prefix] = landlockReadOnly
+				}
+			}
+		}
+	}
+	for path, access := range rules {
+		if err := addLandlockRule(ruleset, path, access); err != nil {
+			return err
+		}
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("sandbox: no_new_privs: %w", err)
+	}
+	if _, _, errno = unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("sandbox: landlock restrict: %w", errno)
+	}
+	return nil
+}
+
+// addLandlockRule allows access beneath path. Paths missing on this host are skipped.
+func addLandlockRule(ruleset int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOENT) {
+			return nil
+		}
+		return fmt.Errorf("sandbox: landlock open %s: %w", path, err)
+	}
+	defer func() { _ = unix.Close(fd) }()
+	var stat unix.Stat_t
+	if err = unix.Fstat(fd, &stat); err == nil && stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+		// Directory rights cannot be granted on files.
+		access &= unix.LANDLOCK_ACCESS_FS_EXECUTE | landlockReadWriteFile | unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("sandbox: landlock rule %s: %w", path, errno)
+	}
+	return nil
+}
+
+// applyLimits sets the resource limits of the gated shell before it execs the program.
+func applyLimits(pid int, opts Options) error {
+	limits := []struct {
+		resource int
+		value    uint64
+	}{
+		{unix.RLIMIT_CPU, uint64(opts.CPUSeconds)},
+		{unix.RLIMIT_AS, uint64(opts.MemoryMB) << 20},
+		{unix.RLIMIT_NPROC, uint64(opts.MaxProcesses)},
+		{unix.RLIMIT_FSIZE, uint64(defaultMaxFileMB) << 20},
+		{unix.RLIMIT_CORE, 0},
+	}
+	for _, limit := range limits {
+		rlimit := unix.Rlimit{Cur: limit.value, Max: limit.value}
+		if err := unix.Prlimit(pid, limit.resource, &rlimit, nil); err != nil {
+			return fmt.Errorf("sandbox: set resource limit %d: %w", limit.resource, err)
+		}
+	}
+	return nil
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,41 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// isolate refuses executions that must run without network access, because network isolation
+// relies on Linux namespaces.
+func isolate(_ *exec.Cmd, allowNetwork bool) error {
+	if !allowNetwork {
+		return errors.New("sandbox: network isolation requires Linux; set sandbox.allow-network to run without it")
+	}
+	return nil
+}
+
+// startConfined refuses executions that must be confined to the workspace, because filesystem
+// isolation relies on Linux Landlock.
+func startConfined(cmd *exec.Cmd, _ string, opts Options) (func(), error) {
+	if !opts.AllowHostFilesystem {
+		return nil, errors.New("sandbox: filesystem isolation requires Linux; set sandbox.allow-host-filesystem to run without it")
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("sandbox: start: %w", err)
+	}
+	return func() {}, nil
+}
+
+// applyLimits is a no-op: resource limits are only enforced on Linux. The timeout still applies.
+func applyLimits(int, Options) error {
+	return nil
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
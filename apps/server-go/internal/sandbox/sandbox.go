@@ -0,0 +1,296 @@
+// Package sandbox runs model-generated code and shell commands in a restricted subprocess. Each
+// execution gets a timeout, resource limits, a private working directory and a minimal
+// environment; on Linux it is confined by Landlock to its workspace plus read-only system
+// directories, and runs without network access in its own user and network namespaces. It backs the proxy's local code_interpreter and shell tools.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeout        = 30 * time.Second
+	defaultMemoryMB       = 512
+	defaultMaxProcesses   = 64
+	defaultMaxOutputBytes = 64 << 10
+	defaultMaxFileMB      = 64
+	defaultPythonCommand  = "python3"
+	defaultPath           = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+	// gateScript waits until the parent has applied resource limits to the shell's pid, then
+	// replaces itself with the requested program so the limits are inherited.
+	gateScript = `read -r _ <&3; exec 3<&-; exec "$@"`
+)
+
+// ErrCommandNotAllowed is returned when a shell command starts a program outside the allowlist.
+var ErrCommandNotAllowed = errors.New("sandbox: command not allowed")
+
+// Options configures executions. Zero values select the defaults documented on each field.
+type Options struct {
+	// WorkDir is the parent of the per-workspace directories. Default is a directory under the
+	// system temp dir.
+	WorkDir string
+	// Timeout bounds one execution. Default is 30 seconds.
+	Timeout time.Duration
+	// CPUSeconds limits CPU time. Default is the timeout rounded up to whole seconds.
+	CPUSeconds int
+	// MemoryMB limits the address space. Default is 512.
+	MemoryMB int
+	// MaxProcesses limits the number of processes. Default is 64.
+	MaxProcesses int
+	// MaxOutputBytes caps captured stdout and stderr each. Default is 64 KiB.
+	MaxOutputBytes int
+	// AllowNetwork keeps network access instead of isolating the execution.
+	AllowNetwork bool
+	// AllowHostFilesystem runs executions without confining them to the workspace directory.
+	// By default the rest of the filesystem is hidden except read-only system directories.
+	AllowHostFilesystem bool
+	// AllowedCommands lists the programs shell commands may start, by base name. "*" allows any
+	// program and an empty list rejects every shell command.
+	AllowedCommands []string
+	// PythonCommand runs code_interpreter code. Default is "python3".
+	PythonCommand string
+}
+
+func (o Options) withDefaults() Options {
+	if strings.TrimSpace(o.WorkDir) == "" {
+		o.WorkDir = filepath.Join(os.TempDir(), "cliproxy-sandbox")
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.CPUSeconds <= 0 {
+		o.CPUSeconds = int((o.Timeout + time.Second - 1) / time.Second)
+	}
+	if o.MemoryMB <= 0 {
+		o.MemoryMB = defaultMemoryMB
+	}
+	if o.MaxProcesses <= 0 {
+		o.MaxProcesses = defaultMaxProcesses
+	}
+	if o.MaxOutputBytes <= 0 {
+		o.MaxOutputBytes = defaultMaxOutputBytes
+	}
+	if strings.TrimSpace(o.PythonCommand) == "" {
+		o.PythonCommand = defaultPythonCommand
+	}
+	return o
+}
+
+// Result is the outcome of one execution.
+type Result struct {
+	Stdout    string
+	Stderr    string
+	ExitCode  int
+	TimedOut  bool
+	Truncated bool
+	Duration  time.Duration
+}
+
+// Workspace is a private working directory shared by the executions of one request, so files
+// written by one call are visible to the next. Close removes it.
+type Workspace struct {
+	opts Options
+	dir  string
+}
+
+// NewWorkspace creates a fresh working directory under opts.WorkDir.
+func NewWorkspace(opts Options) (*Workspace, error) {
+	opts = opts.withDefaults()
+	if err := os.MkdirAll(opts.WorkDir, 0o700); err != nil {
+		return nil, fmt.Errorf("sandbox: create work dir: %w", err)
+	}
+	dir, err := os.MkdirTemp(opts.WorkDir, "ws-")
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: create workspace: %w", err)
+	}
+	return &Workspace{opts: opts, dir: dir}, nil
+}
+
+// Dir returns the workspace directory.
+func (w *Workspace) Dir() string {
+	return w.dir
+}
+
+// Close removes the workspace directory and everything in it.
+func (w *Workspace) Close() error {
+	if w == nil || w.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(w.dir)
+}
+
+// RunPython executes code with the configured Python interpreter.
+func (w *Workspace) RunPython(ctx context.Context, code string) (Result, error) {
+	return w.run(ctx, []string{w.opts.PythonCommand, "-"}, code)
+}
+
+// RunShell executes script with /bin/sh after checking that every command it starts is on the
+// allowlist. Command substitution is rejected because it cannot be checked.
+func (w *Workspace) RunShell(ctx context.Context, script string) (Result, error) {
+	if err := w.checkScript(script); err != nil {
+		return Result{}, err
+	}
+	return w.run(ctx, []string{"/bin/sh", "-c", script}, "")
+}
+
+// RunArgv executes argv directly after checking argv[0] against the allowlist.
+func (w *Workspace) RunArgv(ctx context.Context, argv []string) (Result, error) {
+	if len(argv) == 0 {
+		return Result{}, errors.New("sandbox: empty command")
+	}
+	if !w.commandAllowed(argv[0]) {
+		return Result{}, fmt.Errorf("%w: %s", ErrCommandNotAllowed, argv[0])
+	}
+	return w.run(ctx, argv, "")
+}
+
+func (w *Workspace) commandAllowed(program string) bool {
+	base := filepath.Base(strings.TrimSpace(program))
+	for _, allowed := range w.opts.AllowedCommands {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || allowed == base {
+			return true
+		}
+	}
+	return false
+}
+
+// checkScript applies the allowlist to the first word of every command in script, splitting on
+// the shell's command separators.
+func (w *Workspace) checkScript(script string) error {
+	if strings.Contains(script, "`") || strings.Contains(script, "$(") || strings.Contains(script, "<(") || strings.Contains(script, ">(") {
+		if !w.commandAllowed("*") {
+			return fmt.Errorf("%w: command substitution", ErrCommandNotAllowed)
+		}
+	}
+	separators := strings.NewReplacer("&&", "\n", "||", "\n", ";", "\n", "|", "\n", "&", "\n", "(", "\n", ")", "\n", "{", "\n", "}", "\n")
+	for _, command := range strings.Split(separators.Replace(script), "\n") {
+		fields := strings.Fields(command)
+		for len(fields) > 0 && strings.Contains(fields[0], "=") && !strings.HasPrefix(fields[0], "=") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if !w.commandAllowed(fields[0]) {
+			return fmt.Errorf("%w: %s", ErrCommandNotAllowed, fields[0])
+		}
+	}
+	return nil
+}
+
+func (w *Workspace) run(ctx context.Context, argv []string, stdin string) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.opts.Timeout)
+	defer cancel()
+
+	gateReader, gateWriter, err := os.Pipe()
+	if err != nil {
+		return Result{}, fmt.Errorf("sandbox: %w", err)
+	}
+	defer func() { _ = gateWriter.Close() }()
+
+	cmd := exec.Command("/bin/sh", append([]string{"-c", gateScript, "sandbox"}, argv...)...)
+	cmd.Dir = w.dir
+	cmd.Env = []string{
+		"PATH=" + defaultPath,
+		"HOME=" + w.dir,
+		"TMPDIR=" + w.dir,
+		"LANG=C.UTF-8",
+		"PYTHONDONTWRITEBYTECODE=1",
+	}
+	cmd.Stdin = strings.NewReader(stdin)
+	stdout := &limitedBuffer{limit: w.opts.MaxOutputBytes}
+	stderr := &limitedBuffer{limit: w.opts.MaxOutputBytes}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.ExtraFiles = []*os.File{gateReader}
+	if err = isolate(cmd, w.opts.AllowNetwork); err != nil {
+		_ = gateReader.Close()
+		return Result{}, err
+	}
+
+	started := time.Now()
+	release, err := startConfined(cmd, w.dir, w.opts)
+	_ = gateReader.Close()
+	if err != nil {
+		return Result{}, err
+	}
+	defer release()
+	if err = applyLimits(cmd.Process.Pid, w.opts); err != nil {
+		killProcessGroup(cmd)
+		_ = cmd.Wait()
+		return Result{}, err
+	}
+	_ = gateWriter.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	result := Result{}
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		err = <-done
+		result.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	}
+	result.Duration = time.Since(started)
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.Truncated = stdout.truncated || stderr.truncated
+	result.ExitCode = 0
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return result, fmt.Errorf("sandbox: %w", err)
+	}
+	if result.TimedOut {
+		return result, nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return result, ctxErr
+	}
+	return result, nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the rest.
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	remaining := b.limit - b.buf.Len()
+	if remaining <= 0 {
+		b.truncated = b.truncated || len(p) > 0
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		b.buf.Write(p[:remaining])
+		b.truncated = true
+		return len(p), nil
+	}
+	b.buf.Write(p)
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,119 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestWorkspace(t *testing.T, opts Options) *Workspace {
+	t.Helper()
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh is not available")
+	}
+	opts.WorkDir = t.TempDir()
+	ws, err := NewWorkspace(opts)
+	if err != nil {
+		t.Fatalf("NewWorkspace: %v", err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws
+}
+
+func TestRunShellCapturesOutputInWorkspace(t *testing.T) {
+	ws := newTestWorkspace(t, Options{AllowNetwork: true, AllowedCommands: []string{"echo", "cat", "pwd"}})
+
+	result, err := ws.RunShell(context.Background(), "echo hello > note.txt; cat note.txt; pwd")
+	if err != nil {
+		t.Fatalf("RunShell: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code = %d, stderr=%q", result.ExitCode, result.Stderr)
+	}
+	if want := "hello\n" + ws.Dir() + "\n"; result.Stdout != want {
+		t.Fatalf("stdout = %q, want %q", result.Stdout, want)
+	}
+}
+
+func TestRunShellRejectsCommandsOutsideAllowlist(t *testing.T) {
+	ws := newTestWorkspace(t, Options{AllowNetwork: true, AllowedCommands: []string{"echo"}})
+
+	for _, script := range []string{"rm -rf /tmp/x", "echo ok && curl example.com", "echo $(id)", "FOO=1 wget x"} {
+		if _, err := ws.RunShell(context.Background(), script); !errors.Is(err, ErrCommandNotAllowed) {
+			t.Fatalf("RunShell(%q) err = %v, want ErrCommandNotAllowed", script, err)
+		}
+	}
+}
+
+func TestRunShellTimesOut(t *testing.T) {
+	ws := newTestWorkspace(t, Options{AllowNetwork: true, AllowedCommands: []string{"sleep"}, Timeout: 200 * time.Millisecond})
+
+	started := time.Now()
+	result, err := ws.RunShell(context.Background(), "sleep 5")
+	if err != nil {
+		t.Fatalf("RunShell: %v", err)
+	}
+	if !result.TimedOut {
+		t.Fatalf("TimedOut = false, result=%+v", result)
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Fatalf("timeout took %s", elapsed)
+	}
+}
+
+func TestRunShellTruncatesOutput(t *testing.T) {
+	ws := newTestWorkspace(t, Options{AllowNetwork: true, AllowedCommands: []string{"yes", "head"}, MaxOutputBytes: 16})
+
+	result, err := ws.RunShell(context.Background(), "yes | head -c 1000")
+	if err != nil {
+		t.Fatalf("RunShell: %v", err)
+	}
+	if len(result.Stdout) != 16 || !result.Truncated {
+		t.Fatalf("stdout len = %d, truncated = %t", len(result.Stdout), result.Truncated)
+	}
+}
+
+func TestRunPythonWithoutNetwork(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not available")
+	}
+	ws := newTestWorkspace(t, Options{})
+
+	result, err := ws.RunPython(context.Background(), "import socket\ntry:\n    socket.create_connection(('1.1.1.1', 53), timeout=2)\n    print('connected')\nexcept OSError:\n    print('offline')\nopen('out.txt', 'w').write('ok')\n")
+	if err != nil {
+		if strings.Contains(err.Error(), "operation not permitted") || strings.Contains(err.Error(), "invalid argument") {
+			t.Skipf("user namespaces are unavailable: %v", err)
+		}
+		t.Fatalf("RunPython: %v", err)
+	}
+	if strings.TrimSpace(result.Stdout) != "offline" {
+		t.Fatalf("stdout = %q, stderr = %q", result.Stdout, result.Stderr)
+	}
+	if data, err := os.ReadFile(filepath.Join(ws.Dir(), "out.txt")); err != nil || string(data) != "ok" {
+		t.Fatalf("workspace write = %q, %v", data, err)
+	}
+}
+
+func TestRunShellCannotReadOutsideWorkspace(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(secret, []byte("top-secret"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	ws := newTestWorkspace(t, Options{AllowNetwork: true, AllowedCommands: []string{"cat"}})
+
+	result, err := ws.RunShell(context.Background(), "cat "+secret)
+	if err != nil && strings.Contains(err.Error(), "requires") {
+		t.Skipf("filesystem isolation unavailable: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("RunShell: %v", err)
+	}
+	if result.ExitCode == 0 || strings.Contains(result.Stdout, "top-secret") {
+		t.Fatalf("read outside the workspace: exit=%d stdout=%q stderr=%q", result.ExitCode, result.Stdout, result.Stderr)
+	}
+}
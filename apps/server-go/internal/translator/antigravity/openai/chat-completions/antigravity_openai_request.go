@@ -205,7 +205,17 @@ func ConvertOpenAIRequestToAntigravity(modelName string, inputRawJSON []byte, _
 							if sp := strings.Split(filename, "."); len(sp) > 1 {
 								ext = sp[len(sp)-1]
 							}
-							if mimeType, ok := misc.MimeTypes[ext]; ok {
+							mimeType, ok := misc.MimeTypes[ext]
+							// file_data may be a data URL, which carries its own media type.
+							if strings.HasPrefix(fileData, "data:") {
+								if header, payload, found := strings.Cut(strings.TrimPrefix(fileData, "data:"), ";base64,"); found {
+									if header != "" {
+										mimeType, ok = header, true
+									}
+									fileData = payload
+								}
+							}
+							if ok {
 								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mimeType", mimeType)
 								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", fileData)
 								p++
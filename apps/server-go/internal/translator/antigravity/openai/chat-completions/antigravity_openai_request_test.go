@@ -0,0 +1,23 @@
+package chat_completions
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertOpenAIRequestToAntigravity_FileDataURL(t *testing.T) {
+	input := []byte(`{"model":"gemini-2.5-pro","messages":[{"role":"user","content":[
+		{"type":"text","text":"read these"},
+		{"type":"file","file":{"filename":"upload.bin","file_data":"data:application/pdf;base64,UERGLWE="}},
+		{"type":"file","file":{"filename":"notes.txt","file_data":"bm90ZXM="}}]}]}`)
+
+	out := ConvertOpenAIRequestToAntigravity("gemini-2.5-pro", input, false)
+	parts := gjson.GetBytes(out, "request.contents.0.parts")
+	if parts.Get("1.inlineData.mimeType").String() != "application/pdf" || parts.Get("1.inlineData.data").String() != "UERGLWE=" {
+		t.Fatalf("data URL part = %s", parts.Get("1").Raw)
+	}
+	if parts.Get("2.inlineData.mimeType").String() != "text/plain" || parts.Get("2.inlineData.data").String() != "bm90ZXM=" {
+		t.Fatalf("bare base64 part = %s", parts.Get("2").Raw)
+	}
+}
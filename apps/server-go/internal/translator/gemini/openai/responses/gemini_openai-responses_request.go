@@ -1,8 +1,10 @@
 package responses
 
 import (
+	"path"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -264,6 +266,35 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 								partJSON, _ = sjson.Set(partJSON, "inline_data.mime_type", mimeType)
 								partJSON, _ = sjson.Set(partJSON, "inline_data.data", audioData)
 							}
+						case "input_file":
+							// file_data is a data URL or bare base64 typed by the filename; file_url
+							// is passed to Gemini as a file URI.
+							fileData := contentItem.Get("file_data").String()
+							fileURL := strings.TrimSpace(contentItem.Get("file_url").String())
+							mimeType := "application/octet-stream"
+							if mapped, ok := misc.MimeTypes[strings.ToLower(strings.TrimPrefix(path.Ext(contentItem.Get("filename").String()), "."))]; ok {
+								mimeType = mapped
+							}
+							data := fileData
+							if strings.HasPrefix(fileData, "data:") {
+								header, payload, found := strings.Cut(strings.TrimPrefix(fileData, "data:"), ";base64,")
+								if !found {
+									payload = ""
+								} else if header != "" {
+									mimeType = header
+								}
+								data = payload
+							}
+							switch {
+							case data != "":
+								partJSON = `{"inline_data":{"mime_type":"","data":""}}`
+								partJSON, _ = sjson.Set(partJSON, "inline_data.mime_type", mimeType)
+								partJSON, _ = sjson.Set(partJSON, "inline_data.data", data)
+							case fileURL != "":
+								partJSON = `{"file_data":{"mime_type":"","file_uri":""}}`
+								partJSON, _ = sjson.Set(partJSON, "file_data.mime_type", mimeType)
+								partJSON, _ = sjson.Set(partJSON, "file_data.file_uri", fileURL)
+							}
 						}
 
 						if partJSON != "" {
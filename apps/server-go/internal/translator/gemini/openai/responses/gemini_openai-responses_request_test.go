@@ -0,0 +1,30 @@
+package responses
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertOpenAIResponsesRequestToGemini_InputFile(t *testing.T) {
+	input := []byte(`{"model":"gemini-2.5-pro","input":[{"role":"user","content":[
+		{"type":"input_file","filename":"a.pdf","file_data":"data:application/pdf;base64,UERGLWE="},
+		{"type":"input_file","filename":"notes.TXT","file_data":"bm90ZXM="},
+		{"type":"input_file","filename":"report.pdf","file_url":"https://example.com/report.pdf"},
+		{"type":"input_text","text":"summarize"}]}]}`)
+
+	out := ConvertOpenAIResponsesRequestToGemini("gemini-2.5-pro", input, false)
+	parts := gjson.GetBytes(out, "contents.0.parts")
+	if parts.Get("#").Int() != 4 {
+		t.Fatalf("parts = %s, want 4", parts.Raw)
+	}
+	if parts.Get("0.inline_data.mime_type").String() != "application/pdf" || parts.Get("0.inline_data.data").String() != "UERGLWE=" {
+		t.Fatalf("data URL part = %s", parts.Get("0").Raw)
+	}
+	if parts.Get("1.inline_data.mime_type").String() != "text/plain" || parts.Get("1.inline_data.data").String() != "bm90ZXM=" {
+		t.Fatalf("bare base64 part = %s", parts.Get("1").Raw)
+	}
+	if parts.Get("2.file_data.file_uri").String() != "https://example.com/report.pdf" || parts.Get("2.file_data.mime_type").String() != "application/pdf" {
+		t.Fatalf("file_url part = %s", parts.Get("2").Raw)
+	}
+}
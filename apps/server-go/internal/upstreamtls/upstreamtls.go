@@ -0,0 +1,393 @@
+// Package upstreamtls applies the upstream-tls configuration to outbound connections. Settings are
+// layered: the top-level settings apply to every connection, a provider's settings override them,
+// and the profile named by a credential's tls_profile field overrides both. Transports passed
+// through Wrap pick the settings for each request from its context, where Bind and WithAuth put
+// the provider and profile of the credential making the request.
+package upstreamtls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Profile is the effective TLS configuration for one provider and profile combination.
+type Profile struct {
+	tls *tls.Config
+}
+
+// TLSConfig returns a copy of the profile's client TLS configuration. A nil profile returns nil,
+// meaning the Go defaults.
+func (p *Profile) TLSConfig() *tls.Config {
+	if p == nil {
+		return nil
+	}
+	return p.tls.Clone()
+}
+
+// layer is one loaded settings block.
+type layer struct {
+	roots      []*x509.Certificate
+	cert       *tls.Certificate
+	pins       [][]byte
+	minVersion uint16
+}
+
+type state struct {
+	global    *layer
+	providers map[string]*layer
+	profiles  map[string]*layer
+
+	mu       sync.Mutex
+	resolved map[selector]*Profile
+}
+
+// selector identifies the credential settings a request uses.
+type selector struct {
+	provider string
+	profile  string
+}
+
+type contextKey struct{}
+
+var (
+	active     atomic.Pointer[state]
+	generation atomic.Uint64
+)
+
+// Configure loads the files referenced by cfg and makes it the active configuration. On error the
+// previous configuration stays active.
+func Configure(cfg config.UpstreamTLS) error {
+	next := &state{
+		providers: make(map[string]*layer, len(cfg.Providers)),
+		profiles:  make(map[string]*layer, len(cfg.Profiles)),
+		resolved:  make(map[selector]*Profile),
+	}
+	var errs []error
+	var err error
+	if next.global, err = loadLayer(cfg.UpstreamTLSSettings); err != nil {
+		errs = append(errs, fmt.Errorf("upstream-tls: %w", err))
+	}
+	for name, settings := range cfg.Providers {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if next.providers[key], err = loadLayer(settings); err != nil {
+			errs = append(errs, fmt.Errorf("upstream-tls.providers.%s: %w", name, err))
+		}
+	}
+	for name, settings := range cfg.Profiles {
+		key := strings.TrimSpace(name)
+		if next.profiles[key], err = loadLayer(settings); err != nil {
+			errs = append(errs, fmt.Errorf("upstream-tls.profiles.%s: %w", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	active.Store(next)
+	generation.Add(1)
+	return nil
+}
+
+func loadLayer(settings config.UpstreamTLSSettings) (*layer, error) {
+	l := &layer{}
+	for _, path := range settings.CAFiles {
+		data, err := os.ReadFile(strings.TrimSpace(path))
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		certs, err := parsePEMCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("CA file %s: %w", path, err)
+		}
+		l.roots = append(l.roots, certs...)
+	}
+	certFile, keyFile := strings.TrimSpace(settings.ClientCert), strings.TrimSpace(settings.ClientKey)
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		l.cert = &cert
+	}
+	for _, pin := range settings.PinSHA256 {
+		digest, err := base64.StdEncoding.DecodeString(strings.TrimSpace(pin))
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin %q", pin)
+		}
+		l.pins = append(l.pins, digest)
+	}
+	switch strings.TrimSpace(settings.MinVersion) {
+	case "":
+	case "1.2":
+		l.minVersion = tls.VersionTLS12
+	case "1.3":
+		l.minVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported min-version %q", settings.MinVersion)
+	}
+	return l, nil
+}
+
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			break
+		}
+		data = rest
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found")
+	}
+	return certs, nil
+}
+
+// Resolve returns the effective settings for a credential of provider selecting profile, or nil
+// when nothing is configured for it.
+func Resolve(provider, profile string) *Profile {
+	s := active.Load()
+	if s == nil {
+		return nil
+	}
+	sel := selector{provider: strings.ToLower(strings.TrimSpace(provider)), profile: strings.TrimSpace(profile)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.resolved[sel]; ok {
+		return p
+	}
+	p := s.build(sel)
+	s.resolved[sel] = p
+	return p
+}
+
+// build merges the layers for sel; each field comes from the most specific layer setting it.
+func (s *state) build(sel selector) *Profile {
+	layers := []*layer{s.global, s.providers[sel.provider], s.profiles[sel.profile]}
+	merged := layer{}
+	for _, l := range layers {
+		if l == nil {
+			continue
+		}
+		if len(l.roots) > 0 {
+			merged.roots = l.roots
+		}
+		if l.cert != nil {
+			merged.cert = l.cert
+		}
+		if len(l.pins) > 0 {
+			merged.pins = l.pins
+		}
+		if l.minVersion != 0 {
+			merged.minVersion = l.minVersion
+		}
+	}
+	if len(merged.roots) == 0 && merged.cert == nil && len(merged.pins) == 0 && merged.minVersion == 0 {
+		return nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if merged.minVersion != 0 {
+		cfg.MinVersion = merged.minVersion
+	}
+	if len(merged.roots) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		for _, cert := range merged.roots {
+			pool.AddCert(cert)
+		}
+		cfg.RootCAs = pool
+	}
+	if merged.cert != nil {
+		cfg.Certificates = []tls.Certificate{*merged.cert}
+	}
+	if pins := merged.pins; len(pins) > 0 {
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error { return verifyPins(cs, pins) }
+	}
+	return &Profile{tls: cfg}
+}
+
+// verifyPins accepts the connection when a certificate of a verified chain has a pinned key.
+func verifyPins(cs tls.ConnectionState, pins [][]byte) error {
+	for _, chain := range cs.VerifiedChains {
+		for _, cert := range chain {
+			digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, pin := range pins {
+				if string(digest[:]) == string(pin) {
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("upstream-tls: no pinned public key in the certificate chain of %s", cs.ServerName)
+}
+
+// ProfileName returns the tls_profile selected by a credential's attributes or metadata.
+func ProfileName(attributes map[string]string, metadata map[string]any) string {
+	if name := strings.TrimSpace(attributes[config.UpstreamTLSProfileKey]); name != "" {
+		return name
+	}
+	if name, ok := metadata[config.UpstreamTLSProfileKey].(string); ok {
+		return strings.TrimSpace(name)
+	}
+	return ""
+}
+
+// ForAuth resolves the settings of a credential.
+func ForAuth(provider string, attributes map[string]string, metadata map[string]any) *Profile {
+	return Resolve(provider, ProfileName(attributes, metadata))
+}
+
+// WithAuth returns a context whose requests through wrapped transports use the settings of the
+// credential.
+func WithAuth(ctx context.Context, provider string, attributes map[string]string, metadata map[string]any) context.Context {
+	return context.WithValue(ctx, contextKey{}, selector{provider: provider, profile: ProfileName(attributes, metadata)})
+}
+
+// FromContext resolves the settings of the credential named by ctx, or the top-level settings
+// when it names none.
+func FromContext(ctx context.Context) *Profile {
+	sel, _ := ctx.Value(contextKey{}).(selector)
+	return Resolve(sel.provider, sel.profile)
+}
+
+// Bind returns rt with every request carrying the settings of the credential, as with WithAuth.
+func Bind(rt http.RoundTripper, provider string, attributes map[string]string, metadata map[string]any) http.RoundTripper {
+	if rt == nil {
+		return nil
+	}
+	return &boundTransport{base: rt, sel: selector{provider: provider, profile: ProfileName(attributes, metadata)}}
+}
+
+type boundTransport struct {
+	base http.RoundTripper
+	sel  selector
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *boundTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := req.Context().Value(contextKey{}).(selector); !ok {
+		req = req.WithContext(context.WithValue(req.Context(), contextKey{}, t.sel))
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Wrap returns a round tripper sending each request through a copy of base configured with the
+// settings selected by the request context, or the top-level settings when it selects none. A nil
+// base stands for http.DefaultTransport. Round trippers other than *http.Transport are returned
+// unchanged, as they are expected to wrap a transport of their own. The copies are owned by the
+// returned value, so base should not be shared with other callers of Wrap; use Shared for that.
+func Wrap(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		return defaultRoundTripper{}
+	}
+	base, ok := rt.(*http.Transport)
+	if !ok {
+		return rt
+	}
+	return newTLSTransport(base)
+}
+
+var shared sync.Map // *http.Transport -> *tlsTransport
+
+// Shared is Wrap for a long-lived transport used from many places; all callers share the copies
+// made for each set of settings.
+func Shared(base *http.Transport) http.RoundTripper {
+	if t, ok := shared.Load(base); ok {
+		return t.(*tlsTransport)
+	}
+	t, _ := shared.LoadOrStore(base, newTLSTransport(base))
+	return t.(*tlsTransport)
+}
+
+// defaultRoundTripper resolves http.DefaultTransport on every request, so replacing it later
+// still takes effect.
+type defaultRoundTripper struct{}
+
+// RoundTrip implements http.RoundTripper.
+func (defaultRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return Shared(base).RoundTrip(req)
+}
+
+type tlsTransport struct {
+	base *http.Transport
+
+	mu         sync.Mutex
+	generation uint64
+	copies     map[*Profile]*http.Transport
+}
+
+func newTLSTransport(base *http.Transport) *tlsTransport {
+	return &tlsTransport{base: base, copies: make(map[*Profile]*http.Transport)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tlsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	profile := FromContext(req.Context())
+	if profile == nil {
+		return t.base.RoundTrip(req)
+	}
+	return t.transportFor(profile).RoundTrip(req)
+}
+
+func (t *tlsTransport) transportFor(profile *Profile) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if current := generation.Load(); current != t.generation {
+		for _, stale := range t.copies {
+			stale.CloseIdleConnections()
+		}
+		clear(t.copies)
+		t.generation = current
+	}
+	if transport, ok := t.copies[profile]; ok {
+		return transport
+	}
+	transport := t.base.Clone()
+	Apply(transport, profile)
+	t.copies[profile] = transport
+	return transport
+}
+
+// Apply configures transport with profile, keeping its ALPN protocols and whether it negotiates
+// HTTP/2. A nil profile leaves transport unchanged.
+func Apply(transport *http.Transport, profile *Profile) {
+	if transport == nil || profile == nil {
+		return
+	}
+	// Setting TLSClientConfig or a custom dialer turns off automatic HTTP/2 unless forced.
+	http2 := transport.TLSNextProto == nil && (transport.ForceAttemptHTTP2 ||
+		(transport.TLSClientConfig == nil && transport.DialContext == nil && transport.DialTLSContext == nil))
+	cfg := profile.TLSConfig()
+	if transport.TLSClientConfig != nil {
+		cfg.NextProtos = transport.TLSClientConfig.NextProtos
+		cfg.ServerName = transport.TLSClientConfig.ServerName
+	}
+	transport.TLSClientConfig = cfg
+	transport.ForceAttemptHTTP2 = http2
+}
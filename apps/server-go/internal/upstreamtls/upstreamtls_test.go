@@ -0,0 +1,94 @@
+package upstreamtls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestWrapAppliesLayeredSettings(t *testing.T) {
+	t.Cleanup(func() { _ = Configure(config.UpstreamTLS{}) })
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	goodPin := base64.StdEncoding.EncodeToString(digest[:])
+	badPin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	get := func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		resp, err := Wrap(&http.Transport{}).RoundTrip(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+	if err := get(context.Background()); err == nil {
+		t.Fatal("request to a server signed by an unknown CA succeeded without configuration")
+	}
+
+	err := Configure(config.UpstreamTLS{
+		UpstreamTLSSettings: config.UpstreamTLSSettings{CAFiles: []string{caFile}},
+		Providers:           map[string]config.UpstreamTLSSettings{"codex": {PinSHA256: []string{badPin}}},
+		Profiles:            map[string]config.UpstreamTLSSettings{"pinned": {PinSHA256: []string{goodPin}}},
+	})
+	if err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	if err = get(context.Background()); err != nil {
+		t.Fatalf("request with the extra CA failed: %v", err)
+	}
+	if err = get(WithAuth(context.Background(), "codex", nil, nil)); err == nil {
+		t.Fatal("provider pin mismatch was not enforced")
+	}
+	profile := map[string]any{config.UpstreamTLSProfileKey: "pinned"}
+	if err = get(WithAuth(context.Background(), "codex", nil, profile)); err != nil {
+		t.Fatalf("credential profile did not override the provider pin: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err = Bind(Wrap(&http.Transport{}), "codex", nil, nil).RoundTrip(req); err == nil {
+		t.Fatal("Bind did not select the provider settings")
+	}
+
+	if err = Configure(config.UpstreamTLS{Profiles: map[string]config.UpstreamTLSSettings{"x": {CAFiles: []string{"missing.pem"}}}}); err == nil {
+		t.Fatal("Configure() accepted a missing CA file")
+	}
+	if Resolve("", "") == nil {
+		t.Fatal("failed Configure() replaced the active settings")
+	}
+}
+
+func TestApplyKeepsProtocolNegotiation(t *testing.T) {
+	s := &state{resolved: make(map[selector]*Profile), global: &layer{minVersion: tls.VersionTLS13}}
+	profile := s.build(selector{})
+
+	fresh := &http.Transport{}
+	Apply(fresh, profile)
+	if !fresh.ForceAttemptHTTP2 || fresh.TLSClientConfig.MinVersion != tls.VersionTLS13 {
+		t.Fatalf("fresh transport: http2 %t, min version %x", fresh.ForceAttemptHTTP2, fresh.TLSClientConfig.MinVersion)
+	}
+
+	http1 := &http.Transport{
+		TLSNextProto:    map[string]func(string, *tls.Conn) http.RoundTripper{},
+		TLSClientConfig: &tls.Config{NextProtos: []string{"http/1.1"}},
+	}
+	Apply(http1, profile)
+	if http1.ForceAttemptHTTP2 || len(http1.TLSClientConfig.NextProtos) != 1 || http1.TLSClientConfig.MinVersion != tls.VersionTLS13 {
+		t.Fatalf("HTTP/1.1 transport: http2 %t, ALPN %v", http1.ForceAttemptHTTP2, http1.TLSClientConfig.NextProtos)
+	}
+}
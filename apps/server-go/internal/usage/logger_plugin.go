@@ -71,6 +71,8 @@ type RequestStatistics struct {
 	requestsByHour map[int]int64
 	tokensByDay    map[string]int64
 	tokensByHour   map[int]int64
+
+	redactions map[string]int64
 }
 
 // apiStats holds aggregated metrics for a single API key.
@@ -118,6 +120,10 @@ type StatisticsSnapshot struct {
 	RequestsByHour map[string]int64 `json:"requests_by_hour"`
 	TokensByDay    map[string]int64 `json:"tokens_by_day"`
 	TokensByHour   map[string]int64 `json:"tokens_by_hour"`
+
+	// Redactions counts redaction filter hits per rule. Imports do not merge it because
+	// counters cannot be deduplicated.
+	Redactions map[string]int64 `json:"redactions,omitempty"`
 }
 
 // APISnapshot summarises metrics for a single API key.
@@ -147,6 +153,19 @@ func NewRequestStatistics() *RequestStatistics {
 		requestsByHour: make(map[int]int64),
 		tokensByDay:    make(map[string]int64),
 		tokensByHour:   make(map[int]int64),
+		redactions:     make(map[string]int64),
+	}
+}
+
+// RecordRedactions adds redaction filter hits, keyed by rule name.
+func (s *RequestStatistics) RecordRedactions(hits map[string]int) {
+	if s == nil || len(hits) == 0 || !statisticsEnabled.Load() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for rule, count := range hits {
+		s.redactions[rule] += int64(count)
 	}
 }
 
@@ -279,6 +298,13 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 		result.TokensByHour[key] = v
 	}
 
+	if len(s.redactions) > 0 {
+		result.Redactions = make(map[string]int64, len(s.redactions))
+		for rule, count := range s.redactions {
+			result.Redactions[rule] = count
+		}
+	}
+
 	return result
 }
 
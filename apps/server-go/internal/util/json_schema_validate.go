@@ -0,0 +1,337 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+// maxJSONSchemaViolations caps the number of violations reported by ValidateJSONSchema.
+const maxJSONSchemaViolations = 20
+
+// ValidateJSONSchema validates instance against a JSON Schema and returns one message per violation,
+// or nil when the instance is valid. It covers the keywords used by structured output schemas:
+// type, enum, const, properties, required, additionalProperties, patternProperties, items,
+// prefixItems, size and range limits, pattern, allOf/anyOf/oneOf/not and local $ref pointers.
+// format and other annotation keywords are ignored.
+func ValidateJSONSchema(schema, instance gjson.Result) []string {
+	v := &jsonSchemaValidator{root: schema}
+	v.validate(schema, instance, "$", 0)
+	return v.errors
+}
+
+type jsonSchemaValidator struct {
+	root   gjson.Result
+	errors []string
+}
+
+func (v *jsonSchemaValidator) fail(path, format string, args ...any) {
+	if len(v.errors) < maxJSONSchemaViolations {
+		v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+// matches reports whether instance satisfies schema without recording violations.
+func (v *jsonSchemaValidator) matches(schema, instance gjson.Result, path string, depth int) bool {
+	sub := &jsonSchemaValidator{root: v.root}
+	sub.validate(schema, instance, path, depth)
+	return len(sub.errors) == 0
+}
+
+func (v *jsonSchemaValidator) validate(schema, instance gjson.Result, path string, depth int) {
+	if depth > 64 {
+		v.fail(path, "schema nesting is too deep")
+		return
+	}
+	switch schema.Type {
+	case gjson.True:
+		return
+	case gjson.False:
+		v.fail(path, "no value is allowed here")
+		return
+	}
+	if !schema.IsObject() {
+		return
+	}
+
+	if ref := schema.Get(`\$ref`); ref.Type == gjson.String {
+		target, ok := v.resolveRef(ref.String())
+		if !ok {
+			v.fail(path, "unresolvable $ref %q", ref.String())
+			return
+		}
+		v.validate(target, instance, path, depth+1)
+	}
+
+	if typ := schema.Get("type"); typ.Exists() {
+		var allowed []string
+		if typ.IsArray() {
+			for _, item := range typ.Array() {
+				allowed = append(allowed, item.String())
+			}
+		} else {
+			allowed = []string{typ.String()}
+		}
+		actual := jsonSchemaInstanceType(instance)
+		ok := false
+		for _, want := range allowed {
+			if want == actual || (want == "number" && actual == "integer") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			v.fail(path, "expected %s, got %s", strings.Join(allowed, " or "), actual)
+			return
+		}
+	}
+
+	if enum := schema.Get("enum"); enum.IsArray() {
+		found := false
+		for _, candidate := range enum.Array() {
+			if jsonValuesEqual(candidate, instance) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "value must be one of %s", enum.Raw)
+		}
+	}
+	if constant := schema.Get("const"); constant.Exists() && !jsonValuesEqual(constant, instance) {
+		v.fail(path, "value must equal %s", constant.Raw)
+	}
+
+	switch {
+	case instance.Type == gjson.String:
+		v.validateString(schema, instance, path)
+	case instance.Type == gjson.Number:
+		v.validateNumber(schema, instance, path)
+	case instance.IsObject():
+		v.validateObject(schema, instance, path, depth)
+	case instance.IsArray():
+		v.validateArray(schema, instance, path, depth)
+	}
+
+	for _, sub := range schema.Get("allOf").Array() {
+		v.validate(sub, instance, path, depth+1)
+	}
+	if anyOf := schema.Get("anyOf"); anyOf.IsArray() {
+		matched := false
+		for _, sub := range anyOf.Array() {
+			if v.matches(sub, instance, path, depth+1) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "value does not match any of the anyOf schemas")
+		}
+	}
+	if oneOf := schema.Get("oneOf"); oneOf.IsArray() {
+		count := 0
+		for _, sub := range oneOf.Array() {
+			if v.matches(sub, instance, path, depth+1) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.fail(path, "value must match exactly one oneOf schema, matched %d", count)
+		}
+	}
+	if not := schema.Get("not"); not.Exists() && v.matches(not, instance, path, depth+1) {
+		v.fail(path, "value must not match the \"not\" schema")
+	}
+}
+
+func (v *jsonSchemaValidator) validateString(schema, instance gjson.Result, path string) {
+	length := int64(utf8.RuneCountInString(instance.String()))
+	if limit := schema.Get("minLength"); limit.Type == gjson.Number && length < limit.Int() {
+		v.fail(path, "string is shorter than %d characters", limit.Int())
+	}
+	if limit := schema.Get("maxLength"); limit.Type == gjson.Number && length > limit.Int() {
+		v.fail(path, "string is longer than %d characters", limit.Int())
+	}
+	if pattern := schema.Get("pattern"); pattern.Type == gjson.String {
+		if re, err := regexp.Compile(pattern.String()); err == nil && !re.MatchString(instance.String()) {
+			v.fail(path, "string does not match pattern %q", pattern.String())
+		}
+	}
+}
+
+func (v *jsonSchemaValidator) validateNumber(schema, instance gjson.Result, path string) {
+	value := instance.Float()
+	if limit := schema.Get("minimum"); limit.Type == gjson.Number && value < limit.Float() {
+		v.fail(path, "value must be >= %s", limit.Raw)
+	}
+	if limit := schema.Get("maximum"); limit.Type == gjson.Number && value > limit.Float() {
+		v.fail(path, "value must be <= %s", limit.Raw)
+	}
+	if limit := schema.Get("exclusiveMinimum"); limit.Type == gjson.Number && value <= limit.Float() {
+		v.fail(path, "value must be > %s", limit.Raw)
+	}
+	if limit := schema.Get("exclusiveMaximum"); limit.Type == gjson.Number && value >= limit.Float() {
+		v.fail(path, "value must be < %s", limit.Raw)
+	}
+	if divisor := schema.Get("multipleOf"); divisor.Type == gjson.Number && divisor.Float() > 0 {
+		quotient := value / divisor.Float()
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			v.fail(path, "value must be a multiple of %s", divisor.Raw)
+		}
+	}
+}
+
+func (v *jsonSchemaValidator) validateObject(schema, instance gjson.Result, path string, depth int) {
+	present := make(map[string]struct{})
+	instance.ForEach(func(key, _ gjson.Result) bool {
+		present[key.String()] = struct{}{}
+		return true
+	})
+	for _, name := range schema.Get("required").Array() {
+		if _, ok := present[name.String()]; !ok {
+			v.fail(path, "missing required property %q", name.String())
+		}
+	}
+
+	properties := make(map[string]gjson.Result)
+	schema.Get("properties").ForEach(func(key, value gjson.Result) bool {
+		properties[key.String()] = value
+		return true
+	})
+
+	var patterns []*regexp.Regexp
+	var patternSchemas []gjson.Result
+	schema.Get("patternProperties").ForEach(func(key, value gjson.Result) bool {
+		if re, err := regexp.Compile(key.String()); err == nil {
+			patterns = append(patterns, re)
+			patternSchemas = append(patternSchemas, value)
+		}
+		return true
+	})
+	additional := schema.Get("additionalProperties")
+
+	instance.ForEach(func(key, value gjson.Result) bool {
+		name := key.String()
+		childPath := path + "." + name
+		known := false
+		if property, ok := properties[name]; ok {
+			known = true
+			v.validate(property, value, childPath, depth+1)
+		}
+		for i, re := range patterns {
+			if re.MatchString(name) {
+				known = true
+				v.validate(patternSchemas[i], value, childPath, depth+1)
+			}
+		}
+		if !known && additional.Exists() {
+			if additional.Type == gjson.False {
+				v.fail(path, "additional property %q is not allowed", name)
+			} else {
+				v.validate(additional, value, childPath, depth+1)
+			}
+		}
+		return true
+	})
+
+	count := int64(len(present))
+	if limit := schema.Get("minProperties"); limit.Type == gjson.Number && count < limit.Int() {
+		v.fail(path, "object must have at least %d properties", limit.Int())
+	}
+	if limit := schema.Get("maxProperties"); limit.Type == gjson.Number && count > limit.Int() {
+		v.fail(path, "object must have at most %d properties", limit.Int())
+	}
+}
+
+func (v *jsonSchemaValidator) validateArray(schema, instance gjson.Result, path string, depth int) {
+	items := instance.Array()
+	prefix := schema.Get("prefixItems")
+	itemSchema := schema.Get("items")
+	if itemSchema.IsArray() {
+		// Draft 2019-09 and earlier express tuples with an items array.
+		prefix, itemSchema = itemSchema, schema.Get("additionalItems")
+	}
+	prefixSchemas := prefix.Array()
+	for i, item := range items {
+		childPath := path + "[" + strconv.Itoa(i) + "]"
+		switch {
+		case i < len(prefixSchemas):
+			v.validate(prefixSchemas[i], item, childPath, depth+1)
+		case itemSchema.Exists():
+			v.validate(itemSchema, item, childPath, depth+1)
+		}
+	}
+
+	if limit := schema.Get("minItems"); limit.Type == gjson.Number && int64(len(items)) < limit.Int() {
+		v.fail(path, "array must have at least %d items", limit.Int())
+	}
+	if limit := schema.Get("maxItems"); limit.Type == gjson.Number && int64(len(items)) > limit.Int() {
+		v.fail(path, "array must have at most %d items", limit.Int())
+	}
+	if schema.Get("uniqueItems").Bool() {
+		for i := range items {
+			for j := i + 1; j < len(items); j++ {
+				if jsonValuesEqual(items[i], items[j]) {
+					v.fail(path, "array items %d and %d are not unique", i, j)
+					return
+				}
+			}
+		}
+	}
+}
+
+// resolveRef resolves a local JSON pointer such as "#/$defs/item" against the root schema.
+func (v *jsonSchemaValidator) resolveRef(ref string) (gjson.Result, bool) {
+	if ref == "#" {
+		return v.root, true
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return gjson.Result{}, false
+	}
+	current := v.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		current = current.Get(escapeGJSONPathKey(token))
+		if !current.Exists() {
+			return gjson.Result{}, false
+		}
+	}
+	return current, true
+}
+
+func jsonSchemaInstanceType(instance gjson.Result) string {
+	switch {
+	case instance.Type == gjson.Null:
+		return "null"
+	case instance.Type == gjson.True || instance.Type == gjson.False:
+		return "boolean"
+	case instance.Type == gjson.String:
+		return "string"
+	case instance.Type == gjson.Number:
+		if value := instance.Float(); value == math.Trunc(value) && !strings.ContainsAny(instance.Raw, ".eE") {
+			return "integer"
+		}
+		return "number"
+	case instance.IsArray():
+		return "array"
+	case instance.IsObject():
+		return "object"
+	default:
+		return "undefined"
+	}
+}
+
+func jsonValuesEqual(a, b gjson.Result) bool {
+	var left, right any
+	if json.Unmarshal([]byte(a.Raw), &left) != nil || json.Unmarshal([]byte(b.Raw), &right) != nil {
+		return a.Raw == b.Raw
+	}
+	return reflect.DeepEqual(left, right)
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+const testStructuredSchema = `{
+	"type":"object",
+	"properties":{
+		"name":{"type":"string","minLength":1},
+		"age":{"type":"integer","minimum":0},
+		"tags":{"type":"array","items":{"$ref":"#/$defs/tag"},"maxItems":2},
+		"kind":{"anyOf":[{"const":"person"},{"type":"null"}]}
+	},
+	"required":["name","age","kind"],
+	"additionalProperties":false,
+	"$defs":{"tag":{"type":"string","enum":["a","b"]}}
+}`
+
+func TestValidateJSONSchema_Valid(t *testing.T) {
+	instance := gjson.Parse(`{"name":"Ada","age":36,"tags":["a","b"],"kind":null}`)
+	if errs := ValidateJSONSchema(gjson.Parse(testStructuredSchema), instance); len(errs) != 0 {
+		t.Fatalf("expected valid instance, got %v", errs)
+	}
+}
+
+func TestValidateJSONSchema_ReportsViolations(t *testing.T) {
+	instance := gjson.Parse(`{"name":"","age":1.5,"tags":["a","c","b"],"extra":true}`)
+	errs := ValidateJSONSchema(gjson.Parse(testStructuredSchema), instance)
+	joined := strings.Join(errs, "\n")
+	for _, want := range []string{
+		`$: missing required property "kind"`,
+		`$.name: string is shorter than 1 characters`,
+		`$.age: expected integer, got number`,
+		`$.tags[1]: value must be one of ["a","b"]`,
+		`$.tags: array must have at most 2 items`,
+		`$: additional property "extra" is not allowed`,
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("missing violation %q in:\n%s", want, joined)
+		}
+	}
+}
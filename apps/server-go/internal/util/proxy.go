@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upstreamtls"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/proxy"
@@ -16,7 +17,7 @@ import (
 
 // SetProxy configures the provided HTTP client with proxy settings from the configuration.
 // It supports SOCKS5, HTTP, and HTTPS proxies. The function modifies the client's transport
-// to route requests through the configured proxy server.
+// to route requests through the configured proxy server and applies the upstream TLS settings.
 func SetProxy(cfg *config.SDKConfig, httpClient *http.Client) *http.Client {
 	var transport *http.Transport
 	// Attempt to parse the proxy URL from the configuration.
@@ -51,5 +52,9 @@ func SetProxy(cfg *config.SDKConfig, httpClient *http.Client) *http.Client {
 	if transport != nil {
 		httpClient.Transport = transport
 	}
+	// Apply the upstream TLS settings of the credential named by each request's context.
+	if httpClient.Transport == nil || transport != nil {
+		httpClient.Transport = upstreamtls.Wrap(httpClient.Transport)
+	}
 	return httpClient
 }
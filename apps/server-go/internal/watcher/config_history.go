@@ -0,0 +1,137 @@
+// config_history.go keeps the last known-good config.yaml contents and restores
+// them when a hot reload fails validation.
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultConfigHistorySize = 5
+	rejectedConfigSuffix     = ".rejected"
+)
+
+// configSnapshot is a known-good config.yaml revision.
+type configSnapshot struct {
+	data      []byte
+	hash      string
+	appliedAt time.Time
+}
+
+func hashConfigData(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (w *Watcher) configHistoryLimit() int {
+	w.clientsMutex.RLock()
+	cfg := w.config
+	w.clientsMutex.RUnlock()
+	if cfg != nil && cfg.ConfigReload.HistorySize > 0 {
+		return cfg.ConfigReload.HistorySize
+	}
+	return defaultConfigHistorySize
+}
+
+func (w *Watcher) rollbackDisabled() bool {
+	w.clientsMutex.RLock()
+	defer w.clientsMutex.RUnlock()
+	return w.config != nil && w.config.ConfigReload.DisableRollback
+}
+
+// recordGoodConfig appends data to the known-good history, trimming it to the configured size.
+func (w *Watcher) recordGoodConfig(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	limit := w.configHistoryLimit()
+	snapshot := configSnapshot{
+		data:      append([]byte(nil), data...),
+		hash:      hashConfigData(data),
+		appliedAt: time.Now(),
+	}
+	w.configHistoryMu.Lock()
+	defer w.configHistoryMu.Unlock()
+	if n := len(w.configHistory); n > 0 && w.configHistory[n-1].hash == snapshot.hash {
+		w.configHistory[n-1].appliedAt = snapshot.appliedAt
+		return
+	}
+	w.configHistory = append(w.configHistory, snapshot)
+	if len(w.configHistory) > limit {
+		w.configHistory = append([]configSnapshot(nil), w.configHistory[len(w.configHistory)-limit:]...)
+	}
+}
+
+// lastGoodConfig returns the most recent known-good snapshot, if any.
+func (w *Watcher) lastGoodConfig() (configSnapshot, bool) {
+	w.configHistoryMu.Lock()
+	defer w.configHistoryMu.Unlock()
+	if len(w.configHistory) == 0 {
+		return configSnapshot{}, false
+	}
+	return w.configHistory[len(w.configHistory)-1], true
+}
+
+// seedConfigHistory records the config file present at startup when it validates cleanly.
+func (w *Watcher) seedConfigHistory() {
+	data, err := os.ReadFile(w.configPath)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	if config.HasValidationErrors(config.ValidateConfigData(data, filepath.Dir(w.configPath))) {
+		return
+	}
+	w.recordGoodConfig(data)
+}
+
+// rejectConfig logs why data was not applied and, unless disabled, restores the last
+// known-good config.yaml. The rejected contents are preserved next to the config file.
+func (w *Watcher) rejectConfig(data []byte, issues []config.ValidationIssue) {
+	for _, issue := range issues {
+		if issue.Severity == config.ValidationSeverityError {
+			log.Errorf("config validation failed: %s", issue.String())
+		}
+	}
+	rejectedHash := hashConfigData(data)
+	if w.rollbackDisabled() {
+		log.Warn("config reload rejected; keeping invalid config.yaml on disk (rollback disabled), previous config stays active")
+		w.clientsMutex.Lock()
+		w.lastConfigHash = rejectedHash
+		w.clientsMutex.Unlock()
+		return
+	}
+	last, ok := w.lastGoodConfig()
+	if !ok {
+		log.Warn("config reload rejected; no known-good config available to roll back to")
+		w.clientsMutex.Lock()
+		w.lastConfigHash = rejectedHash
+		w.clientsMutex.Unlock()
+		return
+	}
+
+	rejectedPath := w.configPath + rejectedConfigSuffix
+	if errWrite := os.WriteFile(rejectedPath, data, 0o600); errWrite != nil {
+		log.WithError(errWrite).Warn("failed to preserve rejected config")
+	}
+	mode := os.FileMode(0o644)
+	if info, errStat := os.Stat(w.configPath); errStat == nil {
+		mode = info.Mode().Perm()
+	}
+	// Record the hash before writing so the resulting fsnotify event is treated as unchanged.
+	w.clientsMutex.Lock()
+	w.lastConfigHash = last.hash
+	w.clientsMutex.Unlock()
+	if errWrite := os.WriteFile(w.configPath, last.data, mode); errWrite != nil {
+		log.Errorf("failed to roll back config to last known-good revision: %v", errWrite)
+		return
+	}
+	log.Warnf("config reload rejected; rolled back %s to known-good revision from %s (rejected copy saved to %s)",
+		w.configPath, last.appliedAt.Format(time.RFC3339), rejectedPath)
+}
@@ -3,9 +3,8 @@
 package watcher
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"os"
+	"path/filepath"
 	"reflect"
 	"time"
 
@@ -50,8 +49,7 @@ func (w *Watcher) reloadConfigIfChanged() {
 		log.Debugf("ignoring empty config file write event")
 		return
 	}
-	sum := sha256.Sum256(data)
-	newHash := hex.EncodeToString(sum[:])
+	newHash := hashConfigData(data)
 
 	w.clientsMutex.RLock()
 	currentHash := w.lastConfigHash
@@ -62,19 +60,25 @@ func (w *Watcher) reloadConfigIfChanged() {
 		return
 	}
 	log.Infof("config file changed, reloading: %s", w.configPath)
-	if w.reloadConfig() {
-		finalHash := newHash
-		if updatedData, errRead := os.ReadFile(w.configPath); errRead == nil && len(updatedData) > 0 {
-			sumUpdated := sha256.Sum256(updatedData)
-			finalHash = hex.EncodeToString(sumUpdated[:])
-		} else if errRead != nil {
-			log.WithError(errRead).Debug("failed to compute updated config hash after reload")
-		}
-		w.clientsMutex.Lock()
-		w.lastConfigHash = finalHash
-		w.clientsMutex.Unlock()
-		w.persistConfigAsync()
+	if issues := config.ValidateConfigData(data, filepath.Dir(w.configPath)); config.HasValidationErrors(issues) {
+		w.rejectConfig(data, issues)
+		return
+	}
+	if !w.reloadConfig() {
+		w.rejectConfig(data, nil)
+		return
 	}
+	finalData := data
+	if updatedData, errRead := os.ReadFile(w.configPath); errRead == nil && len(updatedData) > 0 {
+		finalData = updatedData
+	} else if errRead != nil {
+		log.WithError(errRead).Debug("failed to compute updated config hash after reload")
+	}
+	w.clientsMutex.Lock()
+	w.lastConfigHash = hashConfigData(finalData)
+	w.clientsMutex.Unlock()
+	w.recordGoodConfig(finalData)
+	w.persistConfigAsync()
 }
 
 func (w *Watcher) reloadConfig() bool {
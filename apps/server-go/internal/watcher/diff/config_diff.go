@@ -69,6 +69,135 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.NonStreamKeepAliveInterval != newCfg.NonStreamKeepAliveInterval {
 		changes = append(changes, fmt.Sprintf("nonstream-keepalive-interval: %d -> %d", oldCfg.NonStreamKeepAliveInterval, newCfg.NonStreamKeepAliveInterval))
 	}
+	if oldCfg.Streaming.ResumeGraceSeconds != newCfg.Streaming.ResumeGraceSeconds {
+		changes = append(changes, fmt.Sprintf("streaming.resume-grace-seconds: %d -> %d", oldCfg.Streaming.ResumeGraceSeconds, newCfg.Streaming.ResumeGraceSeconds))
+	}
+	if oldCfg.Streaming.ResumeMaxEvents != newCfg.Streaming.ResumeMaxEvents {
+		changes = append(changes, fmt.Sprintf("streaming.resume-max-events: %d -> %d", oldCfg.Streaming.ResumeMaxEvents, newCfg.Streaming.ResumeMaxEvents))
+	}
+
+	if oldCfg.Guardrails.ContextWindow != newCfg.Guardrails.ContextWindow {
+		changes = append(changes, fmt.Sprintf("guardrails.context-window: %t -> %t", oldCfg.Guardrails.ContextWindow, newCfg.Guardrails.ContextWindow))
+	}
+	if oldCfg.Guardrails.ClampOutputTokens != newCfg.Guardrails.ClampOutputTokens {
+		changes = append(changes, fmt.Sprintf("guardrails.clamp-output-tokens: %t -> %t", oldCfg.Guardrails.ClampOutputTokens, newCfg.Guardrails.ClampOutputTokens))
+	}
+	if !reflect.DeepEqual(oldCfg.Guardrails.ContextFallbacks, newCfg.Guardrails.ContextFallbacks) {
+		changes = append(changes, fmt.Sprintf("guardrails.context-fallbacks: %d -> %d entries", len(oldCfg.Guardrails.ContextFallbacks), len(newCfg.Guardrails.ContextFallbacks)))
+	}
+	if oldCfg.ContextCompaction.Enabled != newCfg.ContextCompaction.Enabled {
+		changes = append(changes, fmt.Sprintf("context-compaction.enabled: %t -> %t", oldCfg.ContextCompaction.Enabled, newCfg.ContextCompaction.Enabled))
+	}
+	if !reflect.DeepEqual(oldCfg.ContextCompaction.Models, newCfg.ContextCompaction.Models) {
+		changes = append(changes, fmt.Sprintf("context-compaction.models: %d -> %d entries", len(oldCfg.ContextCompaction.Models), len(newCfg.ContextCompaction.Models)))
+	}
+	if oldCfg.ContextCompaction.Threshold != newCfg.ContextCompaction.Threshold {
+		changes = append(changes, fmt.Sprintf("context-compaction.threshold: %g -> %g", oldCfg.ContextCompaction.Threshold, newCfg.ContextCompaction.Threshold))
+	}
+	if oldCfg.ContextCompaction.SummaryModel != newCfg.ContextCompaction.SummaryModel {
+		changes = append(changes, fmt.Sprintf("context-compaction.summary-model: %s -> %s", oldCfg.ContextCompaction.SummaryModel, newCfg.ContextCompaction.SummaryModel))
+	}
+	if oldCfg.ContextCompaction.KeepRecent != newCfg.ContextCompaction.KeepRecent {
+		changes = append(changes, fmt.Sprintf("context-compaction.keep-recent: %d -> %d", oldCfg.ContextCompaction.KeepRecent, newCfg.ContextCompaction.KeepRecent))
+	}
+	if oldCfg.ConfigReload.HistorySize != newCfg.ConfigReload.HistorySize {
+		changes = append(changes, fmt.Sprintf("config-reload.history-size: %d -> %d", oldCfg.ConfigReload.HistorySize, newCfg.ConfigReload.HistorySize))
+	}
+	if oldCfg.ConfigReload.DisableRollback != newCfg.ConfigReload.DisableRollback {
+		changes = append(changes, fmt.Sprintf("config-reload.disable-rollback: %t -> %t", oldCfg.ConfigReload.DisableRollback, newCfg.ConfigReload.DisableRollback))
+	}
+	if !reflect.DeepEqual(oldCfg.Cluster, newCfg.Cluster) {
+		changes = append(changes, "cluster: updated (applies after restart)")
+	}
+	if !reflect.DeepEqual(oldCfg.SignatureCache, newCfg.SignatureCache) {
+		changes = append(changes, "signature-cache: updated")
+	}
+	if !reflect.DeepEqual(oldCfg.HealthProbe, newCfg.HealthProbe) {
+		changes = append(changes, "health-probe: updated")
+	}
+	if !reflect.DeepEqual(oldCfg.QuotaForecast, newCfg.QuotaForecast) {
+		changes = append(changes, "quota-forecast: updated")
+	}
+	if !reflect.DeepEqual(oldCfg.MockProvider, newCfg.MockProvider) {
+		changes = append(changes, fmt.Sprintf("mock-provider: %d -> %d entries", len(oldCfg.MockProvider), len(newCfg.MockProvider)))
+	}
+	if !reflect.DeepEqual(oldCfg.ProxyPools, newCfg.ProxyPools) {
+		changes = append(changes, fmt.Sprintf("proxy-pools: %d -> %d pools", len(oldCfg.ProxyPools), len(newCfg.ProxyPools)))
+	}
+	if !reflect.DeepEqual(oldCfg.UpstreamTLS, newCfg.UpstreamTLS) {
+		changes = append(changes, "upstream-tls: updated")
+	}
+	if !reflect.DeepEqual(oldCfg.Notifications, newCfg.Notifications) {
+		changes = append(changes, fmt.Sprintf("notifications: enabled %t -> %t, %d -> %d webhooks, %d -> %d commands",
+			oldCfg.Notifications.Enabled, newCfg.Notifications.Enabled,
+			len(oldCfg.Notifications.Webhooks), len(newCfg.Notifications.Webhooks),
+			len(oldCfg.Notifications.Commands), len(newCfg.Notifications.Commands)))
+	}
+	if !reflect.DeepEqual(oldCfg.Cassette, newCfg.Cassette) {
+		changes = append(changes, fmt.Sprintf("cassette: mode %q -> %q", oldCfg.Cassette.Mode, newCfg.Cassette.Mode))
+	}
+	if oldCfg.StructuredOutput.Emulate != newCfg.StructuredOutput.Emulate {
+		changes = append(changes, fmt.Sprintf("structured-output.emulate: %t -> %t", oldCfg.StructuredOutput.Emulate, newCfg.StructuredOutput.Emulate))
+	}
+	if oldCfg.StructuredOutput.MaxRetries != newCfg.StructuredOutput.MaxRetries {
+		changes = append(changes, fmt.Sprintf("structured-output.max-retries: %d -> %d", oldCfg.StructuredOutput.MaxRetries, newCfg.StructuredOutput.MaxRetries))
+	}
+	if !reflect.DeepEqual(oldCfg.ParameterEmulation, newCfg.ParameterEmulation) {
+		changes = append(changes, "parameter-emulation: updated")
+	}
+	if oldCfg.ParameterPolicy.Default != newCfg.ParameterPolicy.Default {
+		changes = append(changes, fmt.Sprintf("parameter-policy.default: %s -> %s", oldCfg.ParameterPolicy.Default, newCfg.ParameterPolicy.Default))
+	}
+	if !reflect.DeepEqual(oldCfg.ParameterPolicy.Models, newCfg.ParameterPolicy.Models) {
+		changes = append(changes, fmt.Sprintf("parameter-policy.models: %d -> %d entries", len(oldCfg.ParameterPolicy.Models), len(newCfg.ParameterPolicy.Models)))
+	}
+	if !reflect.DeepEqual(oldCfg.PolicyTemplates, newCfg.PolicyTemplates) {
+		changes = append(changes, fmt.Sprintf("policy-templates: %d -> %d templates", len(oldCfg.PolicyTemplates), len(newCfg.PolicyTemplates)))
+	}
+	if !reflect.DeepEqual(oldCfg.Redaction, newCfg.Redaction) {
+		changes = append(changes, fmt.Sprintf("redaction: enabled %t -> %t, %d -> %d rules", oldCfg.Redaction.Enabled, newCfg.Redaction.Enabled, len(oldCfg.Redaction.Rules), len(newCfg.Redaction.Rules)))
+	}
+	if oldCfg.MCP.Enabled != newCfg.MCP.Enabled {
+		changes = append(changes, fmt.Sprintf("mcp.enabled: %t -> %t", oldCfg.MCP.Enabled, newCfg.MCP.Enabled))
+	}
+	if oldCfg.MCP.AllowRemoteServers != newCfg.MCP.AllowRemoteServers {
+		changes = append(changes, fmt.Sprintf("mcp.allow-remote-servers: %t -> %t", oldCfg.MCP.AllowRemoteServers, newCfg.MCP.AllowRemoteServers))
+	}
+	if oldCfg.MCP.CallTimeout != newCfg.MCP.CallTimeout || oldCfg.MCP.MaxTurns != newCfg.MCP.MaxTurns || !reflect.DeepEqual(oldCfg.MCP.Servers, newCfg.MCP.Servers) {
+		changes = append(changes, "mcp: updated")
+	}
+	if oldCfg.Sandbox.Enabled != newCfg.Sandbox.Enabled {
+		changes = append(changes, fmt.Sprintf("sandbox.enabled: %t -> %t", oldCfg.Sandbox.Enabled, newCfg.Sandbox.Enabled))
+	}
+	if oldCfg.Sandbox.AllowNetwork != newCfg.Sandbox.AllowNetwork {
+		changes = append(changes, fmt.Sprintf("sandbox.allow-network: %t -> %t", oldCfg.Sandbox.AllowNetwork, newCfg.Sandbox.AllowNetwork))
+	}
+	if oldCfg.Sandbox.AllowHostFilesystem != newCfg.Sandbox.AllowHostFilesystem {
+		changes = append(changes, fmt.Sprintf("sandbox.allow-host-filesystem: %t -> %t", oldCfg.Sandbox.AllowHostFilesystem, newCfg.Sandbox.AllowHostFilesystem))
+	}
+	if !reflect.DeepEqual(oldCfg.Sandbox.Tools, newCfg.Sandbox.Tools) || !reflect.DeepEqual(oldCfg.Sandbox.AllowedCommands, newCfg.Sandbox.AllowedCommands) {
+		changes = append(changes, "sandbox.tools/allowed-commands: updated")
+	}
+	if oldSandbox, newSandbox := oldCfg.Sandbox, newCfg.Sandbox; oldSandbox.PythonCommand != newSandbox.PythonCommand || oldSandbox.WorkDir != newSandbox.WorkDir ||
+		oldSandbox.Timeout != newSandbox.Timeout || oldSandbox.CPUSeconds != newSandbox.CPUSeconds || oldSandbox.MemoryMB != newSandbox.MemoryMB ||
+		oldSandbox.MaxProcesses != newSandbox.MaxProcesses || oldSandbox.MaxOutputBytes != newSandbox.MaxOutputBytes ||
+		oldSandbox.MaxTurns != newSandbox.MaxTurns || oldSandbox.AuditLog != newSandbox.AuditLog {
+		changes = append(changes, "sandbox: updated")
+	}
+	if oldCfg.GeminiFiles.Enabled != newCfg.GeminiFiles.Enabled {
+		changes = append(changes, fmt.Sprintf("gemini-files.enabled: %t -> %t", oldCfg.GeminiFiles.Enabled, newCfg.GeminiFiles.Enabled))
+	}
+	if oldFiles, newFiles := oldCfg.GeminiFiles, newCfg.GeminiFiles; oldFiles.Dir != newFiles.Dir || oldFiles.MaxFileMB != newFiles.MaxFileMB ||
+		oldFiles.FileTTLHours != newFiles.FileTTLHours || oldFiles.CacheTTLSeconds != newFiles.CacheTTLSeconds || oldFiles.MaxCacheTTLHours != newFiles.MaxCacheTTLHours {
+		changes = append(changes, "gemini-files: updated")
+	}
+	if oldCfg.Files.Enabled != newCfg.Files.Enabled {
+		changes = append(changes, fmt.Sprintf("files.enabled: %t -> %t", oldCfg.Files.Enabled, newCfg.Files.Enabled))
+	}
+	if oldFiles, newFiles := oldCfg.Files, newCfg.Files; oldFiles.Dir != newFiles.Dir || oldFiles.MaxFileMB != newFiles.MaxFileMB ||
+		oldFiles.QuotaMB != newFiles.QuotaMB || oldFiles.TTLHours != newFiles.TTLHours {
+		changes = append(changes, "files: updated")
+	}
 
 	// Quota-exceeded behavior
 	if oldCfg.QuotaExceeded.SwitchProject != newCfg.QuotaExceeded.SwitchProject {
@@ -88,6 +217,11 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	} else if !reflect.DeepEqual(trimStrings(oldCfg.APIKeys), trimStrings(newCfg.APIKeys)) {
 		changes = append(changes, "api-keys: values updated (count unchanged, redacted)")
 	}
+	if len(oldCfg.ClientAPIKeys) != len(newCfg.ClientAPIKeys) {
+		changes = append(changes, fmt.Sprintf("client-api-keys count: %d -> %d", len(oldCfg.ClientAPIKeys), len(newCfg.ClientAPIKeys)))
+	} else if !reflect.DeepEqual(oldCfg.ClientAPIKeys, newCfg.ClientAPIKeys) {
+		changes = append(changes, "client-api-keys: entries updated (count unchanged, redacted)")
+	}
 	if len(oldCfg.GeminiKey) != len(newCfg.GeminiKey) {
 		changes = append(changes, fmt.Sprintf("gemini-api-key count: %d -> %d", len(oldCfg.GeminiKey), len(newCfg.GeminiKey)))
 	} else {
@@ -0,0 +1,119 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// ConfigChangeEffects summarizes the runtime impact of applying newCfg over oldCfg.
+// Like BuildConfigChangeDetails, it never includes secret material.
+type ConfigChangeEffects struct {
+	// ModelListings describes which providers will have their model listings re-registered.
+	ModelListings []string `json:"model-listings"`
+	// AccessProviders describes how client authentication will change.
+	AccessProviders []string `json:"access-providers"`
+	// RequiresRestart lists changed fields that only take effect after a process restart.
+	RequiresRestart []string `json:"requires-restart"`
+}
+
+// BuildConfigChangeEffects computes the effects of a config transition on model listings,
+// access providers and restart-only settings.
+func BuildConfigChangeEffects(oldCfg, newCfg *config.Config) ConfigChangeEffects {
+	effects := ConfigChangeEffects{
+		ModelListings:   []string{},
+		AccessProviders: []string{},
+		RequiresRestart: []string{},
+	}
+	if oldCfg == nil || newCfg == nil {
+		return effects
+	}
+
+	affected := make(map[string]struct{})
+	_, aliasProviders := DiffOAuthModelAliasChanges(oldCfg.OAuthModelAlias, newCfg.OAuthModelAlias)
+	for _, p := range aliasProviders {
+		affected[p] = struct{}{}
+	}
+	_, excludedProviders := DiffOAuthExcludedModelChanges(oldCfg.OAuthExcludedModels, newCfg.OAuthExcludedModels)
+	for _, p := range excludedProviders {
+		affected[p] = struct{}{}
+	}
+	providers := make([]string, 0, len(affected))
+	for p := range affected {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	for _, p := range providers {
+		effects.ModelListings = append(effects.ModelListings, fmt.Sprintf("%s: model listing re-registered", p))
+	}
+	if oldCfg.ForceModelPrefix != newCfg.ForceModelPrefix {
+		effects.ModelListings = append(effects.ModelListings, fmt.Sprintf("all providers: force-model-prefix %t -> %t changes which prefixed models are visible", oldCfg.ForceModelPrefix, newCfg.ForceModelPrefix))
+	}
+	if compat := DiffOpenAICompatibility(oldCfg.OpenAICompatibility, newCfg.OpenAICompatibility); len(compat) > 0 {
+		effects.ModelListings = append(effects.ModelListings, fmt.Sprintf("openai-compatibility: %d provider(s) changed", len(compat)))
+	}
+
+	if !reflect.DeepEqual(oldCfg.MockProvider, newCfg.MockProvider) {
+		effects.ModelListings = append(effects.ModelListings, "mock: model listing re-registered")
+	}
+
+	oldKeys := indexClientAPIKeys(oldCfg)
+	newKeys := indexClientAPIKeys(newCfg)
+	added, removed, rescoped := 0, 0, 0
+	for key, entry := range newKeys {
+		prev, ok := oldKeys[key]
+		if !ok {
+			added++
+			continue
+		}
+		if !reflect.DeepEqual(prev.Scope, entry.Scope) {
+			rescoped++
+		}
+	}
+	for key := range oldKeys {
+		if _, ok := newKeys[key]; !ok {
+			removed++
+		}
+	}
+	if added > 0 {
+		effects.AccessProviders = append(effects.AccessProviders, fmt.Sprintf("client keys added: %d", added))
+	}
+	if removed > 0 {
+		effects.AccessProviders = append(effects.AccessProviders, fmt.Sprintf("client keys removed: %d (requests using them will be rejected)", removed))
+	}
+	if rescoped > 0 {
+		effects.AccessProviders = append(effects.AccessProviders, fmt.Sprintf("client keys re-scoped: %d", rescoped))
+	}
+	switch {
+	case len(oldKeys) > 0 && len(newKeys) == 0:
+		effects.AccessProviders = append(effects.AccessProviders, "config-api-key provider unregistered: requests will no longer require a client key")
+	case len(oldKeys) == 0 && len(newKeys) > 0:
+		effects.AccessProviders = append(effects.AccessProviders, "config-api-key provider registered: requests will require a client key")
+	}
+
+	if strings.TrimSpace(oldCfg.Host) != strings.TrimSpace(newCfg.Host) {
+		effects.RequiresRestart = append(effects.RequiresRestart, "host")
+	}
+	if oldCfg.Port != newCfg.Port {
+		effects.RequiresRestart = append(effects.RequiresRestart, "port")
+	}
+	if oldCfg.TLS != newCfg.TLS {
+		effects.RequiresRestart = append(effects.RequiresRestart, "tls")
+	}
+	if oldCfg.CommercialMode != newCfg.CommercialMode {
+		effects.RequiresRestart = append(effects.RequiresRestart, "commercial-mode")
+	}
+	return effects
+}
+
+func indexClientAPIKeys(cfg *config.Config) map[string]config.ClientAPIKey {
+	keys := cfg.EffectiveClientAPIKeys()
+	out := make(map[string]config.ClientAPIKey, len(keys))
+	for _, entry := range keys {
+		out[entry.Key] = entry
+	}
+	return out
+}
@@ -86,6 +86,18 @@ func ComputeGeminiModelsHash(models []config.GeminiModel) string {
 	return hashJoined(keys)
 }
 
+// ComputeMockModelsHash returns a stable hash for the model names served by a mock credential.
+func ComputeMockModelsHash(models []config.MockModel) string {
+	keys := normalizeModelPairs(func(out func(key string)) {
+		for _, model := range models {
+			if name := strings.TrimSpace(model.Name); name != "" {
+				out(strings.ToLower(name))
+			}
+		}
+	})
+	return hashJoined(keys)
+}
+
 // ComputeExcludedModelsHash returns a normalized hash for excluded model lists.
 func ComputeExcludedModelsHash(excluded []string) string {
 	if len(excluded) == 0 {
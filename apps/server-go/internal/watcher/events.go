@@ -40,6 +40,7 @@ func (w *Watcher) start(ctx context.Context) error {
 	log.Debugf("watching auth directory: %s", w.authDir)
 
 	w.ensureAuggieSessionSourceWatches()
+	w.seedConfigHistory()
 
 	go w.processEvents(ctx)
 
@@ -10,7 +10,7 @@ import (
 )
 
 // ConfigSynthesizer generates Auth entries from configuration API keys.
-// It handles Gemini, Claude, Codex, OpenAI-compat, and Vertex-compat providers.
+// It handles Gemini, Claude, Codex, OpenAI-compat, Vertex-compat and mock providers.
 type ConfigSynthesizer struct{}
 
 // NewConfigSynthesizer creates a new ConfigSynthesizer instance.
@@ -35,6 +35,8 @@ func (s *ConfigSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth,
 	out = append(out, s.synthesizeOpenAICompat(ctx)...)
 	// Vertex-compat
 	out = append(out, s.synthesizeVertexCompat(ctx)...)
+	// Mock provider
+	out = append(out, s.synthesizeMockProviders(ctx)...)
 
 	return out, nil
 }
@@ -320,3 +322,41 @@ func (s *ConfigSynthesizer) synthesizeVertexCompat(ctx *SynthesisContext) []*cor
 	}
 	return out
 }
+
+// synthesizeMockProviders creates Auth entries for the built-in mock provider.
+func (s *ConfigSynthesizer) synthesizeMockProviders(ctx *SynthesisContext) []*coreauth.Auth {
+	cfg := ctx.Config
+	now := ctx.Now
+	idGen := ctx.IDGenerator
+
+	out := make([]*coreauth.Auth, 0, len(cfg.MockProvider))
+	for i := range cfg.MockProvider {
+		entry := &cfg.MockProvider[i]
+		name := strings.TrimSpace(entry.Name)
+		if name == "" {
+			continue
+		}
+		id, token := idGen.Next("mock", strings.ToLower(name))
+		attrs := map[string]string{
+			"source":    fmt.Sprintf("config:mock[%s]", token),
+			"mock_name": name,
+		}
+		if entry.Priority != 0 {
+			attrs["priority"] = strconv.Itoa(entry.Priority)
+		}
+		if hash := diff.ComputeMockModelsHash(entry.Models); hash != "" {
+			attrs["models_hash"] = hash
+		}
+		out = append(out, &coreauth.Auth{
+			ID:         id,
+			Provider:   "mock",
+			Label:      name,
+			Prefix:     strings.TrimSpace(entry.Prefix),
+			Status:     coreauth.StatusActive,
+			Attributes: attrs,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+	}
+	return out
+}
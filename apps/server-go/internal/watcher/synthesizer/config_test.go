@@ -615,3 +615,32 @@ func TestConfigSynthesizer_AllProviders(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigSynthesizer_MockProviders(t *testing.T) {
+	synth := NewConfigSynthesizer()
+	ctx := &SynthesisContext{
+		Config: &config.Config{
+			MockProvider: []config.MockProvider{
+				{Name: "", Models: []config.MockModel{{Name: "skipped"}}},
+				{Name: "local", Prefix: "mock", Priority: 3, Models: []config.MockModel{{Name: "mock-echo"}}},
+			},
+		},
+		Now:         time.Now(),
+		IDGenerator: NewStableIDGenerator(),
+	}
+
+	auths, err := synth.Synthesize(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(auths) != 1 {
+		t.Fatalf("expected 1 auth, got %d", len(auths))
+	}
+	a := auths[0]
+	if a.Provider != "mock" || a.Label != "local" || a.Prefix != "mock" {
+		t.Errorf("unexpected auth %+v", a)
+	}
+	if a.Attributes["mock_name"] != "local" || a.Attributes["priority"] != "3" || a.Attributes["models_hash"] == "" {
+		t.Errorf("unexpected attributes %v", a.Attributes)
+	}
+}
@@ -52,6 +52,8 @@ type Watcher struct {
 	storePersister    storePersister
 	mirroredAuthDir   string
 	oldConfigYaml     []byte
+	configHistoryMu   sync.Mutex
+	configHistory     []configSnapshot
 }
 
 // AuthUpdateAction represents the type of change detected in auth sources.
@@ -1885,3 +1885,78 @@ func useRealAuggieSessionLoader(t *testing.T) {
 func hexString(data []byte) string {
 	return strings.ToLower(fmt.Sprintf("%x", data))
 }
+
+func TestReloadConfigIfChanged_RollsBackInvalidConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	authDir := filepath.Join(tmpDir, "auth")
+	if err := os.MkdirAll(authDir, 0o755); err != nil {
+		t.Fatalf("failed to create auth dir: %v", err)
+	}
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	good := []byte("port: 8080\nauth-dir: " + authDir + "\n")
+	if err := os.WriteFile(configPath, good, 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	reloads := 0
+	w := &Watcher{
+		configPath:     configPath,
+		authDir:        authDir,
+		reloadCallback: func(*config.Config) { reloads++ },
+	}
+	w.reloadConfigIfChanged()
+	if reloads != 1 {
+		t.Fatalf("expected initial reload, got %d", reloads)
+	}
+
+	bad := []byte("port: 9090\nauth-dir: " + authDir + "\noauth-model-alias:\n  antigravity:\n    - name: \"m1\"\n      alais: \"m2\"\n")
+	if err := os.WriteFile(configPath, bad, 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	w.reloadConfigIfChanged()
+	if reloads != 1 {
+		t.Fatalf("expected invalid config not to be applied, got %d reloads", reloads)
+	}
+
+	restored, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if string(restored) != string(good) {
+		t.Fatalf("expected config to be rolled back, got %q", restored)
+	}
+	rejected, err := os.ReadFile(configPath + rejectedConfigSuffix)
+	if err != nil || string(rejected) != string(bad) {
+		t.Fatalf("expected rejected config to be preserved, got %q (err=%v)", rejected, err)
+	}
+	w.clientsMutex.RLock()
+	port := w.config.Port
+	w.clientsMutex.RUnlock()
+	if port != 8080 {
+		t.Fatalf("expected active config to keep port 8080, got %d", port)
+	}
+
+	// The rollback write must not trigger another reload.
+	w.reloadConfigIfChanged()
+	if reloads != 1 {
+		t.Fatalf("expected rolled back config to be treated as unchanged, got %d reloads", reloads)
+	}
+}
+
+func TestRecordGoodConfigTrimsHistory(t *testing.T) {
+	w := &Watcher{}
+	w.SetConfig(&config.Config{ConfigReload: config.ConfigReloadConfig{HistorySize: 2}})
+	for i := 0; i < 4; i++ {
+		w.recordGoodConfig([]byte(fmt.Sprintf("port: %d\n", 8000+i)))
+	}
+	w.recordGoodConfig([]byte("port: 8003\n"))
+
+	w.configHistoryMu.Lock()
+	defer w.configHistoryMu.Unlock()
+	if len(w.configHistory) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(w.configHistory))
+	}
+	if string(w.configHistory[0].data) != "port: 8002\n" || string(w.configHistory[1].data) != "port: 8003\n" {
+		t.Fatalf("unexpected history contents: %q, %q", w.configHistory[0].data, w.configHistory[1].data)
+	}
+}
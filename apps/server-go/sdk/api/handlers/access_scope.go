@@ -9,10 +9,14 @@ import (
 )
 
 const (
-	AccessScopeProviderContextKey = "accessScopeProvider"
-	AccessScopeAuthIDContextKey   = "accessScopeAuthID"
-	AccessScopeModelsContextKey   = "accessScopeModels"
-	AccessKeyNoteContextKey       = "accessKeyNote"
+	AccessScopeProviderContextKey     = "accessScopeProvider"
+	AccessScopeAuthIDContextKey       = "accessScopeAuthID"
+	AccessScopeModelsContextKey       = "accessScopeModels"
+	AccessKeyNoteContextKey           = "accessKeyNote"
+	AccessContextCompactionContextKey = "accessContextCompaction"
+	AccessParameterPolicyContextKey   = "accessParameterPolicy"
+	AccessSandboxContextKey           = "accessSandbox"
+	AccessPoliciesContextKey          = "accessPolicies"
 )
 
 type AccessScope struct {
@@ -63,6 +63,14 @@ func (h *ClaudeCodeAPIHandler) Models() []map[string]any {
 // Parameters:
 //   - c: The Gin context for the request.
 func (h *ClaudeCodeAPIHandler) ClaudeMessages(c *gin.Context) {
+	// A reconnect to a resumable stream replays its buffered events instead of starting a new request.
+	if resumed, errMsg := h.ResumeStream(c); resumed {
+		if errMsg != nil {
+			h.writeClaudeErrorResponse(c, errMsg)
+		}
+		return
+	}
+
 	// Extract raw JSON data from the incoming request
 	rawJSON, err := c.GetRawData()
 	// If data retrieval fails, return a 400 Bad Request error.
@@ -81,6 +89,20 @@ func (h *ClaudeCodeAPIHandler) ClaudeMessages(c *gin.Context) {
 		return
 	}
 
+	requestCtx := context.WithValue(c.Request.Context(), "gin", c)
+	providers, normalizedModel, detailsErr := h.GetRequestDetailsForContext(requestCtx, gjson.GetBytes(rawJSON, "model").String())
+	if detailsErr != nil {
+		h.writeClaudeErrorResponse(c, detailsErr)
+		return
+	}
+	rawJSON, errMsg := h.ApplyParameterPolicy(requestCtx, normalizedModel, rawJSON, func(payload []byte) *interfaces.ErrorMessage {
+		return h.ValidateSurfaceParameters(handlers.SurfaceMessages, providers, payload)
+	})
+	if errMsg != nil {
+		h.writeClaudeErrorResponse(c, errMsg)
+		return
+	}
+
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
 	if !streamResult.Exists() || streamResult.Type == gjson.False {
@@ -246,6 +268,7 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 
 	// Create a cancellable context for the backend client request
 	// This allows proper cleanup and cancellation of ongoing requests
+	h.BeginResumableStream(c)
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 
 	dataChan, upstreamHeaders, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
@@ -290,21 +313,16 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 			setSSEHeaders()
 			handlers.WriteUpstreamHeaders(c.Writer.Header(), upstreamHeaders)
 
-			// Write the first chunk
-			if len(chunk) > 0 {
-				_, _ = c.Writer.Write(chunk)
-				flusher.Flush()
-			}
-
-			// Continue streaming the rest
-			h.forwardClaudeStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+			// Stream the first chunk and the rest
+			h.forwardClaudeStream(c, flusher, func(err error) { cliCancel(err) }, chunk, dataChan, errChan)
 			return
 		}
 	}
 }
 
-func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.Flusher, cancel func(error), first []byte, data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
 	h.ForwardStream(c, flusher, cancel, data, errs, handlers.StreamForwardOptions{
+		FirstChunk: first,
 		WriteChunk: func(chunk []byte) {
 			if len(chunk) == 0 {
 				return
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// ContextCompactedHeader is set on responses whose conversation was compacted before forwarding.
+const ContextCompactedHeader = "X-Cliproxy-Context-Compacted"
+
+const (
+	defaultCompactionThreshold  = 0.8
+	defaultCompactionKeepRecent = 8
+	compactionSummaryCacheSize  = 256
+
+	compactionSummaryInstructions = "You compact conversation transcripts for another assistant. Summarize the transcript below so the conversation can continue without it. Preserve user goals, decisions, constraints, file names, identifiers, tool results that are still relevant and any open tasks. Reply with the summary only."
+	compactionSummaryPrefix       = "Summary of the earlier conversation (older turns were compacted to fit the context window):\n\n"
+)
+
+type contextCompactionSkipKey struct{}
+
+// compactionSummaries caches summaries by a hash of the summary model and the compacted turns so
+// that retries and growing conversations do not re-summarize a prefix that was already summarized.
+var compactionSummaries = newCompactionSummaryCache(compactionSummaryCacheSize)
+
+// compactionSummaryCache is a bounded map of prefix hash to summary; the oldest entry is evicted first.
+type compactionSummaryCache struct {
+	mu      sync.Mutex
+	limit   int
+	order   []string
+	entries map[string]string
+}
+
+func newCompactionSummaryCache(limit int) *compactionSummaryCache {
+	return &compactionSummaryCache{limit: limit, entries: make(map[string]string, limit)}
+}
+
+func (c *compactionSummaryCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	summary, ok := c.entries[key]
+	return summary, ok
+}
+
+func (c *compactionSummaryCache) put(key, summary string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		if len(c.order) >= c.limit {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = summary
+}
+
+// compactionPrefixHashes returns, for every n in 1..len(items), a hash of model and items[:n].
+func compactionPrefixHashes(model string, items []gjson.Result) []string {
+	hash := sha256.New()
+	hash.Write([]byte(model))
+	out := make([]string, len(items))
+	for i, item := range items {
+		hash.Write([]byte{0})
+		hash.Write([]byte(item.Raw))
+		out[i] = hex.EncodeToString(hash.Sum(nil))
+	}
+	return out
+}
+
+// contextCompactionEnabled reports whether compaction applies to model for the current request.
+// A per-key override from client-api-keys takes precedence over the global configuration.
+func (h *BaseAPIHandler) contextCompactionEnabled(ctx context.Context, model string) bool {
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		switch strings.ToLower(strings.TrimSpace(getStringContextValue(ginCtx, AccessContextCompactionContextKey))) {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+	}
+	cfg := h.Cfg.ContextCompaction
+	if cfg.Enabled {
+		return true
+	}
+	baseModel := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+	for _, candidate := range cfg.Models {
+		candidate = strings.TrimSpace(candidate)
+		if candidate != "" && (strings.EqualFold(candidate, model) || strings.EqualFold(candidate, baseModel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyContextCompaction replaces older turns of an overlong chat completions or Claude messages
+// conversation with a summary produced by the configured summary model. Tool calls and their results
+// are never split. Any failure leaves the payload unchanged.
+func (h *BaseAPIHandler) applyContextCompaction(ctx context.Context, handlerType, model string, payload []byte) []byte {
+	if h == nil || h.Cfg == nil || ctx == nil || len(payload) == 0 {
+		return payload
+	}
+	if handlerType != constant.OpenAI && handlerType != constant.Claude {
+		return payload
+	}
+	if ctx.Value(contextCompactionSkipKey{}) != nil || !h.contextCompactionEnabled(ctx, model) {
+		return payload
+	}
+	limits := lookupModelTokenLimits(model)
+	if limits.input <= 0 {
+		return payload
+	}
+	cfg := h.Cfg.ContextCompaction
+	threshold := cfg.Threshold
+	if threshold <= 0 || threshold >= 1 {
+		threshold = defaultCompactionThreshold
+	}
+	estimated := EstimateInputTokens(payload)
+	if estimated <= int(float64(limits.input)*threshold) {
+		return payload
+	}
+
+	messages := gjson.GetBytes(payload, "messages")
+	if !messages.IsArray() {
+		return payload
+	}
+	items := messages.Array()
+	start := 0
+	if handlerType == constant.OpenAI {
+		for start < len(items) && isOpenAIInstructionRole(items[start].Get("role").String()) {
+			start++
+		}
+	}
+	keepRecent := cfg.KeepRecent
+	if keepRecent <= 0 {
+		keepRecent = defaultCompactionKeepRecent
+	}
+	split := compactionSplitIndex(handlerType, items, start, len(items)-keepRecent)
+	if split <= start {
+		return payload
+	}
+
+	summary, err := h.summarizeConversation(ctx, items[start:split])
+	if err != nil {
+		log.Warnf("context compaction: summarizing %d messages for %s failed, forwarding unchanged: %v", split-start, model, err)
+		return payload
+	}
+
+	compacted, err := buildCompactedPayload(handlerType, payload, items, start, split, summary)
+	if err != nil {
+		log.Warnf("context compaction: rebuilding request for %s failed, forwarding unchanged: %v", model, err)
+		return payload
+	}
+
+	after := EstimateInputTokens(compacted)
+	log.Infof("context compaction: compacted %d messages for %s (~%d -> ~%d tokens)", split-start, model, estimated, after)
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(ContextCompactedHeader, fmt.Sprintf("messages=%d; estimated-tokens=%d->%d", split-start, estimated, after))
+	}
+	return compacted
+}
+
+func isOpenAIInstructionRole(role string) bool {
+	return role == "system" || role == "developer"
+}
+
+// compactionSplitIndex moves split backwards until the retained tail starts at a turn boundary:
+// never on a tool result (OpenAI) and on a plain user turn for Claude, which requires the
+// conversation to open with a user message whose tool_result blocks match a preceding tool_use.
+func compactionSplitIndex(handlerType string, items []gjson.Result, start, split int) int {
+	for split > start {
+		message := items[split]
+		role := message.Get("role").String()
+		if handlerType == constant.Claude {
+			if role == "user" && !claudeMessageHasToolResult(message) {
+				return split
+			}
+		} else if role != "tool" {
+			return split
+		}
+		split--
+	}
+	return split
+}
+
+func claudeMessageHasToolResult(message gjson.Result) bool {
+	found := false
+	message.Get("content").ForEach(func(_, block gjson.Result) bool {
+		if block.Get("type").String() == "tool_result" {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// summarizeConversation returns the text that replaces the compacted turns. The longest prefix of
+// items with a cached summary is reused, so only the turns after it are sent to the summary model.
+func (h *BaseAPIHandler) summarizeConversation(ctx context.Context, items []gjson.Result) (string, error) {
+	summaryModel := strings.TrimSpace(h.Cfg.ContextCompaction.SummaryModel)
+	if summaryModel == "" {
+		return "", fmt.Errorf("context-compaction.summary-model is not configured")
+	}
+
+	hashes := compactionPrefixHashes(summaryModel, items)
+	previous, covered := "", 0
+	for n := len(items); n > 0; n-- {
+		if cached, ok := compactionSummaries.get(hashes[n-1]); ok {
+			previous, covered = cached, n
+			break
+		}
+	}
+	if covered == len(items) {
+		return compactionSummaryPrefix + previous, nil
+	}
+
+	transcript := renderCompactionTranscript(items[covered:])
+	if previous != "" {
+		transcript = "summary of earlier turns: " + previous + "\n\n" + transcript
+	}
+	if limits := lookupModelTokenLimits(summaryModel); limits.input > 0 {
+		// Keep the most recent part when the transcript itself is too large for the summary model.
+		if maxChars := limits.input * 3; len(transcript) > maxChars {
+			transcript = strings.ToValidUTF8(transcript[len(transcript)-maxChars:], "")
+		}
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"model":  summaryModel,
+		"stream": false,
+		"messages": []map[string]string{
+			{"role": "system", "content": compactionSummaryInstructions},
+			{"role": "user", "content": transcript},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, _, errMsg := h.ExecuteWithAuthManager(detachedExecutionContext(ctx), constant.OpenAI, summaryModel, body, "")
+	if errMsg != nil {
+		if errMsg.Error != nil {
+			return "", errMsg.Error
+		}
+		return "", fmt.Errorf("summary request failed with status %d", errMsg.StatusCode)
+	}
+	summary := strings.TrimSpace(gjson.GetBytes(resp, "choices.0.message.content").String())
+	if summary == "" {
+		return "", errors.New("summary model returned no content")
+	}
+	compactionSummaries.put(hashes[len(hashes)-1], summary)
+	return compactionSummaryPrefix + summary, nil
+}
+
+// detachedExecutionContext keeps cancellation from ctx but drops request-scoped routing so that
+// the internal summary request is not pinned to the client's auth, scope or session.
+func detachedExecutionContext(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, "gin", (*gin.Context)(nil))
+	ctx = context.WithValue(ctx, pinnedAuthContextKey{}, "")
+	ctx = context.WithValue(ctx, selectedAuthCallbackContextKey{}, (func(string))(nil))
+	ctx = context.WithValue(ctx, executionSessionContextKey{}, "")
+	return context.WithValue(ctx, contextCompactionSkipKey{}, true)
+}
+
+// renderCompactionTranscript flattens OpenAI or Claude messages into plain text for summarization.
+func renderCompactionTranscript(items []gjson.Result) string {
+	var b strings.Builder
+	for _, message := range items {
+		role := message.Get("role").String()
+		b.WriteString(role)
+		b.WriteString(": ")
+		renderCompactionContent(message.Get("content"), &b)
+		message.Get("tool_calls").ForEach(func(_, call gjson.Result) bool {
+			fmt.Fprintf(&b, "[tool call %s %s] ", call.Get("function.name").String(), call.Get("function.arguments").String())
+			return true
+		})
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+func renderCompactionContent(content gjson.Result, b *strings.Builder) {
+	if content.Type == gjson.String {
+		b.WriteString(content.String())
+		b.WriteByte(' ')
+		return
+	}
+	content.ForEach(func(_, part gjson.Result) bool {
+		switch part.Get("type").String() {
+		case "text", "input_text", "output_text":
+			b.WriteString(part.Get("text").String())
+			b.WriteByte(' ')
+		case "tool_use":
+			fmt.Fprintf(b, "[tool call %s %s] ", part.Get("name").String(), part.Get("input").Raw)
+		case "tool_result":
+			b.WriteString("[tool result] ")
+			renderCompactionContent(part.Get("content"), b)
+		case "image", "image_url", "input_image":
+			b.WriteString("[image] ")
+		}
+		return true
+	})
+}
+
+// buildCompactedPayload rewrites payload so that items[start:split] are replaced by summary.
+// OpenAI requests receive the summary as a system message; Claude requests get it appended to system.
+func buildCompactedPayload(handlerType string, payload []byte, items []gjson.Result, start, split int, summary string) ([]byte, error) {
+	raws := make([]string, 0, len(items)-(split-start)+1)
+	for _, item := range items[:start] {
+		raws = append(raws, item.Raw)
+	}
+	if handlerType == constant.OpenAI {
+		summaryMessage, err := json.Marshal(map[string]string{"role": "system", "content": summary})
+		if err != nil {
+			return nil, err
+		}
+		raws = append(raws, string(summaryMessage))
+	}
+	for _, item := range items[split:] {
+		raws = append(raws, item.Raw)
+	}
+	out, err := sjson.SetRawBytes(payload, "messages", []byte("["+strings.Join(raws, ",")+"]"))
+	if err != nil || handlerType != constant.Claude {
+		return out, err
+	}
+
+	system := gjson.GetBytes(out, "system")
+	switch {
+	case system.IsArray():
+		return sjson.SetBytes(out, "system.-1", map[string]string{"type": "text", "text": summary})
+	case system.Type == gjson.String && strings.TrimSpace(system.String()) != "":
+		return sjson.SetBytes(out, "system", system.String()+"\n\n"+summary)
+	default:
+		return sjson.SetBytes(out, "system", summary)
+	}
+}
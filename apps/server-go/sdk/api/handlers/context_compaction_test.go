@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+type summaryExecutor struct {
+	mu     sync.Mutex
+	models []string
+}
+
+func (e *summaryExecutor) Identifier() string { return "codex" }
+
+func (e *summaryExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.models = append(e.models, req.Model)
+	e.mu.Unlock()
+	return coreexecutor.Response{Payload: []byte(`{"choices":[{"message":{"role":"assistant","content":"user wants a parser"}}]}`)}, nil
+}
+
+func (e *summaryExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (*coreexecutor.StreamResult, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *summaryExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *summaryExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *summaryExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newCompactionTestHandler(t *testing.T, cfg sdkconfig.ContextCompactionConfig) (*BaseAPIHandler, *summaryExecutor) {
+	t.Helper()
+	executor := &summaryExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "compaction-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{
+		{ID: "compact-main", ContextLength: 300},
+		{ID: "compact-cheap", ContextLength: 100000},
+	})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{ContextCompaction: cfg}, manager), executor
+}
+
+func compactionGinContext() (context.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	return context.WithValue(context.Background(), "gin", c), recorder
+}
+
+func TestApplyContextCompaction_OpenAIKeepsToolPairs(t *testing.T) {
+	handler, executor := newCompactionTestHandler(t, sdkconfig.ContextCompactionConfig{
+		Models:       []string{"compact-main"},
+		SummaryModel: "compact-cheap",
+		KeepRecent:   2,
+	})
+	filler := strings.Repeat("lorem ipsum ", 60)
+	payload := []byte(`{"model":"compact-main","messages":[` +
+		`{"role":"system","content":"be helpful"},` +
+		`{"role":"user","content":"` + filler + `"},` +
+		`{"role":"assistant","content":"` + filler + `"},` +
+		`{"role":"user","content":"run it"},` +
+		`{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"run","arguments":"{}"}}]},` +
+		`{"role":"tool","tool_call_id":"call_1","content":"done"}]}`)
+
+	ctx, recorder := compactionGinContext()
+	out := handler.applyContextCompaction(ctx, "openai", "compact-main", payload)
+
+	messages := gjson.GetBytes(out, "messages").Array()
+	if len(messages) != 4 {
+		t.Fatalf("expected system, summary, tool call and tool result, got %s", gjson.GetBytes(out, "messages").Raw)
+	}
+	if messages[0].Get("content").String() != "be helpful" {
+		t.Fatalf("leading system message must be kept, got %s", messages[0].Raw)
+	}
+	if !strings.Contains(messages[1].Get("content").String(), "user wants a parser") {
+		t.Fatalf("expected summary message, got %s", messages[1].Raw)
+	}
+	if messages[2].Get("tool_calls.0.id").String() != "call_1" || messages[3].Get("tool_call_id").String() != "call_1" {
+		t.Fatalf("tool call and result must stay paired, got %s", gjson.GetBytes(out, "messages").Raw)
+	}
+	if got := recorder.Header().Get(ContextCompactedHeader); !strings.HasPrefix(got, "messages=3") {
+		t.Fatalf("%s = %q, want messages=3 prefix", ContextCompactedHeader, got)
+	}
+	if len(executor.models) != 1 || executor.models[0] != "compact-cheap" {
+		t.Fatalf("expected one summary request to compact-cheap, got %v", executor.models)
+	}
+}
+
+func TestApplyContextCompaction_ClaudeStartsTailWithUserTurn(t *testing.T) {
+	handler, _ := newCompactionTestHandler(t, sdkconfig.ContextCompactionConfig{Enabled: true, SummaryModel: "compact-cheap", KeepRecent: 1})
+	filler := strings.Repeat("lorem ipsum ", 60)
+	payload := []byte(`{"model":"compact-main","system":"be brief","messages":[` +
+		`{"role":"user","content":"` + filler + `"},` +
+		`{"role":"assistant","content":"` + filler + `"},` +
+		`{"role":"user","content":"read the file"},` +
+		`{"role":"assistant","content":[{"type":"tool_use","id":"tu_1","name":"read","input":{}}]},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"tu_1","content":"data"}]}]}`)
+
+	ctx, _ := compactionGinContext()
+	out := handler.applyContextCompaction(ctx, "claude", "compact-main", payload)
+
+	messages := gjson.GetBytes(out, "messages").Array()
+	if len(messages) != 3 || messages[0].Get("content").String() != "read the file" {
+		t.Fatalf("expected tail to start at the last plain user turn, got %s", gjson.GetBytes(out, "messages").Raw)
+	}
+	if system := gjson.GetBytes(out, "system").String(); !strings.HasPrefix(system, "be brief") || !strings.Contains(system, "user wants a parser") {
+		t.Fatalf("expected summary appended to system, got %q", system)
+	}
+}
+
+func TestApplyContextCompaction_NeverDropsTurnsWithoutSummaryModel(t *testing.T) {
+	handler, executor := newCompactionTestHandler(t, sdkconfig.ContextCompactionConfig{Enabled: true, KeepRecent: 1})
+	filler := strings.Repeat("lorem ipsum ", 60)
+	payload := []byte(`{"model":"compact-main","messages":[{"role":"user","content":"` + filler + `"},{"role":"assistant","content":"` + filler + `"},{"role":"user","content":"next"}]}`)
+
+	ctx, recorder := compactionGinContext()
+	if out := handler.applyContextCompaction(ctx, "openai", "compact-main", payload); string(out) != string(payload) {
+		t.Fatalf("expected payload unchanged without a summary model, got %s", out)
+	}
+	if got := recorder.Header().Get(ContextCompactedHeader); got != "" {
+		t.Fatalf("%s = %q, want empty", ContextCompactedHeader, got)
+	}
+	if len(executor.models) != 0 {
+		t.Fatalf("expected no summary requests, got %v", executor.models)
+	}
+}
+
+func TestApplyContextCompaction_KeyOverrideDisables(t *testing.T) {
+	handler, _ := newCompactionTestHandler(t, sdkconfig.ContextCompactionConfig{Enabled: true, KeepRecent: 1})
+	payload := []byte(`{"model":"compact-main","messages":[{"role":"user","content":"` + strings.Repeat("lorem ipsum ", 60) + `"},{"role":"assistant","content":"ok"},{"role":"user","content":"next"}]}`)
+
+	ctx, _ := compactionGinContext()
+	ctx.Value("gin").(*gin.Context).Set(AccessContextCompactionContextKey, "false")
+	if out := handler.applyContextCompaction(ctx, "openai", "compact-main", payload); string(out) != string(payload) {
+		t.Fatalf("expected payload unchanged when the client key opts out")
+	}
+}
+
+func TestSummarizeConversation_ReusesCachedPrefix(t *testing.T) {
+	handler, executor := newCompactionTestHandler(t, sdkconfig.ContextCompactionConfig{Enabled: true, SummaryModel: "compact-cheap"})
+	items := gjson.Parse(`[{"role":"user","content":"cache prefix one"},{"role":"assistant","content":"cache prefix two"},{"role":"user","content":"cache prefix three"}]`).Array()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if summary, err := handler.summarizeConversation(ctx, items[:2]); err != nil || !strings.Contains(summary, "user wants a parser") {
+			t.Fatalf("summarizeConversation() = %q, %v", summary, err)
+		}
+	}
+	if len(executor.models) != 1 {
+		t.Fatalf("expected the repeated prefix to be served from cache, got %d summary requests", len(executor.models))
+	}
+	if _, err := handler.summarizeConversation(ctx, items); err != nil {
+		t.Fatalf("summarizeConversation() error = %v", err)
+	}
+	if len(executor.models) != 2 {
+		t.Fatalf("expected one more summary request for the longer prefix, got %d", len(executor.models))
+	}
+}
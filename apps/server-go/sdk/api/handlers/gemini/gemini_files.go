@@ -0,0 +1,758 @@
+package gemini
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/filestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultGeminiFilesMaxMB      = 100
+	defaultGeminiFileTTL         = 48 * time.Hour
+	defaultGeminiCacheTTL        = time.Hour
+	defaultGeminiMaxCacheTTL     = 24 * time.Hour
+	geminiUploadSessionTTL       = 24 * time.Hour
+	geminiDefaultPageSize        = 10
+	geminiMaxPageSize            = 100
+	geminiUploadChunkGranularity = "8388608"
+)
+
+// geminiFilesSettings is the resolved gemini-files configuration.
+type geminiFilesSettings struct {
+	files       *filestore.Store
+	caches      *filestore.Store
+	uploadDir   string
+	maxBytes    int64
+	fileTTL     time.Duration
+	cacheTTL    time.Duration
+	maxCacheTTL time.Duration
+}
+
+// geminiUploadSession tracks a resumable upload started with X-Goog-Upload-Command: start.
+type geminiUploadSession struct {
+	mu       sync.Mutex
+	owner    string
+	meta     filestore.Meta
+	path     string
+	received int64
+	started  time.Time
+}
+
+var geminiUploads = struct {
+	sync.Mutex
+	sessions map[string]*geminiUploadSession
+}{sessions: make(map[string]*geminiUploadSession)}
+
+// geminiFiles resolves the gemini-files settings, or reports false when the feature is disabled.
+func (h *GeminiAPIHandler) geminiFiles() (geminiFilesSettings, bool, error) {
+	if h.Cfg == nil || !h.Cfg.GeminiFiles.Enabled {
+		return geminiFilesSettings{}, false, nil
+	}
+	cfg := h.Cfg.GeminiFiles
+	dir := strings.TrimSpace(cfg.Dir)
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "cliproxy-gemini-files")
+	}
+	settings := geminiFilesSettings{
+		uploadDir:   filepath.Join(dir, "uploads"),
+		maxBytes:    int64(defaultGeminiFilesMaxMB) << 20,
+		fileTTL:     defaultGeminiFileTTL,
+		cacheTTL:    defaultGeminiCacheTTL,
+		maxCacheTTL: defaultGeminiMaxCacheTTL,
+	}
+	if cfg.MaxFileMB > 0 {
+		settings.maxBytes = int64(cfg.MaxFileMB) << 20
+	}
+	if cfg.FileTTLHours > 0 {
+		settings.fileTTL = time.Duration(cfg.FileTTLHours) * time.Hour
+	}
+	if cfg.CacheTTLSeconds > 0 {
+		settings.cacheTTL = time.Duration(cfg.CacheTTLSeconds) * time.Second
+	}
+	if cfg.MaxCacheTTLHours > 0 {
+		settings.maxCacheTTL = time.Duration(cfg.MaxCacheTTLHours) * time.Hour
+	}
+	var err error
+	if settings.files, err = filestore.Open(filepath.Join(dir, "files")); err != nil {
+		return geminiFilesSettings{}, true, err
+	}
+	if settings.caches, err = filestore.Open(filepath.Join(dir, "cached-contents")); err != nil {
+		return geminiFilesSettings{}, true, err
+	}
+	return settings, true, nil
+}
+
+// geminiFilesFor writes the error response and reports false when the endpoints are unavailable.
+func (h *GeminiAPIHandler) geminiFilesFor(c *gin.Context) (geminiFilesSettings, bool) {
+	settings, enabled, err := h.geminiFiles()
+	if !enabled {
+		h.writeGeminiError(c, http.StatusNotFound, fmt.Sprintf("%s not found. Enable gemini-files to use the Files and cachedContents APIs.", c.Request.URL.Path), nil)
+		return settings, false
+	}
+	if err != nil {
+		h.writeGeminiError(c, http.StatusInternalServerError, err.Error(), nil)
+		return settings, false
+	}
+	return settings, true
+}
+
+func geminiFilesOwner(c *gin.Context) string {
+	return filestore.OwnerKey(c.GetString("apiKey"))
+}
+
+func geminiFilesErrorMessage(status int, format string, args ...any) *interfaces.ErrorMessage {
+	return &interfaces.ErrorMessage{StatusCode: status, Error: fmt.Errorf(format, args...)}
+}
+
+func geminiStoreErrorMessage(err error, resource string) *interfaces.ErrorMessage {
+	switch {
+	case errors.Is(err, filestore.ErrNotFound):
+		return geminiFilesErrorMessage(http.StatusNotFound, "%s not found or it has expired.", resource)
+	case errors.Is(err, filestore.ErrTooLarge):
+		return geminiFilesErrorMessage(http.StatusRequestEntityTooLarge, "%s exceeds the maximum size allowed by this proxy.", resource)
+	case errors.Is(err, filestore.ErrQuotaExceeded):
+		return geminiFilesErrorMessage(http.StatusTooManyRequests, "Storage quota exceeded while saving %s.", resource)
+	default:
+		return geminiFilesErrorMessage(http.StatusInternalServerError, "%v", err)
+	}
+}
+
+// geminiBaseURL returns the scheme and host clients used to reach this proxy.
+func geminiBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.TrimSpace(strings.Split(c.GetHeader("X-Forwarded-Proto"), ",")[0]); proto != "" {
+		scheme = proto
+	}
+	host := c.Request.Host
+	if forwarded := strings.TrimSpace(strings.Split(c.GetHeader("X-Forwarded-Host"), ",")[0]); forwarded != "" {
+		host = forwarded
+	}
+	return scheme + "://" + host
+}
+
+func geminiFileResource(c *gin.Context, meta filestore.Meta) map[string]any {
+	resource := map[string]any{
+		"name":       "files/" + meta.ID,
+		"mimeType":   meta.MIMEType,
+		"sizeBytes":  strconv.FormatInt(meta.Size, 10),
+		"createTime": meta.CreatedAt.Format(time.RFC3339Nano),
+		"updateTime": meta.UpdatedAt.Format(time.RFC3339Nano),
+		"uri":        geminiBaseURL(c) + "/v1beta/files/" + meta.ID,
+		"state":      "ACTIVE",
+		"source":     "UPLOADED",
+	}
+	if meta.Name != "" {
+		resource["displayName"] = meta.Name
+	}
+	if !meta.ExpiresAt.IsZero() {
+		resource["expirationTime"] = meta.ExpiresAt.Format(time.RFC3339Nano)
+	}
+	if sum, err := hex.DecodeString(meta.SHA256); err == nil {
+		resource["sha256Hash"] = base64.StdEncoding.EncodeToString(sum)
+	}
+	return resource
+}
+
+// geminiFileMetadata reads displayName and mimeType from a {"file": {...}} upload body.
+func geminiFileMetadata(raw []byte) (displayName, mimeType string) {
+	file := gjson.GetBytes(raw, "file")
+	displayName = strings.TrimSpace(file.Get("displayName").String())
+	if displayName == "" {
+		displayName = strings.TrimSpace(file.Get("display_name").String())
+	}
+	mimeType = strings.TrimSpace(file.Get("mimeType").String())
+	if mimeType == "" {
+		mimeType = strings.TrimSpace(file.Get("mime_type").String())
+	}
+	return displayName, mimeType
+}
+
+// UploadFile implements POST /upload/v1beta/files for the resumable, multipart and raw upload
+// protocols used by the google-genai SDKs.
+func (h *GeminiAPIHandler) UploadFile(c *gin.Context) {
+	settings, ok := h.geminiFilesFor(c)
+	if !ok {
+		return
+	}
+	if uploadID := strings.TrimSpace(c.Query("upload_id")); uploadID != "" {
+		h.continueGeminiUpload(c, settings, uploadID)
+		return
+	}
+
+	protocol := strings.ToLower(strings.TrimSpace(c.GetHeader("X-Goog-Upload-Protocol")))
+	if protocol == "" {
+		protocol = strings.ToLower(strings.TrimSpace(c.Query("uploadType")))
+	}
+	switch protocol {
+	case "resumable":
+		h.startGeminiUpload(c, settings)
+	case "multipart":
+		h.multipartGeminiUpload(c, settings)
+	default:
+		mimeType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		h.finishGeminiUpload(c, settings, filestore.Meta{Owner: geminiFilesOwner(c), MIMEType: mimeType}, c.Request.Body)
+	}
+}
+
+func (h *GeminiAPIHandler) startGeminiUpload(c *gin.Context, settings geminiFilesSettings) {
+	raw, _ := c.GetRawData()
+	displayName, mimeType := geminiFileMetadata(raw)
+	if mimeType == "" {
+		mimeType = strings.TrimSpace(c.GetHeader("X-Goog-Upload-Header-Content-Type"))
+	}
+	if declared := strings.TrimSpace(c.GetHeader("X-Goog-Upload-Header-Content-Length")); declared != "" {
+		if size, err := strconv.ParseInt(declared, 10, 64); err == nil && size > settings.maxBytes {
+			h.writeGeminiErrorMessage(c, geminiFilesErrorMessage(http.StatusRequestEntityTooLarge, "File size %d exceeds the maximum of %d bytes allowed by this proxy.", size, settings.maxBytes))
+			return
+		}
+	}
+	if err := os.MkdirAll(settings.uploadDir, 0o700); err != nil {
+		h.writeGeminiError(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	uploadID := filestore.NewID("")
+	session := &geminiUploadSession{
+		owner:   geminiFilesOwner(c),
+		meta:    filestore.Meta{Owner: geminiFilesOwner(c), Name: displayName, MIMEType: mimeType},
+		path:    filepath.Join(settings.uploadDir, uploadID+".part"),
+		started: time.Now(),
+	}
+	if err := os.WriteFile(session.path, nil, 0o600); err != nil {
+		h.writeGeminiError(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	geminiUploads.Lock()
+	for id, existing := range geminiUploads.sessions {
+		if time.Since(existing.started) > geminiUploadSessionTTL {
+			_ = os.Remove(existing.path)
+			delete(geminiUploads.sessions, id)
+		}
+	}
+	geminiUploads.sessions[uploadID] = session
+	geminiUploads.Unlock()
+
+	c.Header("X-Goog-Upload-URL", geminiBaseURL(c)+"/upload/v1beta/files?upload_id="+url.QueryEscape(uploadID)+"&upload_protocol=resumable")
+	c.Header("X-Goog-Upload-Status", "active")
+	c.Header("X-Goog-Upload-Chunk-Granularity", geminiUploadChunkGranularity)
+	c.Status(http.StatusOK)
+}
+
+func (h *GeminiAPIHandler) continueGeminiUpload(c *gin.Context, settings geminiFilesSettings, uploadID string) {
+	geminiUploads.Lock()
+	session, ok := geminiUploads.sessions[uploadID]
+	if ok && session.owner != geminiFilesOwner(c) {
+		ok = false
+	}
+	geminiUploads.Unlock()
+	if !ok {
+		h.writeGeminiError(c, http.StatusNotFound, "Upload session not found or it has expired.", nil)
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	commands := make(map[string]bool)
+	for _, command := range strings.Split(c.GetHeader("X-Goog-Upload-Command"), ",") {
+		commands[strings.ToLower(strings.TrimSpace(command))] = true
+	}
+	dropSession := func() {
+		geminiUploads.Lock()
+		delete(geminiUploads.sessions, uploadID)
+		geminiUploads.Unlock()
+		_ = os.Remove(session.path)
+	}
+
+	switch {
+	case commands["cancel"]:
+		dropSession()
+		c.Header("X-Goog-Upload-Status", "cancelled")
+		c.Status(http.StatusOK)
+		return
+	case commands["query"]:
+		c.Header("X-Goog-Upload-Status", "active")
+		c.Header("X-Goog-Upload-Size-Received", strconv.FormatInt(session.received, 10))
+		c.Status(http.StatusOK)
+		return
+	}
+
+	if commands["upload"] {
+		if raw := strings.TrimSpace(c.GetHeader("X-Goog-Upload-Offset")); raw != "" {
+			if offset, err := strconv.ParseInt(raw, 10, 64); err != nil || offset != session.received {
+				h.writeGeminiError(c, http.StatusBadRequest, fmt.Sprintf("Upload offset %s does not match the %d bytes received so far.", raw, session.received), nil)
+				return
+			}
+		}
+		part, err := os.OpenFile(session.path, os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			dropSession()
+			h.writeGeminiError(c, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		written, err := io.Copy(part, io.LimitReader(c.Request.Body, settings.maxBytes-session.received+1))
+		if errClose := part.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			dropSession()
+			h.writeGeminiError(c, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		session.received += written
+		if session.received > settings.maxBytes {
+			dropSession()
+			h.writeGeminiErrorMessage(c, geminiStoreErrorMessage(filestore.ErrTooLarge, "File"))
+			return
+		}
+	}
+	if !commands["finalize"] {
+		c.Header("X-Goog-Upload-Status", "active")
+		c.Header("X-Goog-Upload-Size-Received", strconv.FormatInt(session.received, 10))
+		c.Status(http.StatusOK)
+		return
+	}
+
+	part, err := os.Open(session.path)
+	if err != nil {
+		dropSession()
+		h.writeGeminiError(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	defer dropSession()
+	defer func() { _ = part.Close() }()
+	h.finishGeminiUpload(c, settings, session.meta, part)
+}
+
+func (h *GeminiAPIHandler) multipartGeminiUpload(c *gin.Context, settings geminiFilesSettings) {
+	_, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		h.writeGeminiError(c, http.StatusBadRequest, "Multipart uploads require a multipart/related Content-Type with a boundary.", nil)
+		return
+	}
+	reader := multipart.NewReader(c.Request.Body, params["boundary"])
+	metadataPart, err := reader.NextPart()
+	if err != nil {
+		h.writeGeminiError(c, http.StatusBadRequest, fmt.Sprintf("Invalid multipart upload: %v", err), nil)
+		return
+	}
+	rawMetadata, _ := io.ReadAll(io.LimitReader(metadataPart, 1<<20))
+	displayName, mimeType := geminiFileMetadata(rawMetadata)
+	mediaPart, err := reader.NextPart()
+	if err != nil {
+		h.writeGeminiError(c, http.StatusBadRequest, fmt.Sprintf("Invalid multipart upload: %v", err), nil)
+		return
+	}
+	if mimeType == "" {
+		mimeType, _, _ = mime.ParseMediaType(mediaPart.Header.Get("Content-Type"))
+	}
+	h.finishGeminiUpload(c, settings, filestore.Meta{Owner: geminiFilesOwner(c), Name: displayName, MIMEType: mimeType}, mediaPart)
+}
+
+func (h *GeminiAPIHandler) finishGeminiUpload(c *gin.Context, settings geminiFilesSettings, meta filestore.Meta, body io.Reader) {
+	if meta.MIMEType == "" {
+		meta.MIMEType = "application/octet-stream"
+	}
+	meta.ID = filestore.NewID("")
+	stored, err := settings.files.Put(meta, body, filestore.Limits{MaxBytes: settings.maxBytes, TTL: settings.fileTTL})
+	if err != nil {
+		h.writeGeminiErrorMessage(c, geminiStoreErrorMessage(err, "File"))
+		return
+	}
+	c.Header("X-Goog-Upload-Status", "final")
+	c.JSON(http.StatusOK, gin.H{"file": geminiFileResource(c, stored)})
+}
+
+// geminiPage applies pageSize and pageToken to n items and returns the window and next token.
+func geminiPage(c *gin.Context, n int) (start, end int, next string) {
+	size := geminiDefaultPageSize
+	if raw, err := strconv.Atoi(strings.TrimSpace(c.Query("pageSize"))); err == nil && raw > 0 {
+		size = min(raw, geminiMaxPageSize)
+	}
+	if raw, err := strconv.Atoi(strings.TrimSpace(c.Query("pageToken"))); err == nil && raw > 0 {
+		start = min(raw, n)
+	}
+	end = min(start+size, n)
+	if end < n {
+		next = strconv.Itoa(end)
+	}
+	return start, end, next
+}
+
+// ListFiles implements GET /v1beta/files.
+func (h *GeminiAPIHandler) ListFiles(c *gin.Context) {
+	settings, ok := h.geminiFilesFor(c)
+	if !ok {
+		return
+	}
+	metas := settings.files.List(geminiFilesOwner(c))
+	start, end, next := geminiPage(c, len(metas))
+	response := gin.H{}
+	if start < end {
+		files := make([]map[string]any, 0, end-start)
+		for _, meta := range metas[start:end] {
+			files = append(files, geminiFileResource(c, meta))
+		}
+		response["files"] = files
+	}
+	if next != "" {
+		response["nextPageToken"] = next
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetFile implements GET /v1beta/files/{id} and, with a :download suffix or alt=media, returns
+// the file content.
+func (h *GeminiAPIHandler) GetFile(c *gin.Context) {
+	settings, ok := h.geminiFilesFor(c)
+	if !ok {
+		return
+	}
+	id, download := strings.CutSuffix(c.Param("name"), ":download")
+	if download || c.Query("alt") == "media" {
+		meta, data, err := settings.files.ReadAll(geminiFilesOwner(c), id)
+		if err != nil {
+			h.writeGeminiErrorMessage(c, geminiStoreErrorMessage(err, "File files/"+id))
+			return
+		}
+		c.Data(http.StatusOK, meta.MIMEType, data)
+		return
+	}
+	meta, err := settings.files.Get(geminiFilesOwner(c), id)
+	if err != nil {
+		h.writeGeminiErrorMessage(c, geminiStoreErrorMessage(err, "File files/"+id))
+		return
+	}
+	c.JSON(http.StatusOK, geminiFileResource(c, meta))
+}
+
+// DeleteFile implements DELETE /v1beta/files/{id}.
+func (h *GeminiAPIHandler) DeleteFile(c *gin.Context) {
+	settings, ok := h.geminiFilesFor(c)
+	if !ok {
+		return
+	}
+	id := c.Param("name")
+	if err := settings.files.Delete(geminiFilesOwner(c), id); err != nil {
+		h.writeGeminiErrorMessage(c, geminiStoreErrorMessage(err, "File files/"+id))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// geminiCachedFields are the request fields a cachedContents resource carries into generate requests.
+var geminiCachedFields = []string{"contents", "systemInstruction", "tools", "toolConfig"}
+
+func geminiCachedContentResource(meta filestore.Meta) map[string]any {
+	resource := map[string]any{
+		"name":       "cachedContents/" + meta.ID,
+		"model":      meta.Attributes["model"],
+		"createTime": meta.CreatedAt.Format(time.RFC3339Nano),
+		"updateTime": meta.UpdatedAt.Format(time.RFC3339Nano),
+		"expireTime": meta.ExpiresAt.Format(time.RFC3339Nano),
+	}
+	if meta.Name != "" {
+		resource["displayName"] = meta.Name
+	}
+	if tokens, err := strconv.Atoi(meta.Attributes["tokens"]); err == nil {
+		resource["usageMetadata"] = map[string]any{"totalTokenCount": tokens}
+	}
+	return resource
+}
+
+// geminiCacheExpiry resolves ttl ("300s") or expireTime from a cachedContents body. ok is false
+// when neither is present.
+func geminiCacheExpiry(raw []byte, now time.Time, maxTTL time.Duration) (expiresAt time.Time, ok bool, errMsg *interfaces.ErrorMessage) {
+	if ttl := strings.TrimSpace(gjson.GetBytes(raw, "ttl").String()); ttl != "" {
+		seconds, err := strconv.ParseFloat(strings.TrimSuffix(ttl, "s"), 64)
+		if err != nil || seconds <= 0 {
+			return time.Time{}, false, geminiFilesErrorMessage(http.StatusBadRequest, "Invalid ttl %q: expected a positive duration such as \"3600s\".", ttl)
+		}
+		expiresAt = now.Add(time.Duration(seconds * float64(time.Second)))
+		ok = true
+	} else if expire := strings.TrimSpace(gjson.GetBytes(raw, "expireTime").String()); expire != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, expire)
+		if err != nil || !parsed.After(now) {
+			return time.Time{}, false, geminiFilesErrorMessage(http.StatusBadRequest, "Invalid expireTime %q: expected a future RFC 3339 timestamp.", expire)
+		}
+		expiresAt, ok = parsed.UTC(), true
+	}
+	if ok && expiresAt.Sub(now) > maxTTL {
+		return time.Time{}, false, geminiFilesErrorMessage(http.StatusBadRequest, "CachedContent lifetime exceeds the maximum of %s allowed by this proxy.", maxTTL)
+	}
+	return expiresAt, ok, nil
+}
+
+// CreateCachedContent implements POST /v1beta/cachedContents.
+func (h *GeminiAPIHandler) CreateCachedContent(c *gin.Context) {
+	settings, ok := h.geminiFilesFor(c)
+	if !ok {
+		return
+	}
+	raw, err := c.GetRawData()
+	if err != nil || !gjson.ValidBytes(raw) {
+		h.writeGeminiError(c, http.StatusBadRequest, "Invalid JSON payload received.", nil)
+		return
+	}
+	model := strings.TrimSpace(gjson.GetBytes(raw, "model").String())
+	if model == "" {
+		h.writeGeminiError(c, http.StatusBadRequest, "CachedContent.model is required.", nil)
+		return
+	}
+	if !strings.HasPrefix(model, "models/") {
+		model = "models/" + model
+	}
+	if !gjson.GetBytes(raw, "contents").Exists() && !gjson.GetBytes(raw, "systemInstruction").Exists() {
+		h.writeGeminiError(c, http.StatusBadRequest, "CachedContent requires contents or systemInstruction.", nil)
+		return
+	}
+
+	now := time.Now().UTC()
+	expiresAt, hasExpiry, errMsg := geminiCacheExpiry(raw, now, settings.maxCacheTTL)
+	if errMsg != nil {
+		h.writeGeminiErrorMessage(c, errMsg)
+		return
+	}
+	if !hasExpiry {
+		expiresAt = now.Add(settings.cacheTTL)
+	}
+
+	content := []byte(`{}`)
+	for _, field := range geminiCachedFields {
+		if value := gjson.GetBytes(raw, field); value.Exists() {
+			content, _ = sjson.SetRawBytes(content, field, []byte(value.Raw))
+		}
+	}
+	meta := filestore.Meta{
+		ID:        filestore.NewID(""),
+		Owner:     geminiFilesOwner(c),
+		Name:      strings.TrimSpace(gjson.GetBytes(raw, "displayName").String()),
+		MIMEType:  "application/json",
+		ExpiresAt: expiresAt,
+		Attributes: map[string]string{
+			"model":  model,
+			"tokens": strconv.Itoa(handlers.EstimateInputTokens(content)),
+		},
+	}
+	stored, err := settings.caches.Put(meta, strings.NewReader(string(content)), filestore.Limits{MaxBytes: settings.maxBytes})
+	if err != nil {
+		h.writeGeminiErrorMessage(c, geminiStoreErrorMessage(err, "CachedContent"))
+		return
+	}
+	c.JSON(http.StatusOK, geminiCachedContentResource(stored))
+}
+
+// ListCachedContents implements GET /v1beta/cachedContents.
+func (h *GeminiAPIHandler) ListCachedContents(c *gin.Context) {
+	settings, ok := h.geminiFilesFor(c)
+	if !ok {
+		return
+	}
+	metas := settings.caches.List(geminiFilesOwner(c))
+	start, end, next := geminiPage(c, len(metas))
+	response := gin.H{}
+	if start < end {
+		caches := make([]map[string]any, 0, end-start)
+		for _, meta := range metas[start:end] {
+			caches = append(caches, geminiCachedContentResource(meta))
+		}
+		response["cachedContents"] = caches
+	}
+	if next != "" {
+		response["nextPageToken"] = next
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetCachedContent implements GET /v1beta/cachedContents/{id}.
+func (h *GeminiAPIHandler) GetCachedContent(c *gin.Context) {
+	settings, ok := h.geminiFilesFor(c)
+	if !ok {
+		return
+	}
+	id := c.Param("name")
+	meta, err := settings.caches.Get(geminiFilesOwner(c), id)
+	if err != nil {
+		h.writeGeminiErrorMessage(c, geminiStoreErrorMessage(err, "CachedContent cachedContents/"+id))
+		return
+	}
+	c.JSON(http.StatusOK, geminiCachedContentResource(meta))
+}
+
+// UpdateCachedContent implements PATCH /v1beta/cachedContents/{id}. Only the expiration can change.
+func (h *GeminiAPIHandler) UpdateCachedContent(c *gin.Context) {
+	settings, ok := h.geminiFilesFor(c)
+	if !ok {
+		return
+	}
+	id := c.Param("name")
+	raw, _ := c.GetRawData()
+	expiresAt, hasExpiry, errMsg := geminiCacheExpiry(raw, time.Now().UTC(), settings.maxCacheTTL)
+	if errMsg != nil {
+		h.writeGeminiErrorMessage(c, errMsg)
+		return
+	}
+	if !hasExpiry {
+		h.writeGeminiError(c, http.StatusBadRequest, "Only ttl or expireTime can be updated on a CachedContent.", nil)
+		return
+	}
+	meta, err := settings.caches.Update(geminiFilesOwner(c), id, func(meta *filestore.Meta) { meta.ExpiresAt = expiresAt })
+	if err != nil {
+		h.writeGeminiErrorMessage(c, geminiStoreErrorMessage(err, "CachedContent cachedContents/"+id))
+		return
+	}
+	c.JSON(http.StatusOK, geminiCachedContentResource(meta))
+}
+
+// DeleteCachedContent implements DELETE /v1beta/cachedContents/{id}.
+func (h *GeminiAPIHandler) DeleteCachedContent(c *gin.Context) {
+	settings, ok := h.geminiFilesFor(c)
+	if !ok {
+		return
+	}
+	id := c.Param("name")
+	if err := settings.caches.Delete(geminiFilesOwner(c), id); err != nil {
+		h.writeGeminiErrorMessage(c, geminiStoreErrorMessage(err, "CachedContent cachedContents/"+id))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// resolveGeminiFileReferences expands a cachedContent reference and replaces fileData parts that
+// point at locally stored files with inline content, so upstreams that never saw the upload
+// (Antigravity, Auggie, other Gemini keys) receive a self-contained request. References to files
+// this proxy does not hold are left for the upstream.
+func (h *GeminiAPIHandler) resolveGeminiFileReferences(c *gin.Context, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	settings, enabled, err := h.geminiFiles()
+	if !enabled || len(rawJSON) == 0 {
+		return rawJSON, nil
+	}
+	if err != nil {
+		return nil, geminiFilesErrorMessage(http.StatusInternalServerError, "%v", err)
+	}
+	owner := geminiFilesOwner(c)
+
+	if ref := strings.TrimSpace(gjson.GetBytes(rawJSON, "cachedContent").String()); ref != "" {
+		id := strings.TrimPrefix(ref, "cachedContents/")
+		meta, content, errRead := settings.caches.ReadAll(owner, id)
+		if errRead != nil {
+			return nil, geminiStoreErrorMessage(errRead, "CachedContent "+ref)
+		}
+		if cachedModel := strings.TrimPrefix(meta.Attributes["model"], "models/"); cachedModel != modelName {
+			return nil, geminiFilesErrorMessage(http.StatusBadRequest, "Model used by GenerateContent request (models/%s) and CachedContent (models/%s) has to be the same.", modelName, cachedModel)
+		}
+		for _, field := range geminiCachedFields[1:] {
+			if gjson.GetBytes(rawJSON, field).Exists() && gjson.GetBytes(content, field).Exists() {
+				return nil, geminiFilesErrorMessage(http.StatusBadRequest, "CachedContent can not be used with GenerateContent request setting %s.", field)
+			}
+		}
+		contents := []byte(`[]`)
+		for _, source := range [][]byte{content, rawJSON} {
+			for _, item := range gjson.GetBytes(source, "contents").Array() {
+				contents, _ = sjson.SetRawBytes(contents, "-1", []byte(item.Raw))
+			}
+		}
+		rawJSON, _ = sjson.SetRawBytes(rawJSON, "contents", contents)
+		for _, field := range geminiCachedFields[1:] {
+			if value := gjson.GetBytes(content, field); value.Exists() {
+				rawJSON, _ = sjson.SetRawBytes(rawJSON, field, []byte(value.Raw))
+			}
+		}
+		rawJSON, _ = sjson.DeleteBytes(rawJSON, "cachedContent")
+	}
+
+	partLists := []string{"systemInstruction.parts"}
+	for i := range gjson.GetBytes(rawJSON, "contents").Array() {
+		partLists = append(partLists, fmt.Sprintf("contents.%d.parts", i))
+	}
+	for _, partsPath := range partLists {
+		for j, part := range gjson.GetBytes(rawJSON, partsPath).Array() {
+			uri := strings.TrimSpace(part.Get("fileData.fileUri").String())
+			if uri == "" {
+				continue
+			}
+			id, local := geminiLocalFileID(c, uri)
+			if id == "" {
+				continue
+			}
+			meta, data, errRead := settings.files.ReadAll(owner, id)
+			if errRead != nil {
+				if !local {
+					continue
+				}
+				return nil, geminiStoreErrorMessage(errRead, "File files/"+id)
+			}
+			partPath := fmt.Sprintf("%s.%d", partsPath, j)
+			mimeType := strings.TrimSpace(part.Get("fileData.mimeType").String())
+			if mimeType == "" {
+				mimeType = meta.MIMEType
+			}
+			rawJSON, _ = sjson.DeleteBytes(rawJSON, partPath+".fileData")
+			if geminiTextMIMEType(mimeType) {
+				rawJSON, _ = sjson.SetBytes(rawJSON, partPath+".text", string(data))
+				continue
+			}
+			rawJSON, _ = sjson.SetBytes(rawJSON, partPath+".inlineData", map[string]string{
+				"mimeType": mimeType,
+				"data":     base64.StdEncoding.EncodeToString(data),
+			})
+		}
+	}
+	return rawJSON, nil
+}
+
+// geminiLocalFileID extracts the file id from a fileUri of the form files/{id} or
+// .../v1beta/files/{id}. local reports whether the URI names this proxy, in which case a missing
+// file is an error rather than a reference for the upstream.
+func geminiLocalFileID(c *gin.Context, uri string) (id string, local bool) {
+	if rest, ok := strings.CutPrefix(uri, "files/"); ok {
+		return rest, true
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", false
+	}
+	_, rest, ok := strings.Cut(parsed.Path, "/v1beta/files/")
+	if !ok || rest == "" {
+		return "", false
+	}
+	return rest, strings.EqualFold(parsed.Scheme+"://"+parsed.Host, geminiBaseURL(c)) || strings.EqualFold(parsed.Host, c.Request.Host)
+}
+
+// geminiTextMIMEType reports whether a file is sent as a text part rather than inline bytes.
+func geminiTextMIMEType(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/json", mediaType == "application/xml",
+		mediaType == "application/x-yaml", mediaType == "application/yaml",
+		mediaType == "application/javascript", mediaType == "application/x-python":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package gemini
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func newGeminiFilesTestRouter(t *testing.T) (*GeminiAPIHandler, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := &sdkconfig.SDKConfig{GeminiFiles: sdkconfig.GeminiFilesConfig{Enabled: true, Dir: t.TempDir(), MaxFileMB: 1}}
+	h := NewGeminiAPIHandler(handlers.NewBaseAPIHandlers(cfg, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("apiKey", c.GetHeader("x-goog-api-key")) })
+	router.POST("/upload/v1beta/files", h.UploadFile)
+	router.GET("/v1beta/files", h.ListFiles)
+	router.GET("/v1beta/files/:name", h.GetFile)
+	router.POST("/v1beta/cachedContents", h.CreateCachedContent)
+	return h, router
+}
+
+func serveGeminiFiles(router *gin.Engine, method, target, key string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("x-goog-api-key", key)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestGeminiResumableUploadAndCachedContentResolution(t *testing.T) {
+	h, router := newGeminiFilesTestRouter(t)
+
+	start := serveGeminiFiles(router, http.MethodPost, "/upload/v1beta/files", "key-a", `{"file":{"display_name":"notes.txt"}}`, map[string]string{
+		"X-Goog-Upload-Protocol":              "resumable",
+		"X-Goog-Upload-Command":               "start",
+		"X-Goog-Upload-Header-Content-Length": "11",
+		"X-Goog-Upload-Header-Content-Type":   "text/plain",
+	})
+	uploadURL, err := url.Parse(start.Header().Get("X-Goog-Upload-URL"))
+	if start.Code != http.StatusOK || err != nil || uploadURL.Query().Get("upload_id") == "" {
+		t.Fatalf("start status = %d, upload url = %q", start.Code, start.Header().Get("X-Goog-Upload-URL"))
+	}
+	uploaded := serveGeminiFiles(router, http.MethodPost, uploadURL.RequestURI(), "key-a", "hello notes", map[string]string{
+		"X-Goog-Upload-Command": "upload, finalize",
+		"X-Goog-Upload-Offset":  "0",
+	})
+	file := gjson.Get(uploaded.Body.String(), "file")
+	if uploaded.Code != http.StatusOK || file.Get("sizeBytes").String() != "11" || file.Get("displayName").String() != "notes.txt" {
+		t.Fatalf("finalize status = %d body = %s", uploaded.Code, uploaded.Body.String())
+	}
+	if got := serveGeminiFiles(router, http.MethodGet, "/v1beta/"+file.Get("name").String(), "key-b", "", nil); got.Code != http.StatusNotFound {
+		t.Fatalf("other key GET status = %d, want 404", got.Code)
+	}
+
+	pdf := serveGeminiFiles(router, http.MethodPost, "/upload/v1beta/files?uploadType=media", "key-a", "%PDF-1.7", map[string]string{"Content-Type": "application/pdf"})
+	pdfName := gjson.Get(pdf.Body.String(), "file.name").String()
+	if list := serveGeminiFiles(router, http.MethodGet, "/v1beta/files", "key-a", "", nil); len(gjson.Get(list.Body.String(), "files").Array()) != 2 {
+		t.Fatalf("list body = %s", list.Body.String())
+	}
+
+	cache := serveGeminiFiles(router, http.MethodPost, "/v1beta/cachedContents", "key-a", `{
+		"model":"models/gemini-2.5-pro",
+		"ttl":"600s",
+		"systemInstruction":{"parts":[{"text":"You review notes."}]},
+		"contents":[{"role":"user","parts":[{"fileData":{"fileUri":"`+file.Get("uri").String()+`","mimeType":"text/plain"}}]}]
+	}`, nil)
+	cacheName := gjson.Get(cache.Body.String(), "name").String()
+	if cache.Code != http.StatusOK || !strings.HasPrefix(cacheName, "cachedContents/") || gjson.Get(cache.Body.String(), "expireTime").String() == "" {
+		t.Fatalf("create cache status = %d body = %s", cache.Code, cache.Body.String())
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-pro:generateContent", nil)
+	c.Set("apiKey", "key-a")
+	request := []byte(`{"cachedContent":"` + cacheName + `","contents":[{"role":"user","parts":[{"text":"Summarize"},{"fileData":{"fileUri":"` + pdfName + `"}}]}]}`)
+	resolved, errMsg := h.resolveGeminiFileReferences(c, "gemini-2.5-pro", request)
+	if errMsg != nil {
+		t.Fatalf("resolve error = %v", errMsg.Error)
+	}
+	if gjson.GetBytes(resolved, "cachedContent").Exists() || gjson.GetBytes(resolved, "systemInstruction.parts.0.text").String() != "You review notes." {
+		t.Fatalf("cached content not expanded: %s", resolved)
+	}
+	if got := gjson.GetBytes(resolved, "contents.0.parts.0.text").String(); got != "hello notes" {
+		t.Fatalf("cached file part = %q; body=%s", got, resolved)
+	}
+	inline := gjson.GetBytes(resolved, "contents.1.parts.1.inlineData")
+	if inline.Get("mimeType").String() != "application/pdf" || inline.Get("data").String() != base64.StdEncoding.EncodeToString([]byte("%PDF-1.7")) {
+		t.Fatalf("pdf part = %s", inline.Raw)
+	}
+
+	if _, errMsg = h.resolveGeminiFileReferences(c, "gemini-2.5-flash", request); errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("model mismatch error = %+v, want 400", errMsg)
+	}
+	external := []byte(`{"contents":[{"parts":[{"fileData":{"fileUri":"https://generativelanguage.googleapis.com/v1beta/files/abc"}}]}]}`)
+	if out, errMsg := h.resolveGeminiFileReferences(c, "gemini-2.5-pro", external); errMsg != nil || string(out) != string(external) {
+		t.Fatalf("external file reference changed: %s, %+v", out, errMsg)
+	}
+}
+
+func TestGeminiUploadRejectsOversizedFiles(t *testing.T) {
+	_, router := newGeminiFilesTestRouter(t)
+	resp := serveGeminiFiles(router, http.MethodPost, "/upload/v1beta/files", "key-a", `{"file":{}}`, map[string]string{
+		"X-Goog-Upload-Protocol":              "resumable",
+		"X-Goog-Upload-Command":               "start",
+		"X-Goog-Upload-Header-Content-Length": "2097152",
+	})
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413; body=%s", resp.Code, resp.Body.String())
+	}
+}
@@ -136,6 +136,15 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 		}
 	}
 
+	if method == "generateContent" || method == "streamGenerateContent" || method == "countTokens" {
+		resolved, errMsg := h.resolveGeminiFileReferences(c, modelName, rawJSON)
+		if errMsg != nil {
+			h.writeGeminiErrorMessage(c, errMsg)
+			return
+		}
+		rawJSON = resolved
+	}
+
 	switch method {
 	case "generateContent":
 		h.handleGenerateContent(c, modelName, rawJSON)
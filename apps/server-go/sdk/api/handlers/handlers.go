@@ -361,6 +361,9 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 	baseCtx := parentCtx
 	if requestCtx != nil {
 		baseCtx = requestCtx
+		if ResumableStreamFromContext(c) != nil {
+			baseCtx = resumableUpstreamContext(requestCtx)
+		}
 	}
 
 	newCtx, cancel := context.WithCancel(baseCtx)
@@ -488,9 +491,21 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 	if errMsg != nil {
 		return nil, nil, errMsg
 	}
+	payload, redaction, errMsg := h.applyRedaction(ctx, handlerType, rawJSON)
+	if errMsg != nil {
+		return nil, nil, errMsg
+	}
+	normalizedModel, payload, errMsg = h.applyRequestPolicy(ctx, handlerType, normalizedModel, payload)
+	if errMsg != nil {
+		return nil, nil, errMsg
+	}
+	payload = h.applyContextCompaction(ctx, handlerType, normalizedModel, payload)
+	providers, normalizedModel, payload, errMsg = h.applyRequestGuardrails(ctx, handlerType, providers, normalizedModel, payload)
+	if errMsg != nil {
+		return nil, nil, errMsg
+	}
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
-	payload := rawJSON
 	if len(payload) == 0 {
 		payload = nil
 	}
@@ -501,11 +516,19 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 	opts := coreexecutor.Options{
 		Stream:          false,
 		Alt:             alt,
-		OriginalRequest: rawJSON,
+		OriginalRequest: payload,
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
 	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	// Handlers apply the parameter policy before execution; an upstream rejection of a parameter
+	// they could not see is retried once without it.
+	if err != nil {
+		if next, dropped := h.dropRejectedParameter(ctx, normalizedModel, req.Payload, statusFromError(err), err); dropped {
+			req.Payload, opts.OriginalRequest = next, next
+			resp, err = h.AuthManager.Execute(ctx, providers, req, opts)
+		}
+	}
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -521,6 +544,7 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		}
 		return nil, nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
+	resp.Payload = redaction.restoreJSON(resp.Payload)
 	if !PassthroughHeadersEnabled(h.Cfg) {
 		return resp.Payload, nil, nil
 	}
@@ -534,9 +558,16 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 	if errMsg != nil {
 		return nil, nil, errMsg
 	}
+	payload, _, errMsg := h.applyRedaction(ctx, handlerType, rawJSON)
+	if errMsg != nil {
+		return nil, nil, errMsg
+	}
+	normalizedModel, payload, errMsg = h.applyRequestPolicy(ctx, handlerType, normalizedModel, payload)
+	if errMsg != nil {
+		return nil, nil, errMsg
+	}
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
-	payload := rawJSON
 	if len(payload) == 0 {
 		payload = nil
 	}
@@ -547,7 +578,7 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 	opts := coreexecutor.Options{
 		Stream:          false,
 		Alt:             alt,
-		OriginalRequest: rawJSON,
+		OriginalRequest: payload,
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
@@ -584,9 +615,30 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		close(errChan)
 		return nil, nil, errChan
 	}
+	payload, redaction, errMsg := h.applyRedaction(ctx, handlerType, rawJSON)
+	if errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, nil, errChan
+	}
+	normalizedModel, payload, errMsg = h.applyRequestPolicy(ctx, handlerType, normalizedModel, payload)
+	if errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, nil, errChan
+	}
+	payload = h.applyContextCompaction(ctx, handlerType, normalizedModel, payload)
+	providers, normalizedModel, payload, errMsg = h.applyRequestGuardrails(ctx, handlerType, providers, normalizedModel, payload)
+	if errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, nil, errChan
+	}
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
-	payload := rawJSON
 	if len(payload) == 0 {
 		payload = nil
 	}
@@ -597,11 +649,17 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	opts := coreexecutor.Options{
 		Stream:          true,
 		Alt:             alt,
-		OriginalRequest: rawJSON,
+		OriginalRequest: payload,
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
 	streamResult, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	if err != nil {
+		if next, dropped := h.dropRejectedParameter(ctx, normalizedModel, req.Payload, statusFromError(err), err); dropped {
+			req.Payload, opts.OriginalRequest = next, next
+			streamResult, err = h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+		}
+	}
 	if err != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
@@ -631,6 +689,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		}
 	}
 	chunks := streamResult.Chunks
+	restorer := redaction.streamRestorer(handlerType)
 	dataChan := make(chan []byte)
 	errChan := make(chan *interfaces.ErrorMessage, 1)
 	go func() {
@@ -739,7 +798,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 						}
 					}
 					sentPayload = true
-					if okSendData := sendData(cloneBytes(chunk.Payload)); !okSendData {
+					if okSendData := sendData(restorer.restore(cloneBytes(chunk.Payload))); !okSendData {
 						return
 					}
 				}
@@ -0,0 +1,511 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// completionsContinuationInstructions turn a chat model into a raw text continuation engine.
+	completionsContinuationInstructions = "You are a raw text completion engine. Continue the user's text exactly where it stops. Output only the continuation: do not repeat the given text, do not explain and do not add formatting that is not part of the continuation."
+	// completionsFillInMiddleInstructions implement suffix-based fill-in-the-middle prompting.
+	completionsFillInMiddleInstructions = "You are a fill-in-the-middle completion engine. The user message contains a <prefix> and a <suffix>. Output only the text that belongs between them so that prefix + output + suffix reads as one continuous document. Do not repeat the prefix or the suffix and do not explain."
+
+	// completionsEmptyPrompt stands in for an empty prompt, which chat backends reject.
+	completionsEmptyPrompt = "Complete this:"
+)
+
+// Completions handles the legacy /v1/completions endpoint.
+// Each prompt is adapted to a chat completions request (with fill-in-the-middle prompting when
+// suffix is set) and the chat responses are reshaped into text_completion objects. Multiple prompts
+// are executed in order and their choices are indexed prompt-major, as in the OpenAI API.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) Completions(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	// If data retrieval fails, return a 400 Bad Request error.
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	if errMsg := validateOpenAISurfaceModel(gjson.GetBytes(rawJSON, "model").String()); errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		return
+	}
+	prompts, errMsg := parseCompletionsPrompts(rawJSON)
+	if errMsg == nil {
+		errMsg = validateCompletionsParameters(rawJSON)
+	}
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		return
+	}
+
+	if gjson.GetBytes(rawJSON, "stream").Type == gjson.True {
+		h.handleCompletionsStreamingResponse(c, rawJSON, prompts)
+	} else {
+		h.handleCompletionsNonStreamingResponse(c, rawJSON, prompts)
+	}
+}
+
+// parseCompletionsPrompts returns the prompts of a completions request. Token-array prompts are
+// rejected because the backends only accept text.
+func parseCompletionsPrompts(rawJSON []byte) ([]string, *interfaces.ErrorMessage) {
+	prompt := gjson.GetBytes(rawJSON, "prompt")
+	switch {
+	case !prompt.Exists() || prompt.Type == gjson.Null:
+		return []string{""}, nil
+	case prompt.Type == gjson.String:
+		return []string{prompt.String()}, nil
+	case prompt.IsArray():
+		items := prompt.Array()
+		if len(items) == 0 {
+			return []string{""}, nil
+		}
+		prompts := make([]string, 0, len(items))
+		for index, item := range items {
+			if item.Type != gjson.String {
+				return nil, invalidOpenAIValue(fmt.Sprintf("prompt[%d]", index), "Invalid value for 'prompt': token array prompts are not supported; send a string or an array of strings.")
+			}
+			prompts = append(prompts, item.String())
+		}
+		return prompts, nil
+	default:
+		return nil, invalidOpenAIType("prompt", "a string or an array of strings", prompt)
+	}
+}
+
+func validateCompletionsParameters(rawJSON []byte) *interfaces.ErrorMessage {
+	if errMsg := validateOpenAIOptionalStopField(rawJSON); errMsg != nil {
+		return errMsg
+	}
+	if errMsg := validateOpenAIOptionalIntegerRangeField(rawJSON, "n", 1, 128); errMsg != nil {
+		return errMsg
+	}
+	if errMsg := validateOpenAIOptionalIntegerRangeField(rawJSON, "logprobs", 0, 5); errMsg != nil {
+		return errMsg
+	}
+	if errMsg := validateOpenAIOptionalBooleanField(rawJSON, "echo"); errMsg != nil {
+		return errMsg
+	}
+	if suffix := gjson.GetBytes(rawJSON, "suffix"); suffix.Exists() && suffix.Type != gjson.Null && suffix.Type != gjson.String {
+		return invalidOpenAIType("suffix", "a string", suffix)
+	}
+	return nil
+}
+
+func completionsChoiceCount(root gjson.Result) int {
+	if n := root.Get("n").Int(); n > 1 {
+		return int(n)
+	}
+	return 1
+}
+
+// buildCompletionsChatRequest converts one prompt of a completions request into a chat completions
+// request. best_of is not forwarded; n candidates are requested instead.
+func buildCompletionsChatRequest(root gjson.Result, prompt string, stream bool) []byte {
+	instructions := completionsContinuationInstructions
+	userContent := prompt
+	if suffix := root.Get("suffix").String(); suffix != "" {
+		instructions = completionsFillInMiddleInstructions
+		userContent = "<prefix>" + prompt + "</prefix>\n<suffix>" + suffix + "</suffix>"
+	} else if strings.TrimSpace(userContent) == "" {
+		userContent = completionsEmptyPrompt
+	}
+
+	out := `{"model":"","messages":[{"role":"system","content":""},{"role":"user","content":""}]}`
+	out, _ = sjson.Set(out, "model", root.Get("model").String())
+	out, _ = sjson.Set(out, "messages.0.content", instructions)
+	out, _ = sjson.Set(out, "messages.1.content", userContent)
+
+	for _, field := range []string{"max_tokens", "temperature", "top_p", "frequency_penalty", "presence_penalty", "stop", "seed", "user", "logit_bias"} {
+		if value := root.Get(field); value.Exists() && value.Type != gjson.Null {
+			out, _ = sjson.SetRaw(out, field, value.Raw)
+		}
+	}
+	if n := completionsChoiceCount(root); n > 1 {
+		out, _ = sjson.Set(out, "n", n)
+	}
+	if logprobs := root.Get("logprobs"); logprobs.Type == gjson.Number {
+		out, _ = sjson.Set(out, "logprobs", true)
+		out, _ = sjson.Set(out, "top_logprobs", logprobs.Int())
+	}
+	if stream {
+		out, _ = sjson.Set(out, "stream", true)
+		out, _ = sjson.Set(out, "stream_options.include_usage", true)
+	}
+	return []byte(out)
+}
+
+// convertChatLogprobsToCompletions reshapes chat logprobs.content into the legacy
+// tokens/token_logprobs/top_logprobs/text_offset form. offset is the text offset of the first token.
+func convertChatLogprobsToCompletions(logprobs gjson.Result, offset int) any {
+	content := logprobs.Get("content")
+	if !content.IsArray() {
+		return nil
+	}
+	tokens := []string{}
+	tokenLogprobs := []float64{}
+	topLogprobs := []map[string]float64{}
+	textOffsets := []int{}
+	content.ForEach(func(_, entry gjson.Result) bool {
+		token := entry.Get("token").String()
+		tokens = append(tokens, token)
+		tokenLogprobs = append(tokenLogprobs, entry.Get("logprob").Float())
+		top := map[string]float64{}
+		entry.Get("top_logprobs").ForEach(func(_, alt gjson.Result) bool {
+			top[alt.Get("token").String()] = alt.Get("logprob").Float()
+			return true
+		})
+		topLogprobs = append(topLogprobs, top)
+		textOffsets = append(textOffsets, offset)
+		offset += len(token)
+		return true
+	})
+	return map[string]any{
+		"tokens":         tokens,
+		"token_logprobs": tokenLogprobs,
+		"top_logprobs":   topLogprobs,
+		"text_offset":    textOffsets,
+	}
+}
+
+// completionsUsage accumulates token usage across prompts.
+type completionsUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+func (u *completionsUsage) add(usage gjson.Result) {
+	if !usage.Exists() || usage.Type == gjson.Null {
+		return
+	}
+	u.PromptTokens += usage.Get("prompt_tokens").Int()
+	u.CompletionTokens += usage.Get("completion_tokens").Int()
+	u.TotalTokens += usage.Get("total_tokens").Int()
+}
+
+func newCompletionID() string {
+	return "cmpl-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// handleCompletionsNonStreamingResponse executes every prompt and returns one text_completion object.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+//   - rawJSON: The raw JSON bytes of the completions request
+//   - prompts: The prompts parsed from the request
+func (h *OpenAIAPIHandler) handleCompletionsNonStreamingResponse(c *gin.Context, rawJSON []byte, prompts []string) {
+	c.Header("Content-Type", "application/json")
+
+	root := gjson.ParseBytes(rawJSON)
+	modelName := root.Get("model").String()
+	n := completionsChoiceCount(root)
+	echo := root.Get("echo").Bool()
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	stopKeepAlive := h.StartNonStreamingKeepAlive(c, cliCtx)
+
+	choices := make([]map[string]any, 0, len(prompts)*n)
+	var usage completionsUsage
+	var upstreamHeaders http.Header
+	for promptIndex, prompt := range prompts {
+		resp, headers, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, buildCompletionsChatRequest(root, prompt, false), "")
+		if errMsg != nil {
+			stopKeepAlive()
+			h.WriteErrorResponse(c, errMsg)
+			cliCancel(errMsg.Error)
+			return
+		}
+		if promptIndex == 0 {
+			upstreamHeaders = headers
+		}
+		chatResp := gjson.ParseBytes(resp)
+		usage.add(chatResp.Get("usage"))
+		chatResp.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+			text := choice.Get("message.content").String()
+			offset := 0
+			if echo {
+				text = prompt + text
+				offset = len(prompt)
+			}
+			var finishReason any
+			if reason := choice.Get("finish_reason"); reason.Type == gjson.String {
+				finishReason = reason.String()
+			}
+			choices = append(choices, map[string]any{
+				"text":          text,
+				"index":         promptIndex*n + int(choice.Get("index").Int()),
+				"logprobs":      convertChatLogprobsToCompletions(choice.Get("logprobs"), offset),
+				"finish_reason": finishReason,
+			})
+			return true
+		})
+	}
+	stopKeepAlive()
+
+	body, err := json.Marshal(map[string]any{
+		"id":      newCompletionID(),
+		"object":  "text_completion",
+		"created": time.Now().Unix(),
+		"model":   modelName,
+		"choices": choices,
+		"usage":   usage,
+	})
+	if err != nil {
+		errMsg := &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(err)
+		return
+	}
+	handlers.WriteUpstreamHeaders(c.Writer.Header(), upstreamHeaders)
+	_, _ = c.Writer.Write(body)
+	cliCancel()
+}
+
+// completionsStreamConverter turns chat completion chunks into text_completion chunks that share
+// one id, re-indexing choices for the prompt currently being streamed.
+type completionsStreamConverter struct {
+	id          string
+	created     int64
+	model       string
+	n           int
+	offset      int
+	textOffsets map[int]int
+	usage       completionsUsage
+}
+
+func newCompletionsStreamConverter(model string, n int) *completionsStreamConverter {
+	return &completionsStreamConverter{
+		id:          newCompletionID(),
+		created:     time.Now().Unix(),
+		model:       model,
+		n:           n,
+		textOffsets: make(map[int]int),
+	}
+}
+
+func (s *completionsStreamConverter) chunk(choices []map[string]any, usage *completionsUsage) []byte {
+	payload := map[string]any{
+		"id":      s.id,
+		"object":  "text_completion",
+		"created": s.created,
+		"model":   s.model,
+		"choices": choices,
+	}
+	if usage != nil {
+		payload["usage"] = usage
+	}
+	body, _ := json.Marshal(payload)
+	return body
+}
+
+// begin switches to the prompt at promptIndex and returns the echo chunks, if any.
+func (s *completionsStreamConverter) begin(promptIndex int, prompt string, echo bool) [][]byte {
+	s.offset = promptIndex * s.n
+	if !echo || prompt == "" {
+		return nil
+	}
+	out := make([][]byte, 0, s.n)
+	for i := 0; i < s.n; i++ {
+		index := s.offset + i
+		s.textOffsets[index] = len(prompt)
+		out = append(out, s.chunk([]map[string]any{{"text": prompt, "index": index, "logprobs": nil, "finish_reason": nil}}, nil))
+	}
+	return out
+}
+
+// convert returns the text_completion chunk for a chat completion chunk, or nil when it carries no text
+// or finish reason. Usage-only chunks are accumulated and reported once at the end of the stream.
+func (s *completionsStreamConverter) convert(raw []byte) []byte {
+	root := gjson.ParseBytes(raw)
+	s.usage.add(root.Get("usage"))
+
+	choices := make([]map[string]any, 0, 1)
+	root.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+		text := choice.Get("delta.content").String()
+		reason := choice.Get("finish_reason")
+		if text == "" && reason.Type != gjson.String {
+			return true
+		}
+		index := s.offset + int(choice.Get("index").Int())
+		var finishReason any
+		if reason.Type == gjson.String {
+			finishReason = reason.String()
+		}
+		choices = append(choices, map[string]any{
+			"text":          text,
+			"index":         index,
+			"logprobs":      convertChatLogprobsToCompletions(choice.Get("logprobs"), s.textOffsets[index]),
+			"finish_reason": finishReason,
+		})
+		s.textOffsets[index] += len(text)
+		return true
+	})
+	if len(choices) == 0 {
+		return nil
+	}
+	return s.chunk(choices, nil)
+}
+
+// usageChunk returns the final usage chunk sent when stream_options.include_usage is set.
+func (s *completionsStreamConverter) usageChunk() []byte {
+	return s.chunk([]map[string]any{}, &s.usage)
+}
+
+// handleCompletionsStreamingResponse streams text_completion chunks for every prompt in order.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+//   - rawJSON: The raw JSON bytes of the completions request
+//   - prompts: The prompts parsed from the request
+func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, rawJSON []byte, prompts []string) {
+	// Get the http.Flusher interface to manually flush the response.
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Streaming not supported",
+				Type:    "server_error",
+			},
+		})
+		return
+	}
+
+	root := gjson.ParseBytes(rawJSON)
+	modelName := root.Get("model").String()
+	echo := root.Get("echo").Bool()
+	includeUsage := root.Get("stream_options.include_usage").Bool()
+	converter := newCompletionsStreamConverter(modelName, completionsChoiceCount(root))
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	dataChan, upstreamHeaders, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, buildCompletionsChatRequest(root, prompts[0], true), "")
+
+	setSSEHeaders := func() {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("Access-Control-Allow-Origin", "*")
+	}
+
+	// Peek at the first chunk so that an immediate upstream failure is returned as a JSON error.
+	var first []byte
+	for first == nil {
+		select {
+		case <-c.Request.Context().Done():
+			cliCancel(c.Request.Context().Err())
+			return
+		case errMsg, ok := <-errChan:
+			if !ok {
+				// Err channel closed cleanly; wait for data channel.
+				errChan = nil
+				continue
+			}
+			h.WriteErrorResponse(c, errMsg)
+			if errMsg != nil {
+				cliCancel(errMsg.Error)
+			} else {
+				cliCancel(nil)
+			}
+			return
+		case chunk, ok := <-dataChan:
+			if !ok {
+				dataChan = nil
+				first = []byte{}
+				continue
+			}
+			first = chunk
+		}
+	}
+
+	// Success! Commit to streaming headers.
+	setSSEHeaders()
+	handlers.WriteUpstreamHeaders(c.Writer.Header(), upstreamHeaders)
+
+	done := make(chan struct{})
+	var doneOnce sync.Once
+	stop := func() { doneOnce.Do(func() { close(done) }) }
+
+	convertedChan := make(chan []byte)
+	convertedErrs := make(chan *interfaces.ErrorMessage, 1)
+	go func() {
+		defer close(convertedErrs)
+		defer close(convertedChan)
+
+		send := func(chunk []byte) bool {
+			if chunk == nil {
+				return true
+			}
+			select {
+			case <-done:
+				return false
+			case convertedChan <- chunk:
+				return true
+			}
+		}
+
+		for promptIndex, prompt := range prompts {
+			if promptIndex > 0 {
+				dataChan, _, errChan = h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, buildCompletionsChatRequest(root, prompt, true), "")
+			}
+			for _, chunk := range converter.begin(promptIndex, prompt, echo) {
+				if !send(chunk) {
+					return
+				}
+			}
+			if promptIndex == 0 && len(first) > 0 && !send(converter.convert(first)) {
+				return
+			}
+			for dataChan != nil {
+				select {
+				case <-done:
+					return
+				case chunk, ok := <-dataChan:
+					if !ok {
+						dataChan = nil
+						continue
+					}
+					if !send(converter.convert(chunk)) {
+						return
+					}
+				}
+			}
+			if errChan != nil {
+				for errMsg := range errChan {
+					if errMsg != nil {
+						convertedErrs <- errMsg
+						return
+					}
+				}
+			}
+		}
+		if includeUsage {
+			send(converter.usageChunk())
+		}
+	}()
+
+	h.handleStreamResult(c, flusher, func(err error) {
+		stop()
+		cliCancel(err)
+	}, nil, convertedChan, convertedErrs)
+}
@@ -0,0 +1,155 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestBuildCompletionsChatRequest_SuffixUsesFillInTheMiddle(t *testing.T) {
+	root := gjson.Parse(`{"model":"m","prompt":"def add(a, b):","suffix":"\n\nprint(add(1, 2))","max_tokens":16,"logprobs":2,"best_of":3,"n":2}`)
+	out := buildCompletionsChatRequest(root, root.Get("prompt").String(), true)
+
+	if got := gjson.GetBytes(out, "messages.0.content").String(); got != completionsFillInMiddleInstructions {
+		t.Fatalf("system instructions = %q, want fill-in-the-middle", got)
+	}
+	if got := gjson.GetBytes(out, "messages.1.content").String(); got != "<prefix>def add(a, b):</prefix>\n<suffix>\n\nprint(add(1, 2))</suffix>" {
+		t.Fatalf("user content = %q", got)
+	}
+	if gjson.GetBytes(out, "max_tokens").Int() != 16 || gjson.GetBytes(out, "n").Int() != 2 {
+		t.Fatalf("expected max_tokens and n to be forwarded, got %s", out)
+	}
+	if !gjson.GetBytes(out, "logprobs").Bool() || gjson.GetBytes(out, "top_logprobs").Int() != 2 {
+		t.Fatalf("expected logprobs to map to top_logprobs, got %s", out)
+	}
+	if gjson.GetBytes(out, "best_of").Exists() {
+		t.Fatalf("best_of must not be forwarded, got %s", out)
+	}
+	if !gjson.GetBytes(out, "stream_options.include_usage").Bool() {
+		t.Fatalf("expected streaming request to ask for usage, got %s", out)
+	}
+}
+
+func TestConvertChatLogprobsToCompletions_LegacyShape(t *testing.T) {
+	logprobs := gjson.Parse(`{"content":[{"token":"Hel","logprob":-0.1,"top_logprobs":[{"token":"Hel","logprob":-0.1},{"token":"Hi","logprob":-2}]},{"token":"lo","logprob":-0.2,"top_logprobs":[]}]}`)
+	out, _ := convertChatLogprobsToCompletions(logprobs, 3).(map[string]any)
+	if out == nil {
+		t.Fatalf("expected legacy logprobs object")
+	}
+	if offsets := out["text_offset"].([]int); len(offsets) != 2 || offsets[0] != 3 || offsets[1] != 6 {
+		t.Fatalf("text_offset = %v, want [3 6]", offsets)
+	}
+	if top := out["top_logprobs"].([]map[string]float64); top[0]["Hi"] != -2 {
+		t.Fatalf("top_logprobs = %v", top)
+	}
+	if convertChatLogprobsToCompletions(gjson.Result{}, 0) != nil {
+		t.Fatalf("expected nil when the chat choice has no logprobs")
+	}
+}
+
+func TestCompletions_MultiplePromptsIndexChoicesAndSumUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	executor, manager, auth := newOpenAISurfaceTestHarness(t)
+	executor.payload = []byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":" one"},"finish_reason":"stop"},{"index":1,"message":{"role":"assistant","content":" two"},"finish_reason":"length"}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`)
+	registerSurfaceModel(t, auth.ID, auth.Provider, &registry.ModelInfo{ID: "claude-opus-4-6", Object: "model", OwnedBy: "antigravity", Type: "antigravity"})
+
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	router.POST("/v1/completions", h.Completions)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(`{"model":"claude-opus-4-6","prompt":["a","b"],"n":2,"echo":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body=%s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	if executor.executeCalls != 2 {
+		t.Fatalf("execute calls = %d, want one per prompt", executor.executeCalls)
+	}
+	body := gjson.Parse(resp.Body.String())
+	if body.Get("object").String() != "text_completion" || !strings.HasPrefix(body.Get("id").String(), "cmpl-") {
+		t.Fatalf("unexpected envelope: %s", resp.Body.String())
+	}
+	choices := body.Get("choices").Array()
+	if len(choices) != 4 {
+		t.Fatalf("expected 4 choices, got %s", body.Get("choices").Raw)
+	}
+	if choices[3].Get("index").Int() != 3 || choices[3].Get("text").String() != "b two" || choices[3].Get("finish_reason").String() != "length" {
+		t.Fatalf("unexpected last choice: %s", choices[3].Raw)
+	}
+	if body.Get("usage.total_tokens").Int() != 10 {
+		t.Fatalf("usage.total_tokens = %d, want 10", body.Get("usage.total_tokens").Int())
+	}
+}
+
+func TestCompletions_RejectsTokenArrayPrompt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	executor, manager, auth := newOpenAISurfaceTestHarness(t)
+	registerSurfaceModel(t, auth.ID, auth.Provider, &registry.ModelInfo{ID: "claude-opus-4-6", Object: "model", OwnedBy: "antigravity", Type: "antigravity"})
+
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	router.POST("/v1/completions", h.Completions)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(`{"model":"claude-opus-4-6","prompt":[[1,2,3]]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d; body=%s", resp.Code, http.StatusBadRequest, resp.Body.String())
+	}
+	if executor.executeCalls != 0 {
+		t.Fatalf("execute calls = %d, want 0", executor.executeCalls)
+	}
+}
+
+func TestCompletions_StreamConvertsChunksAndReportsUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	executor, manager, auth := newOpenAISurfaceTestHarness(t)
+	executor.streamPayload = []byte(`{"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	registerSurfaceModel(t, auth.ID, auth.Provider, &registry.ModelInfo{ID: "claude-opus-4-6", Object: "model", OwnedBy: "antigravity", Type: "antigravity"})
+
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	router.POST("/v1/completions", h.Completions)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(`{"model":"claude-opus-4-6","prompt":["hel","hel"],"stream":true,"stream_options":{"include_usage":true}}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body=%s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	var events []gjson.Result
+	for _, line := range strings.Split(resp.Body.String(), "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok && data != "[DONE]" {
+			events = append(events, gjson.Parse(data))
+		}
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected two text chunks and one usage chunk, got %s", resp.Body.String())
+	}
+	if events[1].Get("choices.0.index").Int() != 1 || events[1].Get("id").String() != events[0].Get("id").String() {
+		t.Fatalf("second prompt chunk must be re-indexed under the same id: %s", events[1].Raw)
+	}
+	if events[2].Get("usage.total_tokens").Int() != 4 {
+		t.Fatalf("usage chunk = %s, want total_tokens 4", events[2].Raw)
+	}
+	if !strings.Contains(resp.Body.String(), "data: [DONE]") {
+		t.Fatalf("expected stream terminator, got %s", resp.Body.String())
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
 	"github.com/tidwall/gjson"
@@ -63,6 +64,7 @@ func (s *storedOpenAIConversationStore) Create(metadata map[string]any, items []
 		UpdatedAt: now,
 	}
 	s.items[conversation.ID] = conversation
+	s.publishLocked(conversation)
 	return cloneStoredOpenAIConversation(conversation)
 }
 
@@ -73,7 +75,7 @@ func (s *storedOpenAIConversationStore) Load(conversationID string) (storedOpenA
 	now := time.Now().UTC()
 	s.cleanupLocked(now)
 
-	conversation, ok := s.items[strings.TrimSpace(conversationID)]
+	conversation, ok := s.lookupLocked(strings.TrimSpace(conversationID))
 	if !ok {
 		return storedOpenAIConversation{}, false
 	}
@@ -88,7 +90,7 @@ func (s *storedOpenAIConversationStore) AddItems(conversationID string, items []
 	s.cleanupLocked(now)
 
 	conversationID = strings.TrimSpace(conversationID)
-	conversation, ok := s.items[conversationID]
+	conversation, ok := s.lookupLocked(conversationID)
 	if !ok {
 		return storedOpenAIConversation{}, nil, false
 	}
@@ -97,6 +99,7 @@ func (s *storedOpenAIConversationStore) AddItems(conversationID string, items []
 	conversation.Items = append(conversation.Items, normalized...)
 	conversation.UpdatedAt = now
 	s.items[conversationID] = conversation
+	s.publishLocked(conversation)
 	return cloneStoredOpenAIConversation(conversation), cloneMapStringAnySlice(normalized), true
 }
 
@@ -108,7 +111,7 @@ func (s *storedOpenAIConversationStore) UpdateMetadata(conversationID string, me
 	s.cleanupLocked(now)
 
 	conversationID = strings.TrimSpace(conversationID)
-	conversation, ok := s.items[conversationID]
+	conversation, ok := s.lookupLocked(conversationID)
 	if !ok {
 		return storedOpenAIConversation{}, false
 	}
@@ -116,6 +119,7 @@ func (s *storedOpenAIConversationStore) UpdateMetadata(conversationID string, me
 	conversation.Metadata = cloneMapStringAny(metadata)
 	conversation.UpdatedAt = now
 	s.items[conversationID] = conversation
+	s.publishLocked(conversation)
 	return cloneStoredOpenAIConversation(conversation), true
 }
 
@@ -127,10 +131,11 @@ func (s *storedOpenAIConversationStore) Delete(conversationID string) bool {
 	s.cleanupLocked(now)
 
 	conversationID = strings.TrimSpace(conversationID)
-	if _, ok := s.items[conversationID]; !ok {
+	if _, ok := s.lookupLocked(conversationID); !ok {
 		return false
 	}
 	delete(s.items, conversationID)
+	cluster.Remove(cluster.NamespaceConversation, conversationID)
 	return true
 }
 
@@ -142,7 +147,7 @@ func (s *storedOpenAIConversationStore) GetItem(conversationID string, itemID st
 	s.cleanupLocked(now)
 
 	conversationID = strings.TrimSpace(conversationID)
-	conversation, ok := s.items[conversationID]
+	conversation, ok := s.lookupLocked(conversationID)
 	if !ok {
 		return nil, false, false
 	}
@@ -164,7 +169,7 @@ func (s *storedOpenAIConversationStore) DeleteItem(conversationID string, itemID
 	s.cleanupLocked(now)
 
 	conversationID = strings.TrimSpace(conversationID)
-	conversation, ok := s.items[conversationID]
+	conversation, ok := s.lookupLocked(conversationID)
 	if !ok {
 		return storedOpenAIConversation{}, false, false
 	}
@@ -186,6 +191,7 @@ func (s *storedOpenAIConversationStore) DeleteItem(conversationID string, itemID
 	conversation.Items = cloneMapStringAnySlice(updatedItems)
 	conversation.UpdatedAt = now
 	s.items[conversationID] = conversation
+	s.publishLocked(conversation)
 	return cloneStoredOpenAIConversation(conversation), true, true
 }
 
@@ -195,6 +201,26 @@ func (s *storedOpenAIConversationStore) Reset() {
 	s.items = make(map[string]storedOpenAIConversation)
 }
 
+// lookupLocked returns the stored conversation. In cluster mode the shared copy is
+// authoritative; the local copy is used only when the backend is unreachable.
+func (s *storedOpenAIConversationStore) lookupLocked(conversationID string) (storedOpenAIConversation, bool) {
+	var shared storedOpenAIConversation
+	if found, reachable := cluster.Lookup(cluster.NamespaceConversation, conversationID, &shared); reachable {
+		if !found {
+			delete(s.items, conversationID)
+			return storedOpenAIConversation{}, false
+		}
+		s.items[conversationID] = shared
+		return shared, true
+	}
+	conversation, ok := s.items[conversationID]
+	return conversation, ok
+}
+
+func (s *storedOpenAIConversationStore) publishLocked(conversation storedOpenAIConversation) {
+	cluster.Save(cluster.NamespaceConversation, conversation.ID, conversation, storedOpenAIConversationTTL)
+}
+
 func (s *storedOpenAIConversationStore) cleanupLocked(now time.Time) {
 	cutoff := now.Add(-storedOpenAIConversationTTL)
 	for key, item := range s.items {
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
@@ -744,3 +745,28 @@ func TestAttachOpenAIConversationToStreamChunkAddsConversationToTerminalResponse
 		t.Fatalf("expected conversation linkage in terminal chunk, got %s", updated)
 	}
 }
+
+func TestConversationStore_ClusterModeSharesConversationsAcrossNodes(t *testing.T) {
+	cluster.Install(cluster.NewMemoryBackend(), "node-1")
+	t.Cleanup(cluster.Reset)
+
+	nodeA := &storedOpenAIConversationStore{items: make(map[string]storedOpenAIConversation)}
+	nodeB := &storedOpenAIConversationStore{items: make(map[string]storedOpenAIConversation)}
+
+	created := nodeA.Create(map[string]any{"topic": "demo"}, []map[string]any{{"id": "msg_1", "type": "message"}})
+	if _, _, ok := nodeB.AddItems(created.ID, []map[string]any{{"id": "msg_2", "type": "message"}}); !ok {
+		t.Fatalf("node B could not find conversation %s created on node A", created.ID)
+	}
+
+	loaded, ok := nodeA.Load(created.ID)
+	if !ok || len(loaded.Items) != 2 {
+		t.Fatalf("node A conversation = %+v, want the item added on node B", loaded)
+	}
+
+	if !nodeB.Delete(created.ID) {
+		t.Fatal("node B Delete() = false")
+	}
+	if _, ok = nodeA.Load(created.ID); ok {
+		t.Fatal("node A still returns a conversation deleted on node B")
+	}
+}
@@ -0,0 +1,454 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/filestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultOpenAIFilesMaxMB  = 100
+	defaultOpenAIFileTTL     = 30 * 24 * time.Hour
+	openAIFilesMaxListLimit  = 10000
+	openAIFilesMaxPurposeLen = 64
+)
+
+var openAIFilePurposes = map[string]struct{}{
+	"assistants": {},
+	"batch":      {},
+	"fine-tune":  {},
+	"vision":     {},
+	"user_data":  {},
+	"evals":      {},
+}
+
+// openAIFilesSettings is the resolved files configuration.
+type openAIFilesSettings struct {
+	store  *filestore.Store
+	limits filestore.Limits
+}
+
+// openAIFiles resolves the files settings, or reports false when the feature is disabled.
+func openAIFiles(cfg *sdkconfig.SDKConfig) (openAIFilesSettings, bool, error) {
+	if cfg == nil || !cfg.Files.Enabled {
+		return openAIFilesSettings{}, false, nil
+	}
+	dir := strings.TrimSpace(cfg.Files.Dir)
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "cliproxy-openai-files")
+	}
+	settings := openAIFilesSettings{limits: filestore.Limits{
+		MaxBytes: int64(defaultOpenAIFilesMaxMB) << 20,
+		TTL:      defaultOpenAIFileTTL,
+	}}
+	if cfg.Files.MaxFileMB > 0 {
+		settings.limits.MaxBytes = int64(cfg.Files.MaxFileMB) << 20
+	}
+	if cfg.Files.QuotaMB > 0 {
+		settings.limits.QuotaBytes = int64(cfg.Files.QuotaMB) << 20
+	}
+	if cfg.Files.TTLHours > 0 {
+		settings.limits.TTL = time.Duration(cfg.Files.TTLHours) * time.Hour
+	}
+	store, err := filestore.Open(dir)
+	if err != nil {
+		return openAIFilesSettings{}, true, err
+	}
+	settings.store = store
+	return settings, true, nil
+}
+
+// openAIFilesFor writes the error response and reports false when the endpoints are unavailable.
+func (h *OpenAIAPIHandler) openAIFilesFor(c *gin.Context) (openAIFilesSettings, bool) {
+	settings, enabled, err := openAIFiles(h.Cfg)
+	if !enabled {
+		h.WriteErrorResponse(c, openAIFilesError(http.StatusNotFound, "", "not_found", "%s not found. Enable files to use the Files API.", c.Request.URL.Path))
+		return settings, false
+	}
+	if err != nil {
+		h.WriteErrorResponse(c, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err})
+		return settings, false
+	}
+	return settings, true
+}
+
+func openAIFilesOwner(c *gin.Context) string {
+	return filestore.OwnerKey(c.GetString("apiKey"))
+}
+
+func openAIFilesError(status int, param, code, format string, args ...any) *interfaces.ErrorMessage {
+	errMsg := invalidOpenAIRequestWithDetailf(param, code, format, args...)
+	errMsg.StatusCode = status
+	return errMsg
+}
+
+func openAIFileStoreError(err error, fileID, param string) *interfaces.ErrorMessage {
+	switch {
+	case errors.Is(err, filestore.ErrNotFound):
+		return openAIFilesError(http.StatusNotFound, param, "not_found", "No such File object: %s", fileID)
+	case errors.Is(err, filestore.ErrTooLarge):
+		return openAIFilesError(http.StatusRequestEntityTooLarge, "file", "file_too_large", "The uploaded file exceeds the maximum size allowed by this proxy.")
+	case errors.Is(err, filestore.ErrQuotaExceeded):
+		return openAIFilesError(http.StatusForbidden, "file", "storage_quota_exceeded", "Storing this file would exceed the file storage quota for this API key.")
+	default:
+		return &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+	}
+}
+
+// openAIFileObject renders stored metadata as an OpenAI File object.
+func openAIFileObject(meta filestore.Meta) map[string]any {
+	object := map[string]any{
+		"id":         meta.ID,
+		"object":     "file",
+		"bytes":      meta.Size,
+		"created_at": meta.CreatedAt.Unix(),
+		"filename":   meta.Name,
+		"purpose":    meta.Purpose,
+		"status":     "processed",
+	}
+	if !meta.ExpiresAt.IsZero() {
+		object["expires_at"] = meta.ExpiresAt.Unix()
+	}
+	return object
+}
+
+// openAIFileMIMEType returns the declared content type of an upload, falling back to the file
+// extension when the client sent none or a generic one.
+func openAIFileMIMEType(declared, filename string) string {
+	mediaType, _, err := mime.ParseMediaType(declared)
+	if err == nil && mediaType != "" && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if byExt, ok := misc.MimeTypes[ext]; ok {
+		return byExt
+	}
+	if byExt := mime.TypeByExtension("." + ext); ext != "" && byExt != "" {
+		mediaType, _, _ = mime.ParseMediaType(byExt)
+		return mediaType
+	}
+	return "application/octet-stream"
+}
+
+// openAIFileIsText reports whether a stored file can be sent upstream as extracted text.
+func openAIFileIsText(mimeType string, data []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/json", mediaType == "application/xml",
+		mediaType == "application/x-yaml", mediaType == "application/yaml",
+		mediaType == "application/javascript", mediaType == "application/x-python":
+		return true
+	case mediaType == "", mediaType == "application/octet-stream":
+		// Source files without a registered extension still read as text.
+		return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+	}
+	return false
+}
+
+// UploadFile implements POST /v1/files for multipart/form-data uploads with file and purpose fields.
+func (h *OpenAIAPIHandler) UploadFile(c *gin.Context) {
+	settings, ok := h.openAIFilesFor(c)
+	if !ok {
+		return
+	}
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		h.WriteErrorResponse(c, invalidOpenAIRequestWithDetail("Uploads must be sent as multipart/form-data with 'file' and 'purpose' fields.", "file", "invalid_request"))
+		return
+	}
+
+	owner := openAIFilesOwner(c)
+	var stored *filestore.Meta
+	discard := func() {
+		if stored != nil {
+			_ = settings.store.Delete(owner, stored.ID)
+		}
+	}
+	purpose := ""
+	for {
+		part, errPart := reader.NextPart()
+		if errors.Is(errPart, io.EOF) {
+			break
+		}
+		if errPart != nil {
+			discard()
+			h.WriteErrorResponse(c, invalidOpenAIRequestWithDetailf("file", "invalid_request", "Invalid multipart body: %v", errPart))
+			return
+		}
+		switch part.FormName() {
+		case "purpose":
+			value, _ := io.ReadAll(io.LimitReader(part, openAIFilesMaxPurposeLen))
+			purpose = strings.TrimSpace(string(value))
+		case "file":
+			if stored != nil {
+				break
+			}
+			meta := filestore.Meta{
+				ID:       filestore.NewID("file-"),
+				Owner:    owner,
+				Name:     part.FileName(),
+				MIMEType: openAIFileMIMEType(part.Header.Get("Content-Type"), part.FileName()),
+				Purpose:  purpose,
+			}
+			saved, errPut := settings.store.Put(meta, part, settings.limits)
+			if errPut != nil {
+				_ = part.Close()
+				h.WriteErrorResponse(c, openAIFileStoreError(errPut, meta.ID, "file"))
+				return
+			}
+			stored = &saved
+		}
+		_ = part.Close()
+	}
+
+	if stored == nil {
+		h.WriteErrorResponse(c, missingOpenAIRequiredParameter("file"))
+		return
+	}
+	if purpose == "" {
+		discard()
+		h.WriteErrorResponse(c, missingOpenAIRequiredParameter("purpose"))
+		return
+	}
+	if _, valid := openAIFilePurposes[purpose]; !valid {
+		discard()
+		h.WriteErrorResponse(c, invalidOpenAIValue("purpose", "Invalid value for 'purpose': %q is not a supported file purpose.", purpose))
+		return
+	}
+	if stored.Purpose != purpose {
+		updated, errUpdate := settings.store.Update(owner, stored.ID, func(meta *filestore.Meta) { meta.Purpose = purpose })
+		if errUpdate != nil {
+			discard()
+			h.WriteErrorResponse(c, openAIFileStoreError(errUpdate, stored.ID, "file"))
+			return
+		}
+		stored = &updated
+	}
+	c.JSON(http.StatusOK, openAIFileObject(*stored))
+}
+
+// ListFiles implements GET /v1/files with the purpose, limit, order and after parameters.
+func (h *OpenAIAPIHandler) ListFiles(c *gin.Context) {
+	settings, ok := h.openAIFilesFor(c)
+	if !ok {
+		return
+	}
+	limit := openAIFilesMaxListLimit
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > openAIFilesMaxListLimit {
+			h.WriteErrorResponse(c, invalidOpenAIValue("limit", "Invalid value for 'limit': expected an integer between 1 and %d.", openAIFilesMaxListLimit))
+			return
+		}
+		limit = parsed
+	}
+	order := strings.ToLower(strings.TrimSpace(c.DefaultQuery("order", "desc")))
+	if order != "asc" && order != "desc" {
+		h.WriteErrorResponse(c, invalidOpenAIValue("order", "Invalid value for 'order': expected 'asc' or 'desc'."))
+		return
+	}
+	purpose := strings.TrimSpace(c.Query("purpose"))
+	after := strings.TrimSpace(c.Query("after"))
+
+	files := settings.store.List(openAIFilesOwner(c))
+	if order == "desc" {
+		for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+			files[i], files[j] = files[j], files[i]
+		}
+	}
+	data := make([]map[string]any, 0)
+	seenCursor := after == ""
+	hasMore := false
+	for _, meta := range files {
+		if !seenCursor {
+			seenCursor = meta.ID == after
+			continue
+		}
+		if purpose != "" && meta.Purpose != purpose {
+			continue
+		}
+		if len(data) == limit {
+			hasMore = true
+			break
+		}
+		data = append(data, openAIFileObject(meta))
+	}
+
+	body := map[string]any{"object": "list", "data": data, "has_more": hasMore}
+	if len(data) > 0 {
+		body["first_id"] = data[0]["id"]
+		body["last_id"] = data[len(data)-1]["id"]
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// GetFile implements GET /v1/files/{file_id}.
+func (h *OpenAIAPIHandler) GetFile(c *gin.Context) {
+	settings, ok := h.openAIFilesFor(c)
+	if !ok {
+		return
+	}
+	fileID := c.Param("file_id")
+	meta, err := settings.store.Get(openAIFilesOwner(c), fileID)
+	if err != nil {
+		h.WriteErrorResponse(c, openAIFileStoreError(err, fileID, "file_id"))
+		return
+	}
+	c.JSON(http.StatusOK, openAIFileObject(meta))
+}
+
+// GetFileContent implements GET /v1/files/{file_id}/content.
+func (h *OpenAIAPIHandler) GetFileContent(c *gin.Context) {
+	settings, ok := h.openAIFilesFor(c)
+	if !ok {
+		return
+	}
+	fileID := c.Param("file_id")
+	meta, data, err := settings.store.ReadAll(openAIFilesOwner(c), fileID)
+	if err != nil {
+		h.WriteErrorResponse(c, openAIFileStoreError(err, fileID, "file_id"))
+		return
+	}
+	if meta.Name != "" {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": meta.Name}))
+	}
+	c.Data(http.StatusOK, openAIFileContentType(meta.MIMEType), data)
+}
+
+// DeleteFile implements DELETE /v1/files/{file_id}.
+func (h *OpenAIAPIHandler) DeleteFile(c *gin.Context) {
+	settings, ok := h.openAIFilesFor(c)
+	if !ok {
+		return
+	}
+	fileID := c.Param("file_id")
+	if err := settings.store.Delete(openAIFilesOwner(c), fileID); err != nil {
+		h.WriteErrorResponse(c, openAIFileStoreError(err, fileID, "file_id"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": fileID, "object": "file", "deleted": true})
+}
+
+func openAIFileContentType(mimeType string) string {
+	if strings.TrimSpace(mimeType) == "" {
+		return "application/octet-stream"
+	}
+	return mimeType
+}
+
+// resolveOpenAIFileReferences replaces file_id references in chat `file` parts and Responses
+// input_file/input_image parts with the stored content of the caller's files: text files become
+// text parts, images become data URLs and anything else becomes inline base64 file_data, so every
+// upstream translator receives the attachment without needing access to the local store. Requests
+// are returned unchanged when the Files API is disabled.
+func resolveOpenAIFileReferences(cfg *sdkconfig.SDKConfig, c *gin.Context, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	if !bytes.Contains(rawJSON, []byte(`"file_id"`)) {
+		return rawJSON, nil
+	}
+	settings, enabled, err := openAIFiles(cfg)
+	if !enabled {
+		return rawJSON, nil
+	}
+	if err != nil {
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+	}
+
+	owner := openAIFilesOwner(c)
+	out := rawJSON
+	for _, root := range []string{"messages", "input"} {
+		items := gjson.GetBytes(rawJSON, root)
+		if !items.IsArray() {
+			continue
+		}
+		for i, item := range items.Array() {
+			content := item.Get("content")
+			if !content.IsArray() {
+				continue
+			}
+			for j, part := range content.Array() {
+				fileID, idPath := openAIContentPartFileID(part)
+				if fileID == "" {
+					continue
+				}
+				meta, data, errRead := settings.store.ReadAll(owner, fileID)
+				if errRead != nil {
+					return nil, openAIFileStoreError(errRead, fileID, fmt.Sprintf("%s[%d].content[%d].%s", root, i, j, idPath))
+				}
+				resolved, errMarshal := json.Marshal(openAIResolvedFilePart(part, meta, data))
+				if errMarshal != nil {
+					return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: errMarshal}
+				}
+				if out, err = sjson.SetRawBytes(out, fmt.Sprintf("%s.%d.content.%d", root, i, j), resolved); err != nil {
+					return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// openAIContentPartFileID returns the file_id a content part references and its path in the part.
+func openAIContentPartFileID(part gjson.Result) (string, string) {
+	switch part.Get("type").String() {
+	case "input_file", "input_image":
+		return strings.TrimSpace(part.Get("file_id").String()), "file_id"
+	case "file":
+		return strings.TrimSpace(part.Get("file.file_id").String()), "file.file_id"
+	}
+	return "", ""
+}
+
+// openAIResolvedFilePart builds the inline replacement for a content part, keeping the chat or
+// Responses shape of the original part.
+func openAIResolvedFilePart(part gjson.Result, meta filestore.Meta, data []byte) map[string]any {
+	partType := part.Get("type").String()
+	chat := partType == "file"
+	mimeType := openAIFileContentType(meta.MIMEType)
+	dataURL := "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+
+	switch {
+	case partType == "input_image" || strings.HasPrefix(mimeType, "image/"):
+		if chat {
+			return map[string]any{"type": "image_url", "image_url": map[string]any{"url": dataURL}}
+		}
+		resolved := map[string]any{"type": "input_image", "image_url": dataURL}
+		if detail := part.Get("detail"); detail.Exists() {
+			resolved["detail"] = detail.Value()
+		}
+		return resolved
+	case openAIFileIsText(meta.MIMEType, data):
+		if chat {
+			return map[string]any{"type": "text", "text": string(data)}
+		}
+		return map[string]any{"type": "input_text", "text": string(data)}
+	default:
+		if chat {
+			return map[string]any{"type": "file", "file": map[string]any{"filename": meta.Name, "file_data": dataURL}}
+		}
+		return map[string]any{"type": "input_file", "filename": meta.Name, "file_data": dataURL}
+	}
+}
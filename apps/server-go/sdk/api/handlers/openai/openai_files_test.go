@@ -0,0 +1,173 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func newOpenAIFilesTestRouter(t *testing.T, files sdkconfig.FilesConfig) (*OpenAIAPIHandler, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	files.Enabled = true
+	files.Dir = t.TempDir()
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{Files: files}, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("apiKey", c.GetHeader("Authorization")) })
+	router.POST("/v1/files", h.UploadFile)
+	router.GET("/v1/files", h.ListFiles)
+	router.GET("/v1/files/:file_id", h.GetFile)
+	router.GET("/v1/files/:file_id/content", h.GetFileContent)
+	router.DELETE("/v1/files/:file_id", h.DeleteFile)
+	return h, router
+}
+
+func uploadOpenAIFile(t *testing.T, router *gin.Engine, key, filename, contentType, purpose string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if purpose != "" {
+		_ = writer.WriteField("purpose", purpose)
+	}
+	header := make(map[string][]string)
+	header["Content-Disposition"] = []string{`form-data; name="file"; filename="` + filename + `"`}
+	header["Content-Type"] = []string{contentType}
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("CreatePart() error = %v", err)
+	}
+	_, _ = part.Write(content)
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/files", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", key)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func serveOpenAIFiles(router *gin.Engine, method, target, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", key)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestOpenAIFilesLifecycleIsScopedPerKey(t *testing.T) {
+	_, router := newOpenAIFilesTestRouter(t, sdkconfig.FilesConfig{})
+
+	uploaded := uploadOpenAIFile(t, router, "key-a", "notes.md", "application/octet-stream", "user_data", []byte("# Notes"))
+	fileID := gjson.Get(uploaded.Body.String(), "id").String()
+	if uploaded.Code != http.StatusOK || gjson.Get(uploaded.Body.String(), "bytes").Int() != 7 || gjson.Get(uploaded.Body.String(), "purpose").String() != "user_data" {
+		t.Fatalf("upload status = %d body = %s", uploaded.Code, uploaded.Body.String())
+	}
+	if gjson.Get(uploaded.Body.String(), "expires_at").Int() == 0 {
+		t.Fatalf("upload without default ttl: %s", uploaded.Body.String())
+	}
+	second := uploadOpenAIFile(t, router, "key-a", "photo.png", "image/png", "vision", []byte("png"))
+	secondID := gjson.Get(second.Body.String(), "id").String()
+
+	list := serveOpenAIFiles(router, http.MethodGet, "/v1/files?limit=1", "key-a")
+	if got := gjson.Get(list.Body.String(), "data.0.id").String(); got != secondID || !gjson.Get(list.Body.String(), "has_more").Bool() {
+		t.Fatalf("list body = %s", list.Body.String())
+	}
+	if filtered := serveOpenAIFiles(router, http.MethodGet, "/v1/files?purpose=user_data", "key-a"); gjson.Get(filtered.Body.String(), "data.#").Int() != 1 {
+		t.Fatalf("purpose filter body = %s", filtered.Body.String())
+	}
+	if other := serveOpenAIFiles(router, http.MethodGet, "/v1/files", "key-b"); gjson.Get(other.Body.String(), "data.#").Int() != 0 {
+		t.Fatalf("other key list body = %s", other.Body.String())
+	}
+	if other := serveOpenAIFiles(router, http.MethodGet, "/v1/files/"+fileID, "key-b"); other.Code != http.StatusNotFound {
+		t.Fatalf("other key GET status = %d, want 404", other.Code)
+	}
+
+	content := serveOpenAIFiles(router, http.MethodGet, "/v1/files/"+fileID+"/content", "key-a")
+	if content.Code != http.StatusOK || content.Body.String() != "# Notes" {
+		t.Fatalf("content status = %d body = %q", content.Code, content.Body.String())
+	}
+	deleted := serveOpenAIFiles(router, http.MethodDelete, "/v1/files/"+fileID, "key-a")
+	if deleted.Code != http.StatusOK || !gjson.Get(deleted.Body.String(), "deleted").Bool() {
+		t.Fatalf("delete status = %d body = %s", deleted.Code, deleted.Body.String())
+	}
+	if got := serveOpenAIFiles(router, http.MethodGet, "/v1/files/"+fileID, "key-a"); got.Code != http.StatusNotFound {
+		t.Fatalf("GET after delete status = %d, want 404", got.Code)
+	}
+}
+
+func TestOpenAIFilesUploadLimits(t *testing.T) {
+	_, router := newOpenAIFilesTestRouter(t, sdkconfig.FilesConfig{MaxFileMB: 1, QuotaMB: 1})
+	half := bytes.Repeat([]byte("a"), 600<<10)
+
+	if resp := uploadOpenAIFile(t, router, "key-a", "a.txt", "text/plain", "", half); resp.Code != http.StatusBadRequest {
+		t.Fatalf("missing purpose status = %d body = %s", resp.Code, resp.Body.String())
+	}
+	if resp := uploadOpenAIFile(t, router, "key-a", "a.txt", "text/plain", "user_data", bytes.Repeat([]byte("a"), 2<<20)); resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized status = %d body = %s", resp.Code, resp.Body.String())
+	}
+	if resp := uploadOpenAIFile(t, router, "key-a", "a.txt", "text/plain", "user_data", half); resp.Code != http.StatusOK {
+		t.Fatalf("first upload status = %d body = %s", resp.Code, resp.Body.String())
+	}
+	if resp := uploadOpenAIFile(t, router, "key-a", "b.txt", "text/plain", "user_data", half); resp.Code != http.StatusForbidden {
+		t.Fatalf("over-quota status = %d body = %s", resp.Code, resp.Body.String())
+	}
+	if resp := uploadOpenAIFile(t, router, "key-b", "b.txt", "text/plain", "user_data", half); resp.Code != http.StatusOK {
+		t.Fatalf("other key upload status = %d body = %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestResolveOpenAIFileReferences(t *testing.T) {
+	h, router := newOpenAIFilesTestRouter(t, sdkconfig.FilesConfig{})
+	textID := gjson.Get(uploadOpenAIFile(t, router, "key-a", "notes.txt", "text/plain", "user_data", []byte("hello notes")).Body.String(), "id").String()
+	pdfID := gjson.Get(uploadOpenAIFile(t, router, "key-a", "report.pdf", "application/octet-stream", "user_data", []byte("%PDF-1.7")).Body.String(), "id").String()
+	imageID := gjson.Get(uploadOpenAIFile(t, router, "key-a", "photo.png", "image/png", "vision", []byte("png")).Body.String(), "id").String()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	c.Set("apiKey", "key-a")
+
+	responses := []byte(`{"model":"gemini-2.5-pro","input":[{"role":"user","content":[
+		{"type":"input_file","file_id":"` + textID + `"},
+		{"type":"input_file","file_id":"` + pdfID + `"},
+		{"type":"input_image","file_id":"` + imageID + `","detail":"low"}]}]}`)
+	resolved, errMsg := resolveOpenAIFileReferences(h.Cfg, c, responses)
+	if errMsg != nil {
+		t.Fatalf("resolve error = %v", errMsg.Error)
+	}
+	parts := gjson.GetBytes(resolved, "input.0.content")
+	if parts.Get("0.type").String() != "input_text" || parts.Get("0.text").String() != "hello notes" {
+		t.Fatalf("text part = %s", parts.Get("0").Raw)
+	}
+	if got := parts.Get("1.file_data").String(); got != "data:application/pdf;base64,"+base64.StdEncoding.EncodeToString([]byte("%PDF-1.7")) || parts.Get("1.filename").String() != "report.pdf" {
+		t.Fatalf("pdf part = %s", parts.Get("1").Raw)
+	}
+	if parts.Get("2.image_url").String() != "data:image/png;base64,"+base64.StdEncoding.EncodeToString([]byte("png")) || parts.Get("2.detail").String() != "low" || parts.Get("2.file_id").Exists() {
+		t.Fatalf("image part = %s", parts.Get("2").Raw)
+	}
+
+	chat := []byte(`{"messages":[{"role":"user","content":[{"type":"file","file":{"file_id":"` + pdfID + `"}},{"type":"file","file":{"file_id":"` + textID + `"}}]}]}`)
+	resolved, errMsg = resolveOpenAIFileReferences(h.Cfg, c, chat)
+	if errMsg != nil {
+		t.Fatalf("chat resolve error = %v", errMsg.Error)
+	}
+	if got := gjson.GetBytes(resolved, "messages.0.content.0.file.file_data").String(); got == "" || gjson.GetBytes(resolved, "messages.0.content.0.file.file_id").Exists() {
+		t.Fatalf("chat file part = %s", resolved)
+	}
+	if gjson.GetBytes(resolved, "messages.0.content.1.type").String() != "text" {
+		t.Fatalf("chat text part = %s", resolved)
+	}
+
+	c.Set("apiKey", "key-b")
+	_, errMsg = resolveOpenAIFileReferences(h.Cfg, c, chat)
+	if errMsg == nil || errMsg.StatusCode != http.StatusNotFound || gjson.Get(errMsg.Error.Error(), "error.param").String() != "messages[0].content[0].file.file_id" {
+		t.Fatalf("foreign file error = %+v", errMsg)
+	}
+}
@@ -33,7 +33,7 @@ func TestHandleStreamResult_UsesPermissionErrorBodyForAuggieSuspension(t *testin
 	errs <- &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: errors.New("account suspended")}
 	close(errs)
 
-	h.handleStreamResult(c, flusher, func(error) {}, data, errs)
+	h.handleStreamResult(c, flusher, func(error) {}, nil, data, errs)
 	body := recorder.Body.String()
 	if !strings.Contains(body, `data: {"error":`) {
 		t.Fatalf("expected chat completions SSE error body, got: %q", body)
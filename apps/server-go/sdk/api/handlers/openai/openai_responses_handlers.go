@@ -77,6 +77,14 @@ func (h *OpenAIResponsesAPIHandler) OpenAIResponsesModels(c *gin.Context) {
 func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
 	requestCtx := ensureGinRequestContext(c)
 
+	// A reconnect to a resumable stream replays its buffered events instead of starting a new request.
+	if resumed, errMsg := h.ResumeStream(c); resumed {
+		if errMsg != nil {
+			h.WriteErrorResponse(c, errMsg)
+		}
+		return
+	}
+
 	rawJSON, err := c.GetRawData()
 	// If data retrieval fails, return a 400 Bad Request error.
 	if err != nil {
@@ -88,6 +96,12 @@ func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
 		})
 		return
 	}
+	// Attachments uploaded through /v1/files are inlined before validation and translation.
+	rawJSON, fileErr := resolveOpenAIFileReferences(h.Cfg, c, rawJSON)
+	if fileErr != nil {
+		h.WriteErrorResponse(c, fileErr)
+		return
+	}
 
 	if errMsg := validateOpenAISurfaceModel(gjson.GetBytes(rawJSON, "model").String()); errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
@@ -98,6 +112,19 @@ func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
 		h.WriteErrorResponse(c, detailsErr)
 		return
 	}
+	// Denied tool types are checked on the request as sent, before hosted tools become functions.
+	if errMsg := h.CheckPolicyTools(requestCtx, h.HandlerType(), normalizedModel, rawJSON); errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		return
+	}
+	// Hosted tools the proxy executes itself are resolved first so the validations below see the
+	// function tools and function_call items they are rewritten into.
+	hostedTools, rawJSON, hostedErr := h.prepareOpenAIResponsesHostedTools(requestCtx, rawJSON, normalizedModel, providers)
+	if hostedErr != nil {
+		h.WriteErrorResponse(c, hostedErr)
+		return
+	}
+	defer hostedTools.Close()
 	if errMsg := validateOpenAIStoreSupport(rawJSON, "responses"); errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		return
@@ -166,11 +193,13 @@ func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
 		h.WriteErrorResponse(c, errMsg)
 		return
 	}
-	if errMsg := validateOpenAIResponsesProviderRequestFeatureSupport(rawJSON, normalizedModel, providers); errMsg != nil {
+	rawJSON, errMsg := h.ApplyParameterPolicy(requestCtx, normalizedModel, rawJSON, func(payload []byte) *interfaces.ErrorMessage {
+		return validateOpenAIResponsesProviderRequestFeatureSupport(withoutAuggieEmulatedFields(h.Cfg, payload), normalizedModel, providers)
+	})
+	if errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		return
 	}
-	var errMsg *interfaces.ErrorMessage
 	conversationCtx := (*openAIConversationExecutionContext)(nil)
 	if rawJSON, conversationCtx, errMsg = prepareOpenAIResponsesConversationRequest(rawJSON); errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
@@ -180,7 +209,9 @@ func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
 	// Check if the client requested a streaming response.
 	backgroundRequested := gjson.GetBytes(rawJSON, "background").Bool()
 	streamResult := gjson.GetBytes(rawJSON, "stream")
-	if backgroundRequested {
+	if hostedTools != nil {
+		h.handleHostedToolResponse(c, rawJSON, conversationCtx, hostedTools)
+	} else if backgroundRequested {
 		h.handleBackgroundResponse(c, rawJSON, conversationCtx, providers, normalizedModel)
 	} else if streamResult.Type == gjson.True {
 		h.handleStreamingResponse(c, rawJSON, conversationCtx)
@@ -197,14 +228,26 @@ func (h *OpenAIResponsesAPIHandler) GetResponse(c *gin.Context) {
 	}
 
 	responseID := c.Param("response_id")
+	stream := strings.EqualFold(strings.TrimSpace(c.Query("stream")), "true")
+	startingAfter, _ := storedOpenAIResponseStartingAfter(c)
+	// A response that is still streaming to a dropped client is resumed from its live buffer.
+	if stream {
+		if resumed, errMsg := h.ResumeStreamByKey(c, responseID, startingAfter); resumed {
+			if errMsg != nil {
+				h.WriteErrorResponse(c, errMsg)
+			}
+			return
+		}
+	}
+
 	stored, ok := defaultStoredOpenAIResponseStore.Load(responseID)
 	if !ok {
 		writeStoredOpenAIResponseNotFound(c, responseID)
 		return
 	}
 
-	if strings.EqualFold(strings.TrimSpace(c.Query("stream")), "true") {
-		h.writeStoredOpenAIResponseReplay(c, stored)
+	if stream {
+		h.writeStoredOpenAIResponseReplay(c, stored, startingAfter)
 		return
 	}
 
@@ -301,6 +344,11 @@ func (h *OpenAIResponsesAPIHandler) InputTokens(c *gin.Context) {
 		})
 		return
 	}
+	rawJSON, fileErr := resolveOpenAIFileReferences(h.Cfg, c, rawJSON)
+	if fileErr != nil {
+		h.WriteErrorResponse(c, fileErr)
+		return
+	}
 
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 	if errMsg := validateOpenAISurfaceModel(modelName); errMsg != nil {
@@ -376,7 +424,10 @@ func (h *OpenAIResponsesAPIHandler) InputTokens(c *gin.Context) {
 		h.WriteErrorResponse(c, errMsg)
 		return
 	}
-	if errMsg := validateOpenAIResponsesProviderRequestFeatureSupport(rawJSON, normalizedModel, providers); errMsg != nil {
+	rawJSON, errMsg := h.ApplyParameterPolicy(requestCtx, normalizedModel, rawJSON, func(payload []byte) *interfaces.ErrorMessage {
+		return validateOpenAIResponsesProviderRequestFeatureSupport(withoutAuggieEmulatedFields(h.Cfg, payload), normalizedModel, providers)
+	})
+	if errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		return
 	}
@@ -591,6 +642,12 @@ func (h *OpenAIResponsesAPIHandler) Compact(c *gin.Context) {
 		})
 		return
 	}
+	// Attachments uploaded through /v1/files are inlined before validation and translation.
+	rawJSON, fileErr := resolveOpenAIFileReferences(h.Cfg, c, rawJSON)
+	if fileErr != nil {
+		h.WriteErrorResponse(c, fileErr)
+		return
+	}
 
 	if errMsg := validateOpenAISurfaceModel(gjson.GetBytes(rawJSON, "model").String()); errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
@@ -661,11 +718,13 @@ func (h *OpenAIResponsesAPIHandler) Compact(c *gin.Context) {
 		h.WriteErrorResponse(c, errMsg)
 		return
 	}
-	if errMsg := validateOpenAIResponsesProviderRequestFeatureSupport(rawJSON, normalizedModel, providers); errMsg != nil {
+	rawJSON, errMsg := h.ApplyParameterPolicy(requestCtx, normalizedModel, rawJSON, func(payload []byte) *interfaces.ErrorMessage {
+		return validateOpenAIResponsesProviderRequestFeatureSupport(withoutAuggieEmulatedFields(h.Cfg, payload), normalizedModel, providers)
+	})
+	if errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		return
 	}
-	var errMsg *interfaces.ErrorMessage
 	conversationCtx := (*openAIConversationExecutionContext)(nil)
 	if rawJSON, conversationCtx, errMsg = prepareOpenAIResponsesConversationRequest(rawJSON); errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
@@ -888,6 +947,7 @@ func (h *OpenAIResponsesAPIHandler) handleStreamingResponse(c *gin.Context, rawJ
 
 	// New core execution path
 	modelName := gjson.GetBytes(rawJSON, "model").String()
+	h.BeginResumableStream(c)
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	dataChan, upstreamHeaders, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
 
@@ -932,32 +992,27 @@ func (h *OpenAIResponsesAPIHandler) handleStreamingResponse(c *gin.Context, rawJ
 			// Success! Set headers.
 			setSSEHeaders()
 			handlers.WriteUpstreamHeaders(c.Writer.Header(), upstreamHeaders)
-			chunk = attachOpenAIConversationToStreamChunk(conversationCtx, chunk)
-			maybeStoreOpenAIResponseFromStreamChunk(rawJSON, chunk)
-			maybeStoreOpenAIConversationResponseFromStreamChunk(conversationCtx, chunk)
 
-			// Write first chunk logic (matching forwardResponsesStream)
-			if bytes.HasPrefix(chunk, []byte("event:")) {
-				_, _ = c.Writer.Write([]byte("\n"))
-			}
-			_, _ = c.Writer.Write(chunk)
-			_, _ = c.Writer.Write([]byte("\n"))
-			flusher.Flush()
-
-			// Continue
-			h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, rawJSON, conversationCtx, dataChan, errChan)
+			// Stream the first chunk and the rest
+			h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, rawJSON, conversationCtx, chunk, dataChan, errChan)
 			return
 		}
 	}
 }
 
-func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), rawRequestJSON []byte, conversationCtx *openAIConversationExecutionContext, data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), rawRequestJSON []byte, conversationCtx *openAIConversationExecutionContext, first []byte, data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
 	lastSequenceNumber := 0
 	hasSequenceNumber := false
+	resumable := handlers.ResumableStreamFromContext(c)
 
 	h.ForwardStream(c, flusher, cancel, data, errs, handlers.StreamForwardOptions{
+		FirstChunk: first,
 		WriteChunk: func(chunk []byte) {
 			for _, payload := range websocketJSONPayloadsFromChunk(chunk) {
+				// The response id lets GET /v1/responses/{id}?stream=true resume this stream.
+				if resumable != nil {
+					resumable.Alias(gjson.GetBytes(payload, "response.id").String())
+				}
 				seq := gjson.GetBytes(payload, "sequence_number")
 				if !seq.Exists() {
 					continue
@@ -1002,7 +1057,8 @@ func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flush
 	})
 }
 
-func (h *OpenAIResponsesAPIHandler) writeStoredOpenAIResponseReplay(c *gin.Context, stored storedOpenAIResponse) {
+// writeStoredOpenAIResponseReplay streams the stored events numbered after startingAfter.
+func (h *OpenAIResponsesAPIHandler) writeStoredOpenAIResponseReplay(c *gin.Context, stored storedOpenAIResponse, startingAfter int64) {
 	events := storedOpenAIResponseReplayEvents(stored)
 	if len(events) == 0 {
 		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
@@ -1020,7 +1076,12 @@ func (h *OpenAIResponsesAPIHandler) writeStoredOpenAIResponseReplay(c *gin.Conte
 	c.Header("Access-Control-Allow-Origin", "*")
 
 	flusher, _ := c.Writer.(http.Flusher)
-	for _, event := range events {
+	for i, event := range events {
+		eventID := int64(i + 1)
+		if eventID <= startingAfter {
+			continue
+		}
+		_, _ = fmt.Fprintf(c.Writer, "id: %d\n", eventID)
 		if eventType := strings.TrimSpace(gjson.GetBytes(event, "type").String()); eventType != "" {
 			_, _ = fmt.Fprintf(c.Writer, "event: %s\n", eventType)
 		}
@@ -33,7 +33,7 @@ func TestForwardResponsesStreamTerminalErrorUsesResponsesErrorChunk(t *testing.T
 	errs <- &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: errors.New("unexpected EOF")}
 	close(errs)
 
-	h.forwardResponsesStream(c, flusher, func(error) {}, nil, nil, data, errs)
+	h.forwardResponsesStream(c, flusher, func(error) {}, nil, nil, nil, data, errs)
 	body := recorder.Body.String()
 	if !strings.Contains(body, `"type":"error"`) {
 		t.Fatalf("expected responses error chunk, got: %q", body)
@@ -62,7 +62,7 @@ func TestForwardResponsesStreamTerminalError_UsesPermissionErrorForAuggieSuspens
 	errs <- &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: errors.New("account suspended")}
 	close(errs)
 
-	h.forwardResponsesStream(c, flusher, func(error) {}, nil, nil, data, errs)
+	h.forwardResponsesStream(c, flusher, func(error) {}, nil, nil, nil, data, errs)
 	body := recorder.Body.String()
 	if !strings.Contains(body, "event: error") {
 		t.Fatalf("expected SSE error event, got: %q", body)
@@ -110,7 +110,7 @@ func TestForwardResponsesStreamTerminalError_UsesNextSequenceNumberAfterPriorChu
 		close(data)
 	}()
 
-	h.forwardResponsesStream(c, flusher, func(error) {}, nil, nil, data, errs)
+	h.forwardResponsesStream(c, flusher, func(error) {}, nil, nil, nil, data, errs)
 	payloads := websocketJSONPayloadsFromChunk(recorder.Body.Bytes())
 	if len(payloads) != 2 {
 		t.Fatalf("payload count = %d, want 2; body=%q", len(payloads), recorder.Body.String())
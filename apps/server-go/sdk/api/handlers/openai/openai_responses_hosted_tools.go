@@ -0,0 +1,366 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const defaultOpenAIHostedToolMaxTurns = 8
+
+var openAIHostedItemIDCounter uint64
+
+// openAIHostedCallResult is the outcome of one function call executed by the proxy.
+type openAIHostedCallResult struct {
+	// output holds the items reported to the client in place of the function call.
+	output []json.RawMessage
+	// continuation holds the items fed back to the model on the next turn.
+	continuation []json.RawMessage
+	// pending is set when the call waits for the client, for example an MCP approval.
+	pending bool
+}
+
+// openAIHostedToolProvider executes the function calls of hosted tools that the proxy emulates
+// for backends without native support, such as mcp or code_interpreter.
+type openAIHostedToolProvider interface {
+	// handleFunctionCall executes call when it targets one of the provider's functions and
+	// reports false otherwise.
+	handleFunctionCall(ctx context.Context, call gjson.Result) (openAIHostedCallResult, bool)
+	Close()
+}
+
+// openAIHostedToolSession carries the hosted tool state of one /v1/responses request across
+// model turns.
+type openAIHostedToolSession struct {
+	providers []openAIHostedToolProvider
+	maxTurns  int
+	// leadingItems are output items produced before the first model turn, such as MCP tool
+	// listings and calls the client approved in this request.
+	leadingItems []json.RawMessage
+}
+
+// Close releases every provider of the session. It is safe to call on a nil session.
+func (s *openAIHostedToolSession) Close() {
+	if s == nil {
+		return
+	}
+	for _, provider := range s.providers {
+		provider.Close()
+	}
+}
+
+// prepareOpenAIResponsesHostedTools rewrites the hosted tools the proxy executes itself into
+// function tools. It returns a nil session when the request uses none of them, in which case the
+// request is forwarded unchanged.
+func (h *OpenAIResponsesAPIHandler) prepareOpenAIResponsesHostedTools(ctx context.Context, rawJSON []byte, modelID string, providers []string) (*openAIHostedToolSession, []byte, *interfaces.ErrorMessage) {
+	if h.Cfg == nil || openAIResponsesRouteSupportsNativeInputItems(modelID, providers) {
+		return nil, rawJSON, nil
+	}
+	session := &openAIHostedToolSession{maxTurns: defaultOpenAIHostedToolMaxTurns}
+	for _, limit := range []int{h.Cfg.MCP.MaxTurns, h.Cfg.Sandbox.MaxTurns} {
+		if limit > session.maxTurns {
+			session.maxTurns = limit
+		}
+	}
+
+	mcpSession, rawJSON, errMsg := h.prepareOpenAIResponsesMCPRequest(ctx, rawJSON)
+	if errMsg != nil {
+		return nil, nil, errMsg
+	}
+	if mcpSession != nil {
+		session.providers = append(session.providers, mcpSession)
+		session.leadingItems = append(session.leadingItems, mcpSession.leadingItems...)
+	}
+	sandboxSession, rawJSON, errMsg := h.prepareOpenAIResponsesSandboxRequest(ctx, rawJSON)
+	if errMsg != nil {
+		session.Close()
+		return nil, nil, errMsg
+	}
+	if sandboxSession != nil {
+		session.providers = append(session.providers, sandboxSession)
+	}
+
+	if len(session.providers) == 0 {
+		return nil, rawJSON, nil
+	}
+	if gjson.GetBytes(rawJSON, "background").Bool() {
+		session.Close()
+		return nil, nil, invalidOpenAIRequestWithDetailf("background", "invalid_value", "background=true is not supported together with proxy-executed tools on the selected model route")
+	}
+	return session, rawJSON, nil
+}
+
+// executeOpenAIResponsesHostedTools runs the model/tool loop: each model turn's calls to hosted
+// functions are executed by the proxy and fed back until the model answers, asks for a client-side
+// function, or a call waits for the client. The final response carries the hosted tool output
+// items in place of the intermediate function calls.
+func (h *OpenAIResponsesAPIHandler) executeOpenAIResponsesHostedTools(ctx context.Context, rawJSON []byte, session *openAIHostedToolSession) ([]byte, http.Header, *interfaces.ErrorMessage) {
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	base, _ := sjson.SetBytes(rawJSON, "stream", false)
+	inputItems := openAIResponsesInputAsItems(base)
+	output := append([]json.RawMessage(nil), session.leadingItems...)
+	var usage openAIHostedToolUsage
+
+	for turn := 0; turn < session.maxTurns; turn++ {
+		request, err := sjson.SetRawBytes(base, "input", mustMarshalRawItems(inputItems))
+		if err != nil {
+			return nil, nil, invalidOpenAIRequestf("failed to build hosted tool continuation: %v", err)
+		}
+		resp, upstreamHeaders, errMsg := h.ExecuteWithAuthManager(ctx, h.HandlerType(), modelName, request, "")
+		if errMsg != nil {
+			return nil, nil, errMsg
+		}
+		usage.add(gjson.GetBytes(resp, "usage"))
+
+		var continuation []json.RawMessage
+		executed, pending, clientCalls := 0, false, false
+		for _, item := range gjson.GetBytes(resp, "output").Array() {
+			itemType := item.Get("type").String()
+			if itemType == "function_call" {
+				if result, handled := session.handleFunctionCall(ctx, item); handled {
+					output = append(output, result.output...)
+					continuation = append(continuation, result.continuation...)
+					if result.pending {
+						pending = true
+					} else {
+						executed++
+					}
+					continue
+				}
+				clientCalls = true
+			}
+			output = append(output, json.RawMessage(item.Raw))
+			if itemType == "message" || itemType == "function_call" {
+				continuation = append(continuation, json.RawMessage(item.Raw))
+			}
+		}
+
+		if executed == 0 || pending || clientCalls {
+			return finalizeOpenAIResponsesHostedTools(resp, output, usage), upstreamHeaders, nil
+		}
+		inputItems = append(inputItems, continuation...)
+	}
+	return nil, nil, &interfaces.ErrorMessage{
+		StatusCode: http.StatusBadGateway,
+		Error:      fmt.Errorf("hosted tool loop exceeded %d model turns", session.maxTurns),
+	}
+}
+
+func (s *openAIHostedToolSession) handleFunctionCall(ctx context.Context, call gjson.Result) (openAIHostedCallResult, bool) {
+	for _, provider := range s.providers {
+		if result, handled := provider.handleFunctionCall(ctx, call); handled {
+			return result, true
+		}
+	}
+	return openAIHostedCallResult{}, false
+}
+
+func finalizeOpenAIResponsesHostedTools(resp []byte, output []json.RawMessage, usage openAIHostedToolUsage) []byte {
+	out, err := sjson.SetRawBytes(resp, "output", mustMarshalRawItems(output))
+	if err != nil {
+		return resp
+	}
+	if usage.seen {
+		out, _ = sjson.SetBytes(out, "usage.input_tokens", usage.input)
+		out, _ = sjson.SetBytes(out, "usage.output_tokens", usage.output)
+		out, _ = sjson.SetBytes(out, "usage.total_tokens", usage.input+usage.output)
+	}
+	return out
+}
+
+// openAIHostedToolUsage sums token usage over the model turns of one tool loop.
+type openAIHostedToolUsage struct {
+	input  int64
+	output int64
+	seen   bool
+}
+
+func (u *openAIHostedToolUsage) add(usage gjson.Result) {
+	if !usage.Exists() {
+		return
+	}
+	u.seen = true
+	u.input += usage.Get("input_tokens").Int()
+	u.output += usage.Get("output_tokens").Int()
+}
+
+// handleHostedToolResponse executes a request whose hosted tools run on the proxy. Streaming
+// requests get the finished response replayed as server-sent events, because tool calls have to
+// complete before the model can continue.
+func (h *OpenAIResponsesAPIHandler) handleHostedToolResponse(c *gin.Context, rawJSON []byte, conversationCtx *openAIConversationExecutionContext, session *openAIHostedToolSession) {
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	stream := gjson.GetBytes(rawJSON, "stream").Bool()
+	stopKeepAlive := func() {}
+	if !stream {
+		c.Header("Content-Type", "application/json")
+		stopKeepAlive = h.StartNonStreamingKeepAlive(c, cliCtx)
+	}
+
+	resp, upstreamHeaders, errMsg := h.executeOpenAIResponsesHostedTools(cliCtx, rawJSON, session)
+	stopKeepAlive()
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	resp = attachOpenAIConversationToResponseBody(conversationCtx, resp)
+	maybeStoreOpenAIResponse(rawJSON, resp)
+	maybeStoreOpenAIConversationResponse(conversationCtx, resp)
+	handlers.WriteUpstreamHeaders(c.Writer.Header(), upstreamHeaders)
+
+	if !stream {
+		_, _ = c.Writer.Write(resp)
+		cliCancel()
+		return
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Access-Control-Allow-Origin", "*")
+	flusher, _ := c.Writer.(http.Flusher)
+	for _, event := range openAIResponsesEventsFromCompleted(resp) {
+		_, _ = fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", gjson.GetBytes(event, "type").String(), event)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	_, _ = fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+	cliCancel()
+}
+
+// openAIResponsesEventsFromCompleted renders a finished response as the event sequence a
+// streaming client expects.
+func openAIResponsesEventsFromCompleted(resp []byte) [][]byte {
+	sequence := 0
+	event := func(payload []byte) []byte {
+		payload, _ = sjson.SetBytes(payload, "sequence_number", sequence)
+		sequence++
+		return payload
+	}
+	inProgress, _ := sjson.SetBytes(resp, "status", "in_progress")
+	inProgress, _ = sjson.SetRawBytes(inProgress, "output", []byte("[]"))
+	inProgress, _ = sjson.SetRawBytes(inProgress, "usage", []byte("null"))
+
+	events := [][]byte{
+		event(marshalStoredOpenAIResponseReplayEvent("response.created", inProgress)),
+		event(marshalStoredOpenAIResponseReplayEvent("response.in_progress", inProgress)),
+	}
+	for index, item := range gjson.GetBytes(resp, "output").Array() {
+		itemID := item.Get("id").String()
+		added := []byte(`{"type":"response.output_item.added"}`)
+		added, _ = sjson.SetBytes(added, "output_index", index)
+		added, _ = sjson.SetRawBytes(added, "item", []byte(item.Raw))
+		events = append(events, event(added))
+
+		switch item.Get("type").String() {
+		case "message":
+			for partIndex, part := range item.Get("content").Array() {
+				if part.Get("type").String() != "output_text" {
+					continue
+				}
+				text := part.Get("text").String()
+				delta := []byte(`{"type":"response.output_text.delta"}`)
+				delta, _ = sjson.SetBytes(delta, "item_id", itemID)
+				delta, _ = sjson.SetBytes(delta, "output_index", index)
+				delta, _ = sjson.SetBytes(delta, "content_index", partIndex)
+				delta, _ = sjson.SetBytes(delta, "delta", text)
+				events = append(events, event(delta))
+				done := []byte(`{"type":"response.output_text.done"}`)
+				done, _ = sjson.SetBytes(done, "item_id", itemID)
+				done, _ = sjson.SetBytes(done, "output_index", index)
+				done, _ = sjson.SetBytes(done, "content_index", partIndex)
+				done, _ = sjson.SetBytes(done, "text", text)
+				events = append(events, event(done))
+			}
+		case "mcp_list_tools":
+			events = append(events, event(marshalOpenAIHostedItemEvent("response.mcp_list_tools.completed", itemID, index)))
+		case "mcp_call":
+			eventType := "response.mcp_call.completed"
+			if item.Get("status").String() == "failed" {
+				eventType = "response.mcp_call.failed"
+			}
+			events = append(events, event(marshalOpenAIHostedItemEvent(eventType, itemID, index)))
+		case "code_interpreter_call":
+			codeDone := marshalOpenAIHostedItemEvent("response.code_interpreter_call_code.done", itemID, index)
+			codeDone, _ = sjson.SetBytes(codeDone, "code", item.Get("code").String())
+			events = append(events,
+				event(codeDone),
+				event(marshalOpenAIHostedItemEvent("response.code_interpreter_call.completed", itemID, index)),
+			)
+		}
+
+		done := []byte(`{"type":"response.output_item.done"}`)
+		done, _ = sjson.SetBytes(done, "output_index", index)
+		done, _ = sjson.SetRawBytes(done, "item", []byte(item.Raw))
+		events = append(events, event(done))
+	}
+	events = append(events, event(marshalStoredOpenAIResponseReplayEvent(openAIResponseTerminalReplayEventType(resp), resp)))
+	return events
+}
+
+func marshalOpenAIHostedItemEvent(eventType, itemID string, outputIndex int) []byte {
+	payload := []byte(`{}`)
+	payload, _ = sjson.SetBytes(payload, "type", eventType)
+	payload, _ = sjson.SetBytes(payload, "item_id", itemID)
+	payload, _ = sjson.SetBytes(payload, "output_index", outputIndex)
+	return payload
+}
+
+func openAIResponsesInputAsItems(rawJSON []byte) []json.RawMessage {
+	input := gjson.GetBytes(rawJSON, "input")
+	if input.Type == gjson.String {
+		item, _ := json.Marshal(map[string]any{"type": "message", "role": "user", "content": input.String()})
+		return []json.RawMessage{item}
+	}
+	items := make([]json.RawMessage, 0, len(input.Array()))
+	for _, item := range input.Array() {
+		items = append(items, json.RawMessage(item.Raw))
+	}
+	return items
+}
+
+func marshalOpenAIFunctionCallItem(callID, name, arguments string) json.RawMessage {
+	raw, _ := json.Marshal(map[string]any{
+		"type":      "function_call",
+		"call_id":   callID,
+		"name":      name,
+		"arguments": arguments,
+	})
+	return raw
+}
+
+func marshalOpenAIFunctionCallOutputItem(callID, output string) json.RawMessage {
+	raw, _ := json.Marshal(map[string]any{
+		"type":    "function_call_output",
+		"call_id": callID,
+		"output":  output,
+	})
+	return raw
+}
+
+func mustMarshalRawItems(items []json.RawMessage) []byte {
+	if len(items) == 0 {
+		return []byte("[]")
+	}
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return []byte("[]")
+	}
+	return raw
+}
+
+func newOpenAIHostedItemID(prefix string) string {
+	return fmt.Sprintf("%s_%x%d", prefix, time.Now().UnixNano(), atomic.AddUint64(&openAIHostedItemIDCounter, 1))
+}
@@ -0,0 +1,565 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mcp"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultOpenAIMCPCallTimeout = 60 * time.Second
+	openAIMCPApprovalTTL        = time.Hour
+	openAIMCPFunctionPrefix     = "mcp__"
+	openAIMCPMaxFunctionName    = 64
+	openAIMCPDeclinedOutput     = "The user declined this tool call."
+)
+
+var (
+	defaultOpenAIMCPStdioPool    = mcp.NewStdioPool()
+	defaultOpenAIMCPApprovals    = &openAIMCPApprovalStore{items: make(map[string]openAIMCPPendingApproval)}
+	openAIMCPFunctionNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+)
+
+// openAIMCPServer is one MCP server referenced by a request's mcp tool.
+type openAIMCPServer struct {
+	label           string
+	client          *mcp.Client
+	pooled          bool
+	requireApproval func(toolName string) bool
+}
+
+// openAIMCPFunction maps a function name exposed to the model back to an MCP tool.
+type openAIMCPFunction struct {
+	server *openAIMCPServer
+	tool   string
+}
+
+// openAIMCPSession carries the MCP state of one /v1/responses request across model turns.
+type openAIMCPSession struct {
+	servers     []*openAIMCPServer
+	functions   map[string]openAIMCPFunction
+	callTimeout time.Duration
+	// leadingItems are output items produced before the first model turn: tool listings and
+	// calls the client approved in this request.
+	leadingItems []json.RawMessage
+}
+
+type openAIMCPPendingApproval struct {
+	serverLabel  string
+	toolName     string
+	functionName string
+	arguments    string
+	callID       string
+	expiresAt    time.Time
+}
+
+// openAIMCPApprovalStore remembers approval requests so an mcp_approval_response can be honored
+// without the client replaying the original request item.
+type openAIMCPApprovalStore struct {
+	mu    sync.Mutex
+	items map[string]openAIMCPPendingApproval
+}
+
+func (s *openAIMCPApprovalStore) Store(id string, pending openAIMCPPendingApproval) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, item := range s.items {
+		if now.After(item.expiresAt) {
+			delete(s.items, key)
+		}
+	}
+	pending.expiresAt = now.Add(openAIMCPApprovalTTL)
+	s.items[id] = pending
+}
+
+func (s *openAIMCPApprovalStore) Take(id string) (openAIMCPPendingApproval, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending, ok := s.items[id]
+	if !ok || time.Now().After(pending.expiresAt) {
+		delete(s.items, id)
+		return openAIMCPPendingApproval{}, false
+	}
+	delete(s.items, id)
+	return pending, true
+}
+
+// openAIResponsesRequestUsesMCP reports whether rawJSON carries mcp tools or mcp input items.
+func openAIResponsesRequestUsesMCP(rawJSON []byte) bool {
+	for _, tool := range gjson.GetBytes(rawJSON, "tools").Array() {
+		if tool.Get("type").String() == "mcp" {
+			return true
+		}
+	}
+	for _, item := range gjson.GetBytes(rawJSON, "input").Array() {
+		if strings.HasPrefix(item.Get("type").String(), "mcp_") {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareOpenAIResponsesMCPRequest connects to the MCP servers named by the request's mcp tools,
+// replaces those tools with function tools and rewrites mcp input items into function calls the
+// backend understands. It returns a nil session when the request does not use MCP or when MCP is
+// disabled.
+func (h *OpenAIResponsesAPIHandler) prepareOpenAIResponsesMCPRequest(ctx context.Context, rawJSON []byte) (*openAIMCPSession, []byte, *interfaces.ErrorMessage) {
+	if !h.Cfg.MCP.Enabled || !openAIResponsesRequestUsesMCP(rawJSON) {
+		return nil, rawJSON, nil
+	}
+
+	session := &openAIMCPSession{
+		functions:   make(map[string]openAIMCPFunction),
+		callTimeout: defaultOpenAIMCPCallTimeout,
+	}
+	if h.Cfg.MCP.CallTimeout > 0 {
+		session.callTimeout = time.Duration(h.Cfg.MCP.CallTimeout) * time.Second
+	}
+
+	listedLabels := make(map[string]bool)
+	for _, item := range gjson.GetBytes(rawJSON, "input").Array() {
+		if item.Get("type").String() == "mcp_list_tools" {
+			listedLabels[item.Get("server_label").String()] = true
+		}
+	}
+
+	tools := make([]json.RawMessage, 0)
+	for i, tool := range gjson.GetBytes(rawJSON, "tools").Array() {
+		if tool.Get("type").String() != "mcp" {
+			tools = append(tools, json.RawMessage(tool.Raw))
+			continue
+		}
+		server, errMsg := h.connectOpenAIMCPServer(tool, fmt.Sprintf("tools[%d]", i))
+		if errMsg != nil {
+			session.Close()
+			return nil, nil, errMsg
+		}
+		session.servers = append(session.servers, server)
+
+		listCtx, cancel := context.WithTimeout(ctx, session.callTimeout)
+		listed, err := server.client.ListTools(listCtx)
+		cancel()
+		if err != nil {
+			session.discard(server)
+			session.Close()
+			return nil, nil, openAIMCPDependencyError(server.label, err)
+		}
+		listed = filterOpenAIMCPAllowedTools(listed, tool.Get("allowed_tools"))
+		for _, listedTool := range listed {
+			name := session.functionName(server.label, listedTool.Name)
+			session.functions[name] = openAIMCPFunction{server: server, tool: listedTool.Name}
+			tools = append(tools, marshalOpenAIMCPFunctionTool(name, listedTool))
+		}
+		if !listedLabels[server.label] {
+			session.leadingItems = append(session.leadingItems, marshalOpenAIMCPListToolsItem(server.label, listed))
+			listedLabels[server.label] = true
+		}
+	}
+
+	out, err := sjson.SetRawBytes(rawJSON, "tools", mustMarshalRawItems(tools))
+	if err != nil {
+		session.Close()
+		return nil, nil, invalidOpenAIRequestf("failed to rewrite mcp tools: %v", err)
+	}
+	if choice := gjson.GetBytes(out, "tool_choice"); choice.IsObject() && choice.Get("type").String() == "mcp" {
+		out, _ = sjson.SetBytes(out, "tool_choice", "auto")
+		if name := choice.Get("name").String(); name != "" {
+			out, _ = sjson.SetRawBytes(out, "tool_choice", []byte(fmt.Sprintf(`{"type":"function","name":%q}`, session.functionName(choice.Get("server_label").String(), name))))
+		}
+	}
+	out, errMsg := session.rewriteInput(ctx, out)
+	if errMsg != nil {
+		session.Close()
+		return nil, nil, errMsg
+	}
+	return session, out, nil
+}
+
+// connectOpenAIMCPServer resolves the server behind an mcp tool definition. Configured servers are
+// matched by label; other server_url values are only used when remote servers are allowed.
+func (h *OpenAIResponsesAPIHandler) connectOpenAIMCPServer(tool gjson.Result, param string) (*openAIMCPServer, *interfaces.ErrorMessage) {
+	label := strings.TrimSpace(tool.Get("server_label").String())
+	if label == "" {
+		return nil, missingOpenAIRequiredParameter(param + ".server_label")
+	}
+	server := &openAIMCPServer{label: label, requireApproval: openAIMCPApprovalPolicy(tool.Get("require_approval"))}
+
+	for _, configured := range h.Cfg.MCP.Servers {
+		if strings.TrimSpace(configured.Label) != label {
+			continue
+		}
+		if command := strings.TrimSpace(configured.Command); command != "" {
+			client, err := defaultOpenAIMCPStdioPool.Get(label, command, configured.Args, configured.Env)
+			if err != nil {
+				return nil, openAIMCPDependencyError(label, err)
+			}
+			server.client = client
+			server.pooled = true
+			return server, nil
+		}
+		headers := make(http.Header, len(configured.Headers))
+		for key, value := range configured.Headers {
+			headers.Set(key, value)
+		}
+		server.client = mcp.NewHTTPClient(strings.TrimSpace(configured.URL), headers, h.openAIMCPHTTPClient())
+		return server, nil
+	}
+
+	serverURL := strings.TrimSpace(tool.Get("server_url").String())
+	if serverURL == "" {
+		return nil, invalidOpenAIRequestWithDetailf(param+".server_label", "invalid_value", "MCP server %q is not configured on this proxy", label)
+	}
+	if !h.Cfg.MCP.AllowRemoteServers {
+		return nil, invalidOpenAIRequestWithDetailf(param+".server_url", "invalid_value", "server_url is not supported on this proxy; use a configured server_label")
+	}
+	if !strings.HasPrefix(serverURL, "https://") && !strings.HasPrefix(serverURL, "http://") {
+		return nil, invalidOpenAIRequestWithDetailf(param+".server_url", "invalid_value", "server_url must be an http or https URL")
+	}
+	headers := make(http.Header)
+	tool.Get("headers").ForEach(func(key, value gjson.Result) bool {
+		headers.Set(key.String(), value.String())
+		return true
+	})
+	if authorization := strings.TrimSpace(tool.Get("authorization").String()); authorization != "" {
+		headers.Set("Authorization", "Bearer "+authorization)
+	}
+	server.client = mcp.NewHTTPClient(serverURL, headers, h.openAIMCPHTTPClient())
+	return server, nil
+}
+
+func (h *OpenAIResponsesAPIHandler) openAIMCPHTTPClient() *http.Client {
+	return util.SetProxy(h.Cfg, &http.Client{})
+}
+
+// openAIMCPApprovalPolicy decodes require_approval. Tools require approval unless the policy says
+// otherwise, matching the OpenAI default.
+func openAIMCPApprovalPolicy(value gjson.Result) func(string) bool {
+	switch {
+	case value.Type == gjson.String && strings.EqualFold(value.String(), "never"):
+		return func(string) bool { return false }
+	case value.IsObject():
+		never := make(map[string]bool)
+		for _, name := range value.Get("never.tool_names").Array() {
+			never[name.String()] = true
+		}
+		return func(tool string) bool { return !never[tool] }
+	default:
+		return func(string) bool { return true }
+	}
+}
+
+// filterOpenAIMCPAllowedTools applies allowed_tools, given either as a list of names or as a
+// filter object with tool_names and read_only.
+func filterOpenAIMCPAllowedTools(tools []mcp.Tool, allowed gjson.Result) []mcp.Tool {
+	if !allowed.Exists() || allowed.Type == gjson.Null {
+		return tools
+	}
+	names := allowed
+	readOnly := false
+	if allowed.IsObject() {
+		names = allowed.Get("tool_names")
+		readOnly = allowed.Get("read_only").Bool()
+	}
+	var allowedNames map[string]bool
+	if names.IsArray() {
+		allowedNames = make(map[string]bool)
+		for _, name := range names.Array() {
+			allowedNames[name.String()] = true
+		}
+	}
+	filtered := make([]mcp.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if allowedNames != nil && !allowedNames[tool.Name] {
+			continue
+		}
+		if readOnly && !tool.ReadOnly() {
+			continue
+		}
+		filtered = append(filtered, tool)
+	}
+	return filtered
+}
+
+// functionName returns the function name the model sees for an MCP tool. Names follow the
+// mcp__<server>__<tool> convention and are kept within the 64 character limit.
+func (s *openAIMCPSession) functionName(label, tool string) string {
+	for name, fn := range s.functions {
+		if fn.server.label == label && fn.tool == tool {
+			return name
+		}
+	}
+	name := openAIMCPFunctionPrefix + openAIMCPFunctionNameInvalid.ReplaceAllString(label, "_") + "__" + openAIMCPFunctionNameInvalid.ReplaceAllString(tool, "_")
+	if len(name) > openAIMCPMaxFunctionName {
+		name = name[:openAIMCPMaxFunctionName]
+	}
+	base := name
+	for i := 2; ; i++ {
+		if _, taken := s.functions[name]; !taken {
+			return name
+		}
+		suffix := fmt.Sprintf("_%d", i)
+		name = base
+		if len(name)+len(suffix) > openAIMCPMaxFunctionName {
+			name = name[:openAIMCPMaxFunctionName-len(suffix)]
+		}
+		name += suffix
+	}
+}
+
+func (s *openAIMCPSession) server(label string) *openAIMCPServer {
+	for _, server := range s.servers {
+		if server.label == label {
+			return server
+		}
+	}
+	return nil
+}
+
+// rewriteInput turns mcp input items into function_call / function_call_output items. Approved
+// calls are executed here and reported as mcp_call output items of this response.
+func (s *openAIMCPSession) rewriteInput(ctx context.Context, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	input := gjson.GetBytes(rawJSON, "input")
+	if !input.IsArray() {
+		return rawJSON, nil
+	}
+	requests := make(map[string]gjson.Result)
+	for _, item := range input.Array() {
+		if item.Get("type").String() == "mcp_approval_request" {
+			requests[item.Get("id").String()] = item
+		}
+	}
+
+	items := make([]json.RawMessage, 0, len(input.Array()))
+	for i, item := range input.Array() {
+		switch item.Get("type").String() {
+		case "mcp_list_tools":
+			continue
+		case "mcp_call":
+			callID := item.Get("id").String()
+			name := s.functionName(item.Get("server_label").String(), item.Get("name").String())
+			output := item.Get("output").String()
+			if errText := item.Get("error").String(); errText != "" && output == "" {
+				output = errText
+			}
+			items = append(items, marshalOpenAIFunctionCallItem(callID, name, item.Get("arguments").String()), marshalOpenAIFunctionCallOutputItem(callID, output))
+		case "mcp_approval_request":
+			callID := item.Get("id").String()
+			if pending, ok := peekOpenAIMCPApproval(callID); ok && pending.callID != "" {
+				callID = pending.callID
+			}
+			name := s.functionName(item.Get("server_label").String(), item.Get("name").String())
+			items = append(items, marshalOpenAIFunctionCallItem(callID, name, item.Get("arguments").String()))
+		case "mcp_approval_response":
+			approvalID := item.Get("approval_request_id").String()
+			pending, ok := defaultOpenAIMCPApprovals.Take(approvalID)
+			if !ok {
+				request, found := requests[approvalID]
+				if !found {
+					return nil, invalidOpenAIRequestWithDetailf(fmt.Sprintf("input[%d].approval_request_id", i), "invalid_value", "unknown MCP approval request %q", approvalID)
+				}
+				pending = openAIMCPPendingApproval{
+					serverLabel: request.Get("server_label").String(),
+					toolName:    request.Get("name").String(),
+					arguments:   request.Get("arguments").String(),
+					callID:      approvalID,
+				}
+				pending.functionName = s.functionName(pending.serverLabel, pending.toolName)
+			}
+			output := openAIMCPDeclinedOutput
+			if item.Get("approve").Bool() {
+				server := s.server(pending.serverLabel)
+				if server == nil {
+					return nil, invalidOpenAIRequestWithDetailf(fmt.Sprintf("input[%d].approval_request_id", i), "invalid_value", "approved MCP call targets server %q, which is not among this request's mcp tools", pending.serverLabel)
+				}
+				callItem, result := s.executeCall(ctx, server, pending.toolName, pending.arguments, approvalID)
+				s.leadingItems = append(s.leadingItems, callItem)
+				output = result
+			}
+			items = append(items, marshalOpenAIFunctionCallOutputItem(pending.callID, output))
+		default:
+			items = append(items, json.RawMessage(item.Raw))
+		}
+	}
+	out, err := sjson.SetRawBytes(rawJSON, "input", mustMarshalRawItems(items))
+	if err != nil {
+		return nil, invalidOpenAIRequestf("failed to rewrite mcp input items: %v", err)
+	}
+	return out, nil
+}
+
+func peekOpenAIMCPApproval(id string) (openAIMCPPendingApproval, bool) {
+	defaultOpenAIMCPApprovals.mu.Lock()
+	defer defaultOpenAIMCPApprovals.mu.Unlock()
+	pending, ok := defaultOpenAIMCPApprovals.items[id]
+	return pending, ok
+}
+
+// executeCall runs one MCP tool call and returns the mcp_call output item together with the text
+// handed back to the model.
+func (s *openAIMCPSession) executeCall(ctx context.Context, server *openAIMCPServer, tool, arguments, approvalID string) (json.RawMessage, string) {
+	callCtx, cancel := context.WithTimeout(ctx, s.callTimeout)
+	result, err := server.client.CallTool(callCtx, tool, json.RawMessage(arguments))
+	cancel()
+
+	item := map[string]any{
+		"id":           newOpenAIHostedItemID("mcp"),
+		"type":         "mcp_call",
+		"server_label": server.label,
+		"name":         tool,
+		"arguments":    arguments,
+		"output":       nil,
+		"error":        nil,
+		"status":       "completed",
+	}
+	if approvalID != "" {
+		item["approval_request_id"] = approvalID
+	}
+	var modelOutput string
+	switch {
+	case err != nil:
+		var rpcErr *mcp.RPCError
+		if !errors.As(err, &rpcErr) {
+			s.discard(server)
+		}
+		log.Warnf("mcp call %s/%s failed: %v", server.label, tool, err)
+		item["error"] = err.Error()
+		item["status"] = "failed"
+		modelOutput = "Error: " + err.Error()
+	case result.IsError:
+		item["error"] = result.Text()
+		item["status"] = "failed"
+		modelOutput = "Error: " + result.Text()
+	default:
+		item["output"] = result.Text()
+		modelOutput = result.Text()
+	}
+	raw, _ := json.Marshal(item)
+	return raw, modelOutput
+}
+
+// handleFunctionCall executes a model call to one of the session's MCP functions, or turns it
+// into an mcp_approval_request when the tool's require_approval policy asks for one.
+func (s *openAIMCPSession) handleFunctionCall(ctx context.Context, call gjson.Result) (openAIHostedCallResult, bool) {
+	fn, ok := s.functions[call.Get("name").String()]
+	if !ok {
+		return openAIHostedCallResult{}, false
+	}
+	callID := call.Get("call_id").String()
+	arguments := call.Get("arguments").String()
+	if fn.server.requireApproval(fn.tool) {
+		approvalID := newOpenAIHostedItemID("mcpr")
+		defaultOpenAIMCPApprovals.Store(approvalID, openAIMCPPendingApproval{
+			serverLabel:  fn.server.label,
+			toolName:     fn.tool,
+			functionName: call.Get("name").String(),
+			arguments:    arguments,
+			callID:       callID,
+		})
+		return openAIHostedCallResult{
+			output:  []json.RawMessage{marshalOpenAIMCPApprovalRequestItem(approvalID, fn.server.label, fn.tool, arguments)},
+			pending: true,
+		}, true
+	}
+	callItem, result := s.executeCall(ctx, fn.server, fn.tool, arguments, "")
+	return openAIHostedCallResult{
+		output:       []json.RawMessage{callItem},
+		continuation: []json.RawMessage{json.RawMessage(call.Raw), marshalOpenAIFunctionCallOutputItem(callID, result)},
+	}, true
+}
+
+// discard drops a pooled stdio server after a transport failure so the next request restarts it.
+func (s *openAIMCPSession) discard(server *openAIMCPServer) {
+	if server != nil && server.pooled {
+		defaultOpenAIMCPStdioPool.Discard(server.label, server.client)
+	}
+}
+
+// Close releases per-request HTTP sessions. Pooled stdio servers stay running.
+func (s *openAIMCPSession) Close() {
+	if s == nil {
+		return
+	}
+	for _, server := range s.servers {
+		if !server.pooled && server.client != nil {
+			_ = server.client.Close()
+		}
+	}
+}
+
+func marshalOpenAIMCPFunctionTool(name string, tool mcp.Tool) json.RawMessage {
+	parameters := tool.InputSchema
+	if len(parameters) == 0 || !gjson.ValidBytes(parameters) {
+		parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	raw, _ := json.Marshal(map[string]any{
+		"type":        "function",
+		"name":        name,
+		"description": tool.Description,
+		"parameters":  parameters,
+		"strict":      false,
+	})
+	return raw
+}
+
+func marshalOpenAIMCPListToolsItem(label string, tools []mcp.Tool) json.RawMessage {
+	listed := make([]map[string]any, 0, len(tools))
+	for _, tool := range tools {
+		entry := map[string]any{"name": tool.Name, "input_schema": tool.InputSchema}
+		if tool.Description != "" {
+			entry["description"] = tool.Description
+		}
+		if len(tool.Annotations) > 0 {
+			entry["annotations"] = tool.Annotations
+		}
+		if len(tool.InputSchema) == 0 {
+			entry["input_schema"] = json.RawMessage(`{}`)
+		}
+		listed = append(listed, entry)
+	}
+	raw, _ := json.Marshal(map[string]any{
+		"id":           newOpenAIHostedItemID("mcpl"),
+		"type":         "mcp_list_tools",
+		"server_label": label,
+		"tools":        listed,
+	})
+	return raw
+}
+
+func marshalOpenAIMCPApprovalRequestItem(id, label, tool, arguments string) json.RawMessage {
+	raw, _ := json.Marshal(map[string]any{
+		"id":           id,
+		"type":         "mcp_approval_request",
+		"server_label": label,
+		"name":         tool,
+		"arguments":    arguments,
+	})
+	return raw
+}
+
+// openAIMCPDependencyError reports an MCP server that could not be reached or listed, using the
+// 424 status OpenAI returns for the same failure.
+func openAIMCPDependencyError(label string, err error) *interfaces.ErrorMessage {
+	body, _ := json.Marshal(map[string]any{
+		"error": map[string]any{
+			"message": fmt.Sprintf("Error retrieving tool list from MCP server: '%s'. %v", label, err),
+			"type":    "external_connector_error",
+			"param":   "tools",
+			"code":    "http_error",
+		},
+	})
+	return &interfaces.ErrorMessage{StatusCode: http.StatusFailedDependency, Error: errors.New(string(body))}
+}
@@ -0,0 +1,233 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// sequencedSurfaceExecutor returns one queued payload per Execute call.
+type sequencedSurfaceExecutor struct {
+	surfaceCaptureExecutor
+	mu       sync.Mutex
+	payloads [][]byte
+	requests [][]byte
+}
+
+func (e *sequencedSurfaceExecutor) Execute(ctx context.Context, auth *coreauth.Auth, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests = append(e.requests, append([]byte(nil), req.Payload...))
+	payload := []byte(`{"ok":true}`)
+	if len(e.payloads) > 0 {
+		payload = e.payloads[0]
+		e.payloads = e.payloads[1:]
+	}
+	return coreexecutor.Response{Payload: payload}, nil
+}
+
+func newFakeMCPServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	calls := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		if len(msg.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var result string
+		switch msg.Method {
+		case "initialize":
+			result = `{"protocolVersion":"2025-06-18","capabilities":{"tools":{}},"serverInfo":{"name":"fake","version":"1"}}`
+		case "tools/list":
+			result = `{"tools":[{"name":"search","description":"Search docs","inputSchema":{"type":"object","properties":{"q":{"type":"string"}}},"annotations":{"readOnlyHint":true}},{"name":"delete","inputSchema":{"type":"object"}}]}`
+		case "tools/call":
+			mu.Lock()
+			calls = append(calls, gjson.GetBytes(msg.Params, "name").String()+":"+gjson.GetBytes(msg.Params, "arguments").Raw)
+			mu.Unlock()
+			result = `{"content":[{"type":"text","text":"found it"}]}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(msg.ID) + `,"result":` + result + `}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newMCPResponsesTestRouter(t *testing.T, mcpURL string, payloads ...string) (*gin.Engine, *sequencedSurfaceExecutor) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	executor := &sequencedSurfaceExecutor{}
+	for _, payload := range payloads {
+		executor.payloads = append(executor.payloads, []byte(payload))
+	}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "mcp-auth-" + t.Name(), Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registerSurfaceModel(t, auth.ID, auth.Provider, &registry.ModelInfo{
+		ID:      "gpt-5-4",
+		Object:  "model",
+		OwnedBy: "auggie",
+		Type:    "auggie",
+		Version: "gpt-5-4",
+	})
+
+	cfg := &sdkconfig.SDKConfig{MCP: sdkconfig.MCPConfig{
+		Enabled: true,
+		Servers: []sdkconfig.MCPServerConfig{{Label: "docs", URL: mcpURL}},
+	}}
+	h := NewOpenAIResponsesAPIHandler(handlers.NewBaseAPIHandlers(cfg, manager))
+	router := gin.New()
+	router.POST("/v1/responses", h.Responses)
+	return router, executor
+}
+
+func postMCPResponses(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestResponses_MCPToolsExecuteServerSide(t *testing.T) {
+	mcpServer, calls := newFakeMCPServer(t)
+	router, executor := newMCPResponsesTestRouter(t, mcpServer.URL,
+		`{"id":"resp_1","object":"response","status":"completed","output":[{"type":"function_call","id":"fc_1","call_id":"call_1","name":"mcp__docs__search","arguments":"{\"q\":\"mcp\"}"}],"usage":{"input_tokens":10,"output_tokens":5,"total_tokens":15}}`,
+		`{"id":"resp_2","object":"response","status":"completed","output":[{"type":"message","id":"msg_1","role":"assistant","content":[{"type":"output_text","text":"done"}]}],"usage":{"input_tokens":20,"output_tokens":3,"total_tokens":23}}`,
+	)
+
+	resp := postMCPResponses(router, `{"model":"gpt-5-4","input":"find mcp docs","tools":[{"type":"mcp","server_label":"docs","require_approval":"never","allowed_tools":["search"]}]}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body=%s", resp.Code, resp.Body.String())
+	}
+	if len(executor.requests) != 2 {
+		t.Fatalf("model turns = %d, want 2", len(executor.requests))
+	}
+	first := executor.requests[0]
+	if got := gjson.GetBytes(first, "tools.#").Int(); got != 1 {
+		t.Fatalf("tools sent upstream = %d, want 1 (allowed_tools filter); payload=%s", got, first)
+	}
+	if got := gjson.GetBytes(first, "tools.0.name").String(); got != "mcp__docs__search" {
+		t.Fatalf("tool name = %q; payload=%s", got, first)
+	}
+	second := executor.requests[1]
+	if got := gjson.GetBytes(second, `input.#(type=="function_call_output").output`).String(); got != "found it" {
+		t.Fatalf("function_call_output = %q; payload=%s", got, second)
+	}
+	if len(*calls) != 1 || (*calls)[0] != `search:{"q":"mcp"}` {
+		t.Fatalf("mcp calls = %v", *calls)
+	}
+
+	body := resp.Body.Bytes()
+	types := make([]string, 0)
+	for _, item := range gjson.GetBytes(body, "output").Array() {
+		types = append(types, item.Get("type").String())
+	}
+	if strings.Join(types, ",") != "mcp_list_tools,mcp_call,message" {
+		t.Fatalf("output types = %v; body=%s", types, body)
+	}
+	if got := gjson.GetBytes(body, "output.1.output").String(); got != "found it" {
+		t.Fatalf("mcp_call output = %q", got)
+	}
+	if got := gjson.GetBytes(body, "usage.total_tokens").Int(); got != 38 {
+		t.Fatalf("usage.total_tokens = %d, want 38", got)
+	}
+}
+
+func TestResponses_MCPApprovalRequestAndResponse(t *testing.T) {
+	mcpServer, calls := newFakeMCPServer(t)
+	router, executor := newMCPResponsesTestRouter(t, mcpServer.URL,
+		`{"id":"resp_1","object":"response","status":"completed","output":[{"type":"function_call","id":"fc_1","call_id":"call_1","name":"mcp__docs__delete","arguments":"{}"}]}`,
+		`{"id":"resp_2","object":"response","status":"completed","output":[{"type":"message","id":"msg_1","role":"assistant","content":[{"type":"output_text","text":"deleted"}]}]}`,
+	)
+
+	resp := postMCPResponses(router, `{"model":"gpt-5-4","input":"delete it","tools":[{"type":"mcp","server_label":"docs","require_approval":{"never":{"tool_names":["search"]}}}]}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body=%s", resp.Code, resp.Body.String())
+	}
+	approval := gjson.GetBytes(resp.Body.Bytes(), `output.#(type=="mcp_approval_request")`)
+	if !approval.Exists() || approval.Get("name").String() != "delete" {
+		t.Fatalf("missing approval request; body=%s", resp.Body.String())
+	}
+	if len(*calls) != 0 {
+		t.Fatalf("tool executed before approval: %v", *calls)
+	}
+
+	followUp := `{"model":"gpt-5-4","tools":[{"type":"mcp","server_label":"docs"}],"input":[` +
+		`{"type":"message","role":"user","content":"delete it"},` +
+		approval.Raw + `,{"type":"mcp_approval_response","approval_request_id":"` + approval.Get("id").String() + `","approve":true}]}`
+	resp = postMCPResponses(router, followUp)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("follow-up status = %d, want 200; body=%s", resp.Code, resp.Body.String())
+	}
+	if len(*calls) != 1 || !strings.HasPrefix((*calls)[0], "delete:") {
+		t.Fatalf("mcp calls = %v", *calls)
+	}
+	upstream := executor.requests[len(executor.requests)-1]
+	if got := gjson.GetBytes(upstream, `input.#(type=="function_call").call_id`).String(); got != "call_1" {
+		t.Fatalf("replayed call_id = %q; payload=%s", got, upstream)
+	}
+	call := gjson.GetBytes(resp.Body.Bytes(), `output.#(type=="mcp_call")`)
+	if call.Get("approval_request_id").String() != approval.Get("id").String() {
+		t.Fatalf("mcp_call approval_request_id = %q; body=%s", call.Get("approval_request_id").String(), resp.Body.String())
+	}
+}
+
+func TestResponses_MCPRejectsUnknownServerLabel(t *testing.T) {
+	mcpServer, _ := newFakeMCPServer(t)
+	router, executor := newMCPResponsesTestRouter(t, mcpServer.URL)
+
+	resp := postMCPResponses(router, `{"model":"gpt-5-4","input":"hi","tools":[{"type":"mcp","server_label":"other","server_url":"https://example.com/mcp"}]}`)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400; body=%s", resp.Code, resp.Body.String())
+	}
+	assertSurfaceOpenAIErrorBody(t, resp.Body.String(), "tools[0].server_url", "invalid_value", "server_url")
+	if len(executor.requests) != 0 {
+		t.Fatalf("model was called %d times", len(executor.requests))
+	}
+}
+
+func TestResponses_MCPStreamReplaysEvents(t *testing.T) {
+	mcpServer, _ := newFakeMCPServer(t)
+	router, _ := newMCPResponsesTestRouter(t, mcpServer.URL,
+		`{"id":"resp_1","object":"response","status":"completed","output":[{"type":"message","id":"msg_1","role":"assistant","content":[{"type":"output_text","text":"hi"}]}]}`,
+	)
+
+	resp := postMCPResponses(router, `{"model":"gpt-5-4","stream":true,"input":"hi","tools":[{"type":"mcp","server_label":"docs","require_approval":"never"}]}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body=%s", resp.Code, resp.Body.String())
+	}
+	body := resp.Body.String()
+	for _, want := range []string{"event: response.created", "event: response.mcp_list_tools.completed", "event: response.output_text.delta", "event: response.completed", "data: [DONE]"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("stream missing %q; body=%s", want, body)
+		}
+	}
+}
@@ -171,6 +171,15 @@ func TestResponses_GetResponseStreamSynthesizesLifecycleForLegacyStoredResponse(
 	if got := payloads[len(payloads)-1].Get("response.output.0.content.0.text").String(); got != "legacy hello" {
 		t.Fatalf("terminal replay text = %q, want legacy hello; body=%s", got, resp.Body.String())
 	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/responses/resp_test_stream_legacy?stream=true&starting_after=2", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	payloads, _ = parseResponseStreamPayloads(t, resp.Body.String())
+	gotTypes = responseStreamPayloadTypes(payloads)
+	if strings.Join(gotTypes, ",") != "response.completed,response.done" || !strings.HasPrefix(resp.Body.String(), "id: 3\n") {
+		t.Fatalf("starting_after=2 replay types = %v; body=%s", gotTypes, resp.Body.String())
+	}
 }
 
 func TestResponses_RetrievesStoredResponseByDefault(t *testing.T) {
@@ -444,7 +453,7 @@ func TestResponses_GetResponseRejectsUnsupportedReplayQueryOptions(t *testing.T)
 			wantMessage: "stream",
 		},
 		{
-			name:        "unsupported starting_after",
+			name:        "invalid starting_after",
 			query:       "starting_after=evt_123",
 			wantParam:   "starting_after",
 			wantCode:    "invalid_value",
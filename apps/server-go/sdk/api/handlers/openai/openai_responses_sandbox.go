@@ -0,0 +1,362 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sandbox"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+var (
+	openAISandboxAuditMu   sync.Mutex
+	openAISandboxAuditLogs = make(map[string]*sandbox.AuditLog)
+)
+
+// openAISandboxFunctionTools are the function definitions the model sees in place of the hosted
+// code_interpreter, shell and local_shell tools.
+var openAISandboxFunctionTools = map[string]string{
+	config.SandboxToolCodeInterpreter: `{"type":"function","name":"code_interpreter","description":"Runs Python code in a sandbox without network access and returns its stdout and stderr. Files written to the working directory persist for the rest of this response.","parameters":{"type":"object","properties":{"code":{"type":"string","description":"The Python code to run."}},"required":["code"]},"strict":false}`,
+	config.SandboxToolShell:           `{"type":"function","name":"shell","description":"Runs shell commands in a sandbox without network access and returns their output. Commands run in order in the same working directory.","parameters":{"type":"object","properties":{"commands":{"type":"array","items":{"type":"string"},"description":"The shell commands to run."}},"required":["commands"]},"strict":false}`,
+	config.SandboxToolLocalShell:      `{"type":"function","name":"local_shell","description":"Runs a command in a sandbox without network access and returns its output.","parameters":{"type":"object","properties":{"command":{"type":"array","items":{"type":"string"},"description":"The program and its arguments."}},"required":["command"]},"strict":false}`,
+}
+
+// openAISandboxSession executes code_interpreter, shell and local_shell calls of one request in a
+// shared sandbox workspace.
+type openAISandboxSession struct {
+	options   sandbox.Options
+	audit     *sandbox.AuditLog
+	tools     map[string]bool
+	keyLabel  string
+	model     string
+	workspace *sandbox.Workspace
+	container string
+}
+
+// prepareOpenAIResponsesSandboxRequest replaces code_interpreter, shell and local_shell tools with
+// function tools executed in the local sandbox, and rewrites earlier calls of those tools in the
+// input into function calls. It returns a nil session when the request uses none of them or the
+// sandbox is not enabled for the request's key.
+func (h *OpenAIResponsesAPIHandler) prepareOpenAIResponsesSandboxRequest(ctx context.Context, rawJSON []byte) (*openAISandboxSession, []byte, *interfaces.ErrorMessage) {
+	cfg := h.Cfg.Sandbox
+	requested := make(map[string]bool)
+	for _, tool := range gjson.GetBytes(rawJSON, "tools").Array() {
+		toolType := tool.Get("type").String()
+		if _, ok := openAISandboxFunctionTools[toolType]; ok && cfg.AllowsTool(toolType) {
+			requested[toolType] = true
+		}
+	}
+	if len(requested) == 0 || !h.SandboxEnabled(ctx) {
+		return nil, rawJSON, nil
+	}
+
+	session := &openAISandboxSession{
+		options: sandbox.Options{
+			WorkDir:             cfg.WorkDir,
+			Timeout:             time.Duration(cfg.Timeout) * time.Second,
+			CPUSeconds:          cfg.CPUSeconds,
+			MemoryMB:            cfg.MemoryMB,
+			MaxProcesses:        cfg.MaxProcesses,
+			MaxOutputBytes:      cfg.MaxOutputBytes,
+			AllowNetwork:        cfg.AllowNetwork,
+			AllowHostFilesystem: cfg.AllowHostFilesystem,
+			AllowedCommands:     cfg.AllowedCommands,
+			PythonCommand:       cfg.PythonCommand,
+		},
+		audit:    openAISandboxAuditLog(cfg.AuditLog),
+		tools:    requested,
+		keyLabel: handlers.RequestKeyLabel(ctx),
+		model:    gjson.GetBytes(rawJSON, "model").String(),
+	}
+
+	tools := make([]json.RawMessage, 0)
+	for i, tool := range gjson.GetBytes(rawJSON, "tools").Array() {
+		toolType := tool.Get("type").String()
+		if requested[toolType] {
+			if toolType == config.SandboxToolCodeInterpreter {
+				session.container = tool.Get("container").String()
+			}
+			continue
+		}
+		if toolType == "function" {
+			if _, taken := requested[tool.Get("name").String()]; taken {
+				return nil, nil, invalidOpenAIRequestWithDetailf(fmt.Sprintf("tools[%d].name", i), "invalid_value", "function name %q collides with the %s tool", tool.Get("name").String(), tool.Get("name").String())
+			}
+		}
+		tools = append(tools, json.RawMessage(tool.Raw))
+	}
+	for _, toolType := range []string{config.SandboxToolCodeInterpreter, config.SandboxToolShell, config.SandboxToolLocalShell} {
+		if requested[toolType] {
+			tools = append(tools, json.RawMessage(openAISandboxFunctionTools[toolType]))
+		}
+	}
+	out, err := sjson.SetRawBytes(rawJSON, "tools", mustMarshalRawItems(tools))
+	if err != nil {
+		return nil, nil, invalidOpenAIRequestf("failed to rewrite sandbox tools: %v", err)
+	}
+	if choice := gjson.GetBytes(out, "tool_choice"); choice.IsObject() && requested[choice.Get("type").String()] {
+		out, _ = sjson.SetRawBytes(out, "tool_choice", []byte(fmt.Sprintf(`{"type":"function","name":%q}`, choice.Get("type").String())))
+	}
+	return session, rewriteOpenAISandboxInput(out), nil
+}
+
+// rewriteOpenAISandboxInput turns code_interpreter, shell and local_shell items from earlier
+// responses into function calls and outputs the backend understands.
+func rewriteOpenAISandboxInput(rawJSON []byte) []byte {
+	input := gjson.GetBytes(rawJSON, "input")
+	if !input.IsArray() {
+		return rawJSON
+	}
+	items := make([]json.RawMessage, 0, len(input.Array()))
+	changed := false
+	for _, item := range input.Array() {
+		switch item.Get("type").String() {
+		case "code_interpreter_call":
+			arguments, _ := json.Marshal(map[string]string{"code": item.Get("code").String()})
+			logs := make([]string, 0)
+			for _, output := range item.Get("outputs").Array() {
+				if output.Get("type").String() == "logs" {
+					logs = append(logs, output.Get("logs").String())
+				}
+			}
+			callID := item.Get("id").String()
+			items = append(items, marshalOpenAIFunctionCallItem(callID, config.SandboxToolCodeInterpreter, string(arguments)), marshalOpenAIFunctionCallOutputItem(callID, strings.Join(logs, "\n")))
+			changed = true
+		case "shell_call":
+			arguments, _ := json.Marshal(map[string]any{"commands": json.RawMessage(defaultRawJSON(item.Get("action.commands").Raw, "[]"))})
+			items = append(items, marshalOpenAIFunctionCallItem(item.Get("call_id").String(), config.SandboxToolShell, string(arguments)))
+			changed = true
+		case "shell_call_output":
+			parts := make([]string, 0)
+			for _, output := range item.Get("output").Array() {
+				parts = append(parts, formatOpenAISandboxOutput(output.Get("stdout").String(), output.Get("stderr").String(), int(output.Get("outcome.exit_code").Int()), output.Get("outcome.type").String() == "timeout", false))
+			}
+			items = append(items, marshalOpenAIFunctionCallOutputItem(item.Get("call_id").String(), strings.Join(parts, "\n")))
+			changed = true
+		case "local_shell_call":
+			arguments, _ := json.Marshal(map[string]any{"command": json.RawMessage(defaultRawJSON(item.Get("action.command").Raw, "[]"))})
+			items = append(items, marshalOpenAIFunctionCallItem(item.Get("call_id").String(), config.SandboxToolLocalShell, string(arguments)))
+			changed = true
+		case "local_shell_call_output":
+			items = append(items, marshalOpenAIFunctionCallOutputItem(item.Get("call_id").String(), item.Get("output").String()))
+			changed = true
+		default:
+			items = append(items, json.RawMessage(item.Raw))
+		}
+	}
+	if !changed {
+		return rawJSON
+	}
+	out, err := sjson.SetRawBytes(rawJSON, "input", mustMarshalRawItems(items))
+	if err != nil {
+		return rawJSON
+	}
+	return out
+}
+
+func defaultRawJSON(raw, fallback string) string {
+	if strings.TrimSpace(raw) == "" {
+		return fallback
+	}
+	return raw
+}
+
+// handleFunctionCall runs a model call to one of the sandbox functions and reports it as the
+// matching hosted tool items.
+func (s *openAISandboxSession) handleFunctionCall(ctx context.Context, call gjson.Result) (openAIHostedCallResult, bool) {
+	tool := call.Get("name").String()
+	if !s.tools[tool] {
+		return openAIHostedCallResult{}, false
+	}
+	callID := call.Get("call_id").String()
+	arguments := gjson.Parse(call.Get("arguments").String())
+
+	var items []json.RawMessage
+	var modelOutput string
+	switch tool {
+	case config.SandboxToolCodeInterpreter:
+		code := arguments.Get("code").String()
+		result, err := s.run(ctx, tool, code, func(ws *sandbox.Workspace) (sandbox.Result, error) {
+			return ws.RunPython(ctx, code)
+		})
+		modelOutput = formatOpenAISandboxResult(result, err)
+		status := "completed"
+		if err != nil || result.ExitCode != 0 || result.TimedOut {
+			status = "failed"
+		}
+		item, _ := json.Marshal(map[string]any{
+			"id":           newOpenAIHostedItemID("ci"),
+			"type":         "code_interpreter_call",
+			"status":       status,
+			"code":         code,
+			"container_id": s.containerID(),
+			"outputs":      []map[string]string{{"type": "logs", "logs": modelOutput}},
+		})
+		items = append(items, item)
+	case config.SandboxToolShell:
+		commands := make([]string, 0)
+		for _, command := range arguments.Get("commands").Array() {
+			commands = append(commands, command.String())
+		}
+		outputs := make([]map[string]any, 0, len(commands))
+		texts := make([]string, 0, len(commands))
+		for _, command := range commands {
+			result, err := s.run(ctx, tool, command, func(ws *sandbox.Workspace) (sandbox.Result, error) {
+				return ws.RunShell(ctx, command)
+			})
+			outputs = append(outputs, openAISandboxShellOutput(result, err))
+			texts = append(texts, formatOpenAISandboxResult(result, err))
+		}
+		modelOutput = strings.Join(texts, "\n")
+		callItem, _ := json.Marshal(map[string]any{
+			"id":      newOpenAIHostedItemID("sh"),
+			"type":    "shell_call",
+			"call_id": callID,
+			"action":  map[string]any{"commands": commands, "timeout_ms": s.options.Timeout.Milliseconds()},
+			"status":  "completed",
+		})
+		outputItem, _ := json.Marshal(map[string]any{
+			"id":      newOpenAIHostedItemID("sho"),
+			"type":    "shell_call_output",
+			"call_id": callID,
+			"output":  outputs,
+		})
+		items = append(items, callItem, outputItem)
+	case config.SandboxToolLocalShell:
+		argv := make([]string, 0)
+		for _, arg := range arguments.Get("command").Array() {
+			argv = append(argv, arg.String())
+		}
+		result, err := s.run(ctx, tool, strings.Join(argv, " "), func(ws *sandbox.Workspace) (sandbox.Result, error) {
+			return ws.RunArgv(ctx, argv)
+		})
+		modelOutput = formatOpenAISandboxResult(result, err)
+		callItem, _ := json.Marshal(map[string]any{
+			"id":      newOpenAIHostedItemID("lsh"),
+			"type":    "local_shell_call",
+			"call_id": callID,
+			"action":  map[string]any{"type": "exec", "command": argv, "timeout_ms": s.options.Timeout.Milliseconds(), "env": map[string]string{}},
+			"status":  "completed",
+		})
+		outputItem, _ := json.Marshal(map[string]any{
+			"id":      newOpenAIHostedItemID("lsho"),
+			"type":    "local_shell_call_output",
+			"call_id": callID,
+			"output":  modelOutput,
+		})
+		items = append(items, callItem, outputItem)
+	}
+
+	return openAIHostedCallResult{
+		output:       items,
+		continuation: []json.RawMessage{json.RawMessage(call.Raw), marshalOpenAIFunctionCallOutputItem(callID, modelOutput)},
+	}, true
+}
+
+// run executes one command in the session workspace, creating it on first use, and records the
+// execution in the audit log.
+func (s *openAISandboxSession) run(ctx context.Context, tool, command string, execute func(*sandbox.Workspace) (sandbox.Result, error)) (sandbox.Result, error) {
+	var result sandbox.Result
+	var err error
+	if s.workspace == nil {
+		s.workspace, err = sandbox.NewWorkspace(s.options)
+	}
+	if err == nil {
+		result, err = execute(s.workspace)
+	}
+	entry := sandbox.AuditEntry{
+		Key:        s.keyLabel,
+		Model:      s.model,
+		Tool:       tool,
+		Command:    command,
+		ExitCode:   result.ExitCode,
+		TimedOut:   result.TimedOut,
+		DurationMS: result.Duration.Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		log.Warnf("sandbox %s call failed: %v", tool, err)
+	}
+	s.audit.Record(entry)
+	return result, err
+}
+
+func (s *openAISandboxSession) containerID() string {
+	if id := strings.TrimSpace(s.container); id != "" && !strings.HasPrefix(id, "{") {
+		return id
+	}
+	if s.workspace != nil {
+		return "cntr_local_" + strings.TrimPrefix(filepath.Base(s.workspace.Dir()), "ws-")
+	}
+	return ""
+}
+
+// Close removes the session workspace.
+func (s *openAISandboxSession) Close() {
+	if s != nil && s.workspace != nil {
+		_ = s.workspace.Close()
+	}
+}
+
+func openAISandboxShellOutput(result sandbox.Result, err error) map[string]any {
+	output := map[string]any{"stdout": result.Stdout, "stderr": result.Stderr}
+	switch {
+	case err != nil:
+		output["stderr"] = err.Error()
+		output["outcome"] = map[string]any{"type": "exit", "exit_code": 126}
+	case result.TimedOut:
+		output["outcome"] = map[string]any{"type": "timeout"}
+	default:
+		output["outcome"] = map[string]any{"type": "exit", "exit_code": result.ExitCode}
+	}
+	return output
+}
+
+func formatOpenAISandboxResult(result sandbox.Result, err error) string {
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	return formatOpenAISandboxOutput(result.Stdout, result.Stderr, result.ExitCode, result.TimedOut, result.Truncated)
+}
+
+func formatOpenAISandboxOutput(stdout, stderr string, exitCode int, timedOut, truncated bool) string {
+	var b strings.Builder
+	b.WriteString(stdout)
+	if stderr != "" {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+		b.WriteString(stderr)
+	}
+	switch {
+	case timedOut:
+		b.WriteString("\n[execution timed out]")
+	case exitCode != 0:
+		fmt.Fprintf(&b, "\n[exit code %d]", exitCode)
+	}
+	if truncated {
+		b.WriteString("\n[output truncated]")
+	}
+	return b.String()
+}
+
+func openAISandboxAuditLog(path string) *sandbox.AuditLog {
+	openAISandboxAuditMu.Lock()
+	defer openAISandboxAuditMu.Unlock()
+	if existing, ok := openAISandboxAuditLogs[path]; ok {
+		return existing
+	}
+	audit := sandbox.NewAuditLog(path)
+	openAISandboxAuditLogs[path] = audit
+	return audit
+}
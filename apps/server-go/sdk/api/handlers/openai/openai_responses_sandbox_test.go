@@ -0,0 +1,127 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func newSandboxResponsesTestRouter(t *testing.T, sandbox sdkconfig.SandboxConfig, payloads ...string) (*gin.Engine, *sequencedSurfaceExecutor) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	executor := &sequencedSurfaceExecutor{}
+	for _, payload := range payloads {
+		executor.payloads = append(executor.payloads, []byte(payload))
+	}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "sandbox-auth-" + t.Name(), Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registerSurfaceModel(t, auth.ID, auth.Provider, &registry.ModelInfo{
+		ID:      "gpt-5-4",
+		Object:  "model",
+		OwnedBy: "auggie",
+		Type:    "auggie",
+		Version: "gpt-5-4",
+	})
+
+	h := NewOpenAIResponsesAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{Sandbox: sandbox}, manager))
+	router := gin.New()
+	router.POST("/v1/responses", h.Responses)
+	return router, executor
+}
+
+func TestResponses_SandboxRunsShellToolLocally(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh is not available")
+	}
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	router, executor := newSandboxResponsesTestRouter(t, sdkconfig.SandboxConfig{
+		Enabled:         true,
+		AllowNetwork:    true,
+		AllowedCommands: []string{"echo"},
+		WorkDir:         t.TempDir(),
+		AuditLog:        auditPath,
+	},
+		`{"id":"resp_1","object":"response","status":"completed","output":[{"type":"function_call","id":"fc_1","call_id":"call_1","name":"shell","arguments":"{\"commands\":[\"echo sandboxed\"]}"}]}`,
+		`{"id":"resp_2","object":"response","status":"completed","output":[{"type":"message","id":"msg_1","role":"assistant","content":[{"type":"output_text","text":"done"}]}]}`,
+	)
+
+	resp := postMCPResponses(router, `{"model":"gpt-5-4","input":"say something","tools":[{"type":"shell"}]}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body=%s", resp.Code, resp.Body.String())
+	}
+	if got := gjson.GetBytes(executor.requests[0], "tools.0.name").String(); got != "shell" {
+		t.Fatalf("upstream tool = %q; payload=%s", got, executor.requests[0])
+	}
+	if got := gjson.GetBytes(executor.requests[1], `input.#(type=="function_call_output").output`).String(); got != "sandboxed\n" {
+		t.Fatalf("function_call_output = %q", got)
+	}
+
+	body := resp.Body.Bytes()
+	if got := gjson.GetBytes(body, `output.#(type=="shell_call_output").output.0.stdout`).String(); got != "sandboxed\n" {
+		t.Fatalf("shell_call_output stdout = %q; body=%s", got, body)
+	}
+	if got := gjson.GetBytes(body, `output.#(type=="shell_call").call_id`).String(); got != "call_1" {
+		t.Fatalf("shell_call call_id = %q; body=%s", got, body)
+	}
+	audit, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	if !strings.Contains(string(audit), `"command":"echo sandboxed"`) {
+		t.Fatalf("audit log = %s", audit)
+	}
+}
+
+func TestResponses_SandboxCodeInterpreter(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not available")
+	}
+	router, _ := newSandboxResponsesTestRouter(t, sdkconfig.SandboxConfig{
+		Enabled:      true,
+		AllowNetwork: true,
+		WorkDir:      t.TempDir(),
+	},
+		`{"id":"resp_1","object":"response","status":"completed","output":[{"type":"function_call","id":"fc_1","call_id":"call_1","name":"code_interpreter","arguments":"{\"code\":\"print(6*7)\"}"}]}`,
+		`{"id":"resp_2","object":"response","status":"completed","output":[{"type":"message","id":"msg_1","role":"assistant","content":[{"type":"output_text","text":"42"}]}]}`,
+	)
+
+	resp := postMCPResponses(router, `{"model":"gpt-5-4","input":"compute","tools":[{"type":"code_interpreter","container":{"type":"auto"}}]}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body=%s", resp.Code, resp.Body.String())
+	}
+	call := gjson.GetBytes(resp.Body.Bytes(), `output.#(type=="code_interpreter_call")`)
+	if call.Get("outputs.0.logs").String() != "42\n" || call.Get("status").String() != "completed" {
+		t.Fatalf("code_interpreter_call = %s", call.Raw)
+	}
+}
+
+func TestResponses_SandboxDisabledForwardsToolUnchanged(t *testing.T) {
+	router, executor := newSandboxResponsesTestRouter(t, sdkconfig.SandboxConfig{Enabled: false})
+
+	resp := postMCPResponses(router, `{"model":"gpt-5-4","input":"compute","tools":[{"type":"code_interpreter","container":{"type":"auto"}}]}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body=%s", resp.Code, resp.Body.String())
+	}
+	if len(executor.requests) != 1 {
+		t.Fatalf("model was called %d times, want 1", len(executor.requests))
+	}
+	if got := gjson.GetBytes(executor.requests[0], "tools.0.type").String(); got != "code_interpreter" {
+		t.Fatalf("upstream tool type = %q, want code_interpreter", got)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
 	"github.com/tidwall/gjson"
@@ -114,7 +115,7 @@ func (s *storedOpenAIResponseStore) Load(responseID string) (storedOpenAIRespons
 	now := time.Now().UTC()
 	s.cleanupLocked(now)
 
-	item, ok := s.items[strings.TrimSpace(responseID)]
+	item, ok := s.lookupLocked(strings.TrimSpace(responseID))
 	if !ok {
 		return storedOpenAIResponse{}, false
 	}
@@ -137,18 +138,19 @@ func (s *storedOpenAIResponseStore) AppendReplayEvent(responseID string, eventPa
 	now := time.Now().UTC()
 	s.cleanupLocked(now)
 
-	existing, ok := s.items[responseID]
+	existing, ok := s.lookupLocked(responseID)
 	if !ok {
 		return false
 	}
 	existing.ReplayEvents = append(cloneByteSlices(existing.ReplayEvents), bytes.Clone(eventPayload))
 	existing.UpdatedAt = now
 	s.items[responseID] = existing
+	cluster.Save(cluster.NamespaceResponse, responseID, existing, storedOpenAIResponseTTL)
 	return true
 }
 
 func (s *storedOpenAIResponseStore) storeIfPresentLocked(responseID string, responseBody, inputItems []byte, replayEvents ...[]byte) bool {
-	existing, ok := s.items[responseID]
+	existing, ok := s.lookupLocked(responseID)
 	if !ok {
 		return false
 	}
@@ -160,7 +162,7 @@ func (s *storedOpenAIResponseStore) storeIfPresentLocked(responseID string, resp
 }
 
 func (s *storedOpenAIResponseStore) storeLocked(responseID string, responseBody, inputItems []byte, mustExist bool, replayEvents ...[]byte) {
-	existing, ok := s.items[responseID]
+	existing, ok := s.lookupLocked(responseID)
 	if mustExist && !ok {
 		return
 	}
@@ -174,12 +176,31 @@ func (s *storedOpenAIResponseStore) storeLocked(responseID string, responseBody,
 
 	combinedReplayEvents := cloneByteSlices(existing.ReplayEvents)
 	combinedReplayEvents = append(combinedReplayEvents, compactReplayEvents(replayEvents)...)
-	s.items[responseID] = storedOpenAIResponse{
+	item := storedOpenAIResponse{
 		Response:     bytes.Clone(responseBody),
 		InputItems:   cloneBytesOrDefault(inputItems, []byte("[]")),
 		ReplayEvents: combinedReplayEvents,
 		UpdatedAt:    time.Now().UTC(),
 	}
+	s.items[responseID] = item
+	cluster.Save(cluster.NamespaceResponse, responseID, item, storedOpenAIResponseTTL)
+}
+
+// lookupLocked returns the stored response. In cluster mode the shared copy is authoritative
+// so responses created or updated on other nodes are visible here; the local copy is used only
+// when the backend is unreachable.
+func (s *storedOpenAIResponseStore) lookupLocked(responseID string) (storedOpenAIResponse, bool) {
+	var shared storedOpenAIResponse
+	if found, reachable := cluster.Lookup(cluster.NamespaceResponse, responseID, &shared); reachable {
+		if !found {
+			delete(s.items, responseID)
+			return storedOpenAIResponse{}, false
+		}
+		s.items[responseID] = shared
+		return shared, true
+	}
+	item, ok := s.items[responseID]
+	return item, ok
 }
 
 func (s *storedOpenAIResponseStore) RegisterBackgroundTask(responseID string, cancel context.CancelCauseFunc) bool {
@@ -193,7 +214,7 @@ func (s *storedOpenAIResponseStore) RegisterBackgroundTask(responseID string, ca
 	if responseID == "" || cancel == nil {
 		return false
 	}
-	if _, ok := s.items[responseID]; !ok {
+	if _, ok := s.lookupLocked(responseID); !ok {
 		return false
 	}
 	s.tasks[responseID] = storedOpenAIResponseTask{cancel: cancel}
@@ -238,7 +259,7 @@ func (s *storedOpenAIResponseStore) Delete(responseID string) bool {
 	if responseID == "" {
 		return false
 	}
-	if _, ok := s.items[responseID]; !ok {
+	if _, ok := s.lookupLocked(responseID); !ok {
 		return false
 	}
 	if task, ok := s.tasks[responseID]; ok {
@@ -248,6 +269,7 @@ func (s *storedOpenAIResponseStore) Delete(responseID string) bool {
 		delete(s.tasks, responseID)
 	}
 	delete(s.items, responseID)
+	cluster.Remove(cluster.NamespaceResponse, responseID)
 	return true
 }
 
@@ -817,7 +839,9 @@ func validateStoredOpenAIResponseGetQuery(c *gin.Context) *interfaces.ErrorMessa
 		return invalidOpenAIValue("stream", "Invalid value for 'stream': expected one of true or false on GET /v1/responses/{response_id}.")
 	}
 	if raw := strings.TrimSpace(c.Query("starting_after")); raw != "" {
-		return invalidOpenAIValue("starting_after", "Invalid value for 'starting_after': starting_after is not supported on GET /v1/responses/{response_id} because stored replay pagination is not implemented.")
+		if _, ok := storedOpenAIResponseStartingAfter(c); !ok {
+			return invalidOpenAIValue("starting_after", "Invalid value for 'starting_after': expected a non-negative event number on GET /v1/responses/{response_id}.")
+		}
 	}
 	if raw := strings.TrimSpace(c.Query("include_obfuscation")); raw != "" && !strings.EqualFold(raw, "false") {
 		return invalidOpenAIValue("include_obfuscation", "Invalid value for 'include_obfuscation': include_obfuscation is not supported on GET /v1/responses/{response_id}.")
@@ -828,6 +852,20 @@ func validateStoredOpenAIResponseGetQuery(c *gin.Context) *interfaces.ErrorMessa
 	return nil
 }
 
+// storedOpenAIResponseStartingAfter parses starting_after, the number of the last stream event the
+// client already received. It is 0 when absent.
+func storedOpenAIResponseStartingAfter(c *gin.Context) (int64, bool) {
+	raw := strings.TrimSpace(c.Query("starting_after"))
+	if raw == "" {
+		return 0, true
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value < 0 {
+		return 0, false
+	}
+	return value, true
+}
+
 func validateStoredOpenAIResponseInputItemsQuery(c *gin.Context) *interfaces.ErrorMessage {
 	if queryHasNonEmptyArrayValue(c, "include", "include[]") {
 		return invalidOpenAIRequestWithDetailf("include", "unsupported_parameter", "include is not supported on GET /v1/responses/{response_id}/input_items because include expansions are not implemented")
@@ -194,6 +194,30 @@ func (h *OpenAIResponsesAPIHandler) ResponsesWebsocket(c *gin.Context) {
 			}
 			continue
 		}
+		requestJSON, errMsg = resolveOpenAIFileReferences(h.Cfg, c, requestJSON)
+		if errMsg != nil {
+			h.LoggingAPIResponseError(context.WithValue(context.Background(), "gin", c), errMsg)
+			markAPIResponseTimestamp(c)
+			errorPayload, errWrite := writeResponsesWebsocketError(conn, errMsg)
+			appendWebsocketEvent(&wsBodyLog, "response", errorPayload)
+			log.Infof(
+				"responses websocket: downstream_out id=%s type=%d event=%s payload=%s",
+				passthroughSessionID,
+				websocket.TextMessage,
+				websocketPayloadEventType(errorPayload),
+				websocketPayloadPreview(errorPayload),
+			)
+			if errWrite != nil {
+				log.Warnf(
+					"responses websocket: downstream_out write failed id=%s event=%s error=%v",
+					passthroughSessionID,
+					websocketPayloadEventType(errorPayload),
+					errWrite,
+				)
+				return
+			}
+			continue
+		}
 		if errMsg := validateOpenAIResponsesInputItemTypeSupport(requestJSON, normalizedModel, providers); errMsg != nil {
 			h.LoggingAPIResponseError(context.WithValue(context.Background(), "gin", c), errMsg)
 			markAPIResponseTimestamp(c)
@@ -447,7 +471,11 @@ func (h *OpenAIResponsesAPIHandler) ResponsesWebsocket(c *gin.Context) {
 			}
 			continue
 		}
-		if errMsg := validateOpenAIResponsesProviderRequestFeatureSupport(requestJSON, normalizedModel, providers); errMsg != nil {
+		validatedJSON, errMsg := h.ApplyParameterPolicy(context.WithValue(context.Background(), "gin", c), normalizedModel, requestJSON, func(payload []byte) *interfaces.ErrorMessage {
+			return validateOpenAIResponsesProviderRequestFeatureSupport(withoutAuggieEmulatedFields(h.Cfg, payload), normalizedModel, providers)
+		})
+		requestJSON = validatedJSON
+		if errMsg != nil {
 			h.LoggingAPIResponseError(context.WithValue(context.Background(), "gin", c), errMsg)
 			markAPIResponseTimestamp(c)
 			errorPayload, errWrite := writeResponsesWebsocketError(conn, errMsg)
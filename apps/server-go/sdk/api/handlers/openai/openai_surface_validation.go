@@ -12,7 +12,9 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 )
 
 var supportedOpenAIResponsesIncludeValues = []string{
@@ -1082,6 +1084,10 @@ func validateOpenAIResponsesMessageContentTypeSupport(rawJSON []byte, modelID st
 	}
 
 	supported := openAIResponsesSupportedMessageContentTypes(modelID, providers)
+	inlineFilesOnly := false
+	for _, provider := range providers {
+		inlineFilesOnly = inlineFilesOnly || openAIResponsesProviderFamily(modelID, provider) == "gemini"
+	}
 	for index, item := range input.Array() {
 		itemType := strings.TrimSpace(item.Get("type").String())
 		if itemType == "" && strings.TrimSpace(item.Get("role").String()) != "" {
@@ -1090,7 +1096,7 @@ func validateOpenAIResponsesMessageContentTypeSupport(rawJSON []byte, modelID st
 		if itemType != "message" {
 			continue
 		}
-		if errMsg := validateOpenAIResponsesMessageContentItemSupport(item, index, supported); errMsg != nil {
+		if errMsg := validateOpenAIResponsesMessageContentItemSupport(item, index, supported, inlineFilesOnly); errMsg != nil {
 			return errMsg
 		}
 	}
@@ -1178,6 +1184,40 @@ func validateOpenAIResponsesToolDefinitionSupport(rawJSON []byte, modelID string
 	return nil
 }
 
+// withoutAuggieEmulatedFields drops the fields the Auggie executor emulates under cfg from a copy
+// of the request, so route-level capability checks leave them to the executor, which validates
+// them itself.
+func withoutAuggieEmulatedFields(cfg *config.SDKConfig, rawJSON []byte) []byte {
+	if cfg == nil {
+		return rawJSON
+	}
+	var fields []string
+	if cfg.StructuredOutput.Emulate {
+		for _, path := range []string{"response_format", "text.format"} {
+			switch strings.ToLower(strings.TrimSpace(gjson.GetBytes(rawJSON, path+".type").String())) {
+			case "json_schema", "json_object":
+				fields = append(fields, path)
+			}
+		}
+	}
+	emulation := cfg.ParameterEmulation["auggie"]
+	if emulation.Stop {
+		fields = append(fields, "stop")
+	}
+	if emulation.MaxTokens {
+		fields = append(fields, "max_tokens", "max_completion_tokens", "max_output_tokens")
+	}
+	if emulation.N {
+		fields = append(fields, "n")
+	}
+	for _, field := range fields {
+		if gjson.GetBytes(rawJSON, field).Exists() {
+			rawJSON, _ = sjson.DeleteBytes(rawJSON, field)
+		}
+	}
+	return rawJSON
+}
+
 func validateOpenAIResponsesProviderRequestFeatureSupport(rawJSON []byte, modelID string, providers []string) *interfaces.ErrorMessage {
 	if !openAIResponsesRouteSupportsBridgedCustomTools(modelID, providers) || openAIResponsesRouteSupportsNativeInputItems(modelID, providers) {
 		return nil
@@ -2401,7 +2441,10 @@ func validateOpenAIAuggieBuiltInWebSearchToolConfig(tool gjson.Result, index int
 	return nil
 }
 
-func validateOpenAIResponsesMessageContentItemSupport(item gjson.Result, index int, supported map[string]struct{}) *interfaces.ErrorMessage {
+// validateOpenAIResponsesMessageContentItemSupport rejects content types the route cannot
+// translate. With inlineFilesOnly, input_file parts must carry file_data or file_url, because
+// Gemini has no equivalent of an unresolved file_id.
+func validateOpenAIResponsesMessageContentItemSupport(item gjson.Result, index int, supported map[string]struct{}, inlineFilesOnly bool) *interfaces.ErrorMessage {
 	content := item.Get("content")
 	if !content.Exists() || content.Type == gjson.Null || content.Type == gjson.String {
 		return nil
@@ -2417,6 +2460,15 @@ func validateOpenAIResponsesMessageContentItemSupport(item gjson.Result, index i
 			contentType = "input_text"
 		}
 		if _, ok := supported[contentType]; ok {
+			// file_id references are resolved beforehand by the local Files API when it is enabled.
+			if inlineFilesOnly && contentType == "input_file" && strings.TrimSpace(contentItem.Get("file_data").String()) == "" && strings.TrimSpace(contentItem.Get("file_url").String()) == "" {
+				param := fmt.Sprintf("input[%d].content[%d]", index, contentIndex)
+				return invalidOpenAIValue(
+					param,
+					"Invalid value for '%s': input_file must carry file_data or file_url on /v1/responses for the selected model route; file_id is not supported here",
+					param,
+				)
+			}
 			continue
 		}
 		param := fmt.Sprintf("input[%d].content[%d].type", index, contentIndex)
@@ -2452,6 +2504,20 @@ func openAIResponsesSupportedMessageContentTypes(modelID string, providers []str
 }
 
 func openAIResponsesProviderSupportedMessageContentTypes(modelID string, provider string) map[string]struct{} {
+	switch openAIResponsesProviderFamily(modelID, provider) {
+	case "auggie":
+		return newOpenAIResponsesContentTypeSet("input_text", "output_text")
+	case "claude":
+		return newOpenAIResponsesContentTypeSet("input_text", "output_text", "input_image", "input_file")
+	case "gemini":
+		return newOpenAIResponsesContentTypeSet("input_text", "output_text", "input_image", "input_audio", "input_file")
+	default:
+		return newOpenAIResponsesContentTypeSet("input_text", "output_text", "input_image")
+	}
+}
+
+// openAIResponsesProviderFamily classifies a provider route as "auggie", "claude", "gemini" or "".
+func openAIResponsesProviderFamily(modelID string, provider string) string {
 	provider = strings.ToLower(strings.TrimSpace(provider))
 	baseModel := strings.ToLower(strings.TrimSpace(thinking.ParseSuffix(modelID).ModelName))
 
@@ -2467,13 +2533,13 @@ func openAIResponsesProviderSupportedMessageContentTypes(modelID string, provide
 
 	switch {
 	case provider == "auggie" || typeKey == "auggie" || ownedBy == "auggie":
-		return newOpenAIResponsesContentTypeSet("input_text", "output_text")
+		return "auggie"
 	case provider == "claude" || typeKey == "claude" || ownedBy == "claude" || strings.Contains(baseModel, "claude"):
-		return newOpenAIResponsesContentTypeSet("input_text", "output_text", "input_image", "input_file")
+		return "claude"
 	case provider == "gemini" || typeKey == "gemini" || ownedBy == "gemini" || strings.Contains(baseModel, "gemini"):
-		return newOpenAIResponsesContentTypeSet("input_text", "output_text", "input_image", "input_audio")
+		return "gemini"
 	default:
-		return newOpenAIResponsesContentTypeSet("input_text", "output_text", "input_image")
+		return ""
 	}
 }
 
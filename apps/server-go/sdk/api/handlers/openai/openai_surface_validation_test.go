@@ -837,6 +837,40 @@ func TestResponses_AllowsInputFileMessageContentToReachExecutionForClaudeRespons
 	}
 }
 
+func TestResponses_RejectsUnresolvedInputFileForGeminiRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	executor, manager, auth := newOpenAISurfaceTestHarnessWithProvider(t, "gemini")
+	registerSurfaceModel(t, auth.ID, auth.Provider, &registry.ModelInfo{
+		ID:      "gpt-5-1",
+		Object:  "model",
+		OwnedBy: "gemini",
+		Type:    "gemini",
+	})
+
+	base := handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+	h := NewOpenAIResponsesAPIHandler(base)
+	router := gin.New()
+	router.POST("/v1/responses", h.Responses)
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/v1/responses",
+		strings.NewReader(`{"model":"gpt-5-1","input":[{"type":"message","role":"user","content":[{"type":"input_file","file_id":"file-1","filename":"notes.txt"}]}]}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d; body=%s", resp.Code, http.StatusBadRequest, resp.Body.String())
+	}
+	if executor.executeCalls != 0 {
+		t.Fatalf("execute calls = %d, want 0", executor.executeCalls)
+	}
+	assertSurfaceOpenAIErrorBody(t, resp.Body.String(), "input[0].content[0]", "invalid_value", "file_data or file_url")
+}
+
 func TestResponses_AllowsInputFileMessageContentForOpenAICompatibleNativeResponses(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -4699,6 +4733,104 @@ func TestChatCompletions_RejectsNonPreservedAuggieNStopAndSeedBeforeExecution(t
 	}
 }
 
+func TestChatCompletions_DefersEmulatedAuggieParametersToExecutor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	executor, manager, auth := newOpenAISurfaceTestHarness(t)
+	registerSurfaceModel(t, auth.ID, auth.Provider, &registry.ModelInfo{
+		ID:      "gpt-5-4",
+		Object:  "model",
+		OwnedBy: "auggie",
+		Type:    "auggie",
+		Version: "gpt-5-4",
+	})
+
+	cfg := &sdkconfig.SDKConfig{
+		StructuredOutput:   sdkconfig.StructuredOutputConfig{Emulate: true},
+		ParameterEmulation: map[string]sdkconfig.ParameterEmulationConfig{"auggie": {Stop: true, N: true}},
+	}
+	base := handlers.NewBaseAPIHandlers(cfg, manager)
+	h := NewOpenAIAPIHandler(base)
+	router := gin.New()
+	router.POST("/v1/chat/completions", h.ChatCompletions)
+
+	body := `{"model":"gpt-5-4","messages":[{"role":"user","content":"hello"}],"n":2,"stop":"END","response_format":{"type":"json_object"}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body=%s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	if executor.executeCalls != 1 {
+		t.Fatalf("execute calls = %d, want 1", executor.executeCalls)
+	}
+	if got := gjson.GetBytes(executor.lastPayload, "response_format.type").String(); got != "json_object" {
+		t.Fatalf("executor payload response_format.type = %q, want the emulated field forwarded", got)
+	}
+	if got := gjson.GetBytes(executor.lastPayload, "stop").String(); got != "END" {
+		t.Fatalf("executor payload stop = %q, want the emulated field forwarded", got)
+	}
+}
+
+func TestChatCompletions_ParameterPolicyDropsUnsupportedParameters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		policy     string
+		wantStatus int
+		wantHeader string
+	}{
+		{name: "strict", policy: sdkconfig.ParameterPolicyStrict, wantStatus: http.StatusBadRequest},
+		{name: "lenient", policy: sdkconfig.ParameterPolicyLenient, wantStatus: http.StatusOK},
+		{name: "warn", policy: sdkconfig.ParameterPolicyWarn, wantStatus: http.StatusOK, wantHeader: "temperature,response_format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor, manager, auth := newOpenAISurfaceTestHarness(t)
+			registerSurfaceModel(t, auth.ID, auth.Provider, &registry.ModelInfo{
+				ID:      "gpt-5-4",
+				Object:  "model",
+				OwnedBy: "auggie",
+				Type:    "auggie",
+				Version: "gpt-5-4",
+			})
+
+			cfg := &sdkconfig.SDKConfig{ParameterPolicy: sdkconfig.ParameterPolicyConfig{Default: tt.policy}}
+			base := handlers.NewBaseAPIHandlers(cfg, manager)
+			h := NewOpenAIAPIHandler(base)
+			router := gin.New()
+			router.POST("/v1/chat/completions", h.ChatCompletions)
+
+			body := `{"model":"gpt-5-4","messages":[{"role":"user","content":"hello"}],"temperature":0.2,"response_format":{"type":"json_object"}}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body=%s", resp.Code, tt.wantStatus, resp.Body.String())
+			}
+			if got := resp.Header().Get(handlers.DroppedParamsHeader); got != tt.wantHeader {
+				t.Fatalf("%s = %q, want %q", handlers.DroppedParamsHeader, got, tt.wantHeader)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if executor.executeCalls != 0 {
+					t.Fatalf("execute calls = %d, want 0", executor.executeCalls)
+				}
+				return
+			}
+			for _, field := range []string{"temperature", "response_format"} {
+				if gjson.GetBytes(executor.lastPayload, field).Exists() {
+					t.Fatalf("executor payload still has %s: %s", field, executor.lastPayload)
+				}
+			}
+		})
+	}
+}
+
 func TestChatCompletions_RejectsNonIntegerNBeforeExecution(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// ParameterSupport describes how a provider surface handles a request parameter.
+type ParameterSupport string
+
+const (
+	// ParameterSupportNative means the parameter reaches the provider unchanged or translated losslessly.
+	ParameterSupportNative ParameterSupport = "native"
+	// ParameterSupportEmulated means the proxy enforces the parameter itself.
+	ParameterSupportEmulated ParameterSupport = "emulated"
+	// ParameterSupportPartial means only some values of the parameter are accepted.
+	ParameterSupportPartial ParameterSupport = "partial"
+	// ParameterSupportUnsupported means the parameter is rejected under the strict policy.
+	ParameterSupportUnsupported ParameterSupport = "unsupported"
+	// ParameterSupportIgnored means the parameter is accepted but never reaches the provider.
+	ParameterSupportIgnored ParameterSupport = "ignored"
+)
+
+// API surfaces described by the capability matrix.
+const (
+	SurfaceChatCompletions = "chat.completions"
+	SurfaceResponses       = "responses"
+	SurfaceMessages        = "messages"
+)
+
+// ParameterCapability is one cell of the capability matrix.
+type ParameterCapability struct {
+	Support ParameterSupport `json:"support"`
+	Note    string           `json:"note,omitempty"`
+}
+
+// SurfaceCapabilities lists parameter support for one API surface of a provider.
+type SurfaceCapabilities struct {
+	Surface    string                         `json:"surface"`
+	Parameters map[string]ParameterCapability `json:"parameters"`
+}
+
+// ProviderCapabilities lists the surfaces of one provider. Parameters that are not listed
+// are forwarded natively.
+type ProviderCapabilities struct {
+	Provider string                `json:"provider"`
+	Surfaces []SurfaceCapabilities `json:"surfaces"`
+}
+
+// CapabilityMatrix is the machine-readable view of which parameters each provider surface honors,
+// together with the parameter policy applied to the ones it does not.
+type CapabilityMatrix struct {
+	DefaultSupport  ParameterSupport       `json:"default-support"`
+	ParameterPolicy ParameterPolicySummary `json:"parameter-policy"`
+	Providers       []ProviderCapabilities `json:"providers"`
+}
+
+// ParameterPolicySummary reports the configured parameter policy. Client keys may override it.
+type ParameterPolicySummary struct {
+	Default string            `json:"default"`
+	Models  map[string]string `json:"models,omitempty"`
+}
+
+// BuildCapabilityMatrix returns the capability matrix for cfg. Parameters that cfg opts into
+// proxy-side emulation are reported as emulated instead of unsupported.
+func BuildCapabilityMatrix(cfg *config.SDKConfig) CapabilityMatrix {
+	matrix := CapabilityMatrix{
+		DefaultSupport:  ParameterSupportNative,
+		ParameterPolicy: ParameterPolicySummary{Default: config.ParameterPolicyStrict},
+	}
+	var emulation config.ParameterEmulationConfig
+	structured := false
+	if cfg != nil {
+		if policy := normalizeParameterPolicy(cfg.ParameterPolicy.Default); policy != "" {
+			matrix.ParameterPolicy.Default = policy
+		}
+		if len(cfg.ParameterPolicy.Models) > 0 {
+			matrix.ParameterPolicy.Models = make(map[string]string, len(cfg.ParameterPolicy.Models))
+			for model, policy := range cfg.ParameterPolicy.Models {
+				matrix.ParameterPolicy.Models[model] = strings.ToLower(strings.TrimSpace(policy))
+			}
+		}
+		emulation = cfg.ParameterEmulation["auggie"]
+		structured = cfg.StructuredOutput.Emulate
+	}
+	matrix.Providers = []ProviderCapabilities{
+		auggieCapabilities(emulation, structured),
+		antigravityCapabilities(),
+	}
+	return matrix
+}
+
+// ValidateSurfaceParameters rejects the first parameter of payload that a provider of the route
+// marks unsupported on surface. The error has the OpenAI unsupported_parameter shape so that
+// ApplyParameterPolicy can drop the parameter under the lenient and warn policies.
+func (h *BaseAPIHandler) ValidateSurfaceParameters(surface string, providers []string, payload []byte) *interfaces.ErrorMessage {
+	if len(providers) == 0 || len(payload) == 0 {
+		return nil
+	}
+	var cfg *config.SDKConfig
+	if h != nil {
+		cfg = h.Cfg
+	}
+	matrix := BuildCapabilityMatrix(cfg)
+	for _, provider := range providers {
+		parameters := matrix.surfaceParameters(provider, surface)
+		names := make([]string, 0, len(parameters))
+		for name, capability := range parameters {
+			if capability.Support == ParameterSupportUnsupported {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if value := gjson.GetBytes(payload, name); !value.Exists() || value.Type == gjson.Null {
+				continue
+			}
+			message := fmt.Sprintf("%s is not supported by %s on %s: %s", name, provider, surface, parameters[name].Note)
+			return unsupportedParameterError(message, name)
+		}
+	}
+	return nil
+}
+
+func (m CapabilityMatrix) surfaceParameters(provider, surface string) map[string]ParameterCapability {
+	for _, entry := range m.Providers {
+		if !strings.EqualFold(entry.Provider, strings.TrimSpace(provider)) {
+			continue
+		}
+		for _, candidate := range entry.Surfaces {
+			if candidate.Surface == surface {
+				return candidate.Parameters
+			}
+		}
+	}
+	return nil
+}
+
+func unsupportedParameterError(message, param string) *interfaces.ErrorMessage {
+	body, err := json.Marshal(map[string]any{"error": map[string]any{
+		"message": message,
+		"type":    "invalid_request_error",
+		"param":   param,
+		"code":    "unsupported_parameter",
+	}})
+	if err != nil {
+		return &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: errors.New(message)}
+	}
+	return &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: errors.New(string(body))}
+}
+
+func auggieCapabilities(emulation config.ParameterEmulationConfig, structured bool) ProviderCapabilities {
+	unsupported := func(note string) ParameterCapability {
+		return ParameterCapability{Support: ParameterSupportUnsupported, Note: note}
+	}
+	partial := func(note string) ParameterCapability {
+		return ParameterCapability{Support: ParameterSupportPartial, Note: note}
+	}
+	emulatedIf := func(enabled bool, emulatedNote, unsupportedNote string) ParameterCapability {
+		if enabled {
+			return ParameterCapability{Support: ParameterSupportEmulated, Note: emulatedNote}
+		}
+		return unsupported(unsupportedNote)
+	}
+
+	stop := emulatedIf(emulation.Stop, "truncated at the first stop sequence by the proxy", "enable parameter-emulation.auggie.stop")
+	maxTokens := emulatedIf(emulation.MaxTokens, "counted with the local tokenizer; output is cut off at the limit", "enable parameter-emulation.auggie.max-tokens")
+	n := emulatedIf(emulation.N, "fanned out to parallel upstream calls", "enable parameter-emulation.auggie.n")
+	format := emulatedIf(structured, "json_schema and json_object are validated locally", "only text is accepted unless structured-output.emulate is enabled")
+	if !structured {
+		format.Support = ParameterSupportPartial
+	}
+
+	chat := map[string]ParameterCapability{
+		"temperature":            unsupported("sampling controls"),
+		"top_p":                  unsupported("sampling controls"),
+		"frequency_penalty":      unsupported("penalty controls"),
+		"presence_penalty":       unsupported("penalty controls"),
+		"logit_bias":             unsupported("token logit bias controls"),
+		"logprobs":               unsupported("log probability controls"),
+		"top_logprobs":           unsupported("log probability controls"),
+		"seed":                   unsupported("deterministic sampling"),
+		"service_tier":           unsupported("service tier controls"),
+		"prompt_cache_key":       unsupported("prompt cache controls"),
+		"prompt_cache_retention": unsupported("prompt cache controls"),
+		"safety_identifier":      unsupported("end-user identifiers"),
+		"user":                   unsupported("end-user identifiers"),
+		"audio":                  unsupported("audio output"),
+		"prediction":             unsupported("predicted outputs"),
+		"verbosity":              unsupported("verbosity controls"),
+		"web_search_options":     unsupported("web search activation"),
+		"stream_options":         unsupported("streaming controls"),
+		"modalities":             partial("text only"),
+		"reasoning_effort":       partial("low, medium or high"),
+		"tools":                  partial("function tools only"),
+		"tool_choice":            partial("auto, none or allowed_tools with mode auto"),
+		"response_format":        format,
+		"stop":                   stop,
+		"max_tokens":             maxTokens,
+		"max_completion_tokens":  maxTokens,
+		"n":                      n,
+	}
+	responses := map[string]ParameterCapability{
+		"temperature":        unsupported("sampling controls"),
+		"top_p":              unsupported("sampling controls"),
+		"top_logprobs":       unsupported("log probability controls"),
+		"max_tokens":         unsupported("not a Responses parameter"),
+		"service_tier":       unsupported("service tier controls"),
+		"prompt":             unsupported("prompt template references"),
+		"context_management": unsupported("use /v1/responses/compact"),
+		"truncation":         partial("disabled only"),
+		"include":            partial("expanded include shapes are rejected"),
+		"reasoning.effort":   partial("low, medium or high"),
+		"tool_choice":        partial("auto, none or allowed_tools with mode auto"),
+		"text.format":        format,
+		"max_output_tokens":  maxTokens,
+	}
+	// Claude messages are bridged through chat completions, so the chat rules apply to the
+	// fields both formats share. max_tokens is required by the Messages API and is therefore
+	// accepted even when it cannot be enforced.
+	messagesMaxTokens := maxTokens
+	if !emulation.MaxTokens {
+		messagesMaxTokens = partial("accepted but not enforced; enable parameter-emulation.auggie.max-tokens")
+	}
+	messages := map[string]ParameterCapability{
+		"temperature":    unsupported("sampling controls"),
+		"top_p":          unsupported("sampling controls"),
+		"top_k":          unsupported("sampling controls"),
+		"stop_sequences": stop,
+		"max_tokens":     messagesMaxTokens,
+	}
+
+	return ProviderCapabilities{
+		Provider: "auggie",
+		Surfaces: []SurfaceCapabilities{
+			{Surface: SurfaceChatCompletions, Parameters: chat},
+			{Surface: SurfaceResponses, Parameters: responses},
+			{Surface: SurfaceMessages, Parameters: messages},
+		},
+	}
+}
+
+// antigravityCapabilities describes the Antigravity translators, which map the generation
+// controls Gemini understands and drop the rest without an error.
+func antigravityCapabilities() ProviderCapabilities {
+	partial := func(note string) ParameterCapability {
+		return ParameterCapability{Support: ParameterSupportPartial, Note: note}
+	}
+	ignored := func(note string) ParameterCapability {
+		return ParameterCapability{Support: ParameterSupportIgnored, Note: note}
+	}
+
+	chat := map[string]ParameterCapability{
+		"stop":                  ignored("no stop sequence mapping"),
+		"max_completion_tokens": ignored("use max_tokens"),
+		"frequency_penalty":     ignored("penalty controls"),
+		"presence_penalty":      ignored("penalty controls"),
+		"logit_bias":            ignored("token logit bias controls"),
+		"logprobs":              ignored("log probability controls"),
+		"top_logprobs":          ignored("log probability controls"),
+		"seed":                  ignored("deterministic sampling"),
+		"response_format":       ignored("structured output controls"),
+		"tool_choice":           ignored("tool choice controls"),
+		"parallel_tool_calls":   ignored("tool choice controls"),
+		"stream_options":        ignored("streaming controls"),
+		"modalities":            partial("text and image"),
+		"tools":                 partial("function tools plus google_search, code_execution and url_context"),
+	}
+	responses := map[string]ParameterCapability{
+		"top_logprobs":        ignored("log probability controls"),
+		"max_tool_calls":      ignored("tool-call budget controls"),
+		"truncation":          ignored("context management controls"),
+		"tool_choice":         ignored("tool choice controls"),
+		"text.format":         ignored("structured output controls"),
+		"parallel_tool_calls": ignored("tool choice controls"),
+	}
+	messages := map[string]ParameterCapability{
+		"stop_sequences": ignored("no stop sequence mapping"),
+		"tool_choice":    ignored("tool choice controls"),
+	}
+
+	return ProviderCapabilities{
+		Provider: "antigravity",
+		Surfaces: []SurfaceCapabilities{
+			{Surface: SurfaceChatCompletions, Parameters: chat},
+			{Surface: SurfaceResponses, Parameters: responses},
+			{Surface: SurfaceMessages, Parameters: messages},
+		},
+	}
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// DroppedParamsHeader lists the request parameters removed under the warn parameter policy.
+const DroppedParamsHeader = "X-Cliproxy-Dropped-Params"
+
+const (
+	droppedParamsContextKey = "parameterPolicyDropped"
+
+	// maxParameterPolicyDrops bounds the validate/drop loop so a validator that keeps
+	// reporting the same field cannot spin forever.
+	maxParameterPolicyDrops = 32
+)
+
+// parameterPolicyProtectedRoots are never dropped because they carry the conversation itself.
+var parameterPolicyProtectedRoots = map[string]struct{}{
+	"model":                {},
+	"messages":             {},
+	"input":                {},
+	"instructions":         {},
+	"system":               {},
+	"contents":             {},
+	"systemInstruction":    {},
+	"previous_response_id": {},
+	"conversation":         {},
+}
+
+var parameterPolicyIndexPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// ParameterPolicy returns the effective parameter policy for model on the current request.
+// A client-api-keys override wins over a parameter-policy.models rule, which wins over the default.
+func (h *BaseAPIHandler) ParameterPolicy(ctx context.Context, model string) string {
+	if ctx != nil {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+			if policy := normalizeParameterPolicy(getStringContextValue(ginCtx, AccessParameterPolicyContextKey)); policy != "" {
+				return policy
+			}
+		}
+	}
+	if h == nil || h.Cfg == nil {
+		return config.ParameterPolicyStrict
+	}
+	cfg := h.Cfg.ParameterPolicy
+	if len(cfg.Models) > 0 {
+		baseModel := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+		for _, name := range []string{model, baseModel} {
+			if normalized := normalizeParameterPolicy(cfg.Models[name]); normalized != "" {
+				return normalized
+			}
+		}
+		for _, pattern := range sortedPolicyModelPatterns(cfg.Models) {
+			trimmed := strings.TrimSpace(pattern)
+			matched := strings.EqualFold(trimmed, model) || strings.EqualFold(trimmed, baseModel)
+			if !matched && strings.Contains(trimmed, "*") {
+				matched = matchPolicyModelPattern(trimmed, model) || matchPolicyModelPattern(trimmed, baseModel)
+			}
+			if !matched {
+				continue
+			}
+			if normalized := normalizeParameterPolicy(cfg.Models[pattern]); normalized != "" {
+				return normalized
+			}
+		}
+	}
+	if policy := normalizeParameterPolicy(cfg.Default); policy != "" {
+		return policy
+	}
+	return config.ParameterPolicyStrict
+}
+
+// sortedPolicyModelPatterns orders the parameter-policy.models keys so the first match is the most
+// specific: exact names before wildcard patterns, then patterns with the longest literal part.
+// Remaining ties are broken alphabetically so the result never depends on map iteration order.
+func sortedPolicyModelPatterns(models map[string]string) []string {
+	patterns := make([]string, 0, len(models))
+	for pattern := range models {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		a, b := strings.TrimSpace(patterns[i]), strings.TrimSpace(patterns[j])
+		aWild, bWild := strings.Contains(a, "*"), strings.Contains(b, "*")
+		if aWild != bWild {
+			return !aWild
+		}
+		aLiteral, bLiteral := len(a)-strings.Count(a, "*"), len(b)-strings.Count(b, "*")
+		if aLiteral != bLiteral {
+			return aLiteral > bLiteral
+		}
+		return a < b
+	})
+	return patterns
+}
+
+// ApplyParameterPolicy runs validate against payload and, unless the policy is strict, drops each
+// parameter it reports as unsupported and validates again. It returns the payload to forward, or
+// the first error that cannot be resolved by dropping a parameter.
+func (h *BaseAPIHandler) ApplyParameterPolicy(ctx context.Context, model string, payload []byte, validate func([]byte) *interfaces.ErrorMessage) ([]byte, *interfaces.ErrorMessage) {
+	errMsg := validate(payload)
+	if errMsg == nil || h.ParameterPolicy(ctx, model) == config.ParameterPolicyStrict {
+		return payload, errMsg
+	}
+	for attempt := 0; errMsg != nil && attempt < maxParameterPolicyDrops; attempt++ {
+		next, ok := h.dropRejectedParameter(ctx, model, payload, errMsg.StatusCode, errMsg.Error)
+		if !ok {
+			break
+		}
+		payload = next
+		errMsg = validate(payload)
+	}
+	return payload, errMsg
+}
+
+// dropRejectedParameter removes the parameter named by an unsupported-parameter error from payload
+// and records it on the request. It reports false when the error is not about an optional parameter
+// the client sent, the policy is strict, or the parameter is not present in payload.
+func (h *BaseAPIHandler) dropRejectedParameter(ctx context.Context, model string, payload []byte, status int, err error) ([]byte, bool) {
+	if status != http.StatusBadRequest || err == nil || len(payload) == 0 {
+		return payload, false
+	}
+	policy := h.ParameterPolicy(ctx, model)
+	if policy == config.ParameterPolicyStrict {
+		return payload, false
+	}
+	param, ok := droppableParameter(err.Error())
+	if !ok {
+		return payload, false
+	}
+	path := parameterPolicyPath(param)
+	if path == "" || !gjson.GetBytes(payload, path).Exists() {
+		return payload, false
+	}
+	next, errDelete := sjson.DeleteBytes(payload, path)
+	if errDelete != nil {
+		return payload, false
+	}
+	h.recordDroppedParameter(ctx, policy, model, path)
+	return next, true
+}
+
+// droppableParameter extracts the param of an OpenAI-style error body that rejects a parameter as
+// unsupported. Type and range errors are not droppable: the client sent a broken value, not an
+// unsupported feature.
+func droppableParameter(body string) (string, bool) {
+	parsed := gjson.Parse(strings.TrimSpace(body))
+	param := strings.TrimSpace(parsed.Get("error.param").String())
+	if param == "" {
+		return "", false
+	}
+	switch parsed.Get("error.code").String() {
+	case "unsupported_parameter":
+	case "invalid_value":
+		message := parsed.Get("error.message").String()
+		if !strings.Contains(message, "not supported") {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	return param, true
+}
+
+// parameterPolicyPath converts an error param such as "tools[2].type" into the gjson path of the
+// field to drop. A trailing ".type" drops the enclosing object, because removing only the
+// discriminator would leave an invalid shape behind.
+func parameterPolicyPath(param string) string {
+	path := parameterPolicyIndexPattern.ReplaceAllString(param, ".$1")
+	path = strings.TrimSuffix(path, ".type")
+	root, _, _ := strings.Cut(path, ".")
+	if _, protected := parameterPolicyProtectedRoots[root]; protected || path == "" {
+		return ""
+	}
+	return path
+}
+
+// recordDroppedParameter remembers a dropped parameter on the gin context. Under the warn policy it
+// also refreshes the dropped-params response header, which the request log captures as well.
+func (h *BaseAPIHandler) recordDroppedParameter(ctx context.Context, policy, model, path string) {
+	var ginCtx *gin.Context
+	if ctx != nil {
+		ginCtx, _ = ctx.Value("gin").(*gin.Context)
+	}
+	if policy != config.ParameterPolicyWarn {
+		log.Debugf("parameter policy %s: dropped %s for model %s", policy, path, model)
+		return
+	}
+	log.Warnf("parameter policy warn: dropped unsupported parameter %s for model %s", path, model)
+	if ginCtx == nil {
+		return
+	}
+	var dropped []string
+	if existing, ok := ginCtx.Get(droppedParamsContextKey); ok {
+		dropped, _ = existing.([]string)
+	}
+	for _, item := range dropped {
+		if item == path {
+			return
+		}
+	}
+	dropped = append(dropped, path)
+	ginCtx.Set(droppedParamsContextKey, dropped)
+	ginCtx.Header(DroppedParamsHeader, strings.Join(dropped, ","))
+}
+
+func normalizeParameterPolicy(value string) string {
+	switch policy := strings.ToLower(strings.TrimSpace(value)); policy {
+	case config.ParameterPolicyStrict, config.ParameterPolicyLenient, config.ParameterPolicyWarn:
+		return policy
+	}
+	return ""
+}
+
+// matchPolicyModelPattern performs case-insensitive matching where '*' matches any substring.
+func matchPolicyModelPattern(pattern, value string) bool {
+	pattern = strings.ToLower(pattern)
+	value = strings.ToLower(value)
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(value, part)
+		if idx < 0 {
+			return false
+		}
+		value = value[idx+len(part):]
+	}
+	return strings.HasSuffix(value, last)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"golang.org/x/net/context"
+)
+
+func TestParameterPolicyPrecedence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{ParameterPolicy: config.ParameterPolicyConfig{
+		Default: "lenient",
+		Models:  map[string]string{"gpt-5*": "warn", "gpt-5-mini": "strict"},
+	}}}
+
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+
+	for model, want := range map[string]string{
+		"claude-sonnet-4-6": config.ParameterPolicyLenient,
+		"gpt-5.4":           config.ParameterPolicyWarn,
+		"gpt-5-mini":        config.ParameterPolicyStrict,
+		"gpt-5-mini(high)":  config.ParameterPolicyStrict,
+	} {
+		if got := h.ParameterPolicy(ctx, model); got != want {
+			t.Fatalf("ParameterPolicy(%q) = %q, want %q", model, got, want)
+		}
+	}
+
+	ginCtx.Set(AccessParameterPolicyContextKey, "strict")
+	if got := h.ParameterPolicy(ctx, "gpt-5.4"); got != config.ParameterPolicyStrict {
+		t.Fatalf("client key override = %q, want strict", got)
+	}
+}
+
+func TestParameterPolicyPath(t *testing.T) {
+	for param, want := range map[string]string{
+		"temperature":          "temperature",
+		"response_format.type": "response_format",
+		"tools[2].type":        "tools.2",
+		"include[0]":           "include.0",
+		"reasoning.effort":     "reasoning.effort",
+		"messages[1].content":  "",
+		"input[0].type":        "",
+	} {
+		if got := parameterPolicyPath(param); got != want {
+			t.Fatalf("parameterPolicyPath(%q) = %q, want %q", param, got, want)
+		}
+	}
+}
+
+func TestDroppableParameterRequiresUnsupportedError(t *testing.T) {
+	if param, ok := droppableParameter(`{"error":{"message":"service_tier is not supported on the selected route","param":"service_tier","code":"invalid_value"}}`); !ok || param != "service_tier" {
+		t.Fatalf("droppableParameter = %q, %t; want service_tier", param, ok)
+	}
+	if _, ok := droppableParameter(`{"error":{"message":"temperature must be a number between 0 and 2","param":"temperature","code":"invalid_value"}}`); ok {
+		t.Fatal("range errors must not be droppable")
+	}
+	if _, ok := droppableParameter(`{"error":{"message":"Invalid type for 'n'","param":"n","code":"invalid_type"}}`); ok {
+		t.Fatal("type errors must not be droppable")
+	}
+}
+
+func TestBuildCapabilityMatrixReflectsEmulation(t *testing.T) {
+	matrix := BuildCapabilityMatrix(&config.SDKConfig{
+		ParameterEmulation: map[string]config.ParameterEmulationConfig{"auggie": {Stop: true}},
+	})
+	if matrix.ParameterPolicy.Default != config.ParameterPolicyStrict {
+		t.Fatalf("default policy = %q, want strict", matrix.ParameterPolicy.Default)
+	}
+	chat := matrix.Providers[0].Surfaces[0]
+	if chat.Surface != "chat.completions" {
+		t.Fatalf("first surface = %q", chat.Surface)
+	}
+	if got := chat.Parameters["stop"].Support; got != ParameterSupportEmulated {
+		t.Fatalf("stop support = %q, want emulated", got)
+	}
+	if got := chat.Parameters["n"].Support; got != ParameterSupportUnsupported {
+		t.Fatalf("n support = %q, want unsupported", got)
+	}
+	messages := matrix.Providers[0].Surfaces[2]
+	if messages.Surface != SurfaceMessages || messages.Parameters["max_tokens"].Support != ParameterSupportPartial {
+		t.Fatalf("messages max_tokens = %+v, want partial", messages.Parameters["max_tokens"])
+	}
+	if len(matrix.Providers) < 2 || matrix.Providers[1].Provider != "antigravity" {
+		t.Fatalf("expected an antigravity entry, got %+v", matrix.Providers)
+	}
+}
+
+func TestValidateSurfaceParametersUsesMatrix(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{}}
+	payload := []byte(`{"model":"claude-sonnet-4-6","max_tokens":1024,"temperature":0.2,"messages":[]}`)
+
+	errMsg := h.ValidateSurfaceParameters(SurfaceMessages, []string{"auggie"}, payload)
+	if errMsg == nil {
+		t.Fatal("expected temperature to be rejected on the auggie messages surface")
+	}
+	if param, ok := droppableParameter(errMsg.Error.Error()); !ok || param != "temperature" {
+		t.Fatalf("droppableParameter = %q, %t; want temperature", param, ok)
+	}
+	if errMsg = h.ValidateSurfaceParameters(SurfaceMessages, []string{"antigravity", "claude"}, payload); errMsg != nil {
+		t.Fatalf("unexpected rejection on a route without unsupported parameters: %v", errMsg.Error)
+	}
+}
+
+func TestParameterPolicyOverlappingPatternsPreferLongestLiteral(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{ParameterPolicy: config.ParameterPolicyConfig{
+		Models: map[string]string{"gpt-*": "warn", "*-mini": "lenient", "gpt-5-*": "strict"},
+	}}}
+
+	// Repeat so a result that depends on map iteration order shows up as a flake.
+	for i := 0; i < 50; i++ {
+		for model, want := range map[string]string{
+			"gpt-4o-mini": config.ParameterPolicyLenient,
+			"gpt-5-mini":  config.ParameterPolicyStrict,
+			"gpt-4.1":     config.ParameterPolicyWarn,
+			"o4-mini":     config.ParameterPolicyLenient,
+		} {
+			if got := h.ParameterPolicy(context.Background(), model); got != want {
+				t.Fatalf("ParameterPolicy(%q) = %q, want %q", model, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// RedactedHeader reports redaction filter hits for a request as rule=count pairs.
+const RedactedHeader = "X-Cliproxy-Redacted"
+
+// redactionPlaceholderPrefix starts every placeholder, e.g. [REDACTED_EMAIL_1].
+const redactionPlaceholderPrefix = "[REDACTED_"
+
+// builtinRedactionRules are the secret detectors controlled by redaction.secret-action.
+var builtinRedactionRules = []config.RedactionRule{
+	{Name: "private-key", Pattern: `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`},
+	{Name: "jwt", Pattern: `\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`},
+	{Name: "api-key", Pattern: `\b(?:sk-[A-Za-z0-9_-]{20,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|AIza[0-9A-Za-z_-]{35}|xox[abposr]-[A-Za-z0-9-]{10,})`},
+}
+
+// redactionPatterns caches compiled rule patterns by source.
+var redactionPatterns sync.Map
+
+type redactionRule struct {
+	name    string
+	label   string
+	action  string
+	pattern *regexp.Regexp
+}
+
+// redactionSession holds the placeholders issued for one request.
+type redactionSession struct {
+	placeholders map[string]string
+	originals    map[string]string
+	counters     map[string]int
+}
+
+func compileRedactionRule(rule config.RedactionRule, action string) (redactionRule, bool) {
+	if cached, ok := redactionPatterns.Load(rule.Pattern); ok {
+		pattern, _ := cached.(*regexp.Regexp)
+		return redactionRule{name: rule.Name, label: redactionLabel(rule.Name), action: action, pattern: pattern}, pattern != nil
+	}
+	pattern, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		log.Warnf("redaction: rule %s has an invalid pattern: %v", rule.Name, err)
+		redactionPatterns.Store(rule.Pattern, (*regexp.Regexp)(nil))
+		return redactionRule{}, false
+	}
+	redactionPatterns.Store(rule.Pattern, pattern)
+	return redactionRule{name: rule.Name, label: redactionLabel(rule.Name), action: action, pattern: pattern}, true
+}
+
+func redactionLabel(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func (h *BaseAPIHandler) redactionRules() []redactionRule {
+	if h == nil || h.Cfg == nil || !h.Cfg.Redaction.Enabled {
+		return nil
+	}
+	var rules []redactionRule
+	secretAction := strings.ToLower(strings.TrimSpace(h.Cfg.Redaction.SecretAction))
+	if secretAction == "" {
+		secretAction = config.RedactionActionMask
+	}
+	if secretAction != config.RedactionActionOff {
+		for _, rule := range builtinRedactionRules {
+			if compiled, ok := compileRedactionRule(rule, secretAction); ok {
+				rules = append(rules, compiled)
+			}
+		}
+	}
+	for _, rule := range h.Cfg.Redaction.Rules {
+		name := strings.TrimSpace(rule.Name)
+		if name == "" || strings.TrimSpace(rule.Pattern) == "" {
+			continue
+		}
+		action := strings.ToLower(strings.TrimSpace(rule.Action))
+		if action == "" {
+			action = config.RedactionActionMask
+		}
+		rule.Name = name
+		if compiled, ok := compileRedactionRule(rule, action); ok {
+			rules = append(rules, compiled)
+		}
+	}
+	return rules
+}
+
+// applyRedaction scans every prompt string of payload with the redaction rules, masking or
+// rejecting matches. The returned session restores masked values in the model's output when
+// restore-output is enabled; it is nil otherwise.
+func (h *BaseAPIHandler) applyRedaction(ctx context.Context, handlerType string, payload []byte) ([]byte, *redactionSession, *interfaces.ErrorMessage) {
+	if ctx != nil && ctx.Value(contextCompactionSkipKey{}) != nil {
+		return payload, nil, nil
+	}
+	rules := h.redactionRules()
+	if len(rules) == 0 || len(payload) == 0 || !gjson.ValidBytes(payload) {
+		return payload, nil, nil
+	}
+
+	var targets []redactionTarget
+	collectRedactionTargets(gjson.ParseBytes(payload), "", &targets)
+	session := &redactionSession{
+		placeholders: make(map[string]string),
+		originals:    make(map[string]string),
+		counters:     make(map[string]int),
+	}
+	hits := make(map[string]int)
+	blockedBy := ""
+	for _, target := range targets {
+		text := target.value
+		for _, rule := range rules {
+			matches := len(rule.pattern.FindAllStringIndex(text, -1))
+			if matches == 0 {
+				continue
+			}
+			hits[rule.name] += matches
+			switch rule.action {
+			case config.RedactionActionBlock:
+				if blockedBy == "" {
+					blockedBy = rule.name
+				}
+			case config.RedactionActionMask:
+				text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+					return session.placeholder(rule.label, match)
+				})
+			}
+		}
+		if text == target.value || blockedBy != "" {
+			continue
+		}
+		if updated, err := sjson.SetBytes(payload, target.path, text); err == nil {
+			payload = updated
+		} else {
+			log.Warnf("redaction: failed to rewrite %s: %v", target.path, err)
+		}
+	}
+	if len(hits) == 0 {
+		return payload, nil, nil
+	}
+
+	rulesHit := make([]string, 0, len(hits))
+	for rule := range hits {
+		rulesHit = append(rulesHit, rule)
+	}
+	sort.Strings(rulesHit)
+	summary := make([]string, 0, len(rulesHit))
+	for _, rule := range rulesHit {
+		summary = append(summary, rule+"="+strconv.Itoa(hits[rule]))
+	}
+	usage.GetRequestStatistics().RecordRedactions(hits)
+	log.Infof("redaction: %s request matched %s", handlerType, strings.Join(summary, ","))
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(RedactedHeader, strings.Join(summary, ","))
+	}
+
+	if blockedBy != "" {
+		return nil, nil, &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("request blocked: content matched redaction rule %s", blockedBy),
+		}
+	}
+	if len(session.originals) == 0 || !h.Cfg.Redaction.RestoreOutput {
+		return payload, nil, nil
+	}
+	return payload, session, nil
+}
+
+type redactionTarget struct {
+	path  string
+	value string
+}
+
+// collectRedactionTargets lists the prompt strings of node with their sjson paths, skipping the
+// same identifier and binary fields as the guardrail token estimate.
+func collectRedactionTargets(node gjson.Result, path string, out *[]redactionTarget) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	switch {
+	case node.IsObject():
+		node.ForEach(func(key, value gjson.Result) bool {
+			if _, skip := guardrailSkippedKeys[key.String()]; skip {
+				return true
+			}
+			collectRedactionTargets(value, join(escapeRedactionPathKey(key.String())), out)
+			return true
+		})
+	case node.IsArray():
+		index := 0
+		node.ForEach(func(_, value gjson.Result) bool {
+			collectRedactionTargets(value, join(strconv.Itoa(index)), out)
+			index++
+			return true
+		})
+	case node.Type == gjson.String:
+		if node.Str != "" {
+			*out = append(*out, redactionTarget{path: path, value: node.Str})
+		}
+	}
+}
+
+func escapeRedactionPathKey(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		switch r {
+		case '.', '*', '?', '|', '#', '@', '\\', ':', '!':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *redactionSession) placeholder(label, value string) string {
+	if existing, ok := s.placeholders[value]; ok {
+		return existing
+	}
+	s.counters[label]++
+	placeholder := redactionPlaceholderPrefix + label + "_" + strconv.Itoa(s.counters[label]) + "]"
+	s.placeholders[value] = placeholder
+	s.originals[placeholder] = value
+	return placeholder
+}
+
+// restoreText replaces complete placeholders in decoded text.
+func (s *redactionSession) restoreText(text string) string {
+	if s == nil || !strings.Contains(text, redactionPlaceholderPrefix) {
+		return text
+	}
+	for placeholder, original := range s.originals {
+		text = strings.ReplaceAll(text, placeholder, original)
+	}
+	return text
+}
+
+// restoreJSON replaces complete placeholders inside the JSON strings of a response body.
+func (s *redactionSession) restoreJSON(data []byte) []byte {
+	if s == nil || !strings.Contains(string(data), redactionPlaceholderPrefix) {
+		return data
+	}
+	text := string(data)
+	for placeholder, original := range s.originals {
+		encoded, err := json.Marshal(original)
+		if err != nil {
+			continue
+		}
+		text = strings.ReplaceAll(text, placeholder, string(encoded[1:len(encoded)-1]))
+	}
+	return []byte(text)
+}
+
+// redactionStreamRestorer restores placeholders in streamed chunks. A placeholder split across
+// text deltas is held back until the delta completing it arrives. Held-back text is kept per
+// choice, content block or candidate and released when that one finishes.
+type redactionStreamRestorer struct {
+	session     *redactionSession
+	handlerType string
+	pending     map[string]string
+}
+
+func (s *redactionSession) streamRestorer(handlerType string) *redactionStreamRestorer {
+	if s == nil {
+		return nil
+	}
+	return &redactionStreamRestorer{session: s, handlerType: handlerType, pending: make(map[string]string)}
+}
+
+// restore rewrites one chunk. Claude and Responses streams are SSE-framed, which lets held-back
+// text be released as an extra delta event ahead of the event ending its block.
+func (r *redactionStreamRestorer) restore(chunk []byte) []byte {
+	if r == nil {
+		return chunk
+	}
+	if trimmed := strings.TrimSpace(string(chunk)); !strings.HasPrefix(trimmed, "data:") && !strings.HasPrefix(trimmed, "event:") {
+		event, _ := r.restoreEvent(chunk)
+		return event
+	}
+	lines := strings.Split(string(chunk), "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		data, ok := strings.CutPrefix(line, "data:")
+		trimmed := strings.TrimSpace(data)
+		if !ok || trimmed == "" || trimmed == "[DONE]" {
+			out = append(out, line)
+			continue
+		}
+		event, released := r.restoreEvent([]byte(trimmed))
+		if len(released) > 0 {
+			at := len(out)
+			if at > 0 && strings.HasPrefix(out[at-1], "event:") {
+				at--
+			}
+			var inserted []string
+			for _, extra := range released {
+				inserted = append(inserted, "event: "+gjson.GetBytes(extra, "type").String(), "data: "+string(extra), "")
+			}
+			out = append(out[:at], append(inserted, out[at:]...)...)
+		}
+		out = append(out, "data: "+string(event))
+	}
+	return []byte(strings.Join(out, "\n"))
+}
+
+// restoreEvent restores one event and returns any delta events that must precede it to
+// release text held back for a block the event ends.
+func (r *redactionStreamRestorer) restoreEvent(event []byte) ([]byte, [][]byte) {
+	event = r.session.restoreJSON(event)
+	if !gjson.ValidBytes(event) {
+		return event, nil
+	}
+	var released [][]byte
+	for _, slot := range streamTextSlots(r.handlerType, event) {
+		held := r.pending[slot.key]
+		delete(r.pending, slot.key)
+		if slot.path == "" {
+			if held == "" {
+				continue
+			}
+			if slot.flushPath != "" {
+				if updated, err := sjson.SetBytes(event, slot.flushPath, held); err == nil {
+					event = updated
+				}
+			} else if slot.flushEvent != nil {
+				released = append(released, slot.flushEvent(held))
+			}
+			continue
+		}
+		original := gjson.GetBytes(event, slot.path).String()
+		text := r.session.restoreText(held + original)
+		if start := partialPlaceholderStart(text); start >= 0 && !slot.final {
+			r.pending[slot.key] = text[start:]
+			text = text[:start]
+		}
+		if text == original {
+			continue
+		}
+		if updated, err := sjson.SetBytes(event, slot.path, text); err == nil {
+			event = updated
+		}
+	}
+	return event, released
+}
+
+// streamTextSlot is one choice, content block or candidate touched by a streamed event.
+type streamTextSlot struct {
+	key string
+	// path is the text delta in this event, empty when the event carries none.
+	path string
+	// final marks the event that ends the slot, after which no text may be held back.
+	final bool
+	// flushPath is where a final event without text receives held-back text; otherwise
+	// flushEvent builds a delta event carrying it.
+	flushPath  string
+	flushEvent func(text string) []byte
+}
+
+// streamTextSlots returns the slots of generated text in one streamed event.
+func streamTextSlots(handlerType string, event []byte) []streamTextSlot {
+	var slots []streamTextSlot
+	switch handlerType {
+	case constant.OpenAI:
+		gjson.GetBytes(event, "choices").ForEach(func(key, choice gjson.Result) bool {
+			index := key.String()
+			if value := choice.Get("index"); value.Exists() {
+				index = value.String()
+			}
+			slot := streamTextSlot{key: "choice:" + index, final: choice.Get("finish_reason").String() != ""}
+			if choice.Get("delta.content").Type == gjson.String {
+				slot.path = "choices." + key.String() + ".delta.content"
+			} else if slot.final {
+				slot.flushPath = "choices." + key.String() + ".delta.content"
+			} else {
+				return true
+			}
+			slots = append(slots, slot)
+			return true
+		})
+	case constant.OpenaiResponse:
+		root := gjson.ParseBytes(event)
+		key := "output:" + root.Get("output_index").String() + ":" + root.Get("content_index").String()
+		switch root.Get("type").String() {
+		case "response.output_text.delta":
+			slots = append(slots, streamTextSlot{key: key, path: "delta"})
+		case "response.output_text.done":
+			slots = append(slots, streamTextSlot{key: key, final: true, flushEvent: func(text string) []byte {
+				delta := []byte(`{"type":"response.output_text.delta"}`)
+				for _, field := range []string{"sequence_number", "item_id", "output_index", "content_index"} {
+					if value := root.Get(field); value.Exists() {
+						delta, _ = sjson.SetRawBytes(delta, field, []byte(value.Raw))
+					}
+				}
+				delta, _ = sjson.SetBytes(delta, "delta", text)
+				return delta
+			}})
+		}
+	case constant.Claude:
+		root := gjson.ParseBytes(event)
+		index := root.Get("index")
+		key := "block:" + index.String()
+		switch {
+		case root.Get("delta.type").String() == "text_delta":
+			slots = append(slots, streamTextSlot{key: key, path: "delta.text"})
+		case root.Get("type").String() == "content_block_stop":
+			slots = append(slots, streamTextSlot{key: key, final: true, flushEvent: func(text string) []byte {
+				raw := index.Raw
+				if raw == "" {
+					raw = "0"
+				}
+				delta, _ := sjson.SetRawBytes([]byte(`{"type":"content_block_delta","delta":{"type":"text_delta"}}`), "index", []byte(raw))
+				delta, _ = sjson.SetBytes(delta, "delta.text", text)
+				return delta
+			}})
+		}
+	case constant.Gemini, constant.GeminiCLI:
+		prefix := ""
+		if !gjson.GetBytes(event, "candidates").Exists() && gjson.GetBytes(event, "response.candidates").Exists() {
+			prefix = "response."
+		}
+		gjson.GetBytes(event, prefix+"candidates").ForEach(func(candidateKey, candidate gjson.Result) bool {
+			index := candidateKey.String()
+			if value := candidate.Get("index"); value.Exists() {
+				index = value.String()
+			}
+			key := "candidate:" + index
+			final := candidate.Get("finishReason").String() != ""
+			var textParts []string
+			candidate.Get("content.parts").ForEach(func(partKey, part gjson.Result) bool {
+				if part.Get("text").Type == gjson.String {
+					textParts = append(textParts, fmt.Sprintf("%scandidates.%s.content.parts.%s.text", prefix, candidateKey.String(), partKey.String()))
+				}
+				return true
+			})
+			for i, path := range textParts {
+				slots = append(slots, streamTextSlot{key: key, path: path, final: final && i == len(textParts)-1})
+			}
+			if final && len(textParts) == 0 {
+				slots = append(slots, streamTextSlot{key: key, final: true, flushPath: prefix + "candidates." + candidateKey.String() + ".content.parts.-1.text"})
+			}
+			return true
+		})
+	}
+	return slots
+}
+
+// partialPlaceholderStart returns where text ends with an incomplete placeholder, or -1.
+func partialPlaceholderStart(text string) int {
+	start := strings.LastIndexByte(text, '[')
+	if start < 0 {
+		return -1
+	}
+	tail := text[start:]
+	if len(tail) <= len(redactionPlaceholderPrefix) {
+		if strings.HasPrefix(redactionPlaceholderPrefix, tail) {
+			return start
+		}
+		return -1
+	}
+	if !strings.HasPrefix(tail, redactionPlaceholderPrefix) || len(tail) > 64 {
+		return -1
+	}
+	for _, r := range tail[len(redactionPlaceholderPrefix):] {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != '_' {
+			return -1
+		}
+	}
+	return start
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"github.com/tiktoken-go/tokenizer"
+	"golang.org/x/net/context"
+)
+
+// outputTokenPaths lists the request fields that cap generated tokens, per handler type.
+var outputTokenPaths = map[string][]string{
+	constant.OpenAI:         {"max_tokens", "max_completion_tokens"},
+	constant.OpenaiResponse: {"max_output_tokens"},
+	constant.Claude:         {"max_tokens"},
+	constant.Gemini:         {"generationConfig.maxOutputTokens"},
+	constant.GeminiCLI:      {"request.generationConfig.maxOutputTokens"},
+}
+
+// guardrailSkippedKeys are JSON keys whose string values carry no prompt text
+// (identifiers, enum values and inline binary data).
+var guardrailSkippedKeys = map[string]struct{}{
+	"model":        {},
+	"type":         {},
+	"role":         {},
+	"id":           {},
+	"call_id":      {},
+	"tool_call_id": {},
+	"tool_use_id":  {},
+	"data":         {},
+	"mime_type":    {},
+	"mimeType":     {},
+	"media_type":   {},
+	"detail":       {},
+	"signature":    {},
+}
+
+var (
+	guardrailCodecOnce sync.Once
+	guardrailCodec     tokenizer.Codec
+)
+
+// modelTokenLimits holds the registry limits relevant to pre-flight checks.
+type modelTokenLimits struct {
+	input  int
+	output int
+}
+
+func lookupModelTokenLimits(model string) modelTokenLimits {
+	baseModel := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+	if baseModel == "" {
+		baseModel = strings.TrimSpace(model)
+	}
+	info := registry.LookupModelInfo(baseModel)
+	if info == nil {
+		return modelTokenLimits{}
+	}
+	limits := modelTokenLimits{input: info.InputTokenLimit, output: info.MaxCompletionTokens}
+	if limits.input <= 0 {
+		limits.input = info.ContextLength
+	}
+	if limits.output <= 0 {
+		limits.output = info.OutputTokenLimit
+	}
+	return limits
+}
+
+// EstimateInputTokens approximates the prompt size of a request payload with the local
+// o200k tokenizer. Provider tokenizers differ, so the result is an estimate.
+func EstimateInputTokens(payload []byte) int {
+	if len(payload) == 0 || !gjson.ValidBytes(payload) {
+		return 0
+	}
+	guardrailCodecOnce.Do(func() {
+		codec, err := tokenizer.Get(tokenizer.O200kBase)
+		if err != nil {
+			log.Warnf("guardrails: tokenizer unavailable: %v", err)
+			return
+		}
+		guardrailCodec = codec
+	})
+	if guardrailCodec == nil {
+		return 0
+	}
+
+	var b strings.Builder
+	collectPromptText(gjson.ParseBytes(payload), &b)
+	text := strings.TrimSpace(b.String())
+	if text == "" {
+		return 0
+	}
+	count, err := guardrailCodec.Count(text)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+func collectPromptText(node gjson.Result, b *strings.Builder) {
+	switch {
+	case node.IsObject():
+		node.ForEach(func(key, value gjson.Result) bool {
+			if _, skip := guardrailSkippedKeys[key.String()]; skip {
+				return true
+			}
+			collectPromptText(value, b)
+			return true
+		})
+	case node.IsArray():
+		node.ForEach(func(_, value gjson.Result) bool {
+			collectPromptText(value, b)
+			return true
+		})
+	case node.Type == gjson.String:
+		text := node.String()
+		if text == "" || strings.HasPrefix(text, "data:") {
+			return
+		}
+		b.WriteString(text)
+		b.WriteByte('\n')
+	}
+}
+
+// applyRequestGuardrails runs the configured pre-flight checks for a request that is about to be
+// executed. It may swap the target model for a configured larger-context fallback and clamp output
+// token parameters; requests that cannot fit are rejected with a surface-specific error.
+func (h *BaseAPIHandler) applyRequestGuardrails(ctx context.Context, handlerType string, providers []string, model string, payload []byte) ([]string, string, []byte, *interfaces.ErrorMessage) {
+	if h == nil || h.Cfg == nil {
+		return providers, model, payload, nil
+	}
+	guard := h.Cfg.Guardrails
+	if !guard.ContextWindow && !guard.ClampOutputTokens {
+		return providers, model, payload, nil
+	}
+
+	limits := lookupModelTokenLimits(model)
+	if guard.ContextWindow && limits.input > 0 {
+		if estimated := EstimateInputTokens(payload); estimated > limits.input {
+			fallback := h.contextFallbackModel(model)
+			fallbackLimits := lookupModelTokenLimits(fallback)
+			if fallback == "" || (fallbackLimits.input > 0 && estimated > fallbackLimits.input) {
+				return nil, "", nil, contextLengthExceededError(handlerType, estimated, limits.input)
+			}
+			fallbackProviders, normalizedFallback, errMsg := h.getRequestDetailsForContext(ctx, fallback)
+			if errMsg != nil {
+				return nil, "", nil, contextLengthExceededError(handlerType, estimated, limits.input)
+			}
+			log.Infof("guardrails: prompt of ~%d tokens exceeds %s window (%d), routing to %s", estimated, model, limits.input, normalizedFallback)
+			if gjson.GetBytes(payload, "model").Exists() {
+				if updated, errSet := sjson.SetBytes(payload, "model", normalizedFallback); errSet == nil {
+					payload = updated
+				}
+			}
+			providers, model, limits = fallbackProviders, normalizedFallback, fallbackLimits
+		}
+	}
+
+	if guard.ClampOutputTokens && limits.output > 0 {
+		for _, path := range outputTokenPaths[handlerType] {
+			requested := gjson.GetBytes(payload, path)
+			if requested.Type != gjson.Number || requested.Int() <= int64(limits.output) {
+				continue
+			}
+			if updated, errSet := sjson.SetBytes(payload, path, limits.output); errSet == nil {
+				log.Debugf("guardrails: clamped %s from %d to %d for %s", path, requested.Int(), limits.output, model)
+				payload = updated
+			}
+		}
+	}
+	return providers, model, payload, nil
+}
+
+func (h *BaseAPIHandler) contextFallbackModel(model string) string {
+	fallbacks := h.Cfg.Guardrails.ContextFallbacks
+	if len(fallbacks) == 0 {
+		return ""
+	}
+	if fallback := strings.TrimSpace(fallbacks[model]); fallback != "" {
+		return fallback
+	}
+	baseModel := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+	return strings.TrimSpace(fallbacks[baseModel])
+}
+
+// contextLengthExceededError builds the rejection for an oversized prompt. OpenAI surfaces get the
+// full OpenAI error body (code context_length_exceeded); Claude and Gemini handlers wrap the plain
+// message into their native invalid-request shapes.
+func contextLengthExceededError(handlerType string, estimated, limit int) *interfaces.ErrorMessage {
+	message := fmt.Sprintf("This model's maximum context length is %d tokens. However, your request has about %d input tokens. Please reduce the length of the input.", limit, estimated)
+	switch handlerType {
+	case constant.OpenAI, constant.OpenaiResponse:
+		param := "messages"
+		if handlerType == constant.OpenaiResponse {
+			param = "input"
+		}
+		body, err := json.Marshal(ErrorResponse{Error: ErrorDetail{
+			Message: message,
+			Type:    "invalid_request_error",
+			Param:   param,
+			Code:    "context_length_exceeded",
+		}})
+		if err == nil {
+			message = string(body)
+		}
+	case constant.Claude:
+		message = fmt.Sprintf("prompt is too long: %d tokens > %d maximum", estimated, limit)
+	}
+	return &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: errors.New(message)}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func registerGuardrailTestModels(t *testing.T) {
+	t.Helper()
+	modelRegistry := registry.GetGlobalRegistry()
+	now := time.Now().Unix()
+	modelRegistry.RegisterClient("test-guardrails-auggie", "auggie", []*registry.ModelInfo{
+		{ID: "guard-small", Created: now, ContextLength: 50, MaxCompletionTokens: 100},
+		{ID: "guard-large", Created: now, ContextLength: 100000, MaxCompletionTokens: 4096},
+	})
+	t.Cleanup(func() { modelRegistry.UnregisterClient("test-guardrails-auggie") })
+}
+
+func guardrailTestPayload(model string, maxTokens int) []byte {
+	return []byte(`{"model":"` + model + `","max_tokens":` + strconv.Itoa(maxTokens) + `,"messages":[{"role":"user","content":"` + strings.Repeat("hello world ", 200) + `"}]}`)
+}
+
+func TestApplyRequestGuardrails_RejectsOversizedPromptPerSurface(t *testing.T) {
+	registerGuardrailTestModels(t)
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{Guardrails: sdkconfig.GuardrailsConfig{ContextWindow: true}}, coreauth.NewManager(nil, nil, nil))
+	providers := []string{"auggie"}
+
+	_, _, _, errMsg := handler.applyRequestGuardrails(context.Background(), "openai", providers, "guard-small", guardrailTestPayload("guard-small", 10))
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 rejection, got %#v", errMsg)
+	}
+	if code := gjson.Get(errMsg.Error.Error(), "error.code").String(); code != "context_length_exceeded" {
+		t.Fatalf("error.code = %q, want context_length_exceeded", code)
+	}
+
+	_, _, _, errMsg = handler.applyRequestGuardrails(context.Background(), "claude", providers, "guard-small", guardrailTestPayload("guard-small", 10))
+	if errMsg == nil || !strings.HasPrefix(errMsg.Error.Error(), "prompt is too long") {
+		t.Fatalf("expected Claude-style message, got %#v", errMsg)
+	}
+
+	_, _, _, errMsg = handler.applyRequestGuardrails(context.Background(), "openai", providers, "guard-large", guardrailTestPayload("guard-large", 10))
+	if errMsg != nil {
+		t.Fatalf("expected prompt to fit guard-large, got %v", errMsg.Error)
+	}
+}
+
+func TestApplyRequestGuardrails_FallbackAndClamp(t *testing.T) {
+	registerGuardrailTestModels(t)
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{Guardrails: sdkconfig.GuardrailsConfig{
+		ContextWindow:     true,
+		ClampOutputTokens: true,
+		ContextFallbacks:  map[string]string{"guard-small": "guard-large"},
+	}}, coreauth.NewManager(nil, nil, nil))
+
+	providers, model, payload, errMsg := handler.applyRequestGuardrails(context.Background(), "openai", []string{"auggie"}, "guard-small", guardrailTestPayload("guard-small", 50000))
+	if errMsg != nil {
+		t.Fatalf("unexpected rejection: %v", errMsg.Error)
+	}
+	if model != "guard-large" || len(providers) != 1 || providers[0] != "auggie" {
+		t.Fatalf("expected fallback to guard-large on auggie, got %q %v", model, providers)
+	}
+	if got := gjson.GetBytes(payload, "model").String(); got != "guard-large" {
+		t.Fatalf("payload model = %q, want guard-large", got)
+	}
+	if got := gjson.GetBytes(payload, "max_tokens").Int(); got != 4096 {
+		t.Fatalf("max_tokens = %d, want 4096", got)
+	}
+}
+
+func TestApplyRequestGuardrails_DisabledByDefault(t *testing.T) {
+	registerGuardrailTestModels(t)
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, coreauth.NewManager(nil, nil, nil))
+	raw := guardrailTestPayload("guard-small", 50000)
+	_, model, payload, errMsg := handler.applyRequestGuardrails(context.Background(), "openai", []string{"auggie"}, "guard-small", raw)
+	if errMsg != nil || model != "guard-small" || string(payload) != string(raw) {
+		t.Fatalf("expected request to pass through untouched")
+	}
+}
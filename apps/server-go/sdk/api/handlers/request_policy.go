@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// PolicyHeader lists the policy templates applied to a request, in the order they were applied.
+const PolicyHeader = "X-Cliproxy-Policy"
+
+// reasoningFieldPaths lists the request fields that select a reasoning effort, per handler type.
+var reasoningFieldPaths = map[string][]string{
+	constant.OpenAI:         {"reasoning_effort"},
+	constant.OpenaiResponse: {"reasoning.effort"},
+	constant.Claude:         {"thinking", "output_config.effort"},
+	constant.Gemini:         {"generationConfig.thinkingConfig"},
+	constant.GeminiCLI:      {"request.generationConfig.thinkingConfig"},
+}
+
+// requestPolicy is the combination of every policy template that applies to a request.
+type requestPolicy struct {
+	names                []string
+	stripSystem          bool
+	prepend              []string
+	append               []string
+	reasoningEffort      string
+	forceReasoningEffort bool
+	maxTokens            int
+	denyTools            []string
+}
+
+// requestPolicyFor collects the templates applying to the current request: those listed by the
+// client key first, then those whose models match model, each at most once.
+func (h *BaseAPIHandler) requestPolicyFor(ctx context.Context, model string) *requestPolicy {
+	if h == nil || h.Cfg == nil || len(h.Cfg.PolicyTemplates) == 0 || ctx == nil {
+		return nil
+	}
+	if ctx.Value(contextCompactionSkipKey{}) != nil {
+		return nil
+	}
+	byName := make(map[string]config.PolicyTemplate, len(h.Cfg.PolicyTemplates))
+	for _, template := range h.Cfg.PolicyTemplates {
+		byName[strings.TrimSpace(template.Name)] = template
+	}
+	var selected []config.PolicyTemplate
+	seen := make(map[string]bool)
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		for _, name := range strings.Split(getStringContextValue(ginCtx, AccessPoliciesContextKey), ",") {
+			name = strings.TrimSpace(name)
+			if template, exists := byName[name]; exists && !seen[name] {
+				seen[name] = true
+				selected = append(selected, template)
+			}
+		}
+	}
+	baseModel := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+	for _, template := range h.Cfg.PolicyTemplates {
+		name := strings.TrimSpace(template.Name)
+		if seen[name] {
+			continue
+		}
+		for _, pattern := range template.Models {
+			pattern = strings.TrimSpace(pattern)
+			if pattern != "" && (matchPolicyModelPattern(pattern, model) || matchPolicyModelPattern(pattern, baseModel)) {
+				seen[name] = true
+				selected = append(selected, template)
+				break
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	policy := &requestPolicy{}
+	for _, template := range selected {
+		policy.names = append(policy.names, strings.TrimSpace(template.Name))
+		policy.stripSystem = policy.stripSystem || template.StripSystem
+		if text := strings.TrimSpace(template.SystemPrepend); text != "" {
+			policy.prepend = append(policy.prepend, text)
+		}
+		if text := strings.TrimSpace(template.SystemAppend); text != "" {
+			policy.append = append(policy.append, text)
+		}
+		if effort := strings.TrimSpace(template.ReasoningEffort); effort != "" && policy.reasoningEffort == "" {
+			policy.reasoningEffort = strings.ToLower(effort)
+			policy.forceReasoningEffort = template.ForceReasoningEffort
+		}
+		if template.MaxTokens > 0 && (policy.maxTokens == 0 || template.MaxTokens < policy.maxTokens) {
+			policy.maxTokens = template.MaxTokens
+		}
+		for _, tool := range template.DenyTools {
+			if tool = strings.ToLower(strings.TrimSpace(tool)); tool != "" {
+				policy.denyTools = append(policy.denyTools, tool)
+			}
+		}
+	}
+	return policy
+}
+
+// applyRequestPolicy applies the policy templates for the request to payload before it is
+// translated. It returns the model to execute, which carries a thinking suffix when the policy
+// sets the reasoning effort, and rejects requests declaring a denied tool type.
+func (h *BaseAPIHandler) applyRequestPolicy(ctx context.Context, handlerType, model string, payload []byte) (string, []byte, *interfaces.ErrorMessage) {
+	policy := h.requestPolicyFor(ctx, model)
+	if policy == nil || len(payload) == 0 {
+		return model, payload, nil
+	}
+	if errMsg := policy.checkTools(handlerType, payload); errMsg != nil {
+		return model, nil, errMsg
+	}
+	payload = policy.applySystem(handlerType, payload)
+	model = policy.applyReasoningEffort(handlerType, model, payload)
+	payload = policy.applyMaxTokens(handlerType, payload)
+
+	log.Debugf("request policy: applied %s to %s request for %s", strings.Join(policy.names, ","), handlerType, model)
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(PolicyHeader, strings.Join(policy.names, ","))
+	}
+	return model, payload, nil
+}
+
+// CheckPolicyTools rejects a request declaring a tool type denied by its policy templates. Handlers
+// that rewrite tools before execution call it on the request as received.
+func (h *BaseAPIHandler) CheckPolicyTools(ctx context.Context, handlerType, model string, payload []byte) *interfaces.ErrorMessage {
+	policy := h.requestPolicyFor(ctx, model)
+	if policy == nil {
+		return nil
+	}
+	return policy.checkTools(handlerType, payload)
+}
+
+func (p *requestPolicy) checkTools(handlerType string, payload []byte) *interfaces.ErrorMessage {
+	if len(p.denyTools) == 0 {
+		return nil
+	}
+	for _, toolType := range requestToolTypes(handlerType, payload) {
+		for _, denied := range p.denyTools {
+			if toolType == denied || strings.HasPrefix(toolType, denied+"_") {
+				return &interfaces.ErrorMessage{
+					StatusCode: http.StatusForbidden,
+					Error:      fmt.Errorf("tool type %s is not allowed by policy %s", toolType, strings.Join(p.names, ",")),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// requestToolTypes returns the declared tool types of a request. Function tools are reported as
+// "function" on every surface; Gemini tool keys are reported in snake case.
+func requestToolTypes(handlerType string, payload []byte) []string {
+	var types []string
+	switch handlerType {
+	case constant.Gemini, constant.GeminiCLI:
+		path := "tools"
+		if handlerType == constant.GeminiCLI {
+			path = "request.tools"
+		}
+		gjson.GetBytes(payload, path).ForEach(func(_, tool gjson.Result) bool {
+			tool.ForEach(func(key, _ gjson.Result) bool {
+				switch name := key.String(); name {
+				case "functionDeclarations", "function_declarations":
+					types = append(types, "function")
+				case "googleSearchRetrieval", "google_search_retrieval":
+					types = append(types, "google_search")
+				default:
+					types = append(types, snakeCase(name))
+				}
+				return true
+			})
+			return true
+		})
+	default:
+		gjson.GetBytes(payload, "tools").ForEach(func(_, tool gjson.Result) bool {
+			toolType := strings.ToLower(strings.TrimSpace(tool.Get("type").String()))
+			if toolType == "" || toolType == "custom" && handlerType == constant.Claude {
+				toolType = "function"
+			}
+			types = append(types, toolType)
+			return true
+		})
+	}
+	return types
+}
+
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// applySystem strips, prepends and appends system instructions in the request's native shape.
+func (p *requestPolicy) applySystem(handlerType string, payload []byte) []byte {
+	if !p.stripSystem && len(p.prepend) == 0 && len(p.append) == 0 {
+		return payload
+	}
+	prepend := strings.Join(p.prepend, "\n\n")
+	appendText := strings.Join(p.append, "\n\n")
+	var err error
+	updated := payload
+	switch handlerType {
+	case constant.OpenAI:
+		updated, err = policyOpenAIMessages(payload, p.stripSystem, prepend, appendText)
+	case constant.OpenaiResponse:
+		updated, err = policyResponsesInstructions(payload, p.stripSystem, prepend, appendText)
+	case constant.Claude:
+		updated, err = policyClaudeSystem(payload, p.stripSystem, prepend, appendText)
+	case constant.Gemini:
+		updated, err = policyGeminiSystemInstruction(payload, "", p.stripSystem, prepend, appendText)
+	case constant.GeminiCLI:
+		updated, err = policyGeminiSystemInstruction(payload, "request.", p.stripSystem, prepend, appendText)
+	}
+	if err != nil {
+		log.Warnf("request policy: rewriting system instructions for %s failed, forwarding unchanged: %v", handlerType, err)
+		return payload
+	}
+	return updated
+}
+
+func joinNonEmpty(parts ...string) string {
+	kept := parts[:0:0]
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, "\n\n")
+}
+
+func policyOpenAIMessages(payload []byte, strip bool, prepend, appendText string) ([]byte, error) {
+	var messages []json.RawMessage
+	leading := 0
+	inLeading := true
+	for _, message := range gjson.GetBytes(payload, "messages").Array() {
+		system := isOpenAIInstructionRole(message.Get("role").String())
+		if !system {
+			inLeading = false
+		}
+		if system && strip {
+			continue
+		}
+		messages = append(messages, json.RawMessage(message.Raw))
+		if system && inLeading {
+			leading = len(messages)
+		}
+	}
+	systemMessage := func(text string) json.RawMessage {
+		raw, _ := json.Marshal(map[string]string{"role": "system", "content": text})
+		return raw
+	}
+	if appendText != "" {
+		messages = append(messages[:leading:leading], append([]json.RawMessage{systemMessage(appendText)}, messages[leading:]...)...)
+	}
+	if prepend != "" {
+		messages = append([]json.RawMessage{systemMessage(prepend)}, messages...)
+	}
+	raw, err := json.Marshal(messages)
+	if err != nil {
+		return nil, err
+	}
+	return sjson.SetRawBytes(payload, "messages", raw)
+}
+
+func policyResponsesInstructions(payload []byte, strip bool, prepend, appendText string) ([]byte, error) {
+	var err error
+	existing := gjson.GetBytes(payload, "instructions").String()
+	if strip {
+		existing = ""
+		if input := gjson.GetBytes(payload, "input"); input.IsArray() {
+			var kept []json.RawMessage
+			for _, item := range input.Array() {
+				if isOpenAIInstructionRole(item.Get("role").String()) {
+					continue
+				}
+				kept = append(kept, json.RawMessage(item.Raw))
+			}
+			raw, errMarshal := json.Marshal(kept)
+			if kept == nil {
+				raw = []byte("[]")
+			}
+			if errMarshal != nil {
+				return nil, errMarshal
+			}
+			if payload, err = sjson.SetRawBytes(payload, "input", raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+	instructions := joinNonEmpty(prepend, existing, appendText)
+	if instructions == "" {
+		return sjson.DeleteBytes(payload, "instructions")
+	}
+	return sjson.SetBytes(payload, "instructions", instructions)
+}
+
+func policyClaudeSystem(payload []byte, strip bool, prepend, appendText string) ([]byte, error) {
+	system := gjson.GetBytes(payload, "system")
+	if strip || !system.Exists() || system.Type == gjson.String {
+		existing := ""
+		if !strip {
+			existing = system.String()
+		}
+		text := joinNonEmpty(prepend, existing, appendText)
+		if text == "" {
+			return sjson.DeleteBytes(payload, "system")
+		}
+		return sjson.SetBytes(payload, "system", text)
+	}
+	textBlock := func(text string) json.RawMessage {
+		raw, _ := json.Marshal(map[string]string{"type": "text", "text": text})
+		return raw
+	}
+	var blocks []json.RawMessage
+	if prepend != "" {
+		blocks = append(blocks, textBlock(prepend))
+	}
+	for _, block := range system.Array() {
+		blocks = append(blocks, json.RawMessage(block.Raw))
+	}
+	if appendText != "" {
+		blocks = append(blocks, textBlock(appendText))
+	}
+	raw, err := json.Marshal(blocks)
+	if err != nil {
+		return nil, err
+	}
+	return sjson.SetRawBytes(payload, "system", raw)
+}
+
+func policyGeminiSystemInstruction(payload []byte, prefix string, strip bool, prepend, appendText string) ([]byte, error) {
+	key := prefix + "systemInstruction"
+	if !gjson.GetBytes(payload, key).Exists() && gjson.GetBytes(payload, prefix+"system_instruction").Exists() {
+		key = prefix + "system_instruction"
+	}
+	var parts []json.RawMessage
+	textPart := func(text string) json.RawMessage {
+		raw, _ := json.Marshal(map[string]string{"text": text})
+		return raw
+	}
+	if prepend != "" {
+		parts = append(parts, textPart(prepend))
+	}
+	if !strip {
+		for _, part := range gjson.GetBytes(payload, key+".parts").Array() {
+			parts = append(parts, json.RawMessage(part.Raw))
+		}
+	}
+	if appendText != "" {
+		parts = append(parts, textPart(appendText))
+	}
+	if len(parts) == 0 {
+		return sjson.DeleteBytes(payload, key)
+	}
+	raw, err := json.Marshal(parts)
+	if err != nil {
+		return nil, err
+	}
+	return sjson.SetRawBytes(payload, key+".parts", raw)
+}
+
+// applyReasoningEffort selects the policy's reasoning effort through the model's thinking suffix,
+// which every provider honors over request body settings. Without force it only applies when the
+// client chose no effort of its own.
+func (p *requestPolicy) applyReasoningEffort(handlerType, model string, payload []byte) string {
+	if p.reasoningEffort == "" {
+		return model
+	}
+	suffix := thinking.ParseSuffix(model)
+	if !p.forceReasoningEffort {
+		if suffix.HasSuffix {
+			return model
+		}
+		for _, path := range reasoningFieldPaths[handlerType] {
+			if gjson.GetBytes(payload, path).Exists() {
+				return model
+			}
+		}
+	}
+	return suffix.ModelName + "(" + p.reasoningEffort + ")"
+}
+
+// applyMaxTokens caps the output token parameters, setting the preferred one when none is present.
+func (p *requestPolicy) applyMaxTokens(handlerType string, payload []byte) []byte {
+	paths := outputTokenPaths[handlerType]
+	if p.maxTokens <= 0 || len(paths) == 0 {
+		return payload
+	}
+	present := false
+	for _, path := range paths {
+		requested := gjson.GetBytes(payload, path)
+		if !requested.Exists() {
+			continue
+		}
+		present = true
+		if requested.Type == gjson.Number && requested.Int() <= int64(p.maxTokens) {
+			continue
+		}
+		if updated, err := sjson.SetBytes(payload, path, p.maxTokens); err == nil {
+			payload = updated
+		}
+	}
+	if !present {
+		if updated, err := sjson.SetBytes(payload, paths[len(paths)-1], p.maxTokens); err == nil {
+			payload = updated
+		}
+	}
+	return payload
+}
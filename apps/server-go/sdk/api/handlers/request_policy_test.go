@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/context"
+)
+
+func newPolicyTestContext(t *testing.T, policies string) (context.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if policies != "" {
+		ginCtx.Set(AccessPoliciesContextKey, policies)
+	}
+	return context.WithValue(context.Background(), "gin", ginCtx), recorder
+}
+
+func TestApplyRequestPolicySystemInstructions(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{PolicyTemplates: []config.PolicyTemplate{
+		{Name: "house", SystemPrepend: "Be brief.", SystemAppend: "Cite sources."},
+		{Name: "strict", StripSystem: true},
+	}}}
+
+	ctx, recorder := newPolicyTestContext(t, "house")
+	_, payload, errMsg := h.applyRequestPolicy(ctx, constant.OpenAI, "gpt-5", []byte(`{"messages":[{"role":"system","content":"client"},{"role":"user","content":"hi"}]}`))
+	if errMsg != nil {
+		t.Fatalf("applyRequestPolicy() error = %v", errMsg.Error)
+	}
+	var contents []string
+	for _, message := range gjson.GetBytes(payload, "messages").Array() {
+		contents = append(contents, message.Get("content").String())
+	}
+	if got := len(contents); got != 4 || contents[0] != "Be brief." || contents[1] != "client" || contents[2] != "Cite sources." || contents[3] != "hi" {
+		t.Fatalf("messages = %v", contents)
+	}
+	if got := recorder.Header().Get(PolicyHeader); got != "house" {
+		t.Fatalf("%s = %q, want house", PolicyHeader, got)
+	}
+
+	ctx, _ = newPolicyTestContext(t, "strict,house")
+	_, payload, _ = h.applyRequestPolicy(ctx, constant.Claude, "claude-sonnet-4-6", []byte(`{"system":[{"type":"text","text":"client"}],"messages":[]}`))
+	if got := gjson.GetBytes(payload, "system").String(); got != "Be brief.\n\nCite sources." {
+		t.Fatalf("claude system = %q", got)
+	}
+
+	ctx, _ = newPolicyTestContext(t, "house")
+	_, payload, _ = h.applyRequestPolicy(ctx, constant.Gemini, "gemini-2.5-pro", []byte(`{"systemInstruction":{"parts":[{"text":"client"}]},"contents":[]}`))
+	parts := gjson.GetBytes(payload, "systemInstruction.parts.#.text").Array()
+	if len(parts) != 3 || parts[0].String() != "Be brief." || parts[1].String() != "client" || parts[2].String() != "Cite sources." {
+		t.Fatalf("gemini parts = %v", parts)
+	}
+}
+
+func TestApplyRequestPolicyDefaultsAndLimits(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{PolicyTemplates: []config.PolicyTemplate{
+		{Name: "cheap", Models: []string{"gpt-5*"}, ReasoningEffort: "low", MaxTokens: 1000, DenyTools: []string{"web_search"}},
+		{Name: "forced", ReasoningEffort: "minimal", ForceReasoningEffort: true, MaxTokens: 500},
+	}}}
+	ctx, _ := newPolicyTestContext(t, "")
+
+	model, payload, errMsg := h.applyRequestPolicy(ctx, constant.OpenaiResponse, "gpt-5.4", []byte(`{"input":"hi","max_output_tokens":4000}`))
+	if errMsg != nil {
+		t.Fatalf("applyRequestPolicy() error = %v", errMsg.Error)
+	}
+	if model != "gpt-5.4(low)" || gjson.GetBytes(payload, "max_output_tokens").Int() != 1000 {
+		t.Fatalf("model = %q, payload = %s", model, payload)
+	}
+	if model, _, _ = h.applyRequestPolicy(ctx, constant.OpenaiResponse, "gpt-5.4", []byte(`{"input":"hi","reasoning":{"effort":"high"}}`)); model != "gpt-5.4" {
+		t.Fatalf("client reasoning effort was overridden: %q", model)
+	}
+	_, _, errMsg = h.applyRequestPolicy(ctx, constant.OpenaiResponse, "gpt-5.4", []byte(`{"input":"hi","tools":[{"type":"web_search_preview"}]}`))
+	if errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("denied tool error = %+v, want 403", errMsg)
+	}
+	if model, _, _ = h.applyRequestPolicy(ctx, constant.Claude, "claude-sonnet-4-6", []byte(`{"messages":[]}`)); model != "claude-sonnet-4-6" {
+		t.Fatalf("unmatched model = %q", model)
+	}
+
+	ctx, _ = newPolicyTestContext(t, "forced")
+	model, payload, _ = h.applyRequestPolicy(ctx, constant.OpenAI, "gpt-5.4(high)", []byte(`{"messages":[],"max_completion_tokens":800}`))
+	if model != "gpt-5.4(minimal)" || gjson.GetBytes(payload, "max_completion_tokens").Int() != 500 {
+		t.Fatalf("model = %q, payload = %s", model, payload)
+	}
+	_, payload, _ = h.applyRequestPolicy(ctx, constant.Gemini, "gemini-2.5-pro", []byte(`{"contents":[]}`))
+	if got := gjson.GetBytes(payload, "generationConfig.maxOutputTokens").Int(); got != 500 {
+		t.Fatalf("gemini maxOutputTokens = %d, want 500", got)
+	}
+}
+
+func TestRequestToolTypes(t *testing.T) {
+	gemini := requestToolTypes(constant.Gemini, []byte(`{"tools":[{"functionDeclarations":[]},{"googleSearch":{}},{"codeExecution":{}}]}`))
+	if len(gemini) != 3 || gemini[0] != "function" || gemini[1] != "google_search" || gemini[2] != "code_execution" {
+		t.Fatalf("gemini tool types = %v", gemini)
+	}
+	claude := requestToolTypes(constant.Claude, []byte(`{"tools":[{"name":"lookup"},{"type":"web_search_20250305"}]}`))
+	if len(claude) != 2 || claude[0] != "function" || claude[1] != "web_search_20250305" {
+		t.Fatalf("claude tool types = %v", claude)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// SandboxEnabled reports whether the current request may run code_interpreter and shell tools
+// in the local sandbox. A per-key override from client-api-keys takes precedence over the global
+// configuration.
+func (h *BaseAPIHandler) SandboxEnabled(ctx context.Context) bool {
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		switch strings.ToLower(strings.TrimSpace(getStringContextValue(ginCtx, AccessSandboxContextKey))) {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+	}
+	return h.Cfg != nil && h.Cfg.Sandbox.Enabled
+}
+
+// RequestKeyLabel identifies the client key of the current request for audit records, using the
+// key's note when it has one and a masked key otherwise.
+func RequestKeyLabel(ctx context.Context) string {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return ""
+	}
+	if note := strings.TrimSpace(getStringContextValue(ginCtx, AccessKeyNoteContextKey)); note != "" {
+		return note
+	}
+	return util.HideAPIKey(getStringContextValue(ginCtx, "apiKey"))
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -27,6 +28,11 @@ type StreamForwardOptions struct {
 	// WriteKeepAlive optionally writes a keep-alive heartbeat. It should not flush.
 	// When nil, a standard SSE comment heartbeat is used.
 	WriteKeepAlive func()
+
+	// FirstChunk is written through WriteChunk before reading from the data channel. Handlers pass
+	// the chunk they peeked at before committing headers so it is numbered like the rest when the
+	// stream is resumable.
+	FirstChunk []byte
 }
 
 func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, opts StreamForwardOptions) {
@@ -61,11 +67,59 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 		keepAliveC = keepAlive.C
 	}
 
+	// When the request was made resumable, every event is captured, numbered and buffered, and
+	// a client disconnect only detaches the writer until the grace period runs out.
+	stream := ResumableStreamFromContext(c)
+	writer := c.Writer
+	attached := true
+	emit := func(write func()) {
+		if stream == nil {
+			write()
+			return
+		}
+		recorder := &streamFrameRecorder{ResponseWriter: writer}
+		c.Writer = recorder
+		write()
+		c.Writer = writer
+		if recorder.buf.Len() == 0 {
+			return
+		}
+		if framed := stream.append(recorder.buf.Bytes()); attached {
+			_, _ = writer.Write(framed)
+		}
+	}
+	flush := func() {
+		if attached {
+			flusher.Flush()
+		}
+	}
+	requestDone := c.Request.Context().Done()
+	var expired <-chan struct{}
+	if stream != nil {
+		stream.start()
+		expired = stream.expired
+	}
+
+	if opts.FirstChunk != nil {
+		emit(func() { writeChunk(opts.FirstChunk) })
+		flush()
+	}
+
 	var terminalErr *interfaces.ErrorMessage
 	for {
 		select {
-		case <-c.Request.Context().Done():
-			cancel(c.Request.Context().Err())
+		case <-requestDone:
+			if stream == nil {
+				cancel(c.Request.Context().Err())
+				return
+			}
+			attached = false
+			requestDone = nil
+			keepAliveC = nil
+			stream.detach()
+		case <-expired:
+			stream.finish(0)
+			cancel(context.Canceled)
 			return
 		case chunk, ok := <-data:
 			if !ok {
@@ -81,21 +135,23 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 				}
 				if terminalErr != nil {
 					if opts.WriteTerminalError != nil {
-						opts.WriteTerminalError(terminalErr)
+						emit(func() { opts.WriteTerminalError(terminalErr) })
 					}
-					flusher.Flush()
+					flush()
+					h.finishResumableStream(stream)
 					cancel(terminalErr.Error)
 					return
 				}
 				if opts.WriteDone != nil {
-					opts.WriteDone()
+					emit(opts.WriteDone)
 				}
-				flusher.Flush()
+				flush()
+				h.finishResumableStream(stream)
 				cancel(nil)
 				return
 			}
-			writeChunk(chunk)
-			flusher.Flush()
+			emit(func() { writeChunk(chunk) })
+			flush()
 		case errMsg, ok := <-errs:
 			if !ok {
 				continue
@@ -103,14 +159,15 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 			if errMsg != nil {
 				terminalErr = errMsg
 				if opts.WriteTerminalError != nil {
-					opts.WriteTerminalError(errMsg)
-					flusher.Flush()
+					emit(func() { opts.WriteTerminalError(errMsg) })
+					flush()
 				}
 			}
 			var execErr error
 			if errMsg != nil {
 				execErr = errMsg.Error
 			}
+			h.finishResumableStream(stream)
 			cancel(execErr)
 			return
 		case <-keepAliveC:
@@ -119,3 +176,10 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 		}
 	}
 }
+
+// finishResumableStream keeps a completed stream replayable for the grace period.
+func (h *BaseAPIHandler) finishResumableStream(stream *ResumableStream) {
+	if stream != nil {
+		stream.finish(stream.grace)
+	}
+}
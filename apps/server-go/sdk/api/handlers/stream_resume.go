@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+)
+
+const (
+	// StreamIDHeader carries the identifier of a resumable stream. The proxy sets it on streaming
+	// responses; a client sends it back together with LastEventIDHeader to resume that stream.
+	StreamIDHeader = "X-Cliproxy-Stream-Id"
+
+	// LastEventIDHeader is the standard SSE reconnect header naming the last event the client saw.
+	LastEventIDHeader = "Last-Event-ID"
+
+	resumableStreamContextKey = "resumableStream"
+	defaultResumeMaxEvents    = 4096
+)
+
+// ResumableStream buffers the SSE events of one streaming request so a client that lost its
+// connection can reconnect and replay them. Events are numbered from 1 and written with an
+// `id:` field. After the last listener disconnects the upstream keeps running for the grace
+// period; if nobody reconnects in time it is cancelled.
+type ResumableStream struct {
+	id        string
+	owner     string
+	grace     time.Duration
+	maxEvents int
+
+	mu          sync.Mutex
+	keys        []string
+	events      []resumableEvent
+	lastID      int64
+	done        bool
+	isExpired   bool
+	listeners   int
+	changed     chan struct{}
+	expired     chan struct{}
+	expireTimer *time.Timer
+}
+
+type resumableEvent struct {
+	id    int64
+	frame []byte
+}
+
+type resumableStreamRegistry struct {
+	mu      sync.Mutex
+	streams map[string]*ResumableStream
+}
+
+var defaultResumableStreams = &resumableStreamRegistry{streams: make(map[string]*ResumableStream)}
+
+// BeginResumableStream prepares the current streaming request for resumption when
+// streaming.resume-grace-seconds is enabled, and returns nil otherwise. It must be called
+// before GetContextWithCancel so the upstream context survives a client disconnect.
+func (h *BaseAPIHandler) BeginResumableStream(c *gin.Context) *ResumableStream {
+	if h == nil || h.Cfg == nil || c == nil || h.Cfg.Streaming.ResumeGraceSeconds <= 0 {
+		return nil
+	}
+	maxEvents := h.Cfg.Streaming.ResumeMaxEvents
+	if maxEvents <= 0 {
+		maxEvents = defaultResumeMaxEvents
+	}
+	stream := &ResumableStream{
+		id:        "strm_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		owner:     getStringContextValue(c, "apiKey"),
+		grace:     time.Duration(h.Cfg.Streaming.ResumeGraceSeconds) * time.Second,
+		maxEvents: maxEvents,
+		changed:   make(chan struct{}),
+		expired:   make(chan struct{}),
+	}
+	c.Set(resumableStreamContextKey, stream)
+	c.Header(StreamIDHeader, stream.id)
+	return stream
+}
+
+// ID returns the identifier clients send back in StreamIDHeader.
+func (s *ResumableStream) ID() string {
+	if s == nil {
+		return ""
+	}
+	return s.id
+}
+
+// Alias makes the stream resumable under another key as well, such as a Responses API response id.
+func (s *ResumableStream) Alias(key string) {
+	key = strings.TrimSpace(key)
+	if s == nil || key == "" {
+		return
+	}
+	s.mu.Lock()
+	for _, existing := range s.keys {
+		if existing == key {
+			s.mu.Unlock()
+			return
+		}
+	}
+	s.keys = append(s.keys, key)
+	s.mu.Unlock()
+	defaultResumableStreams.register(key, s)
+}
+
+// ResumeStream serves a reconnect request carrying StreamIDHeader and LastEventIDHeader by
+// replaying the buffered events after Last-Event-ID and then following the live stream. It
+// reports false when the request is not a reconnect or resuming is disabled, so the request is
+// served as a new one; a non-nil error must be written by the caller.
+func (h *BaseAPIHandler) ResumeStream(c *gin.Context) (bool, *interfaces.ErrorMessage) {
+	if h == nil || h.Cfg == nil || h.Cfg.Streaming.ResumeGraceSeconds <= 0 || c == nil || c.Request == nil {
+		return false, nil
+	}
+	streamID := strings.TrimSpace(c.GetHeader(StreamIDHeader))
+	if streamID == "" {
+		return false, nil
+	}
+	lastID := int64(0)
+	if raw := strings.TrimSpace(c.GetHeader(LastEventIDHeader)); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			return true, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("invalid %s %q: expected a non-negative event number", LastEventIDHeader, raw)}
+		}
+		lastID = parsed
+	}
+	if resumed, errMsg := h.ResumeStreamByKey(c, streamID, lastID); resumed {
+		return true, errMsg
+	}
+	return true, &interfaces.ErrorMessage{StatusCode: http.StatusNotFound, Error: fmt.Errorf("stream %s not found or its resume grace period has expired", streamID)}
+}
+
+// ResumeStreamByKey replays the events after lastID of the live stream registered under key and
+// then follows it. It reports false when no stream owned by the caller is registered under key.
+func (h *BaseAPIHandler) ResumeStreamByKey(c *gin.Context, key string, lastID int64) (bool, *interfaces.ErrorMessage) {
+	stream := defaultResumableStreams.lookup(key)
+	if stream == nil || stream.owner != getStringContextValue(c, "apiKey") {
+		return false, nil
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		return true, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: fmt.Errorf("streaming not supported")}
+	}
+
+	stream.attach()
+	defer stream.detach()
+	frames, lastID, done, changed, ok := stream.since(lastID)
+	if !ok {
+		return true, &interfaces.ErrorMessage{StatusCode: http.StatusGone, Error: fmt.Errorf("events after %d are no longer buffered for stream %s", lastID, stream.id)}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Header(StreamIDHeader, stream.id)
+	c.Status(http.StatusOK)
+
+	var keepAliveC <-chan time.Time
+	if interval := StreamingKeepAliveInterval(h.Cfg); interval > 0 {
+		keepAlive := time.NewTicker(interval)
+		defer keepAlive.Stop()
+		keepAliveC = keepAlive.C
+	}
+	for {
+		for _, frame := range frames {
+			_, _ = c.Writer.Write(frame)
+		}
+		flusher.Flush()
+		if done {
+			return true, nil
+		}
+		select {
+		case <-c.Request.Context().Done():
+			return true, nil
+		case <-changed:
+		case <-keepAliveC:
+			_, _ = c.Writer.Write([]byte(": keep-alive\n\n"))
+		}
+		frames, lastID, done, changed, _ = stream.since(lastID)
+	}
+}
+
+// ResumableStreamFromContext returns the stream BeginResumableStream attached to the request, or nil.
+func ResumableStreamFromContext(c *gin.Context) *ResumableStream {
+	if c == nil {
+		return nil
+	}
+	value, ok := c.Get(resumableStreamContextKey)
+	if !ok {
+		return nil
+	}
+	stream, _ := value.(*ResumableStream)
+	return stream
+}
+
+// resumableUpstreamContext keeps the request values of ctx but drops its cancellation, so the
+// upstream call of a resumable stream outlives the client connection.
+func resumableUpstreamContext(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+func (s *ResumableStream) start() {
+	s.mu.Lock()
+	s.listeners++
+	s.mu.Unlock()
+	defaultResumableStreams.register(s.id, s)
+}
+
+// append numbers frame, buffers it and returns it with its `id:` line.
+func (s *ResumableStream) append(frame []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID++
+	framed := withSSEEventID(frame, s.lastID)
+	s.events = append(s.events, resumableEvent{id: s.lastID, frame: framed})
+	if excess := len(s.events) - s.maxEvents; excess > 0 {
+		s.events = s.events[excess:]
+	}
+	s.broadcastLocked()
+	return framed
+}
+
+// since returns the buffered frames after lastID. ok is false when some of them were already dropped.
+func (s *ResumableStream) since(lastID int64) (frames [][]byte, next int64, done bool, changed <-chan struct{}, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next = lastID
+	if len(s.events) > 0 && lastID < s.events[0].id-1 {
+		return nil, lastID, s.done, s.changed, false
+	}
+	for _, event := range s.events {
+		if event.id > lastID {
+			frames = append(frames, event.frame)
+			next = event.id
+		}
+	}
+	return frames, next, s.done, s.changed, true
+}
+
+func (s *ResumableStream) attach() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners++
+	if s.expireTimer != nil {
+		s.expireTimer.Stop()
+		s.expireTimer = nil
+	}
+}
+
+func (s *ResumableStream) detach() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners--
+	if s.listeners > 0 || s.done || s.expireTimer != nil {
+		return
+	}
+	s.expireTimer = time.AfterFunc(s.grace, s.expire)
+}
+
+func (s *ResumableStream) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireTimer = nil
+	if s.listeners > 0 || s.done || s.isExpired {
+		return
+	}
+	s.isExpired = true
+	close(s.expired)
+}
+
+// finish marks the stream complete and unregisters it after retain, so a client that dropped
+// just before the end can still fetch the tail.
+func (s *ResumableStream) finish(retain time.Duration) {
+	s.mu.Lock()
+	s.done = true
+	if s.expireTimer != nil {
+		s.expireTimer.Stop()
+		s.expireTimer = nil
+	}
+	s.broadcastLocked()
+	s.mu.Unlock()
+	if retain <= 0 {
+		defaultResumableStreams.remove(s)
+		return
+	}
+	time.AfterFunc(retain, func() { defaultResumableStreams.remove(s) })
+}
+
+func (s *ResumableStream) broadcastLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (r *resumableStreamRegistry) register(key string, stream *ResumableStream) {
+	r.mu.Lock()
+	r.streams[key] = stream
+	r.mu.Unlock()
+}
+
+func (r *resumableStreamRegistry) lookup(key string) *ResumableStream {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.streams[key]
+}
+
+func (r *resumableStreamRegistry) remove(stream *ResumableStream) {
+	stream.mu.Lock()
+	keys := append([]string{stream.id}, stream.keys...)
+	stream.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		if r.streams[key] == stream {
+			delete(r.streams, key)
+		}
+	}
+}
+
+// withSSEEventID inserts an `id:` line at the start of the first event in frame, after any
+// blank separator lines the handler writes ahead of it.
+func withSSEEventID(frame []byte, id int64) []byte {
+	trimmed := bytes.TrimLeft(frame, "\n")
+	lead := len(frame) - len(trimmed)
+	out := make([]byte, 0, len(frame)+24)
+	out = append(out, frame[:lead]...)
+	out = append(out, "id: "...)
+	out = strconv.AppendInt(out, id, 10)
+	out = append(out, '\n')
+	return append(out, trimmed...)
+}
+
+// streamFrameRecorder captures what a StreamForwardOptions writer produces for one event so it
+// can be numbered and buffered before it reaches the client.
+type streamFrameRecorder struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *streamFrameRecorder) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *streamFrameRecorder) WriteString(s string) (int, error) { return w.buf.WriteString(s) }
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func newResumableTestContext(t *testing.T, ctx context.Context) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(ctx)
+	c.Set("apiKey", "client-key")
+	return c, recorder
+}
+
+func chatStreamOptions(c *gin.Context) StreamForwardOptions {
+	return StreamForwardOptions{
+		WriteChunk: func(chunk []byte) { _, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", chunk) },
+		WriteDone:  func() { _, _ = fmt.Fprint(c.Writer, "data: [DONE]\n\n") },
+	}
+}
+
+func waitForListeners(t *testing.T, stream *ResumableStream, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		stream.mu.Lock()
+		listeners := stream.listeners
+		stream.mu.Unlock()
+		if listeners == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("stream listeners never reached %d", want)
+}
+
+func TestResumableStreamReplaysAfterDisconnect(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{Streaming: config.StreamingConfig{ResumeGraceSeconds: 30}}}
+	requestCtx, disconnect := context.WithCancel(context.Background())
+	c, recorder := newResumableTestContext(t, requestCtx)
+	stream := h.BeginResumableStream(c)
+	if stream == nil || recorder.Header().Get(StreamIDHeader) != stream.ID() {
+		t.Fatalf("stream id header = %q", recorder.Header().Get(StreamIDHeader))
+	}
+
+	data := make(chan []byte)
+	errs := make(chan *interfaces.ErrorMessage)
+	upstreamErr := make(chan error, 1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		opts := chatStreamOptions(c)
+		opts.FirstChunk = []byte(`{"n":1}`)
+		h.ForwardStream(c, recorder, func(err error) { upstreamErr <- err }, data, errs, opts)
+	}()
+	waitForListeners(t, stream, 1)
+	disconnect()
+	waitForListeners(t, stream, 0)
+	data <- []byte(`{"n":2}`)
+	data <- []byte(`{"n":3}`)
+	close(data)
+	<-finished
+
+	if got := recorder.Body.String(); got != "id: 1\ndata: {\"n\":1}\n\n" {
+		t.Fatalf("original connection body = %q", got)
+	}
+	if err := <-upstreamErr; err != nil {
+		t.Fatalf("upstream cancelled with %v, want completion", err)
+	}
+
+	resumeCtx, resumeRecorder := newResumableTestContext(t, context.Background())
+	resumeCtx.Request.Header.Set(StreamIDHeader, stream.ID())
+	resumeCtx.Request.Header.Set(LastEventIDHeader, "1")
+	if resumed, errMsg := h.ResumeStream(resumeCtx); !resumed || errMsg != nil {
+		t.Fatalf("ResumeStream() = %v, %+v", resumed, errMsg)
+	}
+	want := "id: 2\ndata: {\"n\":2}\n\nid: 3\ndata: {\"n\":3}\n\nid: 4\ndata: [DONE]\n\n"
+	if got := resumeRecorder.Body.String(); got != want {
+		t.Fatalf("resumed body = %q, want %q", got, want)
+	}
+
+	otherCtx, _ := newResumableTestContext(t, context.Background())
+	otherCtx.Set("apiKey", "someone-else")
+	otherCtx.Request.Header.Set(StreamIDHeader, stream.ID())
+	if _, errMsg := h.ResumeStream(otherCtx); errMsg == nil || errMsg.StatusCode != http.StatusNotFound {
+		t.Fatalf("foreign key resume error = %+v, want 404", errMsg)
+	}
+}
+
+func TestResumeStreamIgnoresHeaderWhenDisabled(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{}}
+	c, recorder := newResumableTestContext(t, context.Background())
+	c.Request.Header.Set(StreamIDHeader, "stream-from-an-earlier-run")
+	c.Request.Header.Set(LastEventIDHeader, "3")
+
+	if resumed, errMsg := h.ResumeStream(c); resumed || errMsg != nil {
+		t.Fatalf("ResumeStream() = %v, %+v, want the request served as a new one", resumed, errMsg)
+	}
+	if recorder.Body.Len() != 0 {
+		t.Fatalf("body = %q, want nothing written", recorder.Body.String())
+	}
+}
+
+func TestResumableStreamCancelsUpstreamAfterGrace(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{Streaming: config.StreamingConfig{ResumeGraceSeconds: 30}}}
+	requestCtx, disconnect := context.WithCancel(context.Background())
+	c, recorder := newResumableTestContext(t, requestCtx)
+	stream := h.BeginResumableStream(c)
+	stream.grace = 10 * time.Millisecond
+
+	upstreamErr := make(chan error, 1)
+	go h.ForwardStream(c, recorder, func(err error) { upstreamErr <- err }, make(chan []byte), nil, chatStreamOptions(c))
+	waitForListeners(t, stream, 1)
+	disconnect()
+
+	select {
+	case err := <-upstreamErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("upstream cancel error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream was not cancelled after the grace period")
+	}
+	if defaultResumableStreams.lookup(stream.ID()) != nil {
+		t.Fatal("expired stream is still registered")
+	}
+}
+
+func TestWithSSEEventID(t *testing.T) {
+	for frame, want := range map[string]string{
+		"data: x\n\n":                 "id: 7\ndata: x\n\n",
+		"\nevent: a\ndata: x\n\n":     "\nid: 7\nevent: a\ndata: x\n\n",
+		"event: a\ndata: x\n\nevent:": "id: 7\nevent: a\ndata: x\n\nevent:",
+	} {
+		if got := string(withSSEEventID([]byte(frame), 7)); got != want {
+			t.Fatalf("withSSEEventID(%q) = %q, want %q", frame, got, want)
+		}
+	}
+	if !strings.HasPrefix(string(withSSEEventID(nil, 1)), "id: 1\n") {
+		t.Fatal("empty frame did not get an id")
+	}
+}
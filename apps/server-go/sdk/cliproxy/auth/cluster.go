@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+// clusterCooldown is a model cooldown shared with other nodes through the cluster backend.
+type clusterCooldown struct {
+	AuthID       string    `json:"auth_id"`
+	Model        string    `json:"model"`
+	Until        time.Time `json:"until"`
+	Reason       string    `json:"reason,omitempty"`
+	Message      string    `json:"message,omitempty"`
+	Quota        bool      `json:"quota,omitempty"`
+	BackoffLevel int       `json:"backoff_level,omitempty"`
+}
+
+func clusterCooldownKey(authID, model string) string {
+	return authID + "|" + model
+}
+
+func refreshLeaseName(authID string) string {
+	return "refresh/" + authID
+}
+
+// newClusterCooldown captures state for publishing. It returns nil when the model has no
+// timed cooldown to share.
+func newClusterCooldown(authID, model string, state *ModelState, reason string) *clusterCooldown {
+	if state == nil || !state.Unavailable || state.NextRetryAfter.IsZero() {
+		return nil
+	}
+	return &clusterCooldown{
+		AuthID:       authID,
+		Model:        model,
+		Until:        state.NextRetryAfter,
+		Reason:       reason,
+		Message:      state.StatusMessage,
+		Quota:        state.Quota.Exceeded,
+		BackoffLevel: state.Quota.BackoffLevel,
+	}
+}
+
+func publishClusterCooldown(cooldown *clusterCooldown) {
+	ttl := time.Until(cooldown.Until)
+	if ttl <= 0 {
+		return
+	}
+	cluster.Save(cluster.NamespaceCooldown, clusterCooldownKey(cooldown.AuthID, cooldown.Model), cooldown, ttl)
+}
+
+func clearClusterCooldown(authID, model string) {
+	cluster.Remove(cluster.NamespaceCooldown, clusterCooldownKey(authID, model))
+}
+
+// syncClusterCooldowns applies cooldowns published by other nodes and lifts the ones that
+// were cleared since the last sync.
+func (m *Manager) syncClusterCooldowns(now time.Time) {
+	entries := cluster.Entries(cluster.NamespaceCooldown)
+	if entries == nil {
+		return
+	}
+	var suspended []clusterCooldown
+	var resumed []clusterCooldown
+
+	m.mu.Lock()
+	if m.clusterCooldowns == nil {
+		m.clusterCooldowns = make(map[string]time.Time)
+	}
+	seen := make(map[string]struct{}, len(entries))
+	for key, raw := range entries {
+		var cooldown clusterCooldown
+		if err := json.Unmarshal(raw, &cooldown); err != nil || !cooldown.Until.After(now) {
+			continue
+		}
+		seen[key] = struct{}{}
+		auth := m.auths[cooldown.AuthID]
+		if auth == nil || cooldown.Model == "" {
+			continue
+		}
+		state := ensureModelState(auth, cooldown.Model)
+		if state.Unavailable && !state.NextRetryAfter.Before(cooldown.Until) {
+			if state.NextRetryAfter.Equal(cooldown.Until) {
+				m.clusterCooldowns[key] = cooldown.Until
+			}
+			continue
+		}
+		state.Unavailable = true
+		state.Status = StatusError
+		state.StatusMessage = cooldown.Message
+		state.NextRetryAfter = cooldown.Until
+		state.UpdatedAt = now
+		if cooldown.Quota {
+			state.Quota = QuotaState{
+				Exceeded:      true,
+				Reason:        "quota",
+				NextRecoverAt: cooldown.Until,
+				BackoffLevel:  cooldown.BackoffLevel,
+			}
+		}
+		if auth.Status != StatusError {
+			m.health.record(auth.ID, HealthEvent{At: now, Kind: HealthEventStatus, From: auth.Status, Status: StatusError, Reason: clusterHealthReasonPrefix + cooldown.Reason})
+		}
+		auth.Status = StatusError
+		auth.UpdatedAt = now
+		updateAggregatedAvailability(auth, now)
+		m.clusterCooldowns[key] = cooldown.Until
+		suspended = append(suspended, cooldown)
+		kind := HealthEventSuspended
+		if cooldown.Quota {
+			kind = HealthEventQuotaExceeded
+		}
+		until := cooldown.Until
+		m.health.record(cooldown.AuthID, HealthEvent{At: now, Kind: kind, Model: cooldown.Model, Reason: clusterHealthReasonPrefix + cooldown.Reason, Until: &until, Error: cooldown.Message})
+	}
+	for key, until := range m.clusterCooldowns {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		delete(m.clusterCooldowns, key)
+		authID, model, ok := strings.Cut(key, "|")
+		if !ok {
+			continue
+		}
+		auth := m.auths[authID]
+		if auth == nil {
+			continue
+		}
+		state := auth.ModelStates[model]
+		if state == nil || !state.Unavailable || !state.NextRetryAfter.Equal(until) || !until.After(now) {
+			continue
+		}
+		resetModelState(state, now)
+		updateAggregatedAvailability(auth, now)
+		if !hasModelError(auth, now) {
+			if auth.Status != StatusActive {
+				m.health.record(auth.ID, HealthEvent{At: now, Kind: HealthEventStatus, From: auth.Status, Status: StatusActive, Reason: clusterHealthReasonPrefix + "cooldown cleared"})
+			}
+			auth.LastError = nil
+			auth.StatusMessage = ""
+			auth.Status = StatusActive
+		}
+		auth.UpdatedAt = now
+		resumed = append(resumed, clusterCooldown{AuthID: authID, Model: model})
+		m.health.record(authID, HealthEvent{At: now, Kind: HealthEventResumed, Model: model, Reason: clusterHealthReasonPrefix + "cooldown cleared"})
+	}
+	m.mu.Unlock()
+
+	reg := registry.GetGlobalRegistry()
+	for _, cooldown := range suspended {
+		if cooldown.Quota {
+			reg.SetModelQuotaExceeded(cooldown.AuthID, cooldown.Model)
+		}
+		reg.SuspendClientModel(cooldown.AuthID, cooldown.Model, cooldown.Reason)
+	}
+	for _, cooldown := range resumed {
+		reg.ClearModelQuotaExceeded(cooldown.AuthID, cooldown.Model)
+		reg.ResumeClientModel(cooldown.AuthID, cooldown.Model)
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestRoundRobinSelectorPick_SharesCursorAcrossNodes(t *testing.T) {
+	backend := cluster.NewMemoryBackend()
+	cluster.Install(backend, "node-1")
+	t.Cleanup(cluster.Reset)
+
+	nodes := []*RoundRobinSelector{{}, {}}
+	auths := []*Auth{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	counts := make(map[string]int)
+	for i := 0; i < 2*cursorLeaseSize; i++ {
+		got, err := nodes[i%2].Pick(context.Background(), "gemini", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		counts[got.ID]++
+	}
+	if counts["a"] != 22 || counts["b"] != 21 || counts["c"] != 21 {
+		t.Fatalf("pick counts = %v, want an even rotation over the shared sequence", counts)
+	}
+	// Each node reserved exactly one block.
+	if next, _ := backend.Incr(context.Background(), cluster.NamespaceCursor, "gemini:", 0, 0); next != 2*cursorLeaseSize {
+		t.Fatalf("shared cursor = %d, want %d", next, 2*cursorLeaseSize)
+	}
+}
+
+type failingCounterBackend struct {
+	*cluster.MemoryBackend
+	calls atomic.Int32
+}
+
+func (b *failingCounterBackend) Incr(context.Context, string, string, int64, time.Duration) (int64, error) {
+	b.calls.Add(1)
+	return 0, errors.New("backend down")
+}
+
+func TestRoundRobinSelectorPick_FallsBackToLocalCursor(t *testing.T) {
+	backend := &failingCounterBackend{MemoryBackend: cluster.NewMemoryBackend()}
+	cluster.Install(backend, "node-1")
+	t.Cleanup(cluster.Reset)
+
+	selector := &RoundRobinSelector{}
+	auths := []*Auth{{ID: "a"}, {ID: "b"}}
+	want := []string{"a", "b", "a"}
+	for i, id := range want {
+		got, err := selector.Pick(context.Background(), "gemini", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		if got.ID != id {
+			t.Fatalf("Pick() #%d auth.ID = %q, want %q", i, got.ID, id)
+		}
+	}
+	if calls := backend.calls.Load(); calls != 1 {
+		t.Fatalf("backend Incr calls = %d, want 1 until the retry delay passes", calls)
+	}
+}
+
+func TestSyncClusterCooldowns_AppliesAndLiftsPeerCooldowns(t *testing.T) {
+	cluster.Install(cluster.NewMemoryBackend(), "node-1")
+	t.Cleanup(cluster.Reset)
+
+	ctx := context.Background()
+	model := "cluster-sync-model"
+	nodeA := NewManager(nil, nil, nil)
+	nodeB := NewManager(nil, nil, nil)
+	for _, m := range []*Manager{nodeA, nodeB} {
+		if _, err := m.Register(ctx, &Auth{ID: "shared-auth", Provider: "claude"}); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	nodeA.MarkResult(ctx, Result{AuthID: "shared-auth", Provider: "claude", Model: model, Error: &Error{HTTPStatus: 429, Message: "quota"}})
+	nodeB.syncClusterCooldowns(time.Now())
+
+	auth, _ := nodeB.GetByID("shared-auth")
+	state := auth.ModelStates[model]
+	if state == nil || !state.Unavailable || !state.Quota.Exceeded {
+		t.Fatalf("node B model state = %+v, want quota cooldown from node A", state)
+	}
+
+	nodeA.MarkResult(ctx, Result{AuthID: "shared-auth", Provider: "claude", Model: model, Success: true})
+	nodeB.syncClusterCooldowns(time.Now())
+
+	auth, _ = nodeB.GetByID("shared-auth")
+	if state = auth.ModelStates[model]; state == nil || state.Unavailable {
+		t.Fatalf("node B model state = %+v, want cooldown lifted after node A recovered", state)
+	}
+}
+
+func TestHealthTimeline_PersistsThroughClusterBackend(t *testing.T) {
+	cluster.Install(cluster.NewMemoryBackend(), "node-1")
+	t.Cleanup(cluster.Reset)
+
+	at := time.Now().Add(-time.Minute).Truncate(time.Second)
+	first := NewManager(nil, nil, nil)
+	first.health.record("shared-auth",
+		HealthEvent{At: at, Kind: HealthEventRefreshFailed, Reason: "refresh failed"},
+		HealthEvent{At: at, Kind: HealthEventCooldown, Model: "m", Reason: clusterHealthReasonPrefix + "peer cooldown"})
+	first.health.persist()
+	first.health.persist()
+
+	restarted := NewManager(nil, nil, nil)
+	restarted.health.record("shared-auth", HealthEvent{At: at.Add(time.Second), Kind: HealthEventRefreshSucceeded, Reason: "refresh succeeded"})
+	restarted.health.restore()
+	restarted.health.persist()
+
+	events := restarted.HealthTimeline("shared-auth", time.Time{})
+	if len(events) != 2 || events[0].Kind != HealthEventRefreshFailed || events[1].Kind != HealthEventRefreshSucceeded {
+		t.Fatalf("restored timeline = %+v, want the saved refresh failure and the local success without the peer mirror", events)
+	}
+	var shared []HealthEvent
+	if !cluster.Load(cluster.NamespaceHealth, "shared-auth", &shared) || len(shared) != 2 {
+		t.Fatalf("shared timeline = %+v, want 2 events", shared)
+	}
+}
@@ -15,11 +15,13 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
 	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing/ctxkeys"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upstreamtls"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	log "github.com/sirupsen/logrus"
@@ -100,6 +102,8 @@ type Result struct {
 	RetryAfter *time.Duration
 	// Error describes the failure when Success is false.
 	Error *Error
+	// Probe marks results produced by a background health probe rather than a user request.
+	Probe bool
 }
 
 // Selector chooses an auth candidate for execution.
@@ -117,6 +121,12 @@ type Hook interface {
 	OnResult(ctx context.Context, result Result)
 }
 
+// RefreshHook is implemented by hooks that also observe failed credential refreshes.
+type RefreshHook interface {
+	// OnRefreshFailed fires when refreshing an auth fails.
+	OnRefreshFailed(ctx context.Context, auth *Auth, err error)
+}
+
 // NoopHook provides optional hook defaults.
 type NoopHook struct{}
 
@@ -161,6 +171,19 @@ type Manager struct {
 	// Auto refresh state
 	refreshCancel    context.CancelFunc
 	refreshSemaphore chan struct{}
+
+	// clusterCooldowns records cooldowns applied from the shared backend, keyed by
+	// clusterCooldownKey, so they can be lifted when another node clears them. Guarded by mu.
+	clusterCooldowns map[string]time.Time
+
+	// health records state transitions per auth for the management health timeline.
+	health *healthTimeline
+
+	// prober schedules proactive health probes of credentials.
+	prober healthProber
+
+	// quota learns per-auth consumption and quota capacity to forecast exhaustion.
+	quota quotaForecaster
 }
 
 // NewManager constructs a manager with optional custom selector and hook.
@@ -179,6 +202,7 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 		auths:            make(map[string]*Auth),
 		providerOffsets:  make(map[string]int),
 		refreshSemaphore: make(chan struct{}, refreshMaxConcurrency),
+		health:           newHealthTimeline(),
 	}
 	// atomic.Value requires non-nil initial value.
 	manager.runtimeConfig.Store(&internalconfig.Config{})
@@ -198,6 +222,54 @@ func (m *Manager) SetSelector(selector Selector) {
 	m.mu.Unlock()
 }
 
+// AddHook adds hook to the manager's lifecycle callbacks, after any hook it already has. Call
+// it before the manager starts serving requests.
+func (m *Manager) AddHook(hook Hook) {
+	if m == nil || hook == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, noop := m.hook.(NoopHook); noop || m.hook == nil {
+		m.hook = hook
+		return
+	}
+	m.hook = hookChain{m.hook, hook}
+}
+
+// hookChain forwards callbacks to several hooks in order.
+type hookChain []Hook
+
+// OnAuthRegistered implements Hook.
+func (c hookChain) OnAuthRegistered(ctx context.Context, auth *Auth) {
+	for _, hook := range c {
+		hook.OnAuthRegistered(ctx, auth)
+	}
+}
+
+// OnAuthUpdated implements Hook.
+func (c hookChain) OnAuthUpdated(ctx context.Context, auth *Auth) {
+	for _, hook := range c {
+		hook.OnAuthUpdated(ctx, auth)
+	}
+}
+
+// OnResult implements Hook.
+func (c hookChain) OnResult(ctx context.Context, result Result) {
+	for _, hook := range c {
+		hook.OnResult(ctx, result)
+	}
+}
+
+// OnRefreshFailed implements RefreshHook for the hooks that observe refreshes.
+func (c hookChain) OnRefreshFailed(ctx context.Context, auth *Auth, err error) {
+	for _, hook := range c {
+		if refreshHook, ok := hook.(RefreshHook); ok {
+			refreshHook.OnRefreshFailed(ctx, auth, err)
+		}
+	}
+}
+
 // SetStore swaps the underlying persistence store.
 func (m *Manager) SetStore(store Store) {
 	m.mu.Lock()
@@ -461,9 +533,18 @@ func (m *Manager) Update(ctx context.Context, auth *Auth) (*Auth, error) {
 		return nil, nil
 	}
 	m.mu.Lock()
-	if existing, ok := m.auths[auth.ID]; ok && existing != nil && !auth.indexAssigned && auth.Index == "" {
-		auth.Index = existing.Index
-		auth.indexAssigned = existing.indexAssigned
+	if existing, ok := m.auths[auth.ID]; ok && existing != nil {
+		if !auth.indexAssigned && auth.Index == "" {
+			auth.Index = existing.Index
+			auth.indexAssigned = existing.indexAssigned
+		}
+		if existing.Status != auth.Status {
+			reason := auth.StatusMessage
+			if reason == "" {
+				reason = "updated"
+			}
+			m.health.record(auth.ID, HealthEvent{At: time.Now(), Kind: HealthEventStatus, From: existing.Status, Status: auth.Status, Reason: reason})
+		}
 	}
 	auth.EnsureIndex()
 	m.auths[auth.ID] = auth.Clone()
@@ -669,7 +750,7 @@ func (m *Manager) executeMixedAttempt(
 	entry := logEntryWithRequestID(ctx)
 	debugLogAuthSelection(entry, auth, provider, req.Model)
 
-	execCtx := ctx
+	execCtx := upstreamtls.WithAuth(ctx, auth.Provider, auth.Attributes, auth.Metadata)
 	if rt := m.roundTripperFor(auth); rt != nil {
 		execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 		execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
@@ -709,6 +790,9 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 		return m.executeMixedAttempt(ctx, auth, provider, routeModel, req, opts, func(execCtx context.Context, execReq cliproxyexecutor.Request) error {
 			var errExec error
 			resp, errExec = executor.Execute(execCtx, auth, execReq, opts)
+			if errExec == nil {
+				m.observeQuotaHeaders(auth.ID, provider, resp.Headers, time.Now())
+			}
 			return errExec
 		})
 	})
@@ -748,6 +832,7 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 			if streamResult == nil {
 				return &Error{Code: "executor_error", Message: "stream result is nil"}
 			}
+			m.observeQuotaHeaders(auth.ID, provider, streamResult.Headers, time.Now())
 
 			in := streamResult.Chunks
 			if in == nil {
@@ -1224,10 +1309,13 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	suspendReason := ""
 	clearModelQuota := false
 	setModelQuota := false
+	var sharedCooldown *clusterCooldown
+	var quotaRecoverAt time.Time
 
 	m.mu.Lock()
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
 		now := time.Now()
+		before := captureHealth(auth, result.Model)
 
 		if result.Success {
 			if result.Model != "" {
@@ -1294,6 +1382,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 						NextRecoverAt: next,
 						BackoffLevel:  backoffLevel,
 					}
+					quotaRecoverAt = next
 					suspendReason = "quota"
 					shouldSuspendModel = true
 					setModelQuota = true
@@ -1311,15 +1400,27 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 				auth.Status = StatusError
 				auth.UpdatedAt = now
 				updateAggregatedAvailability(auth, now)
+				sharedCooldown = newClusterCooldown(result.AuthID, result.Model, state, suspendReason)
 			} else {
 				applyAuthFailureState(auth, result.Error, result.RetryAfter, now)
+				quotaRecoverAt = auth.Quota.NextRecoverAt
 			}
 		}
 
+		m.health.record(result.AuthID, resultHealthEvents(before, auth, result, suspendReason, now)...)
 		_ = m.persist(ctx, auth)
 	}
 	m.mu.Unlock()
 
+	if !result.Success && statusCodeFromResult(result.Error) == 429 {
+		m.observeQuotaExhausted(result.AuthID, result.Provider, time.Now(), result.RetryAfter, quotaRecoverAt)
+	}
+	if sharedCooldown != nil {
+		publishClusterCooldown(sharedCooldown)
+	} else if shouldResumeModel && result.Model != "" {
+		clearClusterCooldown(result.AuthID, result.Model)
+	}
+
 	if clearModelQuota && result.Model != "" {
 		registry.GetGlobalRegistry().ClearModelQuotaExceeded(result.AuthID, result.Model)
 	}
@@ -1486,6 +1587,8 @@ func statusCodeFromError(err error) int {
 	return 0
 }
 
+// retryAfterFromError returns the retry hint of an upstream error: the executor's own hint, else a
+// Retry-After header (as sent with model_cooldown errors), else the delay in a 429 body.
 func retryAfterFromError(err error) *time.Duration {
 	if err == nil {
 		return nil
@@ -1493,15 +1596,64 @@ func retryAfterFromError(err error) *time.Duration {
 	type retryAfterProvider interface {
 		RetryAfter() *time.Duration
 	}
-	rap, ok := err.(retryAfterProvider)
-	if !ok || rap == nil {
+	if rap, ok := err.(retryAfterProvider); ok && rap != nil {
+		if retryAfter := rap.RetryAfter(); retryAfter != nil {
+			return new(*retryAfter)
+		}
+	}
+	if he, ok := err.(interface{ Headers() http.Header }); ok && he != nil {
+		if retryAfter := parseRetryAfterHeader(he.Headers().Get("Retry-After"), time.Now()); retryAfter != nil {
+			return retryAfter
+		}
+	}
+	if statusCodeFromError(err) == http.StatusTooManyRequests {
+		return retryAfterFromBody(err.Error())
+	}
+	return nil
+}
+
+// parseRetryAfterHeader parses a Retry-After value given as seconds or as an HTTP date.
+func parseRetryAfterHeader(raw string, now time.Time) *time.Duration {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
 		return nil
 	}
-	retryAfter := rap.RetryAfter()
-	if retryAfter == nil {
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil && seconds >= 0 {
+		return new(time.Duration(seconds * float64(time.Second)))
+	}
+	if at, err := http.ParseTime(raw); err == nil && at.After(now) {
+		return new(at.Sub(now))
+	}
+	return nil
+}
+
+// retryAfterFromBody reads the delay from a 429 body: reset_seconds of a model_cooldown error
+// (another proxy in front of the upstream) or a Google RetryInfo retryDelay.
+func retryAfterFromBody(body string) *time.Duration {
+	var parsed struct {
+		Error struct {
+			ResetSeconds *float64 `json:"reset_seconds"`
+			Details      []struct {
+				Type       string `json:"@type"`
+				RetryDelay string `json:"retryDelay"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(strings.TrimSpace(body)), &parsed) != nil {
 		return nil
 	}
-	return new(*retryAfter)
+	if reset := parsed.Error.ResetSeconds; reset != nil && *reset >= 0 {
+		return new(time.Duration(*reset * float64(time.Second)))
+	}
+	for _, detail := range parsed.Error.Details {
+		if detail.Type != "type.googleapis.com/google.rpc.RetryInfo" || detail.RetryDelay == "" {
+			continue
+		}
+		if delay, err := time.ParseDuration(detail.RetryDelay); err == nil && delay >= 0 {
+			return &delay
+		}
+	}
+	return nil
 }
 
 func statusCodeFromResult(err *Error) int {
@@ -1741,6 +1893,7 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		m.mu.RUnlock()
 		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
+	candidates = m.preferAuthsWithQuota(candidates)
 	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
 	if errPick != nil {
 		m.mu.RUnlock()
@@ -1818,6 +1971,7 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		m.mu.RUnlock()
 		return nil, nil, "", &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
+	candidates = m.preferAuthsWithQuota(candidates)
 	selected, errPick := m.selector.Pick(ctx, "mixed", model, opts, candidates)
 	if errPick != nil {
 		m.mu.RUnlock()
@@ -1883,13 +2037,27 @@ func (m *Manager) StartAutoRefresh(parent context.Context, interval time.Duratio
 	go func() {
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
+		// In cluster mode cooldowns recorded by other nodes are pulled, and the health timeline
+		// is saved, on their own cadence.
+		var syncC <-chan time.Time
+		if cluster.Shared() {
+			syncTicker := time.NewTicker(cluster.SyncInterval())
+			defer syncTicker.Stop()
+			syncC = syncTicker.C
+			m.health.restore()
+		}
 		m.checkRefreshes(ctx)
 		for {
 			select {
 			case <-ctx.Done():
+				m.health.persist()
 				return
 			case <-ticker.C:
 				m.checkRefreshes(ctx)
+				m.checkProbes(ctx, time.Now())
+			case <-syncC:
+				m.syncClusterCooldowns(time.Now())
+				m.health.persist()
 			}
 		}
 	}()
@@ -1921,6 +2089,13 @@ func (m *Manager) checkRefreshes(ctx context.Context) {
 			if !m.markRefreshPending(a.ID, now) {
 				continue
 			}
+			// In cluster mode only the node holding the credential's lease refreshes it. The
+			// lease is kept until it expires so peers pick up the new token from the store
+			// instead of refreshing again.
+			if !cluster.AcquireLease(refreshLeaseName(a.ID), cluster.LeaseTTL()) {
+				log.Debugf("skipping refresh for %s, %s: lease held by another node", a.Provider, a.ID)
+				continue
+			}
 			go m.refreshAuthWithLimit(ctx, a.ID)
 		}
 	}
@@ -2170,7 +2345,8 @@ func (m *Manager) markRefreshPending(id string, now time.Time) bool {
 	return true
 }
 
-func (m *Manager) refreshAuth(ctx context.Context, id string) {
+// refreshAuth refreshes the credential id through its executor and reports whether it succeeded.
+func (m *Manager) refreshAuth(ctx context.Context, id string) bool {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -2182,25 +2358,33 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	}
 	m.mu.RUnlock()
 	if auth == nil || exec == nil {
-		return
+		return false
 	}
 	cloned := auth.Clone()
+	// Token endpoints are reached with the upstream TLS settings of the credential.
+	ctx = upstreamtls.WithAuth(ctx, auth.Provider, auth.Attributes, auth.Metadata)
 	updated, err := exec.Refresh(ctx, cloned)
 	if err != nil && errors.Is(err, context.Canceled) {
 		log.Debugf("refresh canceled for %s, %s", auth.Provider, auth.ID)
-		return
+		return false
 	}
 	log.Debugf("refreshed %s, %s, %v", auth.Provider, auth.ID, err)
 	now := time.Now()
 	if err != nil {
+		var failed *Auth
 		m.mu.Lock()
 		if current := m.auths[id]; current != nil {
 			current.NextRefreshAfter = now.Add(refreshFailureBackoff)
 			current.LastError = &Error{Message: err.Error()}
 			m.auths[id] = current
+			m.health.record(id, HealthEvent{At: now, Kind: HealthEventRefreshFailed, Reason: "refresh failed", Error: err.Error()})
+			failed = current.Clone()
 		}
 		m.mu.Unlock()
-		return
+		if refreshHook, ok := m.hook.(RefreshHook); ok && failed != nil {
+			refreshHook.OnRefreshFailed(ctx, failed, err)
+		}
+		return false
 	}
 	if updated == nil {
 		updated = cloned
@@ -2214,7 +2398,9 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	updated.NextRefreshAfter = time.Time{}
 	updated.LastError = nil
 	updated.UpdatedAt = now
+	m.health.record(id, HealthEvent{At: now, Kind: HealthEventRefreshSucceeded, Reason: "refresh succeeded"})
 	_, _ = m.Update(ctx, updated)
+	return true
 }
 
 func (m *Manager) executorFor(provider string) ProviderExecutor {
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type recordingHook struct {
+	NoopHook
+	registered []string
+	refreshErr []string
+}
+
+func (h *recordingHook) OnAuthRegistered(_ context.Context, auth *Auth) {
+	h.registered = append(h.registered, auth.ID)
+}
+
+func (h *recordingHook) OnRefreshFailed(_ context.Context, auth *Auth, _ error) {
+	h.refreshErr = append(h.refreshErr, auth.ID)
+}
+
+func TestManagerAddHook_KeepsExistingHook(t *testing.T) {
+	existing, added := &recordingHook{}, &recordingHook{}
+	manager := NewManager(nil, nil, existing)
+	manager.AddHook(added)
+
+	if _, err := manager.Register(context.Background(), &Auth{ID: "a", Provider: "claude"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if len(existing.registered) != 1 || len(added.registered) != 1 {
+		t.Fatalf("registered callbacks: existing=%v added=%v, want one each", existing.registered, added.registered)
+	}
+	refreshHook, ok := manager.hook.(RefreshHook)
+	if !ok {
+		t.Fatal("chained hook does not forward refresh failures")
+	}
+	refreshHook.OnRefreshFailed(context.Background(), &Auth{ID: "a"}, errors.New("boom"))
+	if len(existing.refreshErr) != 1 || len(added.refreshErr) != 1 {
+		t.Fatalf("refresh callbacks: existing=%v added=%v, want one each", existing.refreshErr, added.refreshErr)
+	}
+
+	bare := NewManager(nil, nil, nil)
+	bare.AddHook(added)
+	if bare.hook != Hook(added) {
+		t.Fatalf("AddHook on a manager without hooks = %T, want the hook itself", bare.hook)
+	}
+}
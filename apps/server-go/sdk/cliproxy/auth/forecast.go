@@ -0,0 +1,465 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const (
+	// forecastRateWindow is the span consumption rates are averaged over.
+	forecastRateWindow = 15 * time.Minute
+	// forecastCapacityWeight is the weight of the newest capacity sample in the running average.
+	forecastCapacityWeight = 0.5
+
+	defaultForecastRotateBefore   = 2 * time.Minute
+	defaultForecastReservePercent = 5
+)
+
+// QuotaForecast is the learned quota model of one auth.
+type QuotaForecast struct {
+	AuthID            string  `json:"auth_id"`
+	Provider          string  `json:"provider"`
+	RequestsPerMinute float64 `json:"requests_per_minute"`
+	TokensPerMinute   float64 `json:"tokens_per_minute"`
+	// RequestsSinceReset and TokensSinceReset count consumption since the last exhaustion.
+	RequestsSinceReset int64 `json:"requests_since_reset"`
+	TokensSinceReset   int64 `json:"tokens_since_reset"`
+	// CapacityRequests and CapacityTokens average the consumption observed between two
+	// exhaustions; zero until the first exhaustion after tracking started.
+	CapacityRequests float64 `json:"capacity_requests,omitempty"`
+	CapacityTokens   float64 `json:"capacity_tokens,omitempty"`
+	// RemainingRequests and RemainingTokens are the best current estimates, from upstream
+	// rate-limit headers when fresh, else from the learned capacity. Nil means unknown.
+	RemainingRequests *float64 `json:"remaining_requests,omitempty"`
+	RemainingTokens   *float64 `json:"remaining_tokens,omitempty"`
+	// RemainingPercent is the lowest share of known capacity left across requests and tokens.
+	RemainingPercent *float64 `json:"remaining_percent,omitempty"`
+	// TimeToExhaustionSeconds is nil when there is not enough signal to forecast.
+	TimeToExhaustionSeconds *float64  `json:"time_to_exhaustion_seconds,omitempty"`
+	Exhaustions             int       `json:"exhaustions"`
+	LastExhaustedAt         time.Time `json:"last_exhausted_at"`
+	// WindowSeconds is the learned quota window: the average time between two exhaustions.
+	WindowSeconds float64 `json:"window_seconds,omitempty"`
+	// NextResetAt is when the consumption counters restart because quota is expected back.
+	NextResetAt *time.Time `json:"next_reset_at,omitempty"`
+	NearLimit   bool       `json:"near_limit"`
+}
+
+// quotaUsageBucket aggregates consumption of one minute.
+type quotaUsageBucket struct {
+	minute   int64
+	requests int64
+	tokens   int64
+}
+
+// quotaModel learns one auth's consumption rate and quota capacity.
+type quotaModel struct {
+	provider string
+	buckets  []quotaUsageBucket
+
+	resetAt          time.Time
+	requests         int64
+	tokens           int64
+	capacityRequests float64
+	capacityTokens   float64
+	exhaustions      int
+	lastExhaustedAt  time.Time
+	// window is the learned quota window and recoverAt the time the counters restart next.
+	window    time.Duration
+	recoverAt time.Time
+
+	// Upstream rate-limit headers, with consumption counted since they were read.
+	headerAt          time.Time
+	headerResetAt     time.Time
+	remainingRequests int64
+	remainingTokens   int64
+	limitRequests     int64
+	limitTokens       int64
+	requestsAtHeader  int64
+	tokensAtHeader    int64
+}
+
+// quotaForecaster holds the quota models of all auths.
+type quotaForecaster struct {
+	mu     sync.Mutex
+	models map[string]*quotaModel
+}
+
+func (f *quotaForecaster) model(authID, provider string) *quotaModel {
+	if f.models == nil {
+		f.models = make(map[string]*quotaModel)
+	}
+	model := f.models[authID]
+	if model == nil {
+		model = &quotaModel{remainingRequests: -1, remainingTokens: -1}
+		f.models[authID] = model
+	}
+	if provider != "" {
+		model.provider = provider
+	}
+	return model
+}
+
+// HandleUsage implements coreusage.Plugin and feeds the quota forecaster.
+func (m *Manager) HandleUsage(_ context.Context, record coreusage.Record) {
+	if m == nil || record.AuthID == "" {
+		return
+	}
+	at := record.RequestedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	m.quota.mu.Lock()
+	defer m.quota.mu.Unlock()
+	model := m.quota.model(record.AuthID, record.Provider)
+	model.rolloverLocked(at)
+	model.requests++
+	model.tokens += tokens
+	minute := at.Unix() / 60
+	if n := len(model.buckets); n > 0 && model.buckets[n-1].minute == minute {
+		model.buckets[n-1].requests++
+		model.buckets[n-1].tokens += tokens
+	} else {
+		model.buckets = append(model.buckets, quotaUsageBucket{minute: minute, requests: 1, tokens: tokens})
+	}
+	cutoff := at.Add(-forecastRateWindow).Unix() / 60
+	drop := 0
+	for drop < len(model.buckets) && model.buckets[drop].minute < cutoff {
+		drop++
+	}
+	model.buckets = model.buckets[drop:]
+}
+
+// observeQuotaExhausted records a quota error: the consumption since the previous reset becomes
+// a capacity sample, the time since the previous exhaustion a window sample, and counting
+// restarts. The counters restart again once quota is expected back: after retryAfter when
+// upstream said so, else after the later of cooldownUntil and the learned window.
+func (m *Manager) observeQuotaExhausted(authID, provider string, now time.Time, retryAfter *time.Duration, cooldownUntil time.Time) {
+	m.quota.mu.Lock()
+	defer m.quota.mu.Unlock()
+	model := m.quota.model(authID, provider)
+	model.rolloverLocked(now)
+	if !model.resetAt.IsZero() && model.requests > 0 {
+		if model.capacityRequests == 0 {
+			model.capacityRequests = float64(model.requests)
+			model.capacityTokens = float64(model.tokens)
+		} else {
+			model.capacityRequests += forecastCapacityWeight * (float64(model.requests) - model.capacityRequests)
+			model.capacityTokens += forecastCapacityWeight * (float64(model.tokens) - model.capacityTokens)
+		}
+		if interval := now.Sub(model.lastExhaustedAt); !model.lastExhaustedAt.IsZero() && interval > 0 {
+			if model.window == 0 {
+				model.window = interval
+			} else {
+				model.window += time.Duration(forecastCapacityWeight * float64(interval-model.window))
+			}
+		}
+	}
+	model.exhaustions++
+	model.lastExhaustedAt = now
+	model.resetAt = now
+	model.requests = 0
+	model.tokens = 0
+	model.requestsAtHeader = 0
+	model.tokensAtHeader = 0
+	model.headerAt = time.Time{}
+	switch {
+	case retryAfter != nil && *retryAfter > 0:
+		model.recoverAt = now.Add(*retryAfter)
+	case model.window > 0 && now.Add(model.window).After(cooldownUntil):
+		model.recoverAt = now.Add(model.window)
+	default:
+		model.recoverAt = cooldownUntil
+	}
+}
+
+// rolloverLocked restarts the consumption counters once the quota window that was exhausted is
+// over, so a credential steered away at its learned capacity becomes eligible again. With a
+// learned window the counters keep restarting every window, since a credential nobody uses will
+// not report another exhaustion.
+func (model *quotaModel) rolloverLocked(now time.Time) {
+	if model.recoverAt.IsZero() || now.Before(model.recoverAt) {
+		return
+	}
+	model.resetAt = model.recoverAt
+	model.recoverAt = time.Time{}
+	if model.window > 0 {
+		model.recoverAt = model.resetAt.Add(model.window)
+		if !now.Before(model.recoverAt) {
+			skipped := now.Sub(model.resetAt) / model.window
+			model.resetAt = model.resetAt.Add(skipped * model.window)
+			model.recoverAt = model.resetAt.Add(model.window)
+		}
+	}
+	model.requests = 0
+	model.tokens = 0
+	model.requestsAtHeader = 0
+	model.tokensAtHeader = 0
+}
+
+// observeQuotaHeaders reads OpenAI- and Anthropic-style rate-limit headers from an upstream
+// response.
+func (m *Manager) observeQuotaHeaders(authID, provider string, headers http.Header, now time.Time) {
+	if len(headers) == 0 {
+		return
+	}
+	remainingRequests := headerInt(headers, "x-ratelimit-remaining-requests", "anthropic-ratelimit-requests-remaining")
+	remainingTokens := headerInt(headers, "x-ratelimit-remaining-tokens", "anthropic-ratelimit-tokens-remaining")
+	if remainingRequests < 0 && remainingTokens < 0 {
+		return
+	}
+	m.quota.mu.Lock()
+	defer m.quota.mu.Unlock()
+	model := m.quota.model(authID, provider)
+	model.headerAt = now
+	model.remainingRequests = remainingRequests
+	model.remainingTokens = remainingTokens
+	model.limitRequests = headerInt(headers, "x-ratelimit-limit-requests", "anthropic-ratelimit-requests-limit")
+	model.limitTokens = headerInt(headers, "x-ratelimit-limit-tokens", "anthropic-ratelimit-tokens-limit")
+	model.headerResetAt = headerReset(headers, now, "x-ratelimit-reset-requests", "anthropic-ratelimit-requests-reset")
+	model.requestsAtHeader = model.requests
+	model.tokensAtHeader = model.tokens
+}
+
+func headerInt(headers http.Header, keys ...string) int64 {
+	for _, key := range keys {
+		if raw := strings.TrimSpace(headers.Get(key)); raw != "" {
+			if value, err := strconv.ParseInt(raw, 10, 64); err == nil && value >= 0 {
+				return value
+			}
+		}
+	}
+	return -1
+}
+
+// headerReset parses a reset header given as a duration ("6m0s", "20ms"), seconds, or an
+// RFC 3339 timestamp.
+func headerReset(headers http.Header, now time.Time, keys ...string) time.Time {
+	for _, key := range keys {
+		raw := strings.TrimSpace(headers.Get(key))
+		if raw == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(raw); err == nil {
+			return now.Add(d)
+		}
+		if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+			return now.Add(time.Duration(seconds * float64(time.Second)))
+		}
+		if ts, err := time.Parse(time.RFC3339, raw); err == nil {
+			return ts
+		}
+	}
+	return time.Time{}
+}
+
+// forecastLocked computes the forecast of model at now.
+func (model *quotaModel) forecastLocked(authID string, now time.Time) QuotaForecast {
+	model.rolloverLocked(now)
+	forecast := QuotaForecast{
+		AuthID:             authID,
+		Provider:           model.provider,
+		RequestsSinceReset: model.requests,
+		TokensSinceReset:   model.tokens,
+		CapacityRequests:   model.capacityRequests,
+		CapacityTokens:     model.capacityTokens,
+		Exhaustions:        model.exhaustions,
+		LastExhaustedAt:    model.lastExhaustedAt,
+		WindowSeconds:      model.window.Seconds(),
+	}
+	if !model.recoverAt.IsZero() {
+		resetAt := model.recoverAt
+		forecast.NextResetAt = &resetAt
+	}
+	var requests, tokens int64
+	var first int64 = math.MaxInt64
+	cutoff := now.Add(-forecastRateWindow).Unix() / 60
+	for _, bucket := range model.buckets {
+		if bucket.minute < cutoff {
+			continue
+		}
+		requests += bucket.requests
+		tokens += bucket.tokens
+		if bucket.minute < first {
+			first = bucket.minute
+		}
+	}
+	if requests > 0 {
+		// Average over the observed span, at least one minute, at most the rate window.
+		minutes := math.Max(1, math.Min(forecastRateWindow.Minutes(), now.Sub(time.Unix(first*60, 0)).Minutes()))
+		forecast.RequestsPerMinute = float64(requests) / minutes
+		forecast.TokensPerMinute = float64(tokens) / minutes
+	}
+
+	headersFresh := !model.headerAt.IsZero() && (model.headerResetAt.IsZero() || now.Before(model.headerResetAt))
+	if headersFresh && model.remainingRequests >= 0 {
+		remaining := float64(model.remainingRequests - (model.requests - model.requestsAtHeader))
+		forecast.RemainingRequests = &remaining
+	} else if model.capacityRequests > 0 {
+		remaining := model.capacityRequests - float64(model.requests)
+		forecast.RemainingRequests = &remaining
+	}
+	if headersFresh && model.remainingTokens >= 0 {
+		remaining := float64(model.remainingTokens - (model.tokens - model.tokensAtHeader))
+		forecast.RemainingTokens = &remaining
+	} else if model.capacityTokens > 0 {
+		remaining := model.capacityTokens - float64(model.tokens)
+		forecast.RemainingTokens = &remaining
+	}
+
+	var tte *float64
+	consider := func(remaining *float64, perMinute float64) {
+		if remaining == nil {
+			return
+		}
+		seconds := 0.0
+		if *remaining > 0 {
+			if perMinute <= 0 {
+				return
+			}
+			seconds = *remaining / perMinute * 60
+		}
+		if tte == nil || seconds < *tte {
+			tte = &seconds
+		}
+	}
+	consider(forecast.RemainingRequests, forecast.RequestsPerMinute)
+	consider(forecast.RemainingTokens, forecast.TokensPerMinute)
+	forecast.TimeToExhaustionSeconds = tte
+
+	requestCapacity, tokenCapacity := model.capacities()
+	for _, pair := range []struct {
+		remaining *float64
+		capacity  float64
+	}{{forecast.RemainingRequests, requestCapacity}, {forecast.RemainingTokens, tokenCapacity}} {
+		if pair.remaining == nil || pair.capacity <= 0 {
+			continue
+		}
+		percent := math.Max(0, *pair.remaining/pair.capacity*100)
+		if forecast.RemainingPercent == nil || percent < *forecast.RemainingPercent {
+			forecast.RemainingPercent = &percent
+		}
+	}
+	return forecast
+}
+
+// capacities returns the request and token capacity, preferring limits reported by upstream
+// headers over learned capacity.
+func (model *quotaModel) capacities() (float64, float64) {
+	requestCapacity, tokenCapacity := model.capacityRequests, model.capacityTokens
+	if model.limitRequests > 0 {
+		requestCapacity = float64(model.limitRequests)
+	}
+	if model.limitTokens > 0 {
+		tokenCapacity = float64(model.limitTokens)
+	}
+	return requestCapacity, tokenCapacity
+}
+
+// nearLimit reports whether forecast says the auth will run out within rotateBefore or has less
+// than reservePercent of its known capacity left.
+func (model *quotaModel) nearLimit(forecast QuotaForecast, rotateBefore time.Duration, reservePercent int) bool {
+	if forecast.TimeToExhaustionSeconds != nil && *forecast.TimeToExhaustionSeconds <= rotateBefore.Seconds() {
+		return true
+	}
+	below := func(remaining *float64, capacity float64) bool {
+		return remaining != nil && capacity > 0 && *remaining < capacity*float64(reservePercent)/100
+	}
+	requestCapacity, tokenCapacity := model.capacities()
+	return below(forecast.RemainingRequests, requestCapacity) || below(forecast.RemainingTokens, tokenCapacity)
+}
+
+func forecastSettings(cfg internalconfig.QuotaForecastConfig) (time.Duration, int) {
+	rotateBefore, reservePercent := defaultForecastRotateBefore, defaultForecastReservePercent
+	if cfg.RotateBefore > 0 {
+		rotateBefore = time.Duration(cfg.RotateBefore) * time.Second
+	}
+	if cfg.ReservePercent > 0 {
+		reservePercent = cfg.ReservePercent
+	}
+	return rotateBefore, reservePercent
+}
+
+func (m *Manager) forecastConfig() internalconfig.QuotaForecastConfig {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil {
+		return internalconfig.QuotaForecastConfig{}
+	}
+	return cfg.QuotaForecast
+}
+
+// QuotaForecasts returns the quota forecast of every auth with recorded usage or quota signals.
+func (m *Manager) QuotaForecasts() []QuotaForecast {
+	if m == nil {
+		return nil
+	}
+	rotateBefore, reservePercent := forecastSettings(m.forecastConfig())
+	now := time.Now()
+	m.quota.mu.Lock()
+	out := make([]QuotaForecast, 0, len(m.quota.models))
+	for authID, model := range m.quota.models {
+		forecast := model.forecastLocked(authID, now)
+		forecast.NearLimit = model.nearLimit(forecast, rotateBefore, reservePercent)
+		out = append(out, forecast)
+	}
+	m.quota.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].AuthID < out[j].AuthID })
+	return out
+}
+
+// QuotaForecast returns the quota forecast of one auth, if it has recorded usage or quota signals.
+func (m *Manager) QuotaForecast(authID string) (QuotaForecast, bool) {
+	if m == nil || authID == "" {
+		return QuotaForecast{}, false
+	}
+	rotateBefore, reservePercent := forecastSettings(m.forecastConfig())
+	m.quota.mu.Lock()
+	defer m.quota.mu.Unlock()
+	model := m.quota.models[authID]
+	if model == nil {
+		return QuotaForecast{}, false
+	}
+	forecast := model.forecastLocked(authID, time.Now())
+	forecast.NearLimit = model.nearLimit(forecast, rotateBefore, reservePercent)
+	return forecast, true
+}
+
+// preferAuthsWithQuota drops candidates forecast to be near their quota limit, unless that would
+// leave none, so the selector rotates away from them before they fail.
+func (m *Manager) preferAuthsWithQuota(candidates []*Auth) []*Auth {
+	cfg := m.forecastConfig()
+	if !cfg.Enabled || len(candidates) < 2 {
+		return candidates
+	}
+	rotateBefore, reservePercent := forecastSettings(cfg)
+	now := time.Now()
+	m.quota.mu.Lock()
+	defer m.quota.mu.Unlock()
+	if len(m.quota.models) == 0 {
+		return candidates
+	}
+	kept := make([]*Auth, 0, len(candidates))
+	for _, candidate := range candidates {
+		if model := m.quota.models[candidate.ID]; model != nil && model.nearLimit(model.forecastLocked(candidate.ID, now), rotateBefore, reservePercent) {
+			continue
+		}
+		kept = append(kept, candidate)
+	}
+	if len(kept) == 0 {
+		return candidates
+	}
+	return kept
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestQuotaForecast_LearnsCapacityAndSteersAway(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(&internalconfig.Config{QuotaForecast: internalconfig.QuotaForecastConfig{Enabled: true}})
+	now := time.Now()
+	use := func(authID string, n int) {
+		for i := 0; i < n; i++ {
+			m.HandleUsage(ctx, coreusage.Record{AuthID: authID, Provider: "antigravity", RequestedAt: now, Detail: coreusage.Detail{TotalTokens: 100}})
+		}
+	}
+
+	// Two exhaustions teach a capacity of 10 requests; the first only starts counting.
+	m.observeQuotaExhausted("busy", "antigravity", now, nil, time.Time{})
+	use("busy", 10)
+	m.observeQuotaExhausted("busy", "antigravity", now, nil, time.Time{})
+	use("busy", 9)
+	use("idle", 1)
+
+	forecasts := m.QuotaForecasts()
+	if len(forecasts) != 2 {
+		t.Fatalf("QuotaForecasts() = %+v, want two auths", forecasts)
+	}
+	busy := forecasts[0]
+	if busy.AuthID != "busy" || busy.CapacityRequests != 10 || busy.RemainingRequests == nil || *busy.RemainingRequests != 1 {
+		t.Fatalf("busy forecast = %+v, want capacity 10 with 1 request left", busy)
+	}
+	if busy.TimeToExhaustionSeconds == nil || !busy.NearLimit {
+		t.Fatalf("busy forecast = %+v, want a near-limit time to exhaustion", busy)
+	}
+	if idle := forecasts[1]; idle.NearLimit || idle.TimeToExhaustionSeconds != nil {
+		t.Fatalf("idle forecast = %+v, want no forecast without capacity signal", idle)
+	}
+
+	kept := m.preferAuthsWithQuota([]*Auth{{ID: "busy"}, {ID: "idle"}})
+	if len(kept) != 1 || kept[0].ID != "idle" {
+		t.Fatalf("preferAuthsWithQuota() kept %v, want only idle", kept)
+	}
+	if kept = m.preferAuthsWithQuota([]*Auth{{ID: "busy"}}); len(kept) != 1 {
+		t.Fatal("preferAuthsWithQuota() must not leave zero candidates")
+	}
+}
+
+func TestQuotaForecast_UsesRateLimitHeaders(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, nil, nil)
+	now := time.Now()
+	headers := http.Header{}
+	headers.Set("anthropic-ratelimit-requests-remaining", "50")
+	headers.Set("anthropic-ratelimit-requests-limit", "1000")
+	headers.Set("x-ratelimit-reset-requests", "1h")
+
+	m.observeQuotaHeaders("claude-auth", "claude", headers, now)
+	for i := 0; i < 10; i++ {
+		m.HandleUsage(ctx, coreusage.Record{AuthID: "claude-auth", Provider: "claude", RequestedAt: now})
+	}
+
+	forecast := m.QuotaForecasts()[0]
+	if forecast.RemainingRequests == nil || *forecast.RemainingRequests != 40 {
+		t.Fatalf("RemainingRequests = %v, want 40 after ten requests", forecast.RemainingRequests)
+	}
+	if !forecast.NearLimit {
+		t.Fatalf("forecast = %+v, want near limit below 5%% of the advertised limit", forecast)
+	}
+}
+
+func TestQuotaForecast_CountersRestartAfterLearnedWindow(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(&internalconfig.Config{QuotaForecast: internalconfig.QuotaForecastConfig{Enabled: true}})
+	second := time.Now().Add(-30 * time.Minute)
+	first := second.Add(-time.Hour)
+	use := func(at time.Time, n int) {
+		for i := 0; i < n; i++ {
+			m.HandleUsage(ctx, coreusage.Record{AuthID: "busy", Provider: "antigravity", RequestedAt: at})
+		}
+	}
+
+	// Two exhaustions an hour apart teach a capacity of 10 requests and a one hour window.
+	m.observeQuotaExhausted("busy", "antigravity", first, nil, first.Add(time.Second))
+	use(first.Add(time.Minute), 10)
+	m.observeQuotaExhausted("busy", "antigravity", second, nil, second.Add(time.Second))
+	use(second, 10)
+
+	if kept := m.preferAuthsWithQuota([]*Auth{{ID: "busy"}, {ID: "idle"}}); len(kept) != 1 || kept[0].ID != "idle" {
+		t.Fatalf("preferAuthsWithQuota() kept %v, want busy steered away at capacity", kept)
+	}
+	forecast, _ := m.QuotaForecast("busy")
+	if forecast.WindowSeconds != time.Hour.Seconds() || forecast.NextResetAt == nil || !forecast.NextResetAt.Equal(second.Add(time.Hour)) {
+		t.Fatalf("forecast = %+v, want a one hour window ending an hour after the last exhaustion", forecast)
+	}
+
+	// Once the window is over the counters restart, although busy never reported another 429.
+	m.quota.mu.Lock()
+	model := m.quota.models["busy"]
+	later := second.Add(time.Hour + time.Minute)
+	atCapacity := model.nearLimit(model.forecastLocked("busy", later), defaultForecastRotateBefore, defaultForecastReservePercent)
+	restarted := model.requests == 0 && model.recoverAt.Equal(second.Add(2*time.Hour))
+	m.quota.mu.Unlock()
+	if atCapacity || !restarted {
+		t.Fatalf("after the window: near limit %v, counters restarted %v; want busy eligible again", atCapacity, restarted)
+	}
+}
+
+func TestMarkResult_TooManyRequestsSchedulesQuotaReset(t *testing.T) {
+	model := uniqueTestModel(t)
+	m := NewManager(nil, nil, nil)
+	registerTestAuthForProviderModel(t, m, "reset-auth", "antigravity", model)
+
+	retryAfter := 90 * time.Second
+	before := time.Now()
+	m.MarkResult(context.Background(), Result{
+		AuthID:     "reset-auth",
+		Provider:   "antigravity",
+		Model:      model,
+		RetryAfter: &retryAfter,
+		Error:      &Error{Message: "quota", HTTPStatus: http.StatusTooManyRequests},
+	})
+
+	forecast, ok := m.QuotaForecast("reset-auth")
+	if !ok || forecast.NextResetAt == nil || forecast.NextResetAt.Before(before.Add(retryAfter)) || forecast.NextResetAt.After(time.Now().Add(retryAfter)) {
+		t.Fatalf("forecast = %+v, want the counters to restart after the 429 retry hint", forecast)
+	}
+}
+
+func TestRetryAfterFromError_ReadsHeadersAndBodies(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want time.Duration
+	}{
+		"model cooldown headers": {newModelCooldownError("m", "auggie", 42*time.Second), 42 * time.Second},
+		"model cooldown body": {
+			&Error{HTTPStatus: http.StatusTooManyRequests, Message: `{"error":{"code":"model_cooldown","reset_seconds":17}}`},
+			17 * time.Second,
+		},
+		"google retry info": {
+			&Error{HTTPStatus: http.StatusTooManyRequests, Message: `{"error":{"details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"3.5s"}]}}`},
+			3500 * time.Millisecond,
+		},
+	}
+	for name, tc := range cases {
+		got := retryAfterFromError(tc.err)
+		if got == nil || *got != tc.want {
+			t.Fatalf("%s: retryAfterFromError() = %v, want %v", name, got, tc.want)
+		}
+	}
+	if got := retryAfterFromError(&Error{HTTPStatus: http.StatusBadRequest, Message: `{"error":{"reset_seconds":5}}`}); got != nil {
+		t.Fatalf("retryAfterFromError() = %v for a 400, want nil", *got)
+	}
+}
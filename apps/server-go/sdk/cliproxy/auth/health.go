@@ -0,0 +1,391 @@
+package auth
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
+)
+
+const (
+	// healthTimelineRetention bounds how far back health events are kept.
+	healthTimelineRetention = 7 * 24 * time.Hour
+	// healthTimelineMaxEvents bounds the number of events kept per auth.
+	healthTimelineMaxEvents = 2000
+	// clusterHealthReasonPrefix marks events mirrored from a peer's cooldown. The peer saves the
+	// original event, so mirrors are not written to the cluster backend.
+	clusterHealthReasonPrefix = "cluster: "
+)
+
+// HealthEventKind identifies a state transition recorded in an auth's health timeline.
+type HealthEventKind string
+
+const (
+	// HealthEventStatus records a change of Auth.Status.
+	HealthEventStatus HealthEventKind = "status"
+	// HealthEventQuotaExceeded records a quota error and the resulting cooldown.
+	HealthEventQuotaExceeded HealthEventKind = "quota_exceeded"
+	// HealthEventSuspended records a model or auth suspended for a non-quota reason
+	// (unauthorized, payment_required, not_found).
+	HealthEventSuspended HealthEventKind = "suspended"
+	// HealthEventCooldown records a short cooldown after a transient upstream error.
+	HealthEventCooldown HealthEventKind = "cooldown"
+	// HealthEventResumed records a model or auth becoming available again.
+	HealthEventResumed HealthEventKind = "resumed"
+	// HealthEventRefreshSucceeded records a successful credential refresh.
+	HealthEventRefreshSucceeded HealthEventKind = "refresh_succeeded"
+	// HealthEventRefreshFailed records a failed credential refresh.
+	HealthEventRefreshFailed HealthEventKind = "refresh_failed"
+)
+
+// HealthEvent is one timestamped entry of an auth's health timeline.
+type HealthEvent struct {
+	At   time.Time       `json:"at"`
+	Kind HealthEventKind `json:"kind"`
+	// Model is set for per-model transitions; empty means the whole auth.
+	Model string `json:"model,omitempty"`
+	// From and Status carry the previous and new Auth.Status of status events.
+	From   Status `json:"from,omitempty"`
+	Status Status `json:"status,omitempty"`
+	Reason string `json:"reason"`
+	// Until is the NextRetryAfter of cooldown-like events.
+	Until      *time.Time `json:"until,omitempty"`
+	HTTPStatus int        `json:"http_status,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// HealthSummary aggregates an auth's health timeline over a window.
+type HealthSummary struct {
+	AuthID string    `json:"auth_id"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+	Status Status    `json:"status"`
+	// UptimePercent is the share of the window the auth was neither in error nor disabled.
+	UptimePercent float64 `json:"uptime_percent"`
+	// CooldownSeconds sums the time spent in cooldown across models; overlapping cooldowns of
+	// different models are counted separately.
+	CooldownSeconds float64 `json:"cooldown_seconds"`
+	// CooldownByModel breaks CooldownSeconds down by model; auth-level cooldowns use "".
+	CooldownByModel map[string]float64      `json:"cooldown_by_model,omitempty"`
+	Counts          map[HealthEventKind]int `json:"counts"`
+}
+
+// healthTimeline keeps recent health events per auth in memory. In cluster mode the events are
+// also saved to the cluster backend and restored at startup, so the timeline survives restarts
+// and covers every node; with the in-process backend it is lost when the process exits.
+type healthTimeline struct {
+	mu     sync.Mutex
+	events map[string][]HealthEvent
+	dirty  map[string]struct{}
+}
+
+func newHealthTimeline() *healthTimeline {
+	return &healthTimeline{events: make(map[string][]HealthEvent)}
+}
+
+func (t *healthTimeline) record(authID string, events ...HealthEvent) {
+	if t == nil || authID == "" || len(events) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events[authID] = trimHealthEvents(append(t.events[authID], events...))
+	if t.dirty == nil {
+		t.dirty = make(map[string]struct{})
+	}
+	t.dirty[authID] = struct{}{}
+}
+
+func (t *healthTimeline) list(authID string) []HealthEvent {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]HealthEvent(nil), t.events[authID]...)
+}
+
+// persist merges the timelines changed since the last call into their shared copies. It is a
+// no-op without a shared cluster backend.
+func (t *healthTimeline) persist() {
+	if t == nil || !cluster.Shared() {
+		return
+	}
+	t.mu.Lock()
+	pending := make(map[string][]HealthEvent, len(t.dirty))
+	for authID := range t.dirty {
+		pending[authID] = append([]HealthEvent(nil), t.events[authID]...)
+	}
+	t.dirty = nil
+	t.mu.Unlock()
+
+	for authID, local := range pending {
+		own := make([]HealthEvent, 0, len(local))
+		for _, event := range local {
+			if !strings.HasPrefix(event.Reason, clusterHealthReasonPrefix) {
+				own = append(own, event)
+			}
+		}
+		var shared []HealthEvent
+		cluster.Load(cluster.NamespaceHealth, authID, &shared)
+		cluster.Save(cluster.NamespaceHealth, authID, mergeHealthEvents(shared, own), healthTimelineRetention)
+	}
+}
+
+// restore merges the shared timelines into the local ones.
+func (t *healthTimeline) restore() {
+	if t == nil {
+		return
+	}
+	entries := cluster.Entries(cluster.NamespaceHealth)
+	for authID, raw := range entries {
+		var shared []HealthEvent
+		if err := json.Unmarshal(raw, &shared); err != nil || len(shared) == 0 {
+			continue
+		}
+		t.mu.Lock()
+		t.events[authID] = mergeHealthEvents(t.events[authID], shared)
+		t.mu.Unlock()
+	}
+}
+
+// mergeHealthEvents returns the union of a and b ordered by time, without duplicates.
+func mergeHealthEvents(a, b []HealthEvent) []HealthEvent {
+	merged := append(append(make([]HealthEvent, 0, len(a)+len(b)), a...), b...)
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].At.Before(merged[j].At) })
+	out := merged[:0]
+	for _, event := range merged {
+		duplicate := false
+		for k := len(out) - 1; k >= 0 && out[k].At.Equal(event.At); k-- {
+			if sameHealthEvent(out[k], event) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			out = append(out, event)
+		}
+	}
+	return trimHealthEvents(out)
+}
+
+func sameHealthEvent(a, b HealthEvent) bool {
+	return a.Kind == b.Kind && a.Model == b.Model && a.From == b.From && a.Status == b.Status && a.Reason == b.Reason
+}
+
+// trimHealthEvents drops events older than the retention before the latest one and keeps at
+// most healthTimelineMaxEvents.
+func trimHealthEvents(list []HealthEvent) []HealthEvent {
+	if len(list) == 0 {
+		return list
+	}
+	cutoff := list[len(list)-1].At.Add(-healthTimelineRetention)
+	drop := 0
+	for drop < len(list) && list[drop].At.Before(cutoff) {
+		drop++
+	}
+	if over := len(list) - drop - healthTimelineMaxEvents; over > 0 {
+		drop += over
+	}
+	if drop > 0 {
+		list = append([]HealthEvent(nil), list[drop:]...)
+	}
+	return list
+}
+
+// healthSnapshot captures the fields of an auth that health events are derived from.
+type healthSnapshot struct {
+	status           Status
+	authUnavailable  bool
+	modelUnavailable bool
+}
+
+func captureHealth(auth *Auth, model string) healthSnapshot {
+	snap := healthSnapshot{status: auth.Status, authUnavailable: auth.Unavailable}
+	if state := auth.ModelStates[model]; model != "" && state != nil {
+		snap.modelUnavailable = state.Unavailable
+	}
+	return snap
+}
+
+// resultHealthEvents derives the timeline entries for a MarkResult call from the auth state
+// before and after the result was applied.
+func resultHealthEvents(before healthSnapshot, auth *Auth, result Result, suspendReason string, now time.Time) []HealthEvent {
+	var events []HealthEvent
+	errMsg, httpStatus := "", 0
+	if result.Error != nil {
+		errMsg = result.Error.Message
+		httpStatus = statusCodeFromResult(result.Error)
+	}
+
+	if result.Success {
+		if (result.Model != "" && before.modelUnavailable) || (result.Model == "" && before.authUnavailable) {
+			events = append(events, HealthEvent{At: now, Kind: HealthEventResumed, Model: result.Model, Reason: "request succeeded"})
+		}
+	} else {
+		quotaExceeded, nextRetry, reason := auth.Quota.Exceeded, auth.NextRetryAfter, auth.StatusMessage
+		if state := auth.ModelStates[result.Model]; result.Model != "" && state != nil {
+			quotaExceeded, nextRetry, reason = state.Quota.Exceeded, state.NextRetryAfter, suspendReason
+		}
+		event := HealthEvent{At: now, Model: result.Model, Reason: reason, HTTPStatus: httpStatus, Error: errMsg}
+		if nextRetry.After(now) {
+			until := nextRetry
+			event.Until = &until
+		}
+		switch {
+		case httpStatus == 429 && quotaExceeded:
+			event.Kind = HealthEventQuotaExceeded
+		case httpStatus == 401 || httpStatus == 402 || httpStatus == 403 || httpStatus == 404:
+			event.Kind = HealthEventSuspended
+		case event.Until != nil:
+			event.Kind = HealthEventCooldown
+			if event.Reason == "" {
+				event.Reason = "transient upstream error"
+			}
+		}
+		if event.Kind != "" {
+			events = append(events, event)
+		}
+	}
+
+	if auth.Status != before.status {
+		event := HealthEvent{At: now, Kind: HealthEventStatus, From: before.status, Status: auth.Status, Reason: auth.StatusMessage, HTTPStatus: httpStatus, Error: errMsg}
+		if event.Reason == "" {
+			event.Reason = "request succeeded"
+			if !result.Success {
+				event.Reason = "request failed"
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// HealthTimeline returns the health events recorded for authID at or after since, oldest first.
+func (m *Manager) HealthTimeline(authID string, since time.Time) []HealthEvent {
+	if m == nil {
+		return nil
+	}
+	events := m.health.list(authID)
+	start := sort.Search(len(events), func(i int) bool { return !events[i].At.Before(since) })
+	return events[start:]
+}
+
+// HealthSummary aggregates the health timeline of authID over [since, now]. It returns false
+// when the auth is unknown.
+func (m *Manager) HealthSummary(authID string, since, now time.Time) (HealthSummary, bool) {
+	if m == nil {
+		return HealthSummary{}, false
+	}
+	auth, ok := m.GetByID(authID)
+	if !ok {
+		return HealthSummary{}, false
+	}
+	return summarizeHealth(auth, m.health.list(authID), since, now), true
+}
+
+func summarizeHealth(auth *Auth, events []HealthEvent, since, now time.Time) HealthSummary {
+	summary := HealthSummary{
+		AuthID: auth.ID,
+		Since:  since,
+		Until:  now,
+		Status: auth.Status,
+		Counts: make(map[HealthEventKind]int),
+	}
+	window := now.Sub(since)
+	if window <= 0 {
+		return summary
+	}
+	clipped := func(start, end time.Time) time.Duration {
+		if start.Before(since) {
+			start = since
+		}
+		if end.After(now) {
+			end = now
+		}
+		if !end.After(start) {
+			return 0
+		}
+		return end.Sub(start)
+	}
+
+	// Uptime: replay status transitions, starting from the status before the first one.
+	current := auth.Status
+	for _, event := range events {
+		if event.Kind == HealthEventStatus {
+			current = event.From
+			break
+		}
+	}
+	var downtime time.Duration
+	downSince := since
+	down := healthStatusDown(current)
+	for _, event := range events {
+		if !event.At.Before(since) && !event.At.After(now) {
+			summary.Counts[event.Kind]++
+		}
+		if event.Kind != HealthEventStatus {
+			continue
+		}
+		if down {
+			downtime += clipped(downSince, event.At)
+		}
+		down = healthStatusDown(event.Status)
+		downSince = event.At
+	}
+	if down {
+		downtime += clipped(downSince, now)
+	}
+	summary.UptimePercent = 100 * float64(window-downtime) / float64(window)
+
+	// Cooldowns: each cooldown-like event opens an interval until its retry time, cut short by a
+	// later cooldown or resume of the same model.
+	open := make(map[string]HealthEvent)
+	cooldown := make(map[string]time.Duration)
+	closeInterval := func(model string, at time.Time) {
+		event, ok := open[model]
+		if !ok {
+			return
+		}
+		end := *event.Until
+		if at.Before(end) {
+			end = at
+		}
+		cooldown[model] += clipped(event.At, end)
+		delete(open, model)
+	}
+	for _, event := range events {
+		switch event.Kind {
+		case HealthEventQuotaExceeded, HealthEventSuspended, HealthEventCooldown:
+			closeInterval(event.Model, event.At)
+			if event.Until != nil {
+				open[event.Model] = event
+			}
+		case HealthEventResumed:
+			closeInterval(event.Model, event.At)
+		}
+	}
+	for model := range open {
+		closeInterval(model, now)
+	}
+	var total time.Duration
+	for model, d := range cooldown {
+		if d <= 0 {
+			continue
+		}
+		if summary.CooldownByModel == nil {
+			summary.CooldownByModel = make(map[string]float64)
+		}
+		summary.CooldownByModel[model] = d.Seconds()
+		total += d
+	}
+	summary.CooldownSeconds = total.Seconds()
+	return summary
+}
+
+func healthStatusDown(status Status) bool {
+	return status == StatusError || status == StatusDisabled
+}
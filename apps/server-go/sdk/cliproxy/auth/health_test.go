@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMarkResult_RecordsHealthTimeline(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, nil, nil)
+	if _, err := m.Register(ctx, &Auth{ID: "health-auth", Provider: "antigravity", Status: StatusActive}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	model := "health-timeline-model"
+	start := time.Now()
+
+	m.MarkResult(ctx, Result{AuthID: "health-auth", Provider: "antigravity", Model: model, Error: &Error{HTTPStatus: 429, Message: "quota"}})
+	m.MarkResult(ctx, Result{AuthID: "health-auth", Provider: "antigravity", Model: model, Success: true})
+
+	events := m.HealthTimeline("health-auth", start)
+	var kinds []HealthEventKind
+	for _, event := range events {
+		kinds = append(kinds, event.Kind)
+	}
+	want := []HealthEventKind{HealthEventQuotaExceeded, HealthEventStatus, HealthEventResumed, HealthEventStatus}
+	if len(kinds) != len(want) {
+		t.Fatalf("timeline kinds = %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("timeline kinds = %v, want %v", kinds, want)
+		}
+	}
+	if quota := events[0]; quota.Model != model || quota.Reason != "quota" || quota.Until == nil || quota.HTTPStatus != 429 {
+		t.Fatalf("quota event = %+v", quota)
+	}
+	if status := events[1]; status.From != StatusActive || status.Status != StatusError {
+		t.Fatalf("status event = %+v, want active -> error", status)
+	}
+
+	summary, ok := m.HealthSummary("health-auth", start, time.Now())
+	if !ok {
+		t.Fatal("HealthSummary() found no auth")
+	}
+	if summary.Counts[HealthEventQuotaExceeded] != 1 || summary.Status != StatusActive {
+		t.Fatalf("summary = %+v", summary)
+	}
+}
+
+func TestSummarizeHealth_UptimeAndCooldown(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := base.Add(3 * time.Hour)
+	events := []HealthEvent{
+		{At: base.Add(1 * time.Hour), Kind: HealthEventQuotaExceeded, Model: "m", Until: &until},
+		{At: base.Add(1 * time.Hour), Kind: HealthEventStatus, From: StatusActive, Status: StatusError},
+		{At: base.Add(2 * time.Hour), Kind: HealthEventResumed, Model: "m"},
+		{At: base.Add(2 * time.Hour), Kind: HealthEventStatus, From: StatusError, Status: StatusActive},
+	}
+	auth := &Auth{ID: "a", Status: StatusActive}
+
+	summary := summarizeHealth(auth, events, base, base.Add(4*time.Hour))
+
+	if math.Abs(summary.UptimePercent-75) > 0.001 {
+		t.Fatalf("UptimePercent = %v, want 75", summary.UptimePercent)
+	}
+	if summary.CooldownSeconds != 3600 || summary.CooldownByModel["m"] != 3600 {
+		t.Fatalf("cooldown = %v / %v, want one hour cut short by the resume", summary.CooldownSeconds, summary.CooldownByModel)
+	}
+	if summary.Counts[HealthEventStatus] != 2 {
+		t.Fatalf("Counts = %v", summary.Counts)
+	}
+}
@@ -0,0 +1,330 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	log "github.com/sirupsen/logrus"
+)
+
+// HealthProber is implemented by executors that can check a credential with a cheap upstream
+// request. Probe returns the auth with renewed credentials when it had to refresh them (nil
+// otherwise), and an error carrying the upstream status when the credential is unusable.
+type HealthProber interface {
+	Probe(ctx context.Context, auth *Auth) (*Auth, error)
+}
+
+const (
+	defaultProbeInterval    = 5 * time.Minute
+	defaultProbeMaxPerCycle = 10
+	defaultProbeTimeout     = 15 * time.Second
+)
+
+// ProbeState describes the most recent probe of one auth.
+type ProbeState struct {
+	AuthID      string    `json:"auth_id"`
+	Provider    string    `json:"provider"`
+	LastProbeAt time.Time `json:"last_probe_at"`
+	Healthy     bool      `json:"healthy"`
+	HTTPStatus  int       `json:"http_status,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	LatencyMs   int64     `json:"latency_ms"`
+	Refreshed   bool      `json:"refreshed,omitempty"`
+	Probes      int64     `json:"probes"`
+}
+
+// ProbeStatus reports the probe schedule and the upstream calls spent on it.
+type ProbeStatus struct {
+	Enabled         bool         `json:"enabled"`
+	IntervalSeconds int          `json:"interval_seconds"`
+	MaxPerCycle     int          `json:"max_per_cycle"`
+	TimeoutSeconds  int          `json:"timeout_seconds"`
+	Providers       []string     `json:"providers,omitempty"`
+	Running         bool         `json:"running"`
+	LastCycleAt     time.Time    `json:"last_cycle_at"`
+	NextCycleAt     time.Time    `json:"next_cycle_at"`
+	Cycles          int64        `json:"cycles"`
+	Probes          int64        `json:"probes"`
+	Refreshes       int64        `json:"refreshes"`
+	Failures        int64        `json:"failures"`
+	Auths           []ProbeState `json:"auths"`
+}
+
+// healthProber holds the probe schedule and per-auth results.
+type healthProber struct {
+	mu        sync.Mutex
+	running   bool
+	lastCycle time.Time
+	nextCycle time.Time
+	cycles    int64
+	probes    int64
+	refreshes int64
+	failures  int64
+	states    map[string]*ProbeState
+}
+
+// probeSettings resolves cfg against the defaults.
+func probeSettings(cfg internalconfig.HealthProbeConfig) (interval time.Duration, maxPerCycle int, timeout time.Duration) {
+	interval, maxPerCycle, timeout = defaultProbeInterval, defaultProbeMaxPerCycle, defaultProbeTimeout
+	if cfg.Interval > 0 {
+		interval = time.Duration(cfg.Interval) * time.Second
+	}
+	if cfg.MaxPerCycle > 0 {
+		maxPerCycle = cfg.MaxPerCycle
+	}
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	return interval, maxPerCycle, timeout
+}
+
+func (m *Manager) probeConfig() internalconfig.HealthProbeConfig {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil {
+		return internalconfig.HealthProbeConfig{}
+	}
+	return cfg.HealthProbe
+}
+
+func probeLeaseName(authID string) string {
+	return "probe/" + authID
+}
+
+// checkProbes starts a probe cycle when probing is enabled and the previous cycle is due.
+func (m *Manager) checkProbes(ctx context.Context, now time.Time) {
+	cfg := m.probeConfig()
+	if !cfg.Enabled {
+		return
+	}
+	interval, _, _ := probeSettings(cfg)
+	m.prober.mu.Lock()
+	if m.prober.running || now.Before(m.prober.nextCycle) {
+		m.prober.mu.Unlock()
+		return
+	}
+	m.prober.running = true
+	m.prober.lastCycle = now
+	m.prober.nextCycle = now.Add(interval)
+	m.prober.mu.Unlock()
+	go m.runProbeCycle(ctx, cfg)
+}
+
+// runProbeCycle probes the least recently probed auths until the cycle's budget is spent.
+func (m *Manager) runProbeCycle(ctx context.Context, cfg internalconfig.HealthProbeConfig) {
+	defer func() {
+		m.prober.mu.Lock()
+		m.prober.running = false
+		m.prober.cycles++
+		m.prober.mu.Unlock()
+	}()
+	interval, budget, timeout := probeSettings(cfg)
+	for _, candidate := range m.probeCandidates(cfg) {
+		if budget <= 0 || ctx.Err() != nil {
+			return
+		}
+		// In cluster mode each credential is probed by one node per interval.
+		if !cluster.AcquireLease(probeLeaseName(candidate.auth.ID), interval) {
+			continue
+		}
+		budget -= m.probeAuth(ctx, candidate.auth, candidate.prober, interval, timeout)
+	}
+}
+
+type probeCandidate struct {
+	auth   *Auth
+	prober HealthProber
+	last   time.Time
+}
+
+func (m *Manager) probeCandidates(cfg internalconfig.HealthProbeConfig) []probeCandidate {
+	providers := make(map[string]struct{}, len(cfg.Providers))
+	for _, provider := range cfg.Providers {
+		if provider = strings.ToLower(strings.TrimSpace(provider)); provider != "" {
+			providers[provider] = struct{}{}
+		}
+	}
+	var candidates []probeCandidate
+	for _, auth := range m.snapshotAuths() {
+		if auth.Disabled || auth.Status == StatusDisabled {
+			continue
+		}
+		if _, ok := providers[strings.ToLower(auth.Provider)]; len(providers) > 0 && !ok {
+			continue
+		}
+		prober, ok := m.executorFor(auth.Provider).(HealthProber)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, probeCandidate{auth: auth, prober: prober})
+	}
+	m.prober.mu.Lock()
+	for i := range candidates {
+		if state := m.prober.states[candidates[i].auth.ID]; state != nil {
+			candidates[i].last = state.LastProbeAt
+		}
+	}
+	m.prober.mu.Unlock()
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].last.Before(candidates[j].last) })
+	return candidates
+}
+
+// probeAuth probes one auth and applies the result. Credentials expiring before the next cycle
+// are refreshed first, and a rejected credential is refreshed and probed once more. It returns
+// the number of upstream probes issued.
+func (m *Manager) probeAuth(ctx context.Context, auth *Auth, prober HealthProber, interval, timeout time.Duration) int {
+	refreshed, attempted := false, false
+	refresh := func() {
+		if attempted || !cluster.AcquireLease(refreshLeaseName(auth.ID), cluster.LeaseTTL()) {
+			return
+		}
+		attempted = true
+		if !m.refreshAuth(ctx, auth.ID) {
+			return
+		}
+		refreshed = true
+		if current, ok := m.GetByID(auth.ID); ok {
+			auth = current
+		}
+	}
+	if typ, _ := auth.AccountInfo(); typ != "api_key" {
+		if expiry, ok := auth.ExpirationTime(); ok && time.Until(expiry) < interval {
+			refresh()
+		}
+	}
+
+	start := time.Now()
+	updated, err := m.runProbe(ctx, prober, auth, timeout)
+	issued := 1
+	if statusCodeFromError(err) == 401 && !refreshed {
+		refresh()
+		if refreshed {
+			updated, err = m.runProbe(ctx, prober, auth, timeout)
+			issued++
+		}
+	}
+	if updated != nil {
+		refreshed = true
+		if _, errUpdate := m.Update(ctx, updated); errUpdate != nil {
+			log.Debugf("health probe: update %s: %v", auth.ID, errUpdate)
+		}
+	}
+	m.recordProbe(auth, err, time.Since(start), refreshed, issued)
+	m.applyProbeResult(ctx, auth, err)
+	return issued
+}
+
+func (m *Manager) runProbe(ctx context.Context, prober HealthProber, auth *Auth, timeout time.Duration) (*Auth, error) {
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return prober.Probe(probeCtx, auth.Clone())
+}
+
+func (m *Manager) recordProbe(auth *Auth, err error, latency time.Duration, refreshed bool, issued int) {
+	m.prober.mu.Lock()
+	defer m.prober.mu.Unlock()
+	if m.prober.states == nil {
+		m.prober.states = make(map[string]*ProbeState)
+	}
+	state := m.prober.states[auth.ID]
+	if state == nil {
+		state = &ProbeState{AuthID: auth.ID}
+		m.prober.states[auth.ID] = state
+	}
+	state.Provider = auth.Provider
+	state.LastProbeAt = time.Now()
+	state.Healthy = err == nil
+	state.HTTPStatus = statusCodeFromError(err)
+	state.Error = ""
+	if err != nil {
+		state.Error = err.Error()
+		m.prober.failures++
+	}
+	state.LatencyMs = latency.Milliseconds()
+	state.Refreshed = refreshed
+	state.Probes += int64(issued)
+	m.prober.probes += int64(issued)
+	if refreshed {
+		m.prober.refreshes++
+	}
+}
+
+// isCredentialFailure reports whether status means the credential itself was rejected.
+func isCredentialFailure(status int) bool {
+	return status == 401 || status == 402 || status == 403
+}
+
+// applyProbeResult updates auth and model state from a probe. A rejected credential suspends
+// every model of the auth. A working credential lifts suspensions caused by rejected
+// credentials; quota cooldowns stay because a model-list call says nothing about generation
+// quota. Transient probe failures leave the state alone.
+func (m *Manager) applyProbeResult(ctx context.Context, auth *Auth, err error) {
+	if err != nil {
+		status := statusCodeFromError(err)
+		if !isCredentialFailure(status) {
+			return
+		}
+		resultErr := &Error{HTTPStatus: status, Message: "health probe: " + err.Error()}
+		models := registry.GetGlobalRegistry().GetModelsForClient(auth.ID)
+		if len(models) == 0 {
+			m.MarkResult(ctx, Result{AuthID: auth.ID, Provider: auth.Provider, Error: resultErr, Probe: true})
+			return
+		}
+		for _, model := range models {
+			m.MarkResult(ctx, Result{AuthID: auth.ID, Provider: auth.Provider, Model: model.ID, Error: resultErr, Probe: true})
+		}
+		return
+	}
+
+	current, ok := m.GetByID(auth.ID)
+	if !ok {
+		return
+	}
+	lifted := false
+	for model, state := range current.ModelStates {
+		if state != nil && state.Unavailable && state.LastError != nil && isCredentialFailure(state.LastError.HTTPStatus) {
+			m.MarkResult(ctx, Result{AuthID: auth.ID, Provider: auth.Provider, Model: model, Success: true, Probe: true})
+			lifted = true
+		}
+	}
+	if !lifted && len(current.ModelStates) == 0 && current.Status == StatusError && current.LastError != nil && isCredentialFailure(current.LastError.HTTPStatus) {
+		m.MarkResult(ctx, Result{AuthID: auth.ID, Provider: auth.Provider, Success: true, Probe: true})
+		lifted = true
+	}
+	if !lifted {
+		m.hook.OnResult(ctx, Result{AuthID: auth.ID, Provider: auth.Provider, Success: true, Probe: true})
+	}
+}
+
+// ProbeStatus returns the probe configuration, schedule and per-auth results.
+func (m *Manager) ProbeStatus() ProbeStatus {
+	cfg := m.probeConfig()
+	interval, maxPerCycle, timeout := probeSettings(cfg)
+	m.prober.mu.Lock()
+	defer m.prober.mu.Unlock()
+	status := ProbeStatus{
+		Enabled:         cfg.Enabled,
+		IntervalSeconds: int(interval / time.Second),
+		MaxPerCycle:     maxPerCycle,
+		TimeoutSeconds:  int(timeout / time.Second),
+		Providers:       cfg.Providers,
+		Running:         m.prober.running,
+		LastCycleAt:     m.prober.lastCycle,
+		NextCycleAt:     m.prober.nextCycle,
+		Cycles:          m.prober.cycles,
+		Probes:          m.prober.probes,
+		Refreshes:       m.prober.refreshes,
+		Failures:        m.prober.failures,
+		Auths:           make([]ProbeState, 0, len(m.prober.states)),
+	}
+	for _, state := range m.prober.states {
+		status.Auths = append(status.Auths, *state)
+	}
+	sort.Slice(status.Auths, func(i, j int) bool { return status.Auths[i].AuthID < status.Auths[j].AuthID })
+	return status
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+type probeStatusError int
+
+func (e probeStatusError) Error() string   { return "probe failed" }
+func (e probeStatusError) StatusCode() int { return int(e) }
+
+type probingExecutor struct {
+	replaceAwareExecutor
+	status     int
+	probes     int
+	refreshes  int
+	refreshErr error
+}
+
+func (e *probingExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	e.refreshes++
+	if e.refreshErr != nil {
+		return nil, e.refreshErr
+	}
+	return auth, nil
+}
+
+func (e *probingExecutor) Probe(context.Context, *Auth) (*Auth, error) {
+	e.probes++
+	if e.status != 0 {
+		return nil, probeStatusError(e.status)
+	}
+	return nil, nil
+}
+
+func TestRunProbeCycle_MarksRejectedCredentialAndRecovers(t *testing.T) {
+	ctx := context.Background()
+	exec := &probingExecutor{replaceAwareExecutor: replaceAwareExecutor{id: "probe-provider"}, status: 401}
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(exec)
+	if _, err := m.Register(ctx, &Auth{ID: "probe-auth", Provider: "probe-provider", Status: StatusActive}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if _, err := m.Register(ctx, &Auth{ID: "probe-disabled", Provider: "probe-provider", Disabled: true}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	cfg := internalconfig.HealthProbeConfig{Enabled: true}
+
+	m.runProbeCycle(ctx, cfg)
+
+	if exec.probes != 2 || exec.refreshes != 1 {
+		t.Fatalf("probes = %d, refreshes = %d; want a refresh and one retry for the rejected credential", exec.probes, exec.refreshes)
+	}
+	auth, _ := m.GetByID("probe-auth")
+	if auth.Status != StatusError || auth.LastError == nil || auth.LastError.HTTPStatus != 401 {
+		t.Fatalf("auth after failed probe = status %q, last error %+v", auth.Status, auth.LastError)
+	}
+
+	exec.status = 0
+	m.runProbeCycle(ctx, cfg)
+
+	auth, _ = m.GetByID("probe-auth")
+	if auth.Status != StatusActive || auth.Unavailable {
+		t.Fatalf("auth after healthy probe = status %q, unavailable %v", auth.Status, auth.Unavailable)
+	}
+	status := m.ProbeStatus()
+	if status.Probes != 3 || status.Cycles != 2 || len(status.Auths) != 1 || !status.Auths[0].Healthy {
+		t.Fatalf("ProbeStatus() = %+v", status)
+	}
+}
+
+func TestRunProbeCycle_FailedRefreshIsNotCounted(t *testing.T) {
+	ctx := context.Background()
+	exec := &probingExecutor{replaceAwareExecutor: replaceAwareExecutor{id: "probe-provider"}, status: 401, refreshErr: errors.New("invalid_grant")}
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(exec)
+	if _, err := m.Register(ctx, &Auth{ID: "probe-auth", Provider: "probe-provider", Status: StatusActive}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	m.runProbeCycle(ctx, internalconfig.HealthProbeConfig{Enabled: true})
+
+	if exec.probes != 1 || exec.refreshes != 1 {
+		t.Fatalf("probes = %d, refreshes = %d; want no retry after a failed refresh", exec.probes, exec.refreshes)
+	}
+	status := m.ProbeStatus()
+	if status.Refreshes != 0 || len(status.Auths) != 1 || status.Auths[0].Refreshed {
+		t.Fatalf("ProbeStatus() = %+v, want the failed refresh not reported", status)
+	}
+}
+
+func TestRunProbeCycle_RespectsBudget(t *testing.T) {
+	ctx := context.Background()
+	exec := &probingExecutor{replaceAwareExecutor: replaceAwareExecutor{id: "probe-provider"}}
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(exec)
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := m.Register(ctx, &Auth{ID: id, Provider: "probe-provider"}); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+	cfg := internalconfig.HealthProbeConfig{Enabled: true, MaxPerCycle: 2}
+
+	m.runProbeCycle(ctx, cfg)
+	m.runProbeCycle(ctx, cfg)
+
+	if exec.probes != 4 {
+		t.Fatalf("probes = %d, want 2 per cycle", exec.probes)
+	}
+	for _, state := range m.ProbeStatus().Auths {
+		if state.Probes == 0 {
+			t.Fatalf("auth %s never probed; least recently probed auths should go first", state.AuthID)
+		}
+	}
+	if len(m.ProbeStatus().Auths) != 3 {
+		t.Fatalf("probed auths = %d, want 3", len(m.ProbeStatus().Auths))
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 )
@@ -22,6 +23,25 @@ type RoundRobinSelector struct {
 	mu      sync.Mutex
 	cursors map[string]int
 	maxKeys int
+
+	leases        map[string]*cursorLease // blocks of the shared cursors reserved by this node
+	sharedRetryAt time.Time               // shared cursors are skipped until then after a failure
+}
+
+const (
+	// cursorLeaseSize is the number of shared cursor positions a node reserves at once, so the
+	// backend is consulted once per block of picks instead of on every pick.
+	cursorLeaseSize = 32
+	// cursorLeaseTTL expires shared cursors that are no longer used.
+	cursorLeaseTTL = 24 * time.Hour
+	// cursorRetryDelay keeps picks on the local cursors after the shared backend failed.
+	cursorRetryDelay = 10 * time.Second
+	cursorWrap       = 2_147_483_640
+)
+
+// cursorLease is a block [next, end) of a shared cursor reserved by this node.
+type cursorLease struct {
+	next, end int64
 }
 
 // FillFirstSelector selects the first available credential (deterministic ordering).
@@ -272,56 +292,92 @@ func (s *RoundRobinSelector) Pick(ctx context.Context, provider, model string, o
 	}
 	available = preferCodexWebsocketAuths(ctx, provider, available)
 	key := provider + ":" + canonicalModelKey(model)
-	s.mu.Lock()
-	if s.cursors == nil {
-		s.cursors = make(map[string]int)
-	}
-	limit := s.maxKeys
-	if limit <= 0 {
-		limit = 4096
-	}
 
 	// Check if any available auth has gemini_virtual_parent attribute,
 	// indicating gemini-cli virtual auths that should use credential-level polling.
 	groups, parentOrder := groupByVirtualParent(available)
 	if len(parentOrder) > 1 {
 		// Two-level round-robin: first select a credential group, then pick within it.
-		groupKey := key + "::group"
-		s.ensureCursorKey(groupKey, limit)
-		if _, exists := s.cursors[groupKey]; !exists {
-			// Seed with a random initial offset so the starting credential is randomized.
-			s.cursors[groupKey] = rand.IntN(len(parentOrder))
-		}
-		groupIndex := s.cursors[groupKey]
-		if groupIndex >= 2_147_483_640 {
-			groupIndex = 0
-		}
-		s.cursors[groupKey] = groupIndex + 1
-
+		groupIndex := s.nextCursor(key+"::group", len(parentOrder))
 		selectedParent := parentOrder[groupIndex%len(parentOrder)]
 		group := groups[selectedParent]
 
 		// Second level: round-robin within the selected credential group.
-		innerKey := key + "::cred:" + selectedParent
-		s.ensureCursorKey(innerKey, limit)
-		innerIndex := s.cursors[innerKey]
-		if innerIndex >= 2_147_483_640 {
-			innerIndex = 0
-		}
-		s.cursors[innerKey] = innerIndex + 1
-		s.mu.Unlock()
+		innerIndex := s.nextCursor(key+"::cred:"+selectedParent, 0)
 		return group[innerIndex%len(group)], nil
 	}
 
 	// Flat round-robin for non-grouped auths (original behavior).
+	index := s.nextCursor(key, 0)
+	return available[index%len(available)], nil
+}
+
+// nextCursor returns the current position of the cursor for key and advances it. A new local
+// cursor starts at a random offset below seedRange when seedRange is positive. In cluster
+// mode positions come from blocks of a shared counter, so instances rotate through disjoint
+// stretches of the same sequence; the local cursor takes over while the backend is failing.
+func (s *RoundRobinSelector) nextCursor(key string, seedRange int) int {
+	if cluster.Shared() {
+		if index, ok := s.nextSharedCursor(key); ok {
+			return index
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cursors == nil {
+		s.cursors = make(map[string]int)
+	}
+	limit := s.maxKeys
+	if limit <= 0 {
+		limit = 4096
+	}
 	s.ensureCursorKey(key, limit)
+	if _, exists := s.cursors[key]; !exists && seedRange > 0 {
+		// Seed with a random initial offset so the starting credential is randomized.
+		s.cursors[key] = rand.IntN(seedRange)
+	}
 	index := s.cursors[key]
-	if index >= 2_147_483_640 {
+	if index >= cursorWrap {
 		index = 0
 	}
 	s.cursors[key] = index + 1
+	return index
+}
+
+// nextSharedCursor takes the next position from this node's block of the shared cursor for
+// key, reserving a new block when it is used up. The reservation runs without s.mu held, so
+// concurrent picks may each reserve a block; the unused positions are simply skipped.
+func (s *RoundRobinSelector) nextSharedCursor(key string) (int, bool) {
+	s.mu.Lock()
+	if lease := s.leases[key]; lease != nil && lease.next < lease.end {
+		index := lease.next
+		lease.next++
+		s.mu.Unlock()
+		return int(index % cursorWrap), true
+	}
+	if time.Now().Before(s.sharedRetryAt) {
+		s.mu.Unlock()
+		return 0, false
+	}
 	s.mu.Unlock()
-	return available[index%len(available)], nil
+
+	first, ok := cluster.Reserve(cluster.NamespaceCursor, key, cursorLeaseSize, cursorLeaseTTL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !ok {
+		s.sharedRetryAt = time.Now().Add(cursorRetryDelay)
+		return 0, false
+	}
+	limit := s.maxKeys
+	if limit <= 0 {
+		limit = 4096
+	}
+	if _, exists := s.leases[key]; s.leases == nil || (!exists && len(s.leases) >= limit) {
+		s.leases = make(map[string]*cursorLease)
+	}
+	s.leases[key] = &cursorLease{next: first + 1, end: first + cursorLeaseSize}
+	return int(first % cursorWrap), true
 }
 
 // ensureCursorKey ensures the cursor map has capacity for the given key.
@@ -8,13 +8,17 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/proxypool"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/upstreamtls"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/proxy"
 )
 
 // defaultRoundTripperProvider returns a per-auth HTTP RoundTripper based on
-// the Auth.ProxyURL value. It caches transports per proxy URL string.
+// the Auth.ProxyURL value. It caches transports per proxy URL string. Auths using
+// a proxy pool get the transport of their assigned member, which records its usage. Transports
+// apply the upstream TLS settings of the credential named by the request context.
 type defaultRoundTripperProvider struct {
 	mu    sync.RWMutex
 	cache map[string]http.RoundTripper
@@ -29,8 +33,11 @@ func (p *defaultRoundTripperProvider) RoundTripperFor(auth *coreauth.Auth) http.
 	if auth == nil {
 		return nil
 	}
+	if rt, ok := proxypool.RoundTripper(auth.ID, auth.Provider, auth.ProxyURL); ok {
+		return rt
+	}
 	proxyStr := strings.TrimSpace(auth.ProxyURL)
-	if proxyStr == "" {
+	if proxyStr == "" || proxypool.IsPoolRef(proxyStr) {
 		return nil
 	}
 	p.mu.RLock()
@@ -70,8 +77,9 @@ func (p *defaultRoundTripperProvider) RoundTripperFor(auth *coreauth.Auth) http.
 		log.Errorf("unsupported proxy scheme: %s", proxyURL.Scheme)
 		return nil
 	}
+	rt = upstreamtls.Wrap(transport)
 	p.mu.Lock()
-	p.cache[proxyStr] = transport
+	p.cache[proxyStr] = rt
 	p.mu.Unlock()
-	return transport
+	return rt
 }
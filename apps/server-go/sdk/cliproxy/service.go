@@ -421,6 +421,17 @@ func (s *Service) ensureExecutorsForAuthWithMode(a *coreauth.Auth, forceReplace
 		s.coreManager.RegisterExecutor(executor.NewIFlowExecutor(s.cfg))
 	case "kimi":
 		s.coreManager.RegisterExecutor(executor.NewKimiExecutor(s.cfg))
+	case "mock":
+		// Keep the executor, and with it the request counters that drive scripted faults,
+		// unless the configuration changed.
+		if !forceReplace {
+			if existing, ok := s.coreManager.Executor("mock"); ok {
+				if _, isMock := existing.(*executor.MockExecutor); isMock {
+					return
+				}
+			}
+		}
+		s.coreManager.RegisterExecutor(executor.NewMockExecutor(s.cfg))
 	default:
 		providerKey := strings.ToLower(strings.TrimSpace(a.Provider))
 		if providerKey == "" {
@@ -466,6 +477,10 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 
 	usage.StartDefault(ctx)
+	if s.coreManager != nil {
+		// The auth manager learns per-credential consumption for quota forecasting.
+		usage.RegisterPlugin(s.coreManager)
+	}
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
@@ -848,6 +863,8 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 	case "kimi":
 		models = registry.GetKimiModels()
 		models = applyExcludedModels(models, excluded)
+	case "mock":
+		models = buildMockConfigModels(s.resolveConfigMockProvider(a))
 	default:
 		// Handle OpenAI-compatibility providers by name using config
 		if s.cfg != nil {
@@ -1048,6 +1065,20 @@ func (s *Service) resolveConfigVertexCompatKey(auth *coreauth.Auth) *config.Vert
 	return nil
 }
 
+func (s *Service) resolveConfigMockProvider(auth *coreauth.Auth) *config.MockProvider {
+	if auth == nil || s.cfg == nil || auth.Attributes == nil {
+		return nil
+	}
+	name := strings.TrimSpace(auth.Attributes["mock_name"])
+	for i := range s.cfg.MockProvider {
+		entry := &s.cfg.MockProvider[i]
+		if strings.EqualFold(strings.TrimSpace(entry.Name), name) {
+			return entry
+		}
+	}
+	return nil
+}
+
 func (s *Service) resolveConfigCodexKey(auth *coreauth.Auth) *config.CodexKey {
 	if auth == nil || s.cfg == nil {
 		return nil
@@ -1544,6 +1575,13 @@ func buildClaudeConfigModels(entry *config.ClaudeKey) []*ModelInfo {
 	return buildConfigModels(entry.Models, "anthropic", "claude")
 }
 
+func buildMockConfigModels(entry *config.MockProvider) []*ModelInfo {
+	if entry == nil {
+		return nil
+	}
+	return buildConfigModels(entry.Models, "mock", "mock")
+}
+
 func buildCodexConfigModels(entry *config.CodexKey) []*ModelInfo {
 	if entry == nil {
 		return nil
@@ -13,6 +13,19 @@ type ClientAPIKeyScope = internalconfig.ClientAPIKeyScope
 type Config = internalconfig.Config
 
 type StreamingConfig = internalconfig.StreamingConfig
+type GuardrailsConfig = internalconfig.GuardrailsConfig
+type ContextCompactionConfig = internalconfig.ContextCompactionConfig
+type StructuredOutputConfig = internalconfig.StructuredOutputConfig
+type ParameterEmulationConfig = internalconfig.ParameterEmulationConfig
+type ParameterPolicyConfig = internalconfig.ParameterPolicyConfig
+type MCPConfig = internalconfig.MCPConfig
+type MCPServerConfig = internalconfig.MCPServerConfig
+type SandboxConfig = internalconfig.SandboxConfig
+type PolicyTemplate = internalconfig.PolicyTemplate
+type RedactionConfig = internalconfig.RedactionConfig
+type RedactionRule = internalconfig.RedactionRule
+type GeminiFilesConfig = internalconfig.GeminiFilesConfig
+type FilesConfig = internalconfig.FilesConfig
 type TLSConfig = internalconfig.TLSConfig
 type RemoteManagement = internalconfig.RemoteManagement
 type AmpCode = internalconfig.AmpCode
@@ -30,11 +43,29 @@ type VertexCompatModel = internalconfig.VertexCompatModel
 type OpenAICompatibility = internalconfig.OpenAICompatibility
 type OpenAICompatibilityAPIKey = internalconfig.OpenAICompatibilityAPIKey
 type OpenAICompatibilityModel = internalconfig.OpenAICompatibilityModel
+type MockProvider = internalconfig.MockProvider
+type MockModel = internalconfig.MockModel
+type MockToolCall = internalconfig.MockToolCall
+type MockUsage = internalconfig.MockUsage
+type MockFault = internalconfig.MockFault
 
 type TLS = internalconfig.TLSConfig
 
 const (
 	DefaultPanelGitHubRepository = internalconfig.DefaultPanelGitHubRepository
+
+	ParameterPolicyStrict  = internalconfig.ParameterPolicyStrict
+	ParameterPolicyLenient = internalconfig.ParameterPolicyLenient
+	ParameterPolicyWarn    = internalconfig.ParameterPolicyWarn
+
+	SandboxToolCodeInterpreter = internalconfig.SandboxToolCodeInterpreter
+	SandboxToolShell           = internalconfig.SandboxToolShell
+	SandboxToolLocalShell      = internalconfig.SandboxToolLocalShell
+
+	RedactionActionMask  = internalconfig.RedactionActionMask
+	RedactionActionBlock = internalconfig.RedactionActionBlock
+	RedactionActionLog   = internalconfig.RedactionActionLog
+	RedactionActionOff   = internalconfig.RedactionActionOff
 )
 
 func LoadConfig(configFile string) (*Config, error) { return internalconfig.LoadConfig(configFile) }
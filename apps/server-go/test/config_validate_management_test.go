@@ -0,0 +1,161 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func newConfigValidateTestRouter(t *testing.T) (*gin.Engine, string, []byte) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	original := []byte("port: 8080\napi-keys:\n  - \"old-key\"\n")
+	if err := os.WriteFile(configPath, original, 0o644); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	h := management.NewHandler(cfg, configPath, coreauth.NewManager(nil, nil, nil))
+
+	r := gin.New()
+	mgmt := r.Group("/v0/management")
+	{
+		mgmt.GET("/config/schema", h.GetConfigSchema)
+		mgmt.PUT("/config.yaml", h.PutConfigYAML)
+		mgmt.POST("/config.yaml/validate", h.PostConfigYAMLValidate)
+	}
+	return r, configPath, original
+}
+
+func TestPostConfigYAMLValidate_DryRunReportsChangesAndEffects(t *testing.T) {
+	r, configPath, original := newConfigValidateTestRouter(t)
+
+	candidate := "port: 8080\napi-keys:\n  - \"new-key\"\n  - \"second-key\"\noauth-model-alias:\n  antigravity:\n    - name: \"gemini-3-pro-high\"\n      alias: \"g3\"\n"
+	req := httptest.NewRequest(http.MethodPost, "/v0/management/config.yaml/validate", bytes.NewBufferString(candidate))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Valid   bool     `json:"valid"`
+		Changes []string `json:"changes"`
+		Effects struct {
+			ModelListings   []string `json:"model-listings"`
+			AccessProviders []string `json:"access-providers"`
+		} `json:"effects"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !resp.Valid {
+		t.Fatalf("expected candidate to be valid: %s", w.Body.String())
+	}
+	if len(resp.Changes) == 0 || len(resp.Effects.ModelListings) == 0 || len(resp.Effects.AccessProviders) == 0 {
+		t.Fatalf("expected changes and effects, got %s", w.Body.String())
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("new-key")) {
+		t.Fatalf("dry run must not leak key material: %s", w.Body.String())
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !bytes.Equal(data, original) {
+		t.Fatalf("dry run must not modify config.yaml, got %q", data)
+	}
+}
+
+func TestPostConfigYAMLValidate_ReportsIssues(t *testing.T) {
+	r, _, _ := newConfigValidateTestRouter(t)
+
+	candidate := "client-api-keys:\n  - kye: \"typo\"\n"
+	req := httptest.NewRequest(http.MethodPost, "/v0/management/config.yaml/validate", bytes.NewBufferString(candidate))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Valid  bool                     `json:"valid"`
+		Issues []config.ValidationIssue `json:"issues"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Valid || len(resp.Issues) == 0 {
+		t.Fatalf("expected invalid result with issues, got %s", w.Body.String())
+	}
+}
+
+func TestPutConfigYAML_RejectsInvalidConfig(t *testing.T) {
+	r, configPath, original := newConfigValidateTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodPut, "/v0/management/config.yaml", bytes.NewBufferString("routing:\n  strategy: random\n"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d: %s", w.Code, w.Body.String())
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !bytes.Equal(data, original) {
+		t.Fatalf("expected config.yaml to be untouched, got %q", data)
+	}
+}
+
+func TestPutConfigYAML_ReturnsWarnings(t *testing.T) {
+	r, _, _ := newConfigValidateTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodPut, "/v0/management/config.yaml", bytes.NewBufferString("port: 8080\nproxy-url: \"ftp://proxy\"\n"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		OK       bool                     `json:"ok"`
+		Warnings []config.ValidationIssue `json:"warnings"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !resp.OK || len(resp.Warnings) == 0 || resp.Warnings[0].Path != "proxy-url" {
+		t.Fatalf("expected proxy-url warning in response, got %s", w.Body.String())
+	}
+}
+
+func TestGetConfigSchema(t *testing.T) {
+	r, _, _ := newConfigValidateTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v0/management/config/schema", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &schema); err != nil {
+		t.Fatalf("decode schema: %v", err)
+	}
+	if schema["type"] != "object" {
+		t.Fatalf("expected object schema, got %v", schema["type"])
+	}
+}